@@ -0,0 +1,276 @@
+package jose
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultAzureADAuthority is the Azure AD (Microsoft Entra ID) authority of the
+// Azure global cloud.
+const DefaultAzureADAuthority = "https://login.microsoftonline.com"
+
+// azureADV1Issuer is the prefix of the issuer of the v1.0 tokens.
+const azureADV1Issuer = "https://sts.windows.net/"
+
+// azureADRefreshInterval is the minimum time between two refreshes of the JWK
+// Set caused by tokens with an unknown kid.
+const azureADRefreshInterval = time.Minute
+
+// AzureADClaims are the claims of an access token issued by Azure AD.
+type AzureADClaims struct {
+	Claims
+	Version         string   `json:"ver,omitempty"`
+	TenantID        string   `json:"tid,omitempty"`
+	ObjectID        string   `json:"oid,omitempty"`
+	AppID           string   `json:"appid,omitempty"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	Scope           string   `json:"scp,omitempty"`
+	Roles           []string `json:"roles,omitempty"`
+}
+
+// AzureADVerifier verifies the access tokens issued by Azure AD for a tenant.
+//
+// The verifier gets the issuer and the JWK Set URL from the OpenID
+// configuration of the tenant, and uses the key with the kid in the token
+// header to verify the signature. If the JWK Set does not have the kid, the JWK
+// Set is retrieved again, at most once per minute, so rotated keys are found
+// before the cached JWK Set expires.
+//
+// Both v1.0 and v2.0 tokens are accepted. The v2.0 tokens must have the issuer
+// in the OpenID configuration, and the v1.0 tokens the issuer
+// https://sts.windows.net/{tid}/ used by the Azure global cloud.
+type AzureADVerifier struct {
+	// TenantID is the tenant ID or domain name, e.g. contoso.onmicrosoft.com.
+	// With the multi-tenant values "common" or "organizations" tokens from any
+	// tenant are accepted, and the caller must check the tenant ID in the
+	// claims.
+	TenantID string
+	// Audience are the accepted audiences, usually the application ID and the
+	// application ID URI.
+	Audience []string
+	// Authority is the Azure AD authority. If it is empty,
+	// DefaultAzureADAuthority is used.
+	Authority string
+	// Fetcher is the JWKSFetcher used to retrieve the JWK Set. Its HTTP client
+	// is also used to retrieve the OpenID configuration. If it is nil, a new
+	// JWKSFetcher using http.DefaultClient is used.
+	Fetcher *JWKSFetcher
+
+	mu          sync.Mutex
+	group       singleflight.Group
+	config      *azureADConfig
+	lastRefresh time.Time
+	now         func() time.Time
+}
+
+type azureADConfig struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewAzureADVerifier creates a new AzureADVerifier for the given tenant that
+// accepts tokens with any of the given audiences.
+func NewAzureADVerifier(tenantID string, audience ...string) *AzureADVerifier {
+	return &AzureADVerifier{
+		TenantID: tenantID,
+		Audience: audience,
+	}
+}
+
+// Verify parses the given token, verifies its signature using the JWK Set of
+// the tenant, and validates its version, issuer, tenant, audience and time
+// claims. It returns the claims of the token if it is valid.
+func (v *AzureADVerifier) Verify(ctx stdcontext.Context, token string) (*AzureADClaims, error) {
+	if v.TenantID == "" {
+		return nil, errors.New("error verifying Azure AD token: tenant ID cannot be empty")
+	}
+	if len(v.Audience) == 0 {
+		return nil, errors.New("error verifying Azure AD token: audience cannot be empty")
+	}
+
+	tok, err := ParseSigned(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Azure AD token")
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("error verifying Azure AD token: token must have one signature")
+	}
+	if err := validateAlgorithm(tok.Headers[0], []SignatureAlgorithm{RS256}); err != nil {
+		return nil, errors.Wrap(err, "error verifying Azure AD token")
+	}
+	kid := tok.Headers[0].KeyID
+	if kid == "" {
+		return nil, errors.New("error verifying Azure AD token: kid header is missing")
+	}
+
+	config, err := v.openIDConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := v.key(ctx, config.JWKSURI, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(AzureADClaims)
+	if err := tok.Claims(jwk.Key, claims); err != nil {
+		return nil, errors.Wrap(err, "error verifying Azure AD token")
+	}
+
+	issuer, err := config.issuer(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateClaims(&claims.Claims, Expected{
+		Issuer:   issuer,
+		Audience: v.Audience,
+		Time:     v.timeNow(),
+	}); err != nil {
+		return nil, errors.Wrap(err, "error verifying Azure AD token")
+	}
+
+	return claims, nil
+}
+
+// issuer returns the expected issuer for the version and tenant of the given
+// claims. The issuer in the OpenID configuration of a multi-tenant authority
+// contains the template {tenantid}, so the tenant can be any; otherwise, the
+// tenant must be the one in the issuer.
+func (c *azureADConfig) issuer(claims *AzureADClaims) (string, error) {
+	if claims.TenantID == "" {
+		return "", errors.New("error verifying Azure AD token: tid claim is missing")
+	}
+	u, err := url.Parse(c.Issuer)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing issuer %s", c.Issuer)
+	}
+	if tenant := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")[0]; tenant != "{tenantid}" && tenant != claims.TenantID {
+		return "", errors.Errorf("error verifying Azure AD token: unexpected tenant %s", claims.TenantID)
+	}
+
+	switch claims.Version {
+	case "1.0":
+		return azureADV1Issuer + claims.TenantID + "/", nil
+	case "2.0":
+		return strings.ReplaceAll(c.Issuer, "{tenantid}", claims.TenantID), nil
+	default:
+		return "", errors.Errorf("error verifying Azure AD token: unsupported version %q", claims.Version)
+	}
+}
+
+// key returns the key with the given kid in the JWK Set in the given URL. The
+// JWK Set is retrieved again if it does not contain the kid and it has not been
+// refreshed in the last minute.
+func (v *AzureADVerifier) key(ctx stdcontext.Context, jwksURI, kid string) (*JSONWebKey, error) {
+	fetcher := v.fetcher()
+	jwks, err := fetcher.Fetch(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	if keys := jwks.Key(kid); len(keys) > 0 {
+		return &keys[0], nil
+	}
+
+	v.mu.Lock()
+	now := v.timeNow()
+	refresh := now.Sub(v.lastRefresh) >= azureADRefreshInterval
+	if refresh {
+		v.lastRefresh = now
+	}
+	v.mu.Unlock()
+
+	if refresh {
+		fetcher.invalidate(jwksURI)
+		if jwks, err = fetcher.Fetch(ctx, jwksURI); err != nil {
+			return nil, err
+		}
+		if keys := jwks.Key(kid); len(keys) > 0 {
+			return &keys[0], nil
+		}
+	}
+	return nil, errors.Wrap(&KeyNotFoundError{Kid: kid}, "error verifying Azure AD token")
+}
+
+// openIDConfig returns the OpenID configuration of the tenant. The
+// configuration is retrieved once and cached, concurrent calls share the same
+// request, and the lock is not held during the request.
+func (v *AzureADVerifier) openIDConfig(ctx stdcontext.Context) (*azureADConfig, error) {
+	v.mu.Lock()
+	config := v.config
+	v.mu.Unlock()
+	if config != nil {
+		return config, nil
+	}
+
+	c, err, _ := v.group.Do("openid-configuration", func() (interface{}, error) {
+		return v.fetchOpenIDConfig(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*azureADConfig), nil
+}
+
+func (v *AzureADVerifier) fetchOpenIDConfig(ctx stdcontext.Context) (*azureADConfig, error) {
+	client := v.fetcher().Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	authority := v.Authority
+	if authority == "" {
+		authority = DefaultAzureADAuthority
+	}
+	configURL := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(v.TenantID) + "/v2.0/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", configURL)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", configURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, errors.Errorf("error retrieving %s: status code %d", configURL, resp.StatusCode)
+	}
+
+	config := new(azureADConfig)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(config); err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", configURL)
+	}
+	if config.Issuer == "" || config.JWKSURI == "" {
+		return nil, errors.Errorf("error retrieving %s: issuer or jwks_uri are missing", configURL)
+	}
+
+	v.mu.Lock()
+	v.config = config
+	v.mu.Unlock()
+	return config, nil
+}
+
+func (v *AzureADVerifier) fetcher() *JWKSFetcher {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.Fetcher == nil {
+		v.Fetcher = NewJWKSFetcher(nil)
+	}
+	return v.Fetcher
+}
+
+func (v *AzureADVerifier) timeNow() time.Time {
+	if v.now != nil {
+		return v.now()
+	}
+	return time.Now()
+}
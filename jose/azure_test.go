@@ -0,0 +1,296 @@
+package jose
+
+import (
+	stdcontext "context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+const testTenantID = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+
+type azureADServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	jwks         JSONWebKeySet
+	keyRequests  int32
+	confRequests int32
+	confBlock    chan struct{}
+}
+
+func newAzureADServer(t *testing.T, keys ...JSONWebKey) *azureADServer {
+	t.Helper()
+	s := &azureADServer{jwks: JSONWebKeySet{Keys: keys}}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		switch r.URL.Path {
+		case "/" + testTenantID + "/v2.0/.well-known/openid-configuration":
+			atomic.AddInt32(&s.confRequests, 1)
+			if s.confBlock != nil {
+				<-s.confBlock
+			}
+			v = map[string]string{
+				"issuer":   "https://login.microsoftonline.com/" + testTenantID + "/v2.0",
+				"jwks_uri": s.URL + "/keys",
+			}
+		case "/common/v2.0/.well-known/openid-configuration":
+			atomic.AddInt32(&s.confRequests, 1)
+			v = map[string]string{
+				"issuer":   "https://login.microsoftonline.com/{tenantid}/v2.0",
+				"jwks_uri": s.URL + "/keys",
+			}
+		case "/bad/v2.0/.well-known/openid-configuration":
+			v = map[string]string{"issuer": "https://login.microsoftonline.com/bad/v2.0"}
+		case "/keys":
+			atomic.AddInt32(&s.keyRequests, 1)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			w.Header().Set("Cache-Control", "max-age=86400")
+			v = s.jwks
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *azureADServer) setKeys(keys ...JSONWebKey) {
+	s.mu.Lock()
+	s.jwks = JSONWebKeySet{Keys: keys}
+	s.mu.Unlock()
+}
+
+func (s *azureADServer) verifier(tenantID string) *AzureADVerifier {
+	v := NewAzureADVerifier(tenantID, "api://my-app", "my-app-id")
+	v.Authority = s.URL
+	v.Fetcher = NewJWKSFetcher(s.Client())
+	return v
+}
+
+func mustSignAzureADToken(t *testing.T, alg SignatureAlgorithm, key interface{}, kid string, claims interface{}) string {
+	t.Helper()
+	so := new(SignerOptions).WithType("JWT")
+	if kid != "" {
+		so.WithHeader("kid", kid)
+	}
+	s, err := NewSigner(SigningKey{Algorithm: alg, Key: key}, so)
+	assert.FatalError(t, err)
+	token, err := Signed(s).Claims(claims).CompactSerialize()
+	assert.FatalError(t, err)
+	return token
+}
+
+func TestAzureADVerifier_Verify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	srv := newAzureADServer(t, JSONWebKey{Key: key.Public(), KeyID: "kid1", Use: "sig"})
+
+	now := time.Now()
+	v1Issuer := "https://sts.windows.net/" + testTenantID + "/"
+	v2Issuer := "https://login.microsoftonline.com/" + testTenantID + "/v2.0"
+	claims := func(ver, iss, tid string, aud ...string) AzureADClaims {
+		return AzureADClaims{
+			Claims: Claims{
+				Issuer:    iss,
+				Subject:   "the-subject",
+				Audience:  aud,
+				Expiry:    NewNumericDate(now.Add(time.Hour)),
+				NotBefore: NewNumericDate(now.Add(-time.Minute)),
+				IssuedAt:  NewNumericDate(now.Add(-time.Minute)),
+			},
+			Version:  ver,
+			TenantID: tid,
+			ObjectID: "the-object-id",
+			Roles:    []string{"Reader"},
+		}
+	}
+	expired := claims("2.0", v2Issuer, testTenantID, "my-app-id")
+	expired.Expiry = NewNumericDate(now.Add(-time.Hour))
+	otherTenant := "00000000-0000-0000-0000-000000000000"
+
+	tests := []struct {
+		name     string
+		tenantID string
+		token    string
+		want     AzureADClaims
+		wantErr  bool
+	}{
+		{"ok v1", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("1.0", v1Issuer, testTenantID, "api://my-app")), claims("1.0", v1Issuer, testTenantID, "api://my-app"), false},
+		{"ok v2", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v2Issuer, testTenantID, "my-app-id")), claims("2.0", v2Issuer, testTenantID, "my-app-id"), false},
+		{"ok common", "common", mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", "https://login.microsoftonline.com/"+otherTenant+"/v2.0", otherTenant, "my-app-id")), claims("2.0", "https://login.microsoftonline.com/"+otherTenant+"/v2.0", otherTenant, "my-app-id"), false},
+		{"fail v1 with v2 issuer", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("1.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail v2 with v1 issuer", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v1Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail version", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("3.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail other tenant", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("1.0", "https://sts.windows.net/"+otherTenant+"/", otherTenant, "my-app-id")), AzureADClaims{}, true},
+		{"fail missing tenant", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v2Issuer, "", "my-app-id")), AzureADClaims{}, true},
+		{"fail audience", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v2Issuer, testTenantID, "other-app")), AzureADClaims{}, true},
+		{"fail expired", testTenantID, mustSignAzureADToken(t, RS256, key, "kid1", expired), AzureADClaims{}, true},
+		{"fail signature", testTenantID, mustSignAzureADToken(t, RS256, other, "kid1", claims("2.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail algorithm", testTenantID, mustSignAzureADToken(t, ES256, ecKey, "kid1", claims("2.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail missing kid", testTenantID, mustSignAzureADToken(t, RS256, key, "", claims("2.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail parse", testTenantID, "not a token", AzureADClaims{}, true},
+		{"fail openid configuration", "missing", mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+		{"fail bad openid configuration", "bad", mustSignAzureADToken(t, RS256, key, "kid1", claims("2.0", v2Issuer, testTenantID, "my-app-id")), AzureADClaims{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := srv.verifier(tt.tenantID).Verify(stdcontext.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AzureADVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equals(t, tt.want.Issuer, got.Issuer)
+			assert.Equals(t, tt.want.Subject, got.Subject)
+			assert.Equals(t, tt.want.Audience, got.Audience)
+			assert.Equals(t, tt.want.Version, got.Version)
+			assert.Equals(t, tt.want.TenantID, got.TenantID)
+			assert.Equals(t, tt.want.ObjectID, got.ObjectID)
+			assert.Equals(t, tt.want.Roles, got.Roles)
+		})
+	}
+
+	t.Run("fail empty tenant", func(t *testing.T) {
+		_, err := srv.verifier("").Verify(stdcontext.Background(), tests[0].token)
+		assert.Error(t, err)
+	})
+
+	t.Run("fail empty audience", func(t *testing.T) {
+		v := srv.verifier(testTenantID)
+		v.Audience = nil
+		_, err := v.Verify(stdcontext.Background(), tests[0].token)
+		assert.Error(t, err)
+	})
+}
+
+func TestAzureADVerifier_Verify_refresh(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+
+	srv := newAzureADServer(t, JSONWebKey{Key: key1.Public(), KeyID: "kid1", Use: "sig"})
+	now := time.Now()
+	v := srv.verifier(testTenantID)
+	v.now = func() time.Time { return now }
+
+	token := func(key *rsa.PrivateKey, kid string) string {
+		return mustSignAzureADToken(t, RS256, key, kid, AzureADClaims{
+			Claims: Claims{
+				Issuer:   "https://login.microsoftonline.com/" + testTenantID + "/v2.0",
+				Audience: Audience{"my-app-id"},
+				Expiry:   NewNumericDate(now.Add(time.Hour)),
+			},
+			Version:  "2.0",
+			TenantID: testTenantID,
+		})
+	}
+	verify := func(t *testing.T, token string, wantKeyRequests int32, wantErr bool) {
+		t.Helper()
+		atomic.StoreInt32(&srv.keyRequests, 0)
+		_, err := v.Verify(stdcontext.Background(), token)
+		if (err != nil) != wantErr {
+			t.Fatalf("AzureADVerifier.Verify() error = %v, wantErr %v", err, wantErr)
+		}
+		assert.Equals(t, wantKeyRequests, atomic.LoadInt32(&srv.keyRequests))
+	}
+
+	// The OpenID configuration and JWK Set are cached.
+	verify(t, token(key1, "kid1"), 1, false)
+	verify(t, token(key1, "kid1"), 0, false)
+	assert.Equals(t, int32(1), atomic.LoadInt32(&srv.confRequests))
+
+	// An unknown kid refreshes the cached JWK Set.
+	srv.setKeys(JSONWebKey{Key: key1.Public(), KeyID: "kid1"}, JSONWebKey{Key: key2.Public(), KeyID: "kid2"})
+	verify(t, token(key2, "kid2"), 1, false)
+	verify(t, token(key2, "kid2"), 0, false)
+
+	// Refreshes are limited to one per minute.
+	verify(t, token(key2, "kid3"), 0, true)
+	now = now.Add(time.Minute)
+	verify(t, token(key2, "kid3"), 1, true)
+	verify(t, token(key2, "kid3"), 0, true)
+	now = now.Add(time.Minute)
+	verify(t, token(key2, "kid3"), 1, true)
+
+	_, err = v.Verify(stdcontext.Background(), token(key2, "kid4"))
+	var notFound *KeyNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.True(t, strings.Contains(err.Error(), "kid4"))
+}
+
+func TestAzureADVerifier_Verify_concurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+
+	srv := newAzureADServer(t, JSONWebKey{Key: key.Public(), KeyID: "kid1", Use: "sig"})
+	srv.confBlock = make(chan struct{})
+	v := srv.verifier(testTenantID)
+	token := mustSignAzureADToken(t, RS256, key, "kid1", AzureADClaims{
+		Claims: Claims{
+			Issuer:   "https://login.microsoftonline.com/" + testTenantID + "/v2.0",
+			Audience: Audience{"my-app-id"},
+			Expiry:   NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Version:  "2.0",
+		TenantID: testTenantID,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(stdcontext.Background(), token); err != nil {
+				t.Errorf("AzureADVerifier.Verify() error = %v", err)
+			}
+		}()
+	}
+
+	// The lock is not held while the OpenID configuration is retrieved.
+	for atomic.LoadInt32(&srv.confRequests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	locked := make(chan struct{})
+	go func() {
+		v.mu.Lock()
+		v.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		close(srv.confBlock)
+		t.Fatal("AzureADVerifier lock is held during the request")
+	}
+
+	close(srv.confBlock)
+	wg.Wait()
+	assert.Equals(t, int32(1), atomic.LoadInt32(&srv.confRequests))
+}
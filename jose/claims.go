@@ -0,0 +1,74 @@
+package jose
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// DefaultLeeway is the leeway used by ValidateClaims to compare the time
+// claims, one minute.
+const DefaultLeeway = jwt.DefaultLeeway
+
+// ValidateClaims checks the claims of a token against the expected values
+// using DefaultLeeway. See ValidateClaimsWithLeeway for more details.
+func ValidateClaims(c *Claims, e Expected) error {
+	return ValidateClaimsWithLeeway(c, e, DefaultLeeway)
+}
+
+// ValidateClaimsWithLeeway checks the claims of a token against the expected
+// values. Expected fields with a zero value are not checked, but if the
+// expected time is zero the current time is used.
+//
+// Unlike Claims.ValidateWithLeeway, which requires all the expected audiences,
+// the token is valid if its aud claim contains any of the expected audiences.
+// The exp, nbf and iat claims are compared with the given leeway, so a token
+// is still valid for the leeway after it expires.
+//
+// It returns ErrInvalidIssuer, ErrInvalidSubject, ErrInvalidID,
+// ErrInvalidAudience, ErrNotValidYet, ErrExpired or ErrIssuedInTheFuture if a
+// claim is not valid.
+func ValidateClaimsWithLeeway(c *Claims, e Expected, leeway time.Duration) error {
+	if c == nil {
+		return errors.New("claims cannot be nil")
+	}
+
+	if e.Issuer != "" && e.Issuer != c.Issuer {
+		return ErrInvalidIssuer
+	}
+	if e.Subject != "" && e.Subject != c.Subject {
+		return ErrInvalidSubject
+	}
+	if e.ID != "" && e.ID != c.ID {
+		return ErrInvalidID
+	}
+	if len(e.Audience) > 0 && !containsAny(c.Audience, e.Audience) {
+		return ErrInvalidAudience
+	}
+
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if c.NotBefore != nil && now.Add(leeway).Before(c.NotBefore.Time()) {
+		return ErrNotValidYet
+	}
+	if c.Expiry != nil && now.Add(-leeway).After(c.Expiry.Time()) {
+		return ErrExpired
+	}
+	if c.IssuedAt != nil && now.Add(leeway).Before(c.IssuedAt.Time()) {
+		return ErrIssuedInTheFuture
+	}
+
+	return nil
+}
+
+func containsAny(aud, expected Audience) bool {
+	for _, v := range expected {
+		if aud.Contains(v) {
+			return true
+		}
+	}
+	return false
+}
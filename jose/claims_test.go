@@ -0,0 +1,96 @@
+package jose
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func TestValidateClaimsWithLeeway(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	at := func(d time.Duration) *NumericDate {
+		return NewNumericDate(now.Add(d))
+	}
+	claims := func(fn func(c *Claims)) *Claims {
+		c := &Claims{
+			Issuer:    "issuer",
+			Subject:   "subject",
+			ID:        "id",
+			Audience:  Audience{"aud1", "aud2"},
+			NotBefore: at(-time.Minute),
+			Expiry:    at(time.Minute),
+			IssuedAt:  at(-time.Minute),
+		}
+		if fn != nil {
+			fn(c)
+		}
+		return c
+	}
+	expected := func(fn func(e *Expected)) Expected {
+		e := Expected{
+			Issuer:   "issuer",
+			Subject:  "subject",
+			ID:       "id",
+			Audience: Audience{"aud1"},
+			Time:     now,
+		}
+		if fn != nil {
+			fn(&e)
+		}
+		return e
+	}
+
+	type args struct {
+		c      *Claims
+		e      Expected
+		leeway time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want error
+	}{
+		{"ok", args{claims(nil), expected(nil), time.Minute}, nil},
+		{"ok empty expected", args{claims(nil), Expected{}, 0}, nil},
+		{"ok no time claims", args{&Claims{}, expected(func(e *Expected) { e.Issuer, e.Subject, e.ID, e.Audience = "", "", "", nil }), 0}, nil},
+		{"ok second audience", args{claims(nil), expected(func(e *Expected) { e.Audience = Audience{"aud2"} }), 0}, nil},
+		{"ok any audience", args{claims(nil), expected(func(e *Expected) { e.Audience = Audience{"other", "aud2"} }), 0}, nil},
+		{"ok single audience", args{claims(func(c *Claims) { c.Audience = Audience{"aud1"} }), expected(func(e *Expected) { e.Audience = Audience{"aud1", "aud2"} }), 0}, nil},
+		{"ok exp at leeway", args{claims(func(c *Claims) { c.Expiry = at(-time.Minute) }), expected(nil), time.Minute}, nil},
+		{"ok nbf at leeway", args{claims(func(c *Claims) { c.NotBefore = at(time.Minute) }), expected(nil), time.Minute}, nil},
+		{"ok iat at leeway", args{claims(func(c *Claims) { c.IssuedAt = at(time.Minute) }), expected(nil), time.Minute}, nil},
+		{"ok exp now", args{claims(func(c *Claims) { c.Expiry = at(0) }), expected(nil), 0}, nil},
+		{"fail issuer", args{claims(nil), expected(func(e *Expected) { e.Issuer = "other" }), 0}, ErrInvalidIssuer},
+		{"fail subject", args{claims(nil), expected(func(e *Expected) { e.Subject = "other" }), 0}, ErrInvalidSubject},
+		{"fail id", args{claims(nil), expected(func(e *Expected) { e.ID = "other" }), 0}, ErrInvalidID},
+		{"fail audience", args{claims(nil), expected(func(e *Expected) { e.Audience = Audience{"other"} }), 0}, ErrInvalidAudience},
+		{"fail missing audience", args{claims(func(c *Claims) { c.Audience = nil }), expected(nil), 0}, ErrInvalidAudience},
+		{"fail exp after leeway", args{claims(func(c *Claims) { c.Expiry = at(-time.Minute - time.Second) }), expected(nil), time.Minute}, ErrExpired},
+		{"fail exp without leeway", args{claims(func(c *Claims) { c.Expiry = at(-time.Second) }), expected(nil), 0}, ErrExpired},
+		{"fail nbf after leeway", args{claims(func(c *Claims) { c.NotBefore = at(time.Minute + time.Second) }), expected(nil), time.Minute}, ErrNotValidYet},
+		{"fail iat after leeway", args{claims(func(c *Claims) { c.IssuedAt = at(time.Minute + time.Second) }), expected(nil), time.Minute}, ErrIssuedInTheFuture},
+		{"fail negative leeway", args{claims(func(c *Claims) { c.Expiry = at(time.Second) }), expected(nil), -2 * time.Second}, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateClaimsWithLeeway(tt.args.c, tt.args.e, tt.args.leeway)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ValidateClaimsWithLeeway() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	now := time.Now()
+
+	// Expected time defaults to now.
+	assert.NoError(t, ValidateClaims(&Claims{Expiry: NewNumericDate(now.Add(time.Minute))}, Expected{}))
+	assert.NoError(t, ValidateClaims(&Claims{Expiry: NewNumericDate(now.Add(-30 * time.Second))}, Expected{}))
+	assert.Equals(t, ErrExpired, ValidateClaims(&Claims{Expiry: NewNumericDate(now.Add(-2 * time.Minute))}, Expected{}))
+	assert.Equals(t, ErrNotValidYet, ValidateClaims(&Claims{NotBefore: NewNumericDate(now.Add(2 * time.Minute))}, Expected{}))
+
+	assert.Error(t, ValidateClaims(nil, Expected{}))
+}
@@ -0,0 +1,130 @@
+package jose
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SignDetached signs the given payload and returns the JWS in the compact
+// serialization format with a detached payload, as defined in RFC 7515
+// Appendix F. The signing algorithm will be guessed from the key if it's not
+// set.
+//
+// To use the unencoded payload option defined in RFC 7797, the options must
+// include the b64 header set to false, e.g. using
+// new(SignerOptions).WithBase64(false). This option will also add b64 to the
+// critical header.
+//
+// The JSON serializations, without the payload member, can be used with
+// WithSerialization.
+func SignDetached(payload []byte, sig SigningKey, opts *SignerOptions, options ...Option) (string, error) {
+	return sign(payload, sig, opts, true, options)
+}
+
+// VerifyDetached verifies the JWS in the compact or JSON serialization formats
+// with a detached payload using the given payload and key.
+//
+// If the protected header contains the b64 header, as defined in RFC 7797, the
+// header must be also in the critical header, and the payload will be used
+// without base64url encoding it if b64 is false.
+//
+// The JWS is rejected if the crit header lists a header that is not b64, use
+// WithKnownCritical to set the list of understood critical headers. The JWS is
+// also rejected if it uses the "none" algorithm, or an algorithm not in the list
+// set with WithAllowedAlgorithms.
+func VerifyDetached(jws string, payload []byte, key interface{}, options ...Option) error {
+	ctx, err := new(context).apply(options...)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	obj, err := ParseDetached(jws, payload)
+	if err != nil {
+		return errors.Wrap(err, "error parsing JWS")
+	}
+	if len(obj.Signatures) != 1 {
+		return errors.New("error verifying JWS: JWS must contain one signature")
+	}
+
+	protected := obj.Signatures[0].Protected
+	if err := validateAlgorithm(protected, ctx.allowedAlgs); err != nil {
+		return errors.Wrap(err, "error verifying JWS")
+	}
+	if err := validateCritical(protected, ctx.knownCritical); err != nil {
+		return errors.Wrap(err, "error verifying JWS")
+	}
+	if v, ok := protected.ExtraHeaders[headerB64]; ok {
+		if _, ok := v.(bool); !ok {
+			return errors.New("error verifying JWS: b64 header must be a boolean")
+		}
+		if !isCritical(protected, headerB64) {
+			return errors.New("error verifying JWS: b64 header must be in the crit header")
+		}
+	}
+
+	if err := obj.DetachedVerify(payload, key); err != nil {
+		return errors.Wrap(err, "error verifying JWS")
+	}
+	return nil
+}
+
+const (
+	headerB64      HeaderKey = "b64"
+	headerCritical HeaderKey = "crit"
+)
+
+func isCritical(h Header, name HeaderKey) bool {
+	crit, ok := h.ExtraHeaders[headerCritical].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, c := range crit {
+		if s, ok := c.(string); ok && s == string(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultKnownCritical are the critical headers understood by default.
+var defaultKnownCritical = []string{string(headerB64)}
+
+// validateCritical checks that the crit header in h, if present, is a list of
+// headers present in h and included in known. If known is nil, the
+// defaultKnownCritical headers are used.
+func validateCritical(h Header, known []string) error {
+	v, ok := h.ExtraHeaders[headerCritical]
+	if !ok {
+		return nil
+	}
+	if known == nil {
+		known = defaultKnownCritical
+	}
+	crit, ok := v.([]interface{})
+	if !ok || len(crit) == 0 {
+		return errors.New("crit header must be a non-empty array of strings")
+	}
+	for _, c := range crit {
+		name, ok := c.(string)
+		if !ok {
+			return errors.New("crit header must be a non-empty array of strings")
+		}
+		if !contains(known, name) {
+			return errors.Errorf("crit header %s is not supported", name)
+		}
+		if _, ok := h.ExtraHeaders[HeaderKey(name)]; !ok {
+			return errors.Errorf("crit header %s is not present in the protected header", name)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
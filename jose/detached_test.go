@@ -0,0 +1,147 @@
+package jose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestSignDetached(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	payload := []byte(`{"large": "payload", "with": "dots..."}`)
+
+	tests := []struct {
+		name string
+		opts *SignerOptions
+		b64  bool
+	}{
+		{"b64 default", nil, true},
+		{"b64 true", new(SignerOptions).WithBase64(true), true},
+		{"b64 false", new(SignerOptions).WithBase64(false), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jws, err := SignDetached(payload, SigningKey{Key: key}, tt.opts)
+			assert.FatalError(t, err)
+
+			parts := strings.Split(jws, ".")
+			assert.Len(t, 3, parts)
+			assert.Equals(t, "", parts[1])
+
+			obj, err := ParseDetached(jws, payload)
+			assert.FatalError(t, err)
+			protected := obj.Signatures[0].Protected
+			assert.Equals(t, ES256, protected.Algorithm)
+			if tt.b64 {
+				_, ok := protected.ExtraHeaders[headerB64]
+				assert.False(t, ok)
+			} else {
+				assert.Equals(t, false, protected.ExtraHeaders[headerB64])
+				assert.True(t, isCritical(protected, headerB64))
+			}
+
+			assert.NoError(t, VerifyDetached(jws, payload, key.Public()))
+			assert.Error(t, VerifyDetached(jws, []byte(`{"other": "payload"}`), key.Public()))
+			assert.Error(t, VerifyDetached(jws, payload, otherKey.Public()))
+		})
+	}
+}
+
+func TestSignDetached_fail(t *testing.T) {
+	_, err := SignDetached([]byte("payload"), SigningKey{Key: "not a key"}, nil)
+	assert.Error(t, err)
+}
+
+func TestVerifyDetached(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	payload := []byte("the payload")
+
+	sign := func(t *testing.T, opts *SignerOptions) *JSONWebSignature {
+		t.Helper()
+		signer, err := NewSigner(SigningKey{Key: key}, opts)
+		assert.FatalError(t, err)
+		jws, err := signer.Sign(payload)
+		assert.FatalError(t, err)
+		return jws
+	}
+	detached := func(t *testing.T, opts *SignerOptions) string {
+		t.Helper()
+		s, err := sign(t, opts).DetachedCompactSerialize()
+		assert.FatalError(t, err)
+		return s
+	}
+
+	attached, err := sign(t, nil).CompactSerialize()
+	assert.FatalError(t, err)
+
+	unknown := new(SignerOptions).WithHeader("foo", "bar").WithCritical("foo")
+	known := WithKnownCritical([]string{"b64", "foo"})
+
+	tests := []struct {
+		name    string
+		jws     string
+		payload []byte
+		options []Option
+		wantErr bool
+	}{
+		{"ok", detached(t, nil), payload, nil, false},
+		{"ok b64 false", detached(t, new(SignerOptions).WithBase64(false)), payload, nil, false},
+		{"ok b64 false known", detached(t, new(SignerOptions).WithBase64(false)), payload, []Option{known}, false},
+		{"ok empty payload", func() string {
+			s, err := SignDetached(nil, SigningKey{Key: key}, nil)
+			assert.FatalError(t, err)
+			return s
+		}(), nil, nil, false},
+		{"fail b64 not critical", detached(t, new(SignerOptions).WithHeader(headerB64, false)), payload, nil, true},
+		{"fail b64 unknown critical", detached(t, new(SignerOptions).WithBase64(false)), payload, []Option{WithKnownCritical([]string{"foo"})}, true},
+		{"fail unknown critical", detached(t, unknown), payload, nil, true},
+		{"fail missing critical", detached(t, new(SignerOptions).WithCritical("foo")), payload, []Option{known}, true},
+		{"ok allowed algorithm", detached(t, nil), payload, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{ES256})}, false},
+		{"fail algorithm not allowed", detached(t, nil), payload, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{RS256, HS256})}, true},
+		{"fail not detached", attached, payload, nil, true},
+		{"fail parse", "not a jws", payload, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyDetached(tt.jws, tt.payload, key.Public(), tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyDetached() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateCritical(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Header
+		known   []string
+		wantErr bool
+	}{
+		{"ok no crit", Header{}, nil, false},
+		{"ok b64", Header{ExtraHeaders: map[HeaderKey]interface{}{"b64": false, "crit": []interface{}{"b64"}}}, nil, false},
+		{"ok known", Header{ExtraHeaders: map[HeaderKey]interface{}{"b64": false, "x5t#S256": "thumbprint", "crit": []interface{}{"b64", "x5t#S256"}}}, []string{"b64", "x5t#S256"}, false},
+		{"fail unknown", Header{ExtraHeaders: map[HeaderKey]interface{}{"x5t#S256": "thumbprint", "crit": []interface{}{"x5t#S256"}}}, nil, true},
+		{"fail not known", Header{ExtraHeaders: map[HeaderKey]interface{}{"b64": false, "crit": []interface{}{"b64"}}}, []string{"x5t#S256"}, true},
+		{"fail missing", Header{ExtraHeaders: map[HeaderKey]interface{}{"crit": []interface{}{"b64"}}}, nil, true},
+		{"fail empty", Header{ExtraHeaders: map[HeaderKey]interface{}{"crit": []interface{}{}}}, nil, true},
+		{"fail not array", Header{ExtraHeaders: map[HeaderKey]interface{}{"b64": false, "crit": "b64"}}, nil, true},
+		{"fail not string", Header{ExtraHeaders: map[HeaderKey]interface{}{"crit": []interface{}{1}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCritical(tt.h, tt.known); (err != nil) != tt.wantErr {
+				t.Errorf("validateCritical() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
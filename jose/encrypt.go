@@ -1,6 +1,8 @@
 package jose
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"encoding/json"
 
 	"github.com/pkg/errors"
@@ -85,6 +87,88 @@ func EncryptJWK(jwk *JSONWebKey, passphrase []byte) (*JSONWebEncryption, error)
 	return Encrypt(b, WithPassword(passphrase), WithContentType("jwk+json"))
 }
 
+// EncryptJWE returns the given data encrypted to multiple recipients with the
+// default content encryption algorithm (A256GCM). The same content encryption
+// key is wrapped for each recipient, and the JWE must be serialized using the
+// general JSON serialization, FullSerialize, if there is more than one
+// recipient.
+//
+// If the algorithm of a recipient is not set, ECDH-ES+A256KW is used for EC
+// keys, RSA-OAEP-256 for RSA keys, and A256GCMKW for symmetric keys. ECDH-ES
+// and direct encryption are not supported, as they do not wrap the key.
+func EncryptJWE(data []byte, recipients []Recipient, opts ...Option) (*JSONWebEncryption, error) {
+	ctx, err := new(context).apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("failed to encrypt the data: missing recipients")
+	}
+
+	rcpts := make([]Recipient, len(recipients))
+	for i, r := range recipients {
+		if r.Algorithm == "" {
+			if r.Algorithm, err = recipientAlgorithm(r.Key); err != nil {
+				return nil, err
+			}
+		}
+		rcpts[i] = r
+	}
+
+	encrypterOptions := new(EncrypterOptions)
+	if ctx.contentType != "" {
+		encrypterOptions.WithContentType(ContentType(ctx.contentType))
+	}
+
+	encrypter, err := NewMultiEncrypter(DefaultEncAlgorithm, rcpts, encrypterOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating cipher")
+	}
+
+	jwe, err := encrypter.Encrypt(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting data")
+	}
+
+	return jwe, nil
+}
+
+// DecryptJWE returns the data in the given JWE, in compact or JSON
+// serialization, decrypted with the given key. If the JWE has multiple
+// recipients, the key is tried against each one of them.
+func DecryptJWE(data []byte, key interface{}) ([]byte, error) {
+	enc, err := ParseEncrypted(string(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing JWE")
+	}
+
+	_, _, b, err := enc.DecryptMulti(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt JWE")
+	}
+
+	return b, nil
+}
+
+// recipientAlgorithm returns the default key management algorithm for a
+// recipient key that can be used with multiple recipients.
+func recipientAlgorithm(key interface{}) (KeyAlgorithm, error) {
+	switch k := key.(type) {
+	case *JSONWebKey:
+		return recipientAlgorithm(k.Key)
+	case JSONWebKey:
+		return recipientAlgorithm(k.Key)
+	case *ecdsa.PublicKey:
+		return ECDH_ES_A256KW, nil
+	case *rsa.PublicKey:
+		return DefaultRSAKeyAlgorithm, nil
+	case []byte:
+		return DefaultOctKeyAlgorithm, nil
+	default:
+		return "", errors.Errorf("unsupported recipient key type %T", key)
+	}
+}
+
 // Decrypt returns the decrypted version of the given data if it's encrypted,
 // it will return the raw data if it's not encrypted or the format is not
 // valid.
@@ -3,6 +3,9 @@ package jose
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -424,3 +427,101 @@ func TestDecrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptJWE_DecryptJWE(t *testing.T) {
+	data := []byte("the-plain-data")
+	ec1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	ec2, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	oct := []byte("0123456789abcdef0123456789abcdef")
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	jwe, err := EncryptJWE(data, []Recipient{
+		{Key: &ec1.PublicKey, KeyID: "ec1"},
+		{Key: &JSONWebKey{Key: &ec2.PublicKey, KeyID: "ec2"}},
+		{Key: &rsaKey.PublicKey},
+		{Key: oct},
+	}, WithContentType("text/plain"))
+	assert.FatalError(t, err)
+	serialized := []byte(jwe.FullSerialize())
+
+	var general struct {
+		Recipients []struct {
+			Header struct {
+				Alg string `json:"alg"`
+				Kid string `json:"kid"`
+			} `json:"header"`
+		} `json:"recipients"`
+	}
+	assert.FatalError(t, json.Unmarshal(serialized, &general))
+	assert.Len(t, 4, general.Recipients)
+	assert.Equals(t, string(ECDH_ES_A256KW), general.Recipients[0].Header.Alg)
+	assert.Equals(t, "ec1", general.Recipients[0].Header.Kid)
+	assert.Equals(t, string(ECDH_ES_A256KW), general.Recipients[1].Header.Alg)
+	assert.Equals(t, "ec2", general.Recipients[1].Header.Kid)
+	assert.Equals(t, string(RSA_OAEP_256), general.Recipients[2].Header.Alg)
+	assert.Equals(t, string(A256GCMKW), general.Recipients[3].Header.Alg)
+
+	single, err := EncryptJWE(data, []Recipient{{Key: &ec1.PublicKey}})
+	assert.FatalError(t, err)
+	compact, err := single.CompactSerialize()
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     interface{}
+		want    []byte
+		wantErr bool
+	}{
+		{"ok first EC recipient", serialized, ec1, data, false},
+		{"ok second EC recipient", serialized, ec2, data, false},
+		{"ok JWK recipient", serialized, &JSONWebKey{Key: ec2, KeyID: "ec2"}, data, false},
+		{"ok RSA recipient", serialized, rsaKey, data, false},
+		{"ok oct recipient", serialized, oct, data, false},
+		{"ok compact", []byte(compact), ec1, data, false},
+		{"fail other key", serialized, other, nil, true},
+		{"fail parse", []byte("not a jwe"), ec1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptJWE(tt.data, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecryptJWE() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecryptJWE() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptJWE_fail(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name       string
+		recipients []Recipient
+		opts       []Option
+	}{
+		{"fail no recipients", nil, nil},
+		{"fail key type", []Recipient{{Key: edKey.Public()}}, nil},
+		{"fail ECDH-ES", []Recipient{{Key: &ec.PublicKey, Algorithm: ECDH_ES}, {Key: &ec.PublicKey}}, nil},
+		{"fail apply", []Recipient{{Key: &ec.PublicKey}}, []Option{WithPasswordFile("testdata/missing.txt")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncryptJWE([]byte("the-plain-data"), tt.recipients, tt.opts...)
+			assert.Error(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
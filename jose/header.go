@@ -0,0 +1,130 @@
+package jose
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TokenHeader is the protected header of a signed token with the registered
+// header parameters decoded. Unlike Header, it includes the x5c certificates
+// without verifying them, and the crit header.
+type TokenHeader struct {
+	// Algorithm is the alg header.
+	Algorithm string
+	// KeyID is the kid header.
+	KeyID string
+	// Type is the typ header.
+	Type string
+	// ContentType is the cty header.
+	ContentType string
+	// Nonce is the nonce header.
+	Nonce string
+	// JSONWebKey is the jwk header.
+	JSONWebKey *JSONWebKey
+	// X5C is the certificate chain in the x5c header. The certificates are
+	// not verified.
+	X5C []*x509.Certificate
+	// X5TS256 is the SHA-256 thumbprint in the x5t#S256 header.
+	X5TS256 []byte
+	// Critical is the list of headers in the crit header.
+	Critical []string
+	// Extra are the rest of headers in the protected header.
+	Extra map[HeaderKey]interface{}
+}
+
+// registeredHeaders are the headers decoded in a TokenHeader, the rest of them
+// are added to Extra.
+var registeredHeaders = []string{"alg", "kid", "typ", "cty", "nonce", "jwk", "x5c", X5TS256Key, string(headerCritical)}
+
+type rawTokenHeader struct {
+	Algorithm   string        `json:"alg"`
+	KeyID       string        `json:"kid"`
+	Type        string        `json:"typ"`
+	ContentType string        `json:"cty"`
+	Nonce       string        `json:"nonce"`
+	JSONWebKey  *JSONWebKey   `json:"jwk"`
+	X5C         []interface{} `json:"x5c"`
+	X5TS256     *string       `json:"x5t#S256"`
+	Critical    []string      `json:"crit"`
+}
+
+// SignedToken is a JSONWebToken with access to its decoded protected header.
+type SignedToken struct {
+	*JSONWebToken
+	header *TokenHeader
+}
+
+// Header returns the decoded protected header of the token.
+func (t *SignedToken) Header() *TokenHeader {
+	return t.header
+}
+
+// ParseSignedWithHeader parses a token in the compact serialization format like
+// ParseSigned, and it decodes its protected header. The signature is not
+// verified.
+func ParseSignedWithHeader(s string) (*SignedToken, error) {
+	tok, err := ParseSigned(s)
+	if err != nil {
+		return nil, err
+	}
+	header, err := parseTokenHeader(s)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedToken{
+		JSONWebToken: tok,
+		header:       header,
+	}, nil
+}
+
+// parseTokenHeader decodes the protected header of the given compact
+// serialized token.
+func parseTokenHeader(s string) (*TokenHeader, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("error parsing token header: compact JWS format must have three parts")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding token header")
+	}
+
+	var raw rawTokenHeader
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, errors.Wrap(err, "error parsing token header")
+	}
+	var extra map[HeaderKey]interface{}
+	if err := json.Unmarshal(b, &extra); err != nil {
+		return nil, errors.Wrap(err, "error parsing token header")
+	}
+	for _, k := range registeredHeaders {
+		delete(extra, HeaderKey(k))
+	}
+
+	h := &TokenHeader{
+		Algorithm:   raw.Algorithm,
+		KeyID:       raw.KeyID,
+		Type:        raw.Type,
+		ContentType: raw.ContentType,
+		Nonce:       raw.Nonce,
+		JSONWebKey:  raw.JSONWebKey,
+		Critical:    raw.Critical,
+		Extra:       extra,
+	}
+	if raw.X5C != nil {
+		if h.X5C, err = decodeCerts(raw.X5C); err != nil {
+			return nil, errors.Wrap(err, "error decoding x5c header")
+		}
+	}
+	if raw.X5TS256 != nil {
+		// Accept both the padded and unpadded base64 URL encodings.
+		if h.X5TS256, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(*raw.X5TS256, "=")); err != nil {
+			return nil, errors.Wrap(err, "error decoding x5t#S256 header")
+		}
+	}
+	return h, nil
+}
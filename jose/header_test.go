@@ -0,0 +1,127 @@
+package jose
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func TestParseSignedWithHeader(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	leaf, err := ca.Sign(&x509.Certificate{
+		DNSNames:  []string{"leaf.example.com"},
+		PublicKey: signer.Public(),
+	})
+	assert.FatalError(t, err)
+	x5c := []string{
+		base64.StdEncoding.EncodeToString(leaf.Raw),
+		base64.StdEncoding.EncodeToString(ca.Intermediate.Raw),
+	}
+	fp := sha256.Sum256(leaf.Raw)
+
+	so := new(SignerOptions).WithType("JWT").
+		WithHeader("kid", "the-kid").
+		WithHeader("x5c", x5c).
+		WithHeader(HeaderKey(X5TS256Key), base64.RawURLEncoding.EncodeToString(fp[:])).
+		WithHeader("crit", []string{"exp-custom"}).
+		WithHeader("exp-custom", "value")
+	s, err := NewSigner(SigningKey{Algorithm: ES256, Key: signer}, so)
+	assert.FatalError(t, err)
+	claims := Claims{
+		Subject: "subject",
+		Expiry:  NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	token, err := Signed(s).Claims(claims).CompactSerialize()
+	assert.FatalError(t, err)
+
+	tok, err := ParseSignedWithHeader(token)
+	assert.FatalError(t, err)
+	h := tok.Header()
+	assert.Equals(t, ES256, h.Algorithm)
+	assert.Equals(t, "the-kid", h.KeyID)
+	assert.Equals(t, "JWT", h.Type)
+	assert.Equals(t, "", h.ContentType)
+	assert.Nil(t, h.JSONWebKey)
+	assert.Equals(t, []*x509.Certificate{leaf, ca.Intermediate}, h.X5C)
+	assert.Equals(t, fp[:], h.X5TS256)
+	assert.Equals(t, []string{"exp-custom"}, h.Critical)
+	assert.Equals(t, map[HeaderKey]interface{}{"exp-custom": "value"}, h.Extra)
+
+	// The embedded token gives access to the claims. Verification would fail,
+	// go-jose rejects tokens with unknown critical headers.
+	var got Claims
+	assert.FatalError(t, tok.UnsafeClaimsWithoutVerification(&got))
+	assert.Equals(t, claims, got)
+	assert.Error(t, tok.Claims(signer.Public(), &got))
+}
+
+func TestParseSignedWithHeader_jwk(t *testing.T) {
+	jwk, err := GenerateJWK("EC", "P-256", ES256, "sig", "", 0)
+	assert.FatalError(t, err)
+	pub := jwk.Public()
+
+	so := new(SignerOptions).WithHeader("jwk", pub).WithHeader("nonce", "the-nonce")
+	s, err := NewSigner(SigningKey{Algorithm: ES256, Key: jwk.Key}, so)
+	assert.FatalError(t, err)
+	token, err := Signed(s).Claims(Claims{Subject: "subject"}).CompactSerialize()
+	assert.FatalError(t, err)
+
+	tok, err := ParseSignedWithHeader(token)
+	assert.FatalError(t, err)
+	h := tok.Header()
+	assert.Equals(t, "the-nonce", h.Nonce)
+	assert.Equals(t, "", h.Type)
+	assert.Nil(t, h.X5C)
+	assert.Nil(t, h.X5TS256)
+	assert.Nil(t, h.Critical)
+	assert.Equals(t, map[HeaderKey]interface{}{}, h.Extra)
+	assert.NotNil(t, h.JSONWebKey)
+	assert.Equals(t, pub.KeyID, h.JSONWebKey.KeyID)
+	assert.True(t, keyutil.PublicKeysEqual(pub.Key, h.JSONWebKey.Key))
+}
+
+func TestParseSignedWithHeader_fail(t *testing.T) {
+	jwk, err := GenerateJWK("EC", "P-256", ES256, "sig", "", 0)
+	assert.FatalError(t, err)
+	sign := func(so *SignerOptions) string {
+		s, err := NewSigner(SigningKey{Algorithm: ES256, Key: jwk.Key}, so)
+		assert.FatalError(t, err)
+		token, err := Signed(s).Claims(Claims{Subject: "subject"}).CompactSerialize()
+		assert.FatalError(t, err)
+		return token
+	}
+	replaceHeader := func(token, header string) string {
+		parts := strings.Split(token, ".")
+		parts[0] = base64.RawURLEncoding.EncodeToString([]byte(header))
+		return strings.Join(parts, ".")
+	}
+	token := sign(nil)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"fail parse", "not-a-token"},
+		{"fail x5c base64", sign(new(SignerOptions).WithHeader("x5c", []string{"%%%"}))},
+		{"fail x5c certificate", sign(new(SignerOptions).WithHeader("x5c", []string{base64.StdEncoding.EncodeToString([]byte("foo"))}))},
+		{"fail x5t#S256", sign(new(SignerOptions).WithHeader(HeaderKey(X5TS256Key), "%%%"))},
+		{"fail crit type", replaceHeader(token, `{"alg":"ES256","crit":"exp-custom"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := ParseSignedWithHeader(tt.token)
+			assert.Error(t, err)
+			assert.Nil(t, tok)
+		})
+	}
+}
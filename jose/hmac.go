@@ -0,0 +1,103 @@
+package jose
+
+import (
+	"github.com/pkg/errors"
+)
+
+// hmacMinKeySize are the minimum key sizes, in bytes, for the HMAC signature
+// algorithms. RFC 7518 Section 3.2 requires keys of the same size as the hash
+// output or larger.
+var hmacMinKeySize = map[SignatureAlgorithm]int{
+	HS256: 32,
+	HS384: 48,
+	HS512: 64,
+}
+
+// NewHMACSigner creates a new signer for the HS256, HS384 or HS512 signature
+// algorithms using the given oct JSONWebKey. The algorithm is the one in the
+// key, HS256 is used if the key does not have one. The key is rejected if it is
+// shorter than the output of the hash function.
+//
+// The returned signer can be used to sign a JWS or a JWT using Signed. The kid
+// header is set if the key has a key id.
+func NewHMACSigner(jwk *JSONWebKey, opts *SignerOptions) (Signer, error) {
+	alg, secret, err := hmacKey(jwk)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.KeyID != "" {
+		// Copy the options to not modify the headers of the caller.
+		o := new(SignerOptions)
+		if opts != nil {
+			*o = *opts
+			o.ExtraHeaders = make(map[HeaderKey]interface{}, len(opts.ExtraHeaders)+1)
+			for k, v := range opts.ExtraHeaders {
+				o.ExtraHeaders[k] = v
+			}
+		}
+		opts = o.WithHeader("kid", jwk.KeyID)
+	}
+	return NewSigner(SigningKey{
+		Algorithm: alg,
+		Key:       secret,
+	}, opts)
+}
+
+// VerifyHMAC verifies the given JWS or JWT, in compact or JSON serialization
+// format, using the given oct JSONWebKey and returns the payload. The JWS must
+// contain one signature, and it must use the algorithm of the key, HS256 if
+// the key does not have one. Use WithAllowedAlgorithms to restrict the
+// algorithms accepted.
+func VerifyHMAC(jws string, jwk *JSONWebKey, options ...Option) ([]byte, error) {
+	ctx, err := new(context).apply(options...)
+	if err != nil {
+		return nil, err
+	}
+	alg, secret, err := hmacKey(jwk)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := ParseJWS(jws)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing JWS")
+	}
+	if len(obj.Signatures) != 1 {
+		return nil, errors.New("error verifying JWS: JWS must contain one signature")
+	}
+	if err := validateAlgorithm(obj.Signatures[0].Protected, ctx.allowedAlgs); err != nil {
+		return nil, errors.Wrap(err, "error verifying JWS")
+	}
+	if a := obj.Signatures[0].Header.Algorithm; a != string(alg) {
+		return nil, errors.Errorf("error verifying JWS: unexpected algorithm %s, want %s", a, alg)
+	}
+	payload, err := obj.Verify(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying JWS")
+	}
+	return payload, nil
+}
+
+// hmacKey returns the signature algorithm and the secret of the given oct
+// JSONWebKey.
+func hmacKey(jwk *JSONWebKey) (SignatureAlgorithm, []byte, error) {
+	if jwk == nil {
+		return "", nil, errors.New("key cannot be nil")
+	}
+	secret, ok := jwk.Key.([]byte)
+	if !ok {
+		return "", nil, errors.Errorf("invalid key type %T, want an oct key", jwk.Key)
+	}
+
+	alg := SignatureAlgorithm(jwk.Algorithm)
+	if alg == "" {
+		alg = DefaultOctSigAlgorithm
+	}
+	size, ok := hmacMinKeySize[alg]
+	if !ok {
+		return "", nil, errors.Errorf("unsupported algorithm %s for kty 'oct'", alg)
+	}
+	if len(secret) < size {
+		return "", nil, errors.Errorf("invalid key size %d for algorithm %s, want at least %d bytes", len(secret), alg, size)
+	}
+	return alg, secret, nil
+}
@@ -0,0 +1,143 @@
+package jose
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func mustOctKey(t *testing.T, size int, alg, kid string) *JSONWebKey {
+	t.Helper()
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	assert.FatalError(t, err)
+	return &JSONWebKey{Key: b, Algorithm: alg, KeyID: kid}
+}
+
+func TestNewHMACSigner_VerifyHMAC(t *testing.T) {
+	now := time.Now()
+	claims := Claims{
+		Issuer:   "issuer",
+		Subject:  "subject",
+		Audience: Audience{"audience"},
+		IssuedAt: NewNumericDate(now),
+		Expiry:   NewNumericDate(now.Add(time.Minute)),
+	}
+
+	tests := []struct {
+		name    string
+		jwk     *JSONWebKey
+		wantAlg string
+	}{
+		{"ok default", mustOctKey(t, 32, "", ""), HS256},
+		{"ok HS256", mustOctKey(t, 32, HS256, "the-kid"), HS256},
+		{"ok HS384", mustOctKey(t, 48, HS384, ""), HS384},
+		{"ok HS512", mustOctKey(t, 64, HS512, "the-kid"), HS512},
+		{"ok HS256 long key", mustOctKey(t, 64, HS256, ""), HS256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := new(SignerOptions).WithType("JWT")
+			signer, err := NewHMACSigner(tt.jwk, opts)
+			assert.FatalError(t, err)
+			_, ok := opts.ExtraHeaders["kid"]
+			assert.False(t, ok, "NewHMACSigner() modified the given options")
+			token, err := Signed(signer).Claims(claims).CompactSerialize()
+			assert.FatalError(t, err)
+
+			tok, err := ParseSigned(token)
+			assert.FatalError(t, err)
+			assert.Len(t, 1, tok.Headers)
+			assert.Equals(t, tt.wantAlg, tok.Headers[0].Algorithm)
+			assert.Equals(t, tt.jwk.KeyID, tok.Headers[0].KeyID)
+
+			payload, err := VerifyHMAC(token, tt.jwk)
+			assert.FatalError(t, err)
+			assert.NotNil(t, payload)
+
+			var got Claims
+			assert.FatalError(t, tok.Claims(tt.jwk.Key, &got))
+			assert.Equals(t, claims, got)
+
+			_, err = VerifyHMAC(token, tt.jwk, WithAllowedAlgorithms([]SignatureAlgorithm{SignatureAlgorithm(tt.wantAlg)}))
+			assert.FatalError(t, err)
+			_, err = VerifyHMAC(token, tt.jwk, WithAllowedAlgorithms([]SignatureAlgorithm{RS256, ES256}))
+			assert.Error(t, err)
+
+			// Other key with the same algorithm.
+			_, err = VerifyHMAC(token, mustOctKey(t, len(tt.jwk.Key.([]byte)), tt.jwk.Algorithm, ""))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewHMACSigner_fail(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  *JSONWebKey
+	}{
+		{"fail nil", nil},
+		{"fail key type", func() *JSONWebKey {
+			jwk, err := GenerateJWK("EC", "P-256", ES256, "sig", "", 0)
+			assert.FatalError(t, err)
+			return jwk
+		}()},
+		{"fail algorithm", mustOctKey(t, 32, RS256, "")},
+		{"fail default short", mustOctKey(t, 31, "", "")},
+		{"fail HS256 short", mustOctKey(t, 16, HS256, "")},
+		{"fail HS384 short", mustOctKey(t, 32, HS384, "")},
+		{"fail HS512 short", mustOctKey(t, 48, HS512, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewHMACSigner(tt.jwk, nil)
+			assert.Error(t, err)
+			assert.Nil(t, signer)
+		})
+	}
+}
+
+func TestVerifyHMAC_fail(t *testing.T) {
+	jwk := mustOctKey(t, 64, HS256, "")
+	signer, err := NewHMACSigner(jwk, nil)
+	assert.FatalError(t, err)
+	jws, err := signer.Sign([]byte("the payload"))
+	assert.FatalError(t, err)
+	token, err := jws.CompactSerialize()
+	assert.FatalError(t, err)
+
+	// The same secret used with a different algorithm.
+	hs512 := &JSONWebKey{Key: jwk.Key, Algorithm: HS512}
+	hs512Signer, err := NewHMACSigner(hs512, nil)
+	assert.FatalError(t, err)
+	jws, err = hs512Signer.Sign([]byte("the payload"))
+	assert.FatalError(t, err)
+	hs512Token, err := jws.CompactSerialize()
+	assert.FatalError(t, err)
+
+	short := &JSONWebKey{Key: jwk.Key.([]byte)[:16], Algorithm: HS256}
+
+	tests := []struct {
+		name string
+		jws  string
+		jwk  *JSONWebKey
+	}{
+		{"fail short key", token, short},
+		{"fail algorithm", hs512Token, jwk},
+		{"fail parse", "not-a-jws", jwk},
+		{"fail signature", token[:len(token)-2], jwk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := VerifyHMAC(tt.jws, tt.jwk)
+			assert.Error(t, err)
+			assert.Nil(t, payload)
+		})
+	}
+
+	payload, err := VerifyHMAC(token, jwk)
+	assert.FatalError(t, err)
+	assert.Equals(t, []byte("the payload"), payload)
+}
@@ -0,0 +1,266 @@
+package jose
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// KeyNotFoundError is the error returned by SelectKey if the JWK Set does not
+// contain a key matching the given kid, use and alg.
+type KeyNotFoundError struct {
+	Kid string
+	Use string
+	Alg string
+}
+
+// Error implements the error interface.
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("cannot find key with kid %q, use %q and alg %q", e.Kid, e.Use, e.Alg)
+}
+
+// ReadJWKS parses the given bytes as a JWK Set. The base64url encoded members
+// of the keys can be padded or unpadded.
+func ReadJWKS(b []byte) (*JSONWebKeySet, error) {
+	jwks := new(JSONWebKeySet)
+	if err := json.Unmarshal(trimJWKPadding(b), jwks); err != nil {
+		return nil, errors.Wrap(err, "error parsing JWK Set")
+	}
+	return jwks, nil
+}
+
+// SelectKey returns the key in the JWK Set with the given kid, use and alg. An
+// empty use or alg matches any key, and a key without use or alg matches any
+// requested value. SelectKey returns a *KeyNotFoundError if no key matches, and
+// an error if more than one key matches.
+func SelectKey(jwks *JSONWebKeySet, kid, use, alg string) (*JSONWebKey, error) {
+	var found []JSONWebKey
+	for _, k := range jwks.Key(kid) {
+		if use != "" && k.Use != "" && k.Use != use {
+			continue
+		}
+		if alg != "" && k.Algorithm != "" && k.Algorithm != alg {
+			continue
+		}
+		found = append(found, k)
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, &KeyNotFoundError{Kid: kid, Use: use, Alg: alg}
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, errors.Errorf("multiple keys with kid %q, use %q and alg %q have been found", kid, use, alg)
+	}
+}
+
+// maxJWKSSize is the maximum size of the JWK Sets retrieved by JWKSFetcher.
+const maxJWKSSize = 1 << 20
+
+// jwksFetchTimeout is the maximum duration of a request made by JWKSFetcher.
+// The request is shared by concurrent callers, so it does not use the context
+// of any of them.
+const jwksFetchTimeout = 30 * time.Second
+
+type jwksCacheEntry struct {
+	jwks    *JSONWebKeySet
+	etag    string
+	expires time.Time
+}
+
+// JWKSFetcher retrieves JWK Sets from a URL and caches them using the
+// Cache-Control max-age and ETag headers of the response. A cached JWK Set is
+// returned until it expires; after that, the request includes the ETag, if
+// any, so the server can reply with a 304 Not Modified.
+type JWKSFetcher struct {
+	// Client is the HTTP client used to retrieve the JWK Sets. If it is nil,
+	// http.DefaultClient will be used.
+	Client *http.Client
+
+	mu    sync.Mutex
+	group singleflight.Group
+	cache map[string]*jwksCacheEntry
+	now   func() time.Time
+}
+
+// NewJWKSFetcher creates a new JWKSFetcher that uses the given HTTP client.
+func NewJWKSFetcher(client *http.Client) *JWKSFetcher {
+	return &JWKSFetcher{
+		Client: client,
+		cache:  make(map[string]*jwksCacheEntry),
+		now:    time.Now,
+	}
+}
+
+var defaultJWKSFetcher = NewJWKSFetcher(nil)
+
+// FetchJWKS retrieves the JWK Set in the given URL using a shared JWKSFetcher.
+// URLs must start with "https://".
+func FetchJWKS(ctx stdcontext.Context, url string) (*JSONWebKeySet, error) {
+	return defaultJWKSFetcher.Fetch(ctx, url)
+}
+
+// Fetch retrieves the JWK Set in the given URL, or returns the cached one if it
+// has not expired yet. URLs must start with "https://". Concurrent calls for
+// the same URL share the same request; the given context only limits how long
+// the caller waits for it, canceling it does not cancel the request for the
+// other callers. The returned JWK Set is shared with other callers and must not
+// be modified.
+func (f *JWKSFetcher) Fetch(ctx stdcontext.Context, url string) (*JSONWebKeySet, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, errors.Errorf("error retrieving %s: url must start with https://", url)
+	}
+	if jwks, ok := f.cached(url); ok {
+		return jwks, nil
+	}
+	ch := f.group.DoChan(url, func() (interface{}, error) {
+		ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), jwksFetchTimeout)
+		defer cancel()
+		return f.fetch(ctx, url)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "error retrieving %s", url)
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*JSONWebKeySet), nil
+	}
+}
+
+// cached returns the JWK Set in the cache if it has not expired yet.
+func (f *JWKSFetcher) cached(url string) (*JSONWebKeySet, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.cache[url]; ok && f.timeNow().Before(entry.expires) {
+		return entry.jwks, true
+	}
+	return nil, false
+}
+
+// fetch retrieves the JWK Set in the given URL and updates the cache. The lock
+// is not held during the request.
+func (f *JWKSFetcher) fetch(ctx stdcontext.Context, url string) (*JSONWebKeySet, error) {
+	f.mu.Lock()
+	entry, ok := f.cache[url]
+	f.mu.Unlock()
+
+	// Another call might have updated the cache.
+	if ok && f.timeNow().Before(entry.expires) {
+		return entry.jwks, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", url)
+	}
+	if ok && entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", url)
+	}
+	defer resp.Body.Close()
+
+	maxAge, cacheable := parseCacheControl(resp.Header.Get("Cache-Control"))
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		f.update(url, &jwksCacheEntry{
+			jwks:    entry.jwks,
+			etag:    entry.etag,
+			expires: f.timeNow().Add(maxAge),
+		}, cacheable)
+		return entry.jwks, nil
+	case resp.StatusCode >= 300:
+		return nil, errors.Errorf("error retrieving %s: status code %d", url, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", url)
+	}
+	if len(b) > maxJWKSSize {
+		return nil, errors.Errorf("error retrieving %s: response is larger than %d bytes", url, maxJWKSSize)
+	}
+	jwks, err := ReadJWKS(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", url)
+	}
+
+	etag := resp.Header.Get("ETag")
+	f.update(url, &jwksCacheEntry{
+		jwks:    jwks,
+		etag:    etag,
+		expires: f.timeNow().Add(maxAge),
+	}, cacheable && (maxAge > 0 || etag != ""))
+
+	return jwks, nil
+}
+
+// update stores the given entry in the cache, or removes the cached entry if
+// the response cannot be stored.
+func (f *JWKSFetcher) update(url string, entry *jwksCacheEntry, store bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cache == nil {
+		f.cache = make(map[string]*jwksCacheEntry)
+	}
+	if store {
+		f.cache[url] = entry
+	} else {
+		delete(f.cache, url)
+	}
+}
+
+func (f *JWKSFetcher) timeNow() time.Time {
+	if f.now != nil {
+		return f.now()
+	}
+	return time.Now()
+}
+
+// invalidate removes the cached JWK Set in the given URL, so the next Fetch
+// retrieves it again.
+func (f *JWKSFetcher) invalidate(url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.cache, url)
+}
+
+// parseCacheControl returns the max-age in the given Cache-Control header and
+// if the response can be stored. A response with no-cache, or without max-age,
+// can be stored but must be revalidated before using it.
+func parseCacheControl(header string) (time.Duration, bool) {
+	var maxAge time.Duration
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return 0, false
+		case directive == "no-cache":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			if n, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && n > 0 {
+				maxAge = time.Duration(n) * time.Second
+			}
+		}
+	}
+	return maxAge, true
+}
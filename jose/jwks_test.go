@@ -0,0 +1,307 @@
+package jose
+
+import (
+	"bytes"
+	stdcontext "context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func testJWKS(t *testing.T) *JSONWebKeySet {
+	t.Helper()
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	return &JSONWebKeySet{Keys: []JSONWebKey{
+		{Key: p256.Public(), KeyID: "ec", Algorithm: ES256, Use: "sig"},
+		{Key: p384.Public(), KeyID: "ec", Algorithm: ES384, Use: "sig"},
+		{Key: rsaKey.Public(), KeyID: "rsa", Algorithm: RS256, Use: "sig"},
+		{Key: rsaKey.Public(), KeyID: "rsa", Algorithm: string(RSA_OAEP_256), Use: "enc"},
+		{Key: edPub, KeyID: "ed"},
+	}}
+}
+
+func TestReadJWKS(t *testing.T) {
+	jwks := testJWKS(t)
+	b, err := json.Marshal(jwks)
+	assert.FatalError(t, err)
+
+	got, err := ReadJWKS(b)
+	assert.FatalError(t, err)
+	assert.Len(t, 5, got.Keys)
+	for i := range got.Keys {
+		assert.Equals(t, jwks.Keys[i].KeyID, got.Keys[i].KeyID)
+		assert.Equals(t, jwks.Keys[i].Algorithm, got.Keys[i].Algorithm)
+		assert.Equals(t, jwks.Keys[i].Use, got.Keys[i].Use)
+		assert.Equals(t, jwks.Keys[i].Key, got.Keys[i].Key)
+	}
+
+	padded, err := os.ReadFile("testdata/jwks.padded.json")
+	assert.FatalError(t, err)
+	unpadded, err := os.ReadFile("testdata/jwks.json")
+	assert.FatalError(t, err)
+	got, err = ReadJWKS(padded)
+	assert.FatalError(t, err)
+	want, err := ReadJWKS(unpadded)
+	assert.FatalError(t, err)
+	assert.Len(t, len(want.Keys), got.Keys)
+	for i := range got.Keys {
+		assert.Equals(t, want.Keys[i].Key, got.Keys[i].Key)
+	}
+
+	_, err = ReadJWKS([]byte("not a jwks"))
+	assert.Error(t, err)
+}
+
+func TestSelectKey(t *testing.T) {
+	jwks := testJWKS(t)
+	type args struct {
+		kid string
+		use string
+		alg string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		want     *JSONWebKey
+		notFound bool
+		wantErr  bool
+	}{
+		{"ok ES256", args{"ec", "sig", ES256}, &jwks.Keys[0], false, false},
+		{"ok ES384", args{"ec", "", ES384}, &jwks.Keys[1], false, false},
+		{"ok RS256 by use", args{"rsa", "sig", ""}, &jwks.Keys[2], false, false},
+		{"ok RSA-OAEP-256 by use", args{"rsa", "enc", ""}, &jwks.Keys[3], false, false},
+		{"ok RSA-OAEP-256 by alg", args{"rsa", "", string(RSA_OAEP_256)}, &jwks.Keys[3], false, false},
+		{"ok no use or alg in key", args{"ed", "sig", EdDSA}, &jwks.Keys[4], false, false},
+		{"fail kid", args{"missing", "", ""}, nil, true, true},
+		{"fail alg", args{"ec", "sig", ES512}, nil, true, true},
+		{"fail use", args{"ec", "enc", ""}, nil, true, true},
+		{"fail multiple", args{"ec", "sig", ""}, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectKey(jwks, tt.args.kid, tt.args.use, tt.args.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SelectKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var notFound *KeyNotFoundError
+			if errors.As(err, &notFound) != tt.notFound {
+				t.Errorf("SelectKey() error = %v, want KeyNotFoundError %v", err, tt.notFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKSFetcher_Fetch(t *testing.T) {
+	b, err := json.Marshal(testJWKS(t))
+	assert.FatalError(t, err)
+
+	var requests int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/bad":
+			w.Write([]byte("not a jwks"))
+			return
+		case "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/large":
+			w.Write(bytes.Repeat([]byte(" "), maxJWKSSize))
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	f := NewJWKSFetcher(srv.Client())
+	f.now = func() time.Time { return now }
+	ctx := stdcontext.Background()
+
+	fetch := func(t *testing.T, path string, wantRequests int32) *JSONWebKeySet {
+		t.Helper()
+		atomic.StoreInt32(&requests, 0)
+		jwks, err := f.Fetch(ctx, srv.URL+path)
+		assert.FatalError(t, err)
+		assert.Len(t, 5, jwks.Keys)
+		assert.Equals(t, wantRequests, atomic.LoadInt32(&requests))
+		return jwks
+	}
+
+	t.Run("max-age", func(t *testing.T) {
+		jwks := fetch(t, "/max-age", 1)
+		assert.True(t, jwks == fetch(t, "/max-age", 0))
+		now = now.Add(61 * time.Second)
+		assert.False(t, jwks == fetch(t, "/max-age", 1))
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		jwks := fetch(t, "/etag", 1)
+		assert.Equals(t, `"v1"`, f.cache[srv.URL+"/etag"].etag)
+		// Revalidated with a 304 response
+		assert.True(t, jwks == fetch(t, "/etag", 1))
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		fetch(t, "/no-store", 1)
+		fetch(t, "/no-store", 1)
+		_, ok := f.cache[srv.URL+"/no-store"]
+		assert.False(t, ok)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := f.Fetch(ctx, srv.URL+"/bad")
+		assert.Error(t, err)
+		_, err = f.Fetch(ctx, srv.URL+"/not-found")
+		assert.Error(t, err)
+		_, err = f.Fetch(ctx, srv.URL+"/large")
+		assert.Error(t, err)
+		_, err = f.Fetch(ctx, "http://example.com/jwks.json")
+		assert.Error(t, err)
+		_, err = NewJWKSFetcher(&http.Client{}).Fetch(ctx, srv.URL+"/max-age")
+		assert.Error(t, err)
+	})
+}
+
+func TestJWKSFetcher_Fetch_concurrent(t *testing.T) {
+	b, err := json.Marshal(testJWKS(t))
+	assert.FatalError(t, err)
+
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			atomic.AddInt32(&requests, 1)
+			close(started)
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	f := NewJWKSFetcher(srv.Client())
+	ctx := stdcontext.Background()
+
+	var wg sync.WaitGroup
+	results := make([]*JSONWebKeySet, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jwks, err := f.Fetch(ctx, srv.URL+"/slow")
+			if err != nil {
+				t.Errorf("JWKSFetcher.Fetch() error = %v", err)
+			}
+			results[i] = jwks
+		}(i)
+	}
+
+	// Other URLs are not blocked by the request in progress.
+	<-started
+	_, err = f.Fetch(ctx, srv.URL+"/fast")
+	assert.FatalError(t, err)
+
+	close(release)
+	wg.Wait()
+	assert.Equals(t, int32(1), atomic.LoadInt32(&requests))
+	for _, jwks := range results {
+		assert.True(t, jwks != nil && jwks == results[0])
+	}
+}
+
+func TestJWKSFetcher_Fetch_canceled(t *testing.T) {
+	b, err := json.Marshal(testJWKS(t))
+	assert.FatalError(t, err)
+
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	f := NewJWKSFetcher(srv.Client())
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	done := make(chan error)
+	go func() {
+		_, err := f.Fetch(ctx, srv.URL)
+		done <- err
+	}()
+
+	// The first caller gives up, but the shared request is not canceled.
+	<-started
+	cancel()
+	assert.True(t, errors.Is(<-done, stdcontext.Canceled))
+
+	close(release)
+	jwks, err := f.Fetch(stdcontext.Background(), srv.URL)
+	assert.FatalError(t, err)
+	assert.Len(t, 5, jwks.Keys)
+	assert.Equals(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		header        string
+		wantMaxAge    time.Duration
+		wantCacheable bool
+	}{
+		{"", 0, true},
+		{"max-age=300", 300 * time.Second, true},
+		{"public, Max-Age=60, must-revalidate", 60 * time.Second, true},
+		{"max-age=foo", 0, true},
+		{"no-cache, max-age=60", 0, true},
+		{"private, no-store", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			maxAge, cacheable := parseCacheControl(tt.header)
+			assert.Equals(t, tt.wantMaxAge, maxAge)
+			assert.Equals(t, tt.wantCacheable, cacheable)
+		})
+	}
+}
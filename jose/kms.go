@@ -0,0 +1,71 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x509util"
+)
+
+// KMSSignerOptions are the headers added to the tokens signed by a signer
+// created with NewKMSSigner.
+type KMSSignerOptions struct {
+	// KeyID is the kid header, it is not set if empty.
+	KeyID string
+	// X5C is the certificate chain added in the x5c header, the first
+	// certificate must be the one of the signer. It is not set if empty.
+	X5C []*x509.Certificate
+	// Algorithm is the signature algorithm, if empty the default one for the
+	// key type is used.
+	Algorithm SignatureAlgorithm
+}
+
+// NewKMSSigner creates a new Signer using a crypto.Signer, like the ones
+// returned by the CreateSigner method of a KMS, with the kid and x5c headers
+// defined in the options.
+//
+// The returned signer can be used to sign a JWS or a JWT using Signed.
+func NewKMSSigner(signer crypto.Signer, opts *KMSSignerOptions) (Signer, error) {
+	if signer == nil {
+		return nil, errors.New("signer cannot be nil")
+	}
+	if opts == nil {
+		opts = new(KMSSignerOptions)
+	}
+
+	// RSA keys support multiple algorithms, use RS256 by default.
+	alg := opts.Algorithm
+	if _, ok := signer.Public().(*rsa.PublicKey); ok && alg == "" {
+		alg = DefaultRSASigAlgorithm
+	}
+
+	var (
+		err error
+		op  OpaqueSigner
+	)
+	if alg != "" {
+		if op, err = NewOpaqueSignerWithAlgorithm(signer, alg); err != nil {
+			return nil, err
+		}
+	} else {
+		op = NewOpaqueSigner(signer)
+	}
+
+	so := new(SignerOptions)
+	if opts.KeyID != "" {
+		so.WithHeader("kid", opts.KeyID)
+	}
+	if len(opts.X5C) > 0 {
+		if err := validateKeyPair(opts.X5C[0].PublicKey, op); err != nil {
+			return nil, errors.Wrap(err, "error verifying certificate and signer")
+		}
+		so.WithHeader("x5c", x509util.ToX5C(opts.X5C))
+	}
+
+	return NewSigner(SigningKey{
+		Algorithm: alg,
+		Key:       op,
+	}, so)
+}
@@ -0,0 +1,110 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/minica"
+)
+
+func TestNewKMSSigner(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	sign := func(signer crypto.Signer) []*x509.Certificate {
+		leaf, err := ca.Sign(&x509.Certificate{
+			DNSNames:  []string{"leaf.example.com"},
+			PublicKey: signer.Public(),
+		})
+		assert.FatalError(t, err)
+		return []*x509.Certificate{leaf, ca.Intermediate}
+	}
+	ecChain := sign(ecKey)
+
+	tests := []struct {
+		name    string
+		signer  crypto.Signer
+		opts    *KMSSignerOptions
+		wantAlg string
+	}{
+		{"ok EC", ecKey, &KMSSignerOptions{KeyID: "the-kid", X5C: ecChain}, ES256},
+		{"ok EC explicit", ecKey, &KMSSignerOptions{KeyID: "the-kid", X5C: ecChain, Algorithm: ES256}, ES256},
+		{"ok RSA", rsaKey, &KMSSignerOptions{X5C: sign(rsaKey)}, RS256},
+		{"ok RSA PS384", rsaKey, &KMSSignerOptions{KeyID: "the-kid", Algorithm: PS384}, PS384},
+		{"ok Ed25519", edKey, &KMSSignerOptions{KeyID: "the-kid", X5C: sign(edKey)}, EdDSA},
+		{"ok nil options", ecKey, nil, ES256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewKMSSigner(tt.signer, tt.opts)
+			assert.FatalError(t, err)
+			token, err := Signed(s).Claims(Claims{Subject: "subject"}).CompactSerialize()
+			assert.FatalError(t, err)
+
+			tok, err := ParseSignedWithHeader(token)
+			assert.FatalError(t, err)
+			h := tok.Header()
+			assert.Equals(t, tt.wantAlg, h.Algorithm)
+
+			opts := tt.opts
+			if opts == nil {
+				opts = new(KMSSignerOptions)
+			}
+			assert.Equals(t, opts.KeyID, h.KeyID)
+			if opts.X5C == nil {
+				assert.Nil(t, h.X5C)
+			} else {
+				assert.Equals(t, opts.X5C, h.X5C)
+			}
+
+			var got Claims
+			assert.FatalError(t, tok.Claims(tt.signer.Public(), &got))
+			assert.Equals(t, "subject", got.Subject)
+		})
+	}
+}
+
+func TestNewKMSSigner_fail(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	leaf, err := ca.Sign(&x509.Certificate{
+		DNSNames:  []string{"leaf.example.com"},
+		PublicKey: other.Public(),
+	})
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		opts   *KMSSignerOptions
+	}{
+		{"fail nil signer", nil, nil},
+		{"fail algorithm", key, &KMSSignerOptions{Algorithm: RS256}},
+		{"fail x5c", key, &KMSSignerOptions{X5C: []*x509.Certificate{leaf}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewKMSSigner(tt.signer, tt.opts)
+			assert.Error(t, err)
+			assert.Nil(t, s)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package jose
 
 import (
 	"go.step.sm/crypto/internal/utils"
+	"golang.org/x/crypto/ssh"
 )
 
 type context struct {
@@ -13,6 +14,12 @@ type context struct {
 	passwordPrompt   string
 	passwordPrompter PasswordPrompter
 	contentType      string
+	sshValidity      bool
+	sshAuthorities   []ssh.PublicKey
+	serialization    Serialization
+	unprotected      map[HeaderKey]interface{}
+	knownCritical    []string
+	allowedAlgs      []SignatureAlgorithm
 }
 
 // apply the options to the context and returns an error if one of the options
@@ -123,3 +130,66 @@ func WithContentType(cty string) Option {
 		return nil
 	}
 }
+
+// WithSSHPOPValidity makes ValidateSSHPOP check that the current time is
+// within the validity period of the SSH certificate.
+func WithSSHPOPValidity() Option {
+	return func(ctx *context) error {
+		ctx.sshValidity = true
+		return nil
+	}
+}
+
+// WithSSHPOPAuthority adds a trusted certificate authority to the context.
+// ValidateSSHPOP will check that the SSH certificate is signed by one of the
+// authorities added.
+func WithSSHPOPAuthority(ca ssh.PublicKey) Option {
+	return func(ctx *context) error {
+		ctx.sshAuthorities = append(ctx.sshAuthorities, ca)
+		return nil
+	}
+}
+
+// WithSerialization sets the serialization format of the JWS created by Sign
+// and SignDetached. The default is CompactSerialization.
+func WithSerialization(s Serialization) Option {
+	return func(ctx *context) error {
+		ctx.serialization = s
+		return nil
+	}
+}
+
+// WithUnprotectedHeader adds a header to the unprotected header of the JWS
+// created by Sign and SignDetached. Unprotected headers are not signed, and
+// they are only supported by the JSON serializations.
+func WithUnprotectedHeader(k HeaderKey, v interface{}) Option {
+	return func(ctx *context) error {
+		if ctx.unprotected == nil {
+			ctx.unprotected = make(map[HeaderKey]interface{})
+		}
+		ctx.unprotected[k] = v
+		return nil
+	}
+}
+
+// WithKnownCritical sets the extension headers that are understood by the
+// caller and can be listed in the crit header, as defined in RFC 7515 section
+// 4.1.11. VerifyDetached and ValidateCritical reject a JWS with a critical
+// header not in the list. By default only b64 is known.
+func WithKnownCritical(headers []string) Option {
+	return func(ctx *context) error {
+		ctx.knownCritical = headers
+		return nil
+	}
+}
+
+// WithAllowedAlgorithms sets the signature algorithms accepted when a JWS is
+// verified. VerifyDetached, VerifyHMAC and ValidateAlgorithm reject a JWS
+// signed with an algorithm not in the list. The "none" algorithm is always
+// rejected, even if it is in the list.
+func WithAllowedAlgorithms(algs []SignatureAlgorithm) Option {
+	return func(ctx *context) error {
+		ctx.allowedAlgs = algs
+		return nil
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
@@ -67,7 +68,8 @@ func ReadKey(filename string, opts ...Option) (*JSONWebKey, error) {
 
 // ParseKey returns a JSONWebKey from the given JWK file or a PEM file. If the
 // file is password protected, and no password or prompt password function is
-// given it will fail.
+// given it will fail. The base64url encoded members of a JWK can be padded or
+// unpadded.
 func ParseKey(b []byte, opts ...Option) (*JSONWebKey, error) {
 	ctx, err := new(context).apply(opts...)
 	if err != nil {
@@ -86,7 +88,7 @@ func ParseKey(b []byte, opts ...Option) (*JSONWebKey, error) {
 		}
 
 		// Unmarshal the plain (or decrypted JWK)
-		if err = json.Unmarshal(b, jwk); err != nil {
+		if err = json.Unmarshal(trimJWKPadding(b), jwk); err != nil {
 			return nil, errors.Errorf("error reading %s: unsupported format", ctx.filename)
 		}
 
@@ -94,21 +96,7 @@ func ParseKey(b []byte, opts ...Option) (*JSONWebKey, error) {
 	// NOTE: we do not set this value by default in the case of jwkKeyType
 	// because it is assumed to have been left empty on purpose.
 	case pemKeyType:
-		pemOptions := []pemutil.Options{
-			pemutil.WithFilename(ctx.filename),
-		}
-		if ctx.password != nil {
-			pemOptions = append(pemOptions, pemutil.WithPassword(ctx.password))
-		}
-		if ctx.passwordPrompter != nil {
-			pemOptions = append(pemOptions, pemutil.WithPasswordPrompt(ctx.passwordPrompt, pemutil.PasswordPrompter(ctx.passwordPrompter)))
-		}
-		if pemutil.PromptPassword == nil && PromptPassword != nil {
-			pemutil.PromptPassword = pemutil.PasswordPrompter(PromptPassword)
-		}
-
-		jwk.Key, err = pemutil.ParseKey(b, pemOptions...)
-		if err != nil {
+		if jwk.Key, err = parsePEMKey(ctx, b); err != nil {
 			return nil, err
 		}
 		if ctx.kid == "" {
@@ -120,6 +108,30 @@ func ParseKey(b []byte, opts ...Option) (*JSONWebKey, error) {
 		jwk.Key = b
 	}
 
+	return completeJWK(ctx, jwk)
+}
+
+// parsePEMKey parses the key in the given PEM block using the password options
+// in the context.
+func parsePEMKey(ctx *context, b []byte) (interface{}, error) {
+	pemOptions := []pemutil.Options{
+		pemutil.WithFilename(ctx.filename),
+	}
+	if ctx.password != nil {
+		pemOptions = append(pemOptions, pemutil.WithPassword(ctx.password))
+	}
+	if ctx.passwordPrompter != nil {
+		pemOptions = append(pemOptions, pemutil.WithPasswordPrompt(ctx.passwordPrompt, pemutil.PasswordPrompter(ctx.passwordPrompter)))
+	}
+	if pemutil.PromptPassword == nil && PromptPassword != nil {
+		pemutil.PromptPassword = pemutil.PasswordPrompter(PromptPassword)
+	}
+	return pemutil.ParseKey(b, pemOptions...)
+}
+
+// completeJWK validates the kid and alg of the JWK against the ones in the
+// context, and sets the kid, use and alg if they are empty.
+func completeJWK(ctx *context, jwk *JSONWebKey) (*JSONWebKey, error) {
 	// Validate key id
 	if ctx.kid != "" && jwk.KeyID != "" && ctx.kid != jwk.KeyID {
 		return nil, errors.Errorf("kid %s does not match the kid on %s", ctx.kid, ctx.filename)
@@ -157,7 +169,8 @@ func ReadKeySet(filename string, opts ...Option) (*JSONWebKey, error) {
 }
 
 // ParseKeySet returns the JWK with the given key after parsing a JWKSet from
-// a given file.
+// a given file. The base64url encoded members of the keys can be padded or
+// unpadded.
 func ParseKeySet(b []byte, opts ...Option) (*JSONWebKey, error) {
 	ctx, err := new(context).apply(opts...)
 	if err != nil {
@@ -171,7 +184,7 @@ func ParseKeySet(b []byte, opts ...Option) (*JSONWebKey, error) {
 
 	// Unmarshal the plain or decrypted JWKSet
 	jwkSet := new(JSONWebKeySet)
-	if err := json.Unmarshal(b, jwkSet); err != nil {
+	if err := json.Unmarshal(trimJWKPadding(b), jwkSet); err != nil {
 		return nil, errors.Errorf("error reading %s: unsupported format", ctx.filename)
 	}
 
@@ -280,6 +293,127 @@ func ParseX5cInsecure(tok string, roots []*x509.Certificate) (*JSONWebToken, [][
 	return jwt, verifiedChains, nil
 }
 
+// X5TS256Key is the key used to store the x5t#S256 certificate thumbprint in
+// the JWT header.
+var X5TS256Key = "x5t#S256"
+
+// ErrX5TS256NotFound is the error returned by VerifyX5TS256 if none of the
+// candidate certificates matches the x5t#S256 header of the token.
+var ErrX5TS256NotFound = errors.New("no certificate matches the x5t#S256 header")
+
+// GetX5TS256Header extracts the x5t#S256 thumbprint from the token.
+func GetX5TS256Header(jwt *JSONWebToken) ([]byte, error) {
+	x5tVal, ok := jwt.Headers[0].ExtraHeaders[HeaderKey(X5TS256Key)]
+	if !ok {
+		return nil, errors.New("token missing x5t#S256 header")
+	}
+	x5t, ok := x5tVal.(string)
+	if !ok {
+		return nil, errors.Errorf("token x5t#S256 header has wrong type; expected string, but got %T", x5tVal)
+	}
+	// Accept both the padded and unpadded base64 URL encodings.
+	fp, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(x5t, "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding x5t#S256 header")
+	}
+	return fp, nil
+}
+
+// VerifyX5TS256 parses a token whose signing certificate is referenced by the
+// x5t#S256 header. It selects the certificate in certs whose SHA-256
+// thumbprint matches the header, verifies its chain using the given options,
+// and verifies the token signature with the certificate key. The claims are
+// unmarshaled into dest. ErrX5TS256NotFound is returned if no certificate
+// matches the thumbprint.
+func VerifyX5TS256(tok string, certs []*x509.Certificate, opts x509.VerifyOptions, dest ...interface{}) (*JSONWebToken, [][]*x509.Certificate, error) {
+	jwt, err := ParseSigned(tok)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing token")
+	}
+
+	fp, err := GetX5TS256Header(jwt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var leaf *x509.Certificate
+	for _, crt := range certs {
+		sum := sha256.Sum256(crt.Raw)
+		if bytes.Equal(sum[:], fp) {
+			leaf = crt
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, nil, ErrX5TS256NotFound
+	}
+
+	verifiedChains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error verifying x5t#S256 certificate chain")
+	}
+	if leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, nil, errors.New("certificate used to sign x5t#S256 token cannot be used for digital signature")
+	}
+
+	if err := Verify(jwt, leaf.PublicKey, dest...); err != nil {
+		return nil, nil, errors.Wrap(err, "error verifying x5t#S256 token signature")
+	}
+
+	return jwt, verifiedChains, nil
+}
+
+// jwkBase64Fields are the JWK members encoded using base64url.
+var jwkBase64Fields = []string{"n", "e", "d", "p", "q", "dp", "dq", "qi", "x", "y", "k"}
+
+// trimJWKPadding removes the base64 padding of the base64url encoded members of
+// the given JWK or JWK Set. Some producers add the padding, but go-jose only
+// accepts the unpadded encoding required by RFC 7515. The data is returned
+// unchanged if it's not a JSON object.
+func trimJWKPadding(data []byte) []byte {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return data
+	}
+
+	if v, ok := m["keys"]; ok {
+		var keys []json.RawMessage
+		if err := json.Unmarshal(v, &keys); err != nil {
+			return data
+		}
+		for i := range keys {
+			keys[i] = trimJWKPadding(keys[i])
+		}
+		b, err := json.Marshal(keys)
+		if err != nil {
+			return data
+		}
+		m["keys"] = b
+	}
+
+	for _, name := range jwkBase64Fields {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil || !strings.HasSuffix(s, "=") {
+			continue
+		}
+		b, err := json.Marshal(strings.TrimRight(s, "="))
+		if err != nil {
+			return data
+		}
+		m[name] = b
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+	return b
+}
+
 // guessKeyType returns the key type of the given data. Key types are JWK, PEM
 // or oct.
 func guessKeyType(ctx *context, data []byte) keyType {
@@ -291,7 +425,7 @@ func guessKeyType(ctx *context, data []byte) keyType {
 			return jwkKeyType
 		}
 		// JSON JWK ?
-		if err := json.Unmarshal(data, &JSONWebKey{}); err == nil {
+		if err := json.Unmarshal(trimJWKPadding(data), &JSONWebKey{}); err == nil {
 			return jwkKeyType
 		}
 		// Default to oct
@@ -368,6 +502,11 @@ func guessSignatureAlgorithm(key crypto.PrivateKey) SignatureAlgorithm {
 		return EdDSA
 	case x25519.PrivateKey, X25519Signer:
 		return XEdDSA
+	case OpaqueSigner:
+		if algs := SupportedSignatureAlgorithms(k); len(algs) == 1 {
+			return algs[0]
+		}
+		return ""
 	default:
 		return ""
 	}
@@ -7,9 +7,12 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -19,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/smallstep/assert"
+	"go.step.sm/crypto/minica"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x25519"
 )
@@ -228,6 +232,53 @@ func TestReadKeyPasswordFile(t *testing.T) {
 	assert.Equals(t, "the-kid", jwk.KeyID)
 }
 
+func TestReadKey_padded(t *testing.T) {
+	tests := []struct {
+		name   string
+		padded string
+		want   string
+		opts   []Option
+	}{
+		{"rsa", "testdata/rsa.padded.priv.json", "testdata/rsa.priv.json", nil},
+		{"ec", "testdata/p256.padded.priv.json", "testdata/p256.priv.json", nil},
+		{"oct", "testdata/oct.padded.json", "testdata/oct.json", nil},
+		{"octWithAlg", "testdata/oct.padded.json", "testdata/oct.json", []Option{WithAlg("HS256")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ReadKey(tt.want, tt.opts...)
+			assert.FatalError(t, err)
+			got, err := ReadKey(tt.padded, tt.opts...)
+			assert.FatalError(t, err)
+			assert.Equals(t, want.Key, got.Key)
+			assert.Equals(t, want.KeyID, got.KeyID)
+			assert.Equals(t, want.Algorithm, got.Algorithm)
+			assert.Equals(t, want.Use, got.Use)
+		})
+	}
+}
+
+func Test_trimJWKPadding(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"ok", `{"kty":"EC","x":"YQ==","y":"YWI=","d":"YWJj"}`, `{"d":"YWJj","kty":"EC","x":"YQ","y":"YWI"}`},
+		{"ok set", `{"keys":[{"kty":"oct","k":"YQ=="},{"kty":"RSA","n":"YWI=","e":"AQAB"}]}`, `{"keys":[{"k":"YQ","kty":"oct"},{"e":"AQAB","kty":"RSA","n":"YWI"}]}`},
+		{"ok other members", `{"kty":"RSA","kid":"a==","x5c":["YQ=="]}`, `{"kid":"a==","kty":"RSA","x5c":["YQ=="]}`},
+		{"ok not a string", `{"kty":"RSA","n":1}`, `{"kty":"RSA","n":1}`},
+		{"ok not an object", `not a jwk`, `not a jwk`},
+		{"ok bad keys", `{"keys":{"kty":"oct","k":"YQ=="}}`, `{"keys":{"kty":"oct","k":"YQ=="}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimJWKPadding([]byte(tt.data))
+			assert.Equals(t, tt.want, string(got))
+		})
+	}
+}
+
 func TestParseKey(t *testing.T) {
 	t.Parallel()
 	marshal := func(i interface{}) []byte {
@@ -430,6 +481,11 @@ func TestReadKeySet(t *testing.T) {
 	assert.Type(t, &ecdsa.PublicKey{}, jwk.Key)
 	assert.Equals(t, "V93A-Yh7Bhw1W2E0igFciviJzX4PXPswoVgriehm9Co", jwk.KeyID)
 
+	jwk, err = ReadKeySet("testdata/jwks.padded.json", WithKid("V93A-Yh7Bhw1W2E0igFciviJzX4PXPswoVgriehm9Co"))
+	assert.NoError(t, err)
+	assert.Type(t, &ecdsa.PublicKey{}, jwk.Key)
+	assert.Equals(t, "V93A-Yh7Bhw1W2E0igFciviJzX4PXPswoVgriehm9Co", jwk.KeyID)
+
 	jwk, err = ReadKeySet("testdata/jwks.json", WithKid("duplicated"))
 	assert.Error(t, err)
 	assert.Equals(t, "multiple keys with kid duplicated have been found on testdata/jwks.json", err.Error())
@@ -775,3 +831,118 @@ func Test_guessSignatureAlgorithm(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyX5TS256(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSign := func(keyUsage x509.KeyUsage) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			Subject:   pkix.Name{CommonName: "leaf"},
+			PublicKey: signer.Public(),
+			KeyUsage:  keyUsage,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt
+	}
+	leaf := mustSign(x509.KeyUsageDigitalSignature)
+	noSigLeaf := mustSign(x509.KeyUsageKeyEncipherment)
+	other := mustSign(x509.KeyUsageDigitalSignature)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(ca.Intermediate)
+	verifyOpts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	}
+
+	mustToken := func(key crypto.Signer, headers map[HeaderKey]interface{}) string {
+		so := new(SignerOptions)
+		for k, v := range headers {
+			so.WithHeader(k, v)
+		}
+		sig, err := NewSigner(SigningKey{Algorithm: ES256, Key: key}, so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, err := Signed(sig).Claims(Claims{Subject: "leaf"}).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	x5t := func(crt *x509.Certificate) map[HeaderKey]interface{} {
+		fp := sha256.Sum256(crt.Raw)
+		return map[HeaderKey]interface{}{
+			HeaderKey(X5TS256Key): base64.RawURLEncoding.EncodeToString(fp[:]),
+		}
+	}
+	badSigner, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		tok   string
+		certs []*x509.Certificate
+		opts  x509.VerifyOptions
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantErr    bool
+		wantErrIs  error
+		wantChains int
+	}{
+		{"ok", args{mustToken(signer, x5t(leaf)), []*x509.Certificate{other, leaf}, verifyOpts}, "leaf", false, nil, 1},
+		{"ok padded", args{mustToken(signer, map[HeaderKey]interface{}{
+			HeaderKey(X5TS256Key): func() string {
+				fp := sha256.Sum256(leaf.Raw)
+				return base64.URLEncoding.EncodeToString(fp[:])
+			}(),
+		}), []*x509.Certificate{leaf}, verifyOpts}, "leaf", false, nil, 1},
+		{"fail parse", args{"foo.bar", []*x509.Certificate{leaf}, verifyOpts}, "", true, nil, 0},
+		{"fail missing header", args{mustToken(signer, nil), []*x509.Certificate{leaf}, verifyOpts}, "", true, nil, 0},
+		{"fail header type", args{mustToken(signer, map[HeaderKey]interface{}{HeaderKey(X5TS256Key): 1234}), []*x509.Certificate{leaf}, verifyOpts}, "", true, nil, 0},
+		{"fail header encoding", args{mustToken(signer, map[HeaderKey]interface{}{HeaderKey(X5TS256Key): "not%base64"}), []*x509.Certificate{leaf}, verifyOpts}, "", true, nil, 0},
+		{"fail not found", args{mustToken(signer, x5t(leaf)), []*x509.Certificate{other}, verifyOpts}, "", true, ErrX5TS256NotFound, 0},
+		{"fail chain", args{mustToken(signer, x5t(leaf)), []*x509.Certificate{leaf}, x509.VerifyOptions{Roots: x509.NewCertPool()}}, "", true, nil, 0},
+		{"fail key usage", args{mustToken(signer, x5t(noSigLeaf)), []*x509.Certificate{noSigLeaf}, verifyOpts}, "", true, nil, 0},
+		{"fail signature", args{mustToken(badSigner, x5t(leaf)), []*x509.Certificate{leaf}, verifyOpts}, "", true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims Claims
+			jwt, chains, err := VerifyX5TS256(tt.args.tok, tt.args.certs, tt.args.opts, &claims)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyX5TS256() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("VerifyX5TS256() error = %v, wantErr %v", err, tt.wantErrIs)
+			}
+			if tt.wantErr {
+				if jwt != nil || chains != nil {
+					t.Errorf("VerifyX5TS256() = %v, %v, want nil", jwt, chains)
+				}
+				return
+			}
+			if claims.Subject != tt.want {
+				t.Errorf("VerifyX5TS256() subject = %v, want %v", claims.Subject, tt.want)
+			}
+			if len(chains) != tt.wantChains || chains[0][0] != leaf {
+				t.Errorf("VerifyX5TS256() chains = %v", chains)
+			}
+		})
+	}
+}
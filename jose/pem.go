@@ -0,0 +1,58 @@
+package jose
+
+import (
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/pemutil"
+)
+
+// JWKToPEM returns the PEM encoding of the key in the given JWK. RSA, EC and
+// Ed25519 public and private keys are supported, and the pemutil options can be
+// used to select the format of the private keys or to encrypt them.
+//
+// PEM blocks cannot hold the kid, use and alg of the JWK, use the WithKid,
+// WithUse and WithAlg options in PEMToJWK to restore them.
+func JWKToPEM(jwk *JSONWebKey, opts ...pemutil.Options) ([]byte, error) {
+	if jwk == nil || jwk.Key == nil {
+		return nil, errors.New("error serializing JWK: key cannot be empty")
+	}
+	if IsSymmetric(jwk) {
+		return nil, errors.New("error serializing JWK: symmetric keys are not supported")
+	}
+	block, err := pemutil.Serialize(jwk.Key, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing JWK")
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// PEMToJWK returns a JWK with the key in the given PEM block. RSA, EC and
+// Ed25519 public and private keys are supported.
+//
+// The kid, use and alg of the JWK can be set using WithKid, WithUse and
+// WithAlg. If no kid is given, the thumbprint of the key is used, and if no
+// alg is given, the default algorithm for the key is used. Encrypted PEM blocks
+// can be decrypted using WithPassword, WithPasswordFile or
+// WithPasswordPrompter.
+func PEMToJWK(b []byte, opts ...Option) (*JSONWebKey, error) {
+	ctx, err := new(context).apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.filename == "" {
+		ctx.filename = "key"
+	}
+
+	key, err := parsePEMKey(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	jwk := &JSONWebKey{Key: key, KeyID: ctx.kid}
+	if jwk.KeyID == "" {
+		if jwk.KeyID, err = Thumbprint(jwk); err != nil {
+			return nil, err
+		}
+	}
+	return completeJWK(ctx, jwk)
+}
@@ -0,0 +1,97 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/pemutil"
+)
+
+func TestJWKToPEM_PEMToJWK(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		wantAlg string
+	}{
+		{"ec private", ecKey, ES384},
+		{"ec public", ecKey.Public(), ES384},
+		{"rsa private", rsaKey, RS256},
+		{"rsa public", rsaKey.Public(), RS256},
+		{"ed25519 private", edKey, EdDSA},
+		{"ed25519 public", edKey.Public(), EdDSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := JWKToPEM(&JSONWebKey{Key: tt.key, KeyID: "the-kid", Use: "sig", Algorithm: tt.wantAlg})
+			assert.FatalError(t, err)
+
+			// Defaults
+			jwk, err := PEMToJWK(b)
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.key, jwk.Key)
+			assert.Equals(t, tt.wantAlg, jwk.Algorithm)
+			assert.Equals(t, "", jwk.Use)
+			thumbprint, err := Thumbprint(&JSONWebKey{Key: tt.key})
+			assert.FatalError(t, err)
+			assert.Equals(t, thumbprint, jwk.KeyID)
+
+			// Restore kid, use and alg
+			jwk, err = PEMToJWK(b, WithKid("the-kid"), WithUse("sig"), WithAlg(tt.wantAlg))
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.key, jwk.Key)
+			assert.Equals(t, "the-kid", jwk.KeyID)
+			assert.Equals(t, "sig", jwk.Use)
+			assert.Equals(t, tt.wantAlg, jwk.Algorithm)
+
+			// Back to PEM
+			b2, err := JWKToPEM(jwk)
+			assert.FatalError(t, err)
+			assert.Equals(t, b, b2)
+		})
+	}
+}
+
+func TestJWKToPEM_encrypted(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	b, err := JWKToPEM(&JSONWebKey{Key: key}, pemutil.WithPKCS8(true), pemutil.WithPassword([]byte("password")))
+	assert.FatalError(t, err)
+
+	_, err = PEMToJWK(b)
+	assert.Error(t, err)
+	jwk, err := PEMToJWK(b, WithPassword([]byte("password")))
+	assert.FatalError(t, err)
+	assert.Equals(t, crypto.PrivateKey(key), jwk.Key)
+}
+
+func TestJWKToPEM_fail(t *testing.T) {
+	_, err := JWKToPEM(nil)
+	assert.Error(t, err)
+	_, err = JWKToPEM(&JSONWebKey{})
+	assert.Error(t, err)
+	_, err = JWKToPEM(&JSONWebKey{Key: []byte("a-symmetric-key")})
+	assert.Error(t, err)
+	_, err = JWKToPEM(&JSONWebKey{Key: "not a key"})
+	assert.Error(t, err)
+}
+
+func TestPEMToJWK_fail(t *testing.T) {
+	_, err := PEMToJWK([]byte(`{"kty":"oct","k":"c2VjcmV0"}`))
+	assert.Error(t, err)
+	_, err = PEMToJWK([]byte("-----BEGIN PUBLIC KEY-----\nbm90IGEga2V5\n-----END PUBLIC KEY-----\n"))
+	assert.Error(t, err)
+}
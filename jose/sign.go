@@ -0,0 +1,209 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Serialization is the serialization format of a JWS, as defined in RFC 7515
+// Section 7.
+type Serialization int
+
+const (
+	// CompactSerialization is the JWS compact serialization, a URL-safe string
+	// with the protected header, the payload and the signature.
+	CompactSerialization Serialization = iota
+	// FlattenedJSONSerialization is the flattened JWS JSON serialization, a
+	// JSON object with the payload, the protected and unprotected headers, and
+	// the signature.
+	FlattenedJSONSerialization
+	// GeneralJSONSerialization is the general JWS JSON serialization, a JSON
+	// object with the payload and the list of signatures.
+	GeneralJSONSerialization
+)
+
+// String implements the fmt.Stringer interface.
+func (s Serialization) String() string {
+	switch s {
+	case CompactSerialization:
+		return "compact"
+	case FlattenedJSONSerialization:
+		return "flattened JSON"
+	case GeneralJSONSerialization:
+		return "general JSON"
+	default:
+		return "unknown"
+	}
+}
+
+// Sign signs the given payload and returns the JWS in the serialization format
+// set with WithSerialization, the compact serialization by default. The signing
+// algorithm will be guessed from the key if it's not set.
+//
+// Unprotected headers can be added using WithUnprotectedHeader, but only with
+// the JSON serializations. The result can be parsed using ParseJWS.
+func Sign(payload []byte, sig SigningKey, opts *SignerOptions, options ...Option) (string, error) {
+	return sign(payload, sig, opts, false, options)
+}
+
+func sign(payload []byte, sig SigningKey, opts *SignerOptions, detached bool, options []Option) (string, error) {
+	ctx, err := new(context).apply(options...)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := NewSigner(sig, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating signer")
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "error signing payload")
+	}
+	s, err := serialize(jws, ctx.serialization, ctx.unprotected, detached)
+	if err != nil {
+		return "", errors.Wrap(err, "error serializing JWS")
+	}
+	return s, nil
+}
+
+// jsonSignature is a signature in the JWS JSON serialization.
+type jsonSignature struct {
+	Protected string                    `json:"protected,omitempty"`
+	Header    map[HeaderKey]interface{} `json:"header,omitempty"`
+	Signature string                    `json:"signature"`
+}
+
+// jsonFlattened is the JWS flattened JSON serialization.
+type jsonFlattened struct {
+	Payload json.RawMessage `json:"payload,omitempty"`
+	jsonSignature
+}
+
+// jsonGeneral is the JWS general JSON serialization.
+type jsonGeneral struct {
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Signatures []jsonSignature `json:"signatures"`
+}
+
+// serialize returns the JWS, with only one signature, in the given
+// serialization format.
+func serialize(jws *JSONWebSignature, s Serialization, unprotected map[HeaderKey]interface{}, detached bool) (string, error) {
+	switch s {
+	case CompactSerialization:
+		if len(unprotected) > 0 {
+			return "", errors.New("compact serialization does not support unprotected headers")
+		}
+		if detached {
+			return jws.DetachedCompactSerialize()
+		}
+		return jws.CompactSerialize()
+	case FlattenedJSONSerialization, GeneralJSONSerialization:
+	default:
+		return "", errors.Errorf("unsupported serialization %d", s)
+	}
+
+	if len(jws.Signatures) != 1 {
+		return "", errors.New("JWS must contain one signature")
+	}
+
+	// The full serialization of a JWS with one signature uses the flattened
+	// format.
+	var v jsonFlattened
+	if err := json.Unmarshal([]byte(jws.FullSerialize()), &v); err != nil {
+		return "", err
+	}
+
+	// The protected and unprotected headers must be disjoint.
+	if len(unprotected) > 0 {
+		b, err := base64.RawURLEncoding.DecodeString(v.Protected)
+		if err != nil {
+			return "", err
+		}
+		var protected map[HeaderKey]json.RawMessage
+		if err := json.Unmarshal(b, &protected); err != nil {
+			return "", err
+		}
+		for k := range unprotected {
+			if _, ok := protected[k]; ok {
+				return "", errors.Errorf("header %s cannot be both protected and unprotected", k)
+			}
+		}
+	}
+	if detached {
+		v.Payload = nil
+	}
+	if len(unprotected) > 0 {
+		v.Header = unprotected
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if s == FlattenedJSONSerialization {
+		b, err = json.Marshal(v)
+	} else {
+		b, err = json.Marshal(jsonGeneral{
+			Payload:    v.Payload,
+			Signatures: []jsonSignature{v.jsonSignature},
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// parseDetachedJSON parses a JWS in the flattened or general JSON serialization
+// with a detached payload.
+func parseDetachedJSON(s string, payload []byte) (*JSONWebSignature, error) {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	if p, ok := v["payload"]; ok && string(p) != `""` {
+		return nil, errors.New("payload is not detached")
+	}
+
+	b, err := json.Marshal(base64.RawURLEncoding.EncodeToString(payload))
+	if err != nil {
+		return nil, err
+	}
+	v["payload"] = b
+	if b, err = json.Marshal(v); err != nil {
+		return nil, err
+	}
+	return ParseJWS(string(b))
+}
+
+// flattenJSON converts a JWS in the general JSON serialization with one
+// signature to the flattened JSON serialization. The general JSON parser does
+// not keep the unprotected headers of the signatures.
+func flattenJSON(s string) string {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	var signatures []map[string]json.RawMessage
+	if err := json.Unmarshal(v["signatures"], &signatures); err != nil || len(signatures) != 1 {
+		return s
+	}
+	delete(v, "signatures")
+	for k, m := range signatures[0] {
+		v[k] = m
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// isJSONSerialization returns true if s uses one of the JSON serializations.
+func isJSONSerialization(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "{")
+}
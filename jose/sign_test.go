@@ -0,0 +1,128 @@
+package jose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestSign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	payload := []byte(`{"the": "payload"}`)
+
+	tests := []struct {
+		name          string
+		serialization Serialization
+		opts          *SignerOptions
+		unprotected   map[HeaderKey]interface{}
+		check         func(t *testing.T, s string)
+	}{
+		{"compact", CompactSerialization, nil, nil, func(t *testing.T, s string) {
+			assert.Len(t, 3, strings.Split(s, "."))
+		}},
+		{"flattened", FlattenedJSONSerialization, nil, nil, func(t *testing.T, s string) {
+			var v map[string]interface{}
+			assert.FatalError(t, json.Unmarshal([]byte(s), &v))
+			assert.Len(t, 3, v)
+			assert.NotNil(t, v["payload"])
+			assert.NotNil(t, v["protected"])
+			assert.NotNil(t, v["signature"])
+		}},
+		{"flattened with header", FlattenedJSONSerialization, nil, map[HeaderKey]interface{}{"kid": "the-kid"}, func(t *testing.T, s string) {
+			var v map[string]interface{}
+			assert.FatalError(t, json.Unmarshal([]byte(s), &v))
+			assert.Equals(t, map[string]interface{}{"kid": "the-kid"}, v["header"])
+		}},
+		{"general", GeneralJSONSerialization, nil, nil, func(t *testing.T, s string) {
+			var v map[string]interface{}
+			assert.FatalError(t, json.Unmarshal([]byte(s), &v))
+			assert.Len(t, 2, v)
+			assert.NotNil(t, v["payload"])
+			assert.Len(t, 1, v["signatures"])
+		}},
+		{"general with header", GeneralJSONSerialization, new(SignerOptions).WithType("JWT"), map[HeaderKey]interface{}{"kid": "the-kid", "x-custom": 1.0}, func(t *testing.T, s string) {
+			var v struct {
+				Signatures []map[string]interface{} `json:"signatures"`
+			}
+			assert.FatalError(t, json.Unmarshal([]byte(s), &v))
+			assert.Len(t, 1, v.Signatures)
+			assert.Equals(t, map[string]interface{}{"kid": "the-kid", "x-custom": 1.0}, v.Signatures[0]["header"])
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := []Option{WithSerialization(tt.serialization)}
+			for k, v := range tt.unprotected {
+				options = append(options, WithUnprotectedHeader(k, v))
+			}
+
+			s, err := Sign(payload, SigningKey{Key: key}, tt.opts, options...)
+			assert.FatalError(t, err)
+			tt.check(t, s)
+
+			jws, err := ParseJWS(s)
+			assert.FatalError(t, err)
+			assert.Len(t, 1, jws.Signatures)
+			assert.Equals(t, ES256, jws.Signatures[0].Protected.Algorithm)
+			if kid, ok := tt.unprotected["kid"]; ok {
+				assert.Equals(t, kid, jws.Signatures[0].Unprotected.KeyID)
+			}
+			got, err := jws.Verify(key.Public())
+			assert.FatalError(t, err)
+			assert.Equals(t, payload, got)
+
+			// Detached payload
+			s, err = SignDetached(payload, SigningKey{Key: key}, tt.opts, options...)
+			assert.FatalError(t, err)
+			if tt.serialization == CompactSerialization {
+				assert.Equals(t, "", strings.Split(s, ".")[1])
+			} else {
+				assert.False(t, strings.Contains(s, `"payload"`))
+			}
+			assert.NoError(t, VerifyDetached(s, payload, key.Public()))
+			assert.Error(t, VerifyDetached(s, []byte("other payload"), key.Public()))
+		})
+	}
+}
+
+func TestSign_fail(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	payload := []byte("the payload")
+
+	_, err = Sign(payload, SigningKey{Key: "not a key"}, nil)
+	assert.Error(t, err)
+	_, err = Sign(payload, SigningKey{Key: key}, nil, WithUnprotectedHeader("kid", "the-kid"))
+	assert.Error(t, err)
+	_, err = Sign(payload, SigningKey{Key: key}, nil, WithSerialization(GeneralJSONSerialization), WithUnprotectedHeader("alg", ES384))
+	assert.Error(t, err)
+	_, err = Sign(payload, SigningKey{Key: key}, nil, WithSerialization(Serialization(100)))
+	assert.Error(t, err)
+}
+
+func TestParseDetached_json(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	payload := []byte("the payload")
+
+	attached, err := Sign(payload, SigningKey{Key: key}, nil, WithSerialization(FlattenedJSONSerialization))
+	assert.FatalError(t, err)
+	detached, err := SignDetached(payload, SigningKey{Key: key}, nil, WithSerialization(FlattenedJSONSerialization))
+	assert.FatalError(t, err)
+
+	_, err = ParseDetached(detached, payload)
+	assert.NoError(t, err)
+	_, err = ParseDetached(attached, payload)
+	assert.Error(t, err)
+	_, err = ParseDetached(detached, nil)
+	assert.Error(t, err)
+	_, err = ParseDetached("{not json", payload)
+	assert.Error(t, err)
+}
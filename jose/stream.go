@@ -0,0 +1,282 @@
+package jose
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+)
+
+// StreamChunkSize is the maximum size of the plaintext encrypted in each
+// record of a stream created with NewEncryptWriter.
+const StreamChunkSize = 64 * 1024
+
+// maxStreamRecordSize is the maximum size of a record read by
+// NewDecryptReader. The records of StreamChunkSize bytes encoded in base64 are
+// about 87KiB.
+const maxStreamRecordSize = 1024 * 1024
+
+// streamSeqHeader is the protected header with the sequence number of a
+// chunk.
+const streamSeqHeader = HeaderKey("seq")
+
+// Flags prefixed to the plaintext of the chunks. The flag makes the last chunk
+// of an empty stream non-empty, go-jose cannot decrypt an empty plaintext.
+const (
+	streamChunkFlag byte = iota
+	streamLastChunkFlag
+)
+
+// streamKeySizes are the content encryption key sizes of the supported content
+// encryption algorithms.
+var streamKeySizes = map[ContentEncryption]int{
+	A128GCM:       16,
+	A192GCM:       24,
+	A256GCM:       32,
+	A128CBC_HS256: 32,
+	A192CBC_HS384: 48,
+	A256CBC_HS512: 64,
+}
+
+// encryptWriter is the io.WriteCloser returned by NewEncryptWriter.
+type encryptWriter struct {
+	w   io.Writer
+	enc ContentEncryption
+	cek []byte
+	seq int
+	buf []byte
+	err error
+}
+
+// NewEncryptWriter returns an io.WriteCloser that encrypts the data written to
+// it in chunks of StreamChunkSize bytes, so large contents can be encrypted
+// without loading them in memory. The data is written to w as a sequence of
+// JWEs in compact serialization separated by new lines.
+//
+// The first JWE encrypts a random content encryption key for the given
+// recipient using the key management algorithm alg, the rest of JWEs encrypt
+// the chunks directly with that key using the content encryption algorithm
+// enc. If alg is empty, the default algorithm for the recipient key is used,
+// and if enc is empty, DefaultEncAlgorithm is used. The chunks are numbered,
+// and the last one is flagged, so NewDecryptReader can detect reordered or
+// truncated streams.
+//
+// Close must be called to write the last chunk, it does not close w.
+func NewEncryptWriter(w io.Writer, recipient interface{}, alg KeyAlgorithm, enc ContentEncryption) (io.WriteCloser, error) {
+	if enc == "" {
+		enc = DefaultEncAlgorithm
+	}
+	size, ok := streamKeySizes[enc]
+	if !ok {
+		return nil, errors.Errorf("unsupported content encryption algorithm %s", enc)
+	}
+	if alg == "" {
+		var err error
+		if alg, err = recipientAlgorithm(recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	cek, err := randutil.Bytes(size)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypter, err := NewEncrypter(enc, Recipient{Algorithm: alg, Key: recipient}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating cipher")
+	}
+	jwe, err := encrypter.Encrypt(cek)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting data")
+	}
+	if err := writeStreamRecord(w, jwe); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:   w,
+		enc: enc,
+		cek: cek,
+		buf: make([]byte, 1, StreamChunkSize+1),
+	}, nil
+}
+
+// Write encrypts and writes the chunks filled with p. The last chunk is kept
+// until Close is called.
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	var n int
+	for len(p) > 0 {
+		if len(e.buf) == cap(e.buf) {
+			if err := e.flush(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close encrypts and writes the last chunk. Further calls to Write or Close
+// will fail.
+func (e *encryptWriter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.flush(true); err != nil {
+		return err
+	}
+	e.err = errors.New("error writing stream: writer is closed")
+	return nil
+}
+
+func (e *encryptWriter) flush(last bool) error {
+	e.buf[0] = streamChunkFlag
+	if last {
+		e.buf[0] = streamLastChunkFlag
+	}
+	opts := new(EncrypterOptions).WithHeader(streamSeqHeader, e.seq)
+	encrypter, err := NewEncrypter(e.enc, Recipient{Algorithm: DIRECT, Key: e.cek}, opts)
+	if err != nil {
+		e.err = errors.Wrap(err, "error creating cipher")
+		return e.err
+	}
+	jwe, err := encrypter.Encrypt(e.buf)
+	if err != nil {
+		e.err = errors.Wrap(err, "error encrypting data")
+		return e.err
+	}
+	if err := writeStreamRecord(e.w, jwe); err != nil {
+		e.err = err
+		return err
+	}
+	e.seq++
+	e.buf = e.buf[:1]
+	return nil
+}
+
+func writeStreamRecord(w io.Writer, jwe *JSONWebEncryption) error {
+	s, err := jwe.CompactSerialize()
+	if err != nil {
+		return errors.Wrap(err, "error serializing JWE")
+	}
+	if _, err := io.WriteString(w, s+"\n"); err != nil {
+		return errors.Wrap(err, "error writing stream")
+	}
+	return nil
+}
+
+// decryptReader is the io.Reader returned by NewDecryptReader.
+type decryptReader struct {
+	scanner *bufio.Scanner
+	cek     []byte
+	seq     int
+	last    bool
+	buf     []byte
+	err     error
+}
+
+// NewDecryptReader returns an io.Reader that decrypts a stream created with
+// NewEncryptWriter using the given recipient key. The chunks are decrypted as
+// they are read, and the reader fails if the stream has been modified,
+// reordered or truncated.
+//
+// The data read before an error is authenticated, but it might not be the
+// complete content, so it must not be trusted until the reader returns io.EOF.
+func NewDecryptReader(r io.Reader, key interface{}) (io.Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxStreamRecordSize)
+
+	jwe, err := scanStreamRecord(scanner)
+	if err != nil {
+		return nil, err
+	}
+	if jwe == nil {
+		return nil, errors.New("error reading stream: stream is empty")
+	}
+	_, _, cek, err := jwe.DecryptMulti(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt JWE")
+	}
+
+	return &decryptReader{
+		scanner: scanner,
+		cek:     cek,
+	}, nil
+}
+
+// Read reads the decrypted content of the stream.
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk of the stream. It returns io.EOF after the last
+// chunk.
+func (d *decryptReader) next() error {
+	jwe, err := scanStreamRecord(d.scanner)
+	if err != nil {
+		return err
+	}
+	if jwe == nil {
+		if d.last {
+			return io.EOF
+		}
+		return errors.Wrap(io.ErrUnexpectedEOF, "error reading stream")
+	}
+	if d.last {
+		return errors.New("error reading stream: unexpected data after the last chunk")
+	}
+	if jwe.Header.Algorithm != string(DIRECT) {
+		return errors.Errorf("error reading stream: unexpected algorithm %s", jwe.Header.Algorithm)
+	}
+
+	b, err := jwe.Decrypt(d.cek)
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt JWE")
+	}
+
+	// The headers are authenticated after decrypting the chunk.
+	seq, ok := jwe.Header.ExtraHeaders[streamSeqHeader].(float64)
+	if !ok || seq != float64(d.seq) {
+		return errors.Errorf("error reading stream: unexpected chunk, want chunk %d", d.seq)
+	}
+	if len(b) == 0 || b[0] > streamLastChunkFlag {
+		return errors.New("error reading stream: invalid chunk")
+	}
+	d.last = b[0] == streamLastChunkFlag
+	d.seq++
+	d.buf = b[1:]
+	return nil
+}
+
+// scanStreamRecord returns the next JWE in the stream, or nil at the end of the
+// stream.
+func scanStreamRecord(scanner *bufio.Scanner) (*JSONWebEncryption, error) {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "error reading stream")
+		}
+		return nil, nil
+	}
+	jwe, err := ParseEncrypted(string(bytes.TrimSpace(scanner.Bytes())))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing JWE")
+	}
+	return jwe, nil
+}
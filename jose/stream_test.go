@@ -0,0 +1,177 @@
+package jose
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func mustEncryptStream(t *testing.T, data []byte, recipient interface{}, alg KeyAlgorithm, enc ContentEncryption) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, recipient, alg, enc)
+	assert.FatalError(t, err)
+	// Write in pieces that are not aligned with the chunks.
+	for b := data; len(b) > 0; {
+		n := 10000
+		if n > len(b) {
+			n = len(b)
+		}
+		_, err := w.Write(b[:n])
+		assert.FatalError(t, err)
+		b = b[n:]
+	}
+	assert.FatalError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestNewEncryptWriter_NewDecryptReader(t *testing.T) {
+	large := make([]byte, 4*1024*1024+123)
+	_, err := rand.Read(large)
+	assert.FatalError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	oct := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		recipient interface{}
+		key       interface{}
+		alg       KeyAlgorithm
+		enc       ContentEncryption
+	}{
+		{"ok large EC", large, &ecKey.PublicKey, ecKey, "", ""},
+		{"ok large RSA", large, &rsaKey.PublicKey, rsaKey, "", A128CBC_HS256},
+		{"ok oct", large[:StreamChunkSize], oct, oct, "", A192GCM},
+		{"ok ECDH-ES", large[:StreamChunkSize+1], &ecKey.PublicKey, ecKey, ECDH_ES, A256CBC_HS512},
+		{"ok JWK", []byte("the-plain-data"), &JSONWebKey{Key: &ecKey.PublicKey}, &JSONWebKey{Key: ecKey}, "", A128GCM},
+		{"ok empty", []byte{}, &ecKey.PublicKey, ecKey, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := mustEncryptStream(t, tt.data, tt.recipient, tt.alg, tt.enc)
+			wantRecords := 2 + len(tt.data)/StreamChunkSize
+			if len(tt.data) > 0 && len(tt.data)%StreamChunkSize == 0 {
+				wantRecords--
+			}
+			assert.Equals(t, wantRecords, bytes.Count(stream, []byte("\n")))
+
+			r, err := NewDecryptReader(bytes.NewReader(stream), tt.key)
+			assert.FatalError(t, err)
+			got, err := io.ReadAll(r)
+			assert.FatalError(t, err)
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("NewDecryptReader() read %d bytes, want %d bytes", len(got), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestNewEncryptWriter_fail(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name      string
+		recipient interface{}
+		alg       KeyAlgorithm
+		enc       ContentEncryption
+	}{
+		{"fail enc", &ecKey.PublicKey, "", ContentEncryption("A512GCM")},
+		{"fail key type", "not a key", "", ""},
+		{"fail alg", &ecKey.PublicKey, RSA_OAEP_256, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewEncryptWriter(io.Discard, tt.recipient, tt.alg, tt.enc)
+			assert.Error(t, err)
+			assert.Nil(t, w)
+		})
+	}
+
+	w, err := NewEncryptWriter(io.Discard, &ecKey.PublicKey, "", "")
+	assert.FatalError(t, err)
+	assert.FatalError(t, w.Close())
+	_, err = w.Write([]byte("the-plain-data"))
+	assert.Error(t, err)
+	assert.Error(t, w.Close())
+}
+
+func TestNewDecryptReader_fail(t *testing.T) {
+	data := make([]byte, 3*StreamChunkSize+1)
+	_, err := rand.Read(data)
+	assert.FatalError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	stream := mustEncryptStream(t, data, &ecKey.PublicKey, "", "")
+	records := strings.SplitAfter(string(stream), "\n")
+	records = records[:len(records)-1]
+	assert.Len(t, 5, records)
+	join := func(rr ...string) io.Reader {
+		return strings.NewReader(strings.Join(rr, ""))
+	}
+	otherStream := mustEncryptStream(t, data, &ecKey.PublicKey, "", "")
+	otherRecords := strings.SplitAfter(string(otherStream), "\n")
+
+	// Errors creating the reader.
+	tests := []struct {
+		name string
+		r    io.Reader
+		key  interface{}
+	}{
+		{"fail empty", strings.NewReader(""), ecKey},
+		{"fail parse", strings.NewReader("not a jwe\n"), ecKey},
+		{"fail too long", strings.NewReader(strings.Repeat("a", maxStreamRecordSize+1)), ecKey},
+		{"fail other key", bytes.NewReader(stream), other},
+		{"fail read", failReader{}, ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDecryptReader(tt.r, tt.key)
+			assert.Error(t, err)
+			assert.Nil(t, got)
+		})
+	}
+
+	// Errors reading the stream.
+	for name, r := range map[string]io.Reader{
+		"fail truncated":     join(records[:4]...),
+		"fail no chunks":     join(records[0]),
+		"fail reordered":     join(records[0], records[2], records[1], records[3], records[4]),
+		"fail dropped":       join(records[0], records[1], records[3], records[4]),
+		"fail duplicated":    join(records[0], records[1], records[1], records[2], records[3], records[4]),
+		"fail after last":    join(records[0], records[1], records[2], records[3], records[4], records[4]),
+		"fail header record": join(records[0], records[0], records[1], records[2], records[3], records[4]),
+		"fail other stream":  join(records[0], otherRecords[1], records[2], records[3], records[4]),
+		"fail parse":         join(records[0], records[1], "not a jwe\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			dr, err := NewDecryptReader(r, ecKey)
+			assert.FatalError(t, err)
+			_, err = io.ReadAll(dr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+type failReader struct{}
+
+func (failReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
@@ -4,7 +4,11 @@ package jose
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -226,6 +230,11 @@ func NewEncrypter(enc ContentEncryption, rcpt Recipient, opts *EncrypterOptions)
 	return jose.NewEncrypter(enc, rcpt, opts)
 }
 
+// NewMultiEncrypter creates a multi-encrypter based on the given recipients.
+func NewMultiEncrypter(enc ContentEncryption, rcpts []Recipient, opts *EncrypterOptions) (Encrypter, error) {
+	return jose.NewMultiEncrypter(enc, rcpts, opts)
+}
+
 // NewNumericDate constructs NumericDate from time.Time value.
 func NewNumericDate(t time.Time) *NumericDate {
 	return jwt.NewNumericDate(t)
@@ -248,15 +257,142 @@ func NewSigner(sig SigningKey, opts *SignerOptions) (Signer, error) {
 	}
 	if sig.Algorithm == "" {
 		sig.Algorithm = guessSignatureAlgorithm(sig.Key)
+	} else if err := validateSignatureAlgorithm(sig.Key, sig.Algorithm); err != nil {
+		return nil, err
 	}
 	return jose.NewSigner(sig, opts)
 }
 
+// SupportedSignatureAlgorithms returns the signature algorithms that can be
+// used with the given key. The key can be a private or public key, a
+// crypto.Signer like the ones returned by a KMS, an OpaqueSigner, or a
+// JSONWebKey. It returns nil if the key type is not supported.
+func SupportedSignatureAlgorithms(key interface{}) []SignatureAlgorithm {
+	switch k := key.(type) {
+	case OpaqueSigner:
+		// Opaque signers created with NewOpaqueSigner report all the ECDSA
+		// algorithms, restrict them to the ones supported by the key.
+		algs := k.Algs()
+		if jwk := k.Public(); jwk != nil {
+			if keyAlgs := SupportedSignatureAlgorithms(jwk.Key); keyAlgs != nil {
+				var filtered []SignatureAlgorithm
+				for _, a := range algs {
+					if containsSignatureAlgorithm(keyAlgs, a) {
+						filtered = append(filtered, a)
+					}
+				}
+				return filtered
+			}
+		}
+		return algs
+	case JSONWebKey:
+		return SupportedSignatureAlgorithms(k.Key)
+	case *JSONWebKey:
+		return SupportedSignatureAlgorithms(k.Key)
+	case []byte:
+		return []SignatureAlgorithm{HS256, HS384, HS512}
+	case x25519.PrivateKey, x25519.PublicKey, X25519Signer:
+		return []SignatureAlgorithm{XEdDSA}
+	case crypto.Signer:
+		return SupportedSignatureAlgorithms(k.Public())
+	case *rsa.PublicKey:
+		return []SignatureAlgorithm{RS256, RS384, RS512, PS256, PS384, PS512}
+	case *ecdsa.PublicKey:
+		if alg := getECAlgorithm(k.Curve); alg != "" {
+			return []SignatureAlgorithm{SignatureAlgorithm(alg)}
+		}
+		return nil
+	case ed25519.PublicKey:
+		return []SignatureAlgorithm{EdDSA}
+	default:
+		return nil
+	}
+}
+
+// validateSignatureAlgorithm returns an error if the given algorithm cannot be
+// used with the key. Unknown key types are left to go-jose.
+func validateSignatureAlgorithm(key interface{}, alg SignatureAlgorithm) error {
+	algs := SupportedSignatureAlgorithms(key)
+	if algs == nil {
+		return nil
+	}
+	switch k := key.(type) {
+	case OpaqueSigner:
+		key = k.Public().Key
+	case crypto.Signer:
+		key = k.Public()
+	}
+	return checkSignatureAlgorithm(algs, alg, key)
+}
+
+// checkSignatureAlgorithm returns an error listing the supported algorithms if
+// alg is not one of them.
+func checkSignatureAlgorithm(algs []SignatureAlgorithm, alg SignatureAlgorithm, pub interface{}) error {
+	if containsSignatureAlgorithm(algs, alg) {
+		return nil
+	}
+	names := make([]string, len(algs))
+	for i, a := range algs {
+		names[i] = string(a)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("signature algorithm %s is not supported by key type %T", alg, pub)
+	}
+	return fmt.Errorf("signature algorithm %s is not supported by key type %T, supported algorithms are %s",
+		alg, pub, strings.Join(names, ", "))
+}
+
+func containsSignatureAlgorithm(algs []SignatureAlgorithm, alg SignatureAlgorithm) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
 // NewOpaqueSigner creates a new OpaqueSigner for JWT signing from a crypto.Signer
 func NewOpaqueSigner(signer crypto.Signer) OpaqueSigner {
 	return cryptosigner.Opaque(signer)
 }
 
+// NewOpaqueSignerWithAlgorithm creates a new OpaqueSigner for JWT signing from
+// a crypto.Signer that only supports the given algorithm. This allows, for
+// example, to use PS256, PS384, or PS512 with an RSA key instead of the
+// default RS256. The algorithm must be supported by the key type of the
+// signer. NewSigner will use this algorithm if the SigningKey does not define
+// one.
+func NewOpaqueSignerWithAlgorithm(signer crypto.Signer, alg SignatureAlgorithm) (OpaqueSigner, error) {
+	op := cryptosigner.Opaque(signer)
+	if err := checkSignatureAlgorithm(SupportedSignatureAlgorithms(op), alg, signer.Public()); err != nil {
+		return nil, err
+	}
+	pk := *op.Public()
+	pk.Algorithm = string(alg)
+	return &algorithmOpaqueSigner{
+		OpaqueSigner: op,
+		alg:          alg,
+		pk:           &pk,
+	}, nil
+}
+
+// algorithmOpaqueSigner is an OpaqueSigner restricted to one algorithm.
+type algorithmOpaqueSigner struct {
+	OpaqueSigner
+	alg SignatureAlgorithm
+	pk  *JSONWebKey
+}
+
+// Public returns the public key of the signer with the algorithm set.
+func (s *algorithmOpaqueSigner) Public() *JSONWebKey {
+	return s.pk
+}
+
+// Algs returns the only algorithm supported by the signer.
+func (s *algorithmOpaqueSigner) Algs() []SignatureAlgorithm {
+	return []SignatureAlgorithm{s.alg}
+}
+
 // Verify validates the token payload with the given public key and deserializes
 // the token into the destination.
 func Verify(token *JSONWebToken, publicKey interface{}, dest ...interface{}) error {
@@ -276,11 +412,27 @@ func Signed(sig Signer) Builder {
 	return jwt.Signed(sig)
 }
 
-// ParseJWS parses a signed message in compact or full serialization format.
+// ParseJWS parses a signed message in compact, flattened JSON or general JSON
+// serialization format.
 func ParseJWS(s string) (*JSONWebSignature, error) {
+	if isJSONSerialization(s) {
+		s = flattenJSON(s)
+	}
 	return jose.ParseSigned(s)
 }
 
+// ParseDetached parses a signed message in compact or JSON serialization format
+// with a detached payload.
+func ParseDetached(s string, payload []byte) (*JSONWebSignature, error) {
+	if isJSONSerialization(s) {
+		if payload == nil {
+			return nil, errors.New("payload cannot be nil")
+		}
+		return parseDetachedJSON(s, payload)
+	}
+	return jose.ParseDetached(s, payload)
+}
+
 // Determine whether a JSONWebKey is symmetric
 func IsSymmetric(k *JSONWebKey) bool {
 	switch k.Key.(type) {
@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -179,3 +180,182 @@ func TestSignVerify(t *testing.T) {
 		})
 	}
 }
+
+func TestNewOpaqueSignerWithAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		signer crypto.Signer
+		alg    SignatureAlgorithm
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok PS256", args{rsaKey, PS256}, false},
+		{"ok PS384", args{rsaKey, PS384}, false},
+		{"ok PS512", args{rsaKey, PS512}, false},
+		{"ok RS256", args{rsaKey, RS256}, false},
+		{"ok ES256", args{p256, ES256}, false},
+		{"fail ES256 with RSA", args{rsaKey, ES256}, true},
+		{"fail PS256 with EC", args{p256, PS256}, true},
+		{"fail ES384 with P-256", args{p256, ES384}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := NewOpaqueSignerWithAlgorithm(tt.args.signer, tt.args.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewOpaqueSignerWithAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := op.Algs(); !reflect.DeepEqual(got, []SignatureAlgorithm{tt.args.alg}) {
+				t.Errorf("OpaqueSigner.Algs() = %v, want %v", got, []SignatureAlgorithm{tt.args.alg})
+			}
+			if got := op.Public().Algorithm; got != string(tt.args.alg) {
+				t.Errorf("OpaqueSigner.Public().Algorithm = %v, want %v", got, tt.args.alg)
+			}
+
+			// The algorithm is selected automatically
+			signer, err := NewSigner(SigningKey{Key: op}, nil)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			jws, err := signer.Sign([]byte(`{"sub": "sub"}`))
+			if err != nil {
+				t.Fatalf("Signer.Sign() error = %v", err)
+			}
+			jwt, err := ParseSigned(jws.FullSerialize())
+			if err != nil {
+				t.Fatalf("ParseSigned() error = %v", err)
+			}
+			if got := jwt.Headers[0].Algorithm; got != string(tt.args.alg) {
+				t.Errorf("JSONWebToken.Headers[0].Algorithm = %v, want %v", got, tt.args.alg)
+			}
+			var claims Claims
+			if err := Verify(jwt, tt.args.signer.Public(), &claims); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if claims.Subject != "sub" {
+				t.Errorf("Verify() claims = %v, want sub", claims)
+			}
+		})
+	}
+
+	// Other algorithms cannot be used
+	op, err := NewOpaqueSignerWithAlgorithm(rsaKey, PS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(SigningKey{Algorithm: RS256, Key: op}, nil); err == nil {
+		t.Error("NewSigner() error = nil, want error")
+	}
+}
+
+func TestNewSigner_algorithmMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, xKey, err := x25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		alg     SignatureAlgorithm
+		wantErr string
+	}{
+		{"ok RS256", rsaKey, RS256, ""},
+		{"ok PS512", rsaKey, PS512, ""},
+		{"ok ES256", p256, ES256, ""},
+		{"ok EdDSA", edKey, EdDSA, ""},
+		{"ok XEdDSA", xKey, XEdDSA, ""},
+		{"ok HS256", []byte("a-shared-secret-of-32-bytes-long"), HS256, ""},
+		{"ok opaque ES384", NewOpaqueSigner(p384), ES384, ""},
+		{"ok opaque guessed", NewOpaqueSigner(p384), "", ""},
+		{"ok jwk", &JSONWebKey{Key: p256}, ES256, ""},
+		{"fail RS256 with EC", p256, RS256, "signature algorithm RS256 is not supported by key type *ecdsa.PublicKey, supported algorithms are ES256"},
+		{"fail ES384 with P-256", p256, ES384, "supported algorithms are ES256"},
+		{"fail ES256 with RSA", rsaKey, ES256, "supported algorithms are RS256, RS384, RS512, PS256, PS384, PS512"},
+		{"fail EdDSA with RSA", rsaKey, EdDSA, "key type *rsa.PublicKey"},
+		{"fail ES256 with Ed25519", edKey, ES256, "supported algorithms are EdDSA"},
+		{"fail EdDSA with X25519", xKey, EdDSA, "supported algorithms are XEdDSA"},
+		{"fail RS256 with HMAC", []byte("a-shared-secret-of-32-bytes-long"), RS256, "supported algorithms are HS256, HS384, HS512"},
+		{"fail opaque RS256 with P-384", NewOpaqueSigner(p384), RS256, "supported algorithms are ES384"},
+		{"fail jwk PS256 with EC", &JSONWebKey{Key: p256}, PS256, "supported algorithms are ES256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSigner(SigningKey{Algorithm: tt.alg, Key: tt.key}, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewSigner() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewSigner() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportedSignatureAlgorithms(t *testing.T) {
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  interface{}
+		want []SignatureAlgorithm
+	}{
+		{"P-521", p521, []SignatureAlgorithm{ES512}},
+		{"P-521 public", &p521.PublicKey, []SignatureAlgorithm{ES512}},
+		{"P-224", p224, nil},
+		{"Ed25519 public", edPub, []SignatureAlgorithm{EdDSA}},
+		{"jwk", JSONWebKey{Key: edPub}, []SignatureAlgorithm{EdDSA}},
+		{"unknown", "foo", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SupportedSignatureAlgorithms(tt.key); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SupportedSignatureAlgorithms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,19 @@
 package jose
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha1" //nolint:gosec // RFC 7515 - X.509 Certificate SHA-1 Thumbprint
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/keyutil"
@@ -18,7 +22,16 @@ import (
 
 // ValidateSSHPOP validates the given SSH certificate and key for use in an
 // sshpop header.
-func ValidateSSHPOP(certFile string, key interface{}) (string, error) {
+//
+// By default, it only checks that the key matches the certificate. Use the
+// WithSSHPOPValidity option to verify that the certificate has not expired, and
+// WithSSHPOPAuthority to verify that the certificate is signed by a trusted
+// certificate authority.
+func ValidateSSHPOP(certFile string, key interface{}, opts ...Option) (string, error) {
+	ctx, err := new(context).apply(opts...)
+	if err != nil {
+		return "", err
+	}
 	if certFile == "" {
 		return "", errors.New("ssh certfile cannot be empty")
 	}
@@ -41,16 +54,58 @@ func ValidateSSHPOP(certFile string, key interface{}) (string, error) {
 	if err = validateKeyPair(pubkey, key); err != nil {
 		return "", errors.Wrap(err, "error verifying ssh key pair")
 	}
+	if ctx.sshValidity {
+		if err := validateSSHValidity(cert, time.Now()); err != nil {
+			return "", err
+		}
+	}
+	if len(ctx.sshAuthorities) > 0 {
+		if err := validateSSHAuthority(cert, ctx.sshAuthorities); err != nil {
+			return "", err
+		}
+	}
 
 	return base64.StdEncoding.EncodeToString(cert.Marshal()), nil
 }
 
+// validateSSHValidity checks that the given time is within the validity period
+// of the certificate.
+func validateSSHValidity(cert *ssh.Certificate, now time.Time) error {
+	unixNow := now.Unix()
+	if after := int64(cert.ValidAfter); after < 0 || unixNow < after {
+		return errors.New("ssh certificate is not yet valid")
+	}
+	if before := int64(cert.ValidBefore); cert.ValidBefore != ssh.CertTimeInfinity && (unixNow >= before || before < 0) {
+		return errors.New("ssh certificate has expired")
+	}
+	return nil
+}
+
+// validateSSHAuthority checks that the certificate is signed by one of the
+// given authorities.
+func validateSSHAuthority(cert *ssh.Certificate, authorities []ssh.PublicKey) error {
+	signatureKey := cert.SignatureKey.Marshal()
+	for _, ca := range authorities {
+		if !bytes.Equal(signatureKey, ca.Marshal()) {
+			continue
+		}
+		// The signed data is the certificate without the signature.
+		b := cert.Marshal()
+		signed := b[:len(b)-4-len(ssh.Marshal(cert.Signature))]
+		if err := ca.Verify(signed, cert.Signature); err != nil {
+			return errors.Wrap(err, "error verifying ssh certificate signature")
+		}
+		return nil
+	}
+	return errors.New("ssh certificate is not signed by a trusted authority")
+}
+
 func validateKeyPair(pub crypto.PublicKey, priv crypto.PrivateKey) error {
 	switch key := priv.(type) {
 	case *JSONWebKey:
 		return keyutil.VerifyPair(pub, key.Key)
 	case OpaqueSigner:
-		if !keyutil.Equal(pub, key.Public().Key) {
+		if !keyutil.PublicKeysEqual(pub, key.Public().Key) {
 			return errors.New("private key does not match public key")
 		}
 		return nil
@@ -101,6 +156,18 @@ func ValidateX5T(certs []*x509.Certificate, key interface{}) (string, error) {
 	return base64.URLEncoding.EncodeToString(fingerprint[:]), nil
 }
 
+// ValidateX5TS256 validates the given certificate and key for use as a token
+// signer and x5t#S256 header.
+func ValidateX5TS256(certs []*x509.Certificate, key interface{}) (string, error) {
+	if err := validateX5(certs, key); err != nil {
+		return "", errors.Wrap(err, "ValidateX5TS256")
+	}
+	// x5t#S256 is the base64 URL encoded SHA-256 thumbprint
+	// (see https://tools.ietf.org/html/rfc7515#section-4.1.8)
+	fingerprint := sha256.Sum256(certs[0].Raw)
+	return base64.URLEncoding.EncodeToString(fingerprint[:]), nil
+}
+
 // ValidateJWK validates the given JWK.
 func ValidateJWK(jwk *JSONWebKey) error {
 	switch jwk.Use {
@@ -198,6 +265,68 @@ func validateEncJWK(jwk *JSONWebKey) error {
 	return errors.Errorf("alg '%s' is not compatible with kty '%s'", jwk.Algorithm, kty)
 }
 
+// ValidateCritical validates the crit header of the given token, as defined in
+// RFC 7515 section 4.1.11. It fails if a header listed in crit is not present
+// in the protected header or is not in the list of known headers set with
+// WithKnownCritical. By default only b64 is known.
+//
+// ValidateCritical does not verify the token signature, and it should be used
+// before Verify. Note that the signature verification also rejects critical
+// headers other than b64.
+func ValidateCritical(jwt *JSONWebToken, opts ...Option) error {
+	ctx, err := new(context).apply(opts...)
+	if err != nil {
+		return err
+	}
+	for _, h := range jwt.Headers {
+		if err := validateCritical(h, ctx.knownCritical); err != nil {
+			return errors.Wrap(err, "error validating token")
+		}
+	}
+	return nil
+}
+
+// ValidateAlgorithm validates the algorithm in the headers of the given token.
+// It fails if the algorithm is "none" or empty, or if it is not in the list of
+// algorithms set with WithAllowedAlgorithms. Without WithAllowedAlgorithms only
+// "none" and empty algorithms are rejected.
+//
+// ValidateAlgorithm does not verify the token signature, and it should be used
+// before Verify to make sure that the key is only used with the expected
+// algorithms, e.g. an RSA public key is never used as an HMAC secret.
+func ValidateAlgorithm(jwt *JSONWebToken, opts ...Option) error {
+	ctx, err := new(context).apply(opts...)
+	if err != nil {
+		return err
+	}
+	for _, h := range jwt.Headers {
+		if err := validateAlgorithm(h, ctx.allowedAlgs); err != nil {
+			return errors.Wrap(err, "error validating token")
+		}
+	}
+	return nil
+}
+
+// validateAlgorithm checks that the alg header in h is not "none" and, if
+// allowed is not nil, that it is one of the allowed algorithms.
+func validateAlgorithm(h Header, allowed []SignatureAlgorithm) error {
+	alg := SignatureAlgorithm(h.Algorithm)
+	switch {
+	case alg == "":
+		return errors.New("alg header is missing")
+	case strings.EqualFold(string(alg), "none"):
+		return errors.New("alg none is not allowed")
+	case allowed == nil:
+		return nil
+	}
+	for _, a := range allowed {
+		if a == alg {
+			return nil
+		}
+	}
+	return errors.Errorf("alg %s is not allowed", alg)
+}
+
 // validateGeneric validates just the supported key types.
 func validateGeneric(jwk *JSONWebKey) error {
 	switch jwk.Key.(type) {
@@ -6,14 +6,19 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
 	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ssh"
 )
 
 var (
@@ -79,6 +84,92 @@ func TestValidateSSHPOP(t *testing.T) {
 	}
 }
 
+func TestValidateSSHPOP_options(t *testing.T) {
+	key, err := pemutil.Read("testdata/host-key")
+	assert.FatalError(t, err)
+	sshPub, err := ssh.NewPublicKey(key.(crypto.Signer).Public())
+	assert.FatalError(t, err)
+
+	newCA := func() ssh.Signer {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		assert.FatalError(t, err)
+		signer, err := ssh.NewSignerFromKey(priv)
+		assert.FatalError(t, err)
+		return signer
+	}
+	ca, otherCA := newCA(), newCA()
+
+	dir := t.TempDir()
+	writeCert := func(name string, signer ssh.Signer, validAfter, validBefore time.Time, modify func(*ssh.Certificate)) string {
+		cert := &ssh.Certificate{
+			Key:             sshPub,
+			Serial:          1234,
+			CertType:        ssh.HostCert,
+			KeyId:           "foo.internal",
+			ValidPrincipals: []string{"foo.internal"},
+			ValidAfter:      uint64(validAfter.Unix()),
+			ValidBefore:     uint64(validBefore.Unix()),
+		}
+		assert.FatalError(t, cert.SignCert(rand.Reader, signer))
+		if modify != nil {
+			modify(cert)
+		}
+		fn := filepath.Join(dir, name)
+		assert.FatalError(t, os.WriteFile(fn, ssh.MarshalAuthorizedKey(cert), 0600))
+		return fn
+	}
+
+	now := time.Now()
+	validCert := writeCert("valid-cert.pub", ca, now.Add(-time.Minute), now.Add(time.Hour), nil)
+	expiredCert := writeCert("expired-cert.pub", ca, now.Add(-time.Hour), now.Add(-time.Minute), nil)
+	notYetValidCert := writeCert("not-yet-valid-cert.pub", ca, now.Add(time.Minute), now.Add(time.Hour), nil)
+	untrustedCert := writeCert("untrusted-cert.pub", otherCA, now.Add(-time.Minute), now.Add(time.Hour), nil)
+	badSignatureCert := writeCert("bad-signature-cert.pub", ca, now.Add(-time.Minute), now.Add(time.Hour), func(cert *ssh.Certificate) {
+		cert.KeyId = "bar.internal"
+	})
+	foreverCert := writeCert("forever-cert.pub", ca, now.Add(-time.Minute), now, func(cert *ssh.Certificate) {
+		cert.ValidBefore = ssh.CertTimeInfinity
+		assert.FatalError(t, cert.SignCert(rand.Reader, ca))
+	})
+
+	type args struct {
+		certFile string
+		opts     []Option
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok", args{validCert, []Option{WithSSHPOPValidity(), WithSSHPOPAuthority(ca.PublicKey())}}, false},
+		{"ok multiple authorities", args{validCert, []Option{WithSSHPOPAuthority(otherCA.PublicKey()), WithSSHPOPAuthority(ca.PublicKey())}}, false},
+		{"ok forever", args{foreverCert, []Option{WithSSHPOPValidity()}}, false},
+		{"ok expired without options", args{expiredCert, nil}, false},
+		{"ok untrusted without options", args{untrustedCert, nil}, false},
+		{"ok expired without validity", args{expiredCert, []Option{WithSSHPOPAuthority(ca.PublicKey())}}, false},
+		{"fail expired", args{expiredCert, []Option{WithSSHPOPValidity()}}, true},
+		{"fail not yet valid", args{notYetValidCert, []Option{WithSSHPOPValidity()}}, true},
+		{"fail untrusted authority", args{untrustedCert, []Option{WithSSHPOPAuthority(ca.PublicKey())}}, true},
+		{"fail bad signature", args{badSignatureCert, []Option{WithSSHPOPAuthority(ca.PublicKey())}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateSSHPOP(tt.args.certFile, key, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSSHPOP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equals(t, "", got)
+			} else {
+				b, err := os.ReadFile(tt.args.certFile)
+				assert.FatalError(t, err)
+				assert.Equals(t, string(bytes.Fields(b)[1]), got)
+			}
+		})
+	}
+}
+
 func Test_validateX5(t *testing.T) {
 	type test struct {
 		certs []*x509.Certificate
@@ -207,6 +298,70 @@ func TestValidateX5T(t *testing.T) {
 	}
 }
 
+func TestValidateX5TS256(t *testing.T) {
+	type test struct {
+		certs []*x509.Certificate
+		key   interface{}
+		fp    string
+		err   error
+	}
+	tests := map[string]func() test{
+		"fail/validateX5-error": func() test {
+			return test{
+				certs: []*x509.Certificate{},
+				key:   nil,
+				err:   errors.New("ValidateX5TS256: certs cannot be empty"),
+			}
+		},
+		"ok": func() test {
+			certs, err := pemutil.ReadCertificateBundle(certFile)
+			assert.FatalError(t, err)
+			k, err := pemutil.Read(keyFile)
+			assert.FatalError(t, err)
+			cert, err := pemutil.ReadCertificate(certFile)
+			assert.FatalError(t, err)
+			// x5t#S256 is the base64 URL encoded SHA-256 thumbprint
+			// (see https://tools.ietf.org/html/rfc7515#section-4.1.8)
+			fp := sha256.Sum256(cert.Raw)
+			return test{
+				certs: certs,
+				key:   k,
+				fp:    base64.URLEncoding.EncodeToString(fp[:]),
+			}
+		},
+		"ok/opaque": func() test {
+			certs, err := pemutil.ReadCertificateBundle(certFile)
+			assert.FatalError(t, err)
+			k, err := pemutil.Read(keyFile)
+			assert.FatalError(t, err)
+			sig, ok := k.(crypto.Signer)
+			assert.True(t, ok)
+			op := NewOpaqueSigner(sig)
+			cert, err := pemutil.ReadCertificate(certFile)
+			assert.FatalError(t, err)
+			fp := sha256.Sum256(cert.Raw)
+			return test{
+				certs: certs,
+				key:   op,
+				fp:    base64.URLEncoding.EncodeToString(fp[:]),
+			}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run()
+			if fingerprint, err := ValidateX5TS256(tc.certs, tc.key); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assert.HasPrefix(t, err.Error(), tc.err.Error())
+				}
+			} else {
+				assert.Nil(t, tc.err)
+				assert.Equals(t, tc.fp, fingerprint)
+			}
+		})
+	}
+}
+
 func TestValidateX5C(t *testing.T) {
 	type test struct {
 		certs []*x509.Certificate
@@ -255,6 +410,105 @@ func TestValidateX5C(t *testing.T) {
 			} else {
 				assert.Nil(t, tc.err)
 				assert.Equals(t, []string{`MIIDCTCCAfGgAwIBAgIQIdY8a5pFZ/FGUowvuGdJvTANBgkqhkiG9w0BAQsFADAPMQ0wCwYDVQQDEwR0ZXN0MB4XDTIwMDQyMTA0MDg0MFoXDTIwMDQyMjA0MDg0MFowDzENMAsGA1UEAxMEdGVzdDCCASIwDQYJKoZIhvcNAQEBBQADggEPADCCAQoCggEBAL0zsTneONS0eNto7LMlD8GtcUYXqILSEWD07v0HgbIguBT+yC8BozpAT3lyB+oBBZkFLzfEHAteULngPwlq0R5hsEZJ6lcL1Z9WXwyLE4nkEndIPMA+zQmHnOzoqgKy7pIqUnFqSGXtGp384fFF3Y0/qjeFciLnmf+Wn0PneaToY1rDj2Eb9sFf5UDiVaSLT1NzpSyXOS5uGbGplPe+WE8uEb3u3Vg2VGbEPau2l5MPYroCwSyxqlpKsmzJ558uvjQ7KpRExSNdb6f0iRfdRMbw3LahrxhbKV1mmM6GD5onmbgBCZpw5htOJj1MzVFZOdnoTHmMl/Y/IUdMjv0jG/UCAwEAAaNhMF8wDgYDVR0PAQH/BAQDAgWgMB0GA1UdJQQWMBQGCCsGAQUFBwMBBggrBgEFBQcDAjAdBgNVHQ4EFgQUXlQCQL6RymQZnvqY15F/GlE3H4UwDwYDVR0RBAgwBoIEdGVzdDANBgkqhkiG9w0BAQsFAAOCAQEArVmOL5L+NVsnCcUWfOVXQYg/6P8AGdPJBECk+BE5pbtMg0GuH5Ml/vCTBqD+diWC4O0TZDxPMhXH5Ehl+67hcqeu4riwB2WvvKOlAqHqIuqVDRHtxwknvS1efstBKVdDC6aAfIa5f2dmCSxvd8elpcnufEefLGALTSPxg4uMVvpfWUkkmpmvOUpI3gNrlvP2H4KZk7hKYz+J4x2jv2pdPWUAtt1U4M8oQ4BCPrrHSxznw2Q5mdCMIB64ZeYnZ+rAMQS6WnZy1fTC3d0pCs0UCXH5JefBpha1clqHDUkxHA6/1EYYsSlKGFaPEmfv2uw7MFz0o+yntG34KVdsC8HO3g==`}, certs)
+				assert.Equals(t, x509util.ToX5C(tc.certs), certs)
+			}
+		})
+	}
+}
+
+func TestValidateCritical(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	parse := func(t *testing.T, opts *SignerOptions) *JSONWebToken {
+		t.Helper()
+		signer, err := NewSigner(SigningKey{Algorithm: EdDSA, Key: key}, opts)
+		assert.FatalError(t, err)
+		raw, err := Signed(signer).Claims(Claims{Subject: "subject"}).CompactSerialize()
+		assert.FatalError(t, err)
+		tok, err := ParseSigned(raw)
+		assert.FatalError(t, err)
+		return tok
+	}
+
+	x5t := new(SignerOptions).WithHeader(HeaderKey(X5TS256Key), "thumbprint").WithCritical(X5TS256Key)
+	tests := []struct {
+		name    string
+		tok     *JSONWebToken
+		opts    []Option
+		wantErr bool
+	}{
+		{"ok", parse(t, nil), nil, false},
+		{"ok known", parse(t, x5t), []Option{WithKnownCritical([]string{"b64", X5TS256Key})}, false},
+		{"fail unknown", parse(t, x5t), nil, true},
+		{"fail not known", parse(t, x5t), []Option{WithKnownCritical([]string{"b64"})}, true},
+		{"fail missing", parse(t, new(SignerOptions).WithCritical(X5TS256Key)), []Option{WithKnownCritical([]string{X5TS256Key})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateCritical(tt.tok, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCritical() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAlgorithm(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	signer, err := NewSigner(SigningKey{Algorithm: EdDSA, Key: key}, nil)
+	assert.FatalError(t, err)
+	raw, err := Signed(signer).Claims(Claims{Subject: "subject"}).CompactSerialize()
+	assert.FatalError(t, err)
+	tok, err := ParseSigned(raw)
+	assert.FatalError(t, err)
+
+	// Unsecured JWT as defined in RFC 7519 section 6.
+	encode := base64.RawURLEncoding.EncodeToString
+	none, err := ParseSigned(encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(`{"sub":"subject"}`)) + ".")
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		tok     *JSONWebToken
+		opts    []Option
+		wantErr bool
+	}{
+		{"ok", tok, nil, false},
+		{"ok allowed", tok, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{ES256, EdDSA})}, false},
+		{"fail not allowed", tok, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{HS256, RS256})}, true},
+		{"fail empty allowed", tok, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{})}, true},
+		{"fail none", none, nil, true},
+		{"fail none allowed", none, []Option{WithAllowedAlgorithms([]SignatureAlgorithm{"none"})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAlgorithm(tt.tok, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Header
+		allowed []SignatureAlgorithm
+		wantErr bool
+	}{
+		{"ok", Header{Algorithm: ES256}, nil, false},
+		{"ok allowed", Header{Algorithm: ES256}, []SignatureAlgorithm{RS256, ES256}, false},
+		{"fail not allowed", Header{Algorithm: HS256}, []SignatureAlgorithm{RS256}, true},
+		{"fail missing", Header{}, nil, true},
+		{"fail none", Header{Algorithm: "none"}, nil, true},
+		{"fail None", Header{Algorithm: "None"}, []SignatureAlgorithm{"None"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAlgorithm(tt.h, tt.allowed); (err != nil) != tt.wantErr {
+				t.Errorf("validateAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -0,0 +1,175 @@
+package keyutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// aesKWIV is the default initial value defined in RFC 3394, Section 2.2.3.1.
+var aesKWIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKWPPrefix is the prefix of the alternative initial value defined in RFC
+// 5649, Section 3.
+var aesKWPPrefix = []byte{0xA6, 0x59, 0x59, 0xA6}
+
+// WrapAES wraps the given key with the key encryption key (KEK) using the AES
+// Key Wrap algorithm defined in RFC 3394. This is the algorithm used by the
+// A128KW, A192KW and A256KW JWE key management algorithms.
+//
+// The KEK must be a 128, 192 or 256-bit AES key, and the key to wrap must be a
+// multiple of 64 bits and at least 128 bits long. Use WrapAESWithPadding to
+// wrap keys of any length.
+func WrapAES(kek, key []byte) ([]byte, error) {
+	block, err := newAESKeyWrapCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, errors.Errorf("error wrapping key: key length must be a multiple of 8 and at least 16 bytes, but got %d", len(key))
+	}
+	return aesWrap(block, aesKWIV, key), nil
+}
+
+// UnwrapAES unwraps a key wrapped with WrapAES using the given key encryption
+// key.
+func UnwrapAES(kek, wrapped []byte) ([]byte, error) {
+	block, err := newAESKeyWrapCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.Errorf("error unwrapping key: wrapped key length must be a multiple of 8 and at least 24 bytes, but got %d", len(wrapped))
+	}
+	iv, key := aesUnwrap(block, wrapped)
+	if subtle.ConstantTimeCompare(iv, aesKWIV) != 1 {
+		return nil, errors.New("error unwrapping key: integrity check failed")
+	}
+	return key, nil
+}
+
+// WrapAESWithPadding wraps the given key with the key encryption key (KEK)
+// using the AES Key Wrap with Padding algorithm defined in RFC 5649. The KEK
+// must be a 128, 192 or 256-bit AES key, and the key to wrap must not be empty.
+func WrapAESWithPadding(kek, key []byte) ([]byte, error) {
+	block, err := newAESKeyWrapCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 || uint64(len(key)) > 0xFFFFFFFF {
+		return nil, errors.Errorf("error wrapping key: key length must be between 1 and 2^32-1 bytes, but got %d", len(key))
+	}
+
+	iv := make([]byte, 8)
+	copy(iv, aesKWPPrefix)
+	binary.BigEndian.PutUint32(iv[4:], uint32(len(key)))
+
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+
+	// A single block is encrypted directly, RFC 5649, Section 4.1.
+	if len(padded) == 8 {
+		out := make([]byte, 16)
+		copy(out, iv)
+		copy(out[8:], padded)
+		block.Encrypt(out, out)
+		return out, nil
+	}
+	return aesWrap(block, iv, padded), nil
+}
+
+// UnwrapAESWithPadding unwraps a key wrapped with WrapAESWithPadding using the
+// given key encryption key.
+func UnwrapAESWithPadding(kek, wrapped []byte) ([]byte, error) {
+	block, err := newAESKeyWrapCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errors.Errorf("error unwrapping key: wrapped key length must be a multiple of 8 and at least 16 bytes, but got %d", len(wrapped))
+	}
+
+	var iv, padded []byte
+	if len(wrapped) == 16 {
+		out := make([]byte, 16)
+		block.Decrypt(out, wrapped)
+		iv, padded = out[:8], out[8:]
+	} else {
+		iv, padded = aesUnwrap(block, wrapped)
+	}
+
+	// Check the alternative initial value, RFC 5649, Section 3.
+	mli := uint64(binary.BigEndian.Uint32(iv[4:]))
+	if subtle.ConstantTimeCompare(iv[:4], aesKWPPrefix) != 1 ||
+		mli+7 < uint64(len(padded)) || mli > uint64(len(padded)) {
+		return nil, errors.New("error unwrapping key: integrity check failed")
+	}
+	var nonZero byte
+	for _, b := range padded[mli:] {
+		nonZero |= b
+	}
+	if nonZero != 0 {
+		return nil, errors.New("error unwrapping key: integrity check failed")
+	}
+	return padded[:mli], nil
+}
+
+func newAESKeyWrapCipher(kek []byte) (cipher.Block, error) {
+	switch len(kek) {
+	case 16, 24, 32:
+		return aes.NewCipher(kek)
+	default:
+		return nil, errors.Errorf("invalid key encryption key: AES key must be 128, 192 or 256 bits, but got %d bits", len(kek)*8)
+	}
+}
+
+// aesWrap implements the wrapping process defined in RFC 3394, Section 2.2.1,
+// with the given initial value. The length of key must be a multiple of 8.
+func aesWrap(block cipher.Block, iv, key []byte) []byte {
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out[8:], key)
+
+	a := make([]byte, 8)
+	copy(a, iv)
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, a)
+			copy(b[8:], out[i*8:i*8+8])
+			block.Encrypt(b, b)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[i*8:], b[8:])
+		}
+	}
+	copy(out, a)
+	return out
+}
+
+// aesUnwrap implements the unwrapping process defined in RFC 3394, Section
+// 2.2.2, and returns the initial value and the key. The length of wrapped must
+// be a multiple of 8 and at least 24 bytes.
+func aesUnwrap(block cipher.Block, wrapped []byte) ([]byte, []byte) {
+	n := len(wrapped)/8 - 1
+	key := make([]byte, len(wrapped)-8)
+	copy(key, wrapped[8:])
+
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b, binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], key[(i-1)*8:i*8])
+			block.Decrypt(b, b)
+			copy(a, b[:8])
+			copy(key[(i-1)*8:], b[8:])
+		}
+	}
+	return a, key
+}
@@ -0,0 +1,210 @@
+package keyutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrapAES(t *testing.T) {
+	kek128 := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	kek192 := mustHex(t, "000102030405060708090A0B0C0D0E0F1011121314151617")
+	kek256 := mustHex(t, "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key128 := mustHex(t, "00112233445566778899AABBCCDDEEFF")
+	key192 := mustHex(t, "00112233445566778899AABBCCDDEEFF0001020304050607")
+	key256 := mustHex(t, "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+
+	// Test vectors from RFC 3394, Section 4.
+	tests := []struct {
+		name string
+		kek  []byte
+		key  []byte
+		want []byte
+	}{
+		{"ok 128-bit key with 128-bit kek", kek128, key128, mustHex(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")},
+		{"ok 128-bit key with 192-bit kek", kek192, key128, mustHex(t, "96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D")},
+		{"ok 128-bit key with 256-bit kek", kek256, key128, mustHex(t, "64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7")},
+		{"ok 192-bit key with 192-bit kek", kek192, key192, mustHex(t, "031D33264E15D33268F24EC260743EDCE1C6C7DDEE725A936BA814915C6762D2")},
+		{"ok 192-bit key with 256-bit kek", kek256, key192, mustHex(t, "A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1")},
+		{"ok 256-bit key with 256-bit kek", kek256, key256, mustHex(t, "28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WrapAES(tt.kek, tt.key)
+			if err != nil {
+				t.Fatalf("WrapAES() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("WrapAES() = %X, want %X", got, tt.want)
+			}
+			key, err := UnwrapAES(tt.kek, got)
+			if err != nil {
+				t.Fatalf("UnwrapAES() error = %v", err)
+			}
+			if !bytes.Equal(key, tt.key) {
+				t.Errorf("UnwrapAES() = %X, want %X", key, tt.key)
+			}
+		})
+	}
+}
+
+func TestWrapAES_fail(t *testing.T) {
+	kek := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	tests := []struct {
+		name string
+		kek  []byte
+		key  []byte
+	}{
+		{"fail kek size", kek[:15], make([]byte, 16)},
+		{"fail 512-bit kek", make([]byte, 64), make([]byte, 16)},
+		{"fail short key", kek, make([]byte, 8)},
+		{"fail key size", kek, make([]byte, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := WrapAES(tt.kek, tt.key); err == nil {
+				t.Errorf("WrapAES() = %X, want error", got)
+			}
+		})
+	}
+}
+
+func TestUnwrapAES_fail(t *testing.T) {
+	kek := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	wrapped := mustHex(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+	tampered := append([]byte{}, wrapped...)
+	tampered[10] ^= 0x01
+
+	tests := []struct {
+		name    string
+		kek     []byte
+		wrapped []byte
+	}{
+		{"fail kek size", kek[:8], wrapped},
+		{"fail other kek", mustHex(t, "0F0E0D0C0B0A09080706050403020100"), wrapped},
+		{"fail tampered", kek, tampered},
+		{"fail short", kek, wrapped[:16]},
+		{"fail size", kek, wrapped[:23]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := UnwrapAES(tt.kek, tt.wrapped); err == nil {
+				t.Errorf("UnwrapAES() = %X, want error", got)
+			}
+		})
+	}
+}
+
+func TestWrapAESWithPadding(t *testing.T) {
+	kek := mustHex(t, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+
+	// Test vectors from RFC 5649, Section 6.
+	tests := []struct {
+		name string
+		kek  []byte
+		key  []byte
+		want []byte
+	}{
+		{"ok 20 octets", kek, mustHex(t, "c37b7e6492584340bed12207808941155068f738"), mustHex(t, "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a")},
+		{"ok 7 octets", kek, mustHex(t, "466f7250617369"), mustHex(t, "afbeb0f07dfbf5419200f2ccb50bb24f")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WrapAESWithPadding(tt.kek, tt.key)
+			if err != nil {
+				t.Fatalf("WrapAESWithPadding() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("WrapAESWithPadding() = %x, want %x", got, tt.want)
+			}
+			key, err := UnwrapAESWithPadding(tt.kek, got)
+			if err != nil {
+				t.Fatalf("UnwrapAESWithPadding() error = %v", err)
+			}
+			if !bytes.Equal(key, tt.key) {
+				t.Errorf("UnwrapAESWithPadding() = %x, want %x", key, tt.key)
+			}
+		})
+	}
+}
+
+func TestWrapAESWithPadding_roundTrip(t *testing.T) {
+	kek := mustHex(t, "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	for size := 1; size <= 40; size++ {
+		key := bytes.Repeat([]byte{0xAB}, size)
+		wrapped, err := WrapAESWithPadding(kek, key)
+		if err != nil {
+			t.Fatalf("WrapAESWithPadding() error = %v", err)
+		}
+		if want := (size+7)/8*8 + 8; len(wrapped) != want {
+			t.Errorf("WrapAESWithPadding() len = %d, want %d", len(wrapped), want)
+		}
+		got, err := UnwrapAESWithPadding(kek, wrapped)
+		if err != nil {
+			t.Fatalf("UnwrapAESWithPadding() error = %v", err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Errorf("UnwrapAESWithPadding() = %x, want %x", got, key)
+		}
+	}
+}
+
+func TestWrapAESWithPadding_fail(t *testing.T) {
+	kek := mustHex(t, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	if got, err := WrapAESWithPadding(kek[:20], []byte("key")); err == nil {
+		t.Errorf("WrapAESWithPadding() = %x, want error", got)
+	}
+	if got, err := WrapAESWithPadding(kek, nil); err == nil {
+		t.Errorf("WrapAESWithPadding() = %x, want error", got)
+	}
+}
+
+func TestUnwrapAESWithPadding_fail(t *testing.T) {
+	kek := mustHex(t, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	wrapped := mustHex(t, "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a")
+	single := mustHex(t, "afbeb0f07dfbf5419200f2ccb50bb24f")
+	tampered := append([]byte{}, wrapped...)
+	tampered[20] ^= 0x01
+
+	// RFC 3394 wrapped keys use a different initial value.
+	rfc3394, err := WrapAES(kek, make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Single blocks with invalid lengths or non-zero padding.
+	encryptBlock := func(b []byte) []byte {
+		block, err := newAESKeyWrapCipher(kek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, 16)
+		block.Encrypt(out, b)
+		return out
+	}
+	zeroLength := encryptBlock(mustHex(t, "a65959a6000000000102030405060708"))
+	longLength := encryptBlock(mustHex(t, "a65959a6000000090102030405060708"))
+	badPadding := encryptBlock(mustHex(t, "a65959a6000000070102030405060708"))
+
+	tests := []struct {
+		name    string
+		kek     []byte
+		wrapped []byte
+	}{
+		{"fail kek size", kek[:20], wrapped},
+		{"fail other kek", mustHex(t, "000102030405060708090A0B0C0D0E0F"), wrapped},
+		{"fail tampered", kek, tampered},
+		{"fail tampered single", kek, append(append([]byte{}, single[:15]...), single[15]^0x01)},
+		{"fail short", kek, wrapped[:8]},
+		{"fail size", kek, wrapped[:31]},
+		{"fail rfc3394", kek, rfc3394},
+		{"fail zero length", kek, zeroLength},
+		{"fail long length", kek, longLength},
+		{"fail padding", kek, badPadding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := UnwrapAESWithPadding(tt.kek, tt.wrapped); err == nil {
+				t.Errorf("UnwrapAESWithPadding() = %x, want error", got)
+			}
+		})
+	}
+}
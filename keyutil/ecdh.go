@@ -0,0 +1,88 @@
+//go:build go1.20
+
+package keyutil
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x25519"
+)
+
+// GenerateECDHKey generates a private key for ECDH key agreement using the
+// given curve. Supported curves are "P-256", "P-384", "P-521" and "X25519".
+func GenerateECDHKey(crv string) (*ecdh.PrivateKey, error) {
+	c, err := ecdhCurve(crv)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating %s key", crv)
+	}
+	return key, nil
+}
+
+// ECDHPrivateKey converts the given *ecdsa.PrivateKey, x25519.PrivateKey or
+// *ecdh.PrivateKey to an *ecdh.PrivateKey.
+func ECDHPrivateKey(priv crypto.PrivateKey) (*ecdh.PrivateKey, error) {
+	switch k := priv.(type) {
+	case *ecdh.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		key, err := k.ECDH()
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting EC key")
+		}
+		return key, nil
+	case x25519.PrivateKey:
+		key, err := ecdh.X25519().NewPrivateKey(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting X25519 key")
+		}
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", priv)
+	}
+}
+
+// ECDHPublicKey converts the given *ecdsa.PublicKey, x25519.PublicKey or
+// *ecdh.PublicKey to an *ecdh.PublicKey.
+func ECDHPublicKey(pub crypto.PublicKey) (*ecdh.PublicKey, error) {
+	switch k := pub.(type) {
+	case *ecdh.PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		key, err := k.ECDH()
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting EC key")
+		}
+		return key, nil
+	case x25519.PublicKey:
+		key, err := ecdh.X25519().NewPublicKey(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting X25519 key")
+		}
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func ecdhCurve(crv string) (ecdh.Curve, error) {
+	switch crv {
+	case "P-256":
+		return ecdh.P256(), nil
+	case "P-384":
+		return ecdh.P384(), nil
+	case "P-521":
+		return ecdh.P521(), nil
+	case "X25519":
+		return ecdh.X25519(), nil
+	default:
+		return nil, errors.Errorf("invalid value for argument crv (crv: '%s')", crv)
+	}
+}
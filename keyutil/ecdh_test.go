@@ -0,0 +1,116 @@
+//go:build go1.20
+
+package keyutil
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/x25519"
+)
+
+func TestGenerateECDHKey(t *testing.T) {
+	tests := []struct {
+		crv     string
+		want    ecdh.Curve
+		wantErr bool
+	}{
+		{"P-256", ecdh.P256(), false},
+		{"P-384", ecdh.P384(), false},
+		{"P-521", ecdh.P521(), false},
+		{"X25519", ecdh.X25519(), false},
+		{"Ed25519", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.crv, func(t *testing.T) {
+			got, err := GenerateECDHKey(tt.crv)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateECDHKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equals(t, tt.want, got.Curve())
+
+			// Key agreement with a peer key
+			peer, err := tt.want.GenerateKey(rand.Reader)
+			assert.FatalError(t, err)
+			secret, err := got.ECDH(peer.PublicKey())
+			assert.FatalError(t, err)
+			peerSecret, err := peer.ECDH(got.PublicKey())
+			assert.FatalError(t, err)
+			assert.Equals(t, secret, peerSecret)
+		})
+	}
+}
+
+func TestECDHPrivateKey(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	assert.FatalError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	assert.FatalError(t, err)
+	_, x25519Key, err := x25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+	ecdhKey, err := ecdh.P384().GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		priv    interface{}
+		pub     interface{}
+		wantErr bool
+	}{
+		{"P-256", p256, p256.Public(), false},
+		{"P-521", p521, p521.Public(), false},
+		{"X25519", x25519Key, x25519Key.Public(), false},
+		{"ecdh", ecdhKey, ecdhKey.Public(), false},
+		{"fail P-224", p224, p224.Public(), true},
+		{"fail X25519", x25519Key[:31], x25519.PublicKey(x25519Key[:31]), true},
+		{"fail type", "not a key", "not a key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priv, err := ECDHPrivateKey(tt.priv)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ECDHPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			pub, err := ECDHPublicKey(tt.pub)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ECDHPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, priv)
+				assert.Nil(t, pub)
+				return
+			}
+			assert.True(t, priv.PublicKey().Equal(pub))
+
+			// Key agreement with a peer key
+			peer, err := priv.Curve().GenerateKey(rand.Reader)
+			assert.FatalError(t, err)
+			secret, err := priv.ECDH(peer.PublicKey())
+			assert.FatalError(t, err)
+			peerSecret, err := peer.ECDH(pub)
+			assert.FatalError(t, err)
+			assert.Equals(t, secret, peerSecret)
+
+			// The shared secret must match the one computed by the x25519 package
+			if k, ok := tt.priv.(x25519.PrivateKey); ok {
+				want, err := k.SharedKey(peer.PublicKey().Bytes())
+				assert.FatalError(t, err)
+				assert.Equals(t, want, secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package keyutil
+
+import (
+	"crypto/elliptic"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// ECDSASigToRS converts an ASN.1 DER ECDSA signature, as returned by
+// crypto/ecdsa and most KMS backends, to the fixed-width concat(R,S) format
+// used by JOSE (RFC 7518, Section 3.4). R and S are left-padded with zeros to
+// the size of the given curve.
+func ECDSASigToRS(der []byte, curve elliptic.Curve) ([]byte, error) {
+	size, err := ecdsaOctetSize(curve)
+	if err != nil {
+		return nil, err
+	}
+
+	var inner cryptobyte.String
+	r, s := new(big.Int), new(big.Int)
+	input := cryptobyte.String(der)
+	if !input.ReadASN1(&inner, asn1.SEQUENCE) || !input.Empty() ||
+		!inner.ReadASN1Integer(r) || !inner.ReadASN1Integer(s) || !inner.Empty() {
+		return nil, errors.New("error parsing ECDSA signature: invalid ASN.1 signature")
+	}
+	if err := checkECDSAValues(r, s, curve); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, size*2)
+	r.FillBytes(raw[:size])
+	s.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// ECDSASigFromRS converts a fixed-width concat(R,S) ECDSA signature, as used by
+// JOSE, to the ASN.1 DER format used by crypto/ecdsa. The length of the
+// signature must be twice the size of the given curve.
+func ECDSASigFromRS(raw []byte, curve elliptic.Curve) ([]byte, error) {
+	size, err := ecdsaOctetSize(curve)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != size*2 {
+		return nil, errors.Errorf("error parsing ECDSA signature: invalid signature length %d, want %d", len(raw), size*2)
+	}
+
+	r := new(big.Int).SetBytes(raw[:size])
+	s := new(big.Int).SetBytes(raw[size:])
+	if err := checkECDSAValues(r, s, curve); err != nil {
+		return nil, err
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1BigInt(r)
+		b.AddASN1BigInt(s)
+	})
+	return b.Bytes()
+}
+
+// ecdsaOctetSize returns the size in bytes of the R and S values for the given
+// curve.
+func ecdsaOctetSize(curve elliptic.Curve) (int, error) {
+	if curve == nil {
+		return 0, errors.New("error converting ECDSA signature: curve cannot be nil")
+	}
+	return (curve.Params().BitSize + 7) / 8, nil
+}
+
+// checkECDSAValues checks that R and S are in the range [1, N-1].
+func checkECDSAValues(r, s *big.Int, curve elliptic.Curve) error {
+	n := curve.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return errors.New("error parsing ECDSA signature: R and S must be in the range [1, N-1]")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package keyutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestECDSASigToRS_roundTrip(t *testing.T) {
+	digest := sha256.Sum256([]byte("the message"))
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := (curve.Params().BitSize + 7) / 8
+
+			// Sign multiple times to get R and S values with leading zeros.
+			for i := 0; i < 50; i++ {
+				der, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw, err := ECDSASigToRS(der, curve)
+				if err != nil {
+					t.Fatalf("ECDSASigToRS() error = %v", err)
+				}
+				if len(raw) != 2*size {
+					t.Fatalf("ECDSASigToRS() len = %d, want %d", len(raw), 2*size)
+				}
+				r := new(big.Int).SetBytes(raw[:size])
+				s := new(big.Int).SetBytes(raw[size:])
+				if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+					t.Fatal("ecdsa.Verify() = false, want true")
+				}
+				got, err := ECDSASigFromRS(raw, curve)
+				if err != nil {
+					t.Fatalf("ECDSASigFromRS() error = %v", err)
+				}
+				if !bytes.Equal(got, der) {
+					t.Fatalf("ECDSASigFromRS() = %x, want %x", got, der)
+				}
+			}
+		})
+	}
+}
+
+func TestECDSASigFromRS_leadingZeros(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			size := (curve.Params().BitSize + 7) / 8
+			raw := make([]byte, 2*size)
+			raw[size-1] = 0x01   // R = 1
+			raw[2*size-1] = 0x80 // S = 128, requires a leading zero in ASN.1
+
+			der, err := ECDSASigFromRS(raw, curve)
+			if err != nil {
+				t.Fatalf("ECDSASigFromRS() error = %v", err)
+			}
+			want := []byte{0x30, 0x07, 0x02, 0x01, 0x01, 0x02, 0x02, 0x00, 0x80}
+			if !bytes.Equal(der, want) {
+				t.Errorf("ECDSASigFromRS() = %x, want %x", der, want)
+			}
+			got, err := ECDSASigToRS(der, curve)
+			if err != nil {
+				t.Fatalf("ECDSASigToRS() error = %v", err)
+			}
+			if !bytes.Equal(got, raw) {
+				t.Errorf("ECDSASigToRS() = %x, want %x", got, raw)
+			}
+		})
+	}
+}
+
+func TestECDSASigToRS_fail(t *testing.T) {
+	p256 := elliptic.P256()
+	n := p256.Params().N
+	mustDER := func(r, s *big.Int) []byte {
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	valid := mustDER(big.NewInt(1), big.NewInt(2))
+
+	tests := []struct {
+		name  string
+		der   []byte
+		curve elliptic.Curve
+	}{
+		{"fail nil curve", valid, nil},
+		{"fail empty", []byte{}, p256},
+		{"fail not asn1", []byte("foobar"), p256},
+		{"fail trailing data", append(append([]byte{}, valid...), 0), p256},
+		{"fail zero r", mustDER(big.NewInt(0), big.NewInt(2)), p256},
+		{"fail negative s", mustDER(big.NewInt(1), big.NewInt(-2)), p256},
+		{"fail r too large", mustDER(n, big.NewInt(2)), p256},
+		{"fail s too large", mustDER(big.NewInt(1), new(big.Int).Lsh(n, 8)), p256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ECDSASigToRS(tt.der, tt.curve); err == nil {
+				t.Errorf("ECDSASigToRS() = %x, want error", got)
+			}
+		})
+	}
+}
+
+func TestECDSASigFromRS_fail(t *testing.T) {
+	p256 := elliptic.P256()
+	nBytes := p256.Params().N.FillBytes(make([]byte, 32))
+
+	tests := []struct {
+		name  string
+		raw   []byte
+		curve elliptic.Curve
+	}{
+		{"fail nil curve", make([]byte, 64), nil},
+		{"fail short", make([]byte, 63), p256},
+		{"fail long", make([]byte, 65), p256},
+		{"fail wrong curve", make([]byte, 96), p256},
+		{"fail zero", make([]byte, 64), p256},
+		{"fail r too large", append(append(append([]byte{}, nBytes...), make([]byte, 31)...), 1), p256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ECDSASigFromRS(tt.raw, tt.curve); err == nil {
+				t.Errorf("ECDSASigFromRS() = %x, want error", got)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"go.step.sm/crypto/fingerprint"
+	"go.step.sm/crypto/x25519"
 )
 
 // FingerprintEncoding defines the supported encodings in certificate
@@ -39,7 +40,8 @@ type subjectPublicKeyInfo struct {
 	SubjectPublicKey asn1.BitString
 }
 
-// Fingerprint returns the SHA-256 fingerprint of an public key.
+// Fingerprint returns the SHA-256 fingerprint of an public key. Supported keys
+// are RSA, ECDSA, Ed25519 and X25519.
 //
 // The fingerprint is calculated from the encoding of the key according to RFC
 // 5280 section 4.2.1.2, but using SHA-256 instead of SHA-1.
@@ -53,22 +55,41 @@ func Fingerprint(pub crypto.PublicKey) (string, error) {
 // The fingerprint is calculated from the encoding of the key according to RFC
 // 5280 section 4.2.1.2, but using SHA-256 instead of SHA-1.
 func EncodedFingerprint(pub crypto.PublicKey, encoding FingerprintEncoding) (string, error) {
-	b, err := x509.MarshalPKIXPublicKey(pub)
+	b, err := subjectPublicKey(pub)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling public key: %w", err)
-	}
-	var info subjectPublicKeyInfo
-	if _, err = asn1.Unmarshal(b, &info); err != nil {
-		return "", fmt.Errorf("error unmarshaling public key: %w", err)
+		return "", err
 	}
 	if encoding == DefaultFingerprint {
 		encoding = Base64Fingerprint
 	}
 
-	sum := sha256.Sum256(info.SubjectPublicKey.Bytes)
+	sum := sha256.Sum256(b)
 	fp := fingerprint.Fingerprint(sum[:], encoding)
 	if fp == "" {
 		return "", fmt.Errorf("error formatting fingerprint: unsupported encoding")
 	}
 	return "SHA256:" + fp, nil
 }
+
+// subjectPublicKey returns the bytes of the subjectPublicKey field in the
+// PKIX encoding of the given public key.
+func subjectPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	// x509.MarshalPKIXPublicKey does not support X25519 keys, but RFC 8410
+	// defines its subjectPublicKey as the raw key.
+	if k, ok := pub.(x25519.PublicKey); ok {
+		if len(k) != x25519.PublicKeySize {
+			return nil, fmt.Errorf("error marshaling public key: x25519 key is not %d bytes", x25519.PublicKeySize)
+		}
+		return k, nil
+	}
+
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling public key: %w", err)
+	}
+	var info subjectPublicKeyInfo
+	if _, err = asn1.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("error unmarshaling public key: %w", err)
+	}
+	return info.SubjectPublicKey.Bytes, nil
+}
@@ -3,9 +3,12 @@ package keyutil
 import (
 	"crypto"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"os"
 	"testing"
+
+	"go.step.sm/crypto/x25519"
 )
 
 func readPublicKey(t *testing.T, filename string) crypto.PublicKey {
@@ -25,10 +28,21 @@ func readPublicKey(t *testing.T, filename string) crypto.PublicKey {
 	return pub
 }
 
+func mustX25519PublicKey(t *testing.T) x25519.PublicKey {
+	t.Helper()
+	// Public key from RFC 7748 section 6.1
+	b, err := hex.DecodeString("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x25519.PublicKey(b)
+}
+
 func TestFingerprint(t *testing.T) {
 	ecdsaKey := readPublicKey(t, "testdata/p256.pub")
 	rsaKey := readPublicKey(t, "testdata/rsa.pub")
 	ed25519Key := readPublicKey(t, "testdata/ed25519.pub")
+	x25519Key := mustX25519PublicKey(t)
 
 	type args struct {
 		pub crypto.PublicKey
@@ -42,7 +56,9 @@ func TestFingerprint(t *testing.T) {
 		{"ecdsa", args{ecdsaKey}, "SHA256:BlA/0e0DGQ8Gcpv+EPNDp3aa8O4TZ6VDLKMIXi40qlE=", false},
 		{"rsa", args{rsaKey}, "SHA256:Su5MWuU91vpyPy2YlX7lqTXomZ1AoGqKbvbZbf0Ff6M=", false},
 		{"ed25519", args{ed25519Key}, "SHA256:r/tA+Uv4M2ff1ZrAz8l+5mu0aJ1yOGwnWV5jDotBySI=", false},
+		{"x25519", args{x25519Key}, "SHA256:815WFhYKML88bnn6c8V21AIF6Pw7pOHG3Pk+a5joV7Q=", false},
 		{"fail", args{[]byte("not a key")}, "", true},
+		{"fail x25519", args{x25519Key[:31]}, "", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -62,6 +78,7 @@ func TestEncodedFingerprint(t *testing.T) {
 	ecdsaKey := readPublicKey(t, "testdata/p256.pub")
 	rsaKey := readPublicKey(t, "testdata/rsa.pub")
 	ed25519Key := readPublicKey(t, "testdata/ed25519.pub")
+	x25519Key := mustX25519PublicKey(t)
 
 	type args struct {
 		pub      crypto.PublicKey
@@ -76,6 +93,7 @@ func TestEncodedFingerprint(t *testing.T) {
 		{"ecdsa", args{ecdsaKey, DefaultFingerprint}, "SHA256:BlA/0e0DGQ8Gcpv+EPNDp3aa8O4TZ6VDLKMIXi40qlE=", false},
 		{"rsa", args{rsaKey, HexFingerprint}, "SHA256:4aee4c5ae53dd6fa723f2d98957ee5a935e8999d40a06a8a6ef6d96dfd057fa3", false},
 		{"ed25519", args{ed25519Key, Base64RawURLFingerprint}, "SHA256:r_tA-Uv4M2ff1ZrAz8l-5mu0aJ1yOGwnWV5jDotBySI", false},
+		{"x25519", args{x25519Key, HexFingerprint}, "SHA256:f35e5616160a30bf3c6e79fa73c576d40205e8fc3ba4e1c6dcf93e6b98e857b4", false},
 		{"fail", args{[]byte("not a key"), DefaultFingerprint}, "", true},
 		{"fail bad encoding", args{ed25519Key, 100}, "", true},
 	}
@@ -92,3 +110,42 @@ func TestEncodedFingerprint(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodedFingerprint_unique(t *testing.T) {
+	tests := []struct {
+		kty  string
+		crv  string
+		size int
+	}{
+		{"EC", "P-256", 0},
+		{"RSA", "", 2048},
+		{"OKP", "Ed25519", 0},
+		{"OKP", "X25519", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kty+tt.crv, func(t *testing.T) {
+			fingerprints := make(map[string]bool)
+			for i := 0; i < 3; i++ {
+				pub, _, err := GenerateKeyPair(tt.kty, tt.crv, tt.size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fp, err := EncodedFingerprint(pub, EmojiFingerprint)
+				if err != nil {
+					t.Fatal(err)
+				}
+				again, err := EncodedFingerprint(pub, EmojiFingerprint)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fp != again {
+					t.Errorf("EncodedFingerprint() = %v, want %v", again, fp)
+				}
+				if fingerprints[fp] {
+					t.Errorf("EncodedFingerprint() = %v, already returned for a different key", fp)
+				}
+				fingerprints[fp] = true
+			}
+		})
+	}
+}
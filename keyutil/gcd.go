@@ -0,0 +1,82 @@
+package keyutil
+
+import (
+	"crypto/rsa"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// BatchGCDCheck checks if any of the given RSA public keys share a prime
+// factor, a catastrophic failure usually caused by a bad random number
+// generator. It returns the indices of all the pairs of keys with a common
+// factor. Keys with the same modulus are also reported.
+//
+// The check uses Bernstein's batch GCD algorithm, it builds a product tree of
+// all the moduli and a remainder tree to compute the GCD of each modulus with
+// the product of all the others, then only the keys flagged by it are compared
+// pairwise.
+func BatchGCDCheck(pubs []*rsa.PublicKey) ([][2]int, error) {
+	moduli := make([]*big.Int, len(pubs))
+	for i, pub := range pubs {
+		if pub == nil || pub.N == nil || pub.N.Sign() <= 0 {
+			return nil, errors.Errorf("invalid RSA public key at index %d", i)
+		}
+		moduli[i] = pub.N
+	}
+	if len(moduli) < 2 {
+		return nil, nil
+	}
+
+	// Product tree, the first level are the moduli and the last one the
+	// product of all of them.
+	tree := [][]*big.Int{moduli}
+	for level := moduli; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = new(big.Int).Mul(level[2*i], level[2*i+1])
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		tree = append(tree, next)
+		level = next
+	}
+
+	// Remainder tree, going down reducing the product modulo the square of
+	// each node.
+	rems := tree[len(tree)-1]
+	for i := len(tree) - 2; i >= 0; i-- {
+		level := tree[i]
+		next := make([]*big.Int, len(level))
+		for j, n := range level {
+			sq := new(big.Int).Mul(n, n)
+			next[j] = new(big.Int).Mod(rems[j/2], sq)
+		}
+		rems = next
+	}
+
+	// gcd(N_i, (P mod N_i^2) / N_i) > 1 if N_i shares a factor with any of
+	// the other moduli.
+	var flagged []int
+	one := big.NewInt(1)
+	for i, n := range moduli {
+		q := new(big.Int).Div(rems[i], n)
+		if new(big.Int).GCD(nil, nil, q, n).Cmp(one) != 0 {
+			flagged = append(flagged, i)
+		}
+	}
+
+	var pairs [][2]int
+	for a := 0; a < len(flagged); a++ {
+		for b := a + 1; b < len(flagged); b++ {
+			i, j := flagged[a], flagged[b]
+			if new(big.Int).GCD(nil, nil, moduli[i], moduli[j]).Cmp(one) != 0 {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+
+	return pairs, nil
+}
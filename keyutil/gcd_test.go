@@ -0,0 +1,63 @@
+package keyutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestBatchGCDCheck(t *testing.T) {
+	primes := make([]*big.Int, 8)
+	for i := range primes {
+		p, err := rand.Prime(rand.Reader, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		primes[i] = p
+	}
+	pub := func(p, q *big.Int) *rsa.PublicKey {
+		return &rsa.PublicKey{N: new(big.Int).Mul(p, q), E: 65537}
+	}
+
+	tests := []struct {
+		name    string
+		pubs    []*rsa.PublicKey
+		want    [][2]int
+		wantErr bool
+	}{
+		{"ok empty", nil, nil, false},
+		{"ok one", []*rsa.PublicKey{pub(primes[0], primes[1])}, nil, false},
+		{"ok no shared", []*rsa.PublicKey{
+			pub(primes[0], primes[1]), pub(primes[2], primes[3]), pub(primes[4], primes[5]),
+		}, nil, false},
+		{"ok shared", []*rsa.PublicKey{
+			pub(primes[0], primes[1]), pub(primes[2], primes[3]), pub(primes[4], primes[1]),
+		}, [][2]int{{0, 2}}, false},
+		{"ok multiple shared", []*rsa.PublicKey{
+			pub(primes[0], primes[1]), pub(primes[2], primes[3]), pub(primes[4], primes[5]),
+			pub(primes[6], primes[3]), pub(primes[0], primes[7]),
+		}, [][2]int{{0, 4}, {1, 3}}, false},
+		{"ok shared by three", []*rsa.PublicKey{
+			pub(primes[0], primes[1]), pub(primes[0], primes[2]), pub(primes[3], primes[4]), pub(primes[0], primes[5]),
+		}, [][2]int{{0, 1}, {0, 3}, {1, 3}}, false},
+		{"ok duplicated", []*rsa.PublicKey{
+			pub(primes[0], primes[1]), pub(primes[2], primes[3]), pub(primes[0], primes[1]),
+		}, [][2]int{{0, 2}}, false},
+		{"fail nil", []*rsa.PublicKey{pub(primes[0], primes[1]), nil}, nil, true},
+		{"fail nil modulus", []*rsa.PublicKey{pub(primes[0], primes[1]), {E: 65537}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BatchGCDCheck(tt.pubs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BatchGCDCheck() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BatchGCDCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package keyutil
+
+import (
+	"crypto"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// SymmetricKey is a secret key used with symmetric algorithms, like AES or
+// HMAC.
+type SymmetricKey []byte
+
+// DeriveHKDF derives a key of the given length in bytes from the given secret
+// using the HMAC-based key derivation function (HKDF) defined in RFC 5869 with
+// the given hash. The salt and info are optional, they are used in the extract
+// and expand steps respectively.
+//
+// The length must be positive and not greater than 255 times the size of the
+// hash.
+func DeriveHKDF(secret, salt, info []byte, length int, hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.Errorf("error deriving key: hash %s is not available", hash)
+	}
+	if max := 255 * hash.Size(); length <= 0 || length > max {
+		return nil, errors.Errorf("error deriving key: length must be between 1 and %d bytes, but got %d", max, length)
+	}
+
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(hash.New, secret, salt, info), key); err != nil {
+		return nil, errors.Wrap(err, "error deriving key")
+	}
+	return key, nil
+}
+
+// DeriveSymmetricKey derives a symmetric key of the given size in bytes using
+// DeriveHKDF.
+func DeriveSymmetricKey(secret, salt, info []byte, size int, hash crypto.Hash) (SymmetricKey, error) {
+	key, err := DeriveHKDF(secret, salt, info, size, hash)
+	if err != nil {
+		return nil, err
+	}
+	return SymmetricKey(key), nil
+}
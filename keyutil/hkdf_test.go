@@ -0,0 +1,130 @@
+package keyutil
+
+import (
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDeriveHKDF(t *testing.T) {
+	type args struct {
+		secret []byte
+		salt   []byte
+		info   []byte
+		length int
+		hash   crypto.Hash
+	}
+	// Test vectors from RFC 5869, Appendix A.
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{"ok rfc5869 case 1", args{
+			mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"),
+			mustHex(t, "000102030405060708090a0b0c"),
+			mustHex(t, "f0f1f2f3f4f5f6f7f8f9"),
+			42, crypto.SHA256,
+		}, mustHex(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"), false},
+		{"ok rfc5869 case 2", args{
+			mustHex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f"),
+			mustHex(t, "606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeaf"),
+			mustHex(t, "b0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"),
+			82, crypto.SHA256,
+		}, mustHex(t, "b11e398dc80327a1c8e7f78c596a49344f012eda2d4efad8a050cc4c19afa97c59045a99cac7827271cb41c65e590e09da3275600c2f09b8367793a9aca3db71cc30c58179ec3e87c14c01d5c1f3434f1d87"), false},
+		{"ok rfc5869 case 3", args{
+			mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"),
+			nil, nil,
+			42, crypto.SHA256,
+		}, mustHex(t, "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"), false},
+		{"ok rfc5869 case 4", args{
+			mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b"),
+			mustHex(t, "000102030405060708090a0b0c"),
+			mustHex(t, "f0f1f2f3f4f5f6f7f8f9"),
+			42, crypto.SHA1,
+		}, mustHex(t, "085a01ea1b10f36933068b56efa5ad81a4f14b822f5b091568a9cdd4f155fda2c22e422478d305f3f896"), false},
+		{"ok rfc5869 case 5", args{
+			mustHex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f"),
+			mustHex(t, "606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeaf"),
+			mustHex(t, "b0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"),
+			82, crypto.SHA1,
+		}, mustHex(t, "0bd770a74d1160f7c9f12cd5912a06ebff6adcae899d92191fe4305673ba2ffe8fa3f1a4e5ad79f3f334b3b202b2173c486ea37ce3d397ed034c7f9dfeb15c5e927336d0441f4c4300e2cff0d0900b52d3b4"), false},
+		{"ok rfc5869 case 6", args{
+			mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"),
+			nil, nil,
+			42, crypto.SHA1,
+		}, mustHex(t, "0ac1af7002b3d761d1e55298da9d0506b9ae52057220a306e07b6b87e8df21d0ea00033de03984d34918"), false},
+		{"ok rfc5869 case 7", args{
+			mustHex(t, "0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c"),
+			nil, nil,
+			42, crypto.SHA1,
+		}, mustHex(t, "2c91117204d745f3500d636a62f64f0ab3bae548aa53d423b0d1f27ebba6f5e5673a081d70cce7acfc48"), false},
+		{"ok max length", args{[]byte("secret"), nil, nil, 255 * 32, crypto.SHA256}, nil, false},
+		{"fail zero length", args{[]byte("secret"), nil, nil, 0, crypto.SHA256}, nil, true},
+		{"fail negative length", args{[]byte("secret"), nil, nil, -1, crypto.SHA256}, nil, true},
+		{"fail length too large", args{[]byte("secret"), nil, nil, 255*32 + 1, crypto.SHA256}, nil, true},
+		{"fail hash not available", args{[]byte("secret"), nil, nil, 32, crypto.Hash(0)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DeriveHKDF(tt.args.secret, tt.args.salt, tt.args.info, tt.args.length, tt.args.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeriveHKDF() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			switch {
+			case tt.wantErr:
+				if got != nil {
+					t.Errorf("DeriveHKDF() = %x, want nil", got)
+				}
+			case tt.want == nil:
+				if len(got) != tt.args.length {
+					t.Errorf("DeriveHKDF() len = %d, want %d", len(got), tt.args.length)
+				}
+			case !reflect.DeepEqual(got, tt.want):
+				t.Errorf("DeriveHKDF() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeriveSymmetricKey(t *testing.T) {
+	secret := []byte("master secret")
+	want, err := DeriveHKDF(secret, []byte("salt"), []byte("tenant-1"), 32, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DeriveSymmetricKey(secret, []byte("salt"), []byte("tenant-1"), 32, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, SymmetricKey(want)) {
+		t.Errorf("DeriveSymmetricKey() = %x, want %x", got, want)
+	}
+
+	other, err := DeriveSymmetricKey(secret, []byte("salt"), []byte("tenant-2"), 32, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(got, other) {
+		t.Error("DeriveSymmetricKey() returned the same key for different info")
+	}
+
+	if _, err := DeriveSymmetricKey(secret, nil, nil, 0, crypto.SHA256); err == nil {
+		t.Error("DeriveSymmetricKey() error = nil, wantErr true")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"math/big"
 	"sync/atomic"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/x25519"
 	"golang.org/x/crypto/ssh"
@@ -31,6 +32,9 @@ var (
 	// MinRSAKeyBytes is the minimum acceptable size (in bytes) for RSA keys
 	// signed by the authority.
 	MinRSAKeyBytes = 256
+	// DefaultRSAPublicExponent is the public exponent used by default when
+	// generating RSA keys.
+	DefaultRSAPublicExponent = 65537
 )
 
 type atomicBool int32
@@ -126,6 +130,34 @@ func GenerateSigner(kty, crv string, size int) (crypto.Signer, error) {
 	}
 }
 
+// GenerateRSAKeyWithExponent creates an RSA key of the given size using the
+// given public exponent. If the exponent is 0 DefaultRSAPublicExponent is used.
+// The exponent must be odd and between 3 and 2^31-1.
+//
+// Small exponents like 3 are only supported for interoperability with legacy
+// systems, the default exponent should be used unless strictly required.
+func GenerateRSAKeyWithExponent(bits, exponent int) (crypto.Signer, error) {
+	if exponent == 0 {
+		exponent = DefaultRSAPublicExponent
+	}
+	if exponent < 3 || exponent > 1<<31-1 || exponent%2 == 0 {
+		return nil, errors.Errorf("invalid RSA public exponent %d: it must be an odd number between 3 and 2^31-1", exponent)
+	}
+	if min := MinRSAKeyBytes * 8; !insecureMode.isSet() && bits < min {
+		return nil, errors.Errorf("the size of the RSA key should be at least %d bits", min)
+	}
+	if exponent == 65537 {
+		return generateRSAKey(bits)
+	}
+
+	key, err := generateRSAKeyWithExponent(bits, exponent)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating RSA key")
+	}
+
+	return key, nil
+}
+
 // ExtractKey returns the given public or private key or extracts the public key
 // if a x509.Certificate or x509.CertificateRequest is given.
 func ExtractKey(in interface{}) (interface{}, error) {
@@ -156,7 +188,7 @@ func VerifyPair(pub crypto.PublicKey, priv crypto.PrivateKey) error {
 	if !ok {
 		return errors.New("private key type does implement crypto.Signer")
 	}
-	if !Equal(pub, signer.Public()) {
+	if !PublicKeysEqual(pub, signer.Public()) {
 		return errors.New("private key does not match public key")
 	}
 	return nil
@@ -197,6 +229,52 @@ func Equal(x, y any) bool {
 	}
 }
 
+// PublicKeysEqual reports whether a and b are the same public key. It supports
+// RSA, ECDSA, Ed25519 and X25519 public keys, including RSA and ECDSA keys
+// passed by value. It returns false, instead of panicking, if any of the keys
+// is nil or incomplete, if they are of different types, or if they are not
+// public keys.
+func PublicKeysEqual(a, b crypto.PublicKey) bool {
+	a, b = normalizePublicKey(a), normalizePublicKey(b)
+	if a == nil || b == nil {
+		return false
+	}
+	return Equal(a, b)
+}
+
+// normalizePublicKey returns the pointer form of RSA and ECDSA public keys, or
+// nil if the key is not a valid public key.
+func normalizePublicKey(key crypto.PublicKey) crypto.PublicKey {
+	switch k := key.(type) {
+	case rsa.PublicKey:
+		return normalizePublicKey(&k)
+	case ecdsa.PublicKey:
+		return normalizePublicKey(&k)
+	case *rsa.PublicKey:
+		if k == nil || k.N == nil {
+			return nil
+		}
+		return k
+	case *ecdsa.PublicKey:
+		if k == nil || k.Curve == nil || k.X == nil || k.Y == nil {
+			return nil
+		}
+		return k
+	case ed25519.PublicKey:
+		if len(k) != ed25519.PublicKeySize {
+			return nil
+		}
+		return k
+	case x25519.PublicKey:
+		if len(k) != x25519.PublicKeySize {
+			return nil
+		}
+		return k
+	default:
+		return nil
+	}
+}
+
 func generateECKey(crv string) (crypto.Signer, error) {
 	var c elliptic.Curve
 	switch crv {
@@ -206,6 +284,8 @@ func generateECKey(crv string) (crypto.Signer, error) {
 		c = elliptic.P384()
 	case "P-521":
 		c = elliptic.P521()
+	case "secp256k1":
+		c = secp256k1.S256()
 	default:
 		return nil, errors.Errorf("invalid value for argument crv (crv: '%s')", crv)
 	}
@@ -231,6 +311,68 @@ func generateRSAKey(bits int) (crypto.Signer, error) {
 	return key, nil
 }
 
+// generateRSAKeyWithExponent generates a two-prime RSA key with the given
+// public exponent. The standard library always uses 65537.
+func generateRSAKeyWithExponent(bits, exponent int) (*rsa.PrivateKey, error) {
+	return generateRSAKeyWithPrimes(bits, exponent, func(bits int) (*big.Int, error) {
+		return rand.Prime(rand.Reader, bits)
+	})
+}
+
+// generateRSAKeyWithPrimes generates a two-prime RSA key with the given public
+// exponent using the primes returned by the prime function.
+func generateRSAKeyWithPrimes(bits, exponent int, prime func(bits int) (*big.Int, error)) (*rsa.PrivateKey, error) {
+	if bits < 64 {
+		return nil, errors.New("RSA key size too small")
+	}
+
+	e := big.NewInt(int64(exponent))
+	one := big.NewInt(1)
+	for {
+		p, err := prime(bits - bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := prime(bits / 2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+
+		pminus1 := new(big.Int).Sub(p, one)
+		qminus1 := new(big.Int).Sub(q, one)
+		totient := new(big.Int).Mul(pminus1, qminus1)
+
+		// The exponent must be coprime with p-1 and q-1, ModInverse returns
+		// nil otherwise.
+		d := new(big.Int).ModInverse(e, totient)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: n,
+				E: exponent,
+			},
+			D:      d,
+			Primes: []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
 func generateOKPKey(crv string) (crypto.Signer, error) {
 	switch crv {
 	case "Ed25519":
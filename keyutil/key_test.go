@@ -6,6 +6,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -297,6 +298,7 @@ func TestGenerateKey(t *testing.T) {
 		{"P-256", randReader, args{"EC", "P-256", 0}, assertKey, crypto.SHA256, false},
 		{"P-384", randReader, args{"EC", "P-384", 0}, assertKey, crypto.SHA384, false},
 		{"P-521", randReader, args{"EC", "P-521", 0}, assertKey, crypto.SHA512, false},
+		{"secp256k1", randReader, args{"EC", "secp256k1", 0}, assertKey, crypto.SHA256, false},
 		{"Ed25519", randReader, args{"OKP", "Ed25519", 0}, assertKey, crypto.Hash(0), false},
 		{"X25519", randReader, args{"OKP", "X25519", 0}, assertKey, crypto.Hash(0), false},
 		{"OCT", zeroReader{}, args{"oct", "", 32}, assertOCT, crypto.Hash(0), false},
@@ -368,6 +370,70 @@ func TestGenerateKey_rsa(t *testing.T) {
 	}
 }
 
+func TestGenerateRSAKeyWithExponent(t *testing.T) {
+	type args struct {
+		bits     int
+		exponent int
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantE    int
+		insecure bool
+		wantErr  bool
+	}{
+		{"ok default", args{2048, 0}, 65537, false, false},
+		{"ok 65537", args{2048, 65537}, 65537, false, false},
+		{"ok 3", args{2048, 3}, 3, false, false},
+		{"ok 17", args{3072, 17}, 17, false, false},
+		{"ok insecure", args{1024, 3}, 3, true, false},
+		{"fail even", args{2048, 4}, 0, false, true},
+		{"fail one", args{2048, 1}, 0, false, true},
+		{"fail negative", args{2048, -3}, 0, false, true},
+		{"fail size", args{1024, 3}, 0, false, true},
+		{"fail too small", args{32, 3}, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.insecure {
+				t.Cleanup(Insecure())
+			}
+			got, err := GenerateRSAKeyWithExponent(tt.args.bits, tt.args.exponent)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateRSAKeyWithExponent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("GenerateRSAKeyWithExponent() = %v, want nil", got)
+				}
+				return
+			}
+			k, ok := got.(*rsa.PrivateKey)
+			if !ok {
+				t.Fatalf("GenerateRSAKeyWithExponent() = %T, want *rsa.PrivateKey", got)
+			}
+			if k.E != tt.wantE {
+				t.Errorf("GenerateRSAKeyWithExponent() exponent = %d, want %d", k.E, tt.wantE)
+			}
+			if k.N.BitLen() != tt.args.bits {
+				t.Errorf("GenerateRSAKeyWithExponent() size = %d, want %d", k.N.BitLen(), tt.args.bits)
+			}
+			if err := k.Validate(); err != nil {
+				t.Errorf("rsa.PrivateKey.Validate() error = %v", err)
+			}
+			digest := sha256.Sum256([]byte("message"))
+			sig, err := k.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatalf("rsa.PrivateKey.Sign() error = %v", err)
+			}
+			if err := rsa.VerifyPKCS1v15(&k.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+				t.Errorf("rsa.VerifyPKCS1v15() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestGenerateKeyPair(t *testing.T) {
 	cleanupRandReader(t)
 
@@ -539,6 +605,7 @@ func TestGenerateSigner(t *testing.T) {
 		{"P-256", args{"EC", "P-256", 0}, assertSigner(crypto.SHA256), false},
 		{"P-384", args{"EC", "P-384", 0}, assertSigner(crypto.SHA384), false},
 		{"P-521", args{"EC", "P-521", 0}, assertSigner(crypto.SHA512), false},
+		{"secp256k1", args{"EC", "secp256k1", 0}, assertSigner(crypto.SHA256), false},
 		{"Ed25519", args{"OKP", "Ed25519", 0}, assertSigner(crypto.Hash(0)), false},
 		{"OCT", args{"oct", "", 32}, assertNil(), true},
 		{"unknown", args{"EC", "P-128", 0}, assertNil(), true},
@@ -768,3 +835,80 @@ func TestEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestPublicKeysEqual(t *testing.T) {
+	mustPublicKey := func(kty, crv string, size int) crypto.PublicKey {
+		s, err := GenerateSigner(kty, crv, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Public()
+	}
+	mustCopy := func(pub crypto.PublicKey) crypto.PublicKey {
+		if x, ok := pub.(x25519.PublicKey); ok {
+			return x25519.PublicKey(append([]byte{}, x...))
+		}
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := x509.ParsePKIXPublicKey(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	ecdsaKey := mustPublicKey("EC", "P-256", 0).(*ecdsa.PublicKey)
+	rsaKey := mustPublicKey("RSA", "", 2048).(*rsa.PublicKey)
+	ed25519Key := mustPublicKey("OKP", "Ed25519", 0)
+	x25519Key := mustPublicKey("OKP", "X25519", 0)
+	ecdsaPrivateKey, err := GenerateSigner("EC", "P-256", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		a crypto.PublicKey
+		b crypto.PublicKey
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"ok ecdsaKey", args{ecdsaKey, mustCopy(ecdsaKey)}, true},
+		{"ok rsaKey", args{rsaKey, mustCopy(rsaKey)}, true},
+		{"ok ed25519Key", args{ed25519Key, mustCopy(ed25519Key)}, true},
+		{"ok x25519Key", args{x25519Key, mustCopy(x25519Key)}, true},
+		{"ok ecdsaKey value", args{*ecdsaKey, mustCopy(ecdsaKey)}, true},
+		{"ok rsaKey value", args{rsaKey, *mustCopy(rsaKey).(*rsa.PublicKey)}, true},
+		{"fail ecdsaKey", args{ecdsaKey, mustPublicKey("EC", "P-256", 0)}, false},
+		{"fail ecdsaKey curve", args{ecdsaKey, mustPublicKey("EC", "P-384", 0)}, false},
+		{"fail rsaKey", args{rsaKey, mustPublicKey("RSA", "", 2048)}, false},
+		{"fail ed25519Key", args{ed25519Key, mustPublicKey("OKP", "Ed25519", 0)}, false},
+		{"fail x25519Key", args{x25519Key, mustPublicKey("OKP", "X25519", 0)}, false},
+		{"fail ecdsaKey rsaKey", args{ecdsaKey, rsaKey}, false},
+		{"fail rsaKey ed25519Key", args{rsaKey, ed25519Key}, false},
+		{"fail ed25519Key x25519Key", args{ed25519Key, x25519.PublicKey(ed25519Key.(ed25519.PublicKey))}, false},
+		{"fail private key", args{ecdsaPrivateKey.Public(), ecdsaPrivateKey}, false},
+		{"fail nil", args{nil, nil}, false},
+		{"fail nil ecdsaKey", args{ecdsaKey, (*ecdsa.PublicKey)(nil)}, false},
+		{"fail nil rsaKey", args{(*rsa.PublicKey)(nil), rsaKey}, false},
+		{"fail empty ecdsaKey", args{&ecdsa.PublicKey{}, &ecdsa.PublicKey{}}, false},
+		{"fail empty rsaKey", args{&rsa.PublicKey{}, &rsa.PublicKey{}}, false},
+		{"fail empty ed25519Key", args{ed25519.PublicKey{}, ed25519.PublicKey{}}, false},
+		{"fail empty x25519Key", args{x25519.PublicKey{}, x25519.PublicKey{}}, false},
+		{"fail []byte", args{[]byte{1, 2, 3}, []byte{1, 2, 3}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PublicKeysEqual(tt.args.a, tt.args.b); got != tt.want {
+				t.Errorf("PublicKeysEqual() = %v, want %v", got, tt.want)
+			}
+			if got := PublicKeysEqual(tt.args.b, tt.args.a); got != tt.want {
+				t.Errorf("PublicKeysEqual() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package keyutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// KeyType is the type of key generated by GenerateFromSeed.
+type KeyType string
+
+const (
+	// Ed25519KeyType is an Ed25519 key.
+	Ed25519KeyType KeyType = "Ed25519"
+	// RSA2048KeyType is a 2048-bit RSA key.
+	RSA2048KeyType KeyType = "RSA-2048"
+	// RSA3072KeyType is a 3072-bit RSA key.
+	RSA3072KeyType KeyType = "RSA-3072"
+	// RSA4096KeyType is a 4096-bit RSA key.
+	RSA4096KeyType KeyType = "RSA-4096"
+)
+
+// GenerateFromSeed deterministically generates a key of the given kind from a
+// seed, e.g. a passphrase. The same seed and kind always return the same key,
+// so it can be used to create test fixtures without committing key files.
+//
+// The keys are as secret as the seed. GenerateFromSeed is meant for testing
+// only, and it must not be used to generate production keys.
+//
+// Ed25519 keys use the first 32 bytes read from a deterministic random bit
+// generator (DRBG) seeded with the seed as the RFC 8032 private key seed. RSA
+// keys use the public exponent 65537 and two primes found using the DRBG.
+func GenerateFromSeed(seed []byte, kind KeyType) (crypto.Signer, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("error generating key: seed cannot be empty")
+	}
+
+	r, err := newSeedReader(seed, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case Ed25519KeyType:
+		b := make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, errors.Wrap(err, "error generating Ed25519 key")
+		}
+		return ed25519.NewKeyFromSeed(b), nil
+	case RSA2048KeyType:
+		return generateSeededRSAKey(r, 2048)
+	case RSA3072KeyType:
+		return generateSeededRSAKey(r, 3072)
+	case RSA4096KeyType:
+		return generateSeededRSAKey(r, 4096)
+	default:
+		return nil, errors.Errorf("error generating key: unsupported key type %q", kind)
+	}
+}
+
+func generateSeededRSAKey(r io.Reader, bits int) (crypto.Signer, error) {
+	key, err := generateRSAKeyWithPrimes(bits, DefaultRSAPublicExponent, func(bits int) (*big.Int, error) {
+		return seededPrime(r, bits)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating RSA key")
+	}
+	return key, nil
+}
+
+// seededPrime returns the first prime of the given bit length starting at a
+// number read from r. Unlike rand.Prime, the result only depends on the bytes
+// read from r.
+func seededPrime(r io.Reader, bits int) (*big.Int, error) {
+	b := make([]byte, (bits+7)/8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+
+	// Clear the bits above the bit length, and set the two most significant
+	// bits, so the product of two primes has exactly twice the bit length,
+	// and the least significant bit to start with an odd number.
+	if excess := len(b)*8 - bits; excess > 0 {
+		b[0] &= byte(0xff >> excess)
+	}
+	p := new(big.Int).SetBytes(b)
+	p.SetBit(p, bits-1, 1)
+	p.SetBit(p, bits-2, 1)
+	p.SetBit(p, 0, 1)
+
+	two := big.NewInt(2)
+	for ; p.BitLen() == bits; p.Add(p, two) {
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+	// Start again if the search overflows the bit length.
+	return seededPrime(r, bits)
+}
+
+// seedReader is a deterministic random bit generator that returns the
+// SHA-256 hash of a key derived from the seed and a counter.
+type seedReader struct {
+	key     []byte
+	counter uint64
+	buf     []byte
+}
+
+func newSeedReader(seed []byte, kind KeyType) (*seedReader, error) {
+	key, err := DeriveHKDF(seed, nil, []byte("keyutil.GenerateFromSeed "+string(kind)), sha256.Size, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &seedReader{key: key}, nil
+}
+
+func (r *seedReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if len(r.buf) == 0 {
+			var counter [8]byte
+			binary.BigEndian.PutUint64(counter[:], r.counter)
+			r.counter++
+			h := sha256.New()
+			h.Write(r.key)
+			h.Write(counter[:])
+			r.buf = h.Sum(nil)
+		}
+		m := copy(b[n:], r.buf)
+		r.buf = r.buf[m:]
+		n += m
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package keyutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenerateFromSeed(t *testing.T) {
+	seed := []byte("the test passphrase")
+	otherSeed := []byte("another test passphrase")
+
+	tests := []struct {
+		name     string
+		kind     KeyType
+		wantType crypto.Signer
+		wantBits int
+	}{
+		{"Ed25519", Ed25519KeyType, ed25519.PrivateKey{}, 0},
+		{"RSA-2048", RSA2048KeyType, &rsa.PrivateKey{}, 2048},
+		{"RSA-3072", RSA3072KeyType, &rsa.PrivateKey{}, 3072},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := GenerateFromSeed(seed, tt.kind)
+			if err != nil {
+				t.Fatalf("GenerateFromSeed() error = %v", err)
+			}
+			sameKey, err := GenerateFromSeed(seed, tt.kind)
+			if err != nil {
+				t.Fatalf("GenerateFromSeed() error = %v", err)
+			}
+			otherKey, err := GenerateFromSeed(otherSeed, tt.kind)
+			if err != nil {
+				t.Fatalf("GenerateFromSeed() error = %v", err)
+			}
+
+			if !Equal(key, sameKey) {
+				t.Error("GenerateFromSeed() returned different keys for the same seed")
+			}
+			if Equal(key, otherKey) {
+				t.Error("GenerateFromSeed() returned the same key for different seeds")
+			}
+
+			switch k := key.(type) {
+			case ed25519.PrivateKey:
+				if _, ok := tt.wantType.(ed25519.PrivateKey); !ok {
+					t.Errorf("GenerateFromSeed() = %T, want %T", key, tt.wantType)
+				}
+			case *rsa.PrivateKey:
+				if _, ok := tt.wantType.(*rsa.PrivateKey); !ok {
+					t.Errorf("GenerateFromSeed() = %T, want %T", key, tt.wantType)
+				}
+				if err := k.Validate(); err != nil {
+					t.Errorf("rsa.PrivateKey.Validate() error = %v", err)
+				}
+				if k.N.BitLen() != tt.wantBits || k.E != 65537 {
+					t.Errorf("GenerateFromSeed() bits = %d, exponent = %d, want %d and 65537", k.N.BitLen(), k.E, tt.wantBits)
+				}
+			default:
+				t.Errorf("GenerateFromSeed() = %T, want %T", key, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestGenerateFromSeed_golden(t *testing.T) {
+	// The keys must not change between releases.
+	key, err := GenerateFromSeed([]byte("the test passphrase"), Ed25519KeyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ca6644696e42118dcec6f7e2e9a3f5a84a8d4d2c739326ef725ef6e8f47f1d71"
+	if got := hex.EncodeToString(key.Public().(ed25519.PublicKey)); got != want {
+		t.Errorf("GenerateFromSeed() public key = %s, want %s", got, want)
+	}
+
+	key, err = GenerateFromSeed([]byte("the test passphrase"), RSA2048KeyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(key.(*rsa.PrivateKey).N.Bytes())
+	want = "49056ee5011c371f9d5a0008a22828c11c550e1a30c74c6d3efc5922f5c9b69d"
+	if got := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("GenerateFromSeed() SHA-256 of the modulus = %s, want %s", got, want)
+	}
+}
+
+func TestGenerateFromSeed_kinds(t *testing.T) {
+	seed := []byte("the test passphrase")
+	rsaKey, err := GenerateFromSeed(seed, RSA2048KeyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := GenerateFromSeed(seed, RSA3072KeyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each kind uses a different DRBG, the primes are not shared.
+	p := rsaKey.(*rsa.PrivateKey).Primes
+	for _, q := range otherRSAKey.(*rsa.PrivateKey).Primes {
+		if q.Cmp(p[0]) == 0 || q.Cmp(p[1]) == 0 {
+			t.Error("GenerateFromSeed() returned keys with the same primes for different kinds")
+		}
+	}
+}
+
+func TestGenerateFromSeed_fail(t *testing.T) {
+	tests := []struct {
+		name string
+		seed []byte
+		kind KeyType
+	}{
+		{"fail empty seed", nil, Ed25519KeyType},
+		{"fail unsupported kind", []byte("the test passphrase"), KeyType("P-256")},
+		{"fail empty kind", []byte("the test passphrase"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GenerateFromSeed(tt.seed, tt.kind); err == nil {
+				t.Error("GenerateFromSeed() error = nil, want error")
+			}
+		})
+	}
+}
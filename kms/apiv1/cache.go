@@ -0,0 +1,369 @@
+package apiv1
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.step.sm/crypto/kms/uri"
+)
+
+// PublicKeyCache is a KeyManager that stores in a directory the public keys
+// returned by another KeyManager, and returns them in the following
+// GetPublicKey calls without calling the KeyManager, even after a process
+// restart. The cache is opt-in, kms.New enables it if the KMS URI has the
+// "cache-dir" parameter, e.g. "azurekms:vault=my-vault;cache-dir=/var/cache",
+// and any KeyManager can be wrapped with NewPublicKeyCache.
+//
+// Only key names with a version, the "version" parameter of the key URI, are
+// cached, because the public key of a version never changes. The public key of
+// a name without a version changes when the key is rotated, so these names are
+// always forwarded to the KeyManager. CreateKey and RotateKey cache the new key
+// if the KeyManager returns a name with the version.
+//
+// The rest of operations, including the ones in the optional interfaces, are
+// forwarded to the KeyManager. The optional operations return a
+// NotImplementedError if the KeyManager does not implement them.
+type PublicKeyCache struct {
+	km  KeyManager
+	dir string
+}
+
+// NewPublicKeyCache creates a new PublicKeyCache that stores the public keys
+// returned by the given KeyManager in dir. The directory is created if it does
+// not exist.
+func NewPublicKeyCache(km KeyManager, dir string) (*PublicKeyCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+	return &PublicKeyCache{
+		km:  km,
+		dir: dir,
+	}, nil
+}
+
+// GetPublicKey returns the public key in the cache, or gets it from the
+// KeyManager and adds it to the cache if it is not there. Errors writing the
+// cache are ignored, the public key is still returned.
+func (c *PublicKeyCache) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if pub, ok := c.load(req.Name); ok {
+		return pub, nil
+	}
+	pub, err := c.km.GetPublicKey(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.store(req.Name, pub)
+	return pub, nil
+}
+
+// GetPublicKeyContext is like GetPublicKey, but it uses the given context if
+// the KeyManager implements the ContextKeyManager interface.
+func (c *PublicKeyCache) GetPublicKeyContext(ctx context.Context, req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	ckm, ok := c.km.(ContextKeyManager)
+	if !ok {
+		return c.GetPublicKey(req)
+	}
+	if pub, ok := c.load(req.Name); ok {
+		return pub, nil
+	}
+	pub, err := ckm.GetPublicKeyContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.store(req.Name, pub)
+	return pub, nil
+}
+
+// CreateKey creates a new key using the KeyManager and adds its public key to
+// the cache.
+func (c *PublicKeyCache) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	return c.storeResponse(c.km.CreateKey(req))
+}
+
+// CreateKeyContext is like CreateKey, but it uses the given context if the
+// KeyManager implements the ContextKeyManager interface.
+func (c *PublicKeyCache) CreateKeyContext(ctx context.Context, req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	if ckm, ok := c.km.(ContextKeyManager); ok {
+		return c.storeResponse(ckm.CreateKeyContext(ctx, req))
+	}
+	return c.CreateKey(req)
+}
+
+// RotateKey rotates the key with the given name using the KeyManager, see
+// RotateKey, and adds the public key of the new key to the cache.
+func (c *PublicKeyCache) RotateKey(name string) (*CreateKeyResponse, error) {
+	return c.storeResponse(RotateKey(c.km, name))
+}
+
+// ValidateCreateKey validates a CreateKeyRequest using the KeyManager. It
+// returns a NotImplementedError if the KeyManager does not implement the
+// CreateKeyValidator interface.
+func (c *PublicKeyCache) ValidateCreateKey(req *CreateKeyRequest) error {
+	v, ok := c.km.(CreateKeyValidator)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement ValidateCreateKey", c.km)}
+	}
+	return v.ValidateCreateKey(req)
+}
+
+// CreateSigner creates a crypto.Signer using the KeyManager.
+func (c *PublicKeyCache) CreateSigner(req *CreateSignerRequest) (crypto.Signer, error) {
+	return c.km.CreateSigner(req)
+}
+
+// CreateSignerContext is like CreateSigner, but it uses the given context if
+// the KeyManager implements the ContextKeyManager interface.
+func (c *PublicKeyCache) CreateSignerContext(ctx context.Context, req *CreateSignerRequest) (crypto.Signer, error) {
+	if ckm, ok := c.km.(ContextKeyManager); ok {
+		return ckm.CreateSignerContext(ctx, req)
+	}
+	return c.km.CreateSigner(req)
+}
+
+// CreateDecrypter creates a crypto.Decrypter using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the Decrypter
+// interface.
+func (c *PublicKeyCache) CreateDecrypter(req *CreateDecrypterRequest) (crypto.Decrypter, error) {
+	d, ok := c.km.(Decrypter)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement CreateDecrypter", c.km)}
+	}
+	return d.CreateDecrypter(req)
+}
+
+// LoadCertificate loads a certificate using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the
+// CertificateManager interface.
+func (c *PublicKeyCache) LoadCertificate(req *LoadCertificateRequest) (*x509.Certificate, error) {
+	cm, ok := c.km.(CertificateManager)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement LoadCertificate", c.km)}
+	}
+	return cm.LoadCertificate(req)
+}
+
+// StoreCertificate stores a certificate using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the
+// CertificateManager interface.
+func (c *PublicKeyCache) StoreCertificate(req *StoreCertificateRequest) error {
+	cm, ok := c.km.(CertificateManager)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement StoreCertificate", c.km)}
+	}
+	return cm.StoreCertificate(req)
+}
+
+// CreateAttestation creates an attestation using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the Attester
+// interface.
+func (c *PublicKeyCache) CreateAttestation(req *CreateAttestationRequest) (*CreateAttestationResponse, error) {
+	a, ok := c.km.(Attester)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement CreateAttestation", c.km)}
+	}
+	return a.CreateAttestation(req)
+}
+
+// Verify verifies a signature using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the Verifier
+// interface.
+func (c *PublicKeyCache) Verify(name string, message, signature []byte, alg SignatureAlgorithm) (bool, error) {
+	v, ok := c.km.(Verifier)
+	if !ok {
+		return false, NotImplementedError{Message: fmt.Sprintf("%T does not implement Verify", c.km)}
+	}
+	return v.Verify(name, message, signature, alg)
+}
+
+// WrapKey wraps a key using the KeyManager. It returns a NotImplementedError if
+// the KeyManager does not implement the KeyWrapper interface.
+func (c *PublicKeyCache) WrapKey(req *WrapKeyRequest) (*WrapKeyResponse, error) {
+	w, ok := c.km.(KeyWrapper)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement WrapKey", c.km)}
+	}
+	return w.WrapKey(req)
+}
+
+// UnwrapKey unwraps a key using the KeyManager. It returns a
+// NotImplementedError if the KeyManager does not implement the KeyWrapper
+// interface.
+func (c *PublicKeyCache) UnwrapKey(req *UnwrapKeyRequest) (*UnwrapKeyResponse, error) {
+	w, ok := c.km.(KeyWrapper)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement UnwrapKey", c.km)}
+	}
+	return w.UnwrapKey(req)
+}
+
+// GetRotationPolicy returns the rotation policy of a key using the KeyManager.
+// It returns a NotImplementedError if the KeyManager does not implement the
+// RotationPolicyManager interface.
+func (c *PublicKeyCache) GetRotationPolicy(name string) (*RotationPolicy, error) {
+	m, ok := c.km.(RotationPolicyManager)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement GetRotationPolicy", c.km)}
+	}
+	return m.GetRotationPolicy(name)
+}
+
+// SetRotationPolicy sets the rotation policy of a key using the KeyManager. It
+// returns a NotImplementedError if the KeyManager does not implement the
+// RotationPolicyManager interface.
+func (c *PublicKeyCache) SetRotationPolicy(name string, policy *RotationPolicy) error {
+	m, ok := c.km.(RotationPolicyManager)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement SetRotationPolicy", c.km)}
+	}
+	return m.SetRotationPolicy(name, policy)
+}
+
+// ValidateName validates a key name using the KeyManager. Names are always
+// valid if the KeyManager does not implement the NameValidator interface.
+func (c *PublicKeyCache) ValidateName(s string) error {
+	if v, ok := c.km.(NameValidator); ok {
+		return v.ValidateName(s)
+	}
+	return nil
+}
+
+// ValidateNameExists checks that a key exists using the KeyManager. It returns
+// a NotImplementedError if the KeyManager does not implement the
+// ExistingNameValidator interface.
+func (c *PublicKeyCache) ValidateNameExists(ctx context.Context, name string) error {
+	v, ok := c.km.(ExistingNameValidator)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement ValidateNameExists", c.km)}
+	}
+	return v.ValidateNameExists(ctx, name)
+}
+
+// Ping checks that the KeyManager is available, see Ping.
+func (c *PublicKeyCache) Ping(ctx context.Context) error {
+	return Ping(ctx, c.km)
+}
+
+// SupportedAlgorithms returns the signature algorithms supported by the
+// KeyManager. It returns nil if the KeyManager does not implement the
+// SupportedAlgorithmsReporter interface.
+func (c *PublicKeyCache) SupportedAlgorithms() []SupportedAlgorithm {
+	algs, _ := SupportedAlgorithms(c.km)
+	return algs
+}
+
+// Capabilities returns the capabilities of the KeyManager.
+func (c *PublicKeyCache) Capabilities() Capabilities {
+	return GetCapabilities(c.km)
+}
+
+// Close closes the KeyManager. The cache is kept in the directory.
+func (c *PublicKeyCache) Close() error {
+	return c.km.Close()
+}
+
+// storeResponse adds the public key in the response of CreateKey or RotateKey
+// to the cache.
+func (c *PublicKeyCache) storeResponse(resp *CreateKeyResponse, err error) (*CreateKeyResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.Name != "" && resp.PublicKey != nil {
+		_ = c.store(resp.Name, resp.PublicKey)
+	}
+	return resp, nil
+}
+
+// load returns the public key in the cache for the given key name. Names
+// without a version are never in the cache.
+func (c *PublicKeyCache) load(name string) (crypto.PublicKey, bool) {
+	base, version := splitKeyVersion(name)
+	if version == "" {
+		return nil, false
+	}
+	b, err := os.ReadFile(c.filename(base, version))
+	if err != nil {
+		return nil, false
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" || block.Headers["Name"] != base || block.Headers["Version"] != version {
+		return nil, false
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, false
+	}
+	return pub, true
+}
+
+// store adds the public key to the cache if the given key name has a version.
+func (c *PublicKeyCache) store(name string, pub crypto.PublicKey) error {
+	base, version := splitKeyVersion(name)
+	if version == "" {
+		return nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	b := pem.EncodeToMemory(&pem.Block{
+		Type: "PUBLIC KEY",
+		Headers: map[string]string{
+			"Name":    base,
+			"Version": version,
+		},
+		Bytes: der,
+	})
+
+	// Write to a temporary file first, so a concurrent load never reads a
+	// partial entry.
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.filename(base, version))
+}
+
+func (c *PublicKeyCache) filename(base, version string) string {
+	sum := sha256.Sum256([]byte(base + "\x00" + version))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".pem")
+}
+
+// splitKeyVersion returns the key name without the "version" parameter, with
+// the attributes in order, and the value of the version. Names that are not
+// URIs, like file paths, are returned unchanged.
+func splitKeyVersion(name string) (string, string) {
+	u, err := uri.Parse(name)
+	if err != nil || len(u.Scheme) < 2 {
+		return name, ""
+	}
+	version := u.Get("version")
+	u.Values.Del("version")
+	query := u.URL.Query()
+	query.Del("version")
+	base := &url.URL{
+		Scheme:   u.Scheme,
+		Opaque:   strings.ReplaceAll(u.Values.Encode(), "&", ";"),
+		RawQuery: query.Encode(),
+	}
+	return base.String(), version
+}
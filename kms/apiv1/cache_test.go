@@ -0,0 +1,409 @@
+package apiv1
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// countingKeyManager is a KeyManager with key versions. Names without a
+// version refer to the latest version of the key, and CreateKey adds a new
+// version.
+type countingKeyManager struct {
+	keys  map[string][]crypto.Signer
+	calls int
+}
+
+func (k *countingKeyManager) key(name string) (crypto.Signer, error) {
+	base, version := splitKeyVersion(name)
+	if len(k.keys[base]) == 0 {
+		if _, err := k.newVersion(base); err != nil {
+			return nil, err
+		}
+	}
+	versions := k.keys[base]
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	i, err := strconv.Atoi(version)
+	if err != nil || i < 1 || i > len(versions) {
+		return nil, NotFoundError{}
+	}
+	return versions[i-1], nil
+}
+
+func (k *countingKeyManager) newVersion(base string) (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	k.keys[base] = append(k.keys[base], key)
+	return base + "?version=" + strconv.Itoa(len(k.keys[base])), nil
+}
+
+func (k *countingKeyManager) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	k.calls++
+	if req.Name == "fail" {
+		return nil, NotFoundError{}
+	}
+	key, err := k.key(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public(), nil
+}
+
+func (k *countingKeyManager) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	if req.Name == "fail" {
+		return nil, AlreadyExistsError{}
+	}
+	base, _ := splitKeyVersion(req.Name)
+	name, err := k.newVersion(base)
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.key(name)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateKeyResponse{Name: name, PublicKey: key.Public()}, nil
+}
+
+func (k *countingKeyManager) CreateSigner(req *CreateSignerRequest) (crypto.Signer, error) {
+	return k.key(req.SigningKey)
+}
+
+func (k *countingKeyManager) Close() error {
+	return nil
+}
+
+func newCountingKeyManager() *countingKeyManager {
+	return &countingKeyManager{keys: make(map[string][]crypto.Signer)}
+}
+
+func TestNewPublicKeyCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("not a directory"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	km := newCountingKeyManager()
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{"ok", dir, false},
+		{"ok new directory", filepath.Join(dir, "a", "b"), false},
+		{"fail empty", "", true},
+		{"fail not a directory", filepath.Join(file, "cache"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPublicKeyCache(km, tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPublicKeyCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublicKeyCache_GetPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	km := newCountingKeyManager()
+	c, err := NewPublicKeyCache(km, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(c *PublicKeyCache, name string, wantCalls int) crypto.PublicKey {
+		t.Helper()
+		pub, err := c.GetPublicKey(&GetPublicKeyRequest{Name: name})
+		if err != nil {
+			t.Fatalf("PublicKeyCache.GetPublicKey() error = %v", err)
+		}
+		if km.calls != wantCalls {
+			t.Fatalf("PublicKeyCache.GetPublicKey() KeyManager calls = %d, want %d", km.calls, wantCalls)
+		}
+		return pub
+	}
+
+	// Miss and hit.
+	pub := get(c, "kms:name=my-key?version=1", 1)
+	if got := get(c, "kms:name=my-key?version=1", 1); !reflect.DeepEqual(got, pub) {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, want %v", got, pub)
+	}
+
+	// Hit after a restart.
+	c2, err := NewPublicKeyCache(km, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c2, "kms:name=my-key?version=1", 1); !reflect.DeepEqual(got, pub) {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, want %v", got, pub)
+	}
+
+	// Names without a version and paths are not cached.
+	get(c, "kms:name=my-key", 2)
+	get(c, "kms:name=my-key", 3)
+	get(c, filepath.Join("path", "to", "key.pem"), 4)
+	get(c, filepath.Join("path", "to", "key.pem"), 5)
+
+	// Errors are not cached.
+	if _, err := c.GetPublicKey(&GetPublicKeyRequest{Name: "fail"}); err == nil {
+		t.Error("PublicKeyCache.GetPublicKey() error = nil, want error")
+	}
+	if _, err := c.GetPublicKey(&GetPublicKeyRequest{Name: "fail"}); err == nil {
+		t.Error("PublicKeyCache.GetPublicKey() error = nil, want error")
+	}
+	if km.calls != 7 {
+		t.Errorf("PublicKeyCache.GetPublicKey() KeyManager calls = %d, want 7", km.calls)
+	}
+}
+
+func TestPublicKeyCache_GetPublicKey_version(t *testing.T) {
+	km := newCountingKeyManager()
+	c, err := NewPublicKeyCache(km, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := km.newVersion("kms:name=my-key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := km.newVersion("kms:name=my-key"); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(name string) crypto.PublicKey {
+		t.Helper()
+		pub, err := c.GetPublicKey(&GetPublicKeyRequest{Name: name})
+		if err != nil {
+			t.Fatalf("PublicKeyCache.GetPublicKey() error = %v", err)
+		}
+		return pub
+	}
+
+	v1 := get("kms:name=my-key?version=1")
+	if get("kms:name=my-key?version=1"); km.calls != 1 {
+		t.Errorf("PublicKeyCache.GetPublicKey() KeyManager calls = %d, want 1", km.calls)
+	}
+	// The attributes are sorted and the version can be an attribute.
+	if got := get("kms:version=1;name=my-key"); !reflect.DeepEqual(got, v1) || km.calls != 1 {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, calls = %d, want %v, calls = 1", got, km.calls, v1)
+	}
+
+	// Each version has its own entry.
+	v2 := get("kms:name=my-key?version=2")
+	if km.calls != 2 {
+		t.Errorf("PublicKeyCache.GetPublicKey() KeyManager calls = %d, want 2", km.calls)
+	}
+	if reflect.DeepEqual(v1, v2) {
+		t.Error("PublicKeyCache.GetPublicKey() returned the public key of the old version")
+	}
+	if got := get("kms:name=my-key?version=2"); !reflect.DeepEqual(got, v2) || km.calls != 2 {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, calls = %d, want %v, calls = 2", got, km.calls, v2)
+	}
+	if got := get("kms:name=my-key?version=1"); !reflect.DeepEqual(got, v1) || km.calls != 2 {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, calls = %d, want %v, calls = 2", got, km.calls, v1)
+	}
+}
+
+func TestPublicKeyCache_CreateKey(t *testing.T) {
+	km := newCountingKeyManager()
+	c, err := NewPublicKeyCache(km, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.CreateKey(&CreateKeyRequest{Name: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "kms:name=my-key?version=1" {
+		t.Fatalf("PublicKeyCache.CreateKey() name = %s, want kms:name=my-key?version=1", resp.Name)
+	}
+	pub, err := c.GetPublicKey(&GetPublicKeyRequest{Name: resp.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub, resp.PublicKey) || km.calls != 0 {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, calls = %d, want %v, calls = 0", pub, km.calls, resp.PublicKey)
+	}
+
+	resp, err = c.CreateKeyContext(context.Background(), &CreateKeyRequest{Name: "kms:name=other-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err = c.GetPublicKeyContext(context.Background(), &GetPublicKeyRequest{Name: resp.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub, resp.PublicKey) || km.calls != 0 {
+		t.Errorf("PublicKeyCache.GetPublicKeyContext() = %v, calls = %d, want %v, calls = 0", pub, km.calls, resp.PublicKey)
+	}
+
+	if _, err := c.CreateKey(&CreateKeyRequest{Name: "fail"}); err == nil {
+		t.Error("PublicKeyCache.CreateKey() error = nil, want error")
+	}
+}
+
+func TestPublicKeyCache_RotateKey(t *testing.T) {
+	km := newCountingKeyManager()
+	c, err := NewPublicKeyCache(km, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := c.CreateKey(&CreateKeyRequest{Name: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := c.GetPublicKey(&GetPublicKeyRequest{Name: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub, v1.PublicKey) {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, want %v", pub, v1.PublicKey)
+	}
+
+	v2, err := c.RotateKey("kms:name=my-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Name != "kms:name=my-key?version=2" || reflect.DeepEqual(v1.PublicKey, v2.PublicKey) {
+		t.Fatalf("PublicKeyCache.RotateKey() = %v, want a new version", v2)
+	}
+
+	// The unversioned name returns the new key after the rotation.
+	pub, err = c.GetPublicKey(&GetPublicKeyRequest{Name: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub, v2.PublicKey) {
+		t.Errorf("PublicKeyCache.GetPublicKey() = %v, want %v", pub, v2.PublicKey)
+	}
+
+	// Both versions are cached.
+	calls := km.calls
+	for _, v := range []*CreateKeyResponse{v1, v2} {
+		pub, err := c.GetPublicKey(&GetPublicKeyRequest{Name: v.Name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pub, v.PublicKey) {
+			t.Errorf("PublicKeyCache.GetPublicKey() = %v, want %v", pub, v.PublicKey)
+		}
+	}
+	if km.calls != calls {
+		t.Errorf("PublicKeyCache.GetPublicKey() KeyManager calls = %d, want %d", km.calls, calls)
+	}
+}
+
+func TestPublicKeyCache_forward(t *testing.T) {
+	km := newCountingKeyManager()
+	c, err := NewPublicKeyCache(km, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := c.CreateSigner(&CreateSignerRequest{SigningKey: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(signer, km.keys["kms:name=my-key"][0]) {
+		t.Errorf("PublicKeyCache.CreateSigner() = %v, want %v", signer, km.keys["kms:name=my-key"][0])
+	}
+	signer, err = c.CreateSignerContext(context.Background(), &CreateSignerRequest{SigningKey: "kms:name=my-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(signer, km.keys["kms:name=my-key"][0]) {
+		t.Errorf("PublicKeyCache.CreateSignerContext() = %v, want %v", signer, km.keys["kms:name=my-key"][0])
+	}
+	if err := c.ValidateName("kms:name=my-key"); err != nil {
+		t.Errorf("PublicKeyCache.ValidateName() error = %v", err)
+	}
+	if got := c.SupportedAlgorithms(); got != nil {
+		t.Errorf("PublicKeyCache.SupportedAlgorithms() = %v, want nil", got)
+	}
+	if got := c.Capabilities(); !reflect.DeepEqual(got, DefaultCapabilities) {
+		t.Errorf("PublicKeyCache.Capabilities() = %v, want %v", got, DefaultCapabilities)
+	}
+
+	var nerr NotImplementedError
+	if err := c.ValidateCreateKey(&CreateKeyRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.ValidateCreateKey() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.CreateDecrypter(&CreateDecrypterRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.CreateDecrypter() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.LoadCertificate(&LoadCertificateRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.LoadCertificate() error = %v, want NotImplementedError", err)
+	}
+	if err := c.StoreCertificate(&StoreCertificateRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.StoreCertificate() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.CreateAttestation(&CreateAttestationRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.CreateAttestation() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.Verify("kms:name=my-key", nil, nil, ECDSAWithSHA256); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.Verify() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.WrapKey(&WrapKeyRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.WrapKey() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.UnwrapKey(&UnwrapKeyRequest{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.UnwrapKey() error = %v, want NotImplementedError", err)
+	}
+	if _, err := c.GetRotationPolicy("kms:name=my-key"); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.GetRotationPolicy() error = %v, want NotImplementedError", err)
+	}
+	if err := c.SetRotationPolicy("kms:name=my-key", &RotationPolicy{}); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.SetRotationPolicy() error = %v, want NotImplementedError", err)
+	}
+	if err := c.ValidateNameExists(context.Background(), "kms:name=my-key"); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.ValidateNameExists() error = %v, want NotImplementedError", err)
+	}
+	if err := c.Ping(context.Background()); !errors.As(err, &nerr) {
+		t.Errorf("PublicKeyCache.Ping() error = %v, want NotImplementedError", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("PublicKeyCache.Close() error = %v", err)
+	}
+}
+
+func Test_splitKeyVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyName     string
+		wantBase    string
+		wantVersion string
+	}{
+		{"no version", "kms:name=my-key;vault=my-vault", "kms:name=my-key;vault=my-vault", ""},
+		{"query", "kms:vault=my-vault;name=my-key?version=1", "kms:name=my-key;vault=my-vault", "1"},
+		{"attribute", "kms:version=1;name=my-key", "kms:name=my-key", "1"},
+		{"other query", "kms:name=my-key?version=1&hsm=true", "kms:name=my-key?hsm=true", "1"},
+		{"path", "/path/to/key.pem", "/path/to/key.pem", ""},
+		{"windows path", `C:\path\to\key.pem`, `C:\path\to\key.pem`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBase, gotVersion := splitKeyVersion(tt.keyName)
+			if gotBase != tt.wantBase {
+				t.Errorf("splitKeyVersion() base = %v, want %v", gotBase, tt.wantBase)
+			}
+			if gotVersion != tt.wantVersion {
+				t.Errorf("splitKeyVersion() version = %v, want %v", gotVersion, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package apiv1
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// MultiKeyManager is a KeyManager that forwards each operation to the
+// KeyManager of the type given by the scheme of the key name, e.g. a
+// CreateSigner request with the signing key "awskms:key-id=..." is forwarded
+// to the AmazonKMS. The KeyManagers are created on first use with the
+// registered KeyManagerNewFunc and cached for the following operations, so the
+// packages implementing them must be imported.
+//
+// Key names without a scheme, like the paths used by the SoftKMS, are
+// forwarded to the default type, SoftKMS if no other is configured.
+type MultiKeyManager struct {
+	ctx         context.Context
+	defaultType Type
+	options     map[Type]Options
+	mu          sync.Mutex
+	managers    map[managerKey]KeyManager
+	closed      bool
+}
+
+// managerKey identifies the KeyManagers created by a MultiKeyManager.
+type managerKey struct {
+	typ Type
+	uri string
+}
+
+// MultiKeyManagerOption is the type of the options passed to
+// NewMultiKeyManager.
+type MultiKeyManagerOption func(m *MultiKeyManager)
+
+// WithKeyManagerOptions sets the options used to create the KeyManager of the
+// type defined in opts. The KeyManagers of the types without options are
+// created with only the Type set.
+func WithKeyManagerOptions(opts Options) MultiKeyManagerOption {
+	return func(m *MultiKeyManager) {
+		if typ, err := opts.GetType(); err == nil {
+			opts.Type = Type(strings.ToLower(string(typ)))
+			m.options[opts.Type] = opts
+		}
+	}
+}
+
+// WithDefaultType sets the type of the KeyManager used with the key names
+// without a scheme.
+func WithDefaultType(t Type) MultiKeyManagerOption {
+	return func(m *MultiKeyManager) {
+		m.defaultType = t
+	}
+}
+
+// NewMultiKeyManager creates a new MultiKeyManager. The given context is used
+// to create the KeyManagers.
+func NewMultiKeyManager(ctx context.Context, opts ...MultiKeyManagerOption) *MultiKeyManager {
+	m := &MultiKeyManager{
+		ctx:         ctx,
+		defaultType: SoftKMS,
+		options:     make(map[Type]Options),
+		managers:    make(map[managerKey]KeyManager),
+	}
+	for _, fn := range opts {
+		fn(m)
+	}
+	return m
+}
+
+// keyManager returns the KeyManager for the scheme of the given name, creating
+// it if necessary.
+func (m *MultiKeyManager) keyManager(name string) (KeyManager, error) {
+	// Names that are not URIs, or with a one letter scheme like a Windows
+	// drive, are paths.
+	typ := m.defaultType
+	if u, err := url.Parse(name); err == nil && len(u.Scheme) > 1 {
+		typ = Type(strings.ToLower(u.Scheme))
+	}
+
+	opts, ok := m.options[typ]
+	if !ok {
+		opts = Options{Type: typ}
+	}
+
+	key := managerKey{typ: opts.Type, uri: opts.URI}
+
+	m.mu.Lock()
+	km, ok := m.managers[key]
+	closed := m.closed
+	m.mu.Unlock()
+
+	switch {
+	case closed:
+		return nil, ErrClosed
+	case ok:
+		return km, nil
+	}
+
+	// The KeyManager is created without holding the lock, it might connect to
+	// a remote service or open a device. If another call created one first, the
+	// new one is closed and the stored one is used.
+	fn, ok := LoadKeyManagerNewFunc(typ)
+	if !ok {
+		return nil, fmt.Errorf("unsupported kms type '%s'", typ)
+	}
+	km, err := fn(m.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		km.Close()
+		return nil, ErrClosed
+	}
+	if stored, ok := m.managers[key]; ok {
+		km.Close()
+		return stored, nil
+	}
+	m.managers[key] = km
+	return km, nil
+}
+
+// GetPublicKey returns the public key using the KeyManager for the scheme of
+// the request name.
+func (m *MultiKeyManager) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return km.GetPublicKey(req)
+}
+
+// CreateKey creates a new key using the KeyManager for the scheme of the
+// request name.
+func (m *MultiKeyManager) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return km.CreateKey(req)
+}
+
+// ValidateCreateKey validates a CreateKeyRequest using the KeyManager for the
+// scheme of the request name. It returns a NotImplementedError if the
+// KeyManager does not implement the CreateKeyValidator interface.
+func (m *MultiKeyManager) ValidateCreateKey(req *CreateKeyRequest) error {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return err
+	}
+	v, ok := km.(CreateKeyValidator)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement ValidateCreateKey", km)}
+	}
+	return v.ValidateCreateKey(req)
+}
+
+// CreateSigner creates a crypto.Signer using the KeyManager for the scheme of
+// the signing key.
+func (m *MultiKeyManager) CreateSigner(req *CreateSignerRequest) (crypto.Signer, error) {
+	km, err := m.keyManager(req.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return km.CreateSigner(req)
+}
+
+// CreateDecrypter creates a crypto.Decrypter using the KeyManager for the
+// scheme of the decryption key. It returns a NotImplementedError if the
+// KeyManager does not implement the Decrypter interface.
+func (m *MultiKeyManager) CreateDecrypter(req *CreateDecrypterRequest) (crypto.Decrypter, error) {
+	km, err := m.keyManager(req.DecryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := km.(Decrypter)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement CreateDecrypter", km)}
+	}
+	return d.CreateDecrypter(req)
+}
+
+// LoadCertificate loads a certificate using the KeyManager for the scheme of
+// the request name. It returns a NotImplementedError if the KeyManager does
+// not implement the CertificateManager interface.
+func (m *MultiKeyManager) LoadCertificate(req *LoadCertificateRequest) (*x509.Certificate, error) {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	cm, ok := km.(CertificateManager)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement LoadCertificate", km)}
+	}
+	return cm.LoadCertificate(req)
+}
+
+// StoreCertificate stores a certificate using the KeyManager for the scheme of
+// the request name. It returns a NotImplementedError if the KeyManager does
+// not implement the CertificateManager interface.
+func (m *MultiKeyManager) StoreCertificate(req *StoreCertificateRequest) error {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return err
+	}
+	cm, ok := km.(CertificateManager)
+	if !ok {
+		return NotImplementedError{Message: fmt.Sprintf("%T does not implement StoreCertificate", km)}
+	}
+	return cm.StoreCertificate(req)
+}
+
+// CreateAttestation creates an attestation using the KeyManager for the scheme
+// of the request name. It returns a NotImplementedError if the KeyManager does
+// not implement the Attester interface.
+func (m *MultiKeyManager) CreateAttestation(req *CreateAttestationRequest) (*CreateAttestationResponse, error) {
+	km, err := m.keyManager(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := km.(Attester)
+	if !ok {
+		return nil, NotImplementedError{Message: fmt.Sprintf("%T does not implement CreateAttestation", km)}
+	}
+	return a.CreateAttestation(req)
+}
+
+// Close closes all the KeyManagers created and returns the first error found.
+// After Close the MultiKeyManager operations return ErrClosed.
+func (m *MultiKeyManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	var err error
+	for key, km := range m.managers {
+		if e := km.Close(); e != nil && err == nil {
+			err = fmt.Errorf("error closing %s: %w", key.typ, e)
+		}
+	}
+	m.managers = nil
+	return err
+}
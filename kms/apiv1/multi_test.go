@@ -0,0 +1,228 @@
+package apiv1
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type routedKeyManager struct {
+	opts   Options
+	signer crypto.Signer
+	closed int
+}
+
+func (f *routedKeyManager) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	return f.signer.Public(), nil
+}
+
+func (f *routedKeyManager) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	return &CreateKeyResponse{Name: req.Name, PublicKey: f.signer.Public()}, nil
+}
+
+func (f *routedKeyManager) CreateSigner(req *CreateSignerRequest) (crypto.Signer, error) {
+	return f.signer, nil
+}
+
+func (f *routedKeyManager) Close() error {
+	f.closed++
+	return nil
+}
+
+type routedAttester struct {
+	*routedKeyManager
+}
+
+func (f *routedAttester) CreateAttestation(req *CreateAttestationRequest) (*CreateAttestationResponse, error) {
+	return &CreateAttestationResponse{PublicKey: f.signer.Public()}, nil
+}
+
+func (f *routedAttester) ValidateCreateKey(req *CreateKeyRequest) error {
+	if req.Bits == 1024 {
+		return errors.New("unsupported key size")
+	}
+	return nil
+}
+
+func registerRoutedKeyManager(t *testing.T, typ Type, wrap func(*routedKeyManager) KeyManager) *[]*routedKeyManager {
+	t.Helper()
+	var created []*routedKeyManager
+	Register(typ, func(ctx context.Context, opts Options) (KeyManager, error) {
+		if opts.URI == "fail" {
+			return nil, errors.New("fail")
+		}
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		f := &routedKeyManager{opts: opts, signer: key}
+		created = append(created, f)
+		return wrap(f), nil
+	})
+	return &created
+}
+
+func TestMultiKeyManager(t *testing.T) {
+	fake1 := registerRoutedKeyManager(t, "fakekms1", func(f *routedKeyManager) KeyManager { return f })
+	fake2 := registerRoutedKeyManager(t, "fakekms2", func(f *routedKeyManager) KeyManager { return &routedAttester{f} })
+	registerRoutedKeyManager(t, "fakekms3", func(f *routedKeyManager) KeyManager { return f })
+
+	m := NewMultiKeyManager(context.Background(),
+		WithDefaultType("fakekms1"),
+		WithKeyManagerOptions(Options{URI: "fakekms2:region=foo"}),
+		WithKeyManagerOptions(Options{Type: "fakekms3", URI: "fail"}),
+	)
+
+	signer1, err := m.CreateSigner(&CreateSignerRequest{SigningKey: "fakekms1:name=foo"})
+	if err != nil {
+		t.Fatalf("MultiKeyManager.CreateSigner() error = %v", err)
+	}
+	signer2, err := m.CreateSigner(&CreateSignerRequest{SigningKey: "FAKEKMS2:name=foo"})
+	if err != nil {
+		t.Fatalf("MultiKeyManager.CreateSigner() error = %v", err)
+	}
+	if len(*fake1) != 1 || len(*fake2) != 1 {
+		t.Fatalf("MultiKeyManager created %d and %d key managers, want 1 and 1", len(*fake1), len(*fake2))
+	}
+	if signer1 != (*fake1)[0].signer || signer2 != (*fake2)[0].signer {
+		t.Error("MultiKeyManager.CreateSigner() did not use the key manager of the scheme")
+	}
+	if want := (Options{Type: "fakekms1"}); (*fake1)[0].opts != want {
+		t.Errorf("MultiKeyManager options = %v, want %v", (*fake1)[0].opts, want)
+	}
+	if want := (Options{Type: "fakekms2", URI: "fakekms2:region=foo"}); (*fake2)[0].opts != want {
+		t.Errorf("MultiKeyManager options = %v, want %v", (*fake2)[0].opts, want)
+	}
+
+	// Cached key managers and default type.
+	tests := []struct {
+		name string
+		fake *[]*routedKeyManager
+	}{
+		{"fakekms1:name=bar", fake1},
+		{"/path/to/key.pem", fake1},
+		{`C:\path\to\key.pem`, fake1},
+		{"fakekms2:name=bar", fake2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := m.GetPublicKey(&GetPublicKeyRequest{Name: tt.name})
+			if err != nil {
+				t.Fatalf("MultiKeyManager.GetPublicKey() error = %v", err)
+			}
+			if len(*tt.fake) != 1 {
+				t.Fatalf("MultiKeyManager created %d key managers, want 1", len(*tt.fake))
+			}
+			if !(*tt.fake)[0].signer.Public().(*ecdsa.PublicKey).Equal(pub) {
+				t.Error("MultiKeyManager.GetPublicKey() did not use the key manager of the scheme")
+			}
+		})
+	}
+
+	if _, err := m.CreateAttestation(&CreateAttestationRequest{Name: "fakekms2:name=foo"}); err != nil {
+		t.Errorf("MultiKeyManager.CreateAttestation() error = %v", err)
+	}
+	var notImplemented NotImplementedError
+	if _, err := m.CreateAttestation(&CreateAttestationRequest{Name: "fakekms1:name=foo"}); !errors.As(err, &notImplemented) {
+		t.Errorf("MultiKeyManager.CreateAttestation() error = %v, want NotImplementedError", err)
+	}
+	if err := m.ValidateCreateKey(&CreateKeyRequest{Name: "fakekms2:name=foo", Bits: 2048}); err != nil {
+		t.Errorf("MultiKeyManager.ValidateCreateKey() error = %v", err)
+	}
+	if err := m.ValidateCreateKey(&CreateKeyRequest{Name: "fakekms2:name=foo", Bits: 1024}); err == nil {
+		t.Error("MultiKeyManager.ValidateCreateKey() error = nil, want error")
+	}
+	if err := m.ValidateCreateKey(&CreateKeyRequest{Name: "fakekms1:name=foo"}); !errors.As(err, &notImplemented) {
+		t.Errorf("MultiKeyManager.ValidateCreateKey() error = %v, want NotImplementedError", err)
+	}
+	if _, err := m.CreateDecrypter(&CreateDecrypterRequest{DecryptionKey: "fakekms1:name=foo"}); !errors.As(err, &notImplemented) {
+		t.Errorf("MultiKeyManager.CreateDecrypter() error = %v, want NotImplementedError", err)
+	}
+	if err := m.StoreCertificate(&StoreCertificateRequest{Name: "fakekms1:name=foo"}); !errors.As(err, &notImplemented) {
+		t.Errorf("MultiKeyManager.StoreCertificate() error = %v, want NotImplementedError", err)
+	}
+	if _, err := m.CreateKey(&CreateKeyRequest{Name: "fakekms3:name=foo"}); err == nil {
+		t.Error("MultiKeyManager.CreateKey() error = nil, want fail")
+	}
+	if _, err := m.CreateKey(&CreateKeyRequest{Name: "unknown:name=foo"}); err == nil {
+		t.Error("MultiKeyManager.CreateKey() error = nil, want unsupported kms type")
+	}
+
+	if err := m.Close(); err != nil {
+		t.Errorf("MultiKeyManager.Close() error = %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("MultiKeyManager.Close() error = %v", err)
+	}
+	if (*fake1)[0].closed != 1 || (*fake2)[0].closed != 1 {
+		t.Errorf("MultiKeyManager.Close() closed %d and %d times, want 1 and 1", (*fake1)[0].closed, (*fake2)[0].closed)
+	}
+	if _, err := m.CreateSigner(&CreateSignerRequest{SigningKey: "fakekms1:name=foo"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("MultiKeyManager.CreateSigner() error = %v, want ErrClosed", err)
+	}
+}
+
+type blockingKeyManager struct {
+	*routedKeyManager
+	closed *int32
+}
+
+func (f *blockingKeyManager) Close() error {
+	atomic.AddInt32(f.closed, 1)
+	return nil
+}
+
+func TestMultiKeyManager_concurrent(t *testing.T) {
+	const n = 4
+	var created, closed int32
+	var started sync.WaitGroup
+	started.Add(n)
+	Register("fakekmsblocking", func(ctx context.Context, opts Options) (KeyManager, error) {
+		atomic.AddInt32(&created, 1)
+		// Wait for all the calls, so they all create a KeyManager.
+		started.Done()
+		started.Wait()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return &blockingKeyManager{&routedKeyManager{opts: opts, signer: key}, &closed}, nil
+	})
+
+	m := NewMultiKeyManager(context.Background())
+	signers := make([]crypto.Signer, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signers[i], errs[i] = m.CreateSigner(&CreateSignerRequest{SigningKey: "fakekmsblocking:name=foo"})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("MultiKeyManager.CreateSigner() error = %v", errs[i])
+		}
+		if signers[i] != signers[0] {
+			t.Fatal("MultiKeyManager.CreateSigner() did not use the same key manager")
+		}
+	}
+	if created != n || closed != n-1 {
+		t.Errorf("MultiKeyManager created %d and closed %d key managers, want %d and %d", created, closed, n, n-1)
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("MultiKeyManager.Close() error = %v", err)
+	}
+	if closed != n {
+		t.Errorf("MultiKeyManager.Close() closed %d key managers, want %d", closed, n)
+	}
+}
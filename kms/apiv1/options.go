@@ -1,15 +1,28 @@
 package apiv1
 
 import (
+	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"go.step.sm/crypto/kms/uri"
 )
 
 // KeyManager is the interface implemented by all the KMS.
+//
+// Close releases the resources used by the KeyManager. It is safe to call Close
+// multiple times, only the first call releases the resources and the following
+// ones return nil. A KeyManager must not be used after Close, the KMS holding
+// resources like connections or sessions will return an error wrapping
+// ErrClosed if they are.
 type KeyManager interface {
 	GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error)
 	CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error)
@@ -17,6 +30,69 @@ type KeyManager interface {
 	Close() error
 }
 
+// ContextKeyManager is the interface implemented by the KMS that accept a
+// context.Context in the KeyManager operations. The context allows callers to
+// propagate cancellations, deadlines, and other request-scoped values to the
+// underlying KMS.
+type ContextKeyManager interface {
+	GetPublicKeyContext(ctx context.Context, req *GetPublicKeyRequest) (crypto.PublicKey, error)
+	CreateKeyContext(ctx context.Context, req *CreateKeyRequest) (*CreateKeyResponse, error)
+	CreateSignerContext(ctx context.Context, req *CreateSignerRequest) (crypto.Signer, error)
+}
+
+// ContextSigner is the interface implemented by the signers that accept a
+// context.Context in the sign operation.
+type ContextSigner interface {
+	crypto.Signer
+	SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// JOSEAlgorithmSigner is the interface implemented by the signers that can
+// report the JOSE signature algorithm to use with their key. It allows callers
+// to use a KMS signer with JOSE without knowing the type of the key in advance.
+//
+// Like crypto/ecdsa, the signers return ECDSA signatures in the ASN.1 DER
+// format, but JOSE uses the fixed-width concat(R,S) format for ES256, ES384,
+// ES512 and ES256K. Use keyutil.ECDSASigToRS to convert them.
+type JOSEAlgorithmSigner interface {
+	crypto.Signer
+	JOSEAlgorithm() string
+}
+
+// DefaultJOSEAlgorithm returns the default JOSE signature algorithm for the
+// given public key: ES256, ES384, ES512 or ES256K for ECDSA keys, depending on
+// the curve, RS256 for RSA keys, and EdDSA for Ed25519 keys. It returns an empty
+// string if the key is not supported.
+func DefaultJOSEAlgorithm(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch {
+		case k.Curve == elliptic.P256():
+			return "ES256"
+		case k.Curve == elliptic.P384():
+			return "ES384"
+		case k.Curve == elliptic.P521():
+			return "ES512"
+		case isSecp256k1(k.Curve):
+			return "ES256K"
+		default:
+			return ""
+		}
+	case *rsa.PublicKey:
+		return "RS256"
+	case ed25519.PublicKey:
+		return "EdDSA"
+	default:
+		return ""
+	}
+}
+
+// isSecp256k1 returns true if the given curve is secp256k1. The curve is
+// detected by name, so apiv1 does not depend on a secp256k1 implementation.
+func isSecp256k1(curve elliptic.Curve) bool {
+	return curve != nil && curve.Params().Name == "secp256k1"
+}
+
 // Decrypter is an interface implemented by KMSes that are used
 // in operations that require decryption
 type Decrypter interface {
@@ -36,6 +112,22 @@ type NameValidator interface {
 	ValidateName(s string) error
 }
 
+// ExistingNameValidator is the interface that a KeyManager can implement to
+// validate that a given name or URI references an existing key. Unlike
+// NameValidator, it connects to the KMS, and it returns a NotFoundError if the
+// key does not exist.
+type ExistingNameValidator interface {
+	ValidateNameExists(ctx context.Context, name string) error
+}
+
+// CreateKeyValidator is the interface implemented by the KMS that can validate
+// a CreateKeyRequest without creating the key. It runs the same checks on the
+// name, algorithm, key size and protection level as CreateKey, but it does not
+// connect to the KMS.
+type CreateKeyValidator interface {
+	ValidateCreateKey(req *CreateKeyRequest) error
+}
+
 // Attester is the interface implemented by the KMS that can respond with an
 // attestation certificate or key.
 //
@@ -47,6 +139,199 @@ type Attester interface {
 	CreateAttestation(req *CreateAttestationRequest) (*CreateAttestationResponse, error)
 }
 
+// Verifier is the interface implemented by the KMS that can verify signatures
+// using the keys they hold, without exporting the public key. The message is
+// hashed, if required, using the hash defined by the signature algorithm.
+// Verify returns false and a nil error if the signature is not valid.
+type Verifier interface {
+	Verify(name string, message, signature []byte, alg SignatureAlgorithm) (bool, error)
+}
+
+// KeyWrapper is the interface implemented by the KMS that can export their keys
+// wrapped, i.e. encrypted, with an external key encryption key (KEK), and that
+// can unwrap keys exported that way.
+type KeyWrapper interface {
+	WrapKey(req *WrapKeyRequest) (*WrapKeyResponse, error)
+	UnwrapKey(req *UnwrapKeyRequest) (*UnwrapKeyResponse, error)
+}
+
+// RotationPolicyManager is the interface implemented by the KMS that can
+// rotate keys automatically.
+type RotationPolicyManager interface {
+	GetRotationPolicy(name string) (*RotationPolicy, error)
+	SetRotationPolicy(name string, policy *RotationPolicy) error
+}
+
+// KeyRotator is the interface implemented by the KMS that can rotate a key,
+// i.e. create a new key, or a new version of a key, with the same parameters as
+// an existing one. KMSs implement it if the parameters of a key cannot be
+// derived from its public key.
+type KeyRotator interface {
+	RotateKey(name string) (*CreateKeyResponse, error)
+}
+
+// Pinger is the interface implemented by the KMS that can check that they are
+// reachable and that their credentials are valid, without creating or using
+// keys. It can be used in readiness probes. Ping returns a
+// PermissionDeniedError if the credentials are not valid, and an
+// UnavailableError if the KMS cannot be reached.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Capabilities describes the operations supported by a KeyManager. It allows
+// callers to know which operations are available without trying them, e.g. to
+// disable unsupported actions in a user interface.
+type Capabilities struct {
+	// CreateKey is true if the KeyManager can create new keys.
+	CreateKey bool
+	// CreateSigner is true if the KeyManager can sign using its keys.
+	CreateSigner bool
+	// CreateDecrypter is true if the KeyManager implements the Decrypter
+	// interface.
+	CreateDecrypter bool
+	// LoadCertificate is true if the KeyManager can load certificates.
+	LoadCertificate bool
+	// StoreCertificate is true if the KeyManager can store certificates.
+	StoreCertificate bool
+	// CreateAttestation is true if the KeyManager implements the Attester
+	// interface.
+	CreateAttestation bool
+	// Verify is true if the KeyManager implements the Verifier interface.
+	Verify bool
+	// WrapKey is true if the KeyManager implements the KeyWrapper interface.
+	WrapKey bool
+}
+
+// CapabilitiesReporter is the interface that a KeyManager can implement to
+// advertise the operations it supports.
+type CapabilitiesReporter interface {
+	Capabilities() Capabilities
+}
+
+// DefaultCapabilities are the capabilities assumed for a KeyManager that does
+// not implement the CapabilitiesReporter interface, only signing is supported.
+var DefaultCapabilities = Capabilities{
+	CreateSigner: true,
+}
+
+// GetCapabilities returns the capabilities of the given KeyManager. If the
+// KeyManager does not implement the CapabilitiesReporter interface,
+// DefaultCapabilities are returned.
+func GetCapabilities(km KeyManager) Capabilities {
+	if r, ok := km.(CapabilitiesReporter); ok {
+		return r.Capabilities()
+	}
+	return DefaultCapabilities
+}
+
+// SupportedAlgorithm describes a signature algorithm supported by a KeyManager
+// and the key parameters that can be used to create keys with it.
+type SupportedAlgorithm struct {
+	// SignatureAlgorithm is the supported signature algorithm.
+	SignatureAlgorithm SignatureAlgorithm
+	// Bits is the list of allowed RSA key sizes. It is empty for other key
+	// types.
+	Bits []int
+	// Curves is the list of allowed elliptic curves, e.g. "P-256". It is
+	// empty for RSA keys.
+	Curves []string
+}
+
+// SupportedAlgorithmsReporter is the interface that a KeyManager can implement
+// to advertise the signature algorithms, key sizes and curves it supports in
+// CreateKey.
+type SupportedAlgorithmsReporter interface {
+	SupportedAlgorithms() []SupportedAlgorithm
+}
+
+// SupportedAlgorithms returns the signature algorithms supported by the given
+// KeyManager. The second value is false if the KeyManager does not implement
+// the SupportedAlgorithmsReporter interface, and the supported algorithms are
+// unknown.
+func SupportedAlgorithms(km KeyManager) ([]SupportedAlgorithm, bool) {
+	if r, ok := km.(SupportedAlgorithmsReporter); ok {
+		return r.SupportedAlgorithms(), true
+	}
+	return nil, false
+}
+
+// Ping checks that the given KeyManager is available. It returns a
+// NotImplementedError if the KeyManager does not implement the Pinger
+// interface.
+func Ping(ctx context.Context, km KeyManager) error {
+	if p, ok := km.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return NotImplementedError{Message: fmt.Sprintf("%T does not implement Ping", km)}
+}
+
+// RotateKey creates a new key with the same parameters as the key with the
+// given name and returns it. The old key is left intact, so it can be used
+// until all the references to it are updated to the new name.
+//
+// If the KeyManager implements the KeyRotator interface, the rotation is
+// delegated to it. Otherwise, the parameters are derived from the public key of
+// the old key using NewCreateKeyRequest, and the new key is created with the
+// same name. On KMSs with key versions, like azurekms, this creates a new
+// version of the same key, and the name of the new version is returned. KMSs
+// without versions might return an AlreadyExistsError.
+func RotateKey(km KeyManager, oldName string) (*CreateKeyResponse, error) {
+	if r, ok := km.(KeyRotator); ok {
+		return r.RotateKey(oldName)
+	}
+	pub, err := km.GetPublicKey(&GetPublicKeyRequest{Name: oldName})
+	if err != nil {
+		return nil, err
+	}
+	req, err := NewCreateKeyRequest(oldName, pub)
+	if err != nil {
+		return nil, err
+	}
+	return km.CreateKey(req)
+}
+
+// NewCreateKeyRequest returns a CreateKeyRequest with the given name that
+// creates a key of the same type and size as the given public key. ECDSA keys
+// use the signature algorithm for their curve, RSA keys use SHA256WithRSA and
+// the size of the modulus, and Ed25519 keys use PureEd25519.
+func NewCreateKeyRequest(name string, pub crypto.PublicKey) (*CreateKeyRequest, error) {
+	req := &CreateKeyRequest{
+		Name: name,
+	}
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			req.SignatureAlgorithm = ECDSAWithSHA256
+		case elliptic.P384():
+			req.SignatureAlgorithm = ECDSAWithSHA384
+		case elliptic.P521():
+			req.SignatureAlgorithm = ECDSAWithSHA512
+		default:
+			if !isSecp256k1(k.Curve) {
+				return nil, fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+			}
+			req.SignatureAlgorithm = ECDSAWithSHA256K
+		}
+	case *rsa.PublicKey:
+		req.SignatureAlgorithm = SHA256WithRSA
+		req.Bits = k.N.BitLen()
+		if k.E != 65537 {
+			req.PublicExponent = k.E
+		}
+	case ed25519.PublicKey:
+		req.SignatureAlgorithm = PureEd25519
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return req, nil
+}
+
+// ErrClosed is the error returned by the KeyManager operations after Close
+// has been called.
+var ErrClosed = errors.New("key manager is closed")
+
 // NotImplementedError is the type of error returned if an operation is not
 // implemented.
 type NotImplementedError struct {
@@ -61,7 +346,7 @@ func (e NotImplementedError) Error() string {
 }
 
 // AlreadyExistsError is the type of error returned if a key already exists. This
-// is currently only implmented on pkcs11.
+// is currently implemented on pkcs11, tpmkms and azurekms.
 type AlreadyExistsError struct {
 	Message string
 }
@@ -73,6 +358,61 @@ func (e AlreadyExistsError) Error() string {
 	return "key already exists"
 }
 
+// NotFoundError is the type of error returned if a key or other object does
+// not exist. This is currently only implemented on azurekms.
+type NotFoundError struct {
+	Message string
+}
+
+func (e NotFoundError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return "not found"
+}
+
+// PermissionDeniedError is the type of error returned if the credentials used
+// by the KMS are not valid or do not allow the operation. This is currently
+// only implemented on azurekms.
+type PermissionDeniedError struct {
+	Message string
+}
+
+func (e PermissionDeniedError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return "permission denied"
+}
+
+// UnavailableError is the type of error returned if the KMS cannot be reached,
+// e.g. because of a network failure. This is currently only implemented on
+// azurekms and softkms.
+type UnavailableError struct {
+	Message string
+}
+
+func (e UnavailableError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return "unavailable"
+}
+
+// UnsupportedAlgorithmError is the type of error returned if the KMS does not
+// support the requested signature algorithm. This is currently only
+// implemented on azurekms.
+type UnsupportedAlgorithmError struct {
+	Message string
+}
+
+func (e UnsupportedAlgorithmError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return "unsupported algorithm"
+}
+
 // Type represents the KMS type used.
 type Type string
 
@@ -95,6 +435,12 @@ const (
 	AzureKMS Type = "azurekms"
 	// CAPIKMS
 	CAPIKMS Type = "capi"
+	// TPMKMS is a KMS implementation using a TPM 2.0.
+	TPMKMS Type = "tpmkms"
+	// KMSTest is an in-memory KMS implementation used for testing.
+	KMSTest Type = "kmstest"
+	// PluginKMS is a KMS implementation using a gRPC plugin.
+	PluginKMS Type = "pluginkms"
 )
 
 // Options are the KMS options. They represent the kms object in the ca.json.
@@ -142,8 +488,8 @@ func (o *Options) Validate() error {
 	switch Type(typ) {
 	case DefaultKMS, SoftKMS: // Go crypto based kms.
 	case CloudKMS, AmazonKMS, AzureKMS: // Cloud based kms.
-	case YubiKey, PKCS11: // Hardware based kms.
-	case SSHAgentKMS, CAPIKMS: // Others
+	case YubiKey, PKCS11, TPMKMS: // Hardware based kms.
+	case SSHAgentKMS, CAPIKMS, KMSTest, PluginKMS: // Others
 	default:
 		return fmt.Errorf("unsupported kms type %s", o.Type)
 	}
@@ -1,7 +1,18 @@
 package apiv1
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
 func TestOptions_Validate(t *testing.T) {
@@ -16,6 +27,8 @@ func TestOptions_Validate(t *testing.T) {
 		{"awskms", &Options{Type: "awskms"}, false},
 		{"sshagentkms", &Options{Type: "sshagentkms"}, false},
 		{"pkcs11", &Options{Type: "pkcs11"}, false},
+		{"kmstest", &Options{Type: "kmstest"}, false},
+		{"pluginkms", &Options{Type: "pluginkms"}, false},
 		{"unsupported", &Options{Type: "unsupported"}, true},
 	}
 	for _, tt := range tests {
@@ -115,3 +128,364 @@ func TestErrAlreadyExists_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorTypes_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not found default", NotFoundError{}, "not found"},
+		{"not found custom", NotFoundError{"key not found"}, "key not found"},
+		{"permission denied default", PermissionDeniedError{}, "permission denied"},
+		{"permission denied custom", PermissionDeniedError{"403 Forbidden"}, "403 Forbidden"},
+		{"unsupported algorithm default", UnsupportedAlgorithmError{}, "unsupported algorithm"},
+		{"unsupported algorithm custom", UnsupportedAlgorithmError{"unsupported ES256"}, "unsupported ES256"},
+		{"unavailable default", UnavailableError{}, "unavailable"},
+		{"unavailable custom", UnavailableError{"connection refused"}, "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("%T.Error() = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeKeyManager struct{}
+
+func (fakeKeyManager) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	return nil, nil
+}
+func (fakeKeyManager) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error)  { return nil, nil }
+func (fakeKeyManager) CreateSigner(req *CreateSignerRequest) (crypto.Signer, error) { return nil, nil }
+func (fakeKeyManager) Close() error                                                 { return nil }
+
+type fakeCapabilitiesReporter struct {
+	fakeKeyManager
+	capabilities Capabilities
+}
+
+func (f fakeCapabilitiesReporter) Capabilities() Capabilities { return f.capabilities }
+
+func TestGetCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		km   KeyManager
+		want Capabilities
+	}{
+		{"default", fakeKeyManager{}, Capabilities{CreateSigner: true}},
+		{"reporter", fakeCapabilitiesReporter{capabilities: Capabilities{
+			CreateKey: true, CreateSigner: true, CreateDecrypter: true,
+		}}, Capabilities{CreateKey: true, CreateSigner: true, CreateDecrypter: true}},
+		{"reporter empty", fakeCapabilitiesReporter{}, Capabilities{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetCapabilities(tt.km); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCapabilities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeSupportedAlgorithmsReporter struct {
+	fakeKeyManager
+	algorithms []SupportedAlgorithm
+}
+
+func (f fakeSupportedAlgorithmsReporter) SupportedAlgorithms() []SupportedAlgorithm {
+	return f.algorithms
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	algorithms := []SupportedAlgorithm{
+		{SignatureAlgorithm: SHA256WithRSA, Bits: []int{2048, 4096}},
+		{SignatureAlgorithm: ECDSAWithSHA256, Curves: []string{"P-256"}},
+	}
+	tests := []struct {
+		name   string
+		km     KeyManager
+		want   []SupportedAlgorithm
+		wantOk bool
+	}{
+		{"unknown", fakeKeyManager{}, nil, false},
+		{"reporter", fakeSupportedAlgorithmsReporter{algorithms: algorithms}, algorithms, true},
+		{"reporter empty", fakeSupportedAlgorithmsReporter{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SupportedAlgorithms(tt.km)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SupportedAlgorithms() got = %v, want %v", got, tt.want)
+			}
+			if ok != tt.wantOk {
+				t.Errorf("SupportedAlgorithms() ok = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
+
+type fakePinger struct {
+	fakeKeyManager
+	err error
+}
+
+func (f fakePinger) Ping(ctx context.Context) error { return f.err }
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		km      KeyManager
+		wantErr error
+	}{
+		{"ok", fakePinger{}, nil},
+		{"fail permission denied", fakePinger{err: PermissionDeniedError{}}, PermissionDeniedError{}},
+		{"fail unavailable", fakePinger{err: UnavailableError{}}, UnavailableError{}},
+		{"fail not implemented", fakeKeyManager{}, NotImplementedError{Message: "apiv1.fakeKeyManager does not implement Ping"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Ping(context.Background(), tt.km); !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultJOSEAlgorithm(t *testing.T) {
+	mustECDSA := func(c elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.Public()
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		want string
+	}{
+		{"P-256", mustECDSA(elliptic.P256()), "ES256"},
+		{"P-384", mustECDSA(elliptic.P384()), "ES384"},
+		{"P-521", mustECDSA(elliptic.P521()), "ES512"},
+		{"secp256k1", mustECDSA(secp256k1.S256()), "ES256K"},
+		{"RSA", rsaKey.Public(), "RS256"},
+		{"Ed25519", edKey, "EdDSA"},
+		{"P-224", mustECDSA(elliptic.P224()), ""},
+		{"unknown", []byte("not a key"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultJOSEAlgorithm(tt.pub); got != tt.want {
+				t.Errorf("DefaultJOSEAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// versionedKeyManager is a KeyManager that keeps all the versions of a key.
+type versionedKeyManager struct {
+	fakeKeyManager
+	keys map[string][]crypto.PublicKey
+}
+
+func (f *versionedKeyManager) GetPublicKey(req *GetPublicKeyRequest) (crypto.PublicKey, error) {
+	versions, ok := f.keys[req.Name]
+	if !ok {
+		return nil, NotFoundError{}
+	}
+	return versions[len(versions)-1], nil
+}
+
+func (f *versionedKeyManager) CreateKey(req *CreateKeyRequest) (*CreateKeyResponse, error) {
+	var pub crypto.PublicKey
+	switch req.SignatureAlgorithm {
+	case ECDSAWithSHA256, ECDSAWithSHA384, ECDSAWithSHA512:
+		curve := map[SignatureAlgorithm]elliptic.Curve{
+			ECDSAWithSHA256: elliptic.P256(),
+			ECDSAWithSHA384: elliptic.P384(),
+			ECDSAWithSHA512: elliptic.P521(),
+		}[req.SignatureAlgorithm]
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		pub = key.Public()
+	case SHA256WithRSA:
+		key, err := rsa.GenerateKey(rand.Reader, req.Bits)
+		if err != nil {
+			return nil, err
+		}
+		pub = key.Public()
+	case PureEd25519:
+		key, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		pub = key
+	default:
+		return nil, UnsupportedAlgorithmError{}
+	}
+	f.keys[req.Name] = append(f.keys[req.Name], pub)
+	return &CreateKeyResponse{
+		Name:      fmt.Sprintf("%s?version=%d", req.Name, len(f.keys[req.Name])),
+		PublicKey: pub,
+	}, nil
+}
+
+type fakeKeyRotator struct {
+	fakeKeyManager
+	name string
+}
+
+func (f *fakeKeyRotator) RotateKey(name string) (*CreateKeyResponse, error) {
+	f.name = name
+	return &CreateKeyResponse{Name: name + "-rotated"}, nil
+}
+
+func TestRotateKey(t *testing.T) {
+	mustECDSA := func(c elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.Public()
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km := &versionedKeyManager{keys: map[string][]crypto.PublicKey{
+		"ec":      {mustECDSA(elliptic.P384())},
+		"rsa":     {rsaKey.Public()},
+		"ed25519": {edKey},
+		"p224":    {mustECDSA(elliptic.P224())},
+	}}
+
+	tests := []struct {
+		name    string
+		oldName string
+		wantAlg SignatureAlgorithm
+		wantErr bool
+	}{
+		{"ok ec", "ec", ECDSAWithSHA384, false},
+		{"ok rsa", "rsa", SHA256WithRSA, false},
+		{"ok ed25519", "ed25519", PureEd25519, false},
+		{"fail not found", "missing", 0, true},
+		{"fail curve", "p224", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var oldKey crypto.PublicKey
+			if versions := km.keys[tt.oldName]; len(versions) > 0 {
+				oldKey = versions[0]
+			}
+			got, err := RotateKey(km, tt.oldName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RotateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("RotateKey() = %v, want nil", got)
+				}
+				return
+			}
+			if got.Name != tt.oldName+"?version=2" {
+				t.Errorf("RotateKey() name = %s, want %s", got.Name, tt.oldName+"?version=2")
+			}
+			// The old key is left intact.
+			if versions := km.keys[tt.oldName]; len(versions) != 2 || !reflect.DeepEqual(versions[0], oldKey) {
+				t.Errorf("RotateKey() modified the old key")
+			}
+			oldReq, err := NewCreateKeyRequest(tt.oldName, oldKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newReq, err := NewCreateKeyRequest(tt.oldName, got.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newReq.SignatureAlgorithm != tt.wantAlg || newReq.SignatureAlgorithm != oldReq.SignatureAlgorithm {
+				t.Errorf("RotateKey() algorithm = %v, want %v", newReq.SignatureAlgorithm, tt.wantAlg)
+			}
+			if newReq.Bits != oldReq.Bits {
+				t.Errorf("RotateKey() bits = %d, want %d", newReq.Bits, oldReq.Bits)
+			}
+		})
+	}
+}
+
+func TestRotateKey_keyRotator(t *testing.T) {
+	km := &fakeKeyRotator{}
+	got, err := RotateKey(km, "the-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.name != "the-key" || got.Name != "the-key-rotated" {
+		t.Errorf("RotateKey() = %v, want the-key-rotated", got)
+	}
+}
+
+func TestNewCreateKeyRequest(t *testing.T) {
+	mustECDSA := func(c elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.Public()
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey3 := &rsa.PublicKey{N: rsaKey.N, E: 3}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pub     crypto.PublicKey
+		want    *CreateKeyRequest
+		wantErr bool
+	}{
+		{"P-256", mustECDSA(elliptic.P256()), &CreateKeyRequest{Name: "name", SignatureAlgorithm: ECDSAWithSHA256}, false},
+		{"P-384", mustECDSA(elliptic.P384()), &CreateKeyRequest{Name: "name", SignatureAlgorithm: ECDSAWithSHA384}, false},
+		{"P-521", mustECDSA(elliptic.P521()), &CreateKeyRequest{Name: "name", SignatureAlgorithm: ECDSAWithSHA512}, false},
+		{"secp256k1", mustECDSA(secp256k1.S256()), &CreateKeyRequest{Name: "name", SignatureAlgorithm: ECDSAWithSHA256K}, false},
+		{"RSA", rsaKey.Public(), &CreateKeyRequest{Name: "name", SignatureAlgorithm: SHA256WithRSA, Bits: 2048}, false},
+		{"RSA exponent", rsaKey3, &CreateKeyRequest{Name: "name", SignatureAlgorithm: SHA256WithRSA, Bits: 2048, PublicExponent: 3}, false},
+		{"Ed25519", edKey, &CreateKeyRequest{Name: "name", SignatureAlgorithm: PureEd25519}, false},
+		{"fail P-224", mustECDSA(elliptic.P224()), nil, true},
+		{"fail unknown", []byte("not a key"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCreateKeyRequest("name", tt.pub)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCreateKeyRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewCreateKeyRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"crypto"
 	"crypto/x509"
 	"fmt"
+	"time"
+
+	"github.com/google/go-attestation/attest"
 )
 
 // ProtectionLevel specifies on some KMS how cryptographic operations are
@@ -48,6 +51,40 @@ const (
 	TouchPolicyCached
 )
 
+// KeyOperation is an operation that can be performed with a key. It is used to
+// restrict the operations allowed on new keys.
+type KeyOperation int
+
+// Key operations supported by this package.
+const (
+	KeyOperationSign KeyOperation = iota + 1
+	KeyOperationVerify
+	KeyOperationEncrypt
+	KeyOperationDecrypt
+	KeyOperationWrapKey
+	KeyOperationUnwrapKey
+)
+
+// String returns a string representation of o.
+func (o KeyOperation) String() string {
+	switch o {
+	case KeyOperationSign:
+		return "sign"
+	case KeyOperationVerify:
+		return "verify"
+	case KeyOperationEncrypt:
+		return "encrypt"
+	case KeyOperationDecrypt:
+		return "decrypt"
+	case KeyOperationWrapKey:
+		return "wrapKey"
+	case KeyOperationUnwrapKey:
+		return "unwrapKey"
+	default:
+		return fmt.Sprintf("unknown(%d)", o)
+	}
+}
+
 // String returns a string representation of p.
 func (p ProtectionLevel) String() string {
 	switch p {
@@ -88,6 +125,8 @@ const (
 	ECDSAWithSHA512
 	// EdDSA on Curve25519 with a SHA512 digest.
 	PureEd25519
+	// ECDSA on the secp256k1 curve with a SHA256 digest.
+	ECDSAWithSHA256K
 )
 
 // String returns a string representation of s.
@@ -115,11 +154,61 @@ func (s SignatureAlgorithm) String() string {
 		return "ECDSA-SHA512"
 	case PureEd25519:
 		return "Ed25519"
+	case ECDSAWithSHA256K:
+		return "ECDSA-SHA256K"
 	default:
 		return fmt.Sprintf("unknown(%d)", s)
 	}
 }
 
+// EncryptionAlgorithm is the algorithm used to encrypt or decrypt data with an
+// asymmetric key, or to wrap a key with a key encryption key. Each KMS supports
+// a subset of them for each operation.
+type EncryptionAlgorithm int
+
+const (
+	// Not specified, the KMS default is used.
+	UnspecifiedEncryptionAlgorithm EncryptionAlgorithm = iota
+	// RSAES-OAEP with SHA1 and MGF1 with SHA1.
+	RSAOAEPWithSHA1
+	// RSAES-OAEP with SHA256 and MGF1 with SHA256.
+	RSAOAEPWithSHA256
+	// RSAES-PKCS1-v1_5. This scheme is vulnerable to padding oracle attacks,
+	// and KMSs might require an explicit opt-in to use it.
+	RSAPKCS1v15
+	// RSAES-OAEP with SHA1 wrapping an ephemeral AES key, that wraps the target
+	// key using AES key wrap with padding (CKM_RSA_AES_KEY_WRAP).
+	RSAAESKeyWrapWithSHA1
+	// RSAES-OAEP with SHA256 wrapping an ephemeral AES key, that wraps the
+	// target key using AES key wrap with padding.
+	RSAAESKeyWrapWithSHA256
+	// RSAES-OAEP with SHA384 wrapping an ephemeral AES key, that wraps the
+	// target key using AES key wrap with padding.
+	RSAAESKeyWrapWithSHA384
+)
+
+// String returns a string representation of e.
+func (e EncryptionAlgorithm) String() string {
+	switch e {
+	case UnspecifiedEncryptionAlgorithm:
+		return "unspecified"
+	case RSAOAEPWithSHA1:
+		return "RSA-OAEP"
+	case RSAOAEPWithSHA256:
+		return "RSA-OAEP-256"
+	case RSAPKCS1v15:
+		return "RSA1_5"
+	case RSAAESKeyWrapWithSHA1:
+		return "RSA-AES-KEY-WRAP"
+	case RSAAESKeyWrapWithSHA256:
+		return "RSA-AES-KEY-WRAP-256"
+	case RSAAESKeyWrapWithSHA384:
+		return "RSA-AES-KEY-WRAP-384"
+	default:
+		return fmt.Sprintf("unknown(%d)", e)
+	}
+}
+
 // GetPublicKeyRequest is the parameter used in the kms.GetPublicKey method.
 type GetPublicKeyRequest struct {
 	Name string
@@ -138,6 +227,13 @@ type CreateKeyRequest struct {
 	// Bits is the number of bits on RSA keys.
 	Bits int
 
+	// PublicExponent is the public exponent used on new RSA keys. If it's not
+	// set the default value 65537 is used. Only softkms supports other
+	// exponents, the rest of the KMSs fail if a value other than 65537 is set.
+	//
+	// Used by: softkms.
+	PublicExponent int
+
 	// ProtectionLevel specifies how cryptographic operations are performed.
 	// Used by: cloudkms, azurekms.
 	ProtectionLevel ProtectionLevel
@@ -159,6 +255,36 @@ type CreateKeyRequest struct {
 	//
 	// Used by: yubikey
 	TouchPolicy TouchPolicy
+
+	// KeyOperations is the list of operations allowed with the new key. If
+	// it's not set, the key can only sign and verify.
+	//
+	// Used by: azurekms
+	KeyOperations []KeyOperation
+
+	// SelfTest, if true, signs a test message with the new key and verifies
+	// the signature with the returned public key. CreateKey fails if the
+	// signature does not verify, and if KeyOperations does not include
+	// KeyOperationSign. The KMS might disable the new key if the test fails.
+	//
+	// Used by: azurekms
+	SelfTest bool
+
+	// IfNotExists, if true, makes CreateKey return the public key and name of
+	// the existing key, instead of creating a new key or a new version, if the
+	// key already exists. It makes the creation of keys idempotent. CreateKey
+	// returns an AlreadyExistsError if the type, curve or size of the existing
+	// key do not match the request.
+	//
+	// Used by: azurekms
+	IfNotExists bool
+
+	// Extra contains backend-specific parameters that don't fit in the rest of
+	// fields. Each KMS reads its own keys and ignores the unknown ones.
+	//
+	// Used by: azurekms ("tags", a map[string]string or a map[string]any with
+	// string values, with the tags of the new key).
+	Extra map[string]any
 }
 
 // CreateKeyResponse is the response value of the kms.CreateKey method.
@@ -186,6 +312,85 @@ type CreateDecrypterRequest struct {
 	DecryptionKey    string
 	DecryptionKeyPEM []byte
 	Password         []byte
+
+	// EncryptionAlgorithm is the algorithm used by the decrypter if the
+	// options passed to Decrypt do not select one.
+	//
+	// Used by: azurekms.
+	EncryptionAlgorithm EncryptionAlgorithm
+
+	// AllowRSAPKCS1v15 enables decryption using RSAES-PKCS1-v1_5. It is
+	// rejected by default due to padding oracle attacks.
+	//
+	// Used by: azurekms.
+	AllowRSAPKCS1v15 bool
+}
+
+// WrapKeyRequest is the parameter used in the WrapKey method of a KeyWrapper.
+type WrapKeyRequest struct {
+	// Name is the name of the key to wrap.
+	Name string
+
+	// Password is used to decrypt the key to wrap.
+	//
+	// Used by: softkms
+	Password []byte
+
+	// WrappingKey is the public key used to wrap the key, the key encryption
+	// key (KEK).
+	//
+	// Used by: softkms
+	WrappingKey crypto.PublicKey
+
+	// WrappingAlgorithm is the algorithm used to wrap the key.
+	WrappingAlgorithm EncryptionAlgorithm
+
+	// TargetAttestationToken is the attestation of the environment the key is
+	// released to. It includes the key encryption key.
+	//
+	// Used by: azurekms
+	TargetAttestationToken string
+}
+
+// WrapKeyResponse is the response value of the WrapKey method of a KeyWrapper.
+type WrapKeyResponse struct {
+	// WrappedKey is the wrapped key. Its format depends on the KMS, softkms
+	// returns the wrapped PKCS #8 private key, and azurekms returns the signed
+	// key release object.
+	WrappedKey []byte
+
+	// WrappingAlgorithm is the algorithm used to wrap the key.
+	WrappingAlgorithm EncryptionAlgorithm
+}
+
+// UnwrapKeyRequest is the parameter used in the UnwrapKey method of a
+// KeyWrapper.
+type UnwrapKeyRequest struct {
+	// WrappedKey is the key to unwrap.
+	WrappedKey []byte
+
+	// WrappingAlgorithm is the algorithm used to wrap the key.
+	WrappingAlgorithm EncryptionAlgorithm
+
+	// UnwrappingKey is the name of the private key used to unwrap the key.
+	UnwrappingKey string
+
+	// UnwrappingKeyPEM is the PEM encoded private key used to unwrap the key.
+	//
+	// Used by: softkms
+	UnwrappingKeyPEM []byte
+
+	// Password is used to decrypt the unwrapping key.
+	//
+	// Used by: softkms
+	Password []byte
+}
+
+// UnwrapKeyResponse is the response value of the UnwrapKey method of a
+// KeyWrapper.
+type UnwrapKeyResponse struct {
+	PublicKey  crypto.PublicKey
+	PrivateKey crypto.PrivateKey
 }
 
 // LoadCertificateRequest is the parameter used in the LoadCertificate method of
@@ -230,4 +435,25 @@ type CreateAttestationResponse struct {
 	CertificateChain    []*x509.Certificate
 	PublicKey           crypto.PublicKey
 	PermanentIdentifier string
+	// CertificationParameters contains the TPM evidence, signed by the
+	// attestation key in Certificate, that certifies the key was created in
+	// the TPM. It is only set by TPM backed KMSs.
+	CertificationParameters *attest.CertificationParameters
+}
+
+// RotationPolicy is the policy used by a KMS to rotate a key automatically. A
+// zero value in a field means that it is not set.
+type RotationPolicy struct {
+	// ExpiryTime is the time, after its creation, when a new key version
+	// expires.
+	ExpiryTime time.Duration
+	// RotateAfterCreate is the time, after the creation of the current key
+	// version, when a new key version is created.
+	RotateAfterCreate time.Duration
+	// RotateBeforeExpiry is the time, before the expiration of the current key
+	// version, when a new key version is created.
+	RotateBeforeExpiry time.Duration
+	// NotifyBeforeExpiry is the time, before the expiration of the current key
+	// version, when a notification is sent.
+	NotifyBeforeExpiry time.Duration
 }
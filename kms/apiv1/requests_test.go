@@ -39,6 +39,7 @@ func TestSignatureAlgorithm_String(t *testing.T) {
 		{"ECDSAWithSHA384", ECDSAWithSHA384, "ECDSA-SHA384"},
 		{"ECDSAWithSHA512", ECDSAWithSHA512, "ECDSA-SHA512"},
 		{"PureEd25519", PureEd25519, "Ed25519"},
+		{"ECDSAWithSHA256K", ECDSAWithSHA256K, "ECDSA-SHA256K"},
 		{"unknown", SignatureAlgorithm(100), "unknown(100)"},
 	}
 	for _, tt := range tests {
@@ -49,3 +50,50 @@ func TestSignatureAlgorithm_String(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptionAlgorithm_String(t *testing.T) {
+	tests := []struct {
+		name string
+		e    EncryptionAlgorithm
+		want string
+	}{
+		{"UnspecifiedEncryptionAlgorithm", UnspecifiedEncryptionAlgorithm, "unspecified"},
+		{"RSAOAEPWithSHA1", RSAOAEPWithSHA1, "RSA-OAEP"},
+		{"RSAOAEPWithSHA256", RSAOAEPWithSHA256, "RSA-OAEP-256"},
+		{"RSAPKCS1v15", RSAPKCS1v15, "RSA1_5"},
+		{"RSAAESKeyWrapWithSHA1", RSAAESKeyWrapWithSHA1, "RSA-AES-KEY-WRAP"},
+		{"RSAAESKeyWrapWithSHA256", RSAAESKeyWrapWithSHA256, "RSA-AES-KEY-WRAP-256"},
+		{"RSAAESKeyWrapWithSHA384", RSAAESKeyWrapWithSHA384, "RSA-AES-KEY-WRAP-384"},
+		{"unknown", EncryptionAlgorithm(100), "unknown(100)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.String(); got != tt.want {
+				t.Errorf("EncryptionAlgorithm.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyOperation_String(t *testing.T) {
+	tests := []struct {
+		name string
+		o    KeyOperation
+		want string
+	}{
+		{"KeyOperationSign", KeyOperationSign, "sign"},
+		{"KeyOperationVerify", KeyOperationVerify, "verify"},
+		{"KeyOperationEncrypt", KeyOperationEncrypt, "encrypt"},
+		{"KeyOperationDecrypt", KeyOperationDecrypt, "decrypt"},
+		{"KeyOperationWrapKey", KeyOperationWrapKey, "wrapKey"},
+		{"KeyOperationUnwrapKey", KeyOperationUnwrapKey, "unwrapKey"},
+		{"unknown", KeyOperation(0), "unknown(0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.o.String(); got != tt.want {
+				t.Errorf("KeyOperation.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
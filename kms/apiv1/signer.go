@@ -0,0 +1,112 @@
+package apiv1
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+)
+
+// SignerOpts returns the crypto.SignerOpts used to sign with the signature
+// algorithm s. RSA-PSS algorithms return an *rsa.PSSOptions with a salt length
+// equal to the hash size, and PureEd25519 returns crypto.Hash(0).
+func (s SignatureAlgorithm) SignerOpts() (crypto.SignerOpts, error) {
+	switch s {
+	case SHA256WithRSA, ECDSAWithSHA256:
+		return crypto.SHA256, nil
+	case SHA384WithRSA, ECDSAWithSHA384:
+		return crypto.SHA384, nil
+	case SHA512WithRSA, ECDSAWithSHA512:
+		return crypto.SHA512, nil
+	case SHA256WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+	case SHA384WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, nil
+	case SHA512WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, nil
+	case PureEd25519:
+		return crypto.Hash(0), nil
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %s", s)
+	}
+}
+
+// SignReader hashes the data read from r with the hash function in opts and
+// signs the resulting digest using the given signer. It allows signing large
+// files without loading them in memory, as only the digest is sent to the
+// signer.
+//
+// Signers that require the full message, like Ed25519, are not supported.
+func SignReader(signer crypto.Signer, r io.Reader, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc()
+	if h == 0 {
+		return nil, fmt.Errorf("error signing data: a hash function is required")
+	}
+	if !h.Available() {
+		return nil, fmt.Errorf("error signing data: hash function %s is not available", h)
+	}
+
+	hash := h.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return nil, fmt.Errorf("error reading data: %w", err)
+	}
+
+	signature, err := signer.Sign(rand.Reader, hash.Sum(nil), opts)
+	if err != nil {
+		return nil, fmt.Errorf("error signing data: %w", err)
+	}
+	return signature, nil
+}
+
+// selfTestMessage is the message signed by SelfTest.
+var selfTestMessage = []byte("go.step.sm/crypto/kms self-test")
+
+// SelfTest signs a test message with the given signer and verifies the
+// signature using the public key pub. It returns an error if the signature
+// cannot be created or it does not verify. The message is hashed with the hash
+// function in opts, Ed25519 keys sign the message using crypto.Hash(0).
+//
+// KeyManagers use it to check that a new key is functional if
+// CreateKeyRequest.SelfTest is set.
+func SelfTest(signer crypto.Signer, pub crypto.PublicKey, opts crypto.SignerOpts) error {
+	digest := selfTestMessage
+	if h := opts.HashFunc(); h != 0 {
+		if !h.Available() {
+			return fmt.Errorf("self-test failed: hash function %s is not available", h)
+		}
+		hash := h.New()
+		hash.Write(selfTestMessage)
+		digest = hash.Sum(nil)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return fmt.Errorf("self-test failed: error signing: %w", err)
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf("self-test failed: invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if o, ok := opts.(*rsa.PSSOptions); ok {
+			err = rsa.VerifyPSS(pub, o.Hash, digest, signature, o)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, opts.HashFunc(), digest, signature)
+		}
+		if err != nil {
+			return fmt.Errorf("self-test failed: invalid RSA signature: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, signature) {
+			return fmt.Errorf("self-test failed: invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("self-test failed: unsupported public key type %T", pub)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package apiv1
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSignatureAlgorithm_SignerOpts(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       SignatureAlgorithm
+		want    crypto.SignerOpts
+		wantErr bool
+	}{
+		{"SHA256WithRSA", SHA256WithRSA, crypto.SHA256, false},
+		{"SHA384WithRSA", SHA384WithRSA, crypto.SHA384, false},
+		{"SHA512WithRSA", SHA512WithRSA, crypto.SHA512, false},
+		{"SHA256WithRSAPSS", SHA256WithRSAPSS, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, false},
+		{"SHA384WithRSAPSS", SHA384WithRSAPSS, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, false},
+		{"SHA512WithRSAPSS", SHA512WithRSAPSS, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, false},
+		{"ECDSAWithSHA256", ECDSAWithSHA256, crypto.SHA256, false},
+		{"ECDSAWithSHA384", ECDSAWithSHA384, crypto.SHA384, false},
+		{"ECDSAWithSHA512", ECDSAWithSHA512, crypto.SHA512, false},
+		{"PureEd25519", PureEd25519, crypto.Hash(0), false},
+		{"fail unspecified", UnspecifiedSignAlgorithm, nil, true},
+		{"fail unknown", SignatureAlgorithm(100), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.SignerOpts()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignatureAlgorithm.SignerOpts() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SignatureAlgorithm.SignerOpts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read error") }
+
+type badSigner struct {
+	crypto.Signer
+}
+
+func (badSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("sign error")
+}
+
+func TestSignReader(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 16MiB of data
+	data := make([]byte, 16<<20)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("rsa", func(t *testing.T) {
+		for _, alg := range []SignatureAlgorithm{SHA256WithRSA, SHA384WithRSA, SHA512WithRSA} {
+			opts, err := alg.SignerOpts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := SignReader(rsaKey, bytes.NewReader(data), opts)
+			if err != nil {
+				t.Fatalf("SignReader() error = %v", err)
+			}
+			// RSASSA-PKCS1-v1_5 signatures are deterministic
+			h := opts.HashFunc().New()
+			h.Write(data)
+			want, err := rsaKey.Sign(rand.Reader, h.Sum(nil), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("SignReader() = %x, want %x", got, want)
+			}
+		}
+	})
+
+	t.Run("rsapss", func(t *testing.T) {
+		opts, err := SHA256WithRSAPSS.SignerOpts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SignReader(rsaKey, bytes.NewReader(data), opts)
+		if err != nil {
+			t.Fatalf("SignReader() error = %v", err)
+		}
+		h := crypto.SHA256.New()
+		h.Write(data)
+		if err := rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, h.Sum(nil), got, opts.(*rsa.PSSOptions)); err != nil {
+			t.Errorf("rsa.VerifyPSS() error = %v", err)
+		}
+	})
+
+	t.Run("ecdsa", func(t *testing.T) {
+		got, err := SignReader(ecKey, bytes.NewReader(data), crypto.SHA256)
+		if err != nil {
+			t.Fatalf("SignReader() error = %v", err)
+		}
+		h := crypto.SHA256.New()
+		h.Write(data)
+		if !ecdsa.VerifyASN1(&ecKey.PublicKey, h.Sum(nil), got) {
+			t.Error("ecdsa.VerifyASN1() failed")
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		if _, err := SignReader(edKey, bytes.NewReader(data), crypto.Hash(0)); err == nil {
+			t.Error("SignReader() error = nil, want ed25519 error")
+		}
+		if _, err := SignReader(ecKey, bytes.NewReader(data), crypto.MD4); err == nil {
+			t.Error("SignReader() error = nil, want hash not available error")
+		}
+		if _, err := SignReader(ecKey, errReader{}, crypto.SHA256); err == nil {
+			t.Error("SignReader() error = nil, want read error")
+		}
+		if _, err := SignReader(badSigner{ecKey}, bytes.NewReader(data), crypto.SHA256); err == nil {
+			t.Error("SignReader() error = nil, want sign error")
+		}
+	})
+}
+
+func TestSelfTest(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherEdKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pssOpts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+	type args struct {
+		signer crypto.Signer
+		pub    crypto.PublicKey
+		opts   crypto.SignerOpts
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok rsa", args{rsaKey, rsaKey.Public(), crypto.SHA384}, false},
+		{"ok rsapss", args{rsaKey, rsaKey.Public(), pssOpts}, false},
+		{"ok ecdsa", args{ecKey, ecKey.Public(), crypto.SHA256}, false},
+		{"ok ed25519", args{edKey, edKey.Public(), crypto.Hash(0)}, false},
+		{"fail ecdsa other key", args{otherKey, ecKey.Public(), crypto.SHA256}, true},
+		{"fail rsa other key", args{rsaKey, &rsa.PublicKey{N: ecKey.X, E: 65537}, crypto.SHA256}, true},
+		{"fail ed25519 other key", args{edKey, otherEdKey.Public(), crypto.Hash(0)}, true},
+		{"fail sign", args{badSigner{ecKey}, ecKey.Public(), crypto.SHA256}, true},
+		{"fail hash", args{ecKey, ecKey.Public(), crypto.MD4}, true},
+		{"fail public key", args{ecKey, []byte("foo"), crypto.SHA256}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SelfTest(tt.args.signer, tt.args.pub, tt.args.opts); (err != nil) != tt.wantErr {
+				t.Errorf("SelfTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -146,11 +146,7 @@ func (k *KMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, er
 // CreateKey generates a new key in KMS and returns the public key version
 // of it.
 func (k *KMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
-	if req.Name == "" {
-		return nil, errors.New("createKeyRequest 'name' cannot be empty")
-	}
-
-	keySpec, err := getCustomerMasterKeySpecMapping(req.SignatureAlgorithm, req.Bits)
+	keySpec, err := createKeyParameters(req)
 	if err != nil {
 		return nil, err
 	}
@@ -200,6 +196,26 @@ func (k *KMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse,
 	}, nil
 }
 
+// ValidateCreateKey validates the parameters of the given request without
+// creating the key. It returns the same errors as CreateKey for unsupported
+// algorithms, key sizes and public exponents.
+func (k *KMS) ValidateCreateKey(req *apiv1.CreateKeyRequest) error {
+	_, err := createKeyParameters(req)
+	return err
+}
+
+// createKeyParameters returns the key spec used to create the key in the given
+// request.
+func createKeyParameters(req *apiv1.CreateKeyRequest) (string, error) {
+	if req.Name == "" {
+		return "", errors.New("createKeyRequest 'name' cannot be empty")
+	}
+	if req.PublicExponent != 0 && req.PublicExponent != 65537 {
+		return "", errors.Errorf("awsKMS does not support public exponent %d", req.PublicExponent)
+	}
+	return getCustomerMasterKeySpecMapping(req.SignatureAlgorithm, req.Bits)
+}
+
 func (k *KMS) createKeyAlias(keyID, alias string) error {
 	alias = "alias/" + alias + "-" + keyID[:8]
 
@@ -229,6 +245,14 @@ func (k *KMS) Close() error {
 	return nil
 }
 
+// Capabilities returns the operations supported by the KMS.
+func (k *KMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:    true,
+		CreateSigner: true,
+	}
+}
+
 func defaultContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 15*time.Second)
 }
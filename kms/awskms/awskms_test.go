@@ -209,6 +209,11 @@ func TestKMS_CreateKey(t *testing.T) {
 			SignatureAlgorithm: apiv1.SHA256WithRSA,
 			Bits:               1234,
 		}}, nil, true},
+		{"fail unsupported public exponent", fields{nil, okClient}, args{&apiv1.CreateKeyRequest{
+			Name:               "root",
+			SignatureAlgorithm: apiv1.SHA256WithRSA,
+			PublicExponent:     3,
+		}}, nil, true},
 		{"fail createKey", fields{nil, &MockClient{
 			createKeyWithContext: func(ctx aws.Context, input *kms.CreateKeyInput, opts ...request.Option) (*kms.CreateKeyOutput, error) {
 				return nil, fmt.Errorf("an error")
@@ -258,6 +263,30 @@ func TestKMS_CreateKey(t *testing.T) {
 	}
 }
 
+func TestKMS_ValidateCreateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateKeyRequest
+		wantErr bool
+	}{
+		{"ok", &apiv1.CreateKeyRequest{Name: "root", SignatureAlgorithm: apiv1.ECDSAWithSHA256}, false},
+		{"ok rsa", &apiv1.CreateKeyRequest{Name: "root", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 4096, PublicExponent: 65537}, false},
+		{"fail name", &apiv1.CreateKeyRequest{SignatureAlgorithm: apiv1.ECDSAWithSHA256}, true},
+		{"fail algorithm", &apiv1.CreateKeyRequest{Name: "root", SignatureAlgorithm: apiv1.PureEd25519}, true},
+		{"fail bits", &apiv1.CreateKeyRequest{Name: "root", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}, true},
+		{"fail public exponent", &apiv1.CreateKeyRequest{Name: "root", SignatureAlgorithm: apiv1.SHA256WithRSA, PublicExponent: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The service is not set, ValidateCreateKey must not use it.
+			k := &KMS{}
+			if err := k.ValidateCreateKey(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("KMS.ValidateCreateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestKMS_CreateSigner(t *testing.T) {
 	client := getOKClient()
 	key, err := pemutil.ParseKey([]byte(publicKey))
@@ -11,6 +11,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/pemutil"
 )
 
@@ -60,6 +61,12 @@ func (s *Signer) Public() crypto.PublicKey {
 	return s.publicKey
 }
 
+// JOSEAlgorithm returns the default JOSE signature algorithm for the key of
+// this signer, e.g. ES256 for a P-256 key or RS256 for an RSA key.
+func (s *Signer) JOSEAlgorithm() string {
+	return apiv1.DefaultJOSEAlgorithm(s.publicKey)
+}
+
 // Sign signs digest with the private key stored in the AWS KMS.
 func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	alg, err := getSigningAlgorithm(s.Public(), opts)
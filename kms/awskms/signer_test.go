@@ -3,8 +3,10 @@ package awskms
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"reflect"
@@ -189,3 +191,59 @@ func Test_getSigningAlgorithm(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_JOSEAlgorithm(t *testing.T) {
+	mustDER := func(pub crypto.PublicKey) []byte {
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		want string
+	}{
+		{"P-256", p256.Public(), "ES256"},
+		{"P-384", p384.Public(), "ES384"},
+		{"P-521", p521.Public(), "ES512"},
+		{"RSA", rsaKey.Public(), "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &MockClient{
+				getPublicKeyWithContext: func(ctx aws.Context, input *kms.GetPublicKeyInput, opts ...request.Option) (*kms.GetPublicKeyOutput, error) {
+					return &kms.GetPublicKeyOutput{
+						KeyId:     input.KeyId,
+						PublicKey: mustDER(tt.pub),
+					}, nil
+				},
+			}
+			signer, err := NewSigner(client, "awskms:key-id="+keyID)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			if got := signer.JOSEAlgorithm(); got != tt.want {
+				t.Errorf("Signer.JOSEAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
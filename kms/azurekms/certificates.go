@@ -0,0 +1,379 @@
+//go:build !noazurekms
+// +build !noazurekms
+
+package azurekms
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azcertificates"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+)
+
+// Content types of the secrets backing the Key Vault certificates.
+const (
+	contentTypePEM    = "application/x-pem-file"
+	contentTypePKCS12 = "application/x-pkcs12"
+)
+
+// Status of a certificate operation.
+const (
+	certificateOperationInProgress = "inProgress"
+	certificateOperationCompleted  = "completed"
+)
+
+// certificatePollInterval is the time between requests to check the status of a
+// certificate operation.
+var certificatePollInterval = time.Second
+
+// CertificatesClient is the interface implemented by azcertificates.Client. It
+// will be used for testing purposes.
+type CertificatesClient interface {
+	GetCertificate(ctx context.Context, name string, version string, options *azcertificates.GetCertificateOptions) (azcertificates.GetCertificateResponse, error)
+	CreateCertificate(ctx context.Context, name string, parameters azcertificates.CreateCertificateParameters, options *azcertificates.CreateCertificateOptions) (azcertificates.CreateCertificateResponse, error)
+	GetCertificateOperation(ctx context.Context, name string, options *azcertificates.GetCertificateOperationOptions) (azcertificates.GetCertificateOperationResponse, error)
+}
+
+// CertificatePolicy is the policy used to create a certificate in Azure Key
+// Vault. Certificates are created with the chain and the private key stored
+// in PEM format in the secret with the same name.
+type CertificatePolicy struct {
+	// Subject is the subject of the certificate, e.g. "CN=example.com".
+	Subject string
+	// DNSNames are the DNS subject alternative names of the certificate.
+	DNSNames []string
+	// ValidityInMonths is the validity of the certificate. Key Vault uses 12
+	// months if it's 0.
+	ValidityInMonths int
+	// IssuerName is the name of the issuer of the certificate, "Self" for self
+	// signed certificates, or the name of an issuer configured in the vault.
+	// Defaults to "Self".
+	IssuerName string
+	// SignatureAlgorithm defines the type of the key, an EC P-256 key is used
+	// by default.
+	SignatureAlgorithm apiv1.SignatureAlgorithm
+	// Bits is the size of RSA keys, 3072 by default.
+	Bits int
+	// ProtectionLevel defines if the key is created in an HSM.
+	ProtectionLevel apiv1.ProtectionLevel
+	// Exportable defines if the private key can be exported.
+	Exportable bool
+}
+
+// GetCertificate returns the certificate and the certificate chain stored in
+// Azure Key Vault. The name of the certificate uses the form
+// "azurekms:vault=my-vault;cert=my-cert", and a specific version can be
+// selected using the version parameter.
+//
+// The certificate chain is read from the secret backing the certificate, so it
+// requires permissions to get secrets.
+func (k *KeyVault) GetCertificate(name string) (*x509.Certificate, []*x509.Certificate, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.GetCertificateContext(ctx, name)
+}
+
+// GetCertificateContext returns the certificate and the certificate chain
+// stored in Azure Key Vault using the given context.
+func (k *KeyVault) GetCertificateContext(ctx context.Context, name string) (*x509.Certificate, []*x509.Certificate, error) {
+	if name == "" {
+		return nil, nil, errors.New("getCertificate 'name' cannot be empty")
+	}
+
+	vault, name, version, err := parseCertificateName(name, k.defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k.getCertificate(ctx, vault, name, version)
+}
+
+// GetCertificateChain returns the certificate chain of the certificate
+// associated with a key in Azure Key Vault. Key Vault creates a key with the
+// same name and version for each certificate, so the name uses the form of the
+// keys, "azurekms:vault=my-vault;name=my-key", and a specific version can be
+// selected using the version parameter.
+//
+// The chain starts with the certificate of the key, followed by the
+// intermediates, so it can be used in an x5c header. Like GetCertificate, it
+// requires permissions to get secrets.
+func (k *KeyVault) GetCertificateChain(name string) ([]*x509.Certificate, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.GetCertificateChainContext(ctx, name)
+}
+
+// GetCertificateChainContext returns the certificate chain of the certificate
+// associated with a key in Azure Key Vault using the given context.
+func (k *KeyVault) GetCertificateChainContext(ctx context.Context, name string) ([]*x509.Certificate, error) {
+	if name == "" {
+		return nil, errors.New("getCertificateChain 'name' cannot be empty")
+	}
+
+	vault, name, version, _, err := parseKeyName(name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, chain, err := k.getCertificate(ctx, vault, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*x509.Certificate{cert}, chain...), nil
+}
+
+// getCertificate returns the certificate with the given name and version, and
+// the intermediates in the secret backing it.
+func (k *KeyVault) getCertificate(ctx context.Context, vault, name, version string) (*x509.Certificate, []*x509.Certificate, error) {
+	client, err := k.certificates.Get(vault)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.GetCertificate(ctx, name, version, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+	}
+	cert, err := x509.ParseCertificate(resp.CER)
+	if err != nil {
+		return nil, nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+	}
+	if resp.SID == nil {
+		return nil, nil, errors.New("keyVault GetCertificate failed: certificate does not contain a secret id")
+	}
+
+	chain, err := k.getCertificateChain(ctx, vault, cert, *resp.SID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, chain, nil
+}
+
+// getCertificateChain returns the intermediates in the secret backing a
+// certificate.
+func (k *KeyVault) getCertificateChain(ctx context.Context, vault string, leaf *x509.Certificate, secretID string) ([]*x509.Certificate, error) {
+	// The secret id has the form https://vault.dns.suffix/secrets/name/version
+	u, err := url.Parse(secretID)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "secrets" {
+		return nil, errors.Errorf("keyVault GetCertificate failed: invalid secret id %q", secretID)
+	}
+
+	client, err := k.secrets.Get(vault)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.GetSecret(ctx, parts[1], parts[2], nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetSecret failed")
+	}
+	if resp.Value == nil {
+		return nil, errors.New("keyVault GetSecret failed: secret does not contain a value")
+	}
+
+	var certs []*x509.Certificate
+	contentType := ""
+	if resp.ContentType != nil {
+		contentType = *resp.ContentType
+	}
+	switch contentType {
+	case contentTypePEM:
+		if certs, err = pemutil.ParseCertificateBundle(bytes.TrimSpace([]byte(*resp.Value))); err != nil {
+			return nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+		}
+	case contentTypePKCS12:
+		if certs, err = parsePKCS12Certificates(*resp.Value); err != nil {
+			return nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+		}
+	default:
+		return nil, errors.Errorf("keyVault GetCertificate failed: unsupported content type %q", contentType)
+	}
+
+	var chain []*x509.Certificate
+	for _, c := range certs {
+		if !c.Equal(leaf) {
+			chain = append(chain, c)
+		}
+	}
+	return chain, nil
+}
+
+// parsePKCS12Certificates returns the certificates in a base64 encoded PKCS #12
+// without password.
+func parsePKCS12Certificates(s string) ([]*x509.Certificate, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	_, certs, err := pemutil.ParsePKCS12(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// CreateCertificate creates a new certificate in Azure Key Vault using the
+// given policy, and returns the issued certificate. The name of the
+// certificate uses the form "azurekms:vault=my-vault;cert=my-cert".
+//
+// Key Vault issues the certificates asynchronously, CreateCertificate waits
+// until the certificate is issued, so it can only be used with the self signed
+// certificates or the issuers that complete the request in the default timeout.
+// Use CreateCertificateContext to define a different timeout.
+func (k *KeyVault) CreateCertificate(name string, policy *CertificatePolicy) (*x509.Certificate, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.CreateCertificateContext(ctx, name, policy)
+}
+
+// CreateCertificateContext creates a new certificate in Azure Key Vault using
+// the given context and policy, and returns the issued certificate.
+func (k *KeyVault) CreateCertificateContext(ctx context.Context, name string, policy *CertificatePolicy) (*x509.Certificate, error) {
+	if name == "" {
+		return nil, errors.New("createCertificate 'name' cannot be empty")
+	}
+	if policy == nil {
+		return nil, errors.New("createCertificate 'policy' cannot be nil")
+	}
+
+	vault, name, _, err := parseCertificateName(name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+	params, err := newCertificateParameters(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k.certificates.Get(vault)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.CreateCertificate(ctx, name, params, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault CreateCertificate failed")
+	}
+	op := resp.CertificateOperation
+	for getStatus(op) == certificateOperationInProgress {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "keyVault CreateCertificate failed")
+		case <-time.After(certificatePollInterval):
+		}
+		resp, err := client.GetCertificateOperation(ctx, name, nil)
+		if err != nil {
+			return nil, errors.Wrap(convertError(err), "keyVault CreateCertificate failed")
+		}
+		op = resp.CertificateOperation
+	}
+	if status := getStatus(op); status != certificateOperationCompleted {
+		var msg string
+		switch {
+		case op.Error != nil && op.Error.Message != nil:
+			msg = *op.Error.Message
+		case op.StatusDetails != nil:
+			msg = *op.StatusDetails
+		}
+		return nil, errors.Errorf("keyVault CreateCertificate failed: certificate operation is %s: %s", status, msg)
+	}
+
+	bundle, err := client.GetCertificate(ctx, name, "", nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+	}
+	cert, err := x509.ParseCertificate(bundle.CER)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetCertificate failed")
+	}
+	return cert, nil
+}
+
+// getStatus returns the status of a certificate operation.
+func getStatus(op azcertificates.CertificateOperation) string {
+	if op.Status == nil {
+		return ""
+	}
+	return *op.Status
+}
+
+// newCertificateParameters returns the parameters used to create a certificate
+// with the given policy. The certificate chain and the private key are stored
+// in PEM format in the secret backing the certificate.
+func newCertificateParameters(p *CertificatePolicy) (azcertificates.CreateCertificateParameters, error) {
+	var params azcertificates.CreateCertificateParameters
+	kt, ok := signatureAlgorithmMapping[p.SignatureAlgorithm]
+	if !ok {
+		return params, apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support signature algorithm %q", p.SignatureAlgorithm)}
+	}
+
+	keyType := azcertificates.JSONWebKeyType(kt.KeyType(p.ProtectionLevel))
+	keyProps := &azcertificates.KeyProperties{
+		Exportable: pointer(p.Exportable),
+		KeyType:    &keyType,
+		ReuseKey:   &valueFalse,
+	}
+	if kt.Kty == azkeys.JSONWebKeyTypeRSA || kt.Kty == azkeys.JSONWebKeyTypeRSAHSM {
+		switch p.Bits {
+		case 2048:
+			keyProps.KeySize = &value2048
+		case 0, 3072:
+			keyProps.KeySize = &value3072
+		case 4096:
+			keyProps.KeySize = &value4096
+		default:
+			return params, errors.Errorf("keyVault does not support key size %d", p.Bits)
+		}
+	} else {
+		keyProps.Curve = pointer(azcertificates.JSONWebKeyCurveName(kt.Curve))
+	}
+
+	x509Props := &azcertificates.X509CertificateProperties{
+		Subject: pointer(p.Subject),
+	}
+	if len(p.DNSNames) > 0 {
+		sans := new(azcertificates.SubjectAlternativeNames)
+		for _, name := range p.DNSNames {
+			sans.DNSNames = append(sans.DNSNames, pointer(name))
+		}
+		x509Props.SubjectAlternativeNames = sans
+	}
+	if p.ValidityInMonths > 0 {
+		x509Props.ValidityInMonths = pointer(int32(p.ValidityInMonths))
+	}
+
+	issuerName := p.IssuerName
+	if issuerName == "" {
+		issuerName = "Self"
+	}
+
+	params.CertificatePolicy = &azcertificates.CertificatePolicy{
+		KeyProperties: keyProps,
+		SecretProperties: &azcertificates.SecretProperties{
+			ContentType: pointer(contentTypePEM),
+		},
+		X509CertificateProperties: x509Props,
+		IssuerParameters: &azcertificates.IssuerParameters{
+			Name: &issuerName,
+		},
+	}
+	return params, nil
+}
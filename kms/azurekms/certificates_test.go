@@ -0,0 +1,409 @@
+//go:build !noazurekms
+// +build !noazurekms
+
+package azurekms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azcertificates"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/minica"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type fakeCertificatesClient struct {
+	certificates map[string]azcertificates.CertificateBundle
+	operations   []azcertificates.CertificateOperation
+	params       *azcertificates.CreateCertificateParameters
+	err          error
+}
+
+func (c *fakeCertificatesClient) GetCertificate(ctx context.Context, name, version string, options *azcertificates.GetCertificateOptions) (azcertificates.GetCertificateResponse, error) {
+	if c.err != nil {
+		return azcertificates.GetCertificateResponse{}, c.err
+	}
+	if version != "" {
+		name = name + "/" + version
+	}
+	v, ok := c.certificates[name]
+	if !ok {
+		return azcertificates.GetCertificateResponse{}, &azcore.ResponseError{StatusCode: 404}
+	}
+	return azcertificates.GetCertificateResponse{CertificateBundle: v}, nil
+}
+
+func (c *fakeCertificatesClient) CreateCertificate(ctx context.Context, name string, parameters azcertificates.CreateCertificateParameters, options *azcertificates.CreateCertificateOptions) (azcertificates.CreateCertificateResponse, error) {
+	if c.err != nil {
+		return azcertificates.CreateCertificateResponse{}, c.err
+	}
+	c.params = &parameters
+	resp, err := c.GetCertificateOperation(ctx, name, nil)
+	return azcertificates.CreateCertificateResponse(resp), err
+}
+
+func (c *fakeCertificatesClient) GetCertificateOperation(ctx context.Context, name string, options *azcertificates.GetCertificateOperationOptions) (azcertificates.GetCertificateOperationResponse, error) {
+	if c.err != nil {
+		return azcertificates.GetCertificateOperationResponse{}, c.err
+	}
+	if len(c.operations) == 0 {
+		return azcertificates.GetCertificateOperationResponse{}, &azcore.ResponseError{StatusCode: 404}
+	}
+	op := c.operations[0]
+	c.operations = c.operations[1:]
+	return azcertificates.GetCertificateOperationResponse{CertificateOperation: op}, nil
+}
+
+type fakeCertSecretsClient struct {
+	secrets map[string]azsecrets.GetSecretResponse
+}
+
+func (c *fakeCertSecretsClient) GetSecret(ctx context.Context, name, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	v, ok := c.secrets[name+"/"+version]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: 404}
+	}
+	return v, nil
+}
+
+func mustCertificate(t *testing.T, ca *minica.CA, cn string) *x509.Certificate {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: cn},
+		DNSNames:  []string{cn},
+		PublicKey: signer.Public(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func secretResponse(contentType, value string) azsecrets.GetSecretResponse {
+	return azsecrets.GetSecretResponse{
+		SecretBundle: azsecrets.SecretBundle{ContentType: &contentType, Value: &value},
+	}
+}
+
+func TestKeyVault_GetCertificate(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := mustCertificate(t, ca, "leaf.example.com")
+	chainPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Intermediate.Raw})) + "\n"
+
+	// Key Vault stores PKCS #12 secrets without password.
+	p12Key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12Leaf, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "p12.example.com"},
+		DNSNames:  []string{"p12.example.com"},
+		PublicKey: p12Key.Public(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := pkcs12.Encode(rand.Reader, p12Key, p12Leaf, []*x509.Certificate{ca.Intermediate}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificates := newLazyCertificatesClient("vault.azure.net", func(vaultURL string) (CertificatesClient, error) {
+		switch vaultURL {
+		case "https://fail.vault.azure.net/":
+			return nil, errTest
+		case "https://fail-client.vault.azure.net/":
+			return &fakeCertificatesClient{err: errTest}, nil
+		default:
+			return &fakeCertificatesClient{certificates: map[string]azcertificates.CertificateBundle{
+				"my-cert":            {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/my-cert/v2")},
+				"my-cert/v1":         {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/my-cert/v1")},
+				"bad-cert":           {CER: []byte("not a certificate")},
+				"no-sid":             {CER: leaf.Raw},
+				"p12-cert":           {CER: p12Leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/p12-cert/v1")},
+				"bad-sid":            {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/keys/my-cert")},
+				"missing-secret":     {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/missing/v1")},
+				"no-value":           {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/no-value/v1")},
+				"bad-content-type":   {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/bad-content-type/v1")},
+				"bad-pem":            {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/bad-pem/v1")},
+				"bad-pkcs12":         {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/bad-pkcs12/v1")},
+				"bad-pkcs12-content": {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/bad-pkcs12-content/v1")},
+			}}, nil
+		}
+	})
+	secrets := newLazySecretsClient("vault.azure.net", func(vaultURL string) (SecretsClient, error) {
+		return &fakeCertSecretsClient{secrets: map[string]azsecrets.GetSecretResponse{
+			"my-cert/v2":            secretResponse(contentTypePEM, chainPEM),
+			"my-cert/v1":            secretResponse(contentTypePEM, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))),
+			"no-value/v1":           {},
+			"p12-cert/v1":           secretResponse(contentTypePKCS12, base64.StdEncoding.EncodeToString(p12)),
+			"bad-content-type/v1":   secretResponse("text/plain", chainPEM),
+			"bad-pem/v1":            secretResponse(contentTypePEM, "not a pem"),
+			"bad-pkcs12/v1":         secretResponse(contentTypePKCS12, "not base64"),
+			"bad-pkcs12-content/v1": secretResponse(contentTypePKCS12, "bm90IGEgcGtjczEy"),
+		}}, nil
+	})
+
+	type fields struct {
+		defaults defaultOptions
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		cert      string
+		want      *x509.Certificate
+		wantChain []*x509.Certificate
+		wantErr   bool
+	}{
+		{"ok", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=my-cert", leaf, []*x509.Certificate{ca.Intermediate}, false},
+		{"ok with version", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=my-cert?version=v1", leaf, nil, false},
+		{"ok with default vault", fields{defaultOptions{Vault: "my-vault"}}, "azurekms:cert=my-cert", leaf, []*x509.Certificate{ca.Intermediate}, false},
+		{"ok pkcs12", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=p12-cert", p12Leaf, []*x509.Certificate{ca.Intermediate}, false},
+		{"fail empty", fields{defaultOptions{}}, "", nil, nil, true},
+		{"fail parse", fields{defaultOptions{}}, "azurekms:vault=my-vault;name=my-cert", nil, nil, true},
+		{"fail no vault", fields{defaultOptions{}}, "azurekms:cert=my-cert", nil, nil, true},
+		{"fail vault", fields{defaultOptions{}}, "azurekms:vault=fail;cert=my-cert", nil, nil, true},
+		{"fail GetCertificate", fields{defaultOptions{}}, "azurekms:vault=fail-client;cert=my-cert", nil, nil, true},
+		{"fail not found", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=not-found", nil, nil, true},
+		{"fail bad certificate", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-cert", nil, nil, true},
+		{"fail no secret id", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=no-sid", nil, nil, true},
+		{"fail bad secret id", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-sid", nil, nil, true},
+		{"fail missing secret", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=missing-secret", nil, nil, true},
+		{"fail no value", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=no-value", nil, nil, true},
+		{"fail content type", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-content-type", nil, nil, true},
+		{"fail bad pem", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-pem", nil, nil, true},
+		{"fail bad pkcs12", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-pkcs12", nil, nil, true},
+		{"fail bad pkcs12 content", fields{defaultOptions{}}, "azurekms:vault=my-vault;cert=bad-pkcs12-content", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				secrets:      secrets,
+				certificates: certificates,
+				defaults:     tt.fields.defaults,
+			}
+			got, gotChain, err := k.GetCertificate(tt.cert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.GetCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.GetCertificate() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotChain, tt.wantChain) {
+				t.Errorf("KeyVault.GetCertificate() gotChain = %v, want %v", gotChain, tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestKeyVault_GetCertificateChain(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := mustCertificate(t, ca, "leaf.example.com")
+	chainPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Intermediate.Raw}))
+
+	certificates := newLazyCertificatesClient("vault.azure.net", func(vaultURL string) (CertificatesClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return &fakeCertificatesClient{certificates: map[string]azcertificates.CertificateBundle{
+			"my-key":    {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/my-key/v2")},
+			"my-key/v1": {CER: leaf.Raw, SID: pointer("https://my-vault.vault.azure.net/secrets/my-key/v1")},
+		}}, nil
+	})
+	secrets := newLazySecretsClient("vault.azure.net", func(vaultURL string) (SecretsClient, error) {
+		return &fakeCertSecretsClient{secrets: map[string]azsecrets.GetSecretResponse{
+			"my-key/v2": secretResponse(contentTypePEM, chainPEM),
+			"my-key/v1": secretResponse(contentTypePEM, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))),
+		}}, nil
+	})
+
+	tests := []struct {
+		name     string
+		defaults defaultOptions
+		key      string
+		want     []*x509.Certificate
+		wantErr  bool
+	}{
+		{"ok", defaultOptions{}, "azurekms:vault=my-vault;name=my-key", []*x509.Certificate{leaf, ca.Intermediate}, false},
+		{"ok with version", defaultOptions{}, "azurekms:vault=my-vault;name=my-key?version=v1", []*x509.Certificate{leaf}, false},
+		{"ok with default vault", defaultOptions{Vault: "my-vault"}, "azurekms:name=my-key", []*x509.Certificate{leaf, ca.Intermediate}, false},
+		{"fail empty", defaultOptions{}, "", nil, true},
+		{"fail parse", defaultOptions{}, "azurekms:vault=my-vault;cert=my-key", nil, true},
+		{"fail vault", defaultOptions{}, "azurekms:vault=fail;name=my-key", nil, true},
+		{"fail not found", defaultOptions{}, "azurekms:vault=my-vault;name=not-found", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				secrets:      secrets,
+				certificates: certificates,
+				defaults:     tt.defaults,
+			}
+			got, err := k.GetCertificateChain(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.GetCertificateChain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.GetCertificateChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_CreateCertificate(t *testing.T) {
+	old := certificatePollInterval
+	certificatePollInterval = time.Millisecond
+	t.Cleanup(func() {
+		certificatePollInterval = old
+	})
+
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := mustCertificate(t, ca, "leaf.example.com")
+
+	inProgress := azcertificates.CertificateOperation{Status: pointer(certificateOperationInProgress)}
+	completed := azcertificates.CertificateOperation{Status: pointer(certificateOperationCompleted)}
+	bundles := map[string]azcertificates.CertificateBundle{
+		"my-cert": {CER: leaf.Raw},
+	}
+	policy := &CertificatePolicy{
+		Subject:  "CN=leaf.example.com",
+		DNSNames: []string{"leaf.example.com"},
+	}
+
+	type args struct {
+		name   string
+		policy *CertificatePolicy
+	}
+	tests := []struct {
+		name    string
+		client  *fakeCertificatesClient
+		args    args
+		want    *x509.Certificate
+		wantErr bool
+	}{
+		{"ok", &fakeCertificatesClient{certificates: bundles, operations: []azcertificates.CertificateOperation{completed}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, leaf, false},
+		{"ok in progress", &fakeCertificatesClient{certificates: bundles, operations: []azcertificates.CertificateOperation{inProgress, inProgress, completed}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, leaf, false},
+		{"fail empty", &fakeCertificatesClient{}, args{"", policy}, nil, true},
+		{"fail nil policy", &fakeCertificatesClient{}, args{"azurekms:vault=my-vault;cert=my-cert", nil}, nil, true},
+		{"fail parse", &fakeCertificatesClient{}, args{"azurekms:vault=my-vault;name=my-cert", policy}, nil, true},
+		{"fail signature algorithm", &fakeCertificatesClient{}, args{"azurekms:vault=my-vault;cert=my-cert", &CertificatePolicy{SignatureAlgorithm: apiv1.PureEd25519}}, nil, true},
+		{"fail CreateCertificate", &fakeCertificatesClient{err: errTest}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, nil, true},
+		{"fail GetCertificateOperation", &fakeCertificatesClient{operations: []azcertificates.CertificateOperation{inProgress}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, nil, true},
+		{"fail operation", &fakeCertificatesClient{operations: []azcertificates.CertificateOperation{{Status: pointer("failed"), Error: &azcertificates.Error{Code: pointer("BadParameter"), Message: pointer("bad parameter")}}}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, nil, true},
+		{"fail cancelled", &fakeCertificatesClient{operations: []azcertificates.CertificateOperation{{Status: pointer("cancelled")}}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, nil, true},
+		{"fail GetCertificate", &fakeCertificatesClient{operations: []azcertificates.CertificateOperation{completed}}, args{"azurekms:vault=my-vault;cert=my-cert", policy}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				certificates: newLazyCertificatesClient("vault.azure.net", func(vaultURL string) (CertificatesClient, error) {
+					return tt.client, nil
+				}),
+			}
+			got, err := k.CreateCertificate(tt.args.name, tt.args.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.CreateCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.CreateCertificate() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr {
+				want, err := newCertificateParameters(tt.args.policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(tt.client.params, &want) {
+					t.Errorf("CertificatesClient.CreateCertificate() parameters = %v, want %v", tt.client.params, &want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyVault_CreateCertificateContext_timeout(t *testing.T) {
+	old := certificatePollInterval
+	certificatePollInterval = time.Minute
+	t.Cleanup(func() {
+		certificatePollInterval = old
+	})
+
+	k := &KeyVault{
+		certificates: newLazyCertificatesClient("vault.azure.net", func(vaultURL string) (CertificatesClient, error) {
+			return &fakeCertificatesClient{operations: []azcertificates.CertificateOperation{{Status: pointer(certificateOperationInProgress)}}}, nil
+		}),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := k.CreateCertificateContext(ctx, "azurekms:vault=my-vault;cert=my-cert", &CertificatePolicy{}); err == nil {
+		t.Error("KeyVault.CreateCertificateContext() error = nil, want context error")
+	}
+}
+
+func Test_newCertificateParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *CertificatePolicy
+		want    string
+		wantErr bool
+	}{
+		{"ok default", &CertificatePolicy{Subject: "CN=example.com"},
+			`{"policy":{"issuer":{"name":"Self"},"key_props":{"crv":"P-256","exportable":false,"kty":"EC","reuse_key":false},"secret_props":{"contentType":"application/x-pem-file"},"x509_props":{"subject":"CN=example.com"}}}`, false},
+		{"ok rsa", &CertificatePolicy{Subject: "CN=example.com", SignatureAlgorithm: apiv1.SHA256WithRSA, Exportable: true, IssuerName: "my-issuer", ValidityInMonths: 6},
+			`{"policy":{"issuer":{"name":"my-issuer"},"key_props":{"exportable":true,"key_size":3072,"kty":"RSA","reuse_key":false},"secret_props":{"contentType":"application/x-pem-file"},"x509_props":{"subject":"CN=example.com","validity_months":6}}}`, false},
+		{"ok rsa hsm", &CertificatePolicy{Subject: "CN=example.com", SignatureAlgorithm: apiv1.SHA256WithRSAPSS, Bits: 4096, ProtectionLevel: apiv1.HSM},
+			`{"policy":{"issuer":{"name":"Self"},"key_props":{"exportable":false,"key_size":4096,"kty":"RSA-HSM","reuse_key":false},"secret_props":{"contentType":"application/x-pem-file"},"x509_props":{"subject":"CN=example.com"}}}`, false},
+		{"ok ec dns names", &CertificatePolicy{Subject: "CN=example.com", DNSNames: []string{"example.com", "www.example.com"}, SignatureAlgorithm: apiv1.ECDSAWithSHA384},
+			`{"policy":{"issuer":{"name":"Self"},"key_props":{"crv":"P-384","exportable":false,"kty":"EC","reuse_key":false},"secret_props":{"contentType":"application/x-pem-file"},"x509_props":{"sans":{"dns_names":["example.com","www.example.com"]},"subject":"CN=example.com"}}}`, false},
+		{"fail signature algorithm", &CertificatePolicy{SignatureAlgorithm: apiv1.PureEd25519}, "", true},
+		{"fail bits", &CertificatePolicy{SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCertificateParameters(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCertificateParameters() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("newCertificateParameters() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+//go:build !noazurekms
+// +build !noazurekms
+
+package azurekms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+// encryptionAlgorithmMapping maps the encryption algorithms to the algorithms
+// used in the Azure Key Vault decrypt operation.
+var encryptionAlgorithmMapping = map[apiv1.EncryptionAlgorithm]azkeys.JSONWebKeyEncryptionAlgorithm{
+	apiv1.UnspecifiedEncryptionAlgorithm: azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256,
+	apiv1.RSAOAEPWithSHA1:                azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP,
+	apiv1.RSAOAEPWithSHA256:              azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256,
+	apiv1.RSAPKCS1v15:                    azkeys.JSONWebKeyEncryptionAlgorithmRSA15,
+}
+
+// Decrypter implements a crypto.Decrypter using the Azure Key Vault.
+type Decrypter struct {
+	client        KeyVaultClient
+	name          string
+	version       string
+	publicKey     crypto.PublicKey
+	algorithm     apiv1.EncryptionAlgorithm
+	allowPKCS1v15 bool
+}
+
+// NewDecrypter creates a new decrypter using an RSA key in the Azure Key
+// Vault. The given algorithm is used if the options passed to Decrypt do not
+// select one, and RSA1_5 is only allowed if allowPKCS1v15 is true.
+func NewDecrypter(lazyClient *lazyClient, decryptionKey string, defaults defaultOptions, alg apiv1.EncryptionAlgorithm, allowPKCS1v15 bool) (crypto.Decrypter, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return newDecrypter(ctx, lazyClient, decryptionKey, defaults, alg, allowPKCS1v15)
+}
+
+func newDecrypter(ctx context.Context, lazyClient *lazyClient, decryptionKey string, defaults defaultOptions, alg apiv1.EncryptionAlgorithm, allowPKCS1v15 bool) (crypto.Decrypter, error) {
+	// Fail early if the default algorithm is not valid.
+	if _, err := getEncryptionAlgorithm(nil, alg, allowPKCS1v15); err != nil {
+		return nil, err
+	}
+
+	vaultURL, name, version, _, err := parseKeyName(decryptionKey, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := lazyClient.Get(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure that the key exists and it is an RSA key.
+	decrypter := &Decrypter{
+		client:        client,
+		name:          name,
+		version:       version,
+		algorithm:     alg,
+		allowPKCS1v15: allowPKCS1v15,
+	}
+	if err := decrypter.preloadKey(ctx); err != nil {
+		return nil, err
+	}
+
+	return decrypter, nil
+}
+
+func (d *Decrypter) preloadKey(ctx context.Context) error {
+	resp, err := d.client.GetKey(ctx, d.name, d.version, nil)
+	if err != nil {
+		return errors.Wrap(convertError(err), "keyVault GetKey failed")
+	}
+
+	d.publicKey, err = convertKey(resp.Key)
+	if err != nil {
+		return err
+	}
+	if _, ok := d.publicKey.(*rsa.PublicKey); !ok {
+		return errors.Errorf("keyVault key %q is not an RSA key", d.name)
+	}
+	return nil
+}
+
+// Public returns the public key of this decrypter.
+func (d *Decrypter) Public() crypto.PublicKey {
+	return d.publicKey
+}
+
+// Decrypt decrypts ciphertext with the private key stored in the Azure Key
+// Vault.
+//
+// The algorithm is selected using opts: an *rsa.OAEPOptions with crypto.SHA1
+// uses RSA-OAEP, with crypto.SHA256 uses RSA-OAEP-256, and an
+// *rsa.PKCS1v15DecryptOptions uses RSA1_5. If opts is nil, the algorithm of
+// the decrypter is used, RSA-OAEP-256 by default.
+func (d *Decrypter) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return d.DecryptContext(ctx, rand, ciphertext, opts)
+}
+
+// DecryptContext decrypts ciphertext with the private key stored in the Azure
+// Key Vault using the given context.
+func (d *Decrypter) DecryptContext(ctx context.Context, rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	alg, err := getEncryptionAlgorithm(opts, d.algorithm, d.allowPKCS1v15)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Decrypt(ctx, d.name, d.version, azkeys.KeyOperationsParameters{
+		Algorithm: &alg,
+		Value:     ciphertext,
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault Decrypt failed")
+	}
+	return resp.Result, nil
+}
+
+// getEncryptionAlgorithm returns the Azure algorithm for the given options. If
+// opts is nil, the default algorithm is used. RSA1_5 is rejected unless
+// allowPKCS1v15 is true, as RSAES-PKCS1-v1_5 is vulnerable to padding oracle
+// attacks.
+func getEncryptionAlgorithm(opts crypto.DecrypterOpts, def apiv1.EncryptionAlgorithm, allowPKCS1v15 bool) (azkeys.JSONWebKeyEncryptionAlgorithm, error) {
+	alg := def
+	switch o := opts.(type) {
+	case nil:
+	case *rsa.OAEPOptions:
+		if len(o.Label) > 0 {
+			return "", errors.New("keyVault does not support RSA-OAEP labels")
+		}
+		switch o.Hash {
+		case crypto.SHA1:
+			alg = apiv1.RSAOAEPWithSHA1
+		case crypto.SHA256:
+			alg = apiv1.RSAOAEPWithSHA256
+		default:
+			return "", apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support RSA-OAEP with hash function %v", o.Hash)}
+		}
+	case *rsa.PKCS1v15DecryptOptions:
+		alg = apiv1.RSAPKCS1v15
+	default:
+		return "", errors.Errorf("unsupported decrypter options %T", opts)
+	}
+
+	if alg == apiv1.RSAPKCS1v15 && !allowPKCS1v15 {
+		return "", apiv1.UnsupportedAlgorithmError{Message: "keyVault decryption using RSA1_5 is not allowed"}
+	}
+
+	v, ok := encryptionAlgorithmMapping[alg]
+	if !ok {
+		return "", apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support encryption algorithm '%s'", alg)}
+	}
+	return v, nil
+}
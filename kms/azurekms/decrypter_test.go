@@ -0,0 +1,201 @@
+package azurekms
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/golang/mock/gomock"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+func TestNewDecrypter(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: createJWK(t, key.Public())},
+	}, nil).Times(2)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "my-version", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: createJWK(t, key.Public())},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "ec-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: createJWK(t, ecKey.Public())},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "not-found", "", nil).Return(azkeys.GetKeyResponse{}, errTest)
+
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var noOptions defaultOptions
+	type args struct {
+		decryptionKey string
+		alg           apiv1.EncryptionAlgorithm
+		allowPKCS1v15 bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    crypto.Decrypter
+		wantErr bool
+	}{
+		{"ok", args{"azurekms:vault=my-vault;name=my-key", apiv1.UnspecifiedEncryptionAlgorithm, false}, &Decrypter{
+			client:    m,
+			name:      "my-key",
+			publicKey: &key.PublicKey,
+		}, false},
+		{"ok with version", args{"azurekms:vault=my-vault;name=my-key?version=my-version", apiv1.RSAOAEPWithSHA1, false}, &Decrypter{
+			client:    m,
+			name:      "my-key",
+			version:   "my-version",
+			publicKey: &key.PublicKey,
+			algorithm: apiv1.RSAOAEPWithSHA1,
+		}, false},
+		{"ok RSA1_5", args{"azurekms:vault=my-vault;name=my-key", apiv1.RSAPKCS1v15, true}, &Decrypter{
+			client:        m,
+			name:          "my-key",
+			publicKey:     &key.PublicKey,
+			algorithm:     apiv1.RSAPKCS1v15,
+			allowPKCS1v15: true,
+		}, false},
+		{"fail RSA1_5", args{"azurekms:vault=my-vault;name=my-key", apiv1.RSAPKCS1v15, false}, nil, true},
+		{"fail algorithm", args{"azurekms:vault=my-vault;name=my-key", apiv1.EncryptionAlgorithm(100), false}, nil, true},
+		{"fail key type", args{"azurekms:vault=my-vault;name=ec-key", apiv1.UnspecifiedEncryptionAlgorithm, false}, nil, true},
+		{"fail GetKey", args{"azurekms:vault=my-vault;name=not-found", apiv1.UnspecifiedEncryptionAlgorithm, false}, nil, true},
+		{"fail get client", args{"azurekms:vault=fail;name=my-key", apiv1.UnspecifiedEncryptionAlgorithm, false}, nil, true},
+		{"fail scheme", args{"kms:vault=my-vault;name=my-key", apiv1.UnspecifiedEncryptionAlgorithm, false}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDecrypter(client, tt.args.decryptionKey, noOptions, tt.args.alg, tt.args.allowPKCS1v15)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewDecrypter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDecrypter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecrypter_Decrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("the-plaintext")
+
+	m := mockClient(t)
+	expectDecrypt := func(alg azkeys.JSONWebKeyEncryptionAlgorithm, ciphertext []byte) {
+		m.EXPECT().Decrypt(gomock.Any(), "my-key", "", azkeys.KeyOperationsParameters{
+			Algorithm: &alg,
+			Value:     ciphertext,
+		}, nil).Return(azkeys.DecryptResponse{
+			KeyOperationResult: azkeys.KeyOperationResult{Result: plaintext},
+		}, nil)
+	}
+	expectDecrypt(azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256, []byte("default"))
+	expectDecrypt(azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP, []byte("decrypter-default"))
+	expectDecrypt(azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP, []byte("oaep-sha1"))
+	expectDecrypt(azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256, []byte("oaep-sha256"))
+	expectDecrypt(azkeys.JSONWebKeyEncryptionAlgorithmRSA15, []byte("pkcs1v15"))
+	m.EXPECT().Decrypt(gomock.Any(), "my-key", "", gomock.Any(), nil).Return(azkeys.DecryptResponse{}, errTest)
+
+	type fields struct {
+		algorithm     apiv1.EncryptionAlgorithm
+		allowPKCS1v15 bool
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		ciphertext []byte
+		opts       crypto.DecrypterOpts
+		want       []byte
+		wantErr    bool
+	}{
+		{"ok default", fields{}, []byte("default"), nil, plaintext, false},
+		{"ok decrypter default", fields{apiv1.RSAOAEPWithSHA1, false}, []byte("decrypter-default"), nil, plaintext, false},
+		{"ok OAEP SHA1", fields{}, []byte("oaep-sha1"), &rsa.OAEPOptions{Hash: crypto.SHA1}, plaintext, false},
+		{"ok OAEP SHA256", fields{apiv1.RSAOAEPWithSHA1, false}, []byte("oaep-sha256"), &rsa.OAEPOptions{Hash: crypto.SHA256}, plaintext, false},
+		{"ok PKCS1v15", fields{allowPKCS1v15: true}, []byte("pkcs1v15"), &rsa.PKCS1v15DecryptOptions{}, plaintext, false},
+		{"fail PKCS1v15", fields{}, []byte("pkcs1v15"), &rsa.PKCS1v15DecryptOptions{}, nil, true},
+		{"fail OAEP SHA384", fields{}, []byte("oaep-sha384"), &rsa.OAEPOptions{Hash: crypto.SHA384}, nil, true},
+		{"fail OAEP label", fields{}, []byte("oaep-label"), &rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")}, nil, true},
+		{"fail Decrypt", fields{}, []byte("fail"), nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Decrypter{
+				client:        m,
+				name:          "my-key",
+				publicKey:     &key.PublicKey,
+				algorithm:     tt.fields.algorithm,
+				allowPKCS1v15: tt.fields.allowPKCS1v15,
+			}
+			got, err := d.Decrypt(rand.Reader, tt.ciphertext, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decrypter.Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decrypter.Decrypt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getEncryptionAlgorithm(t *testing.T) {
+	type args struct {
+		opts          crypto.DecrypterOpts
+		def           apiv1.EncryptionAlgorithm
+		allowPKCS1v15 bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    azkeys.JSONWebKeyEncryptionAlgorithm
+		wantErr bool
+	}{
+		{"ok unspecified", args{nil, apiv1.UnspecifiedEncryptionAlgorithm, false}, azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256, false},
+		{"ok RSA-OAEP", args{nil, apiv1.RSAOAEPWithSHA1, false}, azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP, false},
+		{"ok RSA-OAEP-256", args{nil, apiv1.RSAOAEPWithSHA256, false}, azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256, false},
+		{"ok RSA1_5", args{nil, apiv1.RSAPKCS1v15, true}, azkeys.JSONWebKeyEncryptionAlgorithmRSA15, false},
+		{"ok OAEPOptions SHA1", args{&rsa.OAEPOptions{Hash: crypto.SHA1}, apiv1.RSAOAEPWithSHA256, false}, azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP, false},
+		{"ok OAEPOptions SHA256", args{&rsa.OAEPOptions{Hash: crypto.SHA256}, apiv1.RSAPKCS1v15, false}, azkeys.JSONWebKeyEncryptionAlgorithmRSAOAEP256, false},
+		{"ok PKCS1v15DecryptOptions", args{&rsa.PKCS1v15DecryptOptions{}, apiv1.UnspecifiedEncryptionAlgorithm, true}, azkeys.JSONWebKeyEncryptionAlgorithmRSA15, false},
+		{"fail RSA1_5", args{nil, apiv1.RSAPKCS1v15, false}, "", true},
+		{"fail PKCS1v15DecryptOptions", args{&rsa.PKCS1v15DecryptOptions{}, apiv1.UnspecifiedEncryptionAlgorithm, false}, "", true},
+		{"fail OAEPOptions hash", args{&rsa.OAEPOptions{Hash: crypto.SHA512}, apiv1.UnspecifiedEncryptionAlgorithm, false}, "", true},
+		{"fail OAEPOptions label", args{&rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")}, apiv1.UnspecifiedEncryptionAlgorithm, false}, "", true},
+		{"fail options", args{crypto.SHA256, apiv1.UnspecifiedEncryptionAlgorithm, false}, "", true},
+		{"fail unknown", args{nil, apiv1.EncryptionAlgorithm(100), true}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getEncryptionAlgorithm(tt.args.opts, tt.args.def, tt.args.allowPKCS1v15)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getEncryptionAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getEncryptionAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
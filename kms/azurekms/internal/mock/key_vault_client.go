@@ -50,6 +50,21 @@ func (mr *KeyVaultClientMockRecorder) CreateKey(arg0, arg1, arg2, arg3 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateKey", reflect.TypeOf((*KeyVaultClient)(nil).CreateKey), arg0, arg1, arg2, arg3)
 }
 
+// Decrypt mocks base method.
+func (m *KeyVaultClient) Decrypt(arg0 context.Context, arg1, arg2 string, arg3 azkeys.KeyOperationsParameters, arg4 *azkeys.DecryptOptions) (azkeys.DecryptResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decrypt", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(azkeys.DecryptResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decrypt indicates an expected call of Decrypt.
+func (mr *KeyVaultClientMockRecorder) Decrypt(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrypt", reflect.TypeOf((*KeyVaultClient)(nil).Decrypt), arg0, arg1, arg2, arg3, arg4)
+}
+
 // GetKey mocks base method.
 func (m *KeyVaultClient) GetKey(arg0 context.Context, arg1, arg2 string, arg3 *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +80,51 @@ func (mr *KeyVaultClientMockRecorder) GetKey(arg0, arg1, arg2, arg3 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKey", reflect.TypeOf((*KeyVaultClient)(nil).GetKey), arg0, arg1, arg2, arg3)
 }
 
+// GetKeyRotationPolicy mocks base method.
+func (m *KeyVaultClient) GetKeyRotationPolicy(arg0 context.Context, arg1 string, arg2 *azkeys.GetKeyRotationPolicyOptions) (azkeys.GetKeyRotationPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKeyRotationPolicy", arg0, arg1, arg2)
+	ret0, _ := ret[0].(azkeys.GetKeyRotationPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKeyRotationPolicy indicates an expected call of GetKeyRotationPolicy.
+func (mr *KeyVaultClientMockRecorder) GetKeyRotationPolicy(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKeyRotationPolicy", reflect.TypeOf((*KeyVaultClient)(nil).GetKeyRotationPolicy), arg0, arg1, arg2)
+}
+
+// Release mocks base method.
+func (m *KeyVaultClient) Release(arg0 context.Context, arg1, arg2 string, arg3 azkeys.ReleaseParameters, arg4 *azkeys.ReleaseOptions) (azkeys.ReleaseResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(azkeys.ReleaseResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Release indicates an expected call of Release.
+func (mr *KeyVaultClientMockRecorder) Release(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*KeyVaultClient)(nil).Release), arg0, arg1, arg2, arg3, arg4)
+}
+
+// RotateKey mocks base method.
+func (m *KeyVaultClient) RotateKey(arg0 context.Context, arg1 string, arg2 *azkeys.RotateKeyOptions) (azkeys.RotateKeyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(azkeys.RotateKeyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateKey indicates an expected call of RotateKey.
+func (mr *KeyVaultClientMockRecorder) RotateKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateKey", reflect.TypeOf((*KeyVaultClient)(nil).RotateKey), arg0, arg1, arg2)
+}
+
 // Sign mocks base method.
 func (m *KeyVaultClient) Sign(arg0 context.Context, arg1, arg2 string, arg3 azkeys.SignParameters, arg4 *azkeys.SignOptions) (azkeys.SignResponse, error) {
 	m.ctrl.T.Helper()
@@ -79,3 +139,48 @@ func (mr *KeyVaultClientMockRecorder) Sign(arg0, arg1, arg2, arg3, arg4 interfac
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*KeyVaultClient)(nil).Sign), arg0, arg1, arg2, arg3, arg4)
 }
+
+// UpdateKey mocks base method.
+func (m *KeyVaultClient) UpdateKey(arg0 context.Context, arg1, arg2 string, arg3 azkeys.UpdateKeyParameters, arg4 *azkeys.UpdateKeyOptions) (azkeys.UpdateKeyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKey", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(azkeys.UpdateKeyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateKey indicates an expected call of UpdateKey.
+func (mr *KeyVaultClientMockRecorder) UpdateKey(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKey", reflect.TypeOf((*KeyVaultClient)(nil).UpdateKey), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UpdateKeyRotationPolicy mocks base method.
+func (m *KeyVaultClient) UpdateKeyRotationPolicy(arg0 context.Context, arg1 string, arg2 azkeys.KeyRotationPolicy, arg3 *azkeys.UpdateKeyRotationPolicyOptions) (azkeys.UpdateKeyRotationPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKeyRotationPolicy", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(azkeys.UpdateKeyRotationPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateKeyRotationPolicy indicates an expected call of UpdateKeyRotationPolicy.
+func (mr *KeyVaultClientMockRecorder) UpdateKeyRotationPolicy(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKeyRotationPolicy", reflect.TypeOf((*KeyVaultClient)(nil).UpdateKeyRotationPolicy), arg0, arg1, arg2, arg3)
+}
+
+// Verify mocks base method.
+func (m *KeyVaultClient) Verify(arg0 context.Context, arg1, arg2 string, arg3 azkeys.VerifyParameters, arg4 *azkeys.VerifyOptions) (azkeys.VerifyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(azkeys.VerifyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *KeyVaultClientMockRecorder) Verify(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*KeyVaultClient)(nil).Verify), arg0, arg1, arg2, arg3, arg4)
+}
@@ -6,7 +6,16 @@ package azurekms
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -14,7 +23,10 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/kms/uri"
 )
@@ -29,10 +41,21 @@ func init() {
 const Scheme = "azurekms"
 
 var (
-	valueTrue       = true
-	value2048 int32 = 2048
-	value3072 int32 = 3072
-	value4096 int32 = 4096
+	valueTrue        = true
+	valueFalse       = false
+	value2048  int32 = 2048
+	value3072  int32 = 3072
+	value4096  int32 = 4096
+	value65537 int32 = 65537
+)
+
+// Key types, curves and signature algorithms for Ed25519 keys. The azkeys
+// package version in use does not define them.
+const (
+	jsonWebKeyTypeOKP          azkeys.JSONWebKeyType               = "OKP"
+	jsonWebKeyTypeOKPHSM       azkeys.JSONWebKeyType               = "OKP-HSM"
+	jsonWebKeyCurveNameEd25519 azkeys.JSONWebKeyCurveName          = "Ed25519"
+	signatureAlgorithmEdDSA    azkeys.JSONWebKeySignatureAlgorithm = "EdDSA"
 )
 
 type keyType struct {
@@ -94,6 +117,30 @@ var signatureAlgorithmMapping = map[apiv1.SignatureAlgorithm]keyType{
 		Kty:   azkeys.JSONWebKeyTypeEC,
 		Curve: azkeys.JSONWebKeyCurveNameP521,
 	},
+	apiv1.ECDSAWithSHA256K: {
+		Kty:   azkeys.JSONWebKeyTypeEC,
+		Curve: azkeys.JSONWebKeyCurveNameP256K,
+	},
+}
+
+type verifyAttributes struct {
+	Algorithm azkeys.JSONWebKeySignatureAlgorithm
+	Hash      crypto.Hash
+	// Curve is the curve of the ECDSA signatures, nil for RSA signatures.
+	Curve elliptic.Curve
+}
+
+var verifyAlgorithmMapping = map[apiv1.SignatureAlgorithm]verifyAttributes{
+	apiv1.SHA256WithRSA:    {azkeys.JSONWebKeySignatureAlgorithmRS256, crypto.SHA256, nil},
+	apiv1.SHA384WithRSA:    {azkeys.JSONWebKeySignatureAlgorithmRS384, crypto.SHA384, nil},
+	apiv1.SHA512WithRSA:    {azkeys.JSONWebKeySignatureAlgorithmRS512, crypto.SHA512, nil},
+	apiv1.SHA256WithRSAPSS: {azkeys.JSONWebKeySignatureAlgorithmPS256, crypto.SHA256, nil},
+	apiv1.SHA384WithRSAPSS: {azkeys.JSONWebKeySignatureAlgorithmPS384, crypto.SHA384, nil},
+	apiv1.SHA512WithRSAPSS: {azkeys.JSONWebKeySignatureAlgorithmPS512, crypto.SHA512, nil},
+	apiv1.ECDSAWithSHA256:  {azkeys.JSONWebKeySignatureAlgorithmES256, crypto.SHA256, elliptic.P256()},
+	apiv1.ECDSAWithSHA384:  {azkeys.JSONWebKeySignatureAlgorithmES384, crypto.SHA384, elliptic.P384()},
+	apiv1.ECDSAWithSHA512:  {azkeys.JSONWebKeySignatureAlgorithmES512, crypto.SHA512, elliptic.P521()},
+	apiv1.ECDSAWithSHA256K: {azkeys.JSONWebKeySignatureAlgorithmES256K, crypto.SHA256, secp256k1.S256()},
 }
 
 // KeyVaultClient is the interface implemented by keyvault.BaseClient. It will
@@ -102,6 +149,19 @@ type KeyVaultClient interface {
 	GetKey(ctx context.Context, name string, version string, options *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error)
 	CreateKey(ctx context.Context, name string, parameters azkeys.CreateKeyParameters, options *azkeys.CreateKeyOptions) (azkeys.CreateKeyResponse, error)
 	Sign(ctx context.Context, name string, version string, parameters azkeys.SignParameters, options *azkeys.SignOptions) (azkeys.SignResponse, error)
+	Verify(ctx context.Context, name string, version string, parameters azkeys.VerifyParameters, options *azkeys.VerifyOptions) (azkeys.VerifyResponse, error)
+	Decrypt(ctx context.Context, name string, version string, parameters azkeys.KeyOperationsParameters, options *azkeys.DecryptOptions) (azkeys.DecryptResponse, error)
+	Release(ctx context.Context, name string, version string, parameters azkeys.ReleaseParameters, options *azkeys.ReleaseOptions) (azkeys.ReleaseResponse, error)
+	RotateKey(ctx context.Context, name string, options *azkeys.RotateKeyOptions) (azkeys.RotateKeyResponse, error)
+	UpdateKey(ctx context.Context, name string, version string, parameters azkeys.UpdateKeyParameters, options *azkeys.UpdateKeyOptions) (azkeys.UpdateKeyResponse, error)
+	GetKeyRotationPolicy(ctx context.Context, name string, options *azkeys.GetKeyRotationPolicyOptions) (azkeys.GetKeyRotationPolicyResponse, error)
+	UpdateKeyRotationPolicy(ctx context.Context, name string, keyRotationPolicy azkeys.KeyRotationPolicy, options *azkeys.UpdateKeyRotationPolicyOptions) (azkeys.UpdateKeyRotationPolicyResponse, error)
+}
+
+// SecretsClient is the interface implemented by azsecrets.Client. It will be
+// used for testing purposes.
+type SecretsClient interface {
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
 }
 
 // KeyVault implements a KMS using Azure Key Vault.
@@ -119,7 +179,8 @@ type KeyVaultClient interface {
 // "public" or "AzurePublicCloud", "usgov" or "AzureUSGovernmentCloud", "china"
 // or "AzureChinaCloud", "german" or "AzureGermanCloud", it will default to the
 // public cloud if not specified; "hsm" defines if a key will be generated by an
-// HSM by default.
+// HSM by default. Private clouds can be configured using "dns-suffix" or
+// "vault-base-url", and "aad-endpoint", see New.
 //
 // The URI format for a key in Azure Key Vault is the following:
 //
@@ -132,9 +193,23 @@ type KeyVaultClient interface {
 // parameter that defines the version of they key, if version is not given, the
 // latest one will be used; "vault" and "hsm" will override the default value if
 // set. The "environment" can only be set to initialize the client.
+//
+// Secrets stored in Azure Key Vault can be retrieved with GetSecret using a
+// URI with the following format:
+//
+//   - azurekms:secret=secret-name;vault=vault-name
+//   - azurekms:secret=secret-name;vault=vault-name?version=secret-version
+//
+// Certificates can be retrieved with GetCertificate, and issued with
+// CreateCertificate, using a URI with the following format:
+//
+//   - azurekms:cert=cert-name;vault=vault-name
+//   - azurekms:cert=cert-name;vault=vault-name?version=cert-version
 type KeyVault struct {
-	client   *lazyClient
-	defaults defaultOptions
+	client       *lazyClient
+	secrets      *lazySecretsClient
+	certificates *lazyCertificatesClient
+	defaults     defaultOptions
 }
 
 // defaultDNSSuffix is the suffix of the Azure Public Cloud
@@ -148,9 +223,20 @@ type defaultOptions struct {
 	ProtectionLevel apiv1.ProtectionLevel
 }
 
+// newClientSecretCredential is the function used to create the client secret
+// credentials, it can be replaced in the tests.
+var newClientSecretCredential = azidentity.NewClientSecretCredential
+
 var createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
-	var tenantID string
 	var clientOptions policy.ClientOptions
+
+	// Workload identity parameters, on AKS these environment variables are
+	// injected by the workload identity webhook, but they can be overwritten
+	// using the URI.
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	tokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+
 	if opts.URI != "" {
 		u, err := uri.ParseWithScheme(Scheme, opts.URI)
 		if err != nil {
@@ -159,41 +245,58 @@ var createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.To
 
 		// The 'environment' parameter in the URI defines the Cloud environment to
 		// be used. By default Azure Public Cloud is used.
-		cloudConf, err := getCloudConfiguration(u.Get("environment"))
+		cloudConf, err := getCloudConfigurationFromURI(u)
 		if err != nil {
 			return nil, err
 		}
 
-		// Azure Active Directory endpoint, each environment defines one, but we
-		// allow to update it.
-		//
-		// Defaults to https://login.microsoftonline.com/
-		//
-		// TODO(mariano): is this option still valid?
-		if v := u.Get("aad-endpoint"); v != "" {
-			cloudConf.ActiveDirectoryAuthorityHost = v
-		}
-
 		clientOptions.Cloud = cloudConf.Configuration
 
+		// The 'proxy-url' and 'ca-file' parameters configure the HTTP client
+		// used to get the credentials.
+		transport, err := getTransportFromURI(u)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.Transport = transport
+
 		// ClientSecret credential parameters.
 		//
 		// TenantID can also be used when using environment variables or managed
 		// identities to initialize the credentials.
-		clientID := u.Get("client-id")
+		uriClientID := u.Get("client-id")
+		uriTenantID := u.Get("tenant-id")
 		clientSecret := u.Get("client-secret")
-		tenantID = u.Get("tenant-id")
 
 		// Try to log in only using client credentials in the URI.
 		// Client credentials requires:
 		//   - client-id
 		//   - client-secret
 		//   - tenant-id
-		if clientID != "" && clientSecret != "" && tenantID != "" {
-			return azidentity.NewClientSecretCredential(tenantID, clientID, clientID, &azidentity.ClientSecretCredentialOptions{
+		if uriClientID != "" && clientSecret != "" && uriTenantID != "" {
+			return newClientSecretCredential(uriTenantID, uriClientID, clientSecret, &azidentity.ClientSecretCredentialOptions{
 				ClientOptions: clientOptions,
 			})
 		}
+
+		if uriClientID != "" {
+			clientID = uriClientID
+		}
+		if uriTenantID != "" {
+			tenantID = uriTenantID
+		}
+		if v := u.Get("federated-token-file"); v != "" {
+			tokenFile = v
+		}
+	}
+
+	// Try to log in using workload identity federation. Workload identity
+	// requires:
+	//   - client-id or AZURE_CLIENT_ID
+	//   - tenant-id or AZURE_TENANT_ID
+	//   - federated-token-file or AZURE_FEDERATED_TOKEN_FILE
+	if clientID != "" && tenantID != "" && tokenFile != "" {
+		return newWorkloadIdentityCredential(tenantID, clientID, tokenFile, clientOptions)
 	}
 
 	// Attempt to authorize with the following methods:
@@ -209,6 +312,21 @@ var createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.To
 	})
 }
 
+// newWorkloadIdentityCredential returns a credential that authenticates using
+// the federated token in the given file. The file is read every time a new
+// assertion is required, the token is periodically rotated by Kubernetes.
+func newWorkloadIdentityCredential(tenantID, clientID, tokenFile string, clientOptions policy.ClientOptions) (azcore.TokenCredential, error) {
+	return azidentity.NewClientAssertionCredential(tenantID, clientID, func(context.Context) (string, error) {
+		b, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading federated token file: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}, &azidentity.ClientAssertionCredentialOptions{
+		ClientOptions: clientOptions,
+	})
+}
+
 // New initializes a new KMS implemented using Azure Key Vault.
 //
 // The URI format used to initialized the Azure Key Vault client is the
@@ -218,6 +336,30 @@ var createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.To
 //   - azurekms:vault=vault-name
 //   - azurekms:vault=vault-name;environment=env-name
 //   - azurekms:vault=vault-name?hsm=true
+//   - azurekms:client-id=id;tenant-id=id;federated-token-file=/path/to/token
+//   - azurekms:vault=vault-name;dns-suffix=vault.example.com;aad-endpoint=https://login.example.com/
+//   - azurekms:vault-base-url=https://vault-name.vault.example.com/;aad-endpoint=https://login.example.com/
+//
+// Private clouds, like Azure Stack, can be used defining the "dns-suffix" of
+// the vaults, or the "vault-base-url" of the default vault, and the
+// "aad-endpoint" used to get the credentials. These parameters override the
+// values of the "environment".
+//
+// Keys in other vaults than the default one can be used setting the "vault" in
+// the key name. The clients for each vault are cached, by default up to 100
+// vaults, the least recently used client is dropped when the cache is full.
+// The size of the cache can be set with the "max-clients" parameter, e.g.
+// "azurekms:vault=vault-name;max-clients=10".
+//
+// Connections to Azure can go through an HTTP proxy setting the "proxy-url"
+// parameter, e.g. "azurekms:vault=vault-name;proxy-url=http://proxy:3128",
+// and additional root certificates can be trusted setting the "ca-file"
+// parameter to a PEM file. The system roots are trusted as well. If they are
+// not set, the proxy environment variables and the system roots are used.
+//
+// If the client-id, tenant-id and federated-token-file are defined in the URI,
+// or in the AZURE_CLIENT_ID, AZURE_TENANT_ID, and AZURE_FEDERATED_TOKEN_FILE
+// environment variables, workload identity federation will be used.
 func New(ctx context.Context, opts apiv1.Options) (*KeyVault, error) {
 	credential, err := createCredentials(ctx, opts)
 	if err != nil {
@@ -227,12 +369,14 @@ func New(ctx context.Context, opts apiv1.Options) (*KeyVault, error) {
 	defaults := defaultOptions{
 		DNSSuffix: defaultDNSSuffix,
 	}
+	maxClients := defaultMaxClients
+	var transport policy.Transporter
 	if opts.URI != "" {
 		u, err := uri.ParseWithScheme(Scheme, opts.URI)
 		if err != nil {
 			return nil, err
 		}
-		cloudConf, err := getCloudConfiguration(u.Get("environment"))
+		cloudConf, err := getCloudConfigurationFromURI(u)
 		if err != nil {
 			return nil, err
 		}
@@ -240,19 +384,77 @@ func New(ctx context.Context, opts apiv1.Options) (*KeyVault, error) {
 			Vault:     u.Get("vault"),
 			DNSSuffix: cloudConf.DNSSuffix,
 		}
+		if v := u.Get("vault-base-url"); v != "" {
+			vault, _, err := parseVaultBaseURL(v)
+			if err != nil {
+				return nil, err
+			}
+			if defaults.Vault != "" && defaults.Vault != vault {
+				return nil, fmt.Errorf("vault %q does not match vault-base-url %q", defaults.Vault, v)
+			}
+			defaults.Vault = vault
+		}
 		if u.GetBool("hsm") {
 			defaults.ProtectionLevel = apiv1.HSM
 		}
+		if v := u.Get("max-clients"); v != "" {
+			n, ok := u.GetInt("max-clients")
+			if !ok || n < 1 {
+				return nil, fmt.Errorf("invalid max-clients %q: must be a positive integer", v)
+			}
+			maxClients = n
+		}
+		if transport, err = getTransportFromURI(u); err != nil {
+			return nil, err
+		}
 	}
 
+	client := newLazyClient(defaults.DNSSuffix, lazyClientCreator(credential, transport))
+	client.maxClients = maxClients
+	secrets := newLazySecretsClient(defaults.DNSSuffix, lazySecretsClientCreator(credential, transport))
+	secrets.maxClients = maxClients
+	certificates := newLazyCertificatesClient(defaults.DNSSuffix, lazyCertificatesClientCreator(credential, transport))
+	certificates.maxClients = maxClients
+
 	return &KeyVault{
-		client:   newLazyClient(defaults.DNSSuffix, lazyClientCreator(credential)),
-		defaults: defaults,
+		client:       client,
+		secrets:      secrets,
+		certificates: certificates,
+		defaults:     defaults,
 	}, nil
 }
 
+// ClientCacheStats returns the statistics of the caches of vault clients. A
+// single KeyVault can use keys, secrets and certificates in multiple vaults,
+// and it keeps a client of each kind for each one of them, up to the
+// "max-clients" set in the URI per kind. The returned statistics are the sum
+// of the three caches.
+func (k *KeyVault) ClientCacheStats() ClientCacheStats {
+	var stats ClientCacheStats
+	for _, s := range []ClientCacheStats{
+		k.client.Stats(), k.secrets.Stats(), k.certificates.Stats(),
+	} {
+		stats.Hits += s.Hits
+		stats.Misses += s.Misses
+		stats.Evictions += s.Evictions
+		stats.Size += s.Size
+	}
+	return stats
+}
+
 // GetPublicKey loads a public key from Azure Key Vault by its resource name.
+//
+// Deprecated: use GetPublicKeyContext.
 func (k *KeyVault) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.GetPublicKeyContext(ctx, req)
+}
+
+// GetPublicKeyContext loads a public key from Azure Key Vault by its resource
+// name using the given context.
+func (k *KeyVault) GetPublicKeyContext(ctx context.Context, req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
 	if req.Name == "" {
 		return nil, errors.New("getPublicKeyRequest 'name' cannot be empty")
 	}
@@ -267,24 +469,29 @@ func (k *KeyVault) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKe
 		return nil, err
 	}
 
-	ctx, cancel := defaultContext()
-	defer cancel()
-
 	resp, err := client.GetKey(ctx, name, version, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "keyVault GetKey failed")
+		return nil, errors.Wrap(convertError(err), "keyVault GetKey failed")
 	}
 
 	return convertKey(resp.Key)
 }
 
 // CreateKey creates a asymmetric key in Azure Key Vault.
+//
+// Deprecated: use CreateKeyContext.
 func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
-	if req.Name == "" {
-		return nil, errors.New("createKeyRequest 'name' cannot be empty")
-	}
+	ctx, cancel := defaultContext()
+	defer cancel()
 
-	vault, name, _, hsm, err := parseKeyName(req.Name, k.defaults)
+	return k.CreateKeyContext(ctx, req)
+}
+
+// CreateKeyContext creates a asymmetric key in Azure Key Vault using the given
+// context. The "tags" key in the extra parameters of the request sets the tags
+// of the new key.
+func (k *KeyVault) CreateKeyContext(ctx context.Context, req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	vault, name, params, err := k.createKeyParameters(req)
 	if err != nil {
 		return nil, err
 	}
@@ -294,6 +501,136 @@ func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 		return nil, err
 	}
 
+	created := now()
+	params.KeyAttributes = &azkeys.KeyAttributes{
+		Enabled:   &valueTrue,
+		Created:   &created,
+		NotBefore: &created,
+	}
+
+	// Key Vault creates a new version if the key already exists, so the
+	// existing key must be checked before creating it.
+	if req.IfNotExists {
+		resp, err := client.GetKey(ctx, name, "", nil)
+		if err == nil {
+			return existingKeyResponse(vault, name, resp.Key, params)
+		}
+		var notFoundErr apiv1.NotFoundError
+		if err = convertError(err); !errors.As(err, &notFoundErr) {
+			return nil, errors.Wrap(err, "keyVault GetKey failed")
+		}
+	}
+
+	resp, err := client.CreateKey(ctx, name, params, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault CreateKey failed")
+	}
+
+	publicKey, err := convertKey(resp.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyURI := getKeyName(vault, name, resp.Key)
+	if req.SelfTest {
+		_, _, version, _, err := parseKeyName(keyURI, k.defaults)
+		if err != nil {
+			return nil, err
+		}
+		signer := &Signer{
+			client:    client,
+			name:      name,
+			version:   version,
+			publicKey: publicKey,
+		}
+		if err := selfTest(signer, req.SignatureAlgorithm); err != nil {
+			// Disable the new version, so it is not used as the latest one.
+			if _, uerr := client.UpdateKey(ctx, name, version, azkeys.UpdateKeyParameters{
+				KeyAttributes: &azkeys.KeyAttributes{Enabled: &valueFalse},
+			}, nil); uerr != nil {
+				return nil, errors.Wrapf(err, "keyVault CreateKey self-test failed for %s, and disabling it failed: %v", keyURI, convertError(uerr))
+			}
+			return nil, errors.Wrapf(err, "keyVault CreateKey self-test failed for %s, the version has been disabled", keyURI)
+		}
+	}
+
+	return &apiv1.CreateKeyResponse{
+		Name:      keyURI,
+		PublicKey: publicKey,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: keyURI,
+		},
+	}, nil
+}
+
+// existingKeyResponse returns the response for an existing key if its type,
+// curve and size match the parameters used to create the key.
+func existingKeyResponse(vault, name string, key *azkeys.JSONWebKey, params azkeys.CreateKeyParameters) (*apiv1.CreateKeyResponse, error) {
+	publicKey, err := convertKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyURI := getKeyName(vault, name, key)
+	if key.Kty == nil {
+		return nil, errors.Errorf("key %s already exists without kty", keyURI)
+	}
+	if *key.Kty != *params.Kty {
+		return nil, apiv1.AlreadyExistsError{Message: fmt.Sprintf("key %s already exists with kty %q", keyURI, *key.Kty)}
+	}
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Crv == nil || params.Curve == nil || *key.Crv != *params.Curve {
+			return nil, apiv1.AlreadyExistsError{Message: fmt.Sprintf("key %s already exists with a different curve", keyURI)}
+		}
+	case *rsa.PublicKey:
+		if params.KeySize == nil || pub.N.BitLen() != int(*params.KeySize) {
+			return nil, apiv1.AlreadyExistsError{Message: fmt.Sprintf("key %s already exists with size %d", keyURI, pub.N.BitLen())}
+		}
+	}
+
+	return &apiv1.CreateKeyResponse{
+		Name:      keyURI,
+		PublicKey: publicKey,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: keyURI,
+		},
+	}, nil
+}
+
+// selfTest signs a test message with the new key using a single Sign call and
+// verifies the signature locally using the public key returned by CreateKey.
+func selfTest(signer *Signer, alg apiv1.SignatureAlgorithm) error {
+	opts, err := alg.SignerOpts()
+	if err != nil {
+		// UnspecifiedSignAlgorithm creates P-256 keys, and ECDSAWithSHA256K
+		// keys sign SHA-256 digests.
+		opts = crypto.SHA256
+	}
+	return apiv1.SelfTest(signer, signer.Public(), opts)
+}
+
+// ValidateCreateKey validates the parameters of the given request without
+// creating the key. It returns the same errors as CreateKey for unsupported
+// names, algorithms, key sizes and public exponents.
+func (k *KeyVault) ValidateCreateKey(req *apiv1.CreateKeyRequest) error {
+	_, _, _, err := k.createKeyParameters(req)
+	return err
+}
+
+// createKeyParameters returns the vault, the key name and the parameters used
+// to create the key in the given request.
+func (k *KeyVault) createKeyParameters(req *apiv1.CreateKeyRequest) (string, string, azkeys.CreateKeyParameters, error) {
+	var params azkeys.CreateKeyParameters
+	if req.Name == "" {
+		return "", "", params, errors.New("createKeyRequest 'name' cannot be empty")
+	}
+
+	vault, name, _, hsm, err := parseKeyName(req.Name, k.defaults)
+	if err != nil {
+		return "", "", params, err
+	}
+
 	// Override protection level to HSM only if it's not specified, and is given
 	// in the uri.
 	protectionLevel := req.ProtectionLevel
@@ -303,10 +640,10 @@ func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 
 	kt, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
 	if !ok {
-		return nil, errors.Errorf("keyVault does not support signature algorithm %q", req.SignatureAlgorithm)
+		return "", "", params, apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support signature algorithm %q", req.SignatureAlgorithm)}
 	}
 
-	var keySize *int32
+	var keySize, publicExponent *int32
 	if kt.Kty == azkeys.JSONWebKeyTypeRSA || kt.Kty == azkeys.JSONWebKeyTypeRSAHSM {
 		switch req.Bits {
 		case 2048:
@@ -316,32 +653,339 @@ func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 		case 4096:
 			keySize = &value4096
 		default:
-			return nil, errors.Errorf("keyVault does not support key size %d", req.Bits)
+			return "", "", params, errors.Errorf("keyVault does not support key size %d", req.Bits)
 		}
+		// Key Vault only creates keys with the public exponent 65537.
+		switch req.PublicExponent {
+		case 0:
+		case 65537:
+			publicExponent = &value65537
+		default:
+			return "", "", params, errors.Errorf("keyVault does not support public exponent %d", req.PublicExponent)
+		}
+	} else if req.PublicExponent != 0 {
+		return "", "", params, errors.Errorf("keyVault does not support public exponent on signature algorithm %q", req.SignatureAlgorithm)
+	}
+
+	keyOps, err := getKeyOperations(req.KeyOperations, kt.Kty)
+	if err != nil {
+		return "", "", params, err
+	}
+	if req.SelfTest && !hasKeyOperation(keyOps, azkeys.JSONWebKeyOperationSign) {
+		return "", "", params, errors.New("keyVault cannot self-test a key without the sign operation")
+	}
+
+	tags, err := getTags(req.Extra)
+	if err != nil {
+		return "", "", params, err
 	}
 
 	keyType := kt.KeyType(protectionLevel)
-	created := now()
+	params = azkeys.CreateKeyParameters{
+		Kty:            &keyType,
+		KeySize:        keySize,
+		PublicExponent: publicExponent,
+		Curve:          &kt.Curve,
+		KeyOps:         keyOps,
+		Tags:           tags,
+	}
+	return vault, name, params, nil
+}
+
+// getTags returns the tags of a new key in the "tags" key of the extra
+// parameters of a CreateKeyRequest. The tags can be a map[string]string, or a
+// map[string]any with string values, as decoded from JSON.
+func getTags(extra map[string]any) (map[string]*string, error) {
+	v, ok := extra["tags"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	tags := make(map[string]*string)
+	switch t := v.(type) {
+	case map[string]string:
+		for k, v := range t {
+			tags[k] = pointer(v)
+		}
+	case map[string]any:
+		for k, v := range t {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.Errorf("keyVault tag %q must be a string, got %T", k, v)
+			}
+			tags[k] = pointer(s)
+		}
+	default:
+		return nil, errors.Errorf("keyVault tags must be a map of strings, got %T", v)
+	}
+	return tags, nil
+}
+
+// keyOperationMapping maps the key operations to the operations used in Azure
+// Key Vault.
+var keyOperationMapping = map[apiv1.KeyOperation]azkeys.JSONWebKeyOperation{
+	apiv1.KeyOperationSign:      azkeys.JSONWebKeyOperationSign,
+	apiv1.KeyOperationVerify:    azkeys.JSONWebKeyOperationVerify,
+	apiv1.KeyOperationEncrypt:   azkeys.JSONWebKeyOperationEncrypt,
+	apiv1.KeyOperationDecrypt:   azkeys.JSONWebKeyOperationDecrypt,
+	apiv1.KeyOperationWrapKey:   azkeys.JSONWebKeyOperationWrapKey,
+	apiv1.KeyOperationUnwrapKey: azkeys.JSONWebKeyOperationUnwrapKey,
+}
 
+// getKeyOperations returns the operations allowed on a new key of the given
+// type. Keys can sign and verify if no operations are given. Only RSA keys can
+// encrypt, decrypt, wrap and unwrap keys.
+func getKeyOperations(ops []apiv1.KeyOperation, kty azkeys.JSONWebKeyType) ([]*azkeys.JSONWebKeyOperation, error) {
+	if len(ops) == 0 {
+		ops = []apiv1.KeyOperation{apiv1.KeyOperationSign, apiv1.KeyOperationVerify}
+	}
+
+	seen := make(map[apiv1.KeyOperation]bool, len(ops))
+	keyOps := make([]*azkeys.JSONWebKeyOperation, 0, len(ops))
+	for _, op := range ops {
+		v, ok := keyOperationMapping[op]
+		if !ok {
+			return nil, errors.Errorf("keyVault does not support key operation %q", op)
+		}
+		if kty != azkeys.JSONWebKeyTypeRSA && op != apiv1.KeyOperationSign && op != apiv1.KeyOperationVerify {
+			return nil, errors.Errorf("keyVault does not support key operation %q on %s keys", op, kty)
+		}
+		if !seen[op] {
+			seen[op] = true
+			keyOps = append(keyOps, pointer(v))
+		}
+	}
+	return keyOps, nil
+}
+
+// hasKeyOperation returns true if the given operations include op.
+func hasKeyOperation(ops []*azkeys.JSONWebKeyOperation, op azkeys.JSONWebKeyOperation) bool {
+	for _, v := range ops {
+		if v != nil && *v == op {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSigner returns a crypto.Signer from a previously created asymmetric key.
+//
+// Deprecated: use CreateSignerContext.
+func (k *KeyVault) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
 	ctx, cancel := defaultContext()
 	defer cancel()
 
-	resp, err := client.CreateKey(ctx, name, azkeys.CreateKeyParameters{
-		Kty:     &keyType,
-		KeySize: keySize,
-		Curve:   &kt.Curve,
-		KeyOps: []*azkeys.JSONWebKeyOperation{
-			pointer(azkeys.JSONWebKeyOperationSign),
-			pointer(azkeys.JSONWebKeyOperationVerify),
-		},
-		KeyAttributes: &azkeys.KeyAttributes{
-			Enabled:   &valueTrue,
-			Created:   &created,
-			NotBefore: &created,
-		},
+	return k.CreateSignerContext(ctx, req)
+}
+
+// CreateSignerContext returns a crypto.Signer from a previously created
+// asymmetric key using the given context to load the key. The returned signer
+// implements apiv1.ContextSigner.
+func (k *KeyVault) CreateSignerContext(ctx context.Context, req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if req.SigningKey == "" {
+		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
+	}
+	return newSigner(ctx, k.client, req.SigningKey, k.defaults)
+}
+
+// CreateDecrypter returns a crypto.Decrypter from a previously created RSA key.
+// Ciphertexts are decrypted using RSA-OAEP-256 unless the request or the
+// options passed to Decrypt select a different algorithm. RSA1_5 is only
+// allowed if the request sets AllowRSAPKCS1v15.
+func (k *KeyVault) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Decrypter, error) {
+	if req.DecryptionKey == "" {
+		return nil, errors.New("createDecrypterRequest 'decryptionKey' cannot be empty")
+	}
+	return NewDecrypter(k.client, req.DecryptionKey, k.defaults, req.EncryptionAlgorithm, req.AllowRSAPKCS1v15)
+}
+
+// GetSecret returns the value of a secret stored in Azure Key Vault. The name
+// of the secret uses the form "azurekms:vault=my-vault;secret=my-secret", and
+// a specific version can be selected using the version parameter. This can be
+// used to retrieve PEM encoded material stored as a secret and parse it
+// locally.
+func (k *KeyVault) GetSecret(name string) ([]byte, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.GetSecretContext(ctx, name)
+}
+
+// GetSecretContext returns the value of a secret stored in Azure Key Vault
+// using the given context.
+func (k *KeyVault) GetSecretContext(ctx context.Context, name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New("getSecret 'name' cannot be empty")
+	}
+
+	vaultURL, name, version, err := parseSecretName(name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k.secrets.Get(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetSecret(ctx, name, version, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetSecret failed")
+	}
+	if resp.Value == nil {
+		return nil, errors.New("keyVault GetSecret failed: secret does not contain a value")
+	}
+
+	return []byte(*resp.Value), nil
+}
+
+// Verify verifies the signature of the message using the key in the Azure Key
+// Vault. The message is hashed using the hash of the signature algorithm, and
+// ECDSA signatures are expected to be ASN.1 encoded, as the ones returned by
+// the Sign method.
+func (k *KeyVault) Verify(name string, message, signature []byte, alg apiv1.SignatureAlgorithm) (bool, error) {
+	if name == "" {
+		return false, errors.New("verify 'name' cannot be empty")
+	}
+
+	v, ok := verifyAlgorithmMapping[alg]
+	if !ok {
+		return false, apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support signature algorithm '%s'", alg)}
+	}
+
+	vaultURL, name, version, _, err := parseKeyName(name, k.defaults)
+	if err != nil {
+		return false, err
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return false, err
+	}
+
+	h := v.Hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+
+	// Azure Key Vault expects ECDSA signatures as concat(R,S).
+	if v.Curve != nil {
+		if signature, err = keyutil.ECDSASigToRS(signature, v.Curve); err != nil {
+			return false, nil
+		}
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	resp, err := client.Verify(ctx, name, version, azkeys.VerifyParameters{
+		Algorithm: &v.Algorithm,
+		Digest:    digest,
+		Signature: signature,
 	}, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "keyVault CreateKey failed")
+		return false, errors.Wrap(convertError(err), "keyVault Verify failed")
+	}
+	if resp.Value == nil {
+		return false, errors.New("keyVault Verify failed: response does not contain a value")
+	}
+
+	return *resp.Value, nil
+}
+
+// GetRotationPolicy returns the automatic rotation policy of the key with the
+// given name. Durations in the policy expressed in years or months are
+// converted using 365 and 30 days respectively.
+func (k *KeyVault) GetRotationPolicy(name string) (*apiv1.RotationPolicy, error) {
+	if name == "" {
+		return nil, errors.New("getRotationPolicy 'name' cannot be empty")
+	}
+
+	vaultURL, name, _, _, err := parseKeyName(name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	resp, err := client.GetKeyRotationPolicy(ctx, name, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetKeyRotationPolicy failed")
+	}
+
+	policy, err := convertRotationPolicy(resp.KeyRotationPolicy)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault GetKeyRotationPolicy failed")
+	}
+	return policy, nil
+}
+
+// SetRotationPolicy sets the automatic rotation policy of the key with the
+// given name. The policy replaces the current one, and it can define either
+// RotateAfterCreate or RotateBeforeExpiry, but not both.
+func (k *KeyVault) SetRotationPolicy(name string, policy *apiv1.RotationPolicy) error {
+	switch {
+	case name == "":
+		return errors.New("setRotationPolicy 'name' cannot be empty")
+	case policy == nil:
+		return errors.New("setRotationPolicy 'policy' cannot be nil")
+	case policy.RotateAfterCreate > 0 && policy.RotateBeforeExpiry > 0:
+		return errors.New("setRotationPolicy 'policy' cannot define both RotateAfterCreate and RotateBeforeExpiry")
+	}
+
+	vaultURL, name, _, _, err := parseKeyName(name, k.defaults)
+	if err != nil {
+		return err
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	if _, err := client.UpdateKeyRotationPolicy(ctx, name, newKeyRotationPolicy(policy), nil); err != nil {
+		return errors.Wrap(convertError(err), "keyVault UpdateKeyRotationPolicy failed")
+	}
+	return nil
+}
+
+// RotateKey creates a new version of the key with the given name using the
+// Key Vault rotate operation. The new version keeps the type, size, operations,
+// tags, release policy, exportable flag and rotation policy of the key. Key
+// Vault always rotates the current key, so the name cannot have a version. It
+// returns the URI of the new version, the previous versions are left intact.
+func (k *KeyVault) RotateKey(name string) (*apiv1.CreateKeyResponse, error) {
+	if name == "" {
+		return nil, errors.New("rotateKey 'name' cannot be empty")
+	}
+
+	vaultURL, keyName, version, _, err := parseKeyName(name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+	if version != "" {
+		return nil, errors.Errorf("rotateKey 'name' cannot have a version, got %q", version)
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	resp, err := client.RotateKey(ctx, keyName, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault RotateKey failed")
 	}
 
 	publicKey, err := convertKey(resp.Key)
@@ -349,7 +993,7 @@ func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 		return nil, err
 	}
 
-	keyURI := getKeyName(vault, name, resp.Key)
+	keyURI := getKeyName(vaultURL, keyName, resp.Key)
 	return &apiv1.CreateKeyResponse{
 		Name:      keyURI,
 		PublicKey: publicKey,
@@ -359,17 +1003,124 @@ func (k *KeyVault) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 	}, nil
 }
 
-// CreateSigner returns a crypto.Signer from a previously created asymmetric key.
-func (k *KeyVault) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
-	if req.SigningKey == "" {
-		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
+// Close drops the clients used to connect to the Azure Key Vault. After Close
+// the KeyVault operations will return an error wrapping apiv1.ErrClosed.
+func (k *KeyVault) Close() error {
+	if k.client != nil {
+		k.client.Close()
+	}
+	if k.secrets != nil {
+		k.secrets.Close()
 	}
-	return NewSigner(k.client, req.SigningKey, k.defaults)
+	if k.certificates != nil {
+		k.certificates.Close()
+	}
+	return nil
 }
 
-// Close closes the client connection to the Azure Key Vault. This is a noop.
-func (k *KeyVault) Close() error {
-	return nil
+// wrappingAlgorithmMapping maps the wrapping algorithms to the algorithms used
+// in the key release.
+var wrappingAlgorithmMapping = map[apiv1.EncryptionAlgorithm]azkeys.KeyEncryptionAlgorithm{
+	apiv1.UnspecifiedEncryptionAlgorithm: azkeys.KeyEncryptionAlgorithmRSAAESKEYWRAP256,
+	apiv1.RSAAESKeyWrapWithSHA1:          azkeys.KeyEncryptionAlgorithmCKMRSAAESKEYWRAP,
+	apiv1.RSAAESKeyWrapWithSHA256:        azkeys.KeyEncryptionAlgorithmRSAAESKEYWRAP256,
+	apiv1.RSAAESKeyWrapWithSHA384:        azkeys.KeyEncryptionAlgorithmRSAAESKEYWRAP384,
+}
+
+// WrapKey exports an exportable key using the secure key release flow of Azure
+// Managed HSM or Azure Key Vault Premium. The key is released to the
+// environment in the target attestation token, and it is wrapped with the key
+// encryption key in the token using the given algorithm, RSAAESKeyWrapWithSHA256
+// by default. The key must have been created with a release policy that
+// accepts the attestation.
+//
+// The wrapped key in the response is the signed object returned by Azure
+// containing the released key.
+func (k *KeyVault) WrapKey(req *apiv1.WrapKeyRequest) (*apiv1.WrapKeyResponse, error) {
+	switch {
+	case req.Name == "":
+		return nil, errors.New("wrapKeyRequest 'name' cannot be empty")
+	case req.TargetAttestationToken == "":
+		return nil, errors.New("wrapKeyRequest 'targetAttestationToken' cannot be empty")
+	}
+
+	enc, ok := wrappingAlgorithmMapping[req.WrappingAlgorithm]
+	if !ok {
+		return nil, apiv1.UnsupportedAlgorithmError{Message: fmt.Sprintf("keyVault does not support wrapping algorithm '%s'", req.WrappingAlgorithm)}
+	}
+
+	vaultURL, name, version, _, err := parseKeyName(req.Name, k.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	resp, err := client.Release(ctx, name, version, azkeys.ReleaseParameters{
+		TargetAttestationToken: &req.TargetAttestationToken,
+		Enc:                    &enc,
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "keyVault Release failed")
+	}
+	if resp.Value == nil {
+		return nil, errors.New("keyVault Release failed: response does not contain a value")
+	}
+
+	alg := req.WrappingAlgorithm
+	if alg == apiv1.UnspecifiedEncryptionAlgorithm {
+		alg = apiv1.RSAAESKeyWrapWithSHA256
+	}
+	return &apiv1.WrapKeyResponse{
+		WrappedKey:        []byte(*resp.Value),
+		WrappingAlgorithm: alg,
+	}, nil
+}
+
+// UnwrapKey is not supported by the KeyVault, released keys are unwrapped by
+// the target environment.
+func (k *KeyVault) UnwrapKey(req *apiv1.UnwrapKeyRequest) (*apiv1.UnwrapKeyResponse, error) {
+	return nil, apiv1.NotImplementedError{Message: "keyVault does not support UnwrapKey"}
+}
+
+// Capabilities returns the operations supported by the KeyVault.
+func (k *KeyVault) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:       true,
+		CreateSigner:    true,
+		CreateDecrypter: true,
+		Verify:          true,
+		WrapKey:         true,
+	}
+}
+
+// SupportedAlgorithms returns the signature algorithms, RSA key sizes and
+// elliptic curves that can be used to create keys in Azure Key Vault. The
+// algorithms are sorted and UnspecifiedSignAlgorithm is not included.
+func (k *KeyVault) SupportedAlgorithms() []apiv1.SupportedAlgorithm {
+	algs := make([]apiv1.SupportedAlgorithm, 0, len(signatureAlgorithmMapping))
+	for alg, kt := range signatureAlgorithmMapping {
+		if alg == apiv1.UnspecifiedSignAlgorithm {
+			continue
+		}
+		sa := apiv1.SupportedAlgorithm{SignatureAlgorithm: alg}
+		if kt.Kty == azkeys.JSONWebKeyTypeRSA || kt.Kty == azkeys.JSONWebKeyTypeRSAHSM {
+			sa.Bits = []int{2048, 3072, 4096}
+		} else {
+			sa.Curves = []string{string(kt.Curve)}
+		}
+		algs = append(algs, sa)
+	}
+	sort.Slice(algs, func(i, j int) bool {
+		return algs[i].SignatureAlgorithm < algs[j].SignatureAlgorithm
+	})
+	return algs
 }
 
 // ValidateName validates that the given string is a valid URI.
@@ -378,6 +1129,122 @@ func (k *KeyVault) ValidateName(s string) error {
 	return err
 }
 
+// KeyURI contains the components of a key URI resolved by ParseURI.
+type KeyURI struct {
+	// Vault is the name of the vault, the default vault is used if the URI
+	// does not set one.
+	Vault string
+	// Name is the name of the key.
+	Name string
+	// Version is the version of the key, it is empty if the URI does not set
+	// one, in which case the latest version is used.
+	Version string
+	// HSM is true if new keys are generated by an HSM.
+	HSM bool
+	// VaultURL is the URL of the vault used in requests to Azure Key Vault,
+	// e.g. https://vault-name.vault.azure.net/.
+	VaultURL string
+}
+
+// ParseURI validates the given key URI and returns its components resolved
+// using the defaults of the KeyVault. It can be used to check the endpoint and
+// the version that a URI references before using it.
+func (k *KeyVault) ParseURI(s string) (KeyURI, error) {
+	vault, name, version, hsm, err := parseKeyName(s, k.defaults)
+	if err != nil {
+		return KeyURI{}, err
+	}
+
+	dnsSuffix := k.defaults.DNSSuffix
+	if dnsSuffix == "" {
+		dnsSuffix = defaultDNSSuffix
+	}
+
+	return KeyURI{
+		Vault:    vault,
+		Name:     name,
+		Version:  version,
+		HSM:      hsm,
+		VaultURL: vaultBaseURL(vault, dnsSuffix),
+	}, nil
+}
+
+// ValidateNameExists validates that the given string is a valid URI, and that
+// it references an existing and enabled key in Azure Key Vault. It returns an
+// apiv1.NotFoundError if the key, or the version in the URI, does not exist.
+func (k *KeyVault) ValidateNameExists(ctx context.Context, s string) error {
+	vaultURL, name, version, _, err := parseKeyName(s, k.defaults)
+	if err != nil {
+		return err
+	}
+
+	client, err := k.client.Get(vaultURL)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.GetKey(ctx, name, version, nil)
+	if err != nil {
+		return errors.Wrap(convertError(err), "keyVault GetKey failed")
+	}
+	if resp.Attributes != nil && resp.Attributes.Enabled != nil && !*resp.Attributes.Enabled {
+		return errors.Errorf("keyVault key %q is disabled", s)
+	}
+
+	return nil
+}
+
+// pingKeyName is the name of the key requested by Ping. The key is not
+// expected to exist, a not found error means that the vault is reachable and
+// that the credentials are valid.
+const pingKeyName = "step-kms-ping"
+
+// Ping checks that the default vault is reachable and that the credentials are
+// valid, requesting a key that is not expected to exist. It returns an
+// apiv1.PermissionDeniedError if the authentication fails or the access to the
+// vault is denied, and an apiv1.UnavailableError if the vault cannot be
+// reached. It requires a vault in the KMS URI.
+func (k *KeyVault) Ping(ctx context.Context) error {
+	if k.defaults.Vault == "" {
+		return errors.New("keyVault Ping requires a default vault")
+	}
+
+	client, err := k.client.Get(k.defaults.Vault)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.GetKey(ctx, pingKeyName, "", nil)
+	return convertPingError(err)
+}
+
+// convertPingError converts the error of the GetKey request done by Ping.
+func convertPingError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return apiv1.PermissionDeniedError{Message: "keyVault Ping failed: " + err.Error()}
+	}
+
+	switch e := convertError(err).(type) {
+	case apiv1.NotFoundError:
+		return nil
+	case apiv1.PermissionDeniedError:
+		return errors.Wrap(e, "keyVault Ping failed")
+	}
+
+	var responseError *azcore.ResponseError
+	if errors.As(err, &responseError) {
+		return apiv1.UnavailableError{
+			Message: fmt.Sprintf("keyVault Ping failed: %d %s", responseError.StatusCode, http.StatusText(responseError.StatusCode)),
+		}
+	}
+	return apiv1.UnavailableError{Message: "keyVault Ping failed: " + err.Error()}
+}
+
 type cloudConfiguration struct {
 	cloud.Configuration
 	DNSSuffix string
@@ -417,3 +1284,84 @@ func getCloudConfiguration(cloudName string) (cloudConfiguration, error) {
 		return cloudConfiguration{}, fmt.Errorf("unknown key vault cloud environment with name %q", cloudName)
 	}
 }
+
+// getCloudConfigurationFromURI returns the cloud configuration of the
+// "environment" in the given URI, updated with the "aad-endpoint", and the
+// "dns-suffix" or "vault-base-url" if they are present.
+func getCloudConfigurationFromURI(u *uri.URI) (cloudConfiguration, error) {
+	conf, err := getCloudConfiguration(u.Get("environment"))
+	if err != nil {
+		return cloudConfiguration{}, err
+	}
+
+	// Azure Active Directory endpoint, each environment defines one, but we
+	// allow to update it.
+	//
+	// Defaults to https://login.microsoftonline.com/
+	if v := u.Get("aad-endpoint"); v != "" {
+		if err := validateEndpoint(v); err != nil {
+			return cloudConfiguration{}, fmt.Errorf("aad-endpoint %q is not valid: %w", v, err)
+		}
+		conf.ActiveDirectoryAuthorityHost = v
+	}
+
+	dnsSuffix, baseURL := u.Get("dns-suffix"), u.Get("vault-base-url")
+	switch {
+	case dnsSuffix != "" && baseURL != "":
+		return cloudConfiguration{}, errors.New("dns-suffix and vault-base-url cannot be used together")
+	case dnsSuffix != "":
+		if err := validateDNSSuffix(dnsSuffix); err != nil {
+			return cloudConfiguration{}, fmt.Errorf("dns-suffix %q is not valid: %w", dnsSuffix, err)
+		}
+		conf.DNSSuffix = dnsSuffix
+	case baseURL != "":
+		_, suffix, err := parseVaultBaseURL(baseURL)
+		if err != nil {
+			return cloudConfiguration{}, err
+		}
+		conf.DNSSuffix = suffix
+	}
+
+	return conf, nil
+}
+
+// getTransportFromURI returns the HTTP client used to connect to Azure if the
+// "proxy-url" or "ca-file" parameters are set in the URI. It returns nil if
+// none of them are set, and the default transport will be used.
+func getTransportFromURI(u *uri.URI) (policy.Transporter, error) {
+	proxyURL, caFile := u.Get("proxy-url"), u.Get("ca-file")
+	if proxyURL == "" && caFile == "" {
+		return nil, nil
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		pu, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("proxy-url %q is not valid: %w", proxyURL, err)
+		}
+		if (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+			return nil, fmt.Errorf("proxy-url %q is not valid: url must be an http or https URL", proxyURL)
+		}
+		tr.Proxy = http.ProxyURL(pu)
+	}
+	if caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca-file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("error reading ca-file %q: no certificates found", caFile)
+		}
+		tr.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &http.Client{Transport: tr}, nil
+}
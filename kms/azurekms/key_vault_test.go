@@ -2,22 +2,43 @@
 package azurekms
 
 import (
+	"bytes"
 	"context"
 	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/golang/mock/gomock"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/kms/azurekms/internal/mock"
+	"go.step.sm/crypto/kms/uri"
+	"go.step.sm/crypto/pemutil"
 	"gopkg.in/square/go-jose.v2"
 )
 
@@ -110,7 +131,9 @@ func TestNew(t *testing.T) {
 				return fakeTokenCredential{}, nil
 			}
 		}, args{context.Background(), apiv1.Options{}}, &KeyVault{
-			client: newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{})),
+			client:       newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.azure.net", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.azure.net", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
 			defaults: defaultOptions{
 				DNSSuffix: "vault.azure.net",
 			},
@@ -122,7 +145,9 @@ func TestNew(t *testing.T) {
 		}, args{context.Background(), apiv1.Options{
 			URI: "azurekms:vault=my-vault",
 		}}, &KeyVault{
-			client: newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{})),
+			client:       newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.azure.net", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.azure.net", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
 			defaults: defaultOptions{
 				Vault:           "my-vault",
 				DNSSuffix:       "vault.azure.net",
@@ -136,7 +161,9 @@ func TestNew(t *testing.T) {
 		}, args{context.Background(), apiv1.Options{
 			URI: "azurekms:vault=my-vault;hsm=true",
 		}}, &KeyVault{
-			client: newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{})),
+			client:       newLazyClient("vault.azure.net", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.azure.net", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.azure.net", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
 			defaults: defaultOptions{
 				Vault:           "my-vault",
 				DNSSuffix:       "vault.azure.net",
@@ -150,18 +177,64 @@ func TestNew(t *testing.T) {
 		}, args{context.Background(), apiv1.Options{
 			URI: "azurekms:vault=my-vault;environment=usgov",
 		}}, &KeyVault{
-			client: newLazyClient("vault.usgovcloudapi.net", lazyClientCreator(fakeTokenCredential{})),
+			client:       newLazyClient("vault.usgovcloudapi.net", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.usgovcloudapi.net", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.usgovcloudapi.net", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
 			defaults: defaultOptions{
 				Vault:           "my-vault",
 				DNSSuffix:       "vault.usgovcloudapi.net",
 				ProtectionLevel: apiv1.UnspecifiedProtectionLevel,
 			},
 		}, false},
+		{"ok with vault + dns-suffix", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault=my-vault;dns-suffix=vault.azurestack.example",
+		}}, &KeyVault{
+			client:       newLazyClient("vault.azurestack.example", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.azurestack.example", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.azurestack.example", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
+			defaults: defaultOptions{
+				Vault:     "my-vault",
+				DNSSuffix: "vault.azurestack.example",
+			},
+		}, false},
+		{"ok with vault-base-url", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault-base-url=https%3A%2F%2Fmy-vault.vault.azurestack.example%2F",
+		}}, &KeyVault{
+			client:       newLazyClient("vault.azurestack.example", lazyClientCreator(fakeTokenCredential{}, nil)),
+			secrets:      newLazySecretsClient("vault.azurestack.example", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+			certificates: newLazyCertificatesClient("vault.azurestack.example", lazyCertificatesClientCreator(fakeTokenCredential{}, nil)),
+			defaults: defaultOptions{
+				Vault:     "my-vault",
+				DNSSuffix: "vault.azurestack.example",
+			},
+		}, false},
 		{"fail", func() {
 			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
 				return nil, errTest
 			}
 		}, args{context.Background(), apiv1.Options{}}, nil, true},
+		{"fail vault-base-url", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault-base-url=http%3A%2F%2Fmy-vault.vault.azurestack.example%2F",
+		}}, nil, true},
+		{"fail vault-base-url vault", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault=other-vault;vault-base-url=https%3A%2F%2Fmy-vault.vault.azurestack.example%2F",
+		}}, nil, true},
 		{"fail uri schema", func() {
 			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
 				return fakeTokenCredential{}, nil
@@ -176,6 +249,27 @@ func TestNew(t *testing.T) {
 		}, args{context.Background(), apiv1.Options{
 			URI: "azurekms:environment=bad-one",
 		}}, nil, true},
+		{"fail max-clients", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault=my-vault;max-clients=0",
+		}}, nil, true},
+		{"fail proxy-url", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault=my-vault;proxy-url=proxy.example.com",
+		}}, nil, true},
+		{"fail ca-file", func() {
+			createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+				return fakeTokenCredential{}, nil
+			}
+		}, args{context.Background(), apiv1.Options{
+			URI: "azurekms:vault=my-vault;ca-file=testdata/missing.crt",
+		}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -187,6 +281,8 @@ func TestNew(t *testing.T) {
 			}
 			if tt.want != nil && got != nil {
 				got.client = tt.want.client
+				got.secrets = tt.want.secrets
+				got.certificates = tt.want.certificates
 			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("New() = %v, want %v", got, tt.want)
@@ -195,6 +291,35 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_maxClients(t *testing.T) {
+	old := createCredentials
+	t.Cleanup(func() {
+		createCredentials = old
+	})
+	createCredentials = func(ctx context.Context, opts apiv1.Options) (azcore.TokenCredential, error) {
+		return fakeTokenCredential{}, nil
+	}
+
+	k, err := New(context.Background(), apiv1.Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if k.client.maxClients != defaultMaxClients {
+		t.Errorf("New() maxClients = %d, want %d", k.client.maxClients, defaultMaxClients)
+	}
+
+	k, err = New(context.Background(), apiv1.Options{URI: "azurekms:vault=my-vault;max-clients=10"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if k.client.maxClients != 10 || k.secrets.maxClients != 10 || k.certificates.maxClients != 10 {
+		t.Errorf("New() maxClients = %d, %d, %d, want 10", k.client.maxClients, k.secrets.maxClients, k.certificates.maxClients)
+	}
+	if got := k.ClientCacheStats(); got != (ClientCacheStats{}) {
+		t.Errorf("KeyVault.ClientCacheStats() = %+v, want zero", got)
+	}
+}
+
 func TestKeyVault_createCredentials(t *testing.T) {
 	type args struct {
 		ctx  context.Context
@@ -218,12 +343,27 @@ func TestKeyVault_createCredentials(t *testing.T) {
 		{"ok with uri no config", args{context.Background(), apiv1.Options{
 			URI: "azurekms:",
 		}}, false},
+		{"ok with uri+private cloud", args{context.Background(), apiv1.Options{
+			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;dns-suffix=vault.azurestack.example;aad-endpoint=https%3A%2F%2Flogin.azurestack.example%2F",
+		}}, false},
 		{"fail uri", args{context.Background(), apiv1.Options{
 			URI: "kms:client-id=id;client-secret=secret;tenant-id=id",
 		}}, true},
+		{"fail bad aad-endpoint", args{context.Background(), apiv1.Options{
+			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;aad-endpoint=login.azurestack.example",
+		}}, true},
 		{"ok bad environment", args{context.Background(), apiv1.Options{
 			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;environment=fake",
 		}}, true},
+		{"ok with uri+proxy-url", args{context.Background(), apiv1.Options{
+			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;proxy-url=http%3A%2F%2Fproxy.example.com%3A3128",
+		}}, false},
+		{"fail bad proxy-url", args{context.Background(), apiv1.Options{
+			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;proxy-url=ftp%3A%2F%2Fproxy.example.com",
+		}}, true},
+		{"fail bad ca-file", args{context.Background(), apiv1.Options{
+			URI: "azurekms:client-id=id;client-secret=secret;tenant-id=id;ca-file=testdata/missing.crt",
+		}}, true},
 	}
 
 	for _, tt := range tests {
@@ -236,6 +376,181 @@ func TestKeyVault_createCredentials(t *testing.T) {
 	}
 }
 
+func TestKeyVault_createCredentials_type(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("federated-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		uri  string
+		want azcore.TokenCredential
+	}{
+		{"default", nil, "", &azidentity.DefaultAzureCredential{}},
+		{"default no token file", map[string]string{
+			"AZURE_CLIENT_ID": "id", "AZURE_TENANT_ID": "id",
+		}, "azurekms:", &azidentity.DefaultAzureCredential{}},
+		{"client secret", nil, "azurekms:client-id=id;client-secret=secret;tenant-id=id", &azidentity.ClientSecretCredential{}},
+		{"client secret with environment", map[string]string{
+			"AZURE_CLIENT_ID": "id", "AZURE_TENANT_ID": "id", "AZURE_FEDERATED_TOKEN_FILE": tokenFile,
+		}, "azurekms:client-id=id;client-secret=secret;tenant-id=id", &azidentity.ClientSecretCredential{}},
+		{"workload identity environment", map[string]string{
+			"AZURE_CLIENT_ID": "id", "AZURE_TENANT_ID": "id", "AZURE_FEDERATED_TOKEN_FILE": tokenFile,
+		}, "", &azidentity.ClientAssertionCredential{}},
+		{"workload identity uri", nil, "azurekms:client-id=id;tenant-id=id;federated-token-file=" + tokenFile, &azidentity.ClientAssertionCredential{}},
+		{"workload identity uri and environment", map[string]string{
+			"AZURE_CLIENT_ID": "id", "AZURE_TENANT_ID": "id",
+		}, "azurekms:federated-token-file=" + tokenFile, &azidentity.ClientAssertionCredential{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"AZURE_CLIENT_ID", "AZURE_TENANT_ID", "AZURE_FEDERATED_TOKEN_FILE"} {
+				t.Setenv(k, tt.env[k])
+			}
+			got, err := createCredentials(context.Background(), apiv1.Options{URI: tt.uri})
+			if err != nil {
+				t.Fatalf("createCredentials() error = %v", err)
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Errorf("createCredentials() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_createCredentials_clientSecret(t *testing.T) {
+	old := newClientSecretCredential
+	t.Cleanup(func() {
+		newClientSecretCredential = old
+	})
+
+	type args struct {
+		tenantID, clientID, secret string
+	}
+	var got args
+	newClientSecretCredential = func(tenantID, clientID, secret string, options *azidentity.ClientSecretCredentialOptions) (*azidentity.ClientSecretCredential, error) {
+		got = args{tenantID, clientID, secret}
+		return old(tenantID, clientID, secret, options)
+	}
+
+	t.Setenv("AZURE_CLIENT_ID", "env-client-id")
+	if _, err := createCredentials(context.Background(), apiv1.Options{
+		URI: "azurekms:client-id=client-id;client-secret=client-secret;tenant-id=tenant-id",
+	}); err != nil {
+		t.Fatalf("createCredentials() error = %v", err)
+	}
+	if want := (args{"tenant-id", "client-id", "client-secret"}); got != want {
+		t.Errorf("azidentity.NewClientSecretCredential() called with %+v, want %+v", got, want)
+	}
+}
+
+func Test_getTransportFromURI(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Host)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(proxy.Close)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: srv.Certificate().Raw,
+	}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(emptyFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(t *testing.T, s string) *uri.URI {
+		t.Helper()
+		u, err := uri.ParseWithScheme(Scheme, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	t.Run("ok none", func(t *testing.T) {
+		got, err := getTransportFromURI(parse(t, "azurekms:vault=my-vault"))
+		if err != nil {
+			t.Fatalf("getTransportFromURI() error = %v", err)
+		}
+		if got != nil {
+			t.Errorf("getTransportFromURI() = %v, want nil", got)
+		}
+	})
+
+	t.Run("ok ca-file", func(t *testing.T) {
+		got, err := getTransportFromURI(parse(t, "azurekms:vault=my-vault;ca-file="+caFile))
+		if err != nil {
+			t.Fatalf("getTransportFromURI() error = %v", err)
+		}
+		req, err := http.NewRequest(http.MethodGet, srv.URL, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := got.Do(req)
+		if err != nil {
+			t.Fatalf("Transporter.Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Transporter.Do() status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+	})
+
+	t.Run("ok proxy-url", func(t *testing.T) {
+		got, err := getTransportFromURI(parse(t, "azurekms:vault=my-vault;proxy-url="+url.QueryEscape(proxy.URL)))
+		if err != nil {
+			t.Fatalf("getTransportFromURI() error = %v", err)
+		}
+		req, err := http.NewRequest(http.MethodGet, "http://my-vault.vault.azure.net/keys", http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := got.Do(req)
+		if err != nil {
+			t.Fatalf("Transporter.Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if !reflect.DeepEqual(proxied, []string{"my-vault.vault.azure.net"}) {
+			t.Errorf("Transporter.Do() proxied = %v, want [my-vault.vault.azure.net]", proxied)
+		}
+	})
+
+	failTests := []struct {
+		name string
+		uri  string
+	}{
+		{"fail proxy-url parse", "azurekms:proxy-url=http%3A%2F%2F%25zz"},
+		{"fail proxy-url scheme", "azurekms:proxy-url=socks5%3A%2F%2Fproxy.example.com"},
+		{"fail proxy-url host", "azurekms:proxy-url=proxy.example.com"},
+		{"fail ca-file missing", "azurekms:ca-file=" + filepath.Join(dir, "missing.crt")},
+		{"fail ca-file empty", "azurekms:ca-file=" + emptyFile},
+	}
+	for _, tt := range failTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getTransportFromURI(parse(t, tt.uri))
+			if err == nil {
+				t.Error("getTransportFromURI() error = nil, want error")
+			}
+			if got != nil {
+				t.Errorf("getTransportFromURI() = %v, want nil", got)
+			}
+		})
+	}
+}
+
 func TestKeyVault_GetPublicKey(t *testing.T) {
 	key, err := keyutil.GenerateDefaultSigner()
 	if err != nil {
@@ -335,33 +650,37 @@ func TestKeyVault_CreateKey(t *testing.T) {
 	rsaJWK := createJWK(t, rsaPub)
 
 	expects := []struct {
-		Name    string
-		Kty     azkeys.JSONWebKeyType
-		KeySize *int32
-		Curve   azkeys.JSONWebKeyCurveName
-		Key     *azkeys.JSONWebKey
+		Name           string
+		Kty            azkeys.JSONWebKeyType
+		KeySize        *int32
+		PublicExponent *int32
+		Curve          azkeys.JSONWebKeyCurveName
+		Key            *azkeys.JSONWebKey
 	}{
-		{"P-256", azkeys.JSONWebKeyTypeEC, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
-		{"P-256 HSM", azkeys.JSONWebKeyTypeECHSM, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
-		{"P-256 HSM (uri)", azkeys.JSONWebKeyTypeECHSM, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
-		{"P-256 Default", azkeys.JSONWebKeyTypeEC, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
-		{"P-384", azkeys.JSONWebKeyTypeEC, nil, azkeys.JSONWebKeyCurveNameP384, ecJWK},
-		{"P-521", azkeys.JSONWebKeyTypeEC, nil, azkeys.JSONWebKeyCurveNameP521, ecJWK},
-		{"RSA 0", azkeys.JSONWebKeyTypeRSA, &value3072, "", rsaJWK},
-		{"RSA 0 HSM", azkeys.JSONWebKeyTypeRSAHSM, &value3072, "", rsaJWK},
-		{"RSA 0 HSM (uri)", azkeys.JSONWebKeyTypeRSAHSM, &value3072, "", rsaJWK},
-		{"RSA 2048", azkeys.JSONWebKeyTypeRSA, &value2048, "", rsaJWK},
-		{"RSA 3072", azkeys.JSONWebKeyTypeRSA, &value3072, "", rsaJWK},
-		{"RSA 4096", azkeys.JSONWebKeyTypeRSA, &value4096, "", rsaJWK},
+		{"P-256", azkeys.JSONWebKeyTypeEC, nil, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
+		{"P-256 HSM", azkeys.JSONWebKeyTypeECHSM, nil, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
+		{"P-256 HSM (uri)", azkeys.JSONWebKeyTypeECHSM, nil, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
+		{"P-256 Default", azkeys.JSONWebKeyTypeEC, nil, nil, azkeys.JSONWebKeyCurveNameP256, ecJWK},
+		{"P-384", azkeys.JSONWebKeyTypeEC, nil, nil, azkeys.JSONWebKeyCurveNameP384, ecJWK},
+		{"P-521", azkeys.JSONWebKeyTypeEC, nil, nil, azkeys.JSONWebKeyCurveNameP521, ecJWK},
+		{"P-256K", azkeys.JSONWebKeyTypeECHSM, nil, nil, azkeys.JSONWebKeyCurveNameP256K, ecJWK},
+		{"RSA 0", azkeys.JSONWebKeyTypeRSA, &value3072, nil, "", rsaJWK},
+		{"RSA 0 HSM", azkeys.JSONWebKeyTypeRSAHSM, &value3072, nil, "", rsaJWK},
+		{"RSA 0 HSM (uri)", azkeys.JSONWebKeyTypeRSAHSM, &value3072, nil, "", rsaJWK},
+		{"RSA 2048", azkeys.JSONWebKeyTypeRSA, &value2048, nil, "", rsaJWK},
+		{"RSA 3072", azkeys.JSONWebKeyTypeRSA, &value3072, nil, "", rsaJWK},
+		{"RSA 4096", azkeys.JSONWebKeyTypeRSA, &value4096, nil, "", rsaJWK},
+		{"RSA 2048 65537", azkeys.JSONWebKeyTypeRSA, &value2048, &value65537, "", rsaJWK},
 	}
 
 	t0 := mockNow(t)
 	m := mockClient(t)
 	for _, e := range expects {
 		m.EXPECT().CreateKey(gomock.Any(), "my-key", azkeys.CreateKeyParameters{
-			Kty:     pointer(e.Kty),
-			KeySize: e.KeySize,
-			Curve:   pointer(e.Curve),
+			Kty:            pointer(e.Kty),
+			KeySize:        e.KeySize,
+			PublicExponent: e.PublicExponent,
+			Curve:          pointer(e.Curve),
 			KeyOps: []*azkeys.JSONWebKeyOperation{
 				pointer(azkeys.JSONWebKeyOperationSign),
 				pointer(azkeys.JSONWebKeyOperationVerify),
@@ -375,6 +694,24 @@ func TestKeyVault_CreateKey(t *testing.T) {
 			KeyBundle: azkeys.KeyBundle{Key: e.Key},
 		}, nil)
 	}
+	m.EXPECT().CreateKey(gomock.Any(), "my-decryption-key", azkeys.CreateKeyParameters{
+		Kty:     pointer(azkeys.JSONWebKeyTypeRSA),
+		KeySize: &value3072,
+		Curve:   pointer(azkeys.JSONWebKeyCurveName("")),
+		KeyOps: []*azkeys.JSONWebKeyOperation{
+			pointer(azkeys.JSONWebKeyOperationSign),
+			pointer(azkeys.JSONWebKeyOperationVerify),
+			pointer(azkeys.JSONWebKeyOperationEncrypt),
+			pointer(azkeys.JSONWebKeyOperationDecrypt),
+		},
+		KeyAttributes: &azkeys.KeyAttributes{
+			Enabled:   &valueTrue,
+			Created:   &t0,
+			NotBefore: &t0,
+		},
+	}, nil).Return(azkeys.CreateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: rsaJWK},
+	}, nil)
 	m.EXPECT().CreateKey(gomock.Any(), "not-found", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{}, errTest)
 	m.EXPECT().CreateKey(gomock.Any(), "not-found", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{
 		KeyBundle: azkeys.KeyBundle{Key: nil},
@@ -462,6 +799,17 @@ func TestKeyVault_CreateKey(t *testing.T) {
 				SigningKey: "azurekms:name=my-key;vault=my-vault",
 			},
 		}, false},
+		{"ok P-256K HSM", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=my-key",
+			SignatureAlgorithm: apiv1.ECDSAWithSHA256K,
+			ProtectionLevel:    apiv1.HSM,
+		}}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=my-key;vault=my-vault",
+			PublicKey: ecPub,
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=my-key;vault=my-vault",
+			},
+		}, false},
 		{"ok RSA 0", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
 			Name:               "azurekms:vault=my-vault;name=my-key",
 			Bits:               0,
@@ -530,6 +878,32 @@ func TestKeyVault_CreateKey(t *testing.T) {
 				SigningKey: "azurekms:name=my-key;vault=my-vault",
 			},
 		}, false},
+		{"ok RSA 2048 public exponent", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=my-key",
+			Bits:               2048,
+			PublicExponent:     65537,
+			SignatureAlgorithm: apiv1.SHA256WithRSA,
+		}}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=my-key;vault=my-vault",
+			PublicKey: rsaPub,
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=my-key;vault=my-vault",
+			},
+		}, false},
+		{"ok RSA key operations", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=my-decryption-key",
+			SignatureAlgorithm: apiv1.SHA256WithRSA,
+			KeyOperations: []apiv1.KeyOperation{
+				apiv1.KeyOperationSign, apiv1.KeyOperationVerify,
+				apiv1.KeyOperationEncrypt, apiv1.KeyOperationDecrypt, apiv1.KeyOperationDecrypt,
+			},
+		}}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=my-decryption-key;vault=my-vault",
+			PublicKey: rsaPub,
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=my-decryption-key;vault=my-vault",
+			},
+		}, false},
 		{"fail createKey", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
 			Name:               "azurekms:vault=my-vault;name=not-found",
 			SignatureAlgorithm: apiv1.ECDSAWithSHA256,
@@ -561,6 +935,26 @@ func TestKeyVault_CreateKey(t *testing.T) {
 			SignatureAlgorithm: apiv1.SHA384WithRSAPSS,
 			Bits:               1024,
 		}}, nil, true},
+		{"fail public exponent", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=not-found",
+			SignatureAlgorithm: apiv1.SHA256WithRSA,
+			PublicExponent:     3,
+		}}, nil, true},
+		{"fail public exponent ecdsa", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=not-found",
+			SignatureAlgorithm: apiv1.ECDSAWithSHA256,
+			PublicExponent:     65537,
+		}}, nil, true},
+		{"fail key operation", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=not-found",
+			SignatureAlgorithm: apiv1.SHA256WithRSA,
+			KeyOperations:      []apiv1.KeyOperation{apiv1.KeyOperation(100)},
+		}}, nil, true},
+		{"fail key operation ecdsa", fields{client, defaultOptions{}}, args{&apiv1.CreateKeyRequest{
+			Name:               "azurekms:vault=my-vault;name=not-found",
+			SignatureAlgorithm: apiv1.ECDSAWithSHA256,
+			KeyOperations:      []apiv1.KeyOperation{apiv1.KeyOperationSign, apiv1.KeyOperationDecrypt},
+		}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -580,103 +974,1249 @@ func TestKeyVault_CreateKey(t *testing.T) {
 	}
 }
 
-func TestKeyVault_CreateSigner(t *testing.T) {
-	key, err := keyutil.GenerateDefaultSigner()
+func TestKeyVault_CreateKey_selfTest(t *testing.T) {
+	ecKey, err := keyutil.GenerateDefaultSigner()
 	if err != nil {
 		t.Fatal(err)
 	}
-	pub := key.Public()
-	jwk := createJWK(t, pub)
-
-	m := mockClient(t)
-	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
-		KeyBundle: azkeys.KeyBundle{Key: jwk},
-	}, nil)
-	m.EXPECT().GetKey(gomock.Any(), "my-key", "my-version", nil).Return(azkeys.GetKeyResponse{
-		KeyBundle: azkeys.KeyBundle{Key: jwk},
-	}, nil)
-	m.EXPECT().GetKey(gomock.Any(), "not-found", "my-version", nil).Return(azkeys.GetKeyResponse{}, errTest)
+	otherKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecJWK := createJWK(t, ecKey.Public())
+	ecJWK.KID = pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/my-key/v1"))
 
-	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
-		return m, nil
-	})
+	// signWith returns a mock Sign that signs with the given key, a different
+	// key simulates a key that produces invalid signatures.
+	signWith := func(key crypto.Signer) func(context.Context, string, string, azkeys.SignParameters, *azkeys.SignOptions) (azkeys.SignResponse, error) {
+		return func(_ context.Context, _, _ string, p azkeys.SignParameters, _ *azkeys.SignOptions) (azkeys.SignResponse, error) {
+			if *p.Algorithm != azkeys.JSONWebKeySignatureAlgorithmES256 {
+				return azkeys.SignResponse{}, errTest
+			}
+			der, err := key.Sign(rand.Reader, p.Value, crypto.SHA256)
+			if err != nil {
+				return azkeys.SignResponse{}, err
+			}
+			raw, err := keyutil.ECDSASigToRS(der, elliptic.P256())
+			if err != nil {
+				return azkeys.SignResponse{}, err
+			}
+			return azkeys.SignResponse{
+				KeyOperationResult: azkeys.KeyOperationResult{Result: raw},
+			}, nil
+		}
+	}
 
-	type fields struct {
-		client *lazyClient
+	m := mockClient(t)
+	m.EXPECT().CreateKey(gomock.Any(), "my-key", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: ecJWK},
+	}, nil).Times(4)
+	gomock.InOrder(
+		m.EXPECT().Sign(gomock.Any(), "my-key", "v1", gomock.Any(), nil).DoAndReturn(signWith(ecKey)),
+		m.EXPECT().Sign(gomock.Any(), "my-key", "v1", gomock.Any(), nil).DoAndReturn(signWith(ecKey)),
+		m.EXPECT().Sign(gomock.Any(), "my-key", "v1", gomock.Any(), nil).DoAndReturn(signWith(otherKey)),
+		m.EXPECT().Sign(gomock.Any(), "my-key", "v1", gomock.Any(), nil).Return(azkeys.SignResponse{}, errTest),
+	)
+	disable := azkeys.UpdateKeyParameters{
+		KeyAttributes: &azkeys.KeyAttributes{Enabled: &valueFalse},
 	}
-	type args struct {
-		req *apiv1.CreateSignerRequest
+	gomock.InOrder(
+		m.EXPECT().UpdateKey(gomock.Any(), "my-key", "v1", disable, nil).Return(azkeys.UpdateKeyResponse{}, nil),
+		m.EXPECT().UpdateKey(gomock.Any(), "my-key", "v1", disable, nil).Return(azkeys.UpdateKeyResponse{}, errTest),
+	)
+
+	k := &KeyVault{
+		client: newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+			return m, nil
+		}),
 	}
+
 	tests := []struct {
 		name    string
-		fields  fields
-		args    args
-		want    crypto.Signer
-		wantErr bool
+		req     *apiv1.CreateKeyRequest
+		wantErr string
 	}{
-		{"ok", fields{client}, args{&apiv1.CreateSignerRequest{
-			SigningKey: "azurekms:vault=my-vault;name=my-key",
-		}}, &Signer{
-			client:    m,
-			name:      "my-key",
-			version:   "",
-			publicKey: pub,
-		}, false},
-		{"ok with version", fields{client}, args{&apiv1.CreateSignerRequest{
-			SigningKey: "azurekms:vault=my-vault;name=my-key;version=my-version",
-		}}, &Signer{
-			client:    m,
-			name:      "my-key",
-			version:   "my-version",
-			publicKey: pub,
-		}, false},
-		{"fail GetKey", fields{client}, args{&apiv1.CreateSignerRequest{
-			SigningKey: "azurekms:vault=my-vault;name=not-found;version=my-version",
-		}}, nil, true},
-		{"fail SigningKey", fields{client}, args{&apiv1.CreateSignerRequest{
-			SigningKey: "",
-		}}, nil, true},
+		{"ok", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SelfTest: true}, ""},
+		{"ok ECDSAWithSHA256", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA256, SelfTest: true}, ""},
+		{"fail invalid signature", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SelfTest: true}, "azurekms:name=my-key;vault=my-vault?version=v1, the version has been disabled"},
+		{"fail sign", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SelfTest: true}, "azurekms:name=my-key;vault=my-vault?version=v1, and disabling it failed"},
+		{"fail key operations", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", KeyOperations: []apiv1.KeyOperation{apiv1.KeyOperationVerify}, SelfTest: true}, "without the sign operation"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			k := &KeyVault{
-				client: tt.fields.client,
-			}
-			got, err := k.CreateSigner(tt.args.req)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("KeyVault.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := k.CreateKey(tt.req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("KeyVault.CreateKey() error = %v", err)
+				}
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("KeyVault.CreateSigner() = %v, want %v", got, tt.want)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("KeyVault.CreateKey() error = %v, want containing %q", err, tt.wantErr)
+			}
+			if got != nil {
+				t.Errorf("KeyVault.CreateKey() = %v, want nil", got)
 			}
 		})
 	}
 }
 
-func TestKeyVault_Close(t *testing.T) {
+func TestKeyVault_CreateKey_extra(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := createJWK(t, key.Public())
+	jwk.KID = pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/my-key/v1"))
+
+	var gotTags []map[string]*string
 	m := mockClient(t)
-	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
-		return m, nil
-	})
+	m.EXPECT().CreateKey(gomock.Any(), "my-key", gomock.Any(), nil).DoAndReturn(func(ctx context.Context, name string, params azkeys.CreateKeyParameters, options *azkeys.CreateKeyOptions) (azkeys.CreateKeyResponse, error) {
+		gotTags = append(gotTags, params.Tags)
+		return azkeys.CreateKeyResponse{
+			KeyBundle: azkeys.KeyBundle{Key: jwk},
+		}, nil
+	}).Times(4)
 
-	type fields struct {
-		client *lazyClient
+	k := &KeyVault{
+		client: newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+			return m, nil
+		}),
 	}
+
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr bool
+		name     string
+		extra    map[string]any
+		wantTags map[string]*string
+		wantErr  bool
 	}{
-		{"ok", fields{client}, false},
+		{"ok tags", map[string]any{"tags": map[string]string{"env": "prod", "team": "pki"}}, map[string]*string{"env": pointer("prod"), "team": pointer("pki")}, false},
+		{"ok tags any", map[string]any{"tags": map[string]any{"env": "prod"}}, map[string]*string{"env": pointer("prod")}, false},
+		{"ok unknown keys", map[string]any{"policy": "ignored"}, nil, false},
+		{"ok no extra", nil, nil, false},
+		{"fail tags", map[string]any{"tags": "env=prod"}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			k := &KeyVault{
-				client: tt.fields.client,
+			gotTags = nil
+			_, err := k.CreateKeyContext(context.Background(), &apiv1.CreateKeyRequest{
+				Name:  "azurekms:vault=my-vault;name=my-key",
+				Extra: tt.extra,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.CreateKeyContext() error = %v, wantErr %v", err, tt.wantErr)
+				return
 			}
-			if err := k.Close(); (err != nil) != tt.wantErr {
-				t.Errorf("KeyVault.Close() error = %v, wantErr %v", err, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+			if len(gotTags) != 1 || !reflect.DeepEqual(gotTags[0], tt.wantTags) {
+				t.Errorf("KeyVault.CreateKeyContext() tags = %v, want %v", gotTags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestKeyVault_CreateKey_ifNotExists(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := createJWK(t, key.Public())
+	jwk.KID = pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/my-key/v1"))
+
+	rsaKey, err := keyutil.GenerateSigner("RSA", "", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaJWK := createJWK(t, rsaKey.Public())
+	rsaJWK.KID = pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/rsa-key/v1"))
+
+	newKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newJWK := createJWK(t, newKey.Public())
+	newJWK.KID = pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/new-key/v1"))
+
+	// CreateKey must not be called for existing keys.
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil).Times(4)
+	m.EXPECT().GetKey(gomock.Any(), "rsa-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: rsaJWK},
+	}, nil).Times(2)
+	m.EXPECT().GetKey(gomock.Any(), "new-key", "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 404, ErrorCode: "KeyNotFound"})
+	m.EXPECT().CreateKey(gomock.Any(), "new-key", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: newJWK},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "fail", "", nil).Return(azkeys.GetKeyResponse{}, errTest)
+	m.EXPECT().GetKey(gomock.Any(), "no-kty", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: &azkeys.JSONWebKey{
+			KID: pointer(azkeys.ID("https://my-vault.vault.azure.net/keys/no-kty/v1")),
+			Crv: jwk.Crv, X: jwk.X, Y: jwk.Y,
+		}},
+	}, nil)
+
+	k := &KeyVault{
+		client: newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+			return m, nil
+		}),
+	}
+
+	tests := []struct {
+		name          string
+		req           *apiv1.CreateKeyRequest
+		want          *apiv1.CreateKeyResponse
+		wantErr       bool
+		alreadyExists bool
+	}{
+		{"ok existing", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", IfNotExists: true}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=my-key;vault=my-vault?version=v1",
+			PublicKey: key.Public(),
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=my-key;vault=my-vault?version=v1",
+			},
+		}, false, false},
+		{"ok existing rsa", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=rsa-key", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048, IfNotExists: true}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=rsa-key;vault=my-vault?version=v1",
+			PublicKey: rsaKey.Public(),
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=rsa-key;vault=my-vault?version=v1",
+			},
+		}, false, false},
+		{"ok not found", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=new-key", IfNotExists: true}, &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=new-key;vault=my-vault?version=v1",
+			PublicKey: newKey.Public(),
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=new-key;vault=my-vault?version=v1",
+			},
+		}, false, false},
+		{"fail curve", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA384, IfNotExists: true}, nil, true, true},
+		{"fail kty", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.SHA256WithRSA, IfNotExists: true}, nil, true, true},
+		{"fail protection level", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", ProtectionLevel: apiv1.HSM, IfNotExists: true}, nil, true, true},
+		{"fail size", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=rsa-key", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 4096, IfNotExists: true}, nil, true, true},
+		{"fail get key", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=fail", IfNotExists: true}, nil, true, false},
+		{"fail missing kty", &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=no-kty", IfNotExists: true}, nil, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateKeyContext(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.CreateKeyContext() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var alreadyExists apiv1.AlreadyExistsError
+			if errors.As(err, &alreadyExists) != tt.alreadyExists {
+				t.Errorf("KeyVault.CreateKeyContext() error = %v, want apiv1.AlreadyExistsError %v", err, tt.alreadyExists)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.CreateKeyContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getKeyOperations(t *testing.T) {
+	signVerify := []*azkeys.JSONWebKeyOperation{
+		pointer(azkeys.JSONWebKeyOperationSign),
+		pointer(azkeys.JSONWebKeyOperationVerify),
+	}
+	type args struct {
+		ops []apiv1.KeyOperation
+		kty azkeys.JSONWebKeyType
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []*azkeys.JSONWebKeyOperation
+		wantErr bool
+	}{
+		{"ok default", args{nil, azkeys.JSONWebKeyTypeEC}, signVerify, false},
+		{"ok default RSA", args{nil, azkeys.JSONWebKeyTypeRSA}, signVerify, false},
+		{"ok EC", args{[]apiv1.KeyOperation{apiv1.KeyOperationSign, apiv1.KeyOperationVerify, apiv1.KeyOperationSign}, azkeys.JSONWebKeyTypeEC}, signVerify, false},
+		{"ok RSA", args{[]apiv1.KeyOperation{apiv1.KeyOperationEncrypt, apiv1.KeyOperationDecrypt, apiv1.KeyOperationWrapKey, apiv1.KeyOperationUnwrapKey}, azkeys.JSONWebKeyTypeRSA}, []*azkeys.JSONWebKeyOperation{
+			pointer(azkeys.JSONWebKeyOperationEncrypt),
+			pointer(azkeys.JSONWebKeyOperationDecrypt),
+			pointer(azkeys.JSONWebKeyOperationWrapKey),
+			pointer(azkeys.JSONWebKeyOperationUnwrapKey),
+		}, false},
+		{"fail unknown", args{[]apiv1.KeyOperation{apiv1.KeyOperationSign, 0}, azkeys.JSONWebKeyTypeRSA}, nil, true},
+		{"fail EC decrypt", args{[]apiv1.KeyOperation{apiv1.KeyOperationDecrypt}, azkeys.JSONWebKeyTypeEC}, nil, true},
+		{"fail EC wrapKey", args{[]apiv1.KeyOperation{apiv1.KeyOperationWrapKey}, azkeys.JSONWebKeyTypeEC}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getKeyOperations(tt.args.ops, tt.args.kty)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getKeyOperations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getKeyOperations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_errorTypes(t *testing.T) {
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "not-found", "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 404, ErrorCode: "KeyNotFound"})
+	m.EXPECT().GetKey(gomock.Any(), "forbidden", "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 403, ErrorCode: "Forbidden"})
+	m.EXPECT().CreateKey(gomock.Any(), "conflict", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{}, &azcore.ResponseError{StatusCode: 409, ErrorCode: "Conflict"})
+	k := &KeyVault{
+		client: newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+			return m, nil
+		}),
+	}
+
+	var notFound apiv1.NotFoundError
+	_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "azurekms:vault=my-vault;name=not-found"})
+	if !errors.As(err, &notFound) {
+		t.Errorf("KeyVault.GetPublicKey() error = %v, want apiv1.NotFoundError", err)
+	}
+
+	var permissionDenied apiv1.PermissionDeniedError
+	_, err = k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "azurekms:vault=my-vault;name=forbidden"})
+	if !errors.As(err, &permissionDenied) {
+		t.Errorf("KeyVault.GetPublicKey() error = %v, want apiv1.PermissionDeniedError", err)
+	}
+
+	var alreadyExists apiv1.AlreadyExistsError
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=conflict"})
+	if !errors.As(err, &alreadyExists) {
+		t.Errorf("KeyVault.CreateKey() error = %v, want apiv1.AlreadyExistsError", err)
+	}
+
+	var unsupportedAlgorithm apiv1.UnsupportedAlgorithmError
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.PureEd25519})
+	if !errors.As(err, &unsupportedAlgorithm) {
+		t.Errorf("KeyVault.CreateKey() error = %v, want apiv1.UnsupportedAlgorithmError", err)
+	}
+}
+
+func TestKeyVault_ValidateCreateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults defaultOptions
+		req      *apiv1.CreateKeyRequest
+		wantErr  bool
+	}{
+		{"ok", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key"}, false},
+		{"ok rsa", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 4096, PublicExponent: 65537}, false},
+		{"ok default vault", defaultOptions{Vault: "my-vault"}, &apiv1.CreateKeyRequest{Name: "azurekms:name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA384, ProtectionLevel: apiv1.HSM}, false},
+		{"fail name", defaultOptions{}, &apiv1.CreateKeyRequest{}, true},
+		{"fail parse", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:name=my-key"}, true},
+		{"fail algorithm", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.PureEd25519}, true},
+		{"fail bits", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}, true},
+		{"fail public exponent", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: apiv1.SHA256WithRSA, PublicExponent: 3}, true},
+		{"fail public exponent ec", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", PublicExponent: 65537}, true},
+		{"fail tags", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", Extra: map[string]any{"tags": []string{"env"}}}, true},
+		{"fail tag value", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", Extra: map[string]any{"tags": map[string]any{"env": 1}}}, true},
+		{"fail self-test without sign", defaultOptions{}, &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", KeyOperations: []apiv1.KeyOperation{apiv1.KeyOperationVerify}, SelfTest: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The client is not set, ValidateCreateKey must not use it.
+			k := &KeyVault{defaults: tt.defaults}
+			if err := k.ValidateCreateKey(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.ValidateCreateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyVault_CreateSigner(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public()
+	jwk := createJWK(t, pub)
+
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "my-version", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "not-found", "my-version", nil).Return(azkeys.GetKeyResponse{}, errTest)
+
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		return m, nil
+	})
+
+	type fields struct {
+		client *lazyClient
+	}
+	type args struct {
+		req *apiv1.CreateSignerRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    crypto.Signer
+		wantErr bool
+	}{
+		{"ok", fields{client}, args{&apiv1.CreateSignerRequest{
+			SigningKey: "azurekms:vault=my-vault;name=my-key",
+		}}, &Signer{
+			client:    m,
+			name:      "my-key",
+			version:   "",
+			publicKey: pub,
+		}, false},
+		{"ok with version", fields{client}, args{&apiv1.CreateSignerRequest{
+			SigningKey: "azurekms:vault=my-vault;name=my-key;version=my-version",
+		}}, &Signer{
+			client:    m,
+			name:      "my-key",
+			version:   "my-version",
+			publicKey: pub,
+		}, false},
+		{"fail GetKey", fields{client}, args{&apiv1.CreateSignerRequest{
+			SigningKey: "azurekms:vault=my-vault;name=not-found;version=my-version",
+		}}, nil, true},
+		{"fail SigningKey", fields{client}, args{&apiv1.CreateSignerRequest{
+			SigningKey: "",
+		}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: tt.fields.client,
+			}
+			got, err := k.CreateSigner(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.CreateSigner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_CreateDecrypter(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := createJWK(t, key.Public())
+
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "not-found", "", nil).Return(azkeys.GetKeyResponse{}, errTest)
+
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		return m, nil
+	})
+
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateDecrypterRequest
+		want    crypto.Decrypter
+		wantErr bool
+	}{
+		{"ok", &apiv1.CreateDecrypterRequest{
+			DecryptionKey:       "azurekms:vault=my-vault;name=my-key",
+			EncryptionAlgorithm: apiv1.RSAOAEPWithSHA1,
+		}, &Decrypter{
+			client:    m,
+			name:      "my-key",
+			publicKey: &key.PublicKey,
+			algorithm: apiv1.RSAOAEPWithSHA1,
+		}, false},
+		{"fail GetKey", &apiv1.CreateDecrypterRequest{
+			DecryptionKey: "azurekms:vault=my-vault;name=not-found",
+		}, nil, true},
+		{"fail RSA1_5", &apiv1.CreateDecrypterRequest{
+			DecryptionKey:       "azurekms:vault=my-vault;name=my-key",
+			EncryptionAlgorithm: apiv1.RSAPKCS1v15,
+		}, nil, true},
+		{"fail DecryptionKey", &apiv1.CreateDecrypterRequest{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			got, err := k.CreateDecrypter(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.CreateDecrypter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.CreateDecrypter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type contextKey struct{}
+
+func TestKeyVault_contextMethods(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public()
+	jwk := createJWK(t, pub)
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "my-context")
+	ctxMatcher := FuncMatcher(func(x interface{}) bool {
+		c, ok := x.(context.Context)
+		return ok && c.Value(contextKey{}) == "my-context"
+	})
+
+	client := mockClient(t)
+	client.EXPECT().GetKey(ctxMatcher, "my-key", "my-version", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil).Times(2)
+	client.EXPECT().CreateKey(ctxMatcher, "my-key", gomock.Any(), nil).Return(azkeys.CreateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: jwk},
+	}, nil)
+
+	var _ apiv1.ContextKeyManager = (*KeyVault)(nil)
+	k := &KeyVault{
+		client: newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+			return client, nil
+		}),
+	}
+
+	got, err := k.GetPublicKeyContext(ctx, &apiv1.GetPublicKeyRequest{
+		Name: "azurekms:vault=my-vault;name=my-key?version=my-version",
+	})
+	if err != nil {
+		t.Fatalf("KeyVault.GetPublicKeyContext() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, pub) {
+		t.Errorf("KeyVault.GetPublicKeyContext() = %v, want %v", got, pub)
+	}
+
+	resp, err := k.CreateKeyContext(ctx, &apiv1.CreateKeyRequest{
+		Name:               "azurekms:vault=my-vault;name=my-key",
+		SignatureAlgorithm: apiv1.ECDSAWithSHA256,
+	})
+	if err != nil {
+		t.Fatalf("KeyVault.CreateKeyContext() error = %v", err)
+	}
+	if !reflect.DeepEqual(resp.PublicKey, pub) {
+		t.Errorf("KeyVault.CreateKeyContext() = %v, want %v", resp.PublicKey, pub)
+	}
+
+	signer, err := k.CreateSignerContext(ctx, &apiv1.CreateSignerRequest{
+		SigningKey: "azurekms:vault=my-vault;name=my-key?version=my-version",
+	})
+	if err != nil {
+		t.Fatalf("KeyVault.CreateSignerContext() error = %v", err)
+	}
+	if _, ok := signer.(apiv1.ContextSigner); !ok {
+		t.Errorf("KeyVault.CreateSignerContext() = %T, want apiv1.ContextSigner", signer)
+	}
+}
+
+type fakeSecretsClient struct {
+	secrets map[string]string
+	err     error
+}
+
+func (c *fakeSecretsClient) GetSecret(ctx context.Context, name, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	if c.err != nil {
+		return azsecrets.GetSecretResponse{}, c.err
+	}
+	if version != "" {
+		name = name + "/" + version
+	}
+	v, ok := c.secrets[name]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: 404}
+	}
+	if v == "" {
+		return azsecrets.GetSecretResponse{}, nil
+	}
+	return azsecrets.GetSecretResponse{
+		SecretBundle: azsecrets.SecretBundle{Value: &v},
+	}, nil
+}
+
+func TestKeyVault_GetSecret(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := pemutil.Serialize(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(block)
+
+	client := &fakeSecretsClient{secrets: map[string]string{
+		"my-secret":            string(keyPEM),
+		"my-secret/my-version": "old-value",
+		"empty-secret":         "",
+	}}
+	secrets := newLazySecretsClient("vault.azure.net", func(vaultURL string) (SecretsClient, error) {
+		switch vaultURL {
+		case "https://fail.vault.azure.net/":
+			return nil, errTest
+		case "https://fail-client.vault.azure.net/":
+			return &fakeSecretsClient{err: errTest}, nil
+		default:
+			return client, nil
+		}
+	})
+
+	type fields struct {
+		secrets  *lazySecretsClient
+		defaults defaultOptions
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		secret  string
+		want    []byte
+		wantErr bool
+	}{
+		{"ok", fields{secrets, defaultOptions{}}, "azurekms:vault=my-vault;secret=my-secret", keyPEM, false},
+		{"ok with version", fields{secrets, defaultOptions{}}, "azurekms:vault=my-vault;secret=my-secret?version=my-version", []byte("old-value"), false},
+		{"ok with default vault", fields{secrets, defaultOptions{Vault: "my-vault"}}, "azurekms:secret=my-secret", keyPEM, false},
+		{"fail empty", fields{secrets, defaultOptions{}}, "", nil, true},
+		{"fail parse", fields{secrets, defaultOptions{}}, "azurekms:vault=my-vault;name=my-secret", nil, true},
+		{"fail vault", fields{secrets, defaultOptions{}}, "azurekms:vault=fail;secret=my-secret", nil, true},
+		{"fail GetSecret", fields{secrets, defaultOptions{}}, "azurekms:vault=fail-client;secret=my-secret", nil, true},
+		{"fail not found", fields{secrets, defaultOptions{}}, "azurekms:vault=my-vault;secret=not-found", nil, true},
+		{"fail no value", fields{secrets, defaultOptions{}}, "azurekms:vault=my-vault;secret=empty-secret", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				secrets:  tt.fields.secrets,
+				defaults: tt.fields.defaults,
+			}
+			got, err := k.GetSecret(tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.GetSecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.GetSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The secret can be parsed locally.
+	kv := &KeyVault{secrets: secrets}
+	b, err := kv.GetSecret("azurekms:vault=my-vault;secret=my-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := pemutil.ParseKey(b)
+	if err != nil {
+		t.Fatalf("pemutil.ParseKey() error = %v", err)
+	}
+	if !reflect.DeepEqual(priv, key) {
+		t.Errorf("pemutil.ParseKey() = %v, want %v", priv, key)
+	}
+}
+
+func TestKeyVault_Verify(t *testing.T) {
+	message := []byte("the message to sign")
+	sum256 := sha256.Sum256(message)
+	sum384 := sha512.Sum384(message)
+
+	p256, err := keyutil.GenerateSigner("EC", "P-256", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Sig, err := p256.Sign(rand.Reader, sum256[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(p256Sig, &rs); err != nil {
+		t.Fatal(err)
+	}
+	p256RawSig := make([]byte, 64)
+	rs.R.FillBytes(p256RawSig[:32])
+	rs.S.FillBytes(p256RawSig[32:])
+	k256, err := keyutil.GenerateSigner("EC", "secp256k1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k256Sig, err := k256.Sign(rand.Reader, sum256[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k256RawSig, err := keyutil.ECDSASigToRS(k256Sig, secp256k1.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig := []byte("rsa-signature")
+
+	valid, invalid := true, false
+	verifyMatcher := func(alg azkeys.JSONWebKeySignatureAlgorithm, digest, signature []byte) gomock.Matcher {
+		return FuncMatcher(func(x interface{}) bool {
+			p, ok := x.(azkeys.VerifyParameters)
+			return ok && *p.Algorithm == alg && bytes.Equal(p.Digest, digest) && bytes.Equal(p.Signature, signature)
+		})
+	}
+
+	m := mockClient(t)
+	m.EXPECT().Verify(gomock.Any(), "my-key", "", verifyMatcher(azkeys.JSONWebKeySignatureAlgorithmES256, sum256[:], p256RawSig), nil).Return(azkeys.VerifyResponse{
+		KeyVerifyResult: azkeys.KeyVerifyResult{Value: &valid},
+	}, nil)
+	m.EXPECT().Verify(gomock.Any(), "k256-key", "", verifyMatcher(azkeys.JSONWebKeySignatureAlgorithmES256K, sum256[:], k256RawSig), nil).Return(azkeys.VerifyResponse{
+		KeyVerifyResult: azkeys.KeyVerifyResult{Value: &valid},
+	}, nil)
+	m.EXPECT().Verify(gomock.Any(), "my-key", "my-version", verifyMatcher(azkeys.JSONWebKeySignatureAlgorithmPS384, sum384[:], rsaSig), nil).Return(azkeys.VerifyResponse{
+		KeyVerifyResult: azkeys.KeyVerifyResult{Value: &valid},
+	}, nil)
+	m.EXPECT().Verify(gomock.Any(), "my-key", "", verifyMatcher(azkeys.JSONWebKeySignatureAlgorithmRS256, sum256[:], rsaSig), nil).Return(azkeys.VerifyResponse{
+		KeyVerifyResult: azkeys.KeyVerifyResult{Value: &invalid},
+	}, nil)
+	m.EXPECT().Verify(gomock.Any(), "not-found", "", gomock.Any(), nil).Return(azkeys.VerifyResponse{}, errTest)
+	m.EXPECT().Verify(gomock.Any(), "no-value", "", gomock.Any(), nil).Return(azkeys.VerifyResponse{}, nil)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var _ apiv1.Verifier = (*KeyVault)(nil)
+	type args struct {
+		name      string
+		message   []byte
+		signature []byte
+		alg       apiv1.SignatureAlgorithm
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bool
+		wantErr bool
+	}{
+		{"ok ECDSA", args{"azurekms:vault=my-vault;name=my-key", message, p256Sig, apiv1.ECDSAWithSHA256}, true, false},
+		{"ok ECDSA secp256k1", args{"azurekms:vault=my-vault;name=k256-key", message, k256Sig, apiv1.ECDSAWithSHA256K}, true, false},
+		{"ok RSA-PSS", args{"azurekms:vault=my-vault;name=my-key?version=my-version", message, rsaSig, apiv1.SHA384WithRSAPSS}, true, false},
+		{"invalid RSA", args{"azurekms:vault=my-vault;name=my-key", message, rsaSig, apiv1.SHA256WithRSA}, false, false},
+		{"invalid ECDSA encoding", args{"azurekms:vault=my-vault;name=my-key", message, p256RawSig, apiv1.ECDSAWithSHA256}, false, false},
+		{"fail empty", args{"", message, p256Sig, apiv1.ECDSAWithSHA256}, false, true},
+		{"fail algorithm", args{"azurekms:vault=my-vault;name=my-key", message, p256Sig, apiv1.PureEd25519}, false, true},
+		{"fail parseKeyName", args{"kms:vault=my-vault;name=my-key", message, p256Sig, apiv1.ECDSAWithSHA256}, false, true},
+		{"fail vault", args{"azurekms:vault=fail;name=my-key", message, p256Sig, apiv1.ECDSAWithSHA256}, false, true},
+		{"fail Verify", args{"azurekms:vault=my-vault;name=not-found", message, rsaSig, apiv1.SHA256WithRSA}, false, true},
+		{"fail no value", args{"azurekms:vault=my-vault;name=no-value", message, rsaSig, apiv1.SHA256WithRSA}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			got, err := k.Verify(tt.args.name, tt.args.message, tt.args.signature, tt.args.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("KeyVault.Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_WrapKey(t *testing.T) {
+	releaseMatcher := func(enc azkeys.KeyEncryptionAlgorithm) gomock.Matcher {
+		return FuncMatcher(func(x interface{}) bool {
+			p, ok := x.(azkeys.ReleaseParameters)
+			return ok && *p.TargetAttestationToken == "the-token" && *p.Enc == enc
+		})
+	}
+
+	m := mockClient(t)
+	m.EXPECT().Release(gomock.Any(), "my-key", "", releaseMatcher(azkeys.KeyEncryptionAlgorithmRSAAESKEYWRAP256), nil).Return(azkeys.ReleaseResponse{
+		KeyReleaseResult: azkeys.KeyReleaseResult{Value: pointer("released-256")},
+	}, nil)
+	m.EXPECT().Release(gomock.Any(), "my-key", "my-version", releaseMatcher(azkeys.KeyEncryptionAlgorithmCKMRSAAESKEYWRAP), nil).Return(azkeys.ReleaseResponse{
+		KeyReleaseResult: azkeys.KeyReleaseResult{Value: pointer("released-1")},
+	}, nil)
+	m.EXPECT().Release(gomock.Any(), "my-key", "", releaseMatcher(azkeys.KeyEncryptionAlgorithmRSAAESKEYWRAP384), nil).Return(azkeys.ReleaseResponse{
+		KeyReleaseResult: azkeys.KeyReleaseResult{Value: pointer("released-384")},
+	}, nil)
+	m.EXPECT().Release(gomock.Any(), "not-found", "", gomock.Any(), nil).Return(azkeys.ReleaseResponse{}, errTest)
+	m.EXPECT().Release(gomock.Any(), "no-value", "", gomock.Any(), nil).Return(azkeys.ReleaseResponse{}, nil)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var _ apiv1.KeyWrapper = (*KeyVault)(nil)
+	tests := []struct {
+		name    string
+		req     *apiv1.WrapKeyRequest
+		want    *apiv1.WrapKeyResponse
+		wantErr bool
+	}{
+		{"ok", &apiv1.WrapKeyRequest{
+			Name: "azurekms:vault=my-vault;name=my-key", TargetAttestationToken: "the-token",
+		}, &apiv1.WrapKeyResponse{WrappedKey: []byte("released-256"), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA256}, false},
+		{"ok SHA1", &apiv1.WrapKeyRequest{
+			Name: "azurekms:vault=my-vault;name=my-key?version=my-version", TargetAttestationToken: "the-token", WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA1,
+		}, &apiv1.WrapKeyResponse{WrappedKey: []byte("released-1"), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA1}, false},
+		{"ok SHA384", &apiv1.WrapKeyRequest{
+			Name: "azurekms:vault=my-vault;name=my-key", TargetAttestationToken: "the-token", WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA384,
+		}, &apiv1.WrapKeyResponse{WrappedKey: []byte("released-384"), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA384}, false},
+		{"fail empty name", &apiv1.WrapKeyRequest{TargetAttestationToken: "the-token"}, nil, true},
+		{"fail empty token", &apiv1.WrapKeyRequest{Name: "azurekms:vault=my-vault;name=my-key"}, nil, true},
+		{"fail algorithm", &apiv1.WrapKeyRequest{
+			Name: "azurekms:vault=my-vault;name=my-key", TargetAttestationToken: "the-token", WrappingAlgorithm: apiv1.RSAOAEPWithSHA256,
+		}, nil, true},
+		{"fail parseKeyName", &apiv1.WrapKeyRequest{Name: "kms:vault=my-vault;name=my-key", TargetAttestationToken: "the-token"}, nil, true},
+		{"fail vault", &apiv1.WrapKeyRequest{Name: "azurekms:vault=fail;name=my-key", TargetAttestationToken: "the-token"}, nil, true},
+		{"fail Release", &apiv1.WrapKeyRequest{Name: "azurekms:vault=my-vault;name=not-found", TargetAttestationToken: "the-token"}, nil, true},
+		{"fail no value", &apiv1.WrapKeyRequest{Name: "azurekms:vault=my-vault;name=no-value", TargetAttestationToken: "the-token"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			got, err := k.WrapKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.WrapKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.WrapKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_UnwrapKey(t *testing.T) {
+	k := &KeyVault{}
+	_, err := k.UnwrapKey(&apiv1.UnwrapKeyRequest{WrappedKey: []byte("released")})
+	var nie apiv1.NotImplementedError
+	if !errors.As(err, &nie) {
+		t.Errorf("KeyVault.UnwrapKey() error = %v, want apiv1.NotImplementedError", err)
+	}
+}
+
+func TestKeyVault_GetRotationPolicy(t *testing.T) {
+	m := mockClient(t)
+	m.EXPECT().GetKeyRotationPolicy(gomock.Any(), "my-key", nil).Return(azkeys.GetKeyRotationPolicyResponse{
+		KeyRotationPolicy: azkeys.KeyRotationPolicy{
+			ID: pointer("https://my-vault.vault.azure.net/keys/my-key/rotationpolicy"),
+			Attributes: &azkeys.KeyRotationPolicyAttributes{
+				ExpiryTime: pointer("P1Y"),
+			},
+			LifetimeActions: []*azkeys.LifetimeActions{
+				{
+					Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionType("Rotate"))},
+					Trigger: &azkeys.LifetimeActionsTrigger{TimeAfterCreate: pointer("P90D")},
+				},
+				{
+					Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeNotify)},
+					Trigger: &azkeys.LifetimeActionsTrigger{TimeBeforeExpiry: pointer("P30D")},
+				},
+				nil,
+			},
+		},
+	}, nil)
+	m.EXPECT().GetKeyRotationPolicy(gomock.Any(), "before-expiry", nil).Return(azkeys.GetKeyRotationPolicyResponse{
+		KeyRotationPolicy: azkeys.KeyRotationPolicy{
+			Attributes: &azkeys.KeyRotationPolicyAttributes{
+				ExpiryTime: pointer("P3M"),
+			},
+			LifetimeActions: []*azkeys.LifetimeActions{
+				{
+					Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeRotate)},
+					Trigger: &azkeys.LifetimeActionsTrigger{TimeBeforeExpiry: pointer("PT48H")},
+				},
+			},
+		},
+	}, nil)
+	m.EXPECT().GetKeyRotationPolicy(gomock.Any(), "empty", nil).Return(azkeys.GetKeyRotationPolicyResponse{}, nil)
+	m.EXPECT().GetKeyRotationPolicy(gomock.Any(), "bad-duration", nil).Return(azkeys.GetKeyRotationPolicyResponse{
+		KeyRotationPolicy: azkeys.KeyRotationPolicy{
+			Attributes: &azkeys.KeyRotationPolicyAttributes{
+				ExpiryTime: pointer("90 days"),
+			},
+		},
+	}, nil)
+	m.EXPECT().GetKeyRotationPolicy(gomock.Any(), "not-found", nil).Return(azkeys.GetKeyRotationPolicyResponse{}, errTest)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var _ apiv1.RotationPolicyManager = (*KeyVault)(nil)
+	day := 24 * time.Hour
+	tests := []struct {
+		name    string
+		keyName string
+		want    *apiv1.RotationPolicy
+		wantErr bool
+	}{
+		{"ok", "azurekms:vault=my-vault;name=my-key", &apiv1.RotationPolicy{
+			ExpiryTime:         365 * day,
+			RotateAfterCreate:  90 * day,
+			NotifyBeforeExpiry: 30 * day,
+		}, false},
+		{"ok before expiry", "azurekms:vault=my-vault;name=before-expiry", &apiv1.RotationPolicy{
+			ExpiryTime:         90 * day,
+			RotateBeforeExpiry: 48 * time.Hour,
+		}, false},
+		{"ok empty", "azurekms:vault=my-vault;name=empty", &apiv1.RotationPolicy{}, false},
+		{"fail empty", "", nil, true},
+		{"fail parseKeyName", "kms:vault=my-vault;name=my-key", nil, true},
+		{"fail vault", "azurekms:vault=fail;name=my-key", nil, true},
+		{"fail GetKeyRotationPolicy", "azurekms:vault=my-vault;name=not-found", nil, true},
+		{"fail duration", "azurekms:vault=my-vault;name=bad-duration", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			got, err := k.GetRotationPolicy(tt.keyName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.GetRotationPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.GetRotationPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_SetRotationPolicy(t *testing.T) {
+	m := mockClient(t)
+	m.EXPECT().UpdateKeyRotationPolicy(gomock.Any(), "my-key", azkeys.KeyRotationPolicy{
+		Attributes: &azkeys.KeyRotationPolicyAttributes{
+			ExpiryTime: pointer("P365D"),
+		},
+		LifetimeActions: []*azkeys.LifetimeActions{
+			{
+				Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeRotate)},
+				Trigger: &azkeys.LifetimeActionsTrigger{TimeAfterCreate: pointer("P90D")},
+			},
+			{
+				Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeNotify)},
+				Trigger: &azkeys.LifetimeActionsTrigger{TimeBeforeExpiry: pointer("P30D")},
+			},
+		},
+	}, nil).Return(azkeys.UpdateKeyRotationPolicyResponse{}, nil)
+	m.EXPECT().UpdateKeyRotationPolicy(gomock.Any(), "my-key", azkeys.KeyRotationPolicy{
+		Attributes: &azkeys.KeyRotationPolicyAttributes{
+			ExpiryTime: pointer("P90D"),
+		},
+		LifetimeActions: []*azkeys.LifetimeActions{
+			{
+				Action:  &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeRotate)},
+				Trigger: &azkeys.LifetimeActionsTrigger{TimeBeforeExpiry: pointer("P1DT12H")},
+			},
+		},
+	}, nil).Return(azkeys.UpdateKeyRotationPolicyResponse{}, nil)
+	m.EXPECT().UpdateKeyRotationPolicy(gomock.Any(), "not-found", gomock.Any(), nil).Return(azkeys.UpdateKeyRotationPolicyResponse{}, errTest)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	day := 24 * time.Hour
+	type args struct {
+		name   string
+		policy *apiv1.RotationPolicy
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok after create", args{"azurekms:vault=my-vault;name=my-key", &apiv1.RotationPolicy{
+			ExpiryTime:         365 * day,
+			RotateAfterCreate:  90 * day,
+			NotifyBeforeExpiry: 30 * day,
+		}}, false},
+		{"ok before expiry", args{"azurekms:vault=my-vault;name=my-key?version=my-version", &apiv1.RotationPolicy{
+			ExpiryTime:         90 * day,
+			RotateBeforeExpiry: 36 * time.Hour,
+		}}, false},
+		{"fail empty", args{"", &apiv1.RotationPolicy{}}, true},
+		{"fail nil policy", args{"azurekms:vault=my-vault;name=my-key", nil}, true},
+		{"fail both triggers", args{"azurekms:vault=my-vault;name=my-key", &apiv1.RotationPolicy{
+			RotateAfterCreate:  90 * day,
+			RotateBeforeExpiry: 30 * day,
+		}}, true},
+		{"fail parseKeyName", args{"kms:vault=my-vault;name=my-key", &apiv1.RotationPolicy{}}, true},
+		{"fail vault", args{"azurekms:vault=fail;name=my-key", &apiv1.RotationPolicy{}}, true},
+		{"fail UpdateKeyRotationPolicy", args{"azurekms:vault=my-vault;name=not-found", &apiv1.RotationPolicy{}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			if err := k.SetRotationPolicy(tt.args.name, tt.args.policy); (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.SetRotationPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyVault_RotateKey(t *testing.T) {
+	newECKey, err := keyutil.GenerateSigner("EC", "P-384", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRSAKey, err := keyutil.GenerateSigner("RSA", "", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKID := func(key *azkeys.JSONWebKey, kty azkeys.JSONWebKeyType, kid string) *azkeys.JSONWebKey {
+		key.Kty = pointer(kty)
+		key.KID = pointer(azkeys.ID(kid))
+		return key
+	}
+	newECJWK := withKID(createJWK(t, newECKey.Public()), azkeys.JSONWebKeyTypeECHSM, "https://my-vault.vault.azure.net/keys/ec-key/v2")
+	newRSAJWK := withKID(createJWK(t, newRSAKey.Public()), azkeys.JSONWebKeyTypeRSA, "https://my-vault.vault.azure.net/keys/rsa-key/v2")
+
+	// Key Vault rotates the key and keeps its properties, CreateKey must not
+	// be called.
+	m := mockClient(t)
+	m.EXPECT().RotateKey(gomock.Any(), "ec-key", nil).Return(azkeys.RotateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{
+			Key:  newECJWK,
+			Tags: map[string]*string{"env": pointer("prod")},
+			Attributes: &azkeys.KeyAttributes{
+				Enabled:    &valueTrue,
+				Exportable: &valueTrue,
+			},
+			ReleasePolicy: &azkeys.KeyReleasePolicy{
+				EncodedPolicy: []byte(`{"version":"1.0.0"}`),
+			},
+		},
+	}, nil)
+	m.EXPECT().RotateKey(gomock.Any(), "rsa-key", nil).Return(azkeys.RotateKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Key: newRSAJWK},
+	}, nil)
+	m.EXPECT().RotateKey(gomock.Any(), "not-found", nil).Return(azkeys.RotateKeyResponse{}, errTest)
+	m.EXPECT().RotateKey(gomock.Any(), "nil-key", nil).Return(azkeys.RotateKeyResponse{}, nil)
+
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	tests := []struct {
+		name    string
+		oldName string
+		want    *apiv1.CreateKeyResponse
+		wantErr bool
+	}{
+		{"ok ec hsm", "azurekms:vault=my-vault;name=ec-key", &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=ec-key;vault=my-vault?version=v2",
+			PublicKey: newECKey.Public(),
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=ec-key;vault=my-vault?version=v2",
+			},
+		}, false},
+		{"ok rsa", "azurekms:vault=my-vault;name=rsa-key", &apiv1.CreateKeyResponse{
+			Name:      "azurekms:name=rsa-key;vault=my-vault?version=v2",
+			PublicKey: newRSAKey.Public(),
+			CreateSignerRequest: apiv1.CreateSignerRequest{
+				SigningKey: "azurekms:name=rsa-key;vault=my-vault?version=v2",
+			},
+		}, false},
+		{"fail empty", "", nil, true},
+		{"fail parseKeyName", "kms:vault=my-vault;name=my-key", nil, true},
+		{"fail version", "azurekms:vault=my-vault;name=ec-key?version=v1", nil, true},
+		{"fail vault", "azurekms:vault=fail;name=my-key", nil, true},
+		{"fail RotateKey", "azurekms:vault=my-vault;name=not-found", nil, true},
+		{"fail convertKey", "azurekms:vault=my-vault;name=nil-key", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			got, err := apiv1.RotateKey(k, tt.oldName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("apiv1.RotateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apiv1.RotateKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_Capabilities(t *testing.T) {
+	want := apiv1.Capabilities{
+		CreateKey:       true,
+		CreateSigner:    true,
+		CreateDecrypter: true,
+		Verify:          true,
+		WrapKey:         true,
+	}
+	k := &KeyVault{}
+	if got := k.Capabilities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("KeyVault.Capabilities() = %v, want %v", got, want)
+	}
+	if got := apiv1.GetCapabilities(k); !reflect.DeepEqual(got, want) {
+		t.Errorf("apiv1.GetCapabilities() = %v, want %v", got, want)
+	}
+}
+
+func TestKeyVault_SupportedAlgorithms(t *testing.T) {
+	rsaBits := []int{2048, 3072, 4096}
+	want := []apiv1.SupportedAlgorithm{
+		{SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.SHA384WithRSA, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.SHA512WithRSA, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.SHA256WithRSAPSS, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.SHA384WithRSAPSS, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.SHA512WithRSAPSS, Bits: rsaBits},
+		{SignatureAlgorithm: apiv1.ECDSAWithSHA256, Curves: []string{"P-256"}},
+		{SignatureAlgorithm: apiv1.ECDSAWithSHA384, Curves: []string{"P-384"}},
+		{SignatureAlgorithm: apiv1.ECDSAWithSHA512, Curves: []string{"P-521"}},
+		{SignatureAlgorithm: apiv1.ECDSAWithSHA256K, Curves: []string{"P-256K"}},
+	}
+	k := &KeyVault{}
+	if got := k.SupportedAlgorithms(); !reflect.DeepEqual(got, want) {
+		t.Errorf("KeyVault.SupportedAlgorithms() = %v, want %v", got, want)
+	}
+	got, ok := apiv1.SupportedAlgorithms(k)
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("apiv1.SupportedAlgorithms() = %v, %v, want %v, true", got, ok, want)
+	}
+
+	// All the reported algorithms and sizes must pass the CreateKey checks.
+	for _, alg := range got {
+		for _, bits := range alg.Bits {
+			req := &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: alg.SignatureAlgorithm, Bits: bits}
+			if err := k.ValidateCreateKey(req); err != nil {
+				t.Errorf("KeyVault.ValidateCreateKey() error = %v", err)
+			}
+		}
+		if len(alg.Curves) > 0 {
+			req := &apiv1.CreateKeyRequest{Name: "azurekms:vault=my-vault;name=my-key", SignatureAlgorithm: alg.SignatureAlgorithm}
+			if err := k.ValidateCreateKey(req); err != nil {
+				t.Errorf("KeyVault.ValidateCreateKey() error = %v", err)
+			}
+		}
+	}
+}
+
+func TestKeyVault_Close(t *testing.T) {
+	m := mockClient(t)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		return m, nil
+	})
+
+	type fields struct {
+		client *lazyClient
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{"ok", fields{client}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: tt.fields.client,
+			}
+			if err := k.Close(); (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.Close() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyVault_Close_closed(t *testing.T) {
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{}, errTest)
+	k := &KeyVault{
+		client:  newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) { return m, nil }),
+		secrets: newLazySecretsClient("vault.azure.net", lazySecretsClientCreator(fakeTokenCredential{}, nil)),
+	}
+
+	// Populate the cache before closing the KeyVault.
+	_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "azurekms:vault=my-vault;name=my-key"})
+	if err == nil || errors.Is(err, apiv1.ErrClosed) {
+		t.Fatalf("KeyVault.GetPublicKey() error = %v, want errTest", err)
+	}
+	if len(k.client.clients) != 1 {
+		t.Fatalf("lazyClient.clients = %v, want one client", k.client.clients)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := k.Close(); err != nil {
+			t.Fatalf("KeyVault.Close() error = %v", err)
+		}
+	}
+	if len(k.client.clients) != 0 {
+		t.Errorf("lazyClient.clients = %v, want empty", k.client.clients)
+	}
+
+	name := "azurekms:vault=my-vault;name=my-key"
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetPublicKey", func() error {
+			_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateKey", func() error {
+			_, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateSigner", func() error {
+			_, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: name})
+			return err
+		}},
+		{"GetSecret", func() error {
+			_, err := k.GetSecret("azurekms:vault=my-vault;secret=my-secret")
+			return err
+		}},
+		{"Verify", func() error {
+			_, err := k.Verify(name, []byte("message"), []byte("signature"), apiv1.ECDSAWithSHA256)
+			return err
+		}},
+		{"GetRotationPolicy", func() error {
+			_, err := k.GetRotationPolicy(name)
+			return err
+		}},
+		{"SetRotationPolicy", func() error {
+			return k.SetRotationPolicy(name, &apiv1.RotationPolicy{})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, apiv1.ErrClosed) {
+				t.Errorf("KeyVault.%s() error = %v, want apiv1.ErrClosed", tt.name, err)
 			}
 		})
 	}
@@ -746,6 +2286,161 @@ func TestKeyVault_ValidateName(t *testing.T) {
 	}
 }
 
+func TestKeyVault_ParseURI(t *testing.T) {
+	type fields struct {
+		defaults defaultOptions
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		s       string
+		want    KeyURI
+		wantErr bool
+	}{
+		{"ok", fields{defaultOptions{}}, "azurekms:name=my-key;vault=my-vault", KeyURI{
+			Vault: "my-vault", Name: "my-key", VaultURL: "https://my-vault.vault.azure.net/",
+		}, false},
+		{"ok version", fields{defaultOptions{}}, "azurekms:name=my-key;vault=my-vault?version=my-version", KeyURI{
+			Vault: "my-vault", Name: "my-key", Version: "my-version", VaultURL: "https://my-vault.vault.azure.net/",
+		}, false},
+		{"ok hsm", fields{defaultOptions{}}, "azurekms:name=my-key;vault=my-vault?hsm=true", KeyURI{
+			Vault: "my-vault", Name: "my-key", HSM: true, VaultURL: "https://my-vault.vault.azure.net/",
+		}, false},
+		{"ok version and hsm", fields{defaultOptions{}}, "azurekms:vault=my-vault;name=my-key?version=my-version&hsm=true", KeyURI{
+			Vault: "my-vault", Name: "my-key", Version: "my-version", HSM: true, VaultURL: "https://my-vault.vault.azure.net/",
+		}, false},
+		{"ok defaults", fields{defaultOptions{Vault: "default-vault", DNSSuffix: "vault.azure.cn", ProtectionLevel: apiv1.HSM}}, "azurekms:name=my-key", KeyURI{
+			Vault: "default-vault", Name: "my-key", HSM: true, VaultURL: "https://default-vault.vault.azure.cn/",
+		}, false},
+		{"ok override defaults", fields{defaultOptions{Vault: "default-vault", DNSSuffix: "vault.usgovcloudapi.net", ProtectionLevel: apiv1.HSM}}, "azurekms:name=my-key;vault=my-vault?hsm=false", KeyURI{
+			Vault: "my-vault", Name: "my-key", VaultURL: "https://my-vault.vault.usgovcloudapi.net/",
+		}, false},
+		{"fail scheme", fields{defaultOptions{}}, "kms:name=my-key;vault=my-vault", KeyURI{}, true},
+		{"fail name", fields{defaultOptions{}}, "azurekms:vault=my-vault", KeyURI{}, true},
+		{"fail vault", fields{defaultOptions{}}, "azurekms:name=my-key", KeyURI{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				defaults: tt.fields.defaults,
+			}
+			got, err := k.ParseURI(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.ParseURI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.ParseURI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_ValidateNameExists(t *testing.T) {
+	valueFalse := false
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Attributes: &azkeys.KeyAttributes{Enabled: &valueTrue}},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "my-version", nil).Return(azkeys.GetKeyResponse{}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "not-found", "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 404, ErrorCode: "KeyNotFound"})
+	m.EXPECT().GetKey(gomock.Any(), "disabled", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{Attributes: &azkeys.KeyAttributes{Enabled: &valueFalse}},
+	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "forbidden", "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 403, ErrorCode: "Forbidden"})
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var _ apiv1.ExistingNameValidator = (*KeyVault)(nil)
+	tests := []struct {
+		name         string
+		s            string
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{"ok", "azurekms:name=my-key;vault=my-vault", false, false},
+		{"ok version", "azurekms:name=my-key;vault=my-vault?version=my-version", false, false},
+		{"fail not found", "azurekms:name=not-found;vault=my-vault", true, true},
+		{"fail disabled", "azurekms:name=disabled;vault=my-vault", true, false},
+		{"fail forbidden", "azurekms:name=forbidden;vault=my-vault", true, false},
+		{"fail parse", "azurekms:vault=my-vault", true, false},
+		{"fail vault", "azurekms:name=my-key;vault=fail", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client: client,
+			}
+			err := k.ValidateNameExists(context.Background(), tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.ValidateNameExists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var nfe apiv1.NotFoundError
+			if errors.As(err, &nfe) != tt.wantNotFound {
+				t.Errorf("KeyVault.ValidateNameExists() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestKeyVault_Ping(t *testing.T) {
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 404, ErrorCode: "KeyNotFound"})
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, nil)
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, &azidentity.AuthenticationFailedError{})
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 403, ErrorCode: "Forbidden"})
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, &azcore.ResponseError{StatusCode: 503})
+	m.EXPECT().GetKey(gomock.Any(), pingKeyName, "", nil).Return(azkeys.GetKeyResponse{}, errTest)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return m, nil
+	})
+
+	var _ apiv1.Pinger = (*KeyVault)(nil)
+	tests := []struct {
+		name                 string
+		vault                string
+		wantErr              bool
+		wantPermissionDenied bool
+		wantUnavailable      bool
+	}{
+		{"ok not found", "my-vault", false, false, false},
+		{"ok found", "my-vault", false, false, false},
+		{"fail authentication", "my-vault", true, true, false},
+		{"fail forbidden", "my-vault", true, true, false},
+		{"fail service unavailable", "my-vault", true, false, true},
+		{"fail network", "my-vault", true, false, true},
+		{"fail client", "fail", true, false, false},
+		{"fail no vault", "", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				client:   client,
+				defaults: defaultOptions{Vault: tt.vault},
+			}
+			err := k.Ping(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var pde apiv1.PermissionDeniedError
+			if errors.As(err, &pde) != tt.wantPermissionDenied {
+				t.Errorf("KeyVault.Ping() error = %v, wantPermissionDenied %v", err, tt.wantPermissionDenied)
+			}
+			var ue apiv1.UnavailableError
+			if errors.As(err, &ue) != tt.wantUnavailable {
+				t.Errorf("KeyVault.Ping() error = %v, wantUnavailable %v", err, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
 func Test_getCloudConfiguration(t *testing.T) {
 	germanCloud := cloud.Configuration{
 		ActiveDirectoryAuthorityHost: "https://login.microsoftonline.de/",
@@ -785,3 +2480,42 @@ func Test_getCloudConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func Test_getCloudConfigurationFromURI(t *testing.T) {
+	privateCloud := cloud.AzurePublic
+	privateCloud.ActiveDirectoryAuthorityHost = "https://login.azurestack.example/"
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    cloudConfiguration
+		wantErr bool
+	}{
+		{"ok", "azurekms:", cloudConfiguration{Configuration: cloud.AzurePublic, DNSSuffix: "vault.azure.net"}, false},
+		{"ok environment", "azurekms:environment=usgov", cloudConfiguration{Configuration: cloud.AzureGovernment, DNSSuffix: "vault.usgovcloudapi.net"}, false},
+		{"ok dns-suffix", "azurekms:environment=usgov;dns-suffix=vault.azurestack.example", cloudConfiguration{Configuration: cloud.AzureGovernment, DNSSuffix: "vault.azurestack.example"}, false},
+		{"ok vault-base-url", "azurekms:vault-base-url=https%3A%2F%2Fmy-vault.vault.azurestack.example", cloudConfiguration{Configuration: cloud.AzurePublic, DNSSuffix: "vault.azurestack.example"}, false},
+		{"ok aad-endpoint", "azurekms:dns-suffix=vault.azurestack.example;aad-endpoint=https%3A%2F%2Flogin.azurestack.example%2F", cloudConfiguration{Configuration: privateCloud, DNSSuffix: "vault.azurestack.example"}, false},
+		{"fail environment", "azurekms:environment=fake;dns-suffix=vault.azurestack.example", cloudConfiguration{}, true},
+		{"fail dns-suffix", "azurekms:dns-suffix=https%3A%2F%2Fvault.azurestack.example", cloudConfiguration{}, true},
+		{"fail dns-suffix and vault-base-url", "azurekms:dns-suffix=vault.azurestack.example;vault-base-url=https%3A%2F%2Fmy-vault.vault.azurestack.example", cloudConfiguration{}, true},
+		{"fail vault-base-url", "azurekms:vault-base-url=my-vault.vault.azurestack.example", cloudConfiguration{}, true},
+		{"fail aad-endpoint", "azurekms:aad-endpoint=http%3A%2F%2Flogin.azurestack.example%2F", cloudConfiguration{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := uri.ParseWithScheme(Scheme, tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := getCloudConfigurationFromURI(u)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCloudConfigurationFromURI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getCloudConfigurationFromURI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,55 +4,184 @@
 package azurekms
 
 import (
+	"container/list"
 	"fmt"
 	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azcertificates"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"go.step.sm/crypto/kms/apiv1"
 )
 
-type lazyClientFunc func(vaultURL string) (KeyVaultClient, error)
+// defaultMaxClients is the default number of vault clients cached by a
+// lazyCache.
+const defaultMaxClients = 100
 
-type lazyClient struct {
-	rw        sync.RWMutex
-	clients   map[string]KeyVaultClient
-	new       lazyClientFunc
-	dnsSuffix string
+// ClientCacheStats are the statistics of the cache of vault clients used by a
+// KeyVault. A miss creates a new client, and an eviction drops the least
+// recently used one when the cache is full.
+type ClientCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
 }
 
-func newLazyClient(dnsSuffix string, fn lazyClientFunc) *lazyClient {
-	return &lazyClient{
-		clients:   make(map[string]KeyVaultClient),
-		new:       fn,
-		dnsSuffix: dnsSuffix,
+type lazyCacheFunc[T any] func(vaultURL string) (T, error)
+
+type lazyCacheEntry[T any] struct {
+	vaultURL string
+	client   T
+}
+
+// lazyCache is a cache of vault clients by vault URL. It is safe for concurrent
+// use, and it keeps at most maxClients clients, evicting the least recently
+// used one when a new vault is requested.
+type lazyCache[T any] struct {
+	mu         sync.Mutex
+	clients    map[string]*list.Element
+	lru        *list.List
+	new        lazyCacheFunc[T]
+	kind       string
+	dnsSuffix  string
+	maxClients int
+	stats      ClientCacheStats
+	closed     bool
+}
+
+func newLazyCache[T any](kind, dnsSuffix string, fn lazyCacheFunc[T]) *lazyCache[T] {
+	return &lazyCache[T]{
+		clients:    make(map[string]*list.Element),
+		lru:        list.New(),
+		new:        fn,
+		kind:       kind,
+		dnsSuffix:  dnsSuffix,
+		maxClients: defaultMaxClients,
 	}
 }
 
-func (l *lazyClient) Get(vault string) (KeyVaultClient, error) {
+func (l *lazyCache[T]) Get(vault string) (T, error) {
+	var zero T
 	vaultURL := vaultBaseURL(vault, l.dnsSuffix)
 	// Get an already initialize client
-	l.rw.RLock()
-	c, ok := l.clients[vaultURL]
-	l.rw.RUnlock()
-	if ok {
-		return c, nil
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return zero, apiv1.ErrClosed
+	}
+	if e, ok := l.clients[vaultURL]; ok {
+		l.lru.MoveToFront(e)
+		l.stats.Hits++
+		l.mu.Unlock()
+		return e.Value.(*lazyCacheEntry[T]).client, nil
 	}
+	l.stats.Misses++
+	l.mu.Unlock()
 
 	// Create a new client
 	c, err := l.new(vaultURL)
 	if err != nil {
-		return nil, fmt.Errorf("error creating client for vault %q: %w", vaultURL, err)
+		return zero, fmt.Errorf("error creating %s for vault %q: %w", l.kind, vaultURL, err)
 	}
 
-	l.rw.Lock()
-	l.clients[vaultURL] = c
-	l.rw.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return zero, apiv1.ErrClosed
+	}
+	// Another goroutine might have created the client in the meantime.
+	if e, ok := l.clients[vaultURL]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*lazyCacheEntry[T]).client, nil
+	}
+	l.clients[vaultURL] = l.lru.PushFront(&lazyCacheEntry[T]{
+		vaultURL: vaultURL,
+		client:   c,
+	})
+	for l.maxClients > 0 && l.lru.Len() > l.maxClients {
+		e := l.lru.Back()
+		l.lru.Remove(e)
+		delete(l.clients, e.Value.(*lazyCacheEntry[T]).vaultURL)
+		l.stats.Evictions++
+	}
 	return c, nil
 }
 
-func lazyClientCreator(credential azcore.TokenCredential) lazyClientFunc {
+// Stats returns the statistics of the cache.
+func (l *lazyCache[T]) Stats() ClientCacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Size = l.lru.Len()
+	return stats
+}
+
+// Close drops the cached clients, and makes Get fail with apiv1.ErrClosed.
+func (l *lazyCache[T]) Close() {
+	l.mu.Lock()
+	l.clients = make(map[string]*list.Element)
+	l.lru.Init()
+	l.closed = true
+	l.mu.Unlock()
+}
+
+type (
+	lazyClientFunc             = lazyCacheFunc[KeyVaultClient]
+	lazySecretsClientFunc      = lazyCacheFunc[SecretsClient]
+	lazyCertificatesClientFunc = lazyCacheFunc[CertificatesClient]
+)
+
+type (
+	lazyClient             = lazyCache[KeyVaultClient]
+	lazySecretsClient      = lazyCache[SecretsClient]
+	lazyCertificatesClient = lazyCache[CertificatesClient]
+)
+
+func newLazyClient(dnsSuffix string, fn lazyClientFunc) *lazyClient {
+	return newLazyCache("client", dnsSuffix, fn)
+}
+
+func newLazySecretsClient(dnsSuffix string, fn lazySecretsClientFunc) *lazySecretsClient {
+	return newLazyCache("secrets client", dnsSuffix, fn)
+}
+
+func newLazyCertificatesClient(dnsSuffix string, fn lazyCertificatesClientFunc) *lazyCertificatesClient {
+	return newLazyCache("certificates client", dnsSuffix, fn)
+}
+
+func lazyClientCreator(credential azcore.TokenCredential, transport policy.Transporter) lazyClientFunc {
 	return func(vaultURL string) (KeyVaultClient, error) {
 		return azkeys.NewClient(vaultURL, credential, &azkeys.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: transport,
+			},
+			// See https://aka.ms/azsdk/blog/vault-uri
+			DisableChallengeResourceVerification: true,
+		})
+	}
+}
+
+func lazySecretsClientCreator(credential azcore.TokenCredential, transport policy.Transporter) lazySecretsClientFunc {
+	return func(vaultURL string) (SecretsClient, error) {
+		return azsecrets.NewClient(vaultURL, credential, &azsecrets.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: transport,
+			},
+			// See https://aka.ms/azsdk/blog/vault-uri
+			DisableChallengeResourceVerification: true,
+		})
+	}
+}
+
+func lazyCertificatesClientCreator(credential azcore.TokenCredential, transport policy.Transporter) lazyCertificatesClientFunc {
+	return func(vaultURL string) (CertificatesClient, error) {
+		return azcertificates.NewClient(vaultURL, credential, &azcertificates.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: transport,
+			},
 			// See https://aka.ms/azsdk/blog/vault-uri
 			DisableChallengeResourceVerification: true,
 		})
@@ -4,10 +4,20 @@
 package azurekms
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"go.step.sm/crypto/kms/apiv1"
 )
 
 func Test_lazyClient_Get(t *testing.T) {
@@ -42,10 +52,9 @@ func Test_lazyClient_Get(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := &lazyClient{
-				clients:   tt.fields.clients,
-				new:       tt.fields.new,
-				dnsSuffix: tt.fields.dnsSuffix,
+			l := newLazyClient(tt.fields.dnsSuffix, tt.fields.new)
+			for k, v := range tt.fields.clients {
+				l.clients[k] = l.lru.PushFront(&lazyCacheEntry[KeyVaultClient]{vaultURL: k, client: v})
 			}
 			got, err := l.Get(tt.args.vault)
 			if (err != nil) != tt.wantErr {
@@ -59,8 +68,85 @@ func Test_lazyClient_Get(t *testing.T) {
 	}
 }
 
+func Test_lazyClient_Get_lru(t *testing.T) {
+	var created []string
+	l := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		created = append(created, vaultURL)
+		return mockClient(t), nil
+	})
+	l.maxClients = 2
+
+	for _, vault := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := l.Get(vault); err != nil {
+			t.Fatalf("lazyClient.Get() error = %v", err)
+		}
+	}
+
+	// "b" is evicted when "c" is added, and "c" when "b" is added again.
+	want := []string{
+		"https://a.vault.azure.net/",
+		"https://b.vault.azure.net/",
+		"https://c.vault.azure.net/",
+		"https://b.vault.azure.net/",
+	}
+	if !reflect.DeepEqual(created, want) {
+		t.Errorf("lazyClient.Get() created = %v, want %v", created, want)
+	}
+	if _, ok := l.clients["https://c.vault.azure.net/"]; ok {
+		t.Error("lazyClient.Get() did not evict vault c")
+	}
+	wantStats := ClientCacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}
+	if got := l.Stats(); got != wantStats {
+		t.Errorf("lazyClient.Stats() = %+v, want %+v", got, wantStats)
+	}
+}
+
+func Test_lazyClient_Get_concurrent(t *testing.T) {
+	const vaults, workers, iterations = 20, 16, 200
+
+	var created int64
+	client := mockClient(t)
+	l := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		atomic.AddInt64(&created, 1)
+		return client, nil
+	})
+	l.maxClients = vaults / 2
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				vault := fmt.Sprintf("vault-%d", (w+i)%vaults)
+				c, err := l.Get(vault)
+				if err != nil {
+					t.Errorf("lazyClient.Get() error = %v", err)
+					return
+				}
+				if c == nil {
+					t.Error("lazyClient.Get() = nil")
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := l.Stats()
+	if stats.Hits+stats.Misses != workers*iterations {
+		t.Errorf("lazyClient.Stats() hits + misses = %d, want %d", stats.Hits+stats.Misses, workers*iterations)
+	}
+	if stats.Size > vaults/2 || stats.Size != len(l.clients) {
+		t.Errorf("lazyClient.Stats() size = %d, clients = %d, want at most %d", stats.Size, len(l.clients), vaults/2)
+	}
+	if uint64(atomic.LoadInt64(&created)) != stats.Misses {
+		t.Errorf("lazyClient.Get() created %d clients, want %d", created, stats.Misses)
+	}
+}
+
 func Test_lazyClientCreator(t *testing.T) {
-	fn := lazyClientCreator(fakeTokenCredential{})
+	fn := lazyClientCreator(fakeTokenCredential{}, nil)
 	client, err := fn("https://test.vault.azure.net")
 	if err != nil {
 		t.Errorf("lazyClientCreator() error = %v", err)
@@ -69,3 +155,125 @@ func Test_lazyClientCreator(t *testing.T) {
 		t.Errorf("lazyClientCreator() = %T, want *azkeys.Client", client)
 	}
 }
+
+// fakeTransporter is a policy.Transporter that records the requests.
+type fakeTransporter struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (f *fakeTransporter) Do(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.hosts = append(f.hosts, req.URL.Host)
+	f.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"KeyNotFound","message":"not found"}}`)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeTransporter) Hosts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.hosts...)
+}
+
+func Test_lazyClientCreator_transport(t *testing.T) {
+	transport := new(fakeTransporter)
+	client, err := lazyClientCreator(fakeTokenCredential{}, transport)("https://test.vault.azure.net")
+	if err != nil {
+		t.Fatalf("lazyClientCreator() error = %v", err)
+	}
+	if _, err := client.GetKey(context.Background(), "my-key", "", nil); err == nil {
+		t.Error("KeyVaultClient.GetKey() error = nil, want error")
+	}
+	if hosts := transport.Hosts(); len(hosts) == 0 || hosts[0] != "test.vault.azure.net" {
+		t.Errorf("lazyClientCreator() transport hosts = %v, want [test.vault.azure.net]", hosts)
+	}
+
+	secretsTransport := new(fakeTransporter)
+	secrets, err := lazySecretsClientCreator(fakeTokenCredential{}, secretsTransport)("https://test.vault.azure.net")
+	if err != nil {
+		t.Fatalf("lazySecretsClientCreator() error = %v", err)
+	}
+	if _, err := secrets.GetSecret(context.Background(), "my-secret", "", nil); err == nil {
+		t.Error("SecretsClient.GetSecret() error = nil, want error")
+	}
+	if hosts := secretsTransport.Hosts(); len(hosts) == 0 || hosts[0] != "test.vault.azure.net" {
+		t.Errorf("lazySecretsClientCreator() transport hosts = %v, want [test.vault.azure.net]", hosts)
+	}
+
+	certsTransport := new(fakeTransporter)
+	certs, err := lazyCertificatesClientCreator(fakeTokenCredential{}, certsTransport)("https://test.vault.azure.net")
+	if err != nil {
+		t.Fatalf("lazyCertificatesClientCreator() error = %v", err)
+	}
+	if _, err := certs.GetCertificate(context.Background(), "my-cert", "", nil); err == nil {
+		t.Error("CertificatesClient.GetCertificate() error = nil, want error")
+	}
+	if hosts := certsTransport.Hosts(); len(hosts) == 0 || hosts[0] != "test.vault.azure.net" {
+		t.Errorf("lazyCertificatesClientCreator() transport hosts = %v, want [test.vault.azure.net]", hosts)
+	}
+}
+
+func Test_lazySecretsClient_Get(t *testing.T) {
+	client := &fakeSecretsClient{}
+	l := newLazySecretsClient("vault.azure.net", func(vaultURL string) (SecretsClient, error) {
+		if vaultURL == "https://fail.vault.azure.net/" {
+			return nil, errTest
+		}
+		return client, nil
+	})
+
+	got, err := l.Get("test")
+	if err != nil {
+		t.Fatalf("lazySecretsClient.Get() error = %v", err)
+	}
+	if got != client {
+		t.Errorf("lazySecretsClient.Get() = %v, want %v", got, client)
+	}
+	if _, ok := l.clients["https://test.vault.azure.net/"]; !ok {
+		t.Error("lazySecretsClient.Get() did not cache the client")
+	}
+	if _, err := l.Get("fail"); err == nil {
+		t.Error("lazySecretsClient.Get() error = nil, want error")
+	}
+}
+
+func Test_lazyCertificatesClient_Get_lru(t *testing.T) {
+	l := newLazyCertificatesClient("vault.azure.net", func(vaultURL string) (CertificatesClient, error) {
+		return &fakeCertificatesClient{}, nil
+	})
+	l.maxClients = 1
+
+	for _, vault := range []string{"a", "a", "b"} {
+		if _, err := l.Get(vault); err != nil {
+			t.Fatalf("lazyCertificatesClient.Get() error = %v", err)
+		}
+	}
+	if _, ok := l.clients["https://a.vault.azure.net/"]; ok {
+		t.Error("lazyCertificatesClient.Get() did not evict vault a")
+	}
+	wantStats := ClientCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}
+	if got := l.Stats(); got != wantStats {
+		t.Errorf("lazyCertificatesClient.Stats() = %+v, want %+v", got, wantStats)
+	}
+
+	l.Close()
+	if _, err := l.Get("a"); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("lazyCertificatesClient.Get() error = %v, want %v", err, apiv1.ErrClosed)
+	}
+}
+
+func Test_lazySecretsClientCreator(t *testing.T) {
+	fn := lazySecretsClientCreator(fakeTokenCredential{}, nil)
+	client, err := fn("https://test.vault.azure.net")
+	if err != nil {
+		t.Errorf("lazySecretsClientCreator() error = %v", err)
+	}
+	if _, ok := client.(*azsecrets.Client); !ok {
+		t.Errorf("lazySecretsClientCreator() = %T, want *azsecrets.Client", client)
+	}
+}
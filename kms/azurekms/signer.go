@@ -4,34 +4,52 @@
 package azurekms
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"io"
-	"math/big"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/cryptobyte"
-	"golang.org/x/crypto/cryptobyte/asn1"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
 )
 
-// Signer implements a crypto.Signer using the AWS KMS.
+// Signer implements a crypto.Signer using the Azure Key Vault.
 type Signer struct {
-	client    KeyVaultClient
-	name      string
-	version   string
-	publicKey crypto.PublicKey
+	client       KeyVaultClient
+	name         string
+	version      string
+	publicKey    crypto.PublicKey
+	rawSignature bool
 }
 
-// NewSigner creates a new signer using a key in the AWS KMS.
+// NewSigner creates a new signer using a key in the Azure Key Vault.
+//
+// By default, ECDSA signatures are returned ASN.1 DER encoded, as any other
+// crypto.Signer does. JOSE callers can add the "signature-format=raw" parameter
+// to the signing key URI to get the concat(R,S) signature returned by Azure.
 func NewSigner(lazyClient *lazyClient, signingKey string, defaults defaultOptions) (crypto.Signer, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return newSigner(ctx, lazyClient, signingKey, defaults)
+}
+
+func newSigner(ctx context.Context, lazyClient *lazyClient, signingKey string, defaults defaultOptions) (crypto.Signer, error) {
 	vaultURL, name, version, _, err := parseKeyName(signingKey, defaults)
 	if err != nil {
 		return nil, err
 	}
+	rawSignature, err := parseSignatureFormat(signingKey)
+	if err != nil {
+		return nil, err
+	}
 
 	client, err := lazyClient.Get(vaultURL)
 	if err != nil {
@@ -40,24 +58,22 @@ func NewSigner(lazyClient *lazyClient, signingKey string, defaults defaultOption
 
 	// Make sure that the key exists.
 	signer := &Signer{
-		client:  client,
-		name:    name,
-		version: version,
+		client:       client,
+		name:         name,
+		version:      version,
+		rawSignature: rawSignature,
 	}
-	if err := signer.preloadKey(); err != nil {
+	if err := signer.preloadKey(ctx); err != nil {
 		return nil, err
 	}
 
 	return signer, nil
 }
 
-func (s *Signer) preloadKey() error {
-	ctx, cancel := defaultContext()
-	defer cancel()
-
+func (s *Signer) preloadKey(ctx context.Context) error {
 	resp, err := s.client.GetKey(ctx, s.name, s.version, nil)
 	if err != nil {
-		return errors.Wrap(err, "keyVault GetKey failed")
+		return errors.Wrap(convertError(err), "keyVault GetKey failed")
 	}
 
 	s.publicKey, err = convertKey(resp.Key)
@@ -69,48 +85,67 @@ func (s *Signer) Public() crypto.PublicKey {
 	return s.publicKey
 }
 
-// Sign signs digest with the private key stored in the Azure Key Vault.
+// JOSEAlgorithm returns the default JOSE signature algorithm for the key of
+// this signer, e.g. ES256 for a P-256 key or RS256 for an RSA key.
+func (s *Signer) JOSEAlgorithm() string {
+	return apiv1.DefaultJOSEAlgorithm(s.publicKey)
+}
+
+// Sign signs digest with the private key stored in the Azure Key Vault. Ed25519
+// keys sign the full message, so opts.HashFunc() must be crypto.Hash(0).
+//
+// The signature algorithm is selected on each call using opts, so the same RSA
+// key can sign using RSASSA-PKCS1-v1_5, e.g. RS256 with crypto.SHA256, or
+// RSASSA-PSS, e.g. PS256 with an *rsa.PSSOptions using crypto.SHA256.
 func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return s.SignContext(ctx, rand, digest, opts)
+}
+
+// SignContext signs digest with the private key stored in the Azure Key Vault
+// using the given context.
+func (s *Signer) SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	alg, err := getSigningAlgorithm(s.Public(), opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Sign with retry if the key is not ready
-	resp, err := s.signWithRetry(alg, digest, 3)
+	resp, err := s.signWithRetry(ctx, alg, digest, 3)
 	if err != nil {
-		return nil, errors.Wrap(err, "keyVault Sign failed")
+		return nil, errors.Wrap(convertError(err), "keyVault Sign failed")
 	}
 
-	var octetSize int
 	switch alg {
-	case azkeys.JSONWebKeySignatureAlgorithmES256:
-		octetSize = 32 // 256-bit, concat(R,S) = 64 bytes
-	case azkeys.JSONWebKeySignatureAlgorithmES384:
-		octetSize = 48 // 384-bit, concat(R,S) = 96 bytes
-	case azkeys.JSONWebKeySignatureAlgorithmES512:
-		octetSize = 66 // 528-bit, concat(R,S) = 132 bytes
+	case signatureAlgorithmEdDSA:
+		if len(resp.Result) != ed25519.SignatureSize {
+			return nil, errors.Errorf("keyVault Sign failed: unexpected signature length")
+		}
+		return resp.Result, nil
+	case azkeys.JSONWebKeySignatureAlgorithmES256, azkeys.JSONWebKeySignatureAlgorithmES384,
+		azkeys.JSONWebKeySignatureAlgorithmES512, azkeys.JSONWebKeySignatureAlgorithmES256K:
+		// Azure returns the concat(R,S) format used by JOSE.
+		pub, ok := s.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("keyVault Sign failed: unexpected public key type %T", s.Public())
+		}
+		der, err := keyutil.ECDSASigFromRS(resp.Result, pub.Curve)
+		if err != nil {
+			return nil, errors.Wrap(err, "keyVault Sign failed")
+		}
+		if s.rawSignature {
+			return resp.Result, nil
+		}
+		return der, nil
 	default:
 		return resp.Result, nil
 	}
-
-	// Convert to asn1
-	if len(resp.Result) != octetSize*2 {
-		return nil, errors.Errorf("keyVault Sign failed: unexpected signature length")
-	}
-	var b cryptobyte.Builder
-	b.AddASN1(asn1.SEQUENCE, func(b *cryptobyte.Builder) {
-		b.AddASN1BigInt(new(big.Int).SetBytes(resp.Result[:octetSize])) // R
-		b.AddASN1BigInt(new(big.Int).SetBytes(resp.Result[octetSize:])) // S
-	})
-	return b.Bytes()
 }
 
-func (s *Signer) signWithRetry(alg azkeys.JSONWebKeySignatureAlgorithm, digest []byte, retryAttempts int) (azkeys.SignResponse, error) {
+func (s *Signer) signWithRetry(ctx context.Context, alg azkeys.JSONWebKeySignatureAlgorithm, digest []byte, retryAttempts int) (azkeys.SignResponse, error) {
 retry:
-	ctx, cancel := defaultContext()
-	defer cancel()
-
 	resp, err := s.client.Sign(ctx, s.name, s.version, azkeys.SignParameters{
 		Algorithm: &alg,
 		Value:     digest,
@@ -119,7 +154,11 @@ retry:
 		var responseError *azcore.ResponseError
 		if errors.As(err, &responseError) {
 			if responseError.StatusCode == 429 {
-				time.Sleep(time.Second / time.Duration(retryAttempts))
+				select {
+				case <-ctx.Done():
+					return resp, ctx.Err()
+				case <-time.After(time.Second / time.Duration(retryAttempts)):
+				}
 				retryAttempts--
 				goto retry
 			}
@@ -161,6 +200,13 @@ func getSigningAlgorithm(key crypto.PublicKey, opts crypto.SignerOpts) (azkeys.J
 			return "", errors.Errorf("unsupported hash function %v", h)
 		}
 	case *ecdsa.PublicKey:
+		// secp256k1 keys only sign with ES256K.
+		if key.(*ecdsa.PublicKey).Curve == secp256k1.S256() {
+			if h := opts.HashFunc(); h != crypto.SHA256 {
+				return "", errors.Errorf("unsupported hash function %v", h)
+			}
+			return azkeys.JSONWebKeySignatureAlgorithmES256K, nil
+		}
 		switch h := opts.HashFunc(); h {
 		case crypto.SHA256:
 			return azkeys.JSONWebKeySignatureAlgorithmES256, nil
@@ -171,6 +217,12 @@ func getSigningAlgorithm(key crypto.PublicKey, opts crypto.SignerOpts) (azkeys.J
 		default:
 			return "", errors.Errorf("unsupported hash function %v", h)
 		}
+	case ed25519.PublicKey:
+		// Ed25519 signs the message, not a digest.
+		if h := opts.HashFunc(); h != crypto.Hash(0) {
+			return "", errors.Errorf("unsupported hash function %v", h)
+		}
+		return signatureAlgorithmEdDSA, nil
 	default:
 		return "", errors.Errorf("unsupported key type %T", key)
 	}
@@ -2,10 +2,14 @@ package azurekms
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
@@ -51,6 +55,11 @@ func TestNewSigner(t *testing.T) {
 			Key: jwk,
 		},
 	}, nil)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{
+			Key: jwk,
+		},
+	}, nil)
 	m.EXPECT().GetKey(gomock.Any(), "not-found", "my-version", nil).Return(azkeys.GetKeyResponse{}, errTest)
 
 	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
@@ -90,7 +99,15 @@ func TestNewSigner(t *testing.T) {
 			version:   "my-version",
 			publicKey: pub,
 		}, false},
+		{"ok with raw signature", args{client, "azurekms:name=my-key;vault=my-vault?signature-format=raw", noOptions}, &Signer{
+			client:       m,
+			name:         "my-key",
+			version:      "",
+			publicKey:    pub,
+			rawSignature: true,
+		}, false},
 		{"fail GetKey", args{client, "azurekms:name=not-found;vault=my-vault?version=my-version", noOptions}, nil, true},
+		{"fail signature format", args{client, "azurekms:name=my-key;vault=my-vault?signature-format=jws", noOptions}, nil, true},
 		{"fail vault", args{client, "azurekms:name=not-found;vault=", noOptions}, nil, true},
 		{"fail id", args{client, "azurekms:name=;vault=my-vault?version=my-version", noOptions}, nil, true},
 		{"fail get client", args{client, "azurekms:vault=fail;name=my-key", noOptions}, nil, true},
@@ -139,6 +156,61 @@ func TestSigner_Public(t *testing.T) {
 	}
 }
 
+func TestSigner_JOSEAlgorithm(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		want string
+	}{
+		{"P-256", p256.Public(), "ES256"},
+		{"P-384", p384.Public(), "ES384"},
+		{"P-521", p521.Public(), "ES512"},
+		{"RSA", rsaKey.Public(), "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mockClient(t)
+			m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+				KeyBundle: azkeys.KeyBundle{
+					Key: createJWK(t, tt.pub),
+				},
+			}, nil)
+			client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+				return m, nil
+			})
+
+			signer, err := NewSigner(client, "azurekms:vault=my-vault;name=my-key", defaultOptions{})
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			s, ok := signer.(apiv1.JOSEAlgorithmSigner)
+			if !ok {
+				t.Fatalf("NewSigner() = %T, want apiv1.JOSEAlgorithmSigner", signer)
+			}
+			if got := s.JOSEAlgorithm(); got != tt.want {
+				t.Errorf("Signer.JOSEAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSigner_Sign(t *testing.T) {
 	sign := func(kty, crv string, bits int, opts crypto.SignerOpts) (crypto.PublicKey, []byte, []byte, []byte) {
 		key, err := keyutil.GenerateSigner(kty, crv, bits)
@@ -193,6 +265,7 @@ func TestSigner_Sign(t *testing.T) {
 	p256, p256Digest, p256ResultSig, p256Sig := sign("EC", "P-256", 0, crypto.SHA256)
 	p384, p384Digest, p386ResultSig, p384Sig := sign("EC", "P-384", 0, crypto.SHA384)
 	p521, p521Digest, p521ResultSig, p521Sig := sign("EC", "P-521", 0, crypto.SHA512)
+	k256, k256Digest, k256ResultSig, k256Sig := sign("EC", "secp256k1", 0, crypto.SHA256)
 	rsaSHA256, rsaSHA256Digest, rsaSHA256ResultSig, rsaSHA256Sig := sign("RSA", "", 2048, crypto.SHA256)
 	rsaSHA384, rsaSHA384Digest, rsaSHA384ResultSig, rsaSHA384Sig := sign("RSA", "", 2048, crypto.SHA384)
 	rsaSHA512, rsaSHA512Digest, rsaSHA512ResultSig, rsaSHA512Sig := sign("RSA", "", 2048, crypto.SHA512)
@@ -213,6 +286,11 @@ func TestSigner_Sign(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	ed25519Message := []byte("message")
+	ed25519Sig, err := ed25519Key.Sign(rand.Reader, ed25519Message, crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	client := mockClient(t)
 	expects := []struct {
@@ -232,6 +310,9 @@ func TestSigner_Sign(t *testing.T) {
 		{"P-521", "my-version", azkeys.JSONWebKeySignatureAlgorithmES512, p521Digest, azkeys.SignResponse{
 			KeyOperationResult: azkeys.KeyOperationResult{Result: p521ResultSig},
 		}, nil},
+		{"secp256k1", "", azkeys.JSONWebKeySignatureAlgorithmES256K, k256Digest, azkeys.SignResponse{
+			KeyOperationResult: azkeys.KeyOperationResult{Result: k256ResultSig},
+		}, nil},
 		{"RSA SHA256", "", azkeys.JSONWebKeySignatureAlgorithmRS256, rsaSHA256Digest, azkeys.SignResponse{
 			KeyOperationResult: azkeys.KeyOperationResult{Result: rsaSHA256ResultSig},
 		}, nil},
@@ -250,8 +331,14 @@ func TestSigner_Sign(t *testing.T) {
 		{"RSA-PSS SHA512", "", azkeys.JSONWebKeySignatureAlgorithmPS512, rsaPSSSHA512Digest, azkeys.SignResponse{
 			KeyOperationResult: azkeys.KeyOperationResult{Result: rsaPSSSHA512ResultSig},
 		}, nil},
+		{"Ed25519", "", signatureAlgorithmEdDSA, ed25519Message, azkeys.SignResponse{
+			KeyOperationResult: azkeys.KeyOperationResult{Result: ed25519Sig},
+		}, nil},
 		// Errors
 		{"fail Sign", "", azkeys.JSONWebKeySignatureAlgorithmRS256, rsaSHA256Digest, azkeys.SignResponse{}, errTest},
+		{"fail Ed25519 sign length", "", signatureAlgorithmEdDSA, []byte("other message"), azkeys.SignResponse{
+			KeyOperationResult: azkeys.KeyOperationResult{Result: rsaSHA256ResultSig},
+		}, nil},
 		{"fail sign length", "", azkeys.JSONWebKeySignatureAlgorithmES256, p256Digest, azkeys.SignResponse{
 			KeyOperationResult: azkeys.KeyOperationResult{Result: rsaSHA256ResultSig},
 		}, nil},
@@ -294,6 +381,9 @@ func TestSigner_Sign(t *testing.T) {
 		{"ok P-521", fields{client, "my-key", "my-version", p521}, args{
 			rand.Reader, p521Digest, crypto.SHA512,
 		}, p521Sig, false},
+		{"ok secp256k1", fields{client, "my-key", "", k256}, args{
+			rand.Reader, k256Digest, crypto.SHA256,
+		}, k256Sig, false},
 		{"ok RSA SHA256", fields{client, "my-key", "", rsaSHA256}, args{
 			rand.Reader, rsaSHA256Digest, crypto.SHA256,
 		}, rsaSHA256Sig, false},
@@ -321,6 +411,9 @@ func TestSigner_Sign(t *testing.T) {
 				Hash:       crypto.SHA512,
 			},
 		}, rsaPSSSHA512Sig, false},
+		{"ok Ed25519", fields{client, "my-key", "", ed25519Key.Public()}, args{
+			rand.Reader, ed25519Message, crypto.Hash(0),
+		}, ed25519Sig, false},
 		{"fail Sign", fields{client, "my-key", "", rsaSHA256}, args{
 			rand.Reader, rsaSHA256Digest, crypto.SHA256,
 		}, nil, true},
@@ -342,9 +435,18 @@ func TestSigner_Sign(t *testing.T) {
 		{"fail ECDSA Hash", fields{client, "my-key", "", p256}, args{
 			rand.Reader, p256Digest, crypto.MD5,
 		}, nil, true},
-		{"fail Ed25519", fields{client, "my-key", "", ed25519Key}, args{
+		{"fail secp256k1 Hash", fields{client, "my-key", "", k256}, args{
+			rand.Reader, p384Digest, crypto.SHA384,
+		}, nil, true},
+		{"fail Ed25519 private key", fields{client, "my-key", "", ed25519Key}, args{
 			rand.Reader, []byte("message"), crypto.Hash(0),
 		}, nil, true},
+		{"fail Ed25519 hash", fields{client, "my-key", "", ed25519Key.Public()}, args{
+			rand.Reader, []byte("message"), crypto.SHA512,
+		}, nil, true},
+		{"fail Ed25519 sign length", fields{client, "my-key", "", ed25519Key.Public()}, args{
+			rand.Reader, []byte("other message"), crypto.Hash(0),
+		}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -366,6 +468,159 @@ func TestSigner_Sign(t *testing.T) {
 	}
 }
 
+func TestSigner_Sign_signatureFormat(t *testing.T) {
+	for _, crv := range []string{"P-256", "P-384", "P-521", "secp256k1"} {
+		t.Run(crv, func(t *testing.T) {
+			key, err := keyutil.GenerateSigner("EC", crv, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv := key.(*ecdsa.PrivateKey)
+			alg, err := getSigningAlgorithm(priv.Public(), crypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest := []byte("01234567890123456789012345678901")
+			der, err := ecdsa.SignASN1(rand.Reader, priv, digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := keyutil.ECDSASigToRS(der, priv.Curve)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := mockClient(t)
+			client.EXPECT().Sign(gomock.Any(), "my-key", "", FuncMatcher(func(x interface{}) bool {
+				p, ok := x.(azkeys.SignParameters)
+				return ok && *p.Algorithm == alg && bytes.Equal(p.Value, digest)
+			}), nil).Return(azkeys.SignResponse{
+				KeyOperationResult: azkeys.KeyOperationResult{Result: raw},
+			}, nil).Times(2)
+
+			// Default format is ASN.1 DER.
+			s := &Signer{client: client, name: "my-key", publicKey: priv.Public()}
+			got, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+			if err != nil {
+				t.Fatalf("Signer.Sign() error = %v", err)
+			}
+			if !ecdsa.VerifyASN1(&priv.PublicKey, digest, got) {
+				t.Errorf("Signer.Sign() = %x, failed to verify ASN.1 signature", got)
+			}
+
+			// Raw format returns the Azure signature.
+			s.rawSignature = true
+			got, err = s.Sign(rand.Reader, digest, crypto.SHA256)
+			if err != nil {
+				t.Fatalf("Signer.Sign() error = %v", err)
+			}
+			if !bytes.Equal(got, raw) {
+				t.Errorf("Signer.Sign() = %x, want %x", got, raw)
+			}
+		})
+	}
+}
+
+func TestSigner_Sign_rsaSchemes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mock signs with the local key using the requested algorithm.
+	client := mockClient(t)
+	client.EXPECT().Sign(gomock.Any(), "my-key", "", gomock.Any(), nil).DoAndReturn(
+		func(_ context.Context, _, _ string, p azkeys.SignParameters, _ *azkeys.SignOptions) (azkeys.SignResponse, error) {
+			var opts crypto.SignerOpts
+			switch *p.Algorithm {
+			case azkeys.JSONWebKeySignatureAlgorithmRS256:
+				opts = crypto.SHA256
+			case azkeys.JSONWebKeySignatureAlgorithmRS384:
+				opts = crypto.SHA384
+			case azkeys.JSONWebKeySignatureAlgorithmPS256:
+				opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+			case azkeys.JSONWebKeySignatureAlgorithmPS512:
+				opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+			default:
+				return azkeys.SignResponse{}, errTest
+			}
+			sig, err := key.Sign(rand.Reader, p.Value, opts)
+			if err != nil {
+				return azkeys.SignResponse{}, err
+			}
+			return azkeys.SignResponse{
+				KeyOperationResult: azkeys.KeyOperationResult{Result: sig},
+			}, nil
+		}).Times(4)
+
+	s := &Signer{client: client, name: "my-key", publicKey: key.Public()}
+	tests := []struct {
+		name   string
+		opts   crypto.SignerOpts
+		verify func(digest, sig []byte) error
+	}{
+		{"RS256", crypto.SHA256, func(digest, sig []byte) error {
+			return rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig)
+		}},
+		{"RS384", crypto.SHA384, func(digest, sig []byte) error {
+			return rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA384, digest, sig)
+		}},
+		{"PS256", &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: crypto.SHA256}, func(digest, sig []byte) error {
+			return rsa.VerifyPSS(&key.PublicKey, crypto.SHA256, digest, sig, nil)
+		}},
+		{"PS512", &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, func(digest, sig []byte) error {
+			return rsa.VerifyPSS(&key.PublicKey, crypto.SHA512, digest, sig, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.opts.HashFunc().New()
+			h.Write([]byte("the message"))
+			digest := h.Sum(nil)
+
+			sig, err := s.Sign(rand.Reader, digest, tt.opts)
+			if err != nil {
+				t.Fatalf("Signer.Sign() error = %v", err)
+			}
+			if err := tt.verify(digest, sig); err != nil {
+				t.Errorf("Signer.Sign() signature verification failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewSigner_ed25519(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := mockClient(t)
+	m.EXPECT().GetKey(gomock.Any(), "my-key", "", nil).Return(azkeys.GetKeyResponse{
+		KeyBundle: azkeys.KeyBundle{
+			Key: &azkeys.JSONWebKey{
+				Kty: pointer(jsonWebKeyTypeOKP),
+				Crv: pointer(jsonWebKeyCurveNameEd25519),
+				X:   pub,
+			},
+		},
+	}, nil)
+	client := newLazyClient("vault.azure.net", func(vaultURL string) (KeyVaultClient, error) {
+		return m, nil
+	})
+
+	signer, err := NewSigner(client, "azurekms:vault=my-vault;name=my-key", defaultOptions{})
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	if got, ok := signer.Public().(ed25519.PublicKey); !ok || !got.Equal(pub) {
+		t.Errorf("Signer.Public() = %T %v, want ed25519.PublicKey %v", signer.Public(), signer.Public(), pub)
+	}
+	if got := signer.(*Signer).JOSEAlgorithm(); got != "EdDSA" {
+		t.Errorf("Signer.JOSEAlgorithm() = %v, want EdDSA", got)
+	}
+}
+
 func TestSigner_Sign_signWithRetry(t *testing.T) {
 	sign := func(kty, crv string, bits int, opts crypto.SignerOpts) (crypto.PublicKey, []byte, []byte, []byte) {
 		key, err := keyutil.GenerateSigner(kty, crv, bits)
@@ -494,3 +749,30 @@ func TestSigner_Sign_signWithRetry(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_SignContext_canceled(t *testing.T) {
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := crypto.SHA256.New()
+	h.Write([]byte("random-data"))
+	digest := h.Sum(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := mockClient(t)
+	client.EXPECT().Sign(gomock.Any(), "my-key", "", gomock.Any(), nil).DoAndReturn(
+		func(context.Context, string, string, azkeys.SignParameters, *azkeys.SignOptions) (azkeys.SignResponse, error) {
+			cancel()
+			return azkeys.SignResponse{}, &azcore.ResponseError{StatusCode: 429}
+		})
+
+	s := &Signer{
+		client:    client,
+		name:      "my-key",
+		publicKey: key.Public(),
+	}
+	if _, err := s.SignContext(ctx, rand.Reader, digest, crypto.SHA256); !errors.Is(err, context.Canceled) {
+		t.Errorf("Signer.SignContext() error = %v, want %v", err, context.Canceled)
+	}
+}
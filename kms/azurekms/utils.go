@@ -7,15 +7,21 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"fmt"
 	"math/big"
+	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/kms/uri"
@@ -103,6 +109,107 @@ func parseKeyName(rawURI string, defaults defaultOptions) (vault, name, version
 	return
 }
 
+// parseSignatureFormat returns true if the "signature-format" parameter in the
+// given URI requests raw ECDSA signatures, in the concat(R,S) format used by
+// JOSE, instead of the default ASN.1 DER format. Valid values are "der" and
+// "raw".
+func parseSignatureFormat(rawURI string) (bool, error) {
+	u, err := uri.ParseWithScheme(Scheme, rawURI)
+	if err != nil {
+		return false, err
+	}
+	switch v := u.Get("signature-format"); strings.ToLower(v) {
+	case "", "der":
+		return false, nil
+	case "raw":
+		return true, nil
+	default:
+		return false, errors.Errorf("key uri %q is not valid: signature-format %q is not supported", rawURI, v)
+	}
+}
+
+// parseSecretName returns the key vault, name and version from URIs like:
+//
+//   - azurekms:vault=key-vault;secret=secret-name
+//   - azurekms:vault=key-vault;secret=secret-name?version=secret-id
+//
+// The secret-id defines the version of the secret, if it is not passed the
+// latest version will be used.
+func parseSecretName(rawURI string, defaults defaultOptions) (vault, name, version string, err error) {
+	var u *uri.URI
+
+	u, err = uri.ParseWithScheme(Scheme, rawURI)
+	if err != nil {
+		return
+	}
+	if name = u.Get("secret"); name == "" {
+		err = errors.Errorf("secret uri %q is not valid: secret is missing", rawURI)
+		return
+	}
+	if vault = u.Get("vault"); vault == "" {
+		if defaults.Vault == "" {
+			name = ""
+			err = errors.Errorf("secret uri %q is not valid: vault is missing", rawURI)
+			return
+		}
+		vault = defaults.Vault
+	}
+
+	version = u.Get("version")
+
+	return
+}
+
+func parseCertificateName(rawURI string, defaults defaultOptions) (vault, name, version string, err error) {
+	var u *uri.URI
+
+	u, err = uri.ParseWithScheme(Scheme, rawURI)
+	if err != nil {
+		return
+	}
+	if name = u.Get("cert"); name == "" {
+		err = errors.Errorf("certificate uri %q is not valid: cert is missing", rawURI)
+		return
+	}
+	if vault = u.Get("vault"); vault == "" {
+		if defaults.Vault == "" {
+			name = ""
+			err = errors.Errorf("certificate uri %q is not valid: vault is missing", rawURI)
+			return
+		}
+		vault = defaults.Vault
+	}
+
+	version = u.Get("version")
+
+	return
+}
+
+// convertError converts the errors returned by Azure Key Vault with the status
+// codes 401, 403, 404 and 409 to the apiv1 error types, so callers can use
+// errors.As to check them. Other errors are returned unchanged.
+func convertError(err error) error {
+	var responseError *azcore.ResponseError
+	if !errors.As(err, &responseError) {
+		return err
+	}
+
+	msg := fmt.Sprintf("%d %s", responseError.StatusCode, http.StatusText(responseError.StatusCode))
+	if responseError.ErrorCode != "" {
+		msg += ": " + responseError.ErrorCode
+	}
+	switch responseError.StatusCode {
+	case http.StatusNotFound:
+		return apiv1.NotFoundError{Message: msg}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return apiv1.PermissionDeniedError{Message: msg}
+	case http.StatusConflict:
+		return apiv1.AlreadyExistsError{Message: msg}
+	default:
+		return err
+	}
+}
+
 func convertKey(key *azkeys.JSONWebKey) (crypto.PublicKey, error) {
 	if key == nil || key.Kty == nil {
 		return nil, errors.New("invalid key: missing kty value")
@@ -114,7 +221,9 @@ func convertKey(key *azkeys.JSONWebKey) (crypto.PublicKey, error) {
 	case azkeys.JSONWebKeyTypeRSA, azkeys.JSONWebKeyTypeRSAHSM:
 		return rsaPublicKey(key.N, key.E)
 	case azkeys.JSONWebKeyTypeOct, azkeys.JSONWebKeyTypeOctHSM:
-		return octPublicKey(key.K)
+		return nil, fmt.Errorf("invalid key: kty %q is a symmetric key and does not have a public key", *key.Kty)
+	case jsonWebKeyTypeOKP, jsonWebKeyTypeOKPHSM:
+		return okpPublicKey(key.Crv, key.X)
 	default:
 		return nil, fmt.Errorf("invalid key: unsupported kty %q", *key.Kty)
 	}
@@ -141,7 +250,8 @@ func ecPublicKey(crv *azkeys.JSONWebKeyCurveName, x, y []byte) (crypto.PublicKey
 		curve = elliptic.P521()
 		curveSize = 66 // (521/8 + 1)
 	case azkeys.JSONWebKeyCurveNameP256K:
-		return nil, fmt.Errorf(`invalid EC key: crv %q is not supported`, *crv)
+		curve = secp256k1.S256()
+		curveSize = 32
 	default:
 		return nil, fmt.Errorf("invalid EC key: crv %q is not supported", *crv)
 	}
@@ -173,9 +283,194 @@ func rsaPublicKey(n, e []byte) (crypto.PublicKey, error) {
 	}, nil
 }
 
-func octPublicKey(k []byte) (crypto.PublicKey, error) {
-	if k == nil {
-		return nil, errors.New("invalid oct key: missing k value")
+func okpPublicKey(crv *azkeys.JSONWebKeyCurveName, x []byte) (crypto.PublicKey, error) {
+	if crv == nil {
+		return nil, errors.New("invalid OKP key: missing crv value")
+	}
+	if *crv != jsonWebKeyCurveNameEd25519 {
+		return nil, fmt.Errorf("invalid OKP key: crv %q is not supported", *crv)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid OKP key: x length is not valid")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// newKeyRotationPolicy converts the given policy to an azkeys.KeyRotationPolicy.
+func newKeyRotationPolicy(policy *apiv1.RotationPolicy) azkeys.KeyRotationPolicy {
+	var p azkeys.KeyRotationPolicy
+	if policy.ExpiryTime > 0 {
+		p.Attributes = &azkeys.KeyRotationPolicyAttributes{
+			ExpiryTime: pointer(formatDuration(policy.ExpiryTime)),
+		}
+	}
+	switch {
+	case policy.RotateAfterCreate > 0:
+		p.LifetimeActions = append(p.LifetimeActions, &azkeys.LifetimeActions{
+			Action: &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeRotate)},
+			Trigger: &azkeys.LifetimeActionsTrigger{
+				TimeAfterCreate: pointer(formatDuration(policy.RotateAfterCreate)),
+			},
+		})
+	case policy.RotateBeforeExpiry > 0:
+		p.LifetimeActions = append(p.LifetimeActions, &azkeys.LifetimeActions{
+			Action: &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeRotate)},
+			Trigger: &azkeys.LifetimeActionsTrigger{
+				TimeBeforeExpiry: pointer(formatDuration(policy.RotateBeforeExpiry)),
+			},
+		})
+	}
+	if policy.NotifyBeforeExpiry > 0 {
+		p.LifetimeActions = append(p.LifetimeActions, &azkeys.LifetimeActions{
+			Action: &azkeys.LifetimeActionsType{Type: pointer(azkeys.ActionTypeNotify)},
+			Trigger: &azkeys.LifetimeActionsTrigger{
+				TimeBeforeExpiry: pointer(formatDuration(policy.NotifyBeforeExpiry)),
+			},
+		})
+	}
+	return p
+}
+
+// convertRotationPolicy converts the given azkeys.KeyRotationPolicy to an
+// apiv1.RotationPolicy.
+func convertRotationPolicy(p azkeys.KeyRotationPolicy) (*apiv1.RotationPolicy, error) {
+	var (
+		err    error
+		policy apiv1.RotationPolicy
+	)
+	if p.Attributes != nil && p.Attributes.ExpiryTime != nil {
+		if policy.ExpiryTime, err = parseDuration(*p.Attributes.ExpiryTime); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range p.LifetimeActions {
+		if action == nil || action.Action == nil || action.Action.Type == nil || action.Trigger == nil {
+			continue
+		}
+		var afterCreate, beforeExpiry time.Duration
+		if action.Trigger.TimeAfterCreate != nil {
+			if afterCreate, err = parseDuration(*action.Trigger.TimeAfterCreate); err != nil {
+				return nil, err
+			}
+		}
+		if action.Trigger.TimeBeforeExpiry != nil {
+			if beforeExpiry, err = parseDuration(*action.Trigger.TimeBeforeExpiry); err != nil {
+				return nil, err
+			}
+		}
+		switch strings.ToLower(string(*action.Action.Type)) {
+		case string(azkeys.ActionTypeRotate):
+			policy.RotateAfterCreate = afterCreate
+			policy.RotateBeforeExpiry = beforeExpiry
+		case string(azkeys.ActionTypeNotify):
+			policy.NotifyBeforeExpiry = beforeExpiry
+		}
+	}
+	return &policy, nil
+}
+
+// formatDuration returns the ISO 8601 representation of the given duration,
+// e.g. P90D or PT48H. Fractions of a second are ignored.
+func formatDuration(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+
+	var sb strings.Builder
+	sb.WriteString("P")
+	if days > 0 {
+		fmt.Fprintf(&sb, "%dD", days)
+	}
+	if hours > 0 || minutes > 0 || seconds > 0 {
+		sb.WriteString("T")
+		if hours > 0 {
+			fmt.Fprintf(&sb, "%dH", hours)
+		}
+		if minutes > 0 {
+			fmt.Fprintf(&sb, "%dM", minutes)
+		}
+		if seconds > 0 {
+			fmt.Fprintf(&sb, "%dS", seconds)
+		}
+	}
+	if sb.Len() == 1 {
+		return "PT0S"
+	}
+	return sb.String()
+}
+
+var dnsSuffixRegexp = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// validateDNSSuffix validates that the given suffix is a domain name like
+// "vault.azure.net".
+func validateDNSSuffix(s string) error {
+	if !dnsSuffixRegexp.MatchString(s) {
+		return errors.New("suffix must be a domain name")
+	}
+	return nil
+}
+
+// validateEndpoint validates that the given endpoint is an https URL without
+// query or fragment.
+func validateEndpoint(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("endpoint must be an https URL")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("endpoint must not contain user info, query or fragment")
+	}
+	return nil
+}
+
+// parseVaultBaseURL returns the vault name and the DNS suffix of a vault URL
+// like https://vault-name.vault.azure.net/.
+func parseVaultBaseURL(s string) (vault, dnsSuffix string, err error) {
+	if err = validateEndpoint(s); err != nil {
+		return "", "", fmt.Errorf("vault-base-url %q is not valid: %w", s, err)
+	}
+	u, _ := url.Parse(s)
+	if u.Port() != "" || (u.Path != "" && u.Path != "/") {
+		return "", "", fmt.Errorf("vault-base-url %q is not valid: url must not contain port or path", s)
+	}
+	parts := strings.SplitN(u.Hostname(), ".", 2)
+	if len(parts) != 2 || parts[0] == "" || validateDNSSuffix(parts[1]) != nil {
+		return "", "", fmt.Errorf("vault-base-url %q is not valid: host must be vault-name.dns-suffix", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+var durationRegexp = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an ISO 8601 duration, e.g. P90D, P1Y10D or PT48H. Years
+// and months are converted using 365 and 30 days respectively.
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRegexp.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, errors.Errorf("error parsing duration %q", s)
+	}
+
+	units := []time.Duration{
+		365 * 24 * time.Hour, 30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour,
+		time.Hour, time.Minute, time.Second,
+	}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+1], 10, 32)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error parsing duration %q", s)
+		}
+		d += time.Duration(n) * unit
 	}
-	return k, nil
+	return d, nil
 }
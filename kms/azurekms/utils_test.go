@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"go.step.sm/crypto/kms/apiv1"
 )
 
@@ -108,6 +115,138 @@ func Test_parseKeyName(t *testing.T) {
 	}
 }
 
+func Test_parseKeyName_dnsSuffix(t *testing.T) {
+	defaults := defaultOptions{Vault: "my-vault", DNSSuffix: "vault.azurestack.example"}
+	tests := []struct {
+		name         string
+		rawURI       string
+		wantVaultURL string
+	}{
+		{"default vault", "azurekms:name=my-key", "https://my-vault.vault.azurestack.example/"},
+		{"vault", "azurekms:name=my-key;vault=other-vault", "https://other-vault.vault.azurestack.example/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault, _, _, _, err := parseKeyName(tt.rawURI, defaults)
+			if err != nil {
+				t.Fatalf("parseKeyName() error = %v", err)
+			}
+			if got := vaultBaseURL(vault, defaults.DNSSuffix); got != tt.wantVaultURL {
+				t.Errorf("vaultBaseURL() = %v, want %v", got, tt.wantVaultURL)
+			}
+		})
+	}
+}
+
+func Test_parseSignatureFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURI  string
+		want    bool
+		wantErr bool
+	}{
+		{"ok", "azurekms:name=my-key;vault=my-vault", false, false},
+		{"ok der", "azurekms:name=my-key;vault=my-vault?signature-format=der", false, false},
+		{"ok raw", "azurekms:name=my-key;vault=my-vault?signature-format=raw", true, false},
+		{"ok raw uppercase", "azurekms:name=my-key;vault=my-vault?signature-format=RAW", true, false},
+		{"fail format", "azurekms:name=my-key;vault=my-vault?signature-format=jose", false, true},
+		{"fail scheme", "kms:name=my-key;vault=my-vault?signature-format=raw", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignatureFormat(tt.rawURI)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSignatureFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseSignatureFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseVaultBaseURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		baseURL       string
+		wantVault     string
+		wantDNSSuffix string
+		wantErr       bool
+	}{
+		{"ok", "https://my-vault.vault.azurestack.example/", "my-vault", "vault.azurestack.example", false},
+		{"ok no slash", "https://my-vault.vault.azurestack.example", "my-vault", "vault.azurestack.example", false},
+		{"fail scheme", "http://my-vault.vault.azurestack.example/", "", "", true},
+		{"fail no scheme", "my-vault.vault.azurestack.example", "", "", true},
+		{"fail parse", "https://my-vault.vault.azurestack.example/%ZZ", "", "", true},
+		{"fail port", "https://my-vault.vault.azurestack.example:8443/", "", "", true},
+		{"fail path", "https://my-vault.vault.azurestack.example/keys", "", "", true},
+		{"fail query", "https://my-vault.vault.azurestack.example/?foo=bar", "", "", true},
+		{"fail user", "https://user@my-vault.vault.azurestack.example/", "", "", true},
+		{"fail host", "https://my-vault/", "", "", true},
+		{"fail suffix", "https://my-vault.example/", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotVault, gotDNSSuffix, err := parseVaultBaseURL(tt.baseURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseVaultBaseURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotVault != tt.wantVault {
+				t.Errorf("parseVaultBaseURL() gotVault = %v, want %v", gotVault, tt.wantVault)
+			}
+			if gotDNSSuffix != tt.wantDNSSuffix {
+				t.Errorf("parseVaultBaseURL() gotDNSSuffix = %v, want %v", gotDNSSuffix, tt.wantDNSSuffix)
+			}
+		})
+	}
+}
+
+func Test_parseSecretName(t *testing.T) {
+	var noOptions defaultOptions
+	type args struct {
+		rawURI   string
+		defaults defaultOptions
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantVault   string
+		wantName    string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"ok", args{"azurekms:secret=my-secret;vault=my-vault?version=my-version", noOptions}, "my-vault", "my-secret", "my-version", false},
+		{"ok opaque version", args{"azurekms:secret=my-secret;vault=my-vault;version=my-version", noOptions}, "my-vault", "my-secret", "my-version", false},
+		{"ok no version", args{"azurekms:secret=my-secret;vault=my-vault", noOptions}, "my-vault", "my-secret", "", false},
+		{"ok default vault", args{"azurekms:secret=my-secret", defaultOptions{Vault: "my-vault", DNSSuffix: "vault.azure.net"}}, "my-vault", "my-secret", "", false},
+		{"fail scheme", args{"azure:secret=my-secret;vault=my-vault", noOptions}, "", "", "", true},
+		{"fail parse uri", args{"azurekms:secret=%ZZ;vault=my-vault", noOptions}, "", "", "", true},
+		{"fail no secret", args{"azurekms:name=my-key;vault=my-vault", noOptions}, "", "", "", true},
+		{"fail no vault", args{"azurekms:secret=my-secret", noOptions}, "", "", "", true},
+		{"fail empty", args{"", noOptions}, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotVault, gotName, gotVersion, err := parseSecretName(tt.args.rawURI, tt.args.defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSecretName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotVault != tt.wantVault {
+				t.Errorf("parseSecretName() gotVault = %v, want %v", gotVault, tt.wantVault)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("parseSecretName() gotName = %v, want %v", gotName, tt.wantName)
+			}
+			if gotVersion != tt.wantVersion {
+				t.Errorf("parseSecretName() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
+			}
+		})
+	}
+}
+
 func Test_convertKey(t *testing.T) {
 	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -121,11 +260,20 @@ func Test_convertKey(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	k256, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
 	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	encodeXorY := func(i *big.Int, size int) []byte {
 		b := i.Bytes()
 		if s := size - len(b); s > 0 {
@@ -176,6 +324,12 @@ func Test_convertKey(t *testing.T) {
 			X:   encodeXorY(p521.X, 66),
 			Y:   encodeXorY(p521.Y, 66),
 		}}, &p521.PublicKey, false},
+		{"ok EC P-256K", args{&azkeys.JSONWebKey{
+			Kty: pointer(azkeys.JSONWebKeyTypeEC),
+			Crv: pointer(azkeys.JSONWebKeyCurveNameP256K),
+			X:   encodeXorY(k256.X, 32),
+			Y:   encodeXorY(k256.Y, 32),
+		}}, &k256.PublicKey, false},
 		{"ok RSA", args{&azkeys.JSONWebKey{
 			Kty: pointer(azkeys.JSONWebKeyTypeRSA),
 			E:   e,
@@ -192,10 +346,30 @@ func Test_convertKey(t *testing.T) {
 			E:   e,
 			N:   n,
 		}}, &rsaKey.PublicKey, false},
-		{"ok oct", args{&azkeys.JSONWebKey{
-			Kty: pointer(azkeys.JSONWebKeyTypeOct),
-			K:   []byte("a-symmetric-key"),
-		}}, []byte("a-symmetric-key"), false},
+		{"ok OKP", args{&azkeys.JSONWebKey{
+			Kty: pointer(jsonWebKeyTypeOKP),
+			Crv: pointer(jsonWebKeyCurveNameEd25519),
+			X:   edKey,
+		}}, edKey, false},
+		{"ok OKP-HSM", args{&azkeys.JSONWebKey{
+			Kty: pointer(jsonWebKeyTypeOKPHSM),
+			Crv: pointer(jsonWebKeyCurveNameEd25519),
+			X:   edKey,
+		}}, edKey, false},
+		{"fail OKP nil crv", args{&azkeys.JSONWebKey{
+			Kty: pointer(jsonWebKeyTypeOKP),
+			X:   edKey,
+		}}, nil, true},
+		{"fail OKP crv", args{&azkeys.JSONWebKey{
+			Kty: pointer(jsonWebKeyTypeOKP),
+			Crv: pointer(azkeys.JSONWebKeyCurveName("X25519")),
+			X:   edKey,
+		}}, nil, true},
+		{"fail OKP x", args{&azkeys.JSONWebKey{
+			Kty: pointer(jsonWebKeyTypeOKP),
+			Crv: pointer(jsonWebKeyCurveNameEd25519),
+			X:   edKey[:31],
+		}}, nil, true},
 		{"fail nil", args{nil}, nil, true},
 		{"fail nil kty", args{&azkeys.JSONWebKey{}}, nil, true},
 		{"fail kty", args{&azkeys.JSONWebKey{
@@ -262,9 +436,12 @@ func Test_convertKey(t *testing.T) {
 			N:   n,
 			E:   nil,
 		}}, nil, true},
-		{"fail nil k", args{&azkeys.JSONWebKey{
+		{"fail oct", args{&azkeys.JSONWebKey{
 			Kty: pointer(azkeys.JSONWebKeyTypeOct),
-			K:   nil,
+			K:   []byte("a-symmetric-key"),
+		}}, nil, true},
+		{"fail oct-HSM", args{&azkeys.JSONWebKey{
+			Kty: pointer(azkeys.JSONWebKeyTypeOctHSM),
 		}}, nil, true},
 	}
 	for _, tt := range tests {
@@ -280,3 +457,109 @@ func Test_convertKey(t *testing.T) {
 		})
 	}
 }
+
+func Test_convertKey_symmetric(t *testing.T) {
+	for _, kty := range []azkeys.JSONWebKeyType{azkeys.JSONWebKeyTypeOct, azkeys.JSONWebKeyTypeOctHSM} {
+		t.Run(string(kty), func(t *testing.T) {
+			got, err := convertKey(&azkeys.JSONWebKey{
+				Kty: pointer(kty),
+				K:   []byte("a-symmetric-key"),
+			})
+			if err == nil {
+				t.Fatalf("convertKey() = %v, want error", got)
+			}
+			if got != nil {
+				t.Errorf("convertKey() = %v, want nil", got)
+			}
+			if want := fmt.Sprintf("kty %q is a symmetric key", kty); !strings.Contains(err.Error(), want) {
+				t.Errorf("convertKey() error = %q, want it to contain %q", err, want)
+			}
+		})
+	}
+}
+
+func Test_formatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{90 * 24 * time.Hour, "P90D"},
+		{48 * time.Hour, "P2D"},
+		{36 * time.Hour, "P1DT12H"},
+		{12 * time.Hour, "PT12H"},
+		{90 * time.Minute, "PT1H30M"},
+		{45*time.Second + time.Millisecond, "PT45S"},
+		{25*time.Hour + time.Minute + time.Second, "P1DT1H1M1S"},
+		{0, "PT0S"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatDuration(tt.d); got != tt.want {
+				t.Errorf("formatDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDuration(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"P90D", 90 * day, false},
+		{"P3M", 90 * day, false},
+		{"P1Y10D", 375 * day, false},
+		{"P2W", 14 * day, false},
+		{"PT48H", 48 * time.Hour, false},
+		{"P1DT12H30M15S", 36*time.Hour + 30*time.Minute + 15*time.Second, false},
+		{"PT0S", 0, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"P1DT", 0, true},
+		{"90D", 0, true},
+		{"P1.5D", 0, true},
+		{"P99999999999D", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseDuration(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_convertError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   error
+		target interface{}
+	}{
+		{"not found", &azcore.ResponseError{StatusCode: 404, ErrorCode: "KeyNotFound"}, apiv1.NotFoundError{Message: "404 Not Found: KeyNotFound"}, &apiv1.NotFoundError{}},
+		{"unauthorized", &azcore.ResponseError{StatusCode: 401}, apiv1.PermissionDeniedError{Message: "401 Unauthorized"}, &apiv1.PermissionDeniedError{}},
+		{"forbidden", &azcore.ResponseError{StatusCode: 403, ErrorCode: "Forbidden"}, apiv1.PermissionDeniedError{Message: "403 Forbidden: Forbidden"}, &apiv1.PermissionDeniedError{}},
+		{"conflict", &azcore.ResponseError{StatusCode: 409, ErrorCode: "Conflict"}, apiv1.AlreadyExistsError{Message: "409 Conflict: Conflict"}, &apiv1.AlreadyExistsError{}},
+		{"wrapped", fmt.Errorf("wrapped: %w", &azcore.ResponseError{StatusCode: 404}), apiv1.NotFoundError{Message: "404 Not Found"}, &apiv1.NotFoundError{}},
+		{"other status", &azcore.ResponseError{StatusCode: 500}, &azcore.ResponseError{StatusCode: 500}, nil},
+		{"other error", errTest, errTest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertError(tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertError() = %#v, want %#v", got, tt.want)
+			}
+			if tt.target != nil && !errors.As(fmt.Errorf("keyVault GetKey failed: %w", got), tt.target) {
+				t.Errorf("errors.As() = false, want %T", tt.target)
+			}
+		})
+	}
+}
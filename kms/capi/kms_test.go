@@ -0,0 +1,554 @@
+//go:build !nocapi
+// +build !nocapi
+
+package capi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/uri"
+)
+
+const testProviderHandle = uintptr(1)
+
+var errTest = errors.New("test error")
+
+type fakeKey struct {
+	name       string
+	algorithm  string
+	properties map[string]interface{}
+	signer     crypto.Signer
+}
+
+// fakeNCrypt implements nCryptAPI using software keys.
+type fakeNCrypt struct {
+	keys    map[string]*fakeKey
+	handles map[uintptr]*fakeKey
+	freed   []uintptr
+	fail    map[string]bool
+	next    uintptr
+}
+
+func newFakeNCrypt(t *testing.T) *fakeNCrypt {
+	t.Helper()
+	nc := &fakeNCrypt{
+		keys:    make(map[string]*fakeKey),
+		handles: make(map[uintptr]*fakeKey),
+		fail:    make(map[string]bool),
+		next:    100,
+	}
+	for name, bits := range map[string]int{"rsa-key": 2048} {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nc.keys[name] = &fakeKey{name: name, algorithm: ALG_RSA, properties: map[string]interface{}{}, signer: k}
+	}
+	for name, alg := range map[string]string{"p256-key": ALG_ECDSA_P256, "p384-key": ALG_ECDSA_P384} {
+		k, err := ecdsa.GenerateKey(curveNames[alg], rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nc.keys[name] = &fakeKey{name: name, algorithm: alg, properties: map[string]interface{}{}, signer: k}
+	}
+	return nc
+}
+
+func (f *fakeNCrypt) handle(k *fakeKey) uintptr {
+	f.next++
+	f.handles[f.next] = k
+	return f.next
+}
+
+func (f *fakeNCrypt) key(kh uintptr) (*fakeKey, error) {
+	k, ok := f.handles[kh]
+	if !ok {
+		return nil, errors.New("invalid handle")
+	}
+	return k, nil
+}
+
+func (f *fakeNCrypt) FreeObject(h uintptr) error {
+	f.freed = append(f.freed, h)
+	if f.fail["FreeObject"] {
+		return errTest
+	}
+	return nil
+}
+
+func (f *fakeNCrypt) CreatePersistedKey(providerHandle uintptr, containerName, algorithmName string, legacyKeySpec, flags uint32) (uintptr, error) {
+	if f.fail["CreatePersistedKey"] || providerHandle != testProviderHandle {
+		return 0, errTest
+	}
+	k := &fakeKey{
+		name:       containerName,
+		algorithm:  algorithmName,
+		properties: map[string]interface{}{},
+	}
+	return f.handle(k), nil
+}
+
+func (f *fakeNCrypt) OpenKey(providerHandle uintptr, containerName string, legacyKeySpec, flags uint32) (uintptr, error) {
+	k, ok := f.keys[containerName]
+	if f.fail["OpenKey"] || !ok || providerHandle != testProviderHandle {
+		return 0, errTest
+	}
+	return f.handle(k), nil
+}
+
+func (f *fakeNCrypt) FinalizeKey(keyHandle uintptr, flags uint32) error {
+	k, err := f.key(keyHandle)
+	if err != nil {
+		return err
+	}
+	if f.fail["FinalizeKey"] {
+		return errTest
+	}
+	if k.algorithm == ALG_RSA {
+		bits, _ := k.properties[NCRYPT_LENGTH_PROPERTY].(uint32)
+		if bits == 0 {
+			bits = 2048
+		}
+		k.signer, err = rsa.GenerateKey(rand.Reader, int(bits))
+	} else {
+		k.signer, err = ecdsa.GenerateKey(curveNames[k.algorithm], rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+	f.keys[k.name] = k
+	return nil
+}
+
+func (f *fakeNCrypt) SetProperty(keyHandle uintptr, propertyName string, propertyValue interface{}, flags uint32) error {
+	k, err := f.key(keyHandle)
+	if err != nil {
+		return err
+	}
+	switch propertyValue.(type) {
+	case uint32, string, []byte:
+	default:
+		return errors.New("invalid value type")
+	}
+	if f.fail["SetProperty"] {
+		return errTest
+	}
+	k.properties[propertyName] = propertyValue
+	return nil
+}
+
+func (f *fakeNCrypt) GetPropertyStr(keyHandle uintptr, propertyName string) (string, error) {
+	k, err := f.key(keyHandle)
+	if err != nil {
+		return "", err
+	}
+	switch propertyName {
+	case NCRYPT_ALGORITHM_GROUP_PROPERTY:
+		if k.algorithm == ALG_RSA {
+			return "RSA", nil
+		}
+		return "ECDSA", nil
+	case NCRYPT_ECC_CURVE_NAME_PROPERTY:
+		// The smart card provider does not set the curve name.
+		if k.algorithm == ALG_ECDSA_P384 {
+			return "", errTest
+		}
+		return "nist" + strings.ReplaceAll(curveNames[k.algorithm].Params().Name, "-", ""), nil
+	case NCRYPT_ALGORITHM_PROPERTY:
+		return k.algorithm, nil
+	case NCRYPT_UNIQUE_NAME_PROPERTY:
+		return k.name, nil
+	default:
+		return "", errTest
+	}
+}
+
+func (f *fakeNCrypt) ExportKey(keyHandle uintptr, blobType string) ([]byte, error) {
+	k, err := f.key(keyHandle)
+	if err != nil {
+		return nil, err
+	}
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		if blobType != BCRYPT_RSAPUBLIC_BLOB {
+			return nil, errTest
+		}
+		return rsaBlob(pub), nil
+	case *ecdsa.PublicKey:
+		if blobType != BCRYPT_ECCPUBLIC_BLOB {
+			return nil, errTest
+		}
+		return eccBlob(pub), nil
+	default:
+		return nil, errTest
+	}
+}
+
+func (f *fakeNCrypt) SignHash(keyHandle uintptr, digest []byte, hashID string) ([]byte, error) {
+	k, err := f.key(keyHandle)
+	if err != nil {
+		return nil, err
+	}
+	switch priv := k.signer.(type) {
+	case *rsa.PrivateKey:
+		for h, id := range hashAlgorithms {
+			if id == hashID {
+				return rsa.SignPKCS1v15(rand.Reader, priv, h, digest)
+			}
+		}
+		return nil, errTest
+	case *ecdsa.PrivateKey:
+		if hashID != "" {
+			return nil, errTest
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	default:
+		return nil, errTest
+	}
+}
+
+func rsaBlob(pub *rsa.PublicKey) []byte {
+	exp := big.NewInt(int64(pub.E)).Bytes()
+	mod := pub.N.Bytes()
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint32(b[0:], rsa1Magic)
+	binary.LittleEndian.PutUint32(b[4:], uint32(pub.N.BitLen()))
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(exp)))
+	binary.LittleEndian.PutUint32(b[12:], uint32(len(mod)))
+	b = append(b, exp...)
+	return append(b, mod...)
+}
+
+func eccBlob(pub *ecdsa.PublicKey) []byte {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	b := make([]byte, 8+2*size)
+	binary.LittleEndian.PutUint32(b[0:], curveMagicMap[pub.Curve.Params().Name])
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	pub.X.FillBytes(b[8 : 8+size])
+	pub.Y.FillBytes(b[8+size:])
+	return b
+}
+
+func newTestCAPIKMS(nc *fakeNCrypt, providerName, pin string) *CAPIKMS {
+	return &CAPIKMS{
+		nc:             nc,
+		providerName:   providerName,
+		providerHandle: testProviderHandle,
+		pin:            pin,
+	}
+}
+
+func TestCAPIKMS_CreateKey(t *testing.T) {
+	pcpHash, err := hashPasswordUTF16("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		req *apiv1.CreateKeyRequest
+	}
+	tests := []struct {
+		name           string
+		providerName   string
+		pin            string
+		fail           string
+		args           args
+		wantName       string
+		wantPublicKey  interface{}
+		wantProperties map[string]interface{}
+		wantErr        bool
+	}{
+		{"ok default", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key"}},
+			"capi:provider=Microsoft Software Key Storage Provider;key=my-key", &ecdsa.PublicKey{}, map[string]interface{}{}, false},
+		{"ok rsa machine", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key;store-location=machine", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 3072}},
+			"capi:provider=Microsoft Software Key Storage Provider;key=my-key", &rsa.PublicKey{}, map[string]interface{}{
+				NCRYPT_LENGTH_PROPERTY:   uint32(3072),
+				NCRYPT_KEY_TYPE_PROPERTY: uint32(NCRYPT_MACHINE_KEY_FLAG),
+			}, false},
+		{"ok pcp password", ProviderMSPCP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key;pin-value=password", SignatureAlgorithm: apiv1.ECDSAWithSHA384}},
+			"capi:provider=Microsoft Platform Crypto Provider;key=my-key", &ecdsa.PublicKey{}, map[string]interface{}{
+				NCRYPT_PCP_USAGE_AUTH_PROPERTY: pcpHash,
+			}, false},
+		{"ok pcp kms password", ProviderMSPCP, "password", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key;store-location=user"}},
+			"capi:provider=Microsoft Platform Crypto Provider;key=my-key", &ecdsa.PublicKey{}, map[string]interface{}{
+				NCRYPT_PCP_USAGE_AUTH_PROPERTY: pcpHash,
+			}, false},
+		{"fail name", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{}}, "", nil, nil, true},
+		{"fail public exponent", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key", PublicExponent: 3}}, "", nil, nil, true},
+		{"fail smart card", ProviderMSSC, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key"}}, "", nil, nil, true},
+		{"fail uri", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "pkcs11:key=my-key"}}, "", nil, nil, true},
+		{"fail algorithm", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key", SignatureAlgorithm: apiv1.PureEd25519}}, "", nil, nil, true},
+		{"fail store location", ProviderMSKSP, "", "", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key;store-location=other"}}, "", nil, nil, true},
+		{"fail CreatePersistedKey", ProviderMSKSP, "", "CreatePersistedKey", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key"}}, "", nil, nil, true},
+		{"fail SetProperty", ProviderMSKSP, "", "SetProperty", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key", SignatureAlgorithm: apiv1.SHA256WithRSA}}, "", nil, nil, true},
+		{"fail FinalizeKey", ProviderMSKSP, "", "FinalizeKey", args{&apiv1.CreateKeyRequest{Name: "capi:key=my-key"}}, "", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc := newFakeNCrypt(t)
+			if tt.fail != "" {
+				nc.fail[tt.fail] = true
+			}
+			k := newTestCAPIKMS(nc, tt.providerName, tt.pin)
+			got, err := k.CreateKey(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CAPIKMS.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Name != tt.wantName || got.CreateSignerRequest.SigningKey != tt.wantName {
+				t.Errorf("CAPIKMS.CreateKey() name = %v, want %v", got.Name, tt.wantName)
+			}
+			if reflect.TypeOf(got.PublicKey) != reflect.TypeOf(tt.wantPublicKey) {
+				t.Errorf("CAPIKMS.CreateKey() public key = %T, want %T", got.PublicKey, tt.wantPublicKey)
+			}
+			key := nc.keys["my-key"]
+			if !reflect.DeepEqual(key.properties, tt.wantProperties) {
+				t.Errorf("CAPIKMS.CreateKey() properties = %v, want %v", key.properties, tt.wantProperties)
+			}
+			if !reflect.DeepEqual(got.PublicKey, key.signer.Public()) {
+				t.Errorf("CAPIKMS.CreateKey() public key = %v, want %v", got.PublicKey, key.signer.Public())
+			}
+			if len(nc.freed) != 1 {
+				t.Errorf("CAPIKMS.CreateKey() freed %d handles, want 1", len(nc.freed))
+			}
+		})
+	}
+}
+
+func TestCAPIKMS_GetPublicKey(t *testing.T) {
+	nc := newFakeNCrypt(t)
+	k := newTestCAPIKMS(nc, ProviderMSKSP, "")
+
+	tests := []struct {
+		name    string
+		keyName string
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok rsa", "capi:key=rsa-key", nc.keys["rsa-key"].signer.Public(), false},
+		{"ok p256", "capi:key=p256-key", nc.keys["p256-key"].signer.Public(), false},
+		{"ok p384 without curve name", "capi:provider=Microsoft Software Key Storage Provider;key=p384-key", nc.keys["p384-key"].signer.Public(), false},
+		{"fail uri", "pkcs11:key=rsa-key", nil, true},
+		{"fail key", "capi:provider=Microsoft Software Key Storage Provider", nil, true},
+		{"fail OpenKey", "capi:key=missing-key", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: tt.keyName})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CAPIKMS.GetPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CAPIKMS.GetPublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCAPIKMS_CreateSigner(t *testing.T) {
+	message := []byte("message to sign")
+	sha256Digest := sha256.Sum256(message)
+	sha384Digest := sha512.Sum384(message)
+
+	tests := []struct {
+		name         string
+		providerName string
+		signingKey   string
+		digest       []byte
+		opts         crypto.SignerOpts
+		wantProperty string
+		wantErr      bool
+		wantSignErr  bool
+	}{
+		{"ok rsa", ProviderMSKSP, "capi:key=rsa-key", sha256Digest[:], crypto.SHA256, "", false, false},
+		{"ok p256", ProviderMSKSP, "capi:key=p256-key", sha256Digest[:], crypto.SHA256, "", false, false},
+		{"ok p384", ProviderMSKSP, "capi:key=p384-key", sha384Digest[:], crypto.SHA384, "", false, false},
+		{"ok smart card pin", ProviderMSSC, "capi:key=p256-key;pin-value=123456", sha256Digest[:], crypto.SHA256, NCRYPT_PIN_PROPERTY, false, false},
+		{"ok pcp password", ProviderMSPCP, "capi:key=p256-key?pin-value=password", sha256Digest[:], crypto.SHA256, NCRYPT_PCP_USAGE_AUTH_PROPERTY, false, false},
+		{"fail uri", ProviderMSKSP, "pkcs11:key=rsa-key", nil, nil, "", true, false},
+		{"fail OpenKey", ProviderMSKSP, "capi:key=missing-key", nil, nil, "", true, false},
+		{"fail rsa pss", ProviderMSKSP, "capi:key=rsa-key", sha256Digest[:], &rsa.PSSOptions{Hash: crypto.SHA256}, "", false, true},
+		{"fail rsa hash", ProviderMSKSP, "capi:key=rsa-key", sha256Digest[:], crypto.SHA224, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc := newFakeNCrypt(t)
+			k := newTestCAPIKMS(nc, tt.providerName, "")
+			signer, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: tt.signingKey})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CAPIKMS.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			u, err := uri.Parse(tt.signingKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := nc.keys[u.Get(ContainerNameArg)]
+			if tt.wantProperty != "" {
+				if _, ok := key.properties[tt.wantProperty]; !ok {
+					t.Errorf("CAPIKMS.CreateSigner() did not set %s", tt.wantProperty)
+				}
+			}
+			if !reflect.DeepEqual(signer.Public(), key.signer.Public()) {
+				t.Errorf("CAPISigner.Public() = %v, want %v", signer.Public(), key.signer.Public())
+			}
+
+			sig, err := signer.Sign(rand.Reader, tt.digest, tt.opts)
+			if (err != nil) != tt.wantSignErr {
+				t.Errorf("CAPISigner.Sign() error = %v, wantErr %v", err, tt.wantSignErr)
+				return
+			}
+			if tt.wantSignErr {
+				return
+			}
+			switch pub := signer.Public().(type) {
+			case *rsa.PublicKey:
+				if err := rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), tt.digest, sig); err != nil {
+					t.Errorf("rsa.VerifyPKCS1v15() error = %v", err)
+				}
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, tt.digest, sig) {
+					t.Error("ecdsa.VerifyASN1() failed")
+				}
+			default:
+				t.Errorf("unexpected public key type %T", pub)
+			}
+		})
+	}
+}
+
+func TestCAPIKMS_Close(t *testing.T) {
+	nc := newFakeNCrypt(t)
+	k := newTestCAPIKMS(nc, ProviderMSKSP, "")
+	for i := 0; i < 2; i++ {
+		if err := k.Close(); err != nil {
+			t.Fatalf("CAPIKMS.Close() error = %v", err)
+		}
+	}
+	if !reflect.DeepEqual(nc.freed, []uintptr{testProviderHandle}) {
+		t.Errorf("CAPIKMS.Close() freed %v, want %v", nc.freed, []uintptr{testProviderHandle})
+	}
+
+	nc = newFakeNCrypt(t)
+	nc.fail["FreeObject"] = true
+	if err := newTestCAPIKMS(nc, ProviderMSKSP, "").Close(); err == nil {
+		t.Error("CAPIKMS.Close() error = nil, wantErr true")
+	}
+}
+
+func Test_unmarshalRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := rsaBlob(&key.PublicKey)
+	badMagic := append([]byte{}, blob...)
+	binary.LittleEndian.PutUint32(badMagic, ecs1Magic)
+	bigExponent := append([]byte{}, blob...)
+	binary.LittleEndian.PutUint32(bigExponent[8:], 9)
+
+	tests := []struct {
+		name    string
+		buf     []byte
+		want    *rsa.PublicKey
+		wantErr bool
+	}{
+		{"ok", blob, &key.PublicKey, false},
+		{"fail header", blob[:10], nil, true},
+		{"fail magic", badMagic, nil, true},
+		{"fail exponent size", bigExponent, nil, true},
+		{"fail exponent", blob[:24], nil, true},
+		{"fail modulus", blob[:len(blob)-1], nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshalRSA(tt.buf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unmarshalRSA() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unmarshalRSA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_unmarshalECC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := eccBlob(&key.PublicKey)
+
+	tests := []struct {
+		name    string
+		buf     []byte
+		curve   elliptic.Curve
+		want    *ecdsa.PublicKey
+		wantErr bool
+	}{
+		{"ok", blob, elliptic.P256(), &key.PublicKey, false},
+		{"fail header", blob[:4], elliptic.P256(), nil, true},
+		{"fail magic", blob, elliptic.P384(), nil, true},
+		{"fail x", blob[:20], elliptic.P256(), nil, true},
+		{"fail y", blob[:len(blob)-1], elliptic.P256(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshalECC(tt.buf, tt.curve)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unmarshalECC() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unmarshalECC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hashPasswordUTF16(t *testing.T) {
+	want := sha1.Sum([]byte{'p', 0, 'a', 0, 's', 0, 's', 0, 0xac, 0x20})
+	got, err := hashPasswordUTF16("pass€")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want[:]) {
+		t.Errorf("hashPasswordUTF16() = %x, want %x", got, want)
+	}
+
+	if _, err := hashPasswordUTF16("pass\x00word"); err == nil {
+		t.Error("hashPasswordUTF16() error = nil, wantErr true")
+	}
+}
@@ -0,0 +1,109 @@
+//go:build !nocapi
+// +build !nocapi
+
+package capi
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+const (
+	// Key storage properties
+	NCRYPT_ALGORITHM_GROUP_PROPERTY = "Algorithm Group"
+	NCRYPT_LENGTH_PROPERTY          = "Length"
+	NCRYPT_KEY_TYPE_PROPERTY        = "Key Type"
+	NCRYPT_UNIQUE_NAME_PROPERTY     = "Unique Name"
+	NCRYPT_ECC_CURVE_NAME_PROPERTY  = "ECCCurveName"
+	NCRYPT_IMPL_TYPE_PROPERTY       = "Impl Type"
+	NCRYPT_PROV_HANDLE              = "Provider Handle"
+	NCRYPT_PIN_PROPERTY             = "SmartCardPin"
+	NCRYPT_SECURE_PIN_PROPERTY      = "SmartCardSecurePin"
+	NCRYPT_READER_PROPERTY          = "SmartCardReader"
+	NCRYPT_ALGORITHM_PROPERTY       = "Algorithm Name"
+	NCRYPT_PCP_USAGE_AUTH_PROPERTY  = "PCP_USAGEAUTH"
+
+	// Key Storage Flags
+	NCRYPT_MACHINE_KEY_FLAG = 0x00000001
+
+	BCRYPT_RSAPUBLIC_BLOB = "RSAPUBLICBLOB"
+	BCRYPT_ECCPUBLIC_BLOB = "ECCPUBLICBLOB"
+
+	// Magic numbers for public key blobs.
+	rsa1Magic = 0x31415352 // "RSA1" BCRYPT_RSAPUBLIC_MAGIC
+	ecs1Magic = 0x31534345 // "ECS1" BCRYPT_ECDSA_PUBLIC_P256_MAGIC
+	ecs3Magic = 0x33534345 // "ECS3" BCRYPT_ECDSA_PUBLIC_P384_MAGIC
+	ecs5Magic = 0x35534345 // "ECS5" BCRYPT_ECDSA_PUBLIC_P521_MAGIC
+
+	ALG_RSA        = "RSA"
+	ALG_ECDSA_P256 = "ECDSA_P256"
+	ALG_ECDSA_P384 = "ECDSA_P384"
+	ALG_ECDSA_P521 = "ECDSA_P521"
+
+	ProviderMSKSP = "Microsoft Software Key Storage Provider"
+	ProviderMSSC  = "Microsoft Smart Card Key Storage Provider"
+	ProviderMSPCP = "Microsoft Platform Crypto Provider"
+)
+
+var (
+	// curveNames maps bcrypt.h curve names to elliptic curves.
+	curveNames = map[string]elliptic.Curve{
+		ALG_ECDSA_P256: elliptic.P256(),
+		ALG_ECDSA_P384: elliptic.P384(),
+		ALG_ECDSA_P521: elliptic.P521(),
+		"nistP256":     elliptic.P256(), // BCRYPT_ECC_CURVE_NISTP256
+		"nistP384":     elliptic.P384(), // BCRYPT_ECC_CURVE_NISTP384
+		"nistP521":     elliptic.P521(), // BCRYPT_ECC_CURVE_NISTP521
+	}
+
+	curveMagicMap = map[string]uint32{
+		"P-256": ecs1Magic,
+		"P-384": ecs3Magic,
+		"P-521": ecs5Magic,
+	}
+
+	// algIDs maps crypto.Hash values to bcrypt.h constants.
+	hashAlgorithms = map[crypto.Hash]string{
+		crypto.SHA1:   "SHA1",   // BCRYPT_SHA1_ALGORITHM
+		crypto.SHA256: "SHA256", // BCRYPT_SHA256_ALGORITHM
+		crypto.SHA384: "SHA384", // BCRYPT_SHA384_ALGORITHM
+		crypto.SHA512: "SHA512", // BCRYPT_SHA512_ALGORITHM
+	}
+)
+
+// nCryptAPI defines the CNG key storage functions used by CAPIKMS to manage
+// keys. On Windows it is implemented using ncrypt.dll, and this interface is
+// used to test the KMS logic on any platform.
+type nCryptAPI interface {
+	FreeObject(h uintptr) error
+	CreatePersistedKey(providerHandle uintptr, containerName, algorithmName string, legacyKeySpec, flags uint32) (uintptr, error)
+	OpenKey(providerHandle uintptr, containerName string, legacyKeySpec, flags uint32) (uintptr, error)
+	FinalizeKey(keyHandle uintptr, flags uint32) error
+	SetProperty(keyHandle uintptr, propertyName string, propertyValue interface{}, flags uint32) error
+	GetPropertyStr(keyHandle uintptr, propertyName string) (string, error)
+	ExportKey(keyHandle uintptr, blobType string) ([]byte, error)
+	SignHash(keyHandle uintptr, digest []byte, hashID string) ([]byte, error)
+}
+
+// hashPasswordUTF16 returns the SHA-1 hash of the UTF-16 encoding of the
+// password, the format used by the Microsoft Platform Crypto Provider.
+func hashPasswordUTF16(s string) ([]byte, error) {
+	for _, r := range s {
+		if r == 0 {
+			return nil, errors.New("password cannot contain NUL characters")
+		}
+	}
+	utf16Str := utf16.Encode([]rune(s))
+	bytesStr := make([]byte, len(utf16Str)*2)
+	for i, v := range utf16Str {
+		// LPCSTR (Windows' representation of utf16) is always little endian.
+		binary.LittleEndian.PutUint16(bytesStr[i*2:i*2+2], v)
+	}
+
+	digest := sha1.Sum(bytesStr) // TODO: SHA256 is supported, but if used wont show the UI
+	return digest[:], nil
+}
@@ -1,39 +1,18 @@
-//go:build windows
-// +build windows
+//go:build windows && !nocapi
+// +build windows,!nocapi
 
 package capi
 
 import (
 	"bytes"
-	"crypto"
-	"crypto/elliptic"
-	"crypto/sha1"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"golang.org/x/sys/windows"
 	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 const (
-
-	// Key storage properties
-	NCRYPT_ALGORITHM_GROUP_PROPERTY = "Algorithm Group"
-	NCRYPT_LENGTH_PROPERTY          = "Length"
-	NCRYPT_KEY_TYPE_PROPERTY        = "Key Type"
-	NCRYPT_UNIQUE_NAME_PROPERTY     = "Unique Name"
-	NCRYPT_ECC_CURVE_NAME_PROPERTY  = "ECCCurveName"
-	NCRYPT_IMPL_TYPE_PROPERTY       = "Impl Type"
-	NCRYPT_PROV_HANDLE              = "Provider Handle"
-	NCRYPT_PIN_PROPERTY             = "SmartCardPin"
-	NCRYPT_SECURE_PIN_PROPERTY      = "SmartCardSecurePin"
-	NCRYPT_READER_PROPERTY          = "SmartCardReader"
-	NCRYPT_ALGORITHM_PROPERTY       = "Algorithm Name"
-	NCRYPT_PCP_USAGE_AUTH_PROPERTY  = "PCP_USAGEAUTH"
-
-	// Key Storage Flags
-	NCRYPT_MACHINE_KEY_FLAG = 0x00000001
-
 	// Errors
 	NTE_NOT_SUPPORTED         = uint32(0x80090029)
 	NTE_INVALID_PARAMETER     = uint32(0x80090027)
@@ -67,9 +46,6 @@ const (
 	signatureKeyUsage = 0x80       // CERT_DIGITAL_SIGNATURE_KEY_USAGE
 	ncryptKeySpec     = 0xFFFFFFFF // CERT_NCRYPT_KEY_SPEC
 
-	BCRYPT_RSAPUBLIC_BLOB = "RSAPUBLICBLOB"
-	BCRYPT_ECCPUBLIC_BLOB = "ECCPUBLICBLOB"
-
 	// winerror.h constants
 	CRYPT_E_NOT_FOUND                    = uint32(0x80092004)
 	CRYPT_ACQUIRE_ALLOW_NCRYPT_KEY_FLAG  = uint32(0x00010000)
@@ -89,48 +65,9 @@ const (
 
 	// Legacy CryptoAPI flags
 	bCryptPadPKCS1 = uint32(2)
-
-	// Magic numbers for public key blobs.
-	rsa1Magic = 0x31415352 // "RSA1" BCRYPT_RSAPUBLIC_MAGIC
-	ecs1Magic = 0x31534345 // "ECS1" BCRYPT_ECDSA_PUBLIC_P256_MAGIC
-	ecs3Magic = 0x33534345 // "ECS3" BCRYPT_ECDSA_PUBLIC_P384_MAGIC
-	ecs5Magic = 0x35534345 // "ECS5" BCRYPT_ECDSA_PUBLIC_P521_MAGIC
-
-	ALG_RSA        = "RSA"
-	ALG_ECDSA_P256 = "ECDSA_P256"
-	ALG_ECDSA_P384 = "ECDSA_P384"
-	ALG_ECDSA_P521 = "ECDSA_P521"
-
-	ProviderMSKSP = "Microsoft Software Key Storage Provider"
-	ProviderMSSC  = "Microsoft Smart Card Key Storage Provider"
-	ProviderMSPCP = "Microsoft Platform Crypto Provider"
 )
 
 var (
-	// curveNames maps bcrypt.h curve names to elliptic curves.
-	curveNames = map[string]elliptic.Curve{
-		ALG_ECDSA_P256: elliptic.P256(),
-		ALG_ECDSA_P384: elliptic.P384(),
-		ALG_ECDSA_P521: elliptic.P521(),
-		"nistP256":     elliptic.P256(), // BCRYPT_ECC_CURVE_NISTP256
-		"nistP384":     elliptic.P384(), // BCRYPT_ECC_CURVE_NISTP384
-		"nistP521":     elliptic.P521(), // BCRYPT_ECC_CURVE_NISTP521
-	}
-
-	curveMagicMap = map[string]uint32{
-		"P-256": ecs1Magic,
-		"P-384": ecs3Magic,
-		"P-521": ecs5Magic,
-	}
-
-	// algIDs maps crypto.Hash values to bcrypt.h constants.
-	hashAlgorithms = map[crypto.Hash]string{
-		crypto.SHA1:   "SHA1",   // BCRYPT_SHA1_ALGORITHM
-		crypto.SHA256: "SHA256", // BCRYPT_SHA256_ALGORITHM
-		crypto.SHA384: "SHA384", // BCRYPT_SHA384_ALGORITHM
-		crypto.SHA512: "SHA512", // BCRYPT_SHA512_ALGORITHM
-	}
-
 	nCrypt                        = windows.MustLoadDLL("ncrypt.dll")
 	procNCryptCreatePersistedKey  = nCrypt.MustFindProc("NCryptCreatePersistedKey")
 	procNCryptExportKey           = nCrypt.MustFindProc("NCryptExportKey")
@@ -202,6 +139,41 @@ func wide(s string) *uint16 {
 	return w
 }
 
+// nCryptWindows implements nCryptAPI using ncrypt.dll.
+type nCryptWindows struct{}
+
+func (nCryptWindows) FreeObject(h uintptr) error {
+	return nCryptFreeObject(h)
+}
+
+func (nCryptWindows) CreatePersistedKey(providerHandle uintptr, containerName, algorithmName string, legacyKeySpec, flags uint32) (uintptr, error) {
+	return nCryptCreatePersistedKey(providerHandle, containerName, algorithmName, legacyKeySpec, flags)
+}
+
+func (nCryptWindows) OpenKey(providerHandle uintptr, containerName string, legacyKeySpec, flags uint32) (uintptr, error) {
+	return nCryptOpenKey(providerHandle, containerName, legacyKeySpec, flags)
+}
+
+func (nCryptWindows) FinalizeKey(keyHandle uintptr, flags uint32) error {
+	return nCryptFinalizeKey(keyHandle, flags)
+}
+
+func (nCryptWindows) SetProperty(keyHandle uintptr, propertyName string, propertyValue interface{}, flags uint32) error {
+	return nCryptSetProperty(keyHandle, propertyName, propertyValue, flags)
+}
+
+func (nCryptWindows) GetPropertyStr(keyHandle uintptr, propertyName string) (string, error) {
+	return nCryptGetPropertyStr(keyHandle, propertyName)
+}
+
+func (nCryptWindows) ExportKey(keyHandle uintptr, blobType string) ([]byte, error) {
+	return nCryptExportKey(keyHandle, blobType)
+}
+
+func (nCryptWindows) SignHash(keyHandle uintptr, digest []byte, hashID string) ([]byte, error) {
+	return nCryptSignHash(keyHandle, digest, hashID)
+}
+
 func nCryptOpenStorageProvider(provider string) (uintptr, error) {
 	var hProv uintptr
 	// Open the provider, the last parameter is not used
@@ -555,18 +527,3 @@ func certStrToName(x500Str string) ([]byte, error) {
 	}
 	return buf, nil
 }
-
-func hashPasswordUTF16(s string) ([]byte, error) {
-	utf16Str, err := windows.UTF16FromString(s)
-	if err != nil {
-		return nil, err
-	}
-	bytesStr := make([]byte, len(utf16Str)*2)
-	for i, utf16 := range utf16Str {
-		// LPCSTR (Windows' representation of utf16) is always little endian.
-		binary.LittleEndian.PutUint16(bytesStr[i*2:i*2+2], utf16)
-	}
-
-	digest := sha1.Sum(bytesStr[:len(bytesStr)-2]) // TODO: SHA256 is supported, but if used wont show the UI
-	return digest[:], nil
-}
@@ -1,7 +1,7 @@
 //go:build !windows || nocapi
 // +build !windows nocapi
 
-package cloudkms
+package capi
 
 import (
 	"context"
@@ -9,6 +9,8 @@ import (
 	"crypto/x509"
 	"log"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc/codes"
@@ -99,6 +101,8 @@ var newKeyManagementClient = func(ctx context.Context, opts ...option.ClientOpti
 // CloudKMS implements a KMS using Google's Cloud apiv1.
 type CloudKMS struct {
 	client KeyManagementClient
+	closed sync.Once
+	done   uint32
 }
 
 // New creates a new CloudKMS configured with a new client.
@@ -143,17 +147,40 @@ func NewCloudKMS(client KeyManagementClient) *CloudKMS {
 	}
 }
 
-// Close closes the connection of the Cloud KMS client.
-func (k *CloudKMS) Close() error {
-	if err := k.client.Close(); err != nil {
-		return errors.Wrap(err, "cloudKMS Close failed")
+// Close closes the connection of the Cloud KMS client. It is safe to call Close
+// multiple times, but only the first call will close the client. After Close
+// the CloudKMS operations will return an error wrapping apiv1.ErrClosed.
+func (k *CloudKMS) Close() (err error) {
+	k.closed.Do(func() {
+		atomic.StoreUint32(&k.done, 1)
+		if e := k.client.Close(); e != nil {
+			err = errors.Wrap(e, "cloudKMS Close failed")
+		}
+	})
+	return
+}
+
+func (k *CloudKMS) checkClosed() error {
+	if atomic.LoadUint32(&k.done) == 1 {
+		return errors.Wrap(apiv1.ErrClosed, "cloudKMS is closed")
 	}
 	return nil
 }
 
+// Capabilities returns the operations supported by the CloudKMS.
+func (k *CloudKMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:    true,
+		CreateSigner: true,
+	}
+}
+
 // CreateSigner returns a new cloudkms signer configured with the given signing
 // key name.
 func (k *CloudKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	if req.SigningKey == "" {
 		return nil, errors.New("signing key cannot be empty")
 	}
@@ -162,29 +189,12 @@ func (k *CloudKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer,
 
 // CreateKey creates in Google's Cloud KMS a new asymmetric key for signing.
 func (k *CloudKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
-	if req.Name == "" {
-		return nil, errors.New("createKeyRequest 'name' cannot be empty")
-	}
-
-	protectionLevel, ok := protectionLevelMapping[req.ProtectionLevel]
-	if !ok {
-		return nil, errors.Errorf("cloudKMS does not support protection level '%s'", req.ProtectionLevel)
-	}
-
-	var signatureAlgorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
-	v, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
-	if !ok {
-		return nil, errors.Errorf("cloudKMS does not support signature algorithm '%s'", req.SignatureAlgorithm)
+	if err := k.checkClosed(); err != nil {
+		return nil, err
 	}
-	switch v := v.(type) {
-	case kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm:
-		signatureAlgorithm = v
-	case map[int]kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm:
-		if signatureAlgorithm, ok = v[req.Bits]; !ok {
-			return nil, errors.Errorf("cloudKMS does not support signature algorithm '%s' with '%d' bits", req.SignatureAlgorithm, req.Bits)
-		}
-	default:
-		return nil, errors.Errorf("unexpected error: this should not happen")
+	protectionLevel, signatureAlgorithm, err := createKeyParameters(req)
+	if err != nil {
+		return nil, err
 	}
 
 	var crytoKeyName string
@@ -257,6 +267,48 @@ func (k *CloudKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespo
 	}, nil
 }
 
+// ValidateCreateKey validates the parameters of the given request without
+// creating the key. It returns the same errors as CreateKey for unsupported
+// protection levels, algorithms, key sizes and public exponents.
+func (k *CloudKMS) ValidateCreateKey(req *apiv1.CreateKeyRequest) error {
+	_, _, err := createKeyParameters(req)
+	return err
+}
+
+// createKeyParameters returns the protection level and the algorithm used to
+// create the key in the given request.
+func createKeyParameters(req *apiv1.CreateKeyRequest) (kmspb.ProtectionLevel, kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, error) {
+	if req.Name == "" {
+		return 0, 0, errors.New("createKeyRequest 'name' cannot be empty")
+	}
+	if req.PublicExponent != 0 && req.PublicExponent != 65537 {
+		return 0, 0, errors.Errorf("cloudKMS does not support public exponent %d", req.PublicExponent)
+	}
+
+	protectionLevel, ok := protectionLevelMapping[req.ProtectionLevel]
+	if !ok {
+		return 0, 0, errors.Errorf("cloudKMS does not support protection level '%s'", req.ProtectionLevel)
+	}
+
+	var signatureAlgorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+	v, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
+	if !ok {
+		return 0, 0, errors.Errorf("cloudKMS does not support signature algorithm '%s'", req.SignatureAlgorithm)
+	}
+	switch v := v.(type) {
+	case kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm:
+		signatureAlgorithm = v
+	case map[int]kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm:
+		if signatureAlgorithm, ok = v[req.Bits]; !ok {
+			return 0, 0, errors.Errorf("cloudKMS does not support signature algorithm '%s' with '%d' bits", req.SignatureAlgorithm, req.Bits)
+		}
+	default:
+		return 0, 0, errors.Errorf("unexpected error: this should not happen")
+	}
+
+	return protectionLevel, signatureAlgorithm, nil
+}
+
 func (k *CloudKMS) createKeyRingIfNeeded(name string) error {
 	ctx, cancel := defaultContext()
 	defer cancel()
@@ -285,6 +337,9 @@ func (k *CloudKMS) createKeyRingIfNeeded(name string) error {
 //
 //	projects/([^/]+)/locations/([a-zA-Z0-9_-]{1,63})/keyRings/([a-zA-Z0-9_-]{1,63})/cryptoKeys/([a-zA-Z0-9_-]{1,63})/cryptoKeyVersions/([a-zA-Z0-9_-]{1,63})
 func (k *CloudKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	if req.Name == "" {
 		return nil, errors.New("createKeyRequest 'name' cannot be empty")
 	}
@@ -3,6 +3,7 @@ package cloudkms
 import (
 	"context"
 	"crypto"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -128,7 +129,7 @@ func TestNewCloudKMS(t *testing.T) {
 		args args
 		want *CloudKMS
 	}{
-		{"ok", args{&MockClient{}}, &CloudKMS{&MockClient{}}},
+		{"ok", args{&MockClient{}}, &CloudKMS{client: &MockClient{}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -163,6 +164,35 @@ func TestCloudKMS_Close(t *testing.T) {
 	}
 }
 
+func TestCloudKMS_Close_closed(t *testing.T) {
+	var calls int
+	k := &CloudKMS{
+		client: &MockClient{close: func() error {
+			calls++
+			return nil
+		}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := k.Close(); err != nil {
+			t.Fatalf("CloudKMS.Close() error = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("KeyManagementClient.Close() called %d times, want 1", calls)
+	}
+
+	name := "projects/p/locations/l/keyRings/k/cryptoKeys/c/cryptoKeyVersions/1"
+	if _, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: name}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("CloudKMS.GetPublicKey() error = %v, want apiv1.ErrClosed", err)
+	}
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("CloudKMS.CreateKey() error = %v, want apiv1.ErrClosed", err)
+	}
+	if _, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: name}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("CloudKMS.CreateSigner() error = %v, want apiv1.ErrClosed", err)
+	}
+}
+
 func TestCloudKMS_CreateSigner(t *testing.T) {
 	keyName := "projects/p/locations/l/keyRings/k/cryptoKeys/c/cryptoKeyVersions/1"
 	pemBytes, err := os.ReadFile("testdata/pub.pem")
@@ -313,6 +343,7 @@ func TestCloudKMS_CreateKey(t *testing.T) {
 			args{&apiv1.CreateKeyRequest{Name: keyName, ProtectionLevel: apiv1.HSM, SignatureAlgorithm: apiv1.ECDSAWithSHA256}},
 			&apiv1.CreateKeyResponse{Name: keyName + "/cryptoKeyVersions/1", PublicKey: pk, CreateSignerRequest: apiv1.CreateSignerRequest{SigningKey: keyName + "/cryptoKeyVersions/1"}}, false},
 		{"fail name", fields{&MockClient{}}, args{&apiv1.CreateKeyRequest{}}, nil, true},
+		{"fail public exponent", fields{&MockClient{}}, args{&apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.SHA256WithRSA, PublicExponent: 3}}, nil, true},
 		{"fail protection level", fields{&MockClient{}}, args{&apiv1.CreateKeyRequest{Name: keyName, ProtectionLevel: apiv1.ProtectionLevel(100)}}, nil, true},
 		{"fail signature algorithm", fields{&MockClient{}}, args{&apiv1.CreateKeyRequest{Name: keyName, ProtectionLevel: apiv1.Software, SignatureAlgorithm: apiv1.SignatureAlgorithm(100)}}, nil, true},
 		{"fail number of bits", fields{&MockClient{}}, args{&apiv1.CreateKeyRequest{Name: keyName, ProtectionLevel: apiv1.Software, SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}},
@@ -385,6 +416,32 @@ func TestCloudKMS_CreateKey(t *testing.T) {
 	}
 }
 
+func TestCloudKMS_ValidateCreateKey(t *testing.T) {
+	keyName := "projects/p/locations/l/keyRings/k/cryptoKeys/c"
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateKeyRequest
+		wantErr bool
+	}{
+		{"ok", &apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.ECDSAWithSHA256}, false},
+		{"ok rsa", &apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 3072, ProtectionLevel: apiv1.HSM}, false},
+		{"fail name", &apiv1.CreateKeyRequest{SignatureAlgorithm: apiv1.ECDSAWithSHA256}, true},
+		{"fail protection level", &apiv1.CreateKeyRequest{Name: keyName, ProtectionLevel: apiv1.ProtectionLevel(100)}, true},
+		{"fail algorithm", &apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.PureEd25519}, true},
+		{"fail bits", &apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}, true},
+		{"fail public exponent", &apiv1.CreateKeyRequest{Name: keyName, SignatureAlgorithm: apiv1.SHA256WithRSA, PublicExponent: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The client is not set, ValidateCreateKey must not use it.
+			k := &CloudKMS{}
+			if err := k.ValidateCreateKey(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("CloudKMS.ValidateCreateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestCloudKMS_GetPublicKey(t *testing.T) {
 	keyName := "projects/p/locations/l/keyRings/k/cryptoKeys/c/cryptoKeyVersions/1"
 	testError := fmt.Errorf("an error")
@@ -10,6 +10,7 @@ import (
 
 	"cloud.google.com/go/kms/apiv1/kmspb"
 	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/pemutil"
 )
 
@@ -55,6 +56,30 @@ func (s *Signer) Public() crypto.PublicKey {
 	return s.publicKey
 }
 
+// JOSEAlgorithm returns the JOSE signature algorithm for the key of this
+// signer. Cloud KMS keys are bound to a signature algorithm, so, for example,
+// an RSA_SIGN_PSS_2048_SHA256 key returns PS256. If the algorithm is not known,
+// it returns the default one for the public key, e.g. ES256 for a P-256 key or
+// RS256 for an RSA key.
+func (s *Signer) JOSEAlgorithm() string {
+	switch s.algorithm {
+	case x509.ECDSAWithSHA256:
+		return "ES256"
+	case x509.ECDSAWithSHA384:
+		return "ES384"
+	case x509.SHA256WithRSA:
+		return "RS256"
+	case x509.SHA512WithRSA:
+		return "RS512"
+	case x509.SHA256WithRSAPSS:
+		return "PS256"
+	case x509.SHA512WithRSAPSS:
+		return "PS512"
+	default:
+		return apiv1.DefaultJOSEAlgorithm(s.publicKey)
+	}
+}
+
 // Sign signs digest with the private key stored in Google's Cloud KMS.
 func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	req := &kmspb.AsymmetricSignRequest{
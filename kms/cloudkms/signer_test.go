@@ -233,3 +233,44 @@ func TestSigner_SignatureAlgorithm(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_JOSEAlgorithm(t *testing.T) {
+	pemBytes, err := os.ReadFile("testdata/pub.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &MockClient{
+		getPublicKey: func(_ context.Context, req *kmspb.GetPublicKeyRequest, _ ...gax.CallOption) (*kmspb.PublicKey, error) {
+			return &kmspb.PublicKey{
+				Pem:       string(pemBytes),
+				Algorithm: kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm(kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm_value[req.Name]),
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		signingKey string
+		want       string
+	}{
+		{"EC_SIGN_P256_SHA256", "EC_SIGN_P256_SHA256", "ES256"},
+		{"EC_SIGN_P384_SHA384", "EC_SIGN_P384_SHA384", "ES384"},
+		{"RSA_SIGN_PKCS1_2048_SHA256", "RSA_SIGN_PKCS1_2048_SHA256", "RS256"},
+		{"RSA_SIGN_PKCS1_4096_SHA512", "RSA_SIGN_PKCS1_4096_SHA512", "RS512"},
+		{"RSA_SIGN_PSS_3072_SHA256", "RSA_SIGN_PSS_3072_SHA256", "PS256"},
+		{"RSA_SIGN_PSS_4096_SHA512", "RSA_SIGN_PSS_4096_SHA512", "PS512"},
+		{"unknown uses public key", "UNKNOWN", "ES256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(client, tt.signingKey)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			if got := signer.JOSEAlgorithm(); got != tt.want {
+				t.Errorf("Signer.JOSEAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/uri"
 
 	// Enable default implementation
 	"go.step.sm/crypto/kms/softkms"
@@ -36,6 +37,10 @@ type Type = apiv1.Type
 var Default = &softkms.SoftKMS{}
 
 // New initializes a new KMS from the given type.
+//
+// If the URI in the options has the "cache-dir" parameter, e.g.
+// "azurekms:vault=my-vault;cache-dir=/var/cache/kms", the public keys are
+// cached in that directory, see apiv1.PublicKeyCache.
 func New(ctx context.Context, opts apiv1.Options) (KeyManager, error) {
 	if err := opts.Validate(); err != nil {
 		return nil, err
@@ -49,5 +54,24 @@ func New(ctx context.Context, opts apiv1.Options) (KeyManager, error) {
 	if !ok {
 		return nil, errors.Errorf("unsupported kms type '%s'", typ)
 	}
-	return fn(ctx, opts)
+	km, err := fn(ctx, opts)
+	if err != nil || opts.URI == "" {
+		return km, err
+	}
+
+	u, err := uri.Parse(opts.URI)
+	if err != nil {
+		km.Close()
+		return nil, err
+	}
+	if dir := u.Get("cache-dir"); dir != "" {
+		cache, err := apiv1.NewPublicKeyCache(km, dir)
+		if err != nil {
+			km.Close()
+			return nil, err
+		}
+		return cache, nil
+	}
+
+	return km, nil
 }
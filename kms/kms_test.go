@@ -10,6 +10,7 @@ import (
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/kms/awskms"
 	"go.step.sm/crypto/kms/cloudkms"
+	"go.step.sm/crypto/kms/kmstest"
 	"go.step.sm/crypto/kms/softkms"
 )
 
@@ -24,6 +25,11 @@ func TestNew(t *testing.T) {
 		}
 	}
 
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, []byte("not a directory"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
 	type args struct {
 		ctx  context.Context
 		opts apiv1.Options
@@ -40,7 +46,10 @@ func TestNew(t *testing.T) {
 		{"uri", false, args{ctx, apiv1.Options{URI: "softkms:foo=bar"}}, &softkms.SoftKMS{}, false},
 		{"awskms", false, args{ctx, apiv1.Options{Type: "awskms"}}, &awskms.KMS{}, false},
 		{"cloudkms", true, args{ctx, apiv1.Options{Type: "cloudkms"}}, &cloudkms.CloudKMS{}, failCloudKMS},
+		{"kmstest", false, args{ctx, apiv1.Options{Type: "kmstest"}}, &kmstest.KeyManager{}, false},
+		{"cache-dir", false, args{ctx, apiv1.Options{URI: "softkms:cache-dir=" + t.TempDir()}}, &apiv1.PublicKeyCache{}, false},
 		{"fail validation", false, args{ctx, apiv1.Options{Type: "foobar"}}, nil, true},
+		{"fail cache-dir", false, args{ctx, apiv1.Options{URI: "softkms:cache-dir=" + filepath.Join(notDir, "cache")}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
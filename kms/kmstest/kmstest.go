@@ -0,0 +1,347 @@
+// Package kmstest implements an in-memory KeyManager that can be used to test
+// the code using a KMS without access to a real one.
+//
+// The KeyManager generates software keys on demand, records the operations
+// called, and can be programmed to fail in specific operations. Importing this
+// package registers the KeyManager under the "kmstest" type, so the key names
+// like "kmstest:name=my-key" can be used with kms.New and the
+// apiv1.MultiKeyManager. Tests that need to inspect the KeyManager used by the
+// code under test can replace it with Register.
+package kmstest
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/uri"
+)
+
+// Scheme is the scheme used in the key names of the KeyManager.
+const Scheme = string(apiv1.KMSTest)
+
+// Names of the operations recorded by the KeyManager.
+const (
+	MethodGetPublicKey = "GetPublicKey"
+	MethodCreateKey    = "CreateKey"
+	MethodCreateSigner = "CreateSigner"
+	MethodSign         = "Sign"
+	MethodClose        = "Close"
+)
+
+var signatureAlgorithmMapping = map[apiv1.SignatureAlgorithm][2]string{
+	apiv1.UnspecifiedSignAlgorithm: {"EC", "P-256"},
+	apiv1.SHA256WithRSA:            {"RSA", ""},
+	apiv1.SHA384WithRSA:            {"RSA", ""},
+	apiv1.SHA512WithRSA:            {"RSA", ""},
+	apiv1.SHA256WithRSAPSS:         {"RSA", ""},
+	apiv1.SHA384WithRSAPSS:         {"RSA", ""},
+	apiv1.SHA512WithRSAPSS:         {"RSA", ""},
+	apiv1.ECDSAWithSHA256:          {"EC", "P-256"},
+	apiv1.ECDSAWithSHA384:          {"EC", "P-384"},
+	apiv1.ECDSAWithSHA512:          {"EC", "P-521"},
+	apiv1.PureEd25519:              {"OKP", "Ed25519"},
+}
+
+// DefaultRSAKeySize is the size of the RSA keys created without bits. It is
+// smaller than the one used by the real KMSs to keep the tests fast.
+const DefaultRSAKeySize = 2048
+
+// Call represents an operation called on the KeyManager.
+type Call struct {
+	Method string
+	Name   string
+}
+
+// KeyManager is an in-memory apiv1.KeyManager for testing. Keys are generated
+// on first use: GetPublicKey and CreateSigner create a new P-256 key if the
+// requested one does not exist, and CreateKey always creates a new one. A
+// KeyManager is safe for concurrent use.
+type KeyManager struct {
+	mu     sync.Mutex
+	keys   map[string]crypto.Signer
+	errs   map[string]error
+	calls  []Call
+	closed bool
+}
+
+// New creates a new empty KeyManager.
+func New(ctx context.Context, opts apiv1.Options) (*KeyManager, error) {
+	return &KeyManager{
+		keys: make(map[string]crypto.Signer),
+		errs: make(map[string]error),
+	}, nil
+}
+
+func init() {
+	apiv1.Register(apiv1.KMSTest, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return New(ctx, opts)
+	})
+}
+
+// TestingT is the subset of testing.TB used by the helpers in this package. It
+// is defined here so the package does not import the testing package.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Register makes kms.New and the apiv1.MultiKeyManager return k for the
+// "kmstest" type, so the test can inspect the operations done by the code
+// under test. The default constructor is restored when the test ends. Tests
+// using Register must not run in parallel.
+func Register(t TestingT, k *KeyManager) {
+	t.Helper()
+	fn, _ := apiv1.LoadKeyManagerNewFunc(apiv1.KMSTest)
+	apiv1.Register(apiv1.KMSTest, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return k, nil
+	})
+	t.Cleanup(func() {
+		apiv1.Register(apiv1.KMSTest, fn)
+	})
+}
+
+// SetError programs the KeyManager to return err in all the following calls to
+// the given method. Setting a nil error clears it.
+func (k *KeyManager) SetError(method string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err == nil {
+		delete(k.errs, method)
+	} else {
+		k.errs[method] = err
+	}
+}
+
+// AddKey stores the given signer under the given name.
+func (k *KeyManager) AddKey(name string, signer crypto.Signer) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[parseName(name)] = signer
+}
+
+// Calls returns the operations called on the KeyManager in order.
+func (k *KeyManager) Calls() []Call {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]Call(nil), k.calls...)
+}
+
+// CallCount returns the number of times the given method has been called.
+func (k *KeyManager) CallCount(method string) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var n int
+	for _, c := range k.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset clears the recorded calls and the programmed errors. The keys are not
+// removed.
+func (k *KeyManager) Reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.calls = nil
+	k.errs = make(map[string]error)
+}
+
+// AssertCalled fails the test if the given method has not been called.
+func (k *KeyManager) AssertCalled(t TestingT, method string) {
+	t.Helper()
+	if k.CallCount(method) == 0 {
+		t.Errorf("kmstest: %s was not called", method)
+	}
+}
+
+// AssertNotCalled fails the test if the given method has been called.
+func (k *KeyManager) AssertNotCalled(t TestingT, method string) {
+	t.Helper()
+	if n := k.CallCount(method); n != 0 {
+		t.Errorf("kmstest: %s was called %d times", method, n)
+	}
+}
+
+// AssertCalls fails the test if the names of the methods called are not the
+// given ones, in the same order.
+func (k *KeyManager) AssertCalls(t TestingT, methods ...string) {
+	t.Helper()
+	calls := k.Calls()
+	got := make([]string, len(calls))
+	for i, c := range calls {
+		got[i] = c.Method
+	}
+	if strings.Join(got, ",") != strings.Join(methods, ",") {
+		t.Errorf("kmstest: calls = %v, want %v", got, methods)
+	}
+}
+
+// record adds the call to the list and returns the error programmed for the
+// method, if any. It must be called with the lock held.
+func (k *KeyManager) record(method, name string) error {
+	k.calls = append(k.calls, Call{Method: method, Name: name})
+	if k.closed && method != MethodClose {
+		return fmt.Errorf("kmstest %s failed: %w", method, apiv1.ErrClosed)
+	}
+	return k.errs[method]
+}
+
+// getOrCreate returns the key with the given name, creating a new P-256 key
+// if it does not exist. It must be called with the lock held.
+func (k *KeyManager) getOrCreate(name string) (crypto.Signer, error) {
+	if signer, ok := k.keys[name]; ok {
+		return signer, nil
+	}
+	signer, err := generateKey(apiv1.UnspecifiedSignAlgorithm, 0)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[name] = signer
+	return signer, nil
+}
+
+// GetPublicKey returns the public key of the key with the given name.
+func (k *KeyManager) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name := parseName(req.Name)
+	if err := k.record(MethodGetPublicKey, name); err != nil {
+		return nil, err
+	}
+	signer, err := k.getOrCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// CreateKey generates a new key and stores it with the name in the request,
+// replacing any previous key with the same name.
+func (k *KeyManager) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name := parseName(req.Name)
+	if err := k.record(MethodCreateKey, name); err != nil {
+		return nil, err
+	}
+	signer, err := generateKey(req.SignatureAlgorithm, req.Bits)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[name] = signer
+	return &apiv1.CreateKeyResponse{
+		Name:       req.Name,
+		PublicKey:  signer.Public(),
+		PrivateKey: signer,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: req.Name,
+		},
+	}, nil
+}
+
+// CreateSigner returns a signer for the key with the given signing key name.
+// The Sign operations of the signer are recorded in the KeyManager.
+func (k *KeyManager) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name := parseName(req.SigningKey)
+	if err := k.record(MethodCreateSigner, name); err != nil {
+		return nil, err
+	}
+	signer, err := k.getOrCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{km: k, name: name, signer: signer}, nil
+}
+
+// Close marks the KeyManager as closed, the following operations will return
+// an error wrapping apiv1.ErrClosed.
+func (k *KeyManager) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.record(MethodClose, ""); err != nil {
+		return err
+	}
+	k.closed = true
+	return nil
+}
+
+// Capabilities returns the operations supported by the KeyManager.
+func (k *KeyManager) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:    true,
+		CreateSigner: true,
+	}
+}
+
+// Signer is the crypto.Signer returned by the KeyManager.
+type Signer struct {
+	km     *KeyManager
+	name   string
+	signer crypto.Signer
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+// Sign signs the digest with the key in memory, or returns the error
+// programmed for MethodSign.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.km.mu.Lock()
+	err := s.km.record(MethodSign, s.name)
+	s.km.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.signer.Sign(rand, digest, opts)
+}
+
+// JOSEAlgorithm returns the default JOSE algorithm for the key.
+func (s *Signer) JOSEAlgorithm() string {
+	return apiv1.DefaultJOSEAlgorithm(s.signer.Public())
+}
+
+func generateKey(alg apiv1.SignatureAlgorithm, bits int) (crypto.Signer, error) {
+	v, ok := signatureAlgorithmMapping[alg]
+	if !ok {
+		return nil, apiv1.UnsupportedAlgorithmError{
+			Message: fmt.Sprintf("kmstest does not support signature algorithm '%s'", alg),
+		}
+	}
+	if v[0] == "RSA" && bits == 0 {
+		bits = DefaultRSAKeySize
+	}
+	priv, err := keyutil.GenerateSigner(v[0], v[1], bits)
+	if err != nil {
+		return nil, fmt.Errorf("kmstest failed to generate key: %w", err)
+	}
+	return priv, nil
+}
+
+// parseName returns the name attribute of a "kmstest:name=..." uri, or the
+// given string if it does not have the kmstest scheme.
+func parseName(rawuri string) string {
+	if !uri.HasScheme(Scheme, rawuri) {
+		return rawuri
+	}
+	u, err := uri.ParseWithScheme(Scheme, rawuri)
+	if err != nil {
+		return rawuri
+	}
+	if name := u.Get("name"); name != "" {
+		return name
+	}
+	return u.Opaque
+}
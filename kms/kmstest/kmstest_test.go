@@ -0,0 +1,113 @@
+package kmstest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+func TestKeyManager(t *testing.T) {
+	km, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+
+	pub, err := km.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "kmstest:name=foo"})
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PublicKey{}, pub)
+
+	signer, err := km.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, pub, signer.Public())
+	assert.Equal(t, "ES256", signer.(apiv1.JOSEAlgorithmSigner).JOSEAlgorithm())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig))
+
+	resp, err := km.CreateKey(&apiv1.CreateKeyRequest{Name: "kmstest:name=bar", SignatureAlgorithm: apiv1.PureEd25519})
+	require.NoError(t, err)
+	assert.IsType(t, ed25519.PublicKey{}, resp.PublicKey)
+	signer, err = km.CreateSigner(&resp.CreateSignerRequest)
+	require.NoError(t, err)
+	assert.Equal(t, resp.PublicKey, signer.Public())
+
+	km.AssertCalls(t, MethodGetPublicKey, MethodCreateSigner, MethodSign, MethodCreateKey, MethodCreateSigner)
+	km.AssertCalled(t, MethodSign)
+	km.AssertNotCalled(t, MethodClose)
+	assert.Equal(t, Call{Method: MethodSign, Name: "foo"}, km.Calls()[2])
+
+	require.NoError(t, km.Close())
+	_, err = km.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "foo"})
+	assert.ErrorIs(t, err, apiv1.ErrClosed)
+}
+
+func TestKeyManager_CreateKey(t *testing.T) {
+	km, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+
+	resp, err := km.CreateKey(&apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSA})
+	require.NoError(t, err)
+	if assert.IsType(t, &rsa.PublicKey{}, resp.PublicKey) {
+		assert.Equal(t, DefaultRSAKeySize, resp.PublicKey.(*rsa.PublicKey).N.BitLen())
+	}
+
+	_, err = km.CreateKey(&apiv1.CreateKeyRequest{Name: "bad", SignatureAlgorithm: apiv1.SignatureAlgorithm(100)})
+	var uerr apiv1.UnsupportedAlgorithmError
+	assert.ErrorAs(t, err, &uerr)
+}
+
+func TestKeyManager_SetError(t *testing.T) {
+	errTest := errors.New("test error")
+	km, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+
+	km.SetError(MethodCreateSigner, errTest)
+	_, err = km.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "foo"})
+	assert.Equal(t, errTest, err)
+
+	km.SetError(MethodCreateSigner, nil)
+	km.SetError(MethodSign, errTest)
+	signer, err := km.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "foo"})
+	require.NoError(t, err)
+	_, err = signer.Sign(rand.Reader, make([]byte, 32), crypto.SHA256)
+	assert.Equal(t, errTest, err)
+	assert.Equal(t, 2, km.CallCount(MethodCreateSigner))
+
+	km.Reset()
+	assert.Empty(t, km.Calls())
+	_, err = signer.Sign(rand.Reader, make([]byte, 32), crypto.SHA256)
+	assert.NoError(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	km, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+	key, err := generateKey(apiv1.ECDSAWithSHA384, 0)
+	require.NoError(t, err)
+	km.AddKey("kmstest:name=foo", key)
+
+	t.Run("registered", func(t *testing.T) {
+		Register(t, km)
+		m := apiv1.NewMultiKeyManager(context.Background())
+		signer, err := m.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "kmstest:name=foo"})
+		require.NoError(t, err)
+		assert.Equal(t, key.Public(), signer.Public())
+		km.AssertCalled(t, MethodCreateSigner)
+	})
+
+	// The default constructor creates a new KeyManager.
+	m := apiv1.NewMultiKeyManager(context.Background())
+	signer, err := m.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "kmstest:name=foo"})
+	require.NoError(t, err)
+	assert.NotEqual(t, key.Public(), signer.Public())
+}
@@ -14,6 +14,7 @@ import (
 	"math/big"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ThalesIgnite/crypto11"
 	"github.com/pkg/errors"
@@ -48,6 +49,7 @@ var p11Configure = func(config *crypto11.Config) (P11, error) {
 type PKCS11 struct {
 	p11    P11
 	closed sync.Once
+	done   uint32
 }
 
 // New returns a new PKCS11 KMS.
@@ -108,6 +110,9 @@ func init() {
 
 // GetPublicKey returns the public key ....
 func (k *PKCS11) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, errors.Wrap(err, "getPublicKey failed")
+	}
 	if req.Name == "" {
 		return nil, errors.New("getPublicKeyRequest 'name' cannot be empty")
 	}
@@ -122,11 +127,16 @@ func (k *PKCS11) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey,
 
 // CreateKey generates a new key in the PKCS#11 module and returns the public key.
 func (k *PKCS11) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, errors.Wrap(err, "createKey failed")
+	}
 	switch {
 	case req.Name == "":
 		return nil, errors.New("createKeyRequest 'name' cannot be empty")
 	case req.Bits < 0:
 		return nil, errors.New("createKeyRequest 'bits' cannot be negative")
+	case req.PublicExponent != 0 && req.PublicExponent != 65537:
+		return nil, errors.Errorf("createKeyRequest 'publicExponent' %d is not supported", req.PublicExponent)
 	}
 
 	signer, err := generateKey(k.p11, req)
@@ -145,6 +155,9 @@ func (k *PKCS11) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespons
 
 // CreateSigner creates a signer using a key present in the PKCS#11 module.
 func (k *PKCS11) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, errors.Wrap(err, "createSigner failed")
+	}
 	if req.SigningKey == "" {
 		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
 	}
@@ -160,6 +173,9 @@ func (k *PKCS11) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, er
 // CreateDecrypter creates a decrypter using a key present in the PKCS#11
 // module.
 func (k *PKCS11) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Decrypter, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, errors.Wrap(err, "createDecrypterRequest failed")
+	}
 	if req.DecryptionKey == "" {
 		return nil, errors.New("createDecrypterRequest 'decryptionKey' cannot be empty")
 	}
@@ -181,6 +197,9 @@ func (k *PKCS11) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Decr
 // LoadCertificate implements kms.CertificateManager and loads a certificate
 // from the YubiKey.
 func (k *PKCS11) LoadCertificate(req *apiv1.LoadCertificateRequest) (*x509.Certificate, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, errors.Wrap(err, "loadCertificate failed")
+	}
 	if req.Name == "" {
 		return nil, errors.New("loadCertificateRequest 'name' cannot be nil")
 	}
@@ -194,6 +213,9 @@ func (k *PKCS11) LoadCertificate(req *apiv1.LoadCertificateRequest) (*x509.Certi
 // StoreCertificate implements kms.CertificateManager and stores a certificate
 // in the YubiKey.
 func (k *PKCS11) StoreCertificate(req *apiv1.StoreCertificateRequest) error {
+	if err := k.checkClosed(); err != nil {
+		return errors.Wrap(err, "storeCertificate failed")
+	}
 	switch {
 	case req.Name == "":
 		return errors.New("storeCertificateRequest 'name' cannot be empty")
@@ -241,6 +263,9 @@ func (k *PKCS11) StoreCertificate(req *apiv1.StoreCertificateRequest) error {
 
 // DeleteKey is a utility function to delete a key given an uri.
 func (k *PKCS11) DeleteKey(u string) error {
+	if err := k.checkClosed(); err != nil {
+		return errors.Wrap(err, "deleteKey failed")
+	}
 	id, object, err := parseObject(u)
 	if err != nil {
 		return errors.Wrap(err, "deleteKey failed")
@@ -260,6 +285,9 @@ func (k *PKCS11) DeleteKey(u string) error {
 
 // DeleteCertificate is a utility function to delete a certificate given an uri.
 func (k *PKCS11) DeleteCertificate(u string) error {
+	if err := k.checkClosed(); err != nil {
+		return errors.Wrap(err, "deleteCertificate failed")
+	}
 	id, object, err := parseObject(u)
 	if err != nil {
 		return errors.Wrap(err, "deleteCertificate failed")
@@ -270,14 +298,36 @@ func (k *PKCS11) DeleteCertificate(u string) error {
 	return nil
 }
 
-// Close releases the connection to the PKCS#11 module.
+// Close releases the connection to the PKCS#11 module. It is safe to call
+// Close multiple times, but only the first call will close the PKCS#11
+// context. After Close the PKCS11 operations will return an error wrapping
+// apiv1.ErrClosed.
 func (k *PKCS11) Close() (err error) {
 	k.closed.Do(func() {
+		atomic.StoreUint32(&k.done, 1)
 		err = errors.Wrap(k.p11.Close(), "error closing pkcs#11 context")
 	})
 	return
 }
 
+func (k *PKCS11) checkClosed() error {
+	if atomic.LoadUint32(&k.done) == 1 {
+		return apiv1.ErrClosed
+	}
+	return nil
+}
+
+// Capabilities returns the operations supported by the PKCS11.
+func (k *PKCS11) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:        true,
+		CreateSigner:     true,
+		CreateDecrypter:  true,
+		LoadCertificate:  true,
+		StoreCertificate: true,
+	}
+}
+
 func toByte(s string) []byte {
 	if s == "" {
 		return nil
@@ -834,3 +834,53 @@ func TestPKCS11_Close(t *testing.T) {
 		})
 	}
 }
+
+func TestPKCS11_closed(t *testing.T) {
+	k := mustPKCS11(t)
+	if err := k.Close(); err != nil {
+		t.Fatalf("PKCS11.Close() error = %v", err)
+	}
+
+	name := "pkcs11:id=7371;object=rsa-key"
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetPublicKey", func() error {
+			_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateKey", func() error {
+			_, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateSigner", func() error {
+			_, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: name})
+			return err
+		}},
+		{"CreateDecrypter", func() error {
+			_, err := k.CreateDecrypter(&apiv1.CreateDecrypterRequest{DecryptionKey: name})
+			return err
+		}},
+		{"LoadCertificate", func() error {
+			_, err := k.LoadCertificate(&apiv1.LoadCertificateRequest{Name: name})
+			return err
+		}},
+		{"StoreCertificate", func() error {
+			return k.StoreCertificate(&apiv1.StoreCertificateRequest{Name: name, Certificate: &x509.Certificate{}})
+		}},
+		{"DeleteKey", func() error {
+			return k.DeleteKey(name)
+		}},
+		{"DeleteCertificate", func() error {
+			return k.DeleteCertificate(name)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, apiv1.ErrClosed) {
+				t.Errorf("PKCS11.%s() error = %v, want apiv1.ErrClosed", tt.name, err)
+			}
+		})
+	}
+}
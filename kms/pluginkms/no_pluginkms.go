@@ -0,0 +1,20 @@
+//go:build nopluginkms
+// +build nopluginkms
+
+package pluginkms
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+func init() {
+	apiv1.Register(apiv1.PluginKMS, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		name := filepath.Base(os.Args[0])
+		return nil, errors.Errorf("unsupported kms type 'pluginkms': %s is compiled without KMS plugins support", name)
+	})
+}
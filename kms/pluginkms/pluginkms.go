@@ -0,0 +1,329 @@
+//go:build !nopluginkms
+// +build !nopluginkms
+
+package pluginkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/pluginkms/pluginpb"
+	"go.step.sm/crypto/kms/uri"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Scheme is the scheme used in uris.
+const Scheme = "pluginkms"
+
+// protectionLevelMapping maps the step protection levels with the plugin ones.
+var protectionLevelMapping = map[apiv1.ProtectionLevel]pluginpb.ProtectionLevel{
+	apiv1.UnspecifiedProtectionLevel: pluginpb.ProtectionLevel_PROTECTION_LEVEL_UNSPECIFIED,
+	apiv1.Software:                   pluginpb.ProtectionLevel_SOFTWARE,
+	apiv1.HSM:                        pluginpb.ProtectionLevel_HSM,
+}
+
+// signatureAlgorithmMapping maps the step signature algorithms with the plugin
+// ones.
+var signatureAlgorithmMapping = map[apiv1.SignatureAlgorithm]pluginpb.SignatureAlgorithm{
+	apiv1.UnspecifiedSignAlgorithm: pluginpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_UNSPECIFIED,
+	apiv1.SHA256WithRSA:            pluginpb.SignatureAlgorithm_SHA256_WITH_RSA,
+	apiv1.SHA384WithRSA:            pluginpb.SignatureAlgorithm_SHA384_WITH_RSA,
+	apiv1.SHA512WithRSA:            pluginpb.SignatureAlgorithm_SHA512_WITH_RSA,
+	apiv1.SHA256WithRSAPSS:         pluginpb.SignatureAlgorithm_SHA256_WITH_RSA_PSS,
+	apiv1.SHA384WithRSAPSS:         pluginpb.SignatureAlgorithm_SHA384_WITH_RSA_PSS,
+	apiv1.SHA512WithRSAPSS:         pluginpb.SignatureAlgorithm_SHA512_WITH_RSA_PSS,
+	apiv1.ECDSAWithSHA256:          pluginpb.SignatureAlgorithm_ECDSA_WITH_SHA256,
+	apiv1.ECDSAWithSHA384:          pluginpb.SignatureAlgorithm_ECDSA_WITH_SHA384,
+	apiv1.ECDSAWithSHA512:          pluginpb.SignatureAlgorithm_ECDSA_WITH_SHA512,
+	apiv1.PureEd25519:              pluginpb.SignatureAlgorithm_PURE_ED25519,
+}
+
+// PluginKMS implements a KMS that forwards the operations to a KMS plugin
+// using gRPC. Plugins implement the pluginpb.KeyManagementServiceServer
+// interface.
+//
+// The plugin is configured with a URI with the following format:
+//
+//   - pluginkms:target=localhost:9000
+//   - pluginkms:target=localhost:9000;ca-cert=/path/to/ca.crt
+//   - pluginkms:target=localhost:9000;insecure=true
+//   - pluginkms:target=unix:///run/kms-plugin.sock
+//
+// The "target" is the gRPC target of the plugin, see
+// https://github.com/grpc/grpc/blob/master/doc/naming.md. Connections use TLS
+// with the system roots, or the roots in "ca-cert" if set, except for unix
+// sockets or if "insecure" is set to true.
+//
+// The names of the keys are sent unchanged to the plugin, it is the plugin
+// who defines their format.
+type PluginKMS struct {
+	conn   *grpc.ClientConn
+	client pluginpb.KeyManagementServiceClient
+	closed sync.Once
+	done   uint32
+}
+
+// New creates a new PluginKMS connected to the plugin in the given URI. The
+// connection is established in the background, so New does not fail if the
+// plugin is not available yet.
+func New(ctx context.Context, opts apiv1.Options) (*PluginKMS, error) {
+	if opts.URI == "" {
+		return nil, errors.New("pluginkms uri is required")
+	}
+	u, err := uri.ParseWithScheme(Scheme, opts.URI)
+	if err != nil {
+		return nil, err
+	}
+	target := u.Get("target")
+	if target == "" {
+		return nil, errors.Errorf("pluginkms uri %q is not valid: target is missing", opts.URI)
+	}
+
+	var creds credentials.TransportCredentials
+	switch {
+	case u.GetBool("insecure") || strings.HasPrefix(target, "unix:"):
+		creds = insecure.NewCredentials()
+	case u.Get("ca-cert") != "":
+		b, err := os.ReadFile(u.Get("ca-cert"))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading ca-cert")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("error reading ca-cert: no certificates found in %q", u.Get("ca-cert"))
+		}
+		creds = credentials.NewTLS(&tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		})
+	default:
+		creds = credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+		})
+	}
+
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error connecting to %q", target)
+	}
+
+	return &PluginKMS{
+		conn:   conn,
+		client: pluginpb.NewKeyManagementServiceClient(conn),
+	}, nil
+}
+
+func init() {
+	apiv1.Register(apiv1.PluginKMS, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return New(ctx, opts)
+	})
+}
+
+// NewPluginKMS creates a PluginKMS with a given client.
+func NewPluginKMS(client pluginpb.KeyManagementServiceClient) *PluginKMS {
+	return &PluginKMS{
+		client: client,
+	}
+}
+
+// Close closes the connection to the plugin. It is safe to call Close multiple
+// times, but only the first call will close the connection. After Close the
+// PluginKMS operations will return an error wrapping apiv1.ErrClosed.
+func (k *PluginKMS) Close() (err error) {
+	k.closed.Do(func() {
+		atomic.StoreUint32(&k.done, 1)
+		if k.conn != nil {
+			if e := k.conn.Close(); e != nil {
+				err = errors.Wrap(e, "pluginKMS Close failed")
+			}
+		}
+	})
+	return
+}
+
+func (k *PluginKMS) checkClosed() error {
+	if atomic.LoadUint32(&k.done) == 1 {
+		return errors.Wrap(apiv1.ErrClosed, "pluginKMS is closed")
+	}
+	return nil
+}
+
+// Capabilities returns the operations supported by the PluginKMS.
+func (k *PluginKMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:    true,
+		CreateSigner: true,
+	}
+}
+
+// GetPublicKey returns the public key of the given key name.
+func (k *PluginKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.GetPublicKeyContext(ctx, req)
+}
+
+// GetPublicKeyContext returns the public key of the given key name using the
+// given context.
+func (k *PluginKMS) GetPublicKeyContext(ctx context.Context, req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, errors.New("getPublicKeyRequest 'name' cannot be empty")
+	}
+	return getPublicKey(ctx, k.client, req.Name)
+}
+
+// CreateKey creates a new key in the plugin.
+func (k *PluginKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.CreateKeyContext(ctx, req)
+}
+
+// CreateKeyContext creates a new key in the plugin using the given context.
+func (k *PluginKMS) CreateKeyContext(ctx context.Context, req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	params, err := createKeyParameters(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := k.client.CreateKey(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "pluginKMS CreateKey failed")
+	}
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "pluginKMS CreateKey failed: error parsing public key")
+	}
+
+	name := resp.Name
+	if name == "" {
+		name = req.Name
+	}
+	signingKey := resp.SigningKey
+	if signingKey == "" {
+		signingKey = name
+	}
+
+	return &apiv1.CreateKeyResponse{
+		Name:      name,
+		PublicKey: pub,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: signingKey,
+		},
+	}, nil
+}
+
+// ValidateCreateKey validates the parameters of the given request without
+// creating the key.
+func (k *PluginKMS) ValidateCreateKey(req *apiv1.CreateKeyRequest) error {
+	_, err := createKeyParameters(req)
+	return err
+}
+
+// createKeyParameters returns the plugin request for the given one.
+func createKeyParameters(req *apiv1.CreateKeyRequest) (*pluginpb.CreateKeyRequest, error) {
+	if req.Name == "" {
+		return nil, errors.New("createKeyRequest 'name' cannot be empty")
+	}
+	protectionLevel, ok := protectionLevelMapping[req.ProtectionLevel]
+	if !ok {
+		return nil, errors.Errorf("pluginKMS does not support protection level '%s'", req.ProtectionLevel)
+	}
+	signatureAlgorithm, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
+	if !ok {
+		return nil, errors.Errorf("pluginKMS does not support signature algorithm '%s'", req.SignatureAlgorithm)
+	}
+	if req.PublicExponent != 0 && req.PublicExponent != 65537 {
+		return nil, errors.Errorf("pluginKMS does not support public exponent %d", req.PublicExponent)
+	}
+	return &pluginpb.CreateKeyRequest{
+		Name:               req.Name,
+		SignatureAlgorithm: signatureAlgorithm,
+		Bits:               int32(req.Bits),
+		ProtectionLevel:    protectionLevel,
+	}, nil
+}
+
+// CreateSigner returns a new signer configured with the given signing key.
+func (k *PluginKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return k.CreateSignerContext(ctx, req)
+}
+
+// CreateSignerContext returns a new signer configured with the given signing
+// key using the given context.
+func (k *PluginKMS) CreateSignerContext(ctx context.Context, req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	if req.SigningKey == "" {
+		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
+	}
+	return newSigner(ctx, k.client, req.SigningKey)
+}
+
+func getPublicKey(ctx context.Context, client pluginpb.KeyManagementServiceClient, name string) (crypto.PublicKey, error) {
+	resp, err := client.GetPublicKey(ctx, &pluginpb.GetPublicKeyRequest{
+		Name: name,
+	})
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "pluginKMS GetPublicKey failed")
+	}
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "pluginKMS GetPublicKey failed: error parsing public key")
+	}
+	return pub, nil
+}
+
+// convertError converts the gRPC errors returned by the plugin with the codes
+// NotFound, AlreadyExists, PermissionDenied, Unauthenticated, Unimplemented and
+// Unavailable to the apiv1 error types, so callers can use errors.As to check
+// them. Other errors are returned unchanged.
+func convertError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return apiv1.NotFoundError{Message: st.Message()}
+	case codes.AlreadyExists:
+		return apiv1.AlreadyExistsError{Message: st.Message()}
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return apiv1.PermissionDeniedError{Message: st.Message()}
+	case codes.Unimplemented:
+		return apiv1.NotImplementedError{Message: st.Message()}
+	case codes.Unavailable:
+		return apiv1.UnavailableError{Message: st.Message()}
+	default:
+		return err
+	}
+}
+
+func defaultContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 15*time.Second)
+}
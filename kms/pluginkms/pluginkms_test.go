@@ -0,0 +1,354 @@
+package pluginkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/pluginkms/pluginpb"
+	"go.step.sm/crypto/minica"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testServer is an in-memory KMS plugin.
+type testServer struct {
+	pluginpb.UnimplementedKeyManagementServiceServer
+	mu   sync.Mutex
+	keys map[string]crypto.Signer
+}
+
+func (s *testServer) GetPublicKey(ctx context.Context, req *pluginpb.GetPublicKeyRequest) (*pluginpb.GetPublicKeyResponse, error) {
+	s.mu.Lock()
+	key, ok := s.keys[req.Name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "key %q not found", req.Name)
+	}
+	b, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pluginpb.GetPublicKeyResponse{PublicKey: b}, nil
+}
+
+func (s *testServer) CreateKey(ctx context.Context, req *pluginpb.CreateKeyRequest) (*pluginpb.CreateKeyResponse, error) {
+	var kty, crv string
+	switch req.SignatureAlgorithm {
+	case pluginpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_UNSPECIFIED, pluginpb.SignatureAlgorithm_ECDSA_WITH_SHA256:
+		kty, crv = "EC", "P-256"
+	case pluginpb.SignatureAlgorithm_SHA256_WITH_RSA, pluginpb.SignatureAlgorithm_SHA256_WITH_RSA_PSS:
+		kty = "RSA"
+	case pluginpb.SignatureAlgorithm_PURE_ED25519:
+		kty, crv = "OKP", "Ed25519"
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "signature algorithm %s is not supported", req.SignatureAlgorithm)
+	}
+	bits := int(req.Bits)
+	if kty == "RSA" && bits == 0 {
+		bits = 2048
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[req.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "key %q already exists", req.Name)
+	}
+	key, err := keyutil.GenerateSigner(kty, crv, bits)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	b, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.keys[req.Name] = key
+	return &pluginpb.CreateKeyResponse{
+		Name:       req.Name,
+		PublicKey:  b,
+		SigningKey: req.Name,
+	}, nil
+}
+
+func (s *testServer) Sign(ctx context.Context, req *pluginpb.SignRequest) (*pluginpb.SignResponse, error) {
+	s.mu.Lock()
+	key, ok := s.keys[req.SigningKey]
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "key %q not found", req.SigningKey)
+	}
+
+	var opts crypto.SignerOpts
+	for h, v := range hashMapping {
+		if v == req.Hash {
+			opts = h
+		}
+	}
+	if req.Pss {
+		opts = &rsa.PSSOptions{SaltLength: int(req.SaltLength), Hash: opts.HashFunc()}
+	}
+	sig, err := key.Sign(rand.Reader, req.Digest, opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pluginpb.SignResponse{Signature: sig}, nil
+}
+
+// startServer starts a plugin in a random port and returns its address.
+func startServer(t *testing.T, srv pluginpb.KeyManagementServiceServer) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pluginpb.RegisterKeyManagementServiceServer(s, srv)
+	go s.Serve(l) //nolint:errcheck // errors are reported by the client
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func newTestServer() *testServer {
+	return &testServer{keys: make(map[string]crypto.Signer)}
+}
+
+func mustPluginKMS(t *testing.T, srv pluginpb.KeyManagementServiceServer) *PluginKMS {
+	t.Helper()
+	k, err := New(context.Background(), apiv1.Options{
+		Type: apiv1.PluginKMS,
+		URI:  "pluginkms:target=" + startServer(t, srv) + ";insecure=true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { k.Close() })
+	return k
+}
+
+func TestRegister(t *testing.T) {
+	fn, ok := apiv1.LoadKeyManagerNewFunc(apiv1.PluginKMS)
+	if !ok {
+		t.Fatal("pluginkms is not registered")
+	}
+	k, err := fn(context.Background(), apiv1.Options{
+		Type: apiv1.PluginKMS,
+		URI:  "pluginkms:target=127.0.0.1:9000;insecure=true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	caCert := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	noCerts := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(noCerts, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    apiv1.Options
+		wantErr bool
+	}{
+		{"ok", apiv1.Options{URI: "pluginkms:target=localhost:9000"}, false},
+		{"ok insecure", apiv1.Options{URI: "pluginkms:target=localhost:9000;insecure=true"}, false},
+		{"ok ca-cert", apiv1.Options{URI: "pluginkms:target=localhost:9000;ca-cert=" + caCert}, false},
+		{"ok unix", apiv1.Options{URI: "pluginkms:target=unix://" + filepath.Join(dir, "kms.sock")}, false},
+		{"fail uri", apiv1.Options{}, true},
+		{"fail scheme", apiv1.Options{URI: "softkms:target=localhost:9000"}, true},
+		{"fail target", apiv1.Options{URI: "pluginkms:insecure=true"}, true},
+		{"fail ca-cert missing", apiv1.Options{URI: "pluginkms:target=localhost:9000;ca-cert=" + filepath.Join(dir, "missing.crt")}, true},
+		{"fail ca-cert empty", apiv1.Options{URI: "pluginkms:target=localhost:9000;ca-cert=" + noCerts}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != nil {
+				if err := got.Close(); err != nil {
+					t.Errorf("PluginKMS.Close() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPluginKMS_CreateKey(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+
+	tests := []struct {
+		name        string
+		req         *apiv1.CreateKeyRequest
+		wantKeyType interface{}
+		wantErr     error
+	}{
+		{"ok default", &apiv1.CreateKeyRequest{Name: "default"}, &ecdsa.PublicKey{}, nil},
+		{"ok EC", &apiv1.CreateKeyRequest{Name: "ec", SignatureAlgorithm: apiv1.ECDSAWithSHA256}, &ecdsa.PublicKey{}, nil},
+		{"ok RSA", &apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048}, &rsa.PublicKey{}, nil},
+		{"ok Ed25519", &apiv1.CreateKeyRequest{Name: "ed25519", SignatureAlgorithm: apiv1.PureEd25519}, ed25519.PublicKey{}, nil},
+		{"fail exists", &apiv1.CreateKeyRequest{Name: "ec", SignatureAlgorithm: apiv1.ECDSAWithSHA256}, nil, apiv1.AlreadyExistsError{}},
+		{"fail plugin algorithm", &apiv1.CreateKeyRequest{Name: "p384", SignatureAlgorithm: apiv1.ECDSAWithSHA384}, nil, errors.New("")},
+		{"fail name", &apiv1.CreateKeyRequest{SignatureAlgorithm: apiv1.ECDSAWithSHA256}, nil, errors.New("")},
+		{"fail algorithm", &apiv1.CreateKeyRequest{Name: "secp256k1", SignatureAlgorithm: apiv1.ECDSAWithSHA256K}, nil, errors.New("")},
+		{"fail protection level", &apiv1.CreateKeyRequest{Name: "ec", ProtectionLevel: apiv1.ProtectionLevel(100)}, nil, errors.New("")},
+		{"fail public exponent", &apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSA, PublicExponent: 3}, nil, errors.New("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateKey(tt.req)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("PluginKMS.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				var aerr apiv1.AlreadyExistsError
+				if _, ok := tt.wantErr.(apiv1.AlreadyExistsError); ok && !errors.As(err, &aerr) {
+					t.Errorf("PluginKMS.CreateKey() error = %v, want apiv1.AlreadyExistsError", err)
+				}
+				return
+			}
+			if reflect.TypeOf(got.PublicKey) != reflect.TypeOf(tt.wantKeyType) {
+				t.Errorf("PluginKMS.CreateKey() PublicKey = %T, want %T", got.PublicKey, tt.wantKeyType)
+			}
+			if got.Name != tt.req.Name || got.CreateSignerRequest.SigningKey != tt.req.Name {
+				t.Errorf("PluginKMS.CreateKey() = %v, want name %q", got, tt.req.Name)
+			}
+
+			pub, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: tt.req.Name})
+			if err != nil {
+				t.Fatalf("PluginKMS.GetPublicKey() error = %v", err)
+			}
+			if !reflect.DeepEqual(pub, got.PublicKey) {
+				t.Errorf("PluginKMS.GetPublicKey() = %v, want %v", pub, got.PublicKey)
+			}
+		})
+	}
+}
+
+func TestPluginKMS_GetPublicKey(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+
+	_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "missing"})
+	var nerr apiv1.NotFoundError
+	if !errors.As(err, &nerr) {
+		t.Errorf("PluginKMS.GetPublicKey() error = %v, want apiv1.NotFoundError", err)
+	}
+	if _, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{}); err == nil {
+		t.Error("PluginKMS.GetPublicKey() error = nil, want error")
+	}
+}
+
+func TestPluginKMS_unimplemented(t *testing.T) {
+	k := mustPluginKMS(t, &pluginpb.UnimplementedKeyManagementServiceServer{})
+
+	_, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "key"})
+	var nerr apiv1.NotImplementedError
+	if !errors.As(err, &nerr) {
+		t.Errorf("PluginKMS.CreateKey() error = %v, want apiv1.NotImplementedError", err)
+	}
+}
+
+func TestPluginKMS_unavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	k, err := New(context.Background(), apiv1.Options{
+		URI: "pluginkms:target=" + addr + ";insecure=true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+
+	_, err = k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "key"})
+	var uerr apiv1.UnavailableError
+	if !errors.As(err, &uerr) {
+		t.Errorf("PluginKMS.GetPublicKey() error = %v, want apiv1.UnavailableError", err)
+	}
+}
+
+func TestPluginKMS_Close(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Errorf("PluginKMS.Close() error = %v, want nil", err)
+	}
+
+	if _, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "key"}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("PluginKMS.GetPublicKey() error = %v, want apiv1.ErrClosed", err)
+	}
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "key"}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("PluginKMS.CreateKey() error = %v, want apiv1.ErrClosed", err)
+	}
+	if _, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "key"}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("PluginKMS.CreateSigner() error = %v, want apiv1.ErrClosed", err)
+	}
+}
+
+func TestPluginKMS_Capabilities(t *testing.T) {
+	want := apiv1.Capabilities{CreateKey: true, CreateSigner: true}
+	if got := apiv1.GetCapabilities(&PluginKMS{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("PluginKMS.Capabilities() = %v, want %v", got, want)
+	}
+}
+
+func Test_convertError(t *testing.T) {
+	errTest := errors.New("test error")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"NotFound", status.Error(codes.NotFound, "not found"), apiv1.NotFoundError{Message: "not found"}},
+		{"AlreadyExists", status.Error(codes.AlreadyExists, "exists"), apiv1.AlreadyExistsError{Message: "exists"}},
+		{"PermissionDenied", status.Error(codes.PermissionDenied, "denied"), apiv1.PermissionDeniedError{Message: "denied"}},
+		{"Unauthenticated", status.Error(codes.Unauthenticated, "unauthenticated"), apiv1.PermissionDeniedError{Message: "unauthenticated"}},
+		{"Unimplemented", status.Error(codes.Unimplemented, "unimplemented"), apiv1.NotImplementedError{Message: "unimplemented"}},
+		{"Unavailable", status.Error(codes.Unavailable, "unavailable"), apiv1.UnavailableError{Message: "unavailable"}},
+		{"Internal", status.Error(codes.Internal, "internal"), status.Error(codes.Internal, "internal")},
+		{"other", errTest, errTest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertError(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// Package pluginpb contains the protocol buffers and the gRPC service used by
+// pluginkms to communicate with KMS plugins. Plugins implement the
+// KeyManagementServiceServer interface.
+package pluginpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pluginkms.proto
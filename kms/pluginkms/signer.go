@@ -0,0 +1,100 @@
+//go:build !nopluginkms
+// +build !nopluginkms
+
+package pluginkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/pluginkms/pluginpb"
+)
+
+// hashMapping maps the hash functions with the plugin ones.
+var hashMapping = map[crypto.Hash]pluginpb.Hash{
+	crypto.Hash(0): pluginpb.Hash_HASH_UNSPECIFIED,
+	crypto.SHA1:    pluginpb.Hash_SHA1,
+	crypto.SHA224:  pluginpb.Hash_SHA224,
+	crypto.SHA256:  pluginpb.Hash_SHA256,
+	crypto.SHA384:  pluginpb.Hash_SHA384,
+	crypto.SHA512:  pluginpb.Hash_SHA512,
+}
+
+// Signer implements a crypto.Signer using a KMS plugin.
+type Signer struct {
+	client     pluginpb.KeyManagementServiceClient
+	signingKey string
+	publicKey  crypto.PublicKey
+}
+
+// NewSigner creates a new crypto.Signer with the given plugin signing key.
+func NewSigner(client pluginpb.KeyManagementServiceClient, signingKey string) (*Signer, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return newSigner(ctx, client, signingKey)
+}
+
+func newSigner(ctx context.Context, client pluginpb.KeyManagementServiceClient, signingKey string) (*Signer, error) {
+	// Make sure that the key exists.
+	pub, err := getPublicKey(ctx, client, signingKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		client:     client,
+		signingKey: signingKey,
+		publicKey:  pub,
+	}, nil
+}
+
+// Public returns the public key of this signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// JOSEAlgorithm returns the default JOSE signature algorithm for the key of
+// this signer.
+func (s *Signer) JOSEAlgorithm() string {
+	return apiv1.DefaultJOSEAlgorithm(s.publicKey)
+}
+
+// Sign signs digest with the private key in the plugin. RSA keys use
+// RSASSA-PSS if opts is an *rsa.PSSOptions, and Ed25519 keys sign the full
+// message, so opts.HashFunc() must be crypto.Hash(0).
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	return s.SignContext(ctx, rand, digest, opts)
+}
+
+// SignContext signs digest with the private key in the plugin using the given
+// context.
+func (s *Signer) SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc()
+	hash, ok := hashMapping[h]
+	if !ok {
+		return nil, errors.Errorf("unsupported hash function %v", h)
+	}
+
+	req := &pluginpb.SignRequest{
+		SigningKey: s.signingKey,
+		Digest:     digest,
+		Hash:       hash,
+	}
+	if o, ok := opts.(*rsa.PSSOptions); ok {
+		req.Pss = true
+		req.SaltLength = int32(o.SaltLength)
+	}
+
+	resp, err := s.client.Sign(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(convertError(err), "pluginKMS Sign failed")
+	}
+	return resp.Signature, nil
+}
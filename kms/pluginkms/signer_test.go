@@ -0,0 +1,145 @@
+package pluginkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+
+	createSigner := func(name string, alg apiv1.SignatureAlgorithm) crypto.Signer {
+		t.Helper()
+		resp, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name, SignatureAlgorithm: alg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := k.CreateSigner(&resp.CreateSignerRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signer
+	}
+
+	ecSigner := createSigner("ec", apiv1.ECDSAWithSHA256)
+	rsaSigner := createSigner("rsa", apiv1.SHA256WithRSA)
+	edSigner := createSigner("ed25519", apiv1.PureEd25519)
+
+	message := []byte("the-message")
+	sum256 := sha256.Sum256(message)
+	sum384 := sha512.Sum384(message)
+
+	verify := func(pub crypto.PublicKey, digest, sig []byte, opts crypto.SignerOpts) error {
+		switch pub := pub.(type) {
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(pub, digest, sig) {
+				return errors.New("invalid ECDSA signature")
+			}
+			return nil
+		case *rsa.PublicKey:
+			if o, ok := opts.(*rsa.PSSOptions); ok {
+				return rsa.VerifyPSS(pub, o.Hash, digest, sig, o)
+			}
+			return rsa.VerifyPKCS1v15(pub, opts.HashFunc(), digest, sig)
+		case ed25519.PublicKey:
+			if !ed25519.Verify(pub, digest, sig) {
+				return errors.New("invalid Ed25519 signature")
+			}
+			return nil
+		default:
+			return errors.New("unsupported key")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		signer  crypto.Signer
+		digest  []byte
+		opts    crypto.SignerOpts
+		wantErr bool
+	}{
+		{"ok EC", ecSigner, sum256[:], crypto.SHA256, false},
+		{"ok RSA", rsaSigner, sum256[:], crypto.SHA256, false},
+		{"ok RSA SHA384", rsaSigner, sum384[:], crypto.SHA384, false},
+		{"ok RSA-PSS", rsaSigner, sum256[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}, false},
+		{"ok RSA-PSS auto", rsaSigner, sum256[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthAuto}, false},
+		{"ok Ed25519", edSigner, message, crypto.Hash(0), false},
+		{"fail hash", ecSigner, sum256[:], crypto.MD5, true},
+		{"fail plugin", edSigner, sum256[:], crypto.SHA256, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.signer.Sign(rand.Reader, tt.digest, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Signer.Sign() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if err := verify(tt.signer.Public(), tt.digest, got, tt.opts); err != nil {
+					t.Errorf("Signer.Sign() signature verification failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSigner_SignContext(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+	resp, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "ec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(k.client, resp.CreateSignerRequest.SigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sum := sha256.Sum256([]byte("the-message"))
+	if _, err := signer.SignContext(ctx, rand.Reader, sum[:], crypto.SHA256); err == nil {
+		t.Error("Signer.SignContext() error = nil, want error")
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	k := mustPluginKMS(t, newTestServer())
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "ec"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		signingKey string
+		wantErr    bool
+	}{
+		{"ok", "ec", false},
+		{"fail not found", "missing", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSigner(k.client, tt.signingKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != nil && got.JOSEAlgorithm() != "ES256" {
+				t.Errorf("Signer.JOSEAlgorithm() = %v, want ES256", got.JOSEAlgorithm())
+			}
+		})
+	}
+
+	if _, err := k.CreateSigner(&apiv1.CreateSignerRequest{}); err == nil {
+		t.Error("PluginKMS.CreateSigner() error = nil, want error")
+	}
+}
@@ -5,13 +5,18 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"fmt"
+	"io/fs"
+	"os"
 
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/randutil"
 )
 
 type algorithmAttributes struct {
@@ -34,6 +39,20 @@ var signatureAlgorithmMapping = map[apiv1.SignatureAlgorithm]algorithmAttributes
 	apiv1.ECDSAWithSHA384:          {"EC", "P-384"},
 	apiv1.ECDSAWithSHA512:          {"EC", "P-521"},
 	apiv1.PureEd25519:              {"OKP", "Ed25519"},
+	apiv1.ECDSAWithSHA256K:         {"EC", "secp256k1"},
+}
+
+var hashMapping = map[apiv1.SignatureAlgorithm]crypto.Hash{
+	apiv1.SHA256WithRSA:    crypto.SHA256,
+	apiv1.SHA384WithRSA:    crypto.SHA384,
+	apiv1.SHA512WithRSA:    crypto.SHA512,
+	apiv1.SHA256WithRSAPSS: crypto.SHA256,
+	apiv1.SHA384WithRSAPSS: crypto.SHA384,
+	apiv1.SHA512WithRSAPSS: crypto.SHA512,
+	apiv1.ECDSAWithSHA256:  crypto.SHA256,
+	apiv1.ECDSAWithSHA384:  crypto.SHA384,
+	apiv1.ECDSAWithSHA512:  crypto.SHA512,
+	apiv1.ECDSAWithSHA256K: crypto.SHA256,
 }
 
 // generateKey is used for testing purposes.
@@ -44,6 +63,18 @@ var generateKey = func(kty, crv string, size int) (interface{}, interface{}, err
 	return keyutil.GenerateKeyPair(kty, crv, size)
 }
 
+// generateRSAKeyWithExponent is used for testing purposes.
+var generateRSAKeyWithExponent = func(size, exponent int) (interface{}, interface{}, error) {
+	if size == 0 {
+		size = DefaultRSAKeySize
+	}
+	signer, err := keyutil.GenerateRSAKeyWithExponent(size, exponent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer.Public(), signer, nil
+}
+
 // SoftKMS is a key manager that uses keys stored in disk.
 type SoftKMS struct{}
 
@@ -63,6 +94,40 @@ func (k *SoftKMS) Close() error {
 	return nil
 }
 
+// Ping checks that the working directory, used to resolve the relative paths of
+// the keys, can be read. It returns an apiv1.PermissionDeniedError if the
+// access to the directory is denied, and an apiv1.UnavailableError if the
+// directory cannot be read for any other reason.
+func (k *SoftKMS) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return apiv1.UnavailableError{Message: "softKMS Ping failed: " + err.Error()}
+	}
+
+	dir, err := os.Getwd()
+	if err == nil {
+		_, err = os.ReadDir(dir)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		return apiv1.PermissionDeniedError{Message: "softKMS Ping failed: " + err.Error()}
+	default:
+		return apiv1.UnavailableError{Message: "softKMS Ping failed: " + err.Error()}
+	}
+}
+
+// Capabilities returns the operations supported by the SoftKMS.
+func (k *SoftKMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:       true,
+		CreateSigner:    true,
+		CreateDecrypter: true,
+		Verify:          true,
+		WrapKey:         true,
+	}
+}
+
 // CreateSigner returns a new signer configured with the given signing key.
 func (k *SoftKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
 	var opts []pemutil.Options
@@ -99,14 +164,24 @@ func (k *SoftKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, e
 }
 
 // CreateKey generates a new key using Golang crypto and returns both public and
-// private key.
+// private key. RSA keys can be created with a custom public exponent using the
+// PublicExponent attribute in the request.
 func (k *SoftKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
 	v, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
 	if !ok {
 		return nil, errors.Errorf("softKMS does not support signature algorithm '%s'", req.SignatureAlgorithm)
 	}
 
-	pub, priv, err := generateKey(v.Type, v.Curve, req.Bits)
+	var pub, priv interface{}
+	var err error
+	if req.PublicExponent != 0 {
+		if v.Type != "RSA" {
+			return nil, errors.Errorf("softKMS does not support public exponent on signature algorithm '%s'", req.SignatureAlgorithm)
+		}
+		pub, priv, err = generateRSAKeyWithExponent(req.Bits, req.PublicExponent)
+	} else {
+		pub, priv, err = generateKey(v.Type, v.Curve, req.Bits)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -176,3 +251,203 @@ func (k *SoftKMS) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Dec
 		return nil, errors.New("failed to load softKMS: please define decryptionKeyPEM or decryptionKey")
 	}
 }
+
+// Verify verifies the signature of the message using the public key in the
+// file passed in the name. It returns false if the signature is not valid.
+func (k *SoftKMS) Verify(name string, message, signature []byte, alg apiv1.SignatureAlgorithm) (bool, error) {
+	pub, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{
+		Name: name,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if alg == apiv1.PureEd25519 {
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return false, errors.Errorf("softKMS signature algorithm '%s' cannot be used with key type %T", alg, pub)
+		}
+		return ed25519.Verify(key, message, signature), nil
+	}
+
+	h, ok := hashMapping[alg]
+	if !ok {
+		return false, errors.Errorf("softKMS does not support signature algorithm '%s'", alg)
+	}
+	hash := h.New()
+	hash.Write(message)
+	digest := hash.Sum(nil)
+
+	switch alg {
+	case apiv1.SHA256WithRSA, apiv1.SHA384WithRSA, apiv1.SHA512WithRSA:
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return false, errors.Errorf("softKMS signature algorithm '%s' cannot be used with key type %T", alg, pub)
+		}
+		return rsa.VerifyPKCS1v15(key, h, digest, signature) == nil, nil
+	case apiv1.SHA256WithRSAPSS, apiv1.SHA384WithRSAPSS, apiv1.SHA512WithRSAPSS:
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return false, errors.Errorf("softKMS signature algorithm '%s' cannot be used with key type %T", alg, pub)
+		}
+		return rsa.VerifyPSS(key, h, digest, signature, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthAuto,
+			Hash:       h,
+		}) == nil, nil
+	default:
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return false, errors.Errorf("softKMS signature algorithm '%s' cannot be used with key type %T", alg, pub)
+		}
+		return ecdsa.VerifyASN1(key, digest, signature), nil
+	}
+}
+
+// wrappingAttributes are the hash used in RSA-OAEP and whether the key is
+// wrapped with an ephemeral AES key for each of the supported wrapping
+// algorithms.
+type wrappingAttributes struct {
+	Hash       crypto.Hash
+	AESKeyWrap bool
+}
+
+var wrappingAlgorithmMapping = map[apiv1.EncryptionAlgorithm]wrappingAttributes{
+	apiv1.UnspecifiedEncryptionAlgorithm: {crypto.SHA256, false},
+	apiv1.RSAOAEPWithSHA1:                {crypto.SHA1, false},
+	apiv1.RSAOAEPWithSHA256:              {crypto.SHA256, false},
+	apiv1.RSAAESKeyWrapWithSHA1:          {crypto.SHA1, true},
+	apiv1.RSAAESKeyWrapWithSHA256:        {crypto.SHA256, true},
+	apiv1.RSAAESKeyWrapWithSHA384:        {crypto.SHA384, true},
+}
+
+// aesKeyWrapKeySize is the size of the ephemeral AES keys used by the
+// RSAAESKeyWrap algorithms.
+const aesKeyWrapKeySize = 32
+
+// WrapKey encrypts the PKCS #8 encoding of the private key in the file passed
+// in the request name with the given RSA wrapping key. The default wrapping
+// algorithm is RSAOAEPWithSHA256.
+//
+// The RSAOAEP algorithms encrypt the key directly using RSA-OAEP, they can
+// only encrypt messages shorter than the size of the wrapping key minus twice
+// the hash size, so RSA keys cannot be wrapped using them. The RSAAESKeyWrap
+// algorithms implement CKM_RSA_AES_KEY_WRAP, the key is wrapped with an
+// ephemeral 256-bit AES key using AES Key Wrap with Padding (RFC 5649), and
+// the AES key is encrypted using RSA-OAEP and prepended to the wrapped key.
+func (k *SoftKMS) WrapKey(req *apiv1.WrapKeyRequest) (*apiv1.WrapKeyResponse, error) {
+	w, ok := wrappingAlgorithmMapping[req.WrappingAlgorithm]
+	if !ok {
+		return nil, apiv1.UnsupportedAlgorithmError{
+			Message: fmt.Sprintf("softKMS does not support wrapping algorithm '%s'", req.WrappingAlgorithm),
+		}
+	}
+	wrappingKey, ok := req.WrappingKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("softKMS wrapping key %T is not supported", req.WrappingKey)
+	}
+
+	var opts []pemutil.Options
+	if req.Password != nil {
+		opts = append(opts, pemutil.WithPassword(req.Password))
+	}
+	v, err := pemutil.Read(req.Name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling private key")
+	}
+
+	wrappedKey, err := wrapKey(w, wrappingKey, der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error wrapping key")
+	}
+
+	alg := req.WrappingAlgorithm
+	if alg == apiv1.UnspecifiedEncryptionAlgorithm {
+		alg = apiv1.RSAOAEPWithSHA256
+	}
+	return &apiv1.WrapKeyResponse{
+		WrappedKey:        wrappedKey,
+		WrappingAlgorithm: alg,
+	}, nil
+}
+
+func wrapKey(w wrappingAttributes, wrappingKey *rsa.PublicKey, der []byte) ([]byte, error) {
+	if !w.AESKeyWrap {
+		return rsa.EncryptOAEP(w.Hash.New(), rand.Reader, wrappingKey, der, nil)
+	}
+
+	kek, err := randutil.Bytes(aesKeyWrapKeySize)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKEK, err := rsa.EncryptOAEP(w.Hash.New(), rand.Reader, wrappingKey, kek, nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := keyutil.WrapAESWithPadding(kek, der)
+	if err != nil {
+		return nil, err
+	}
+	return append(encryptedKEK, wrapped...), nil
+}
+
+// UnwrapKey decrypts a key wrapped by WrapKey using the RSA private key in the
+// UnwrappingKey file or in UnwrappingKeyPEM.
+func (k *SoftKMS) UnwrapKey(req *apiv1.UnwrapKeyRequest) (*apiv1.UnwrapKeyResponse, error) {
+	w, ok := wrappingAlgorithmMapping[req.WrappingAlgorithm]
+	if !ok {
+		return nil, apiv1.UnsupportedAlgorithmError{
+			Message: fmt.Sprintf("softKMS does not support wrapping algorithm '%s'", req.WrappingAlgorithm),
+		}
+	}
+
+	decrypter, err := k.CreateDecrypter(&apiv1.CreateDecrypterRequest{
+		DecryptionKey:    req.UnwrappingKey,
+		DecryptionKeyPEM: req.UnwrappingKeyPEM,
+		Password:         req.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := decrypter.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("softKMS unwrapping key %T is not supported", decrypter.Public())
+	}
+
+	der, err := unwrapKey(w, decrypter, pub.Size(), req.WrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unwrapping key")
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing unwrapped key")
+	}
+	publicKey, err := keyutil.PublicKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiv1.UnwrapKeyResponse{
+		PublicKey:  publicKey,
+		PrivateKey: priv,
+	}, nil
+}
+
+func unwrapKey(w wrappingAttributes, decrypter crypto.Decrypter, size int, wrappedKey []byte) ([]byte, error) {
+	opts := &rsa.OAEPOptions{Hash: w.Hash}
+	if !w.AESKeyWrap {
+		return decrypter.Decrypt(rand.Reader, wrappedKey, opts)
+	}
+
+	if len(wrappedKey) <= size {
+		return nil, errors.New("wrapped key is too short")
+	}
+	kek, err := decrypter.Decrypt(rand.Reader, wrappedKey[:size], opts)
+	if err != nil {
+		return nil, err
+	}
+	return keyutil.UnwrapAESWithPadding(kek, wrappedKey[size:])
+}
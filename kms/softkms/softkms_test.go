@@ -8,13 +8,18 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/pemutil"
 )
@@ -63,6 +68,73 @@ func TestSoftKMS_Close(t *testing.T) {
 	}
 }
 
+func TestSoftKMS_Capabilities(t *testing.T) {
+	want := apiv1.Capabilities{
+		CreateKey:       true,
+		CreateSigner:    true,
+		CreateDecrypter: true,
+		Verify:          true,
+		WrapKey:         true,
+	}
+	k := &SoftKMS{}
+	if got := apiv1.GetCapabilities(k); !reflect.DeepEqual(got, want) {
+		t.Errorf("SoftKMS.Capabilities() = %v, want %v", got, want)
+	}
+}
+
+func TestSoftKMS_Ping(t *testing.T) {
+	var _ apiv1.Pinger = (*SoftKMS)(nil)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	tests := []struct {
+		name            string
+		ctx             context.Context
+		setup           func(t *testing.T)
+		wantErr         bool
+		wantUnavailable bool
+	}{
+		{"ok", context.Background(), func(t *testing.T) {}, false, false},
+		{"fail canceled", canceled, func(t *testing.T) {}, true, true},
+		{"fail removed directory", context.Background(), func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "removed")
+			if err := os.Mkdir(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(dir); err != nil {
+				t.Fatal(err)
+			}
+		}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+			k := &SoftKMS{}
+			err := k.Ping(tt.ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftKMS.Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(apiv1.UnavailableError); ok != tt.wantUnavailable {
+				t.Errorf("SoftKMS.Ping() error = %v, wantUnavailable %v", err, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
 func TestSoftKMS_CreateSigner(t *testing.T) {
 	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -192,6 +264,9 @@ func TestSoftKMS_CreateKey(t *testing.T) {
 		{"ed25519", args{&apiv1.CreateKeyRequest{Name: "ed25519", SignatureAlgorithm: apiv1.PureEd25519}}, func() (interface{}, interface{}, error) {
 			return edpub, edpriv, nil
 		}, &apiv1.CreateKeyResponse{Name: "ed25519", PublicKey: edpub, PrivateKey: edpriv, CreateSignerRequest: apiv1.CreateSignerRequest{Signer: edpriv}}, params{"OKP", "Ed25519", 0}, false},
+		{"secp256k1", args{&apiv1.CreateKeyRequest{Name: "secp256k1", SignatureAlgorithm: apiv1.ECDSAWithSHA256K}}, func() (interface{}, interface{}, error) {
+			return p256.Public(), p256, nil //nolint:gocritic // ignore eval order warning
+		}, &apiv1.CreateKeyResponse{Name: "secp256k1", PublicKey: p256.Public(), PrivateKey: p256, CreateSignerRequest: apiv1.CreateSignerRequest{Signer: p256}}, params{"EC", "secp256k1", 0}, false},
 		{"default", args{&apiv1.CreateKeyRequest{Name: "default"}}, func() (interface{}, interface{}, error) {
 			return p256.Public(), p256, nil //nolint:gocritic // ignore eval order warning
 		}, &apiv1.CreateKeyResponse{Name: "default", PublicKey: p256.Public(), PrivateKey: p256, CreateSignerRequest: apiv1.CreateSignerRequest{Signer: p256}}, params{"EC", "P-256", 0}, false},
@@ -233,6 +308,50 @@ func TestSoftKMS_CreateKey(t *testing.T) {
 	}
 }
 
+func TestSoftKMS_CreateKey_secp256k1(t *testing.T) {
+	k := &SoftKMS{}
+	resp, err := k.CreateKey(&apiv1.CreateKeyRequest{
+		Name:               "secp256k1",
+		SignatureAlgorithm: apiv1.ECDSAWithSHA256K,
+	})
+	if err != nil {
+		t.Fatalf("SoftKMS.CreateKey() error = %v", err)
+	}
+	pub, ok := resp.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != secp256k1.S256() {
+		t.Fatalf("SoftKMS.CreateKey() PublicKey = %v, want secp256k1 key", resp.PublicKey)
+	}
+	if alg := apiv1.DefaultJOSEAlgorithm(pub); alg != "ES256K" {
+		t.Errorf("apiv1.DefaultJOSEAlgorithm() = %s, want ES256K", alg)
+	}
+
+	signer, err := k.CreateSigner(&resp.CreateSignerRequest)
+	if err != nil {
+		t.Fatalf("SoftKMS.CreateSigner() error = %v", err)
+	}
+	digest := sha256.Sum256([]byte("the message to sign"))
+	der, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Signer.Sign() error = %v", err)
+	}
+	if !ecdsa.VerifyASN1(pub, digest[:], der) {
+		t.Error("ecdsa.VerifyASN1() = false, want true")
+	}
+
+	// ES256K signatures use the concat(R,S) format.
+	raw, err := keyutil.ECDSASigToRS(der, pub.Curve)
+	if err != nil {
+		t.Fatalf("keyutil.ECDSASigToRS() error = %v", err)
+	}
+	if len(raw) != 64 {
+		t.Fatalf("keyutil.ECDSASigToRS() len = %d, want 64", len(raw))
+	}
+	r, s := new(big.Int).SetBytes(raw[:32]), new(big.Int).SetBytes(raw[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Error("ecdsa.Verify() = false, want true")
+	}
+}
+
 func TestSoftKMS_GetPublicKey(t *testing.T) {
 	b, err := os.ReadFile("testdata/pub.pem")
 	if err != nil {
@@ -273,6 +392,43 @@ func TestSoftKMS_GetPublicKey(t *testing.T) {
 	}
 }
 
+func TestSoftKMS_CreateKey_publicExponent(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateKeyRequest
+		wantE   int
+		wantErr bool
+	}{
+		{"ok 3", &apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048, PublicExponent: 3}, 3, false},
+		{"ok 65537", &apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSAPSS, Bits: 2048, PublicExponent: 65537}, 65537, false},
+		{"fail even", &apiv1.CreateKeyRequest{Name: "rsa", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048, PublicExponent: 4}, 0, true},
+		{"fail ecdsa", &apiv1.CreateKeyRequest{Name: "ec", SignatureAlgorithm: apiv1.ECDSAWithSHA256, PublicExponent: 3}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			got, err := k.CreateKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftKMS.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			pub, ok := got.PublicKey.(*rsa.PublicKey)
+			if !ok {
+				t.Fatalf("SoftKMS.CreateKey() PublicKey = %T, want *rsa.PublicKey", got.PublicKey)
+			}
+			if pub.E != tt.wantE {
+				t.Errorf("SoftKMS.CreateKey() PublicKey.E = %d, want %d", pub.E, tt.wantE)
+			}
+			if pub.N.BitLen() != tt.req.Bits {
+				t.Errorf("SoftKMS.CreateKey() PublicKey.N.BitLen() = %d, want %d", pub.N.BitLen(), tt.req.Bits)
+			}
+		})
+	}
+}
+
 func Test_generateKey(t *testing.T) {
 	type args struct {
 		kty  string
@@ -379,3 +535,310 @@ func TestSoftKMS_CreateDecrypter(t *testing.T) {
 		})
 	}
 }
+
+func TestSoftKMS_Verify(t *testing.T) {
+	dir := t.TempDir()
+	message := []byte("the message to sign")
+	writeKey := func(t *testing.T, name string, pub crypto.PublicKey) string {
+		t.Helper()
+		block, err := pemutil.Serialize(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	sign := func(t *testing.T, signer crypto.Signer, h crypto.Hash, opts crypto.SignerOpts) []byte {
+		t.Helper()
+		digest := message
+		if h != 0 {
+			hash := h.New()
+			hash.Write(message)
+			digest = hash.Sum(nil)
+		}
+		sig, err := signer.Sign(rand.Reader, digest, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256File := writeKey(t, "p256.pub", p256.Public())
+	p384File := writeKey(t, "p384.pub", p384.Public())
+	rsaFile := writeKey(t, "rsa.pub", rsaKey.Public())
+	edFile := writeKey(t, "ed25519.pub", edKey.Public())
+
+	p256Sig := sign(t, p256, crypto.SHA256, crypto.SHA256)
+	p384Sig := sign(t, p384, crypto.SHA384, crypto.SHA384)
+	rsaSig := sign(t, rsaKey, crypto.SHA256, crypto.SHA256)
+	rsaPSSSig := sign(t, rsaKey, crypto.SHA512, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+		Hash:       crypto.SHA512,
+	})
+	edSig := sign(t, edKey, 0, crypto.Hash(0))
+
+	type args struct {
+		name      string
+		message   []byte
+		signature []byte
+		alg       apiv1.SignatureAlgorithm
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bool
+		wantErr bool
+	}{
+		{"ok P-256", args{p256File, message, p256Sig, apiv1.ECDSAWithSHA256}, true, false},
+		{"ok P-384", args{p384File, message, p384Sig, apiv1.ECDSAWithSHA384}, true, false},
+		{"ok RSA", args{rsaFile, message, rsaSig, apiv1.SHA256WithRSA}, true, false},
+		{"ok RSA-PSS", args{rsaFile, message, rsaPSSSig, apiv1.SHA512WithRSAPSS}, true, false},
+		{"ok Ed25519", args{edFile, message, edSig, apiv1.PureEd25519}, true, false},
+		{"invalid cert", args{"testdata/cert.crt", message, p256Sig, apiv1.ECDSAWithSHA256}, false, false},
+		{"invalid P-256", args{p256File, []byte("other message"), p256Sig, apiv1.ECDSAWithSHA256}, false, false},
+		{"invalid P-384 hash", args{p384File, message, p384Sig, apiv1.ECDSAWithSHA256}, false, false},
+		{"invalid RSA", args{rsaFile, message, rsaPSSSig, apiv1.SHA256WithRSA}, false, false},
+		{"invalid RSA-PSS", args{rsaFile, message, rsaSig, apiv1.SHA256WithRSAPSS}, false, false},
+		{"invalid Ed25519", args{edFile, []byte("other message"), edSig, apiv1.PureEd25519}, false, false},
+		{"fail missing", args{"testdata/missing", message, p256Sig, apiv1.ECDSAWithSHA256}, false, true},
+		{"fail algorithm", args{p256File, message, p256Sig, apiv1.UnspecifiedSignAlgorithm}, false, true},
+		{"fail ECDSA key type", args{rsaFile, message, p256Sig, apiv1.ECDSAWithSHA256}, false, true},
+		{"fail RSA key type", args{p256File, message, rsaSig, apiv1.SHA256WithRSA}, false, true},
+		{"fail RSA-PSS key type", args{edFile, message, rsaPSSSig, apiv1.SHA512WithRSAPSS}, false, true},
+		{"fail Ed25519 key type", args{p256File, message, edSig, apiv1.PureEd25519}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			got, err := k.Verify(tt.args.name, tt.args.message, tt.args.signature, tt.args.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftKMS.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("SoftKMS.Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoftKMS_WrapKey_UnwrapKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(t *testing.T, name string, key crypto.PrivateKey) string {
+		t.Helper()
+		block, err := pemutil.Serialize(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+
+	kek, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kekName := writeKey(t, "kek.pem", kek)
+	kekBlock, err := pemutil.Serialize(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passKey, err := pemutil.Read("testdata/priv.pem", pemutil.WithPassword([]byte("pass")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := pemutil.Read("testdata/rsa.priv.pem", pemutil.WithPassword([]byte("pass")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		wrapReq  *apiv1.WrapKeyRequest
+		unwrap   *apiv1.UnwrapKeyRequest
+		want     crypto.PrivateKey
+		wantAlgo apiv1.EncryptionAlgorithm
+	}{
+		{"ok P-256", &apiv1.WrapKeyRequest{
+			Name: writeKey(t, "p256.pem", p256), WrappingKey: kek.Public(),
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKey: kekName}, p256, apiv1.RSAOAEPWithSHA256},
+		{"ok P-521", &apiv1.WrapKeyRequest{
+			Name: writeKey(t, "p521.pem", p521), WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.RSAOAEPWithSHA256,
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKey: kekName}, p521, apiv1.RSAOAEPWithSHA256},
+		{"ok Ed25519 SHA1", &apiv1.WrapKeyRequest{
+			Name: writeKey(t, "ed25519.pem", edKey), WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.RSAOAEPWithSHA1,
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKeyPEM: pem.EncodeToMemory(kekBlock)}, edKey, apiv1.RSAOAEPWithSHA1},
+		{"ok password", &apiv1.WrapKeyRequest{
+			Name: "testdata/priv.pem", Password: []byte("pass"), WrappingKey: kek.Public(),
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKey: kekName}, passKey, apiv1.RSAOAEPWithSHA256},
+		{"ok RSA AES key wrap", &apiv1.WrapKeyRequest{
+			Name: "testdata/rsa.priv.pem", Password: []byte("pass"), WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA256,
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKey: kekName}, rsaKey, apiv1.RSAAESKeyWrapWithSHA256},
+		{"ok RSA AES key wrap SHA1", &apiv1.WrapKeyRequest{
+			Name: "testdata/rsa.priv.pem", Password: []byte("pass"), WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA1,
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKey: kekName}, rsaKey, apiv1.RSAAESKeyWrapWithSHA1},
+		{"ok P-256 AES key wrap SHA384", &apiv1.WrapKeyRequest{
+			Name: writeKey(t, "p256-aes.pem", p256), WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA384,
+		}, &apiv1.UnwrapKeyRequest{UnwrappingKeyPEM: pem.EncodeToMemory(kekBlock)}, p256, apiv1.RSAAESKeyWrapWithSHA384},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			wrapped, err := k.WrapKey(tt.wrapReq)
+			if err != nil {
+				t.Fatalf("SoftKMS.WrapKey() error = %v", err)
+			}
+			if wrapped.WrappingAlgorithm != tt.wantAlgo {
+				t.Errorf("SoftKMS.WrapKey() WrappingAlgorithm = %v, want %v", wrapped.WrappingAlgorithm, tt.wantAlgo)
+			}
+
+			tt.unwrap.WrappedKey = wrapped.WrappedKey
+			tt.unwrap.WrappingAlgorithm = wrapped.WrappingAlgorithm
+			got, err := k.UnwrapKey(tt.unwrap)
+			if err != nil {
+				t.Fatalf("SoftKMS.UnwrapKey() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.PrivateKey, tt.want) {
+				t.Errorf("SoftKMS.UnwrapKey() PrivateKey = %v, want %v", got.PrivateKey, tt.want)
+			}
+			if !reflect.DeepEqual(got.PublicKey, tt.want.(crypto.Signer).Public()) {
+				t.Errorf("SoftKMS.UnwrapKey() PublicKey = %v, want %v", got.PublicKey, tt.want.(crypto.Signer).Public())
+			}
+
+			// Unwrapping with the wrong hash must fail.
+			switch wrapped.WrappingAlgorithm {
+			case apiv1.RSAOAEPWithSHA1:
+				tt.unwrap.WrappingAlgorithm = apiv1.RSAOAEPWithSHA256
+			case apiv1.RSAAESKeyWrapWithSHA256:
+				tt.unwrap.WrappingAlgorithm = apiv1.RSAAESKeyWrapWithSHA384
+			case apiv1.RSAAESKeyWrapWithSHA1, apiv1.RSAAESKeyWrapWithSHA384:
+				tt.unwrap.WrappingAlgorithm = apiv1.RSAAESKeyWrapWithSHA256
+			default:
+				tt.unwrap.WrappingAlgorithm = apiv1.RSAOAEPWithSHA1
+			}
+			if _, err := k.UnwrapKey(tt.unwrap); err == nil {
+				t.Error("SoftKMS.UnwrapKey() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestSoftKMS_WrapKey_fail(t *testing.T) {
+	kek, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := pemutil.Serialize(p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Name := filepath.Join(t.TempDir(), "p256.pem")
+	if err := os.WriteFile(p256Name, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *apiv1.WrapKeyRequest
+	}{
+		{"fail algorithm", &apiv1.WrapKeyRequest{Name: p256Name, WrappingKey: kek.Public(), WrappingAlgorithm: apiv1.EncryptionAlgorithm(100)}},
+		{"fail wrapping key", &apiv1.WrapKeyRequest{Name: p256Name, WrappingKey: p256.Public()}},
+		{"fail missing", &apiv1.WrapKeyRequest{Name: "testdata/missing", WrappingKey: kek.Public()}},
+		{"fail password", &apiv1.WrapKeyRequest{Name: "testdata/priv.pem", Password: []byte("bad-pass"), WrappingKey: kek.Public()}},
+		{"fail public key", &apiv1.WrapKeyRequest{Name: "testdata/pub.pem", WrappingKey: kek.Public()}},
+		{"fail too long", &apiv1.WrapKeyRequest{Name: "testdata/rsa.priv.pem", Password: []byte("pass"), WrappingKey: kek.Public()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			if got, err := k.WrapKey(tt.req); err == nil {
+				t.Errorf("SoftKMS.WrapKey() = %v, want error", got)
+			}
+		})
+	}
+}
+
+func TestSoftKMS_UnwrapKey_fail(t *testing.T) {
+	k := &SoftKMS{}
+	wrapped, err := k.WrapKey(&apiv1.WrapKeyRequest{
+		Name:        "testdata/priv.pem",
+		Password:    []byte("pass"),
+		WrappingKey: mustReadRSAPublicKey(t, "testdata/rsa.pub.pem"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *apiv1.UnwrapKeyRequest
+	}{
+		{"fail algorithm", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey, WrappingAlgorithm: apiv1.EncryptionAlgorithm(100), UnwrappingKey: "testdata/rsa.priv.pem", Password: []byte("pass")}},
+		{"fail format", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey, WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA256, UnwrappingKey: "testdata/rsa.priv.pem", Password: []byte("pass")}},
+		{"fail missing key", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey}},
+		{"fail password", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey, UnwrappingKey: "testdata/rsa.priv.pem", Password: []byte("bad-pass")}},
+		{"fail unwrapping key", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey, UnwrappingKey: "testdata/priv.pem", Password: []byte("pass")}},
+		{"fail decrypt", &apiv1.UnwrapKeyRequest{WrappedKey: wrapped.WrappedKey[1:], UnwrappingKey: "testdata/rsa.priv.pem", Password: []byte("pass")}},
+		{"fail aes key wrap", &apiv1.UnwrapKeyRequest{WrappedKey: append(wrapped.WrappedKey, make([]byte, 24)...), WrappingAlgorithm: apiv1.RSAAESKeyWrapWithSHA256, UnwrappingKey: "testdata/rsa.priv.pem", Password: []byte("pass")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := k.UnwrapKey(tt.req); err == nil {
+				t.Errorf("SoftKMS.UnwrapKey() = %v, want error", got)
+			}
+		})
+	}
+}
+
+func mustReadRSAPublicKey(t *testing.T, fn string) *rsa.PublicKey {
+	t.Helper()
+	v, err := pemutil.Read(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := v.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("%s is not an RSA public key", fn)
+	}
+	return pub
+}
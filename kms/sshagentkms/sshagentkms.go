@@ -15,6 +15,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -29,6 +30,9 @@ import (
 // SSHAgentKMS is a key manager that uses keys provided by ssh-agent
 type SSHAgentKMS struct {
 	agentClient agent.Agent
+	conn        io.Closer
+	closed      sync.Once
+	done        uint32
 }
 
 // New returns a new SSHAgentKMS.
@@ -43,6 +47,7 @@ func New(ctx context.Context, opts apiv1.Options) (*SSHAgentKMS, error) {
 
 	return &SSHAgentKMS{
 		agentClient: agentClient,
+		conn:        conn,
 	}, nil
 }
 
@@ -60,9 +65,24 @@ func init() {
 	})
 }
 
-// Close closes the agent. This is a noop for the SSHAgentKMS.
-func (k *SSHAgentKMS) Close() error {
-	return nil
+// Close closes the connection to the agent, if the SSHAgentKMS has opened it.
+// It is safe to call Close multiple times. After Close the operations using
+// agent keys will return an error wrapping apiv1.ErrClosed.
+func (k *SSHAgentKMS) Close() (err error) {
+	k.closed.Do(func() {
+		atomic.StoreUint32(&k.done, 1)
+		if k.conn != nil {
+			err = errors.Wrap(k.conn.Close(), "error closing SSH_AUTH_SOCK")
+		}
+	})
+	return
+}
+
+// Capabilities returns the operations supported by the SSHAgentKMS.
+func (k *SSHAgentKMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateSigner: true,
+	}
 }
 
 // WrappedSSHSigner is a utility type to wrap a ssh.Signer as a crypto.Signer
@@ -119,7 +139,13 @@ func NewWrappedSignerFromSSHSigner(signer ssh.Signer) crypto.Signer {
 	return &WrappedSSHSigner{Signer: signer}
 }
 
+// findKey returns the index of the agent key identified by the given name. The
+// name is in the form "sshagentkms:<key>" where the key can be its comment or
+// its fingerprint, either "SHA256:<base64-raw>" or "MD5:<colon-hex>".
 func (k *SSHAgentKMS) findKey(signingKey string) (target int, err error) {
+	if atomic.LoadUint32(&k.done) == 1 {
+		return -1, errors.Wrap(apiv1.ErrClosed, "SSHAgentKMS is closed")
+	}
 	if strings.HasPrefix(signingKey, "sshagentkms:") {
 		var key = strings.TrimPrefix(signingKey, "sshagentkms:")
 
@@ -132,14 +158,24 @@ func (k *SSHAgentKMS) findKey(signingKey string) (target int, err error) {
 				return i, nil
 			}
 		}
+		for i, s := range l {
+			switch {
+			case strings.HasPrefix(key, "SHA256:") && sshutil.Fingerprint(s) == key:
+				return i, nil
+			case strings.HasPrefix(key, "MD5:") && sshutil.EncodedFingerprint(s, sshutil.LegacyMD5Fingerprint) == key:
+				return i, nil
+			}
+		}
 	}
 
 	return -1, errors.Errorf("SSHAgentKMS couldn't find %s", signingKey)
 }
 
-// CreateSigner returns a new signer configured with the given signing key. Note
-// that because of the way an SSH agent and x509.CreateCertificate works, this
-// signer can only properly sign X509 certificates if the key type is Ed25519.
+// CreateSigner returns a new signer configured with the given signing key. Keys
+// in the agent are referenced using "sshagentkms:<comment>" or
+// "sshagentkms:<fingerprint>". Note that because of the way an SSH agent and
+// x509.CreateCertificate works, this signer can only properly sign X509
+// certificates if the key type is Ed25519.
 func (k *SSHAgentKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
 	if req.Signer != nil {
 		return req.Signer, nil
@@ -194,7 +230,9 @@ func (k *SSHAgentKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRe
 	return nil, errors.Errorf("SSHAgentKMS doesn't support generating keys")
 }
 
-// GetPublicKey returns the public key from the file passed in the request name.
+// GetPublicKey returns the public key from the agent key referenced by the
+// request name, "sshagentkms:<comment>" or "sshagentkms:<fingerprint>", or from
+// the file passed in the request name.
 func (k *SSHAgentKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
 	var pub crypto.PublicKey
 	if strings.HasPrefix(req.Name, "sshagentkms:") {
@@ -10,6 +10,7 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"net"
 	"os"
 	"os/exec"
@@ -274,6 +275,29 @@ func TestSSHAgentKMS_Close(t *testing.T) {
 	}
 }
 
+func TestSSHAgentKMS_Close_closed(t *testing.T) {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() { c2.Close() })
+	k := &SSHAgentKMS{
+		agentClient: agent.NewClient(c1),
+		conn:        c1,
+	}
+	for i := 0; i < 2; i++ {
+		if err := k.Close(); err != nil {
+			t.Fatalf("SSHAgentKMS.Close() error = %v", err)
+		}
+	}
+	if _, err := c1.Write([]byte("ping")); err == nil {
+		t.Error("SSHAgentKMS.Close() did not close the connection")
+	}
+	if _, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "sshagentkms:foo"}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("SSHAgentKMS.GetPublicKey() error = %v, want apiv1.ErrClosed", err)
+	}
+	if _, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:foo"}); !errors.Is(err, apiv1.ErrClosed) {
+		t.Errorf("SSHAgentKMS.CreateSigner() error = %v, want apiv1.ErrClosed", err)
+	}
+}
+
 func TestSSHAgentKMS_CreateSigner(t *testing.T) {
 	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -318,7 +342,7 @@ func TestSSHAgentKMS_CreateSigner(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, comment, _, _, err := ssh.ParseAuthorizedKey(sshPubKeyStr)
+	sshPubKey, comment, _, _, err := ssh.ParseAuthorizedKey(sshPubKeyStr)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -350,6 +374,9 @@ func TestSSHAgentKMS_CreateSigner(t *testing.T) {
 		{"pem password", args{&apiv1.CreateSignerRequest{SigningKeyPEM: pem.EncodeToMemory(pemBlockPassword), Password: []byte("pass")}}, pk, false},
 		{"file", args{&apiv1.CreateSignerRequest{SigningKey: "testdata/priv.pem", Password: []byte("pass")}}, pk2, false},
 		{"sshagent", args{&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:" + comment}}, wrappedSSHPrivateKey, false},
+		{"sshagent fingerprint", args{&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:" + ssh.FingerprintSHA256(sshPubKey)}}, wrappedSSHPrivateKey, false},
+		{"sshagent md5 fingerprint", args{&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:MD5:" + ssh.FingerprintLegacyMD5(sshPubKey)}}, wrappedSSHPrivateKey, false},
+		{"sshagent Nonexistant fingerprint", args{&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:SHA256:Nonexistant"}}, nil, true},
 		{"sshagent Nonexistant", args{&apiv1.CreateSignerRequest{SigningKey: "sshagentkms:Nonexistant"}}, nil, true},
 		{"fail", args{&apiv1.CreateSignerRequest{}}, nil, true},
 		{"fail bad pem", args{&apiv1.CreateSignerRequest{SigningKeyPEM: []byte("bad pem")}}, nil, true},
@@ -439,6 +466,9 @@ func TestSSHAgentKMS_GetPublicKey(t *testing.T) {
 		{"key", args{&apiv1.GetPublicKeyRequest{Name: "testdata/pub.pem"}}, pub, false},
 		{"cert", args{&apiv1.GetPublicKeyRequest{Name: "testdata/cert.crt"}}, pub, false},
 		{"sshagent", args{&apiv1.GetPublicKeyRequest{Name: "sshagentkms:" + comment}}, sshPubKey, false},
+		{"sshagent fingerprint", args{&apiv1.GetPublicKeyRequest{Name: "sshagentkms:" + ssh.FingerprintSHA256(sshPubKey)}}, sshPubKey, false},
+		{"sshagent md5 fingerprint", args{&apiv1.GetPublicKeyRequest{Name: "sshagentkms:MD5:" + ssh.FingerprintLegacyMD5(sshPubKey)}}, sshPubKey, false},
+		{"sshagent Nonexistant fingerprint", args{&apiv1.GetPublicKeyRequest{Name: "sshagentkms:MD5:00:11:22"}}, nil, true},
 		{"sshagent Nonexistant", args{&apiv1.GetPublicKeyRequest{Name: "sshagentkms:Nonexistant"}}, nil, true},
 		{"fail not exists", args{&apiv1.GetPublicKeyRequest{Name: "testdata/missing"}}, nil, true},
 		{"fail type", args{&apiv1.GetPublicKeyRequest{Name: "testdata/cert.key"}}, nil, true},
@@ -576,3 +606,58 @@ func TestWrappedSSHSigner_agent(t *testing.T) {
 		t.Errorf("ssh.PublicKey.Verify() error = %v", err)
 	}
 }
+
+func TestWrappedSSHSigner_agentFingerprint(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := randutil.Salt(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshAgent, err := NewFromAgent(context.Background(), apiv1.Options{}, startTestKeyringAgent(t,
+		agent.AddedKey{PrivateKey: edKey, Comment: "go-test-ed25519"},
+		agent.AddedKey{PrivateKey: ecKey, Comment: "go-test-ecdsa"},
+	))
+	if err != nil {
+		t.Fatalf("NewFromAgent() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"ed25519", edKey},
+		{"ecdsa", ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshPub, err := ssh.NewPublicKey(tt.key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer, err := sshAgent.CreateSigner(&apiv1.CreateSignerRequest{
+				SigningKey: "sshagentkms:" + ssh.FingerprintSHA256(sshPub),
+			})
+			if err != nil {
+				t.Fatalf("SSHAgentKMS.CreateSigner() error = %v", err)
+			}
+			if got := signer.Public().(ssh.PublicKey); !bytes.Equal(got.Marshal(), sshPub.Marshal()) {
+				t.Errorf("WrappedSSHSigner.Public() = %v, want %v", got, sshPub)
+			}
+			if _, err := signer.Sign(rand.Reader, message, crypto.Hash(0)); err != nil {
+				t.Fatalf("WrappedSSHSigner.Sign() error = %v", err)
+			}
+			sshSig := signer.(*WrappedSSHSigner).LastSignature()
+			if err := sshPub.Verify(message, sshSig); err != nil {
+				t.Errorf("ssh.PublicKey.Verify() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+//go:build !notpmkms
+// +build !notpmkms
+
+package tpmkms
+
+import (
+	"context"
+	"crypto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/uri"
+	"go.step.sm/crypto/tpm"
+	"go.step.sm/crypto/tpm/storage"
+)
+
+// Scheme is the scheme used in uris.
+const Scheme = "tpmkms"
+
+// maxPCR is the highest PCR index supported in the pcr attribute, TPMs
+// implement at least 24 PCRs.
+const maxPCR = 23
+
+// DefaultRSASize is the number of bits of a new RSA key if no size has been
+// specified. Most TPMs only support RSA keys up to 2048 bits.
+const DefaultRSASize = 2048
+
+func init() {
+	apiv1.Register(apiv1.TPMKMS, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return New(ctx, opts)
+	})
+}
+
+// TPMKMS is a KMS implementation backed by a TPM 2.0. Keys are created as
+// children of the TPM storage root key and its encrypted blobs are persisted
+// in the configured storage directory, so they can be loaded again into the
+// TPM that created them.
+type TPMKMS struct {
+	tpm    *tpm.TPM
+	closed uint32
+}
+
+type algorithmAttributes struct {
+	Type  string
+	Curve int
+}
+
+var signatureAlgorithmMapping = map[apiv1.SignatureAlgorithm]algorithmAttributes{
+	apiv1.UnspecifiedSignAlgorithm: {"RSA", -1},
+	apiv1.SHA256WithRSA:            {"RSA", -1},
+	apiv1.SHA384WithRSA:            {"RSA", -1},
+	apiv1.SHA512WithRSA:            {"RSA", -1},
+	apiv1.SHA256WithRSAPSS:         {"RSA", -1},
+	apiv1.SHA384WithRSAPSS:         {"RSA", -1},
+	apiv1.SHA512WithRSAPSS:         {"RSA", -1},
+	apiv1.ECDSAWithSHA256:          {"ECDSA", 256},
+	apiv1.ECDSAWithSHA384:          {"ECDSA", 384},
+}
+
+// New initializes a new KMS backed by a TPM.
+//
+// The TPM can be configured using the URI in the options:
+//
+//	New(ctx, &apiv1.Options{
+//	    URI: "tpmkms:device=/dev/tpmrm0;storage-directory=/var/lib/step/tpm",
+//	})
+//
+// The device attribute selects the path to the TPM device, if it's not
+// provided the default TPM of the system will be used. The storage-directory
+// attribute defines where the key blobs are persisted, if it's not provided,
+// the keys won't be persisted and will only be available for the lifetime of
+// the KMS.
+func New(ctx context.Context, opts apiv1.Options) (*TPMKMS, error) {
+	var tpmOpts []tpm.NewTPMOption
+	if opts.URI != "" {
+		u, err := uri.ParseWithScheme(Scheme, opts.URI)
+		if err != nil {
+			return nil, err
+		}
+		if device := u.Get("device"); device != "" {
+			tpmOpts = append(tpmOpts, tpm.WithDeviceName(device))
+		}
+		if dir := u.Get("storage-directory"); dir != "" {
+			tpmOpts = append(tpmOpts, tpm.WithStore(storage.NewDirstore(dir)))
+		}
+	}
+
+	t, err := tpm.New(tpmOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error initializing TPM")
+	}
+
+	return &TPMKMS{
+		tpm: t,
+	}, nil
+}
+
+// CreateKey generates a new key in the TPM and returns its public key. The
+// name of the key must be in the form "tpmkms:name=my-key". RSA keys are
+// created with 2048 bits by default. P-521 keys are not supported, most TPMs
+// do not implement that curve.
+//
+// If the name contains an ak attribute, e.g. "tpmkms:name=my-key;ak=my-ak",
+// the key will be attested by the AK with that name, and CreateAttestation can
+// be used to retrieve the attestation.
+//
+// If the name contains a pcr attribute with a comma separated list of PCR
+// indexes, e.g. "tpmkms:name=my-key;pcr=0,1,7", the key will be bound to the
+// current values of those PCRs in the SHA-256 bank, and signing with it will
+// fail if any of them changes. The PCRs are stored with the key, so the pcr
+// attribute is not required in the other operations. Keys bound to PCRs
+// cannot be attested by an AK.
+func (k *TPMKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	name, akName, config, err := createKeyParameters(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *tpm.Key
+	ctx := context.Background()
+	if akName != "" {
+		key, err = k.tpm.AttestKey(ctx, akName, name, tpm.AttestKeyConfig{
+			Algorithm: config.Algorithm,
+			Size:      config.Size,
+		})
+	} else {
+		key, err = k.tpm.CreateKey(ctx, name, config)
+	}
+	if err != nil {
+		if errors.Is(err, tpm.ErrExists) {
+			return nil, apiv1.AlreadyExistsError{Message: err.Error()}
+		}
+		return nil, errors.Wrap(err, "error creating key")
+	}
+
+	signer, err := key.Signer(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting signer")
+	}
+
+	keyURI := uri.New(Scheme, url.Values{
+		"name": []string{key.Name()},
+	}).String()
+
+	return &apiv1.CreateKeyResponse{
+		Name:      keyURI,
+		PublicKey: signer.Public(),
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: keyURI,
+		},
+	}, nil
+}
+
+// ValidateCreateKey validates the parameters of the given request without
+// creating the key. It returns the same errors as CreateKey for invalid names
+// and unsupported algorithms, including ECDSAWithSHA512, and public exponents.
+func (k *TPMKMS) ValidateCreateKey(req *apiv1.CreateKeyRequest) error {
+	if err := k.checkClosed(); err != nil {
+		return err
+	}
+	_, _, _, err := createKeyParameters(req)
+	return err
+}
+
+// createKeyParameters returns the key name, the AK name and the configuration
+// used to create the key in the given request.
+func createKeyParameters(req *apiv1.CreateKeyRequest) (string, string, tpm.CreateKeyConfig, error) {
+	var config tpm.CreateKeyConfig
+	name, err := parseNameURI(req.Name)
+	if err != nil {
+		return "", "", config, err
+	}
+	u, err := uri.ParseWithScheme(Scheme, req.Name)
+	if err != nil {
+		return "", "", config, err
+	}
+	akName := u.Get("ak")
+	pcrs, err := parsePCRs(u.Get("pcr"))
+	if err != nil {
+		return "", "", config, errors.Wrapf(err, "error parsing %s", req.Name)
+	}
+	if akName != "" && len(pcrs) > 0 {
+		return "", "", config, apiv1.NotImplementedError{Message: "tpmkms does not support PCR policies in keys attested by an AK"}
+	}
+
+	v, ok := signatureAlgorithmMapping[req.SignatureAlgorithm]
+	if !ok {
+		return "", "", config, errors.Errorf("TPMKMS does not support signature algorithm '%s'", req.SignatureAlgorithm)
+	}
+	if req.PublicExponent != 0 && req.PublicExponent != 65537 {
+		return "", "", config, errors.Errorf("TPMKMS does not support public exponent %d", req.PublicExponent)
+	}
+
+	size := v.Curve
+	if v.Type == "RSA" {
+		size = req.Bits
+		if size == 0 {
+			size = DefaultRSASize
+		}
+	}
+
+	return name, akName, tpm.CreateKeyConfig{
+		Algorithm: v.Type,
+		Size:      size,
+		PCRs:      pcrs,
+	}, nil
+}
+
+// CreateSigner creates a crypto.Signer backed by the TPM key referenced in
+// the signing key. Signatures are created using the TPM Sign command.
+func (k *TPMKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	if req.Signer != nil {
+		return req.Signer, nil
+	}
+
+	name, err := parseNameURI(req.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := k.tpm.GetSigner(context.Background(), name)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting signer")
+	}
+
+	return signer, nil
+}
+
+// GetPublicKey returns the public key of the TPM key referenced in the request
+// name.
+func (k *TPMKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	name, err := parseNameURI(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := k.tpm.GetSigner(context.Background(), name)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting public key")
+	}
+
+	return signer.Public(), nil
+}
+
+// CreateAttestation returns the attestation of a TPM key created with an ak
+// attribute. The response contains the certificate chain of the AK, that can
+// be verified against the TPM manufacturer roots, and the certification
+// parameters signed by the AK that prove that the key was created in the same
+// TPM.
+//
+// # Experimental
+//
+// Notice: This API is EXPERIMENTAL and may be changed or removed in a later
+// release.
+func (k *TPMKMS) CreateAttestation(req *apiv1.CreateAttestationRequest) (*apiv1.CreateAttestationResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	name, err := parseNameURI(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	key, err := k.tpm.GetKey(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting key")
+	}
+	if !key.WasAttested() {
+		return nil, errors.Errorf("key %q was not attested by an AK", name)
+	}
+
+	ak, err := k.tpm.GetAK(ctx, key.AttestedBy())
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting AK")
+	}
+	chain := ak.CertificateChain()
+	if len(chain) == 0 {
+		return nil, errors.Errorf("AK %q does not have a certificate chain", ak.Name())
+	}
+
+	params, err := key.CertificationParameters(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting certification parameters")
+	}
+	if err := params.Verify(attest.VerifyOpts{
+		Public: chain[0].PublicKey,
+		Hash:   crypto.SHA256,
+	}); err != nil {
+		return nil, errors.Wrap(err, "error verifying certification parameters")
+	}
+
+	signer, err := key.Signer(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting signer")
+	}
+
+	return &apiv1.CreateAttestationResponse{
+		Certificate:             chain[0],
+		CertificateChain:        chain,
+		PublicKey:               signer.Public(),
+		CertificationParameters: &params,
+	}, nil
+}
+
+// Close releases the connection to the TPM. The TPM is only opened while an
+// operation is performed, and the key blobs are persisted after each one, so
+// Close only marks the TPMKMS as closed. After Close the TPMKMS operations will
+// return an error wrapping apiv1.ErrClosed.
+func (k *TPMKMS) Close() error {
+	atomic.StoreUint32(&k.closed, 1)
+	return nil
+}
+
+func (k *TPMKMS) checkClosed() error {
+	if atomic.LoadUint32(&k.closed) == 1 {
+		return errors.Wrap(apiv1.ErrClosed, "error using TPMKMS")
+	}
+	return nil
+}
+
+// Capabilities returns the operations supported by the TPMKMS.
+func (k *TPMKMS) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:         true,
+		CreateSigner:      true,
+		CreateAttestation: true,
+	}
+}
+
+// parseNameURI returns the name of a key in a tpmkms URI.
+func parseNameURI(rawuri string) (string, error) {
+	if rawuri == "" {
+		return "", errors.New("key name cannot be empty")
+	}
+	u, err := uri.ParseWithScheme(Scheme, rawuri)
+	if err != nil {
+		return "", err
+	}
+	name := u.Get("name")
+	if name == "" {
+		return "", errors.Errorf("error parsing %s: name is required", rawuri)
+	}
+	return name, nil
+}
+
+// parsePCRs parses a comma separated list of PCR indexes, e.g. "0,1,7". It
+// returns nil if the list is empty.
+func parsePCRs(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var pcrs []int
+	seen := make(map[int]bool)
+	for _, v := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || i < 0 || i > maxPCR {
+			return nil, errors.Errorf("pcr %q is not valid", v)
+		}
+		if !seen[i] {
+			seen[i] = true
+			pcrs = append(pcrs, i)
+		}
+	}
+	return pcrs, nil
+}
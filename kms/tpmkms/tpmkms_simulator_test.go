@@ -0,0 +1,235 @@
+//go:build tpmsimulator
+// +build tpmsimulator
+
+package tpmkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/tpm"
+	"go.step.sm/crypto/tpm/simulator"
+	"go.step.sm/crypto/tpm/storage"
+)
+
+func newSimulatedTPMKMS(t *testing.T) *TPMKMS {
+	t.Helper()
+	k, _ := newSimulatedTPMKMSWithSimulator(t)
+	return k
+}
+
+func newSimulatedTPMKMSWithSimulator(t *testing.T) (*TPMKMS, simulator.Simulator) {
+	t.Helper()
+	sim := simulator.New()
+	require.NoError(t, sim.Open())
+	t.Cleanup(func() {
+		assert.NoError(t, sim.Close())
+	})
+
+	instance, err := tpm.New(tpm.WithSimulator(sim), tpm.WithStore(storage.NewDirstore(t.TempDir())))
+	require.NoError(t, err)
+	return &TPMKMS{tpm: instance}, sim
+}
+
+func TestTPMKMS_CreateKey(t *testing.T) {
+	k := newSimulatedTPMKMS(t)
+
+	tests := []struct {
+		name string
+		req  *apiv1.CreateKeyRequest
+		want func(t *testing.T, pub crypto.PublicKey)
+	}{
+		{"ok default", &apiv1.CreateKeyRequest{Name: "tpmkms:name=default"}, func(t *testing.T, pub crypto.PublicKey) {
+			require.IsType(t, &rsa.PublicKey{}, pub)
+			assert.Equal(t, 2048, pub.(*rsa.PublicKey).N.BitLen())
+		}},
+		{"ok rsa", &apiv1.CreateKeyRequest{Name: "tpmkms:name=rsa", SignatureAlgorithm: apiv1.SHA256WithRSAPSS, Bits: 1024}, func(t *testing.T, pub crypto.PublicKey) {
+			require.IsType(t, &rsa.PublicKey{}, pub)
+			assert.Equal(t, 1024, pub.(*rsa.PublicKey).N.BitLen())
+		}},
+		{"ok ecdsa", &apiv1.CreateKeyRequest{Name: "tpmkms:name=ecdsa", SignatureAlgorithm: apiv1.ECDSAWithSHA256}, func(t *testing.T, pub crypto.PublicKey) {
+			require.IsType(t, &ecdsa.PublicKey{}, pub)
+			assert.Equal(t, "P-256", pub.(*ecdsa.PublicKey).Curve.Params().Name)
+		}},
+		{"ok ecdsa P-384", &apiv1.CreateKeyRequest{Name: "tpmkms:name=ecdsa-p384", SignatureAlgorithm: apiv1.ECDSAWithSHA384}, func(t *testing.T, pub crypto.PublicKey) {
+			require.IsType(t, &ecdsa.PublicKey{}, pub)
+			assert.Equal(t, "P-384", pub.(*ecdsa.PublicKey).Curve.Params().Name)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateKey(tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.req.Name, got.Name)
+			assert.Equal(t, tt.req.Name, got.CreateSignerRequest.SigningKey)
+			tt.want(t, got.PublicKey)
+
+			pub, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: got.Name})
+			require.NoError(t, err)
+			assert.Equal(t, got.PublicKey, pub)
+		})
+	}
+
+	_, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=ecdsa", SignatureAlgorithm: apiv1.ECDSAWithSHA256})
+	var alreadyExists apiv1.AlreadyExistsError
+	assert.ErrorAs(t, err, &alreadyExists)
+}
+
+func TestTPMKMS_CreateSigner(t *testing.T) {
+	k := newSimulatedTPMKMS(t)
+
+	rsaKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=rsa", SignatureAlgorithm: apiv1.SHA256WithRSAPSS, Bits: 2048})
+	require.NoError(t, err)
+	ecKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=ecdsa", SignatureAlgorithm: apiv1.ECDSAWithSHA384})
+	require.NoError(t, err)
+
+	sum256 := sha256.Sum256([]byte("the-data"))
+	sum384 := sha512.Sum384([]byte("the-data"))
+
+	t.Run("ok rsa-pss", func(t *testing.T) {
+		signer, err := k.CreateSigner(&rsaKey.CreateSignerRequest)
+		require.NoError(t, err)
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: crypto.SHA256}
+		sig, err := signer.Sign(rand.Reader, sum256[:], opts)
+		require.NoError(t, err)
+		assert.NoError(t, rsa.VerifyPSS(rsaKey.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum256[:], sig, opts))
+	})
+
+	t.Run("ok rsa", func(t *testing.T) {
+		signer, err := k.CreateSigner(&rsaKey.CreateSignerRequest)
+		require.NoError(t, err)
+		sig, err := signer.Sign(rand.Reader, sum256[:], crypto.SHA256)
+		require.NoError(t, err)
+		assert.NoError(t, rsa.VerifyPKCS1v15(rsaKey.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum256[:], sig))
+	})
+
+	t.Run("ok ecdsa", func(t *testing.T) {
+		signer, err := k.CreateSigner(&ecKey.CreateSignerRequest)
+		require.NoError(t, err)
+		sig, err := signer.Sign(rand.Reader, sum384[:], crypto.SHA384)
+		require.NoError(t, err)
+		assert.True(t, ecdsa.VerifyASN1(ecKey.PublicKey.(*ecdsa.PublicKey), sum384[:], sig))
+	})
+
+	t.Run("fail not found", func(t *testing.T) {
+		_, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "tpmkms:name=missing"})
+		assert.ErrorIs(t, err, tpm.ErrNotFound)
+		_, err = k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "tpmkms:name=missing"})
+		assert.ErrorIs(t, err, tpm.ErrNotFound)
+	})
+}
+
+func TestTPMKMS_CreateAttestation(t *testing.T) {
+	k := newSimulatedTPMKMS(t)
+	ctx := context.Background()
+
+	ak, err := k.tpm.CreateAK(ctx, "my-ak")
+	require.NoError(t, err)
+	params, err := ak.AttestationParameters(ctx)
+	require.NoError(t, err)
+	akPub, err := attest.ParseAKPublic(attest.TPMVersion20, params.Public)
+	require.NoError(t, err)
+
+	ca, err := minica.New(minica.WithGetSignerFunc(func() (crypto.Signer, error) {
+		return keyutil.GenerateSigner("EC", "P-256", 0)
+	}))
+	require.NoError(t, err)
+	akCert, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "my-ak"},
+		PublicKey: akPub.Public,
+	})
+	require.NoError(t, err)
+
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=no-chain;ak=my-ak"})
+	require.NoError(t, err)
+	_, err = k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: "tpmkms:name=no-chain"})
+	assert.EqualError(t, err, `AK "my-ak" does not have a certificate chain`)
+
+	require.NoError(t, ak.SetCertificateChain(ctx, []*x509.Certificate{akCert, ca.Intermediate}))
+	attested, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=attested;ak=my-ak", SignatureAlgorithm: apiv1.ECDSAWithSHA256})
+	require.NoError(t, err)
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=not-attested"})
+	require.NoError(t, err)
+
+	t.Run("ok", func(t *testing.T) {
+		resp, err := k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: attested.Name})
+		require.NoError(t, err)
+		assert.Equal(t, akCert, resp.Certificate)
+		assert.Equal(t, []*x509.Certificate{akCert, ca.Intermediate}, resp.CertificateChain)
+		assert.Equal(t, attested.PublicKey, resp.PublicKey)
+		require.NotNil(t, resp.CertificationParameters)
+		assert.NoError(t, resp.CertificationParameters.Verify(attest.VerifyOpts{
+			Public: resp.Certificate.PublicKey,
+			Hash:   crypto.SHA256,
+		}))
+	})
+
+	t.Run("fail not attested", func(t *testing.T) {
+		_, err := k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: "tpmkms:name=not-attested"})
+		assert.EqualError(t, err, `key "not-attested" was not attested by an AK`)
+	})
+
+	t.Run("fail not found", func(t *testing.T) {
+		_, err := k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: "tpmkms:name=missing"})
+		assert.ErrorIs(t, err, tpm.ErrNotFound)
+		_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=key;ak=missing"})
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestTPMKMS_CreateKey_pcr(t *testing.T) {
+	k, sim := newSimulatedTPMKMSWithSimulator(t)
+
+	rsaKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=rsa;pcr=16", SignatureAlgorithm: apiv1.SHA256WithRSA})
+	require.NoError(t, err)
+	assert.Equal(t, "tpmkms:name=rsa", rsaKey.Name)
+	ecKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=ecdsa;pcr=0,16", SignatureAlgorithm: apiv1.ECDSAWithSHA256})
+	require.NoError(t, err)
+
+	key, err := k.tpm.GetKey(context.Background(), "ecdsa")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 16}, key.PCRs())
+
+	sum := sha256.Sum256([]byte("the-data"))
+	sign := func(t *testing.T, resp *apiv1.CreateKeyResponse, opts crypto.SignerOpts) ([]byte, error) {
+		t.Helper()
+		signer, err := k.CreateSigner(&resp.CreateSignerRequest)
+		require.NoError(t, err)
+		assert.Equal(t, resp.PublicKey, signer.Public())
+		return signer.Sign(rand.Reader, sum[:], opts)
+	}
+
+	sig, err := sign(t, rsaKey, crypto.SHA256)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(rsaKey.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum[:], sig))
+	pssOpts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: crypto.SHA256}
+	sig, err = sign(t, rsaKey, pssOpts)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPSS(rsaKey.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum[:], sig, pssOpts))
+	sig, err = sign(t, ecKey, crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(ecKey.PublicKey.(*ecdsa.PublicKey), sum[:], sig))
+
+	// Extending the PCR invalidates the policy.
+	require.NoError(t, tpm2.PCRExtend(sim, tpmutil.Handle(16), tpm2.AlgSHA256, make([]byte, 32), ""))
+	_, err = sign(t, rsaKey, crypto.SHA256)
+	assert.Error(t, err)
+	_, err = sign(t, ecKey, crypto.SHA256)
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+//go:build !notpmkms
+// +build !notpmkms
+
+package tpmkms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+func TestRegister(t *testing.T) {
+	fn, ok := apiv1.LoadKeyManagerNewFunc(apiv1.TPMKMS)
+	require.True(t, ok)
+	km, err := fn(context.Background(), apiv1.Options{
+		Type: apiv1.TPMKMS,
+		URI:  "tpmkms:device=/dev/tpmrm0",
+	})
+	require.NoError(t, err)
+	assert.NoError(t, km.Close())
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	type args struct {
+		ctx  context.Context
+		opts apiv1.Options
+	}
+	tests := []struct {
+		name      string
+		args      args
+		assertion assert.ErrorAssertionFunc
+	}{
+		{"ok", args{context.Background(), apiv1.Options{}}, assert.NoError},
+		{"ok with device", args{context.Background(), apiv1.Options{URI: "tpmkms:device=/dev/tpmrm0"}}, assert.NoError},
+		{"ok with storage", args{context.Background(), apiv1.Options{URI: "tpmkms:device=/dev/tpmrm0;storage-directory=" + dir}}, assert.NoError},
+		{"fail scheme", args{context.Background(), apiv1.Options{URI: "yubikey:device=/dev/tpmrm0"}}, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.args.ctx, tt.args.opts)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.NotNil(t, got)
+			}
+		})
+	}
+}
+
+func TestTPMKMS_errors(t *testing.T) {
+	k, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+
+	var notImplemented apiv1.NotImplementedError
+
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: ""})
+	assert.EqualError(t, err, "key name cannot be empty")
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:foo=bar"})
+	assert.EqualError(t, err, "error parsing tpmkms:foo=bar: name is required")
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "yubikey:name=my-key"})
+	assert.Error(t, err)
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;pcr=0,1,24"})
+	assert.EqualError(t, err, `error parsing tpmkms:name=my-key;pcr=0,1,24: pcr "24" is not valid`)
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;ak=my-ak;pcr=7"})
+	assert.ErrorAs(t, err, &notImplemented)
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key", SignatureAlgorithm: apiv1.PureEd25519})
+	assert.EqualError(t, err, "TPMKMS does not support signature algorithm 'Ed25519'")
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA512})
+	assert.EqualError(t, err, "TPMKMS does not support signature algorithm 'ECDSA-SHA512'")
+
+	_, err = k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "tpmkms:foo=bar"})
+	assert.Error(t, err)
+
+	_, err = k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: ""})
+	assert.Error(t, err)
+
+	_, err = k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: ""})
+	assert.EqualError(t, err, "key name cannot be empty")
+}
+
+func TestTPMKMS_ValidateCreateKey(t *testing.T) {
+	k, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		req       *apiv1.CreateKeyRequest
+		assertion assert.ErrorAssertionFunc
+	}{
+		{"ok", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key"}, assert.NoError},
+		{"ok ecdsa", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA384}, assert.NoError},
+		{"ok pcr", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;pcr=0,1,7"}, assert.NoError},
+		{"ok ak", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;ak=my-ak"}, assert.NoError},
+		{"fail name", &apiv1.CreateKeyRequest{Name: "tpmkms:foo=bar"}, assert.Error},
+		{"fail pcr", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;pcr=foo"}, assert.Error},
+		{"fail pcr and ak", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key;ak=my-ak;pcr=7"}, assert.Error},
+		{"fail P-521", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key", SignatureAlgorithm: apiv1.ECDSAWithSHA512}, assert.Error},
+		{"fail exponent", &apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key", PublicExponent: 3}, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, k.ValidateCreateKey(tt.req))
+		})
+	}
+}
+
+func Test_parsePCRs(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		want      []int
+		assertion assert.ErrorAssertionFunc
+	}{
+		{"ok empty", "", nil, assert.NoError},
+		{"ok", "0,1,7", []int{0, 1, 7}, assert.NoError},
+		{"ok duplicated", "7, 23,7", []int{7, 23}, assert.NoError},
+		{"fail negative", "-1", nil, assert.Error},
+		{"fail too large", "24", nil, assert.Error},
+		{"fail not a number", "0,a", nil, assert.Error},
+		{"fail empty item", "0,,1", nil, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePCRs(tt.s)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTPMKMS_Close(t *testing.T) {
+	k, err := New(context.Background(), apiv1.Options{})
+	require.NoError(t, err)
+	assert.NoError(t, k.Close())
+	assert.NoError(t, k.Close())
+
+	_, err = k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key"})
+	assert.ErrorIs(t, err, apiv1.ErrClosed)
+	assert.ErrorIs(t, k.ValidateCreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:name=my-key"}), apiv1.ErrClosed)
+	_, err = k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "tpmkms:name=my-key"})
+	assert.ErrorIs(t, err, apiv1.ErrClosed)
+	_, err = k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: "tpmkms:name=my-key"})
+	assert.ErrorIs(t, err, apiv1.ErrClosed)
+	_, err = k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: "tpmkms:name=my-key"})
+	assert.ErrorIs(t, err, apiv1.ErrClosed)
+}
@@ -2,10 +2,17 @@ package uri
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/pkg/errors"
@@ -50,20 +57,58 @@ func HasScheme(scheme, rawuri string) bool {
 	return strings.EqualFold(u.Scheme, scheme)
 }
 
-// Parse returns the URI for the given string or an error.
+// ParseError is the error returned by Parse and ParseWithScheme. Besides the
+// original URI, it reports the segment of the URI that failed to parse and, if
+// available, the value found and what was expected instead.
+type ParseError struct {
+	URI      string
+	Segment  string
+	Value    string
+	Expected string
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	msg := "error parsing " + e.URI
+	if e.Segment != "" {
+		msg += ": invalid " + e.Segment
+		if e.Value != "" {
+			msg += fmt.Sprintf(" %q", e.Value)
+		}
+	}
+	if e.Expected != "" {
+		msg += ", expected " + e.Expected
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error if any.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse returns the URI for the given string or an error. Errors are always of
+// type *ParseError.
 func Parse(rawuri string) (*URI, error) {
 	u, err := url.Parse(rawuri)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing %s", rawuri)
+		return nil, &ParseError{URI: rawuri, Segment: "uri", Err: err}
 	}
 	if u.Scheme == "" {
-		return nil, errors.Errorf("error parsing %s: scheme is missing", rawuri)
+		return nil, &ParseError{URI: rawuri, Segment: "scheme", Expected: "a scheme like 'name:'", Err: errors.New("scheme is missing")}
 	}
 	// Starting with Go 1.17 url.ParseQuery returns an error using semicolon as
 	// separator.
 	v, err := url.ParseQuery(strings.ReplaceAll(u.Opaque, ";", "&"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing %s", rawuri)
+		return nil, &ParseError{URI: rawuri, Segment: "attributes", Value: u.Opaque, Expected: "'key=value' pairs separated by ';'", Err: err}
+	}
+	if _, err := url.ParseQuery(u.RawQuery); err != nil {
+		return nil, &ParseError{URI: rawuri, Segment: "query", Value: u.RawQuery, Expected: "'key=value' pairs separated by '&'", Err: err}
 	}
 
 	return &URI{
@@ -73,14 +118,14 @@ func Parse(rawuri string) (*URI, error) {
 }
 
 // ParseWithScheme returns the URI for the given string only if it has the given
-// scheme.
+// scheme. Errors are always of type *ParseError.
 func ParseWithScheme(scheme, rawuri string) (*URI, error) {
 	u, err := Parse(rawuri)
 	if err != nil {
 		return nil, err
 	}
 	if !strings.EqualFold(u.Scheme, scheme) {
-		return nil, errors.Errorf("error parsing %s: scheme not expected", rawuri)
+		return nil, &ParseError{URI: rawuri, Segment: "scheme", Value: u.Scheme, Expected: fmt.Sprintf("%q", scheme), Err: errors.New("scheme not expected")}
 	}
 	return u, nil
 }
@@ -121,6 +166,60 @@ func (u *URI) GetEncoded(key string) []byte {
 	return []byte(v)
 }
 
+// GetInt returns the integer value of the first value in the uri with the
+// given key. The boolean will be false if the key is not present or if its
+// value is not a valid integer.
+func (u *URI) GetInt(key string) (int, bool) {
+	v := u.Get(key)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// GetDuration returns the duration of the first value in the uri with the given
+// key, using the format accepted by time.ParseDuration, e.g. "30s" or "1h30m".
+// The boolean will be false if the key is not present or if its value is not a
+// valid duration.
+func (u *URI) GetDuration(key string) (time.Duration, bool) {
+	v := u.Get(key)
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+// GetBase64 returns the base64 decoded value of the first value in the uri
+// with the given key. Both the standard and the URL-safe alphabets, padded or
+// not, are accepted. The boolean will be false if the key is not present or if
+// its value is not valid base64.
+//
+// A '+' that is not percent-encoded is decoded as a space when the uri is
+// parsed, so spaces are read as '+'.
+func (u *URI) GetBase64(key string) ([]byte, bool) {
+	v := strings.ReplaceAll(u.Get(key), " ", "+")
+	if v == "" {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(v); err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
 // Pin returns the pin encoded in the url. It will read the pin from the
 // pin-value or the pin-source attributes.
 func (u *URI) Pin() string {
@@ -135,14 +234,84 @@ func (u *URI) Pin() string {
 	return ""
 }
 
+// maxPinSize is the maximum number of bytes read from a pin-source.
+const maxPinSize = 4096
+
+// pinSourceClient is the HTTP client used to retrieve https pin-sources.
+var pinSourceClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+// PinValue returns the pin in the given uri. The pin is the value of the
+// pin-value attribute, or the contents of the pin-source attribute, without
+// trailing spaces or new lines. As defined in RFC 7512, the pin-source can be
+// a file path, a file:// URL or an https:// URL, other schemes are rejected.
+// PinValue returns nil if neither attribute is present.
+func PinValue(u *URI) ([]byte, error) {
+	if value := u.Get("pin-value"); value != "" {
+		return []byte(value), nil
+	}
+	source := u.Get("pin-source")
+	if source == "" {
+		return nil, nil
+	}
+
+	src, err := url.Parse(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing pin-source %s", source)
+	}
+
+	var b []byte
+	switch scheme := strings.ToLower(src.Scheme); {
+	case scheme == "", scheme == "file", filepath.VolumeName(source) != "":
+		// A volume name is present in Windows paths like C:\pin.txt.
+		b, err = readFile(source)
+	case scheme == "https":
+		b, err = readURL(src.String())
+	default:
+		return nil, errors.Errorf("error reading pin-source %s: scheme %q is not supported", source, src.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRightFunc(b, unicode.IsSpace), nil
+}
+
+func readURL(rawurl string) ([]byte, error) {
+	resp, err := pinSourceClient.Get(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", rawurl)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, errors.Errorf("error retrieving %s: status code %d", rawurl, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxPinSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", rawurl)
+	}
+	if len(b) > maxPinSize {
+		return nil, errors.Errorf("error retrieving %s: pin exceeds %d bytes", rawurl, maxPinSize)
+	}
+	return b, nil
+}
+
 func readFile(path string) ([]byte, error) {
 	u, err := url.Parse(path)
 	if err == nil && (u.Scheme == "" || u.Scheme == "file") && u.Path != "" {
 		path = u.Path
 	}
-	b, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error reading %s", path)
 	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxPinSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", path)
+	}
+	if len(b) > maxPinSize {
+		return nil, errors.Errorf("error reading %s: pin exceeds %d bytes", path, maxPinSize)
+	}
 	return b, nil
 }
@@ -46,6 +46,7 @@ func TestParse(t *testing.T) {
 		{"fail parse", args{"yubi%key:slot-id=9a"}, nil, true},
 		{"fail scheme", args{"yubikey"}, nil, true},
 		{"fail parse opaque", args{"yubikey:slot-id=%ZZ"}, nil, true},
+		{"fail parse query", args{"yubikey:slot-id=9a?pin=%ZZ"}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1,9 +1,16 @@
 package uri
 
 import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -126,6 +133,45 @@ func TestParseWithScheme(t *testing.T) {
 	}
 }
 
+func TestParseError(t *testing.T) {
+	type args struct {
+		scheme string
+		rawuri string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *ParseError
+		wantErr string
+	}{
+		{"fail parse", args{"yubikey", "yubi%key:slot-id=9a"}, &ParseError{URI: "yubi%key:slot-id=9a", Segment: "uri"}, `error parsing yubi%key:slot-id=9a: invalid uri: parse "yubi%key:slot-id=9a": first path segment in URL cannot contain colon`},
+		{"fail missing scheme", args{"yubikey", "yubikey"}, &ParseError{URI: "yubikey", Segment: "scheme", Expected: "a scheme like 'name:'"}, "error parsing yubikey: invalid scheme, expected a scheme like 'name:': scheme is missing"},
+		{"fail scheme", args{"yubikey", "awskms:key-id=foo"}, &ParseError{URI: "awskms:key-id=foo", Segment: "scheme", Value: "awskms", Expected: `"yubikey"`}, `error parsing awskms:key-id=foo: invalid scheme "awskms", expected "yubikey": scheme not expected`},
+		{"fail attributes", args{"yubikey", "yubikey:slot-id=%ZZ"}, &ParseError{URI: "yubikey:slot-id=%ZZ", Segment: "attributes", Value: "slot-id=%ZZ", Expected: "'key=value' pairs separated by ';'"}, `error parsing yubikey:slot-id=%ZZ: invalid attributes "slot-id=%ZZ", expected 'key=value' pairs separated by ';': invalid URL escape "%ZZ"`},
+		{"fail query", args{"yubikey", "yubikey:slot-id=9a?pin=%ZZ"}, &ParseError{URI: "yubikey:slot-id=9a?pin=%ZZ", Segment: "query", Value: "pin=%ZZ", Expected: "'key=value' pairs separated by '&'"}, `error parsing yubikey:slot-id=9a?pin=%ZZ: invalid query "pin=%ZZ", expected 'key=value' pairs separated by '&': invalid URL escape "%ZZ"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithScheme(tt.args.scheme, tt.args.rawuri)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseWithScheme() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ParseWithScheme() error = %T, want *ParseError", err)
+			}
+			if errors.Unwrap(err) == nil {
+				t.Errorf("ParseWithScheme() error does not wrap an error")
+			}
+			pe.Err = nil
+			if !reflect.DeepEqual(pe, tt.want) {
+				t.Errorf("ParseWithScheme() error = %#v, want %#v", pe, tt.want)
+			}
+		})
+	}
+}
+
 func TestURI_Get(t *testing.T) {
 	mustParse := func(s string) *URI {
 		u, err := Parse(s)
@@ -229,6 +275,111 @@ func TestURI_GetEncoded(t *testing.T) {
 	}
 }
 
+func TestURI_GetInt(t *testing.T) {
+	mustParse := func(s string) *URI {
+		u, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name   string
+		uri    *URI
+		key    string
+		want   int
+		wantOk bool
+	}{
+		{"ok", mustParse("awskms:key-id=foo;max-retries=3"), "max-retries", 3, true},
+		{"ok zero", mustParse("awskms:key-id=foo;max-retries=0"), "max-retries", 0, true},
+		{"ok negative", mustParse("awskms:key-id=foo;max-retries=-1"), "max-retries", -1, true},
+		{"ok in query", mustParse("awskms:key-id=foo?max-retries=10"), "max-retries", 10, true},
+		{"missing", mustParse("awskms:key-id=foo"), "max-retries", 0, false},
+		{"empty", mustParse("awskms:key-id=foo;max-retries="), "max-retries", 0, false},
+		{"malformed", mustParse("awskms:key-id=foo;max-retries=three"), "max-retries", 0, false},
+		{"malformed float", mustParse("awskms:key-id=foo;max-retries=1.5"), "max-retries", 0, false},
+		{"malformed overflow", mustParse("awskms:key-id=foo;max-retries=99999999999999999999"), "max-retries", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.uri.GetInt(tt.key)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("URI.GetInt() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestURI_GetDuration(t *testing.T) {
+	mustParse := func(s string) *URI {
+		u, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name   string
+		uri    *URI
+		key    string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"ok", mustParse("awskms:key-id=foo;timeout=30s"), "timeout", 30 * time.Second, true},
+		{"ok complex", mustParse("awskms:key-id=foo;timeout=1h30m"), "timeout", 90 * time.Minute, true},
+		{"ok zero", mustParse("awskms:key-id=foo;timeout=0"), "timeout", 0, true},
+		{"ok in query", mustParse("awskms:key-id=foo?timeout=500ms"), "timeout", 500 * time.Millisecond, true},
+		{"missing", mustParse("awskms:key-id=foo"), "timeout", 0, false},
+		{"empty", mustParse("awskms:key-id=foo;timeout="), "timeout", 0, false},
+		{"malformed", mustParse("awskms:key-id=foo;timeout=30"), "timeout", 0, false},
+		{"malformed unit", mustParse("awskms:key-id=foo;timeout=30days"), "timeout", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.uri.GetDuration(tt.key)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("URI.GetDuration() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestURI_GetBase64(t *testing.T) {
+	mustParse := func(s string) *URI {
+		u, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name   string
+		uri    *URI
+		key    string
+		want   []byte
+		wantOk bool
+	}{
+		{"ok", mustParse("softkms:cert=aGVsbG8gd29ybGQ%3D"), "cert", []byte("hello world"), true},
+		{"ok raw", mustParse("softkms:cert=aGVsbG8gd29ybGQ"), "cert", []byte("hello world"), true},
+		{"ok plus", mustParse("softkms:cert=+/8="), "cert", []byte{0xfb, 0xff}, true},
+		{"ok percent plus", mustParse("softkms:cert=%2B%2F8%3D"), "cert", []byte{0xfb, 0xff}, true},
+		{"ok url", mustParse("softkms:cert=-_8"), "cert", []byte{0xfb, 0xff}, true},
+		{"ok in query", mustParse("softkms:name=foo?cert=aGVsbG8gd29ybGQ%3D"), "cert", []byte("hello world"), true},
+		{"missing", mustParse("softkms:name=foo"), "cert", nil, false},
+		{"empty", mustParse("softkms:cert="), "cert", nil, false},
+		{"malformed", mustParse("softkms:cert=not*base64"), "cert", nil, false},
+		{"malformed length", mustParse("softkms:cert=a"), "cert", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.uri.GetBase64(tt.key)
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk {
+				t.Errorf("URI.GetBase64() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
 func TestURI_Pin(t *testing.T) {
 	mustParse := func(s string) *URI {
 		u, err := Parse(s)
@@ -256,6 +407,83 @@ func TestURI_Pin(t *testing.T) {
 	}
 }
 
+func TestPinValue(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pin":
+			w.Write([]byte("https-pin\r\n"))
+		case "/big":
+			w.Write(bytes.Repeat([]byte("a"), maxPinSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tmp := pinSourceClient
+	t.Cleanup(func() {
+		pinSourceClient = tmp
+	})
+	pinSourceClient = srv.Client()
+
+	path, err := filepath.Abs("testdata/pin.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileURL := func(path string) string {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	}
+	maxPin := bytes.Repeat([]byte("a"), maxPinSize)
+	maxFile := filepath.Join(t.TempDir(), "max.txt")
+	if err := os.WriteFile(maxFile, maxPin, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bigFile := filepath.Join(t.TempDir(), "big.txt")
+	if err := os.WriteFile(bigFile, append(maxPin, 'a'), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mustParse := func(s string) *URI {
+		u, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name    string
+		uri     *URI
+		want    []byte
+		wantErr bool
+	}{
+		{"ok value", mustParse("pkcs11:id=%72%73?pin-value=0123456789"), []byte("0123456789"), false},
+		{"ok value first", mustParse("pkcs11:id=%72%73?pin-value=0123456789&pin-source=testdata/pin.txt"), []byte("0123456789"), false},
+		{"ok file path", mustParse("pkcs11:id=%72%73?pin-source=testdata/pin.txt"), []byte("trim-this-pin"), false},
+		{"ok file url", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(fileURL(path))), []byte("trim-this-pin"), false},
+		{"ok https", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(srv.URL+"/pin")), []byte("https-pin"), false},
+		{"ok file max size", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(fileURL(maxFile))), maxPin, false},
+		{"ok missing", mustParse("pkcs11:id=%72%73"), nil, false},
+		{"fail file missing", mustParse("pkcs11:id=%72%73?pin-source=testdata/foo.txt"), nil, true},
+		{"fail file too big", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(fileURL(bigFile))), nil, true},
+		{"fail https too big", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(srv.URL+"/big")), nil, true},
+		{"fail https not found", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape(srv.URL+"/missing")), nil, true},
+		{"fail http", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape("http://example.com/pin")), nil, true},
+		{"fail scheme", mustParse("pkcs11:id=%72%73?pin-source=" + url.QueryEscape("ftp://example.com/pin")), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PinValue(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PinValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PinValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestURI_String(t *testing.T) {
 	mustParse := func(s string) *URI {
 		u, err := Parse(s)
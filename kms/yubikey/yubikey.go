@@ -12,6 +12,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-piv/piv-go/piv"
 	"github.com/pkg/errors"
@@ -31,6 +33,8 @@ type YubiKey struct {
 	yk            pivKey
 	pin           string
 	managementKey [24]byte
+	closed        sync.Once
+	done          uint32
 }
 
 type pivKey interface {
@@ -151,6 +155,9 @@ func init() {
 // LoadCertificate implements kms.CertificateManager and loads a certificate
 // from the YubiKey.
 func (k *YubiKey) LoadCertificate(req *apiv1.LoadCertificateRequest) (*x509.Certificate, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	slot, err := getSlot(req.Name)
 	if err != nil {
 		return nil, err
@@ -167,6 +174,9 @@ func (k *YubiKey) LoadCertificate(req *apiv1.LoadCertificateRequest) (*x509.Cert
 // StoreCertificate implements kms.CertificateManager and stores a certificate
 // in the YubiKey.
 func (k *YubiKey) StoreCertificate(req *apiv1.StoreCertificateRequest) error {
+	if err := k.checkClosed(); err != nil {
+		return err
+	}
 	if req.Certificate == nil {
 		return errors.New("storeCertificateRequest 'Certificate' cannot be nil")
 	}
@@ -186,6 +196,9 @@ func (k *YubiKey) StoreCertificate(req *apiv1.StoreCertificateRequest) error {
 
 // GetPublicKey returns the public key present in the YubiKey signature slot.
 func (k *YubiKey) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	slot, err := getSlot(req.Name)
 	if err != nil {
 		return nil, err
@@ -201,6 +214,12 @@ func (k *YubiKey) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey
 
 // CreateKey generates a new key in the YubiKey and returns the public key.
 func (k *YubiKey) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
+	if req.PublicExponent != 0 && req.PublicExponent != 65537 {
+		return nil, errors.Errorf("yubikey does not support public exponent %d", req.PublicExponent)
+	}
 	alg, err := getSignatureAlgorithm(req.SignatureAlgorithm, req.Bits)
 	if err != nil {
 		return nil, err
@@ -231,16 +250,19 @@ func (k *YubiKey) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespon
 // CreateSigner creates a signer using the key present in the YubiKey signature
 // slot.
 func (k *YubiKey) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	slot, err := getSlot(req.SigningKey)
 	if err != nil {
 		return nil, err
 	}
 
+	// A pin in the key uri takes precedence over the configured one.
 	pin := k.pin
-	if pin == "" {
-		// Attempt to get the pin from the uri
-		if u, err := uri.ParseWithScheme(Scheme, req.SigningKey); err == nil {
-			pin = u.Pin()
+	if u, err := uri.ParseWithScheme(Scheme, req.SigningKey); err == nil {
+		if v := u.Pin(); v != "" {
+			pin = v
 		}
 	}
 
@@ -267,16 +289,19 @@ func (k *YubiKey) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, e
 // CreateDecrypter creates a crypto.Decrypter using the key present in the configured
 // Yubikey slot.
 func (k *YubiKey) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Decrypter, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	slot, err := getSlot(req.DecryptionKey)
 	if err != nil {
 		return nil, err
 	}
 
+	// A pin in the key uri takes precedence over the configured one.
 	pin := k.pin
-	if pin == "" {
-		// Attempt to get the pin from the uri
-		if u, err := uri.ParseWithScheme(Scheme, req.DecryptionKey); err == nil {
-			pin = u.Pin()
+	if u, err := uri.ParseWithScheme(Scheme, req.DecryptionKey); err == nil {
+		if v := u.Pin(); v != "" {
+			pin = v
 		}
 	}
 
@@ -307,6 +332,9 @@ func (k *YubiKey) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Dec
 // Notice: This API is EXPERIMENTAL and may be changed or removed in a later
 // release.
 func (k *YubiKey) CreateAttestation(req *apiv1.CreateAttestationRequest) (*apiv1.CreateAttestationResponse, error) {
+	if err := k.checkClosed(); err != nil {
+		return nil, err
+	}
 	slot, err := getSlot(req.Name)
 	if err != nil {
 		return nil, err
@@ -330,9 +358,34 @@ func (k *YubiKey) CreateAttestation(req *apiv1.CreateAttestationRequest) (*apiv1
 	}, nil
 }
 
-// Close releases the connection to the YubiKey.
-func (k *YubiKey) Close() error {
-	return errors.Wrap(k.yk.Close(), "error closing yubikey")
+// Close releases the connection to the YubiKey. It is safe to call Close
+// multiple times, but only the first call will close the connection. After
+// Close the YubiKey operations will return an error wrapping apiv1.ErrClosed.
+func (k *YubiKey) Close() (err error) {
+	k.closed.Do(func() {
+		atomic.StoreUint32(&k.done, 1)
+		err = errors.Wrap(k.yk.Close(), "error closing yubikey")
+	})
+	return
+}
+
+func (k *YubiKey) checkClosed() error {
+	if atomic.LoadUint32(&k.done) == 1 {
+		return errors.Wrap(apiv1.ErrClosed, "error using yubikey")
+	}
+	return nil
+}
+
+// Capabilities returns the operations supported by the YubiKey.
+func (k *YubiKey) Capabilities() apiv1.Capabilities {
+	return apiv1.Capabilities{
+		CreateKey:         true,
+		CreateSigner:      true,
+		CreateDecrypter:   true,
+		LoadCertificate:   true,
+		StoreCertificate:  true,
+		CreateAttestation: true,
+	}
 }
 
 // getPublicKey returns the public key on a slot. First it attempts to do
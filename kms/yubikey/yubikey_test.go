@@ -32,6 +32,7 @@ type stubPivKey struct {
 	certMap       map[piv.Slot]*x509.Certificate
 	signerMap     map[piv.Slot]interface{}
 	keyOptionsMap map[piv.Slot]piv.Key
+	closed        int
 }
 
 type symmetricAlgorithm int
@@ -212,6 +213,7 @@ func (s *stubPivKey) Attest(slot piv.Slot) (*x509.Certificate, error) {
 }
 
 func (s *stubPivKey) Close() error {
+	s.closed++
 	return nil
 }
 
@@ -854,6 +856,9 @@ func TestYubiKey_CreateSigner(t *testing.T) {
 		{"ok with pin", fields{yk, "", piv.DefaultManagementKey}, args{&apiv1.CreateSignerRequest{
 			SigningKey: "yubikey:slot-id=9c?pin-value=123456",
 		}}, yk.signerMap[piv.SlotSignature].(crypto.Signer), false},
+		{"ok with uri pin override", fields{yk, "654321", piv.DefaultManagementKey}, args{&apiv1.CreateSignerRequest{
+			SigningKey: "yubikey:slot-id=9c?pin-value=123456",
+		}}, yk.signerMap[piv.SlotSignature].(crypto.Signer), false},
 		{"fail getSlot", fields{yk, "123456", piv.DefaultManagementKey}, args{&apiv1.CreateSignerRequest{
 			SigningKey: "yubikey:slot-id=%%FF",
 		}}, nil, true},
@@ -916,6 +921,9 @@ func TestYubiKey_CreateDecrypter(t *testing.T) {
 		{"ok with pin", fields{yk, "", piv.DefaultManagementKey}, args{&apiv1.CreateDecrypterRequest{
 			DecryptionKey: "yubikey:slot-id=9c?pin-value=123456",
 		}}, yk.signerMap[piv.SlotSignature].(crypto.Decrypter), false},
+		{"ok with uri pin override", fields{yk, "654321", piv.DefaultManagementKey}, args{&apiv1.CreateDecrypterRequest{
+			DecryptionKey: "yubikey:slot-id=9c?pin-value=123456",
+		}}, yk.signerMap[piv.SlotSignature].(crypto.Decrypter), false},
 		{"fail getSlot", fields{yk, "123456", piv.DefaultManagementKey}, args{&apiv1.CreateDecrypterRequest{
 			DecryptionKey: "yubikey:slot-id=%%FF",
 		}}, nil, true},
@@ -1038,6 +1046,64 @@ func TestYubiKey_Close(t *testing.T) {
 	}
 }
 
+func TestYubiKey_closed(t *testing.T) {
+	yk := newStubPivKey(t, ECDSA)
+	k := &YubiKey{
+		yk:            yk,
+		pin:           "123456",
+		managementKey: piv.DefaultManagementKey,
+	}
+	for i := 0; i < 2; i++ {
+		if err := k.Close(); err != nil {
+			t.Fatalf("YubiKey.Close() error = %v", err)
+		}
+		if yk.closed != 1 {
+			t.Fatalf("pivKey.Close() called %d times, want 1", yk.closed)
+		}
+	}
+
+	name := "yubikey:slot-id=9c"
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"LoadCertificate", func() error {
+			_, err := k.LoadCertificate(&apiv1.LoadCertificateRequest{Name: name})
+			return err
+		}},
+		{"StoreCertificate", func() error {
+			return k.StoreCertificate(&apiv1.StoreCertificateRequest{Name: name, Certificate: &x509.Certificate{}})
+		}},
+		{"GetPublicKey", func() error {
+			_, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateKey", func() error {
+			_, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name})
+			return err
+		}},
+		{"CreateSigner", func() error {
+			_, err := k.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: name})
+			return err
+		}},
+		{"CreateDecrypter", func() error {
+			_, err := k.CreateDecrypter(&apiv1.CreateDecrypterRequest{DecryptionKey: name})
+			return err
+		}},
+		{"CreateAttestation", func() error {
+			_, err := k.CreateAttestation(&apiv1.CreateAttestationRequest{Name: name})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, apiv1.ErrClosed) {
+				t.Errorf("YubiKey.%s() error = %v, want apiv1.ErrClosed", tt.name, err)
+			}
+		})
+	}
+}
+
 func Test_getSerialNumber(t *testing.T) {
 	serialNumber, err := asn1.Marshal(112233)
 	if err != nil {
@@ -22,7 +22,8 @@ type CA struct {
 }
 
 // New creates a new MiniCA, the custom options allows to overwrite templates,
-// signer types and certificate names.
+// signer types and certificate names. The intermediate signer, used to sign
+// leaf certificates, can be a KMS signer using WithKMS.
 func New(opts ...Option) (*CA, error) {
 	now := time.Now()
 	o := newOptions().apply(opts)
@@ -51,7 +52,11 @@ func New(opts ...Option) (*CA, error) {
 
 	// Create intermediate
 	intSubject := o.Name + " Intermediate CA"
-	intSigner, err := o.GetSigner()
+	getIntermediateSigner := o.GetSigner
+	if o.GetIntermediateSigner != nil {
+		getIntermediateSigner = o.GetIntermediateSigner
+	}
+	intSigner, err := getIntermediateSigner()
 	if err != nil {
 		return nil, err
 	}
@@ -1,18 +1,25 @@
 package minica
 
 import (
+	"context"
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
 	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/softkms"
+	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
 	"golang.org/x/crypto/ssh"
 )
@@ -164,6 +171,62 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_withKMS(t *testing.T) {
+	intSigner, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := pemutil.Serialize(intSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "intermediate.key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	km, err := softkms.New(context.Background(), apiv1.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := New(WithKMS(km, keyFile))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !reflect.DeepEqual(ca.Intermediate.PublicKey, intSigner.Public()) {
+		t.Errorf("CA.Intermediate.PublicKey = %v, want %v", ca.Intermediate.PublicKey, intSigner.Public())
+	}
+
+	leafSigner, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ca.Sign(&x509.Certificate{
+		DNSNames:  []string{"leaf.test.com"},
+		PublicKey: leafSigner.Public(),
+	})
+	if err != nil {
+		t.Fatalf("CA.Sign() error = %v", err)
+	}
+	if err := leaf.CheckSignatureFrom(ca.Intermediate); err != nil {
+		t.Errorf("Certificate.CheckSignatureFrom() error = %v", err)
+	}
+
+	// Any crypto.Signer can be used as the intermediate signer.
+	ca, err = New(WithIntermediateSigner(leafSigner))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !reflect.DeepEqual(ca.Intermediate.PublicKey, leafSigner.Public()) {
+		t.Errorf("CA.Intermediate.PublicKey = %v, want %v", ca.Intermediate.PublicKey, leafSigner.Public())
+	}
+
+	if _, err := New(WithKMS(km, filepath.Join(t.TempDir(), "missing.key"))); err == nil {
+		t.Error("New() error = nil, want error")
+	}
+}
+
 func TestCA_Sign(t *testing.T) {
 	signer, err := keyutil.GenerateDefaultSigner()
 	if err != nil {
@@ -5,14 +5,16 @@ import (
 	"crypto/x509"
 
 	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/x509util"
 )
 
 type options struct {
-	Name                 string
-	RootTemplate         string
-	IntermediateTemplate string
-	GetSigner            func() (crypto.Signer, error)
+	Name                  string
+	RootTemplate          string
+	IntermediateTemplate  string
+	GetSigner             func() (crypto.Signer, error)
+	GetIntermediateSigner func() (crypto.Signer, error)
 }
 
 // Option is the type used to pass custom attributes to the constructor.
@@ -67,6 +69,33 @@ func WithGetSignerFunc(fn func() (crypto.Signer, error)) Option {
 	}
 }
 
+// WithIntermediateSigner is an option that allows to set the signer used by the
+// intermediate certificate, this signer will be used to sign the leaf
+// certificates. The signer can be any crypto.Signer, including the ones
+// created by a KMS.
+func WithIntermediateSigner(signer crypto.Signer) Option {
+	return func(o *options) {
+		o.GetIntermediateSigner = func() (crypto.Signer, error) {
+			return signer, nil
+		}
+	}
+}
+
+// WithKMS is an option that allows to use a key in the given KMS as the
+// intermediate signer. The name is the key name used in the KMS, e.g. a PKCS#11
+// or an Azure Key Vault URI, and it will be used to create a signer using
+// km.CreateSigner. This allows to have an intermediate key that never leaves
+// the KMS.
+func WithKMS(km apiv1.KeyManager, name string) Option {
+	return func(o *options) {
+		o.GetIntermediateSigner = func() (crypto.Signer, error) {
+			return km.CreateSigner(&apiv1.CreateSignerRequest{
+				SigningKey: name,
+			})
+		}
+	}
+}
+
 type signOptions struct {
 	Template string
 	Modify   func(*x509.Certificate) error
@@ -0,0 +1,137 @@
+package pemutil
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Bundle is a list of X.509 certificates, usually a certificate chain.
+type Bundle []*x509.Certificate
+
+// ReadBundle returns the bundle with the certificates in the given filename. It
+// supports PEM and DER formats, see ReadCertificateBundle.
+func ReadBundle(filename string) (Bundle, error) {
+	certs, err := ReadCertificateBundle(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Bundle(certs), nil
+}
+
+// Bytes returns the PEM encoding of the certificates in the bundle.
+func (b Bundle) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, crt := range b {
+		buf.Write(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: crt.Raw,
+		}))
+	}
+	return buf.Bytes()
+}
+
+// Write writes the PEM encoding of the certificates in the bundle to the given
+// filename using the WriteFile method.
+func (b Bundle) Write(filename string, perm os.FileMode) error {
+	if len(b) == 0 {
+		return errors.New("error writing bundle: bundle is empty")
+	}
+	return WriteFile(filename, b.Bytes(), perm)
+}
+
+// Sort sorts the certificates in the bundle in place, starting with the leaf
+// and finishing with the root or the last intermediate. Sort fails if the
+// certificates in the bundle do not form a single chain.
+func (b Bundle) Sort() error {
+	if len(b) < 2 {
+		return nil
+	}
+
+	// The leaf is the only certificate that has not issued any other
+	// certificate in the bundle.
+	var leaves []int
+	for i, crt := range b {
+		isLeaf := true
+		for j, child := range b {
+			if i != j && isIssuer(crt, child) {
+				isLeaf = false
+				break
+			}
+		}
+		if isLeaf {
+			leaves = append(leaves, i)
+		}
+	}
+	if len(leaves) != 1 {
+		return errors.Errorf("error sorting bundle: bundle has %d leaf certificates", len(leaves))
+	}
+
+	used := make([]bool, len(b))
+	sorted := make(Bundle, 0, len(b))
+	sorted = append(sorted, b[leaves[0]])
+	used[leaves[0]] = true
+	for len(sorted) < len(b) {
+		crt := sorted[len(sorted)-1]
+		next := -1
+		for i, parent := range b {
+			if !used[i] && isIssuer(parent, crt) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return errors.Errorf("error sorting bundle: issuer of %q not found", crt.Subject)
+		}
+		sorted = append(sorted, b[next])
+		used[next] = true
+	}
+
+	copy(b, sorted)
+	return nil
+}
+
+// Verify verifies the first certificate in the bundle, using the rest of the
+// certificates as intermediates, and returns the chains built up to the given
+// roots. If roots is nil, the system roots are used. Use Sort to place the
+// leaf first.
+//
+// The revocation status of the certificates is not checked, use
+// x509util.VerifyChainsWithRevocation with the first certificate and the
+// VerifyOptions of the bundle to check it.
+func (b Bundle) Verify(roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(b) == 0 {
+		return nil, errors.New("error verifying bundle: bundle is empty")
+	}
+	chains, err := b[0].Verify(b.VerifyOptions(roots))
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying bundle")
+	}
+	return chains, nil
+}
+
+// VerifyOptions returns the x509.VerifyOptions used by Verify, with the given
+// roots and the certificates in the bundle but the first one as
+// intermediates.
+func (b Bundle) VerifyOptions(roots *x509.CertPool) x509.VerifyOptions {
+	intermediates := x509.NewCertPool()
+	if len(b) > 1 {
+		for _, crt := range b[1:] {
+			intermediates.AddCert(crt)
+		}
+	}
+	return x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+}
+
+// isIssuer returns true if parent has issued the child certificate.
+func isIssuer(parent, child *x509.Certificate) bool {
+	return bytes.Equal(child.RawIssuer, parent.RawSubject) &&
+		child.CheckSignatureFrom(parent) == nil
+}
@@ -0,0 +1,118 @@
+package pemutil
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func mustBundleChain(t *testing.T) (leaf, intermediate, root *x509.Certificate) {
+	t.Helper()
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	leaf, err = ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "leaf.example.com"},
+		DNSNames:  []string{"leaf.example.com"},
+		PublicKey: signer.Public(),
+	})
+	assert.FatalError(t, err)
+	return leaf, ca.Intermediate, ca.Root
+}
+
+func TestBundle_Sort(t *testing.T) {
+	leaf, intermediate, root := mustBundleChain(t)
+	otherLeaf, _, _ := mustBundleChain(t)
+
+	tests := []struct {
+		name    string
+		bundle  Bundle
+		want    Bundle
+		wantErr bool
+	}{
+		{"ok sorted", Bundle{leaf, intermediate, root}, Bundle{leaf, intermediate, root}, false},
+		{"ok out of order", Bundle{root, leaf, intermediate}, Bundle{leaf, intermediate, root}, false},
+		{"ok reversed", Bundle{root, intermediate, leaf}, Bundle{leaf, intermediate, root}, false},
+		{"ok without root", Bundle{intermediate, leaf}, Bundle{leaf, intermediate}, false},
+		{"ok one", Bundle{leaf}, Bundle{leaf}, false},
+		{"ok empty", Bundle{}, Bundle{}, false},
+		{"fail missing intermediate", Bundle{root, leaf}, Bundle{root, leaf}, true},
+		{"fail two leaves", Bundle{leaf, otherLeaf, intermediate}, Bundle{leaf, otherLeaf, intermediate}, true},
+		{"fail duplicated", Bundle{leaf, intermediate, intermediate}, Bundle{leaf, intermediate, intermediate}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bundle.Sort()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equals(t, tt.want, tt.bundle)
+		})
+	}
+}
+
+func TestBundle_Verify(t *testing.T) {
+	leaf, intermediate, root := mustBundleChain(t)
+	_, _, otherRoot := mustBundleChain(t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherRoot)
+
+	tests := []struct {
+		name    string
+		bundle  Bundle
+		roots   *x509.CertPool
+		want    [][]*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", Bundle{leaf, intermediate}, roots, [][]*x509.Certificate{{leaf, intermediate, root}}, false},
+		{"ok with root", Bundle{leaf, intermediate, root}, roots, [][]*x509.Certificate{{leaf, intermediate, root}}, false},
+		{"fail empty", Bundle{}, roots, nil, true},
+		{"fail missing intermediate", Bundle{leaf}, roots, nil, true},
+		{"fail other root", Bundle{leaf, intermediate}, otherRoots, nil, true},
+		{"ok not sorted verifies first", Bundle{intermediate, leaf}, roots, [][]*x509.Certificate{{intermediate, root}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.bundle.Verify(tt.roots)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestBundle_ReadWrite(t *testing.T) {
+	leaf, intermediate, root := mustBundleChain(t)
+	fn := filepath.Join(t.TempDir(), "bundle.crt")
+
+	bundle := Bundle{root, leaf, intermediate}
+	assert.FatalError(t, bundle.Sort())
+	assert.FatalError(t, bundle.Write(fn, 0600))
+
+	got, err := ReadBundle(fn)
+	assert.FatalError(t, err)
+	assert.Equals(t, Bundle{leaf, intermediate, root}, got)
+
+	b, err := os.ReadFile(fn)
+	assert.FatalError(t, err)
+	assert.Equals(t, bundle.Bytes(), b)
+
+	assert.Error(t, Bundle{}.Write(fn, 0600))
+	_, err = ReadBundle("testdata/notexists.crt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package pemutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x25519"
+)
+
+var (
+	oidPublicKeyX25519  = asn1.ObjectIdentifier{1, 3, 101, 110}
+	oidPublicKeyEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// oneAsymmetricKey reflects an ASN.1, PKCS#8 v2 OneAsymmetricKey, the PKCS#8
+// PrivateKey with an optional public key. See RFC 5958 and RFC 8410.
+type oneAsymmetricKey struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+	Attributes asn1.RawValue  `asn1:"optional,tag:0"`
+	PublicKey  asn1.BitString `asn1:"optional,tag:1"`
+}
+
+// parsePKCS8PrivateKey parses an unencrypted PKCS#8 private key like
+// x509.ParsePKCS8PrivateKey, but Ed25519 and X25519 keys are returned as an
+// ed25519.PrivateKey or an x25519.PrivateKey.
+//
+// Both algorithms use the same 32 bytes private keys, and some producers use
+// the OID of one algorithm with keys of the other. If the PKCS#8 contains the
+// public key, it is used to detect the algorithm of the key, if not, the
+// algorithm is the one in the OID.
+func parsePKCS8PrivateKey(der []byte) (crypto.PrivateKey, error) {
+	var key oneAsymmetricKey
+	if _, err := asn1.Unmarshal(der, &key); err != nil ||
+		(!key.Algo.Algorithm.Equal(oidPublicKeyX25519) && !key.Algo.Algorithm.Equal(oidPublicKeyEd25519)) {
+		return x509.ParsePKCS8PrivateKey(der)
+	}
+
+	if len(key.Algo.Parameters.FullBytes) != 0 {
+		return nil, errors.New("invalid Curve25519 private key parameters")
+	}
+	var seed []byte
+	if _, err := asn1.Unmarshal(key.PrivateKey, &seed); err != nil {
+		return nil, errors.Wrap(err, "invalid Curve25519 private key")
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.Errorf("invalid Curve25519 private key length: %d", len(seed))
+	}
+
+	edKey := ed25519.NewKeyFromSeed(seed)
+	xKey := x25519.PrivateKey(seed)
+	if pub := key.PublicKey.RightAlign(); len(pub) > 0 {
+		xPub, err := xKey.PublicKey()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid Curve25519 private key")
+		}
+		switch {
+		case bytes.Equal(pub, edKey.Public().(ed25519.PublicKey)):
+			return edKey, nil
+		case bytes.Equal(pub, xPub):
+			return xKey, nil
+		default:
+			return nil, errors.New("invalid Curve25519 private key: public key does not match")
+		}
+	}
+
+	if key.Algo.Algorithm.Equal(oidPublicKeyEd25519) {
+		return edKey, nil
+	}
+	return xKey, nil
+}
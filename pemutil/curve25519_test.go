@@ -0,0 +1,98 @@
+package pemutil
+
+import (
+	"crypto/ed25519"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/x25519"
+)
+
+func mustPEMBytes(t *testing.T, filename string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filename)
+	assert.FatalError(t, err)
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatalf("error decoding %s", filename)
+	}
+	return block.Bytes
+}
+
+func Test_parsePKCS8PrivateKey_curve25519(t *testing.T) {
+	edKey, err := Read("testdata/pkcs8/openssl.ed25519.pem")
+	assert.FatalError(t, err)
+	xKey, err := Read("testdata/pkcs8/openssl.x25519.pem")
+	assert.FatalError(t, err)
+
+	// X25519 keys with the Ed25519 OID, and the other way around, without the
+	// public key use the algorithm in the OID.
+	xWithEdOID := mustPEMBytes(t, "testdata/pkcs8/openssl.x25519.pem")
+	xWithEdOID[11] = 0x70
+	edWithXOID := mustPEMBytes(t, "testdata/pkcs8/openssl.ed25519.pem")
+	edWithXOID[11] = 0x6e
+
+	tests := []struct {
+		name string
+		der  []byte
+		want interface{}
+	}{
+		{"ed25519", mustPEMBytes(t, "testdata/pkcs8/openssl.ed25519.pem"), edKey},
+		{"x25519", mustPEMBytes(t, "testdata/pkcs8/openssl.x25519.pem"), xKey},
+		{"ed25519 with x25519 oid and public key", mustPEMBytes(t, "testdata/pkcs8/ed25519.x25519-oid.pem"), edKey},
+		{"x25519 with ed25519 oid and public key", mustPEMBytes(t, "testdata/pkcs8/x25519.ed25519-oid.pem"), xKey},
+		{"ed25519 seed with ed25519 oid", xWithEdOID, ed25519.NewKeyFromSeed(xKey.(x25519.PrivateKey))},
+		{"x25519 seed with x25519 oid", edWithXOID, x25519.PrivateKey(edKey.(ed25519.PrivateKey).Seed())},
+		{"p256", mustPEMBytes(t, "testdata/pkcs8/openssl.p256.pem"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePKCS8PrivateKey(tt.der)
+			assert.FatalError(t, err)
+			if tt.want != nil {
+				assert.Equals(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_parsePKCS8PrivateKey_curve25519_fail(t *testing.T) {
+	mismatch := mustPEMBytes(t, "testdata/pkcs8/x25519.ed25519-oid.pem")
+	mismatch[len(mismatch)-1] ^= 0xff
+	badLength := []byte{
+		0x30, 0x0d, 0x02, 0x01, 0x00, 0x30, 0x05, 0x06, 0x03, 0x2b, 0x65, 0x70,
+		0x04, 0x01, 0x00,
+	}
+	badSeed := mustPEMBytes(t, "testdata/pkcs8/openssl.ed25519.pem")
+	badSeed[14] = 0x05
+	badSeedLength := []byte{
+		0x30, 0x0f, 0x02, 0x01, 0x00, 0x30, 0x05, 0x06, 0x03, 0x2b, 0x65, 0x70,
+		0x04, 0x03, 0x04, 0x01, 0x00,
+	}
+	withParams := []byte{
+		0x30, 0x30, 0x02, 0x01, 0x00, 0x30, 0x07, 0x06, 0x03, 0x2b, 0x65, 0x70, 0x05, 0x00,
+		0x04, 0x22, 0x04, 0x20,
+	}
+	withParams = append(withParams, make([]byte, 32)...)
+
+	tests := []struct {
+		name string
+		der  []byte
+	}{
+		{"fail public key", mismatch},
+		{"fail private key", badLength},
+		{"fail seed length", badSeedLength},
+		{"fail inner octet string", badSeed},
+		{"fail parameters", withParams},
+		{"fail asn1", []byte("not a key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePKCS8PrivateKey(tt.der)
+			assert.Error(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package pemutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x25519"
+)
+
+// KeyPair is a certificate and the private key of its public key.
+type KeyPair struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.PrivateKey
+}
+
+// MatchKeyToCerts returns the first certificate in certs with the public key of
+// the given private key. It returns false if none of the certificates match or
+// if key is not a supported private key.
+func MatchKeyToCerts(key crypto.PrivateKey, certs []*x509.Certificate) (*x509.Certificate, bool) {
+	pub, ok := publicKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+	for _, crt := range certs {
+		if crt != nil && keyutil.PublicKeysEqual(pub, crt.PublicKey) {
+			return crt, true
+		}
+	}
+	return nil, false
+}
+
+// MatchKeysToCerts pairs each of the given private keys with the first
+// certificate in certs with the same public key, e.g. to load the keys and
+// certificates in a directory. Keys without a certificate are not returned, and
+// the pairs are returned in the same order as the keys.
+func MatchKeysToCerts(keys []crypto.PrivateKey, certs []*x509.Certificate) []KeyPair {
+	var pairs []KeyPair
+	for _, key := range keys {
+		if crt, ok := MatchKeyToCerts(key, certs); ok {
+			pairs = append(pairs, KeyPair{
+				Certificate: crt,
+				PrivateKey:  key,
+			})
+		}
+	}
+	return pairs
+}
+
+// publicKeyOf returns the public key of the given private key. Public keys are
+// not private keys and return false.
+func publicKeyOf(key crypto.PrivateKey) (crypto.PublicKey, bool) {
+	switch key.(type) {
+	case nil, *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, x25519.PublicKey:
+		return nil, false
+	}
+	pub, err := keyutil.PublicKey(key)
+	return pub, err == nil
+}
@@ -0,0 +1,116 @@
+package pemutil
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func mustKeyPair(t *testing.T, ca *minica.CA, kty, crv string, size int) (crypto.Signer, *x509.Certificate) {
+	t.Helper()
+	signer, err := keyutil.GenerateSigner(kty, crv, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "leaf.example.com"},
+		PublicKey: signer.Public(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, crt
+}
+
+func TestMatchKeyToCerts(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, ecCert := mustKeyPair(t, ca, "EC", "P-256", 0)
+	rsaKey, rsaCert := mustKeyPair(t, ca, "RSA", "", 2048)
+	edKey, edCert := mustKeyPair(t, ca, "OKP", "Ed25519", 0)
+	otherKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := []*x509.Certificate{ecCert, rsaCert, edCert, ca.Intermediate, ca.Root}
+
+	type args struct {
+		key   crypto.PrivateKey
+		certs []*x509.Certificate
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   *x509.Certificate
+		wantOK bool
+	}{
+		{"ok ec", args{ecKey, certs}, ecCert, true},
+		{"ok rsa", args{rsaKey, certs}, rsaCert, true},
+		{"ok ed25519", args{edKey, certs}, edCert, true},
+		{"ok nil cert", args{ecKey, []*x509.Certificate{nil, ecCert}}, ecCert, true},
+		{"fail other key", args{otherKey, certs}, nil, false},
+		{"fail no certs", args{ecKey, nil}, nil, false},
+		{"fail public key", args{ecKey.Public(), certs}, nil, false},
+		{"fail nil key", args{nil, certs}, nil, false},
+		{"fail unsupported key", args{"foo", certs}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOK := MatchKeyToCerts(tt.args.key, tt.args.certs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchKeyToCerts() got = %v, want %v", got, tt.want)
+			}
+			if gotOK != tt.wantOK {
+				t.Errorf("MatchKeyToCerts() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatchKeysToCerts(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, ecCert := mustKeyPair(t, ca, "EC", "P-256", 0)
+	_, rsaCert := mustKeyPair(t, ca, "RSA", "", 2048)
+	edKey, _ := mustKeyPair(t, ca, "OKP", "Ed25519", 0)
+	otherKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		keys  []crypto.PrivateKey
+		certs []*x509.Certificate
+	}
+	tests := []struct {
+		name string
+		args args
+		want []KeyPair
+	}{
+		{"ok one pair", args{
+			[]crypto.PrivateKey{otherKey, edKey, ecKey},
+			[]*x509.Certificate{ca.Root, rsaCert, ecCert, ca.Intermediate},
+		}, []KeyPair{{Certificate: ecCert, PrivateKey: ecKey}}},
+		{"ok no pairs", args{
+			[]crypto.PrivateKey{otherKey, edKey},
+			[]*x509.Certificate{rsaCert, ecCert},
+		}, nil},
+		{"ok empty", args{nil, nil}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchKeysToCerts(tt.args.keys, tt.args.certs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchKeysToCerts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
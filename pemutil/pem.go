@@ -5,6 +5,7 @@ package pemutil
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -15,6 +16,7 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/internal/utils"
@@ -53,8 +55,12 @@ type context struct {
 	openSSH          bool
 	comment          string
 	firstBlock       bool
+	blockType        string
 	passwordPrompt   string
 	passwordPrompter PasswordPrompter
+	pkcs8Cipher      PKCS8Cipher
+	kdfIterations    int
+	encrypted        *bool
 }
 
 // newContext initializes the context with a filename.
@@ -75,8 +81,12 @@ func (c *context) apply(opts []Options) error {
 	return nil
 }
 
-// promptPassword returns the password or prompts for one.
+// promptPassword returns the password or prompts for one. It also records
+// that the data being parsed is encrypted.
 func (c *context) promptPassword() ([]byte, error) {
+	if c.encrypted != nil {
+		*c.encrypted = true
+	}
 	switch {
 	case len(c.password) > 0:
 		return c.password, nil
@@ -104,6 +114,18 @@ func (c *context) promptEncryptPassword() ([]byte, error) {
 	}
 }
 
+// PKCS8Cipher is the cipher used by SerializeEncrypted to encrypt PKCS#8
+// private keys.
+type PKCS8Cipher int
+
+const (
+	// AES256CBC encrypts the private key using AES-256 in CBC mode. This is
+	// the default.
+	AES256CBC PKCS8Cipher = iota
+	// AES256GCM encrypts the private key using AES-256 in GCM mode.
+	AES256GCM
+)
+
 // Options is the type to add attributes to the context.
 type Options func(o *context) error
 
@@ -194,6 +216,33 @@ func WithComment(comment string) Options {
 	}
 }
 
+// WithPKCS8Cipher is an option used in the SerializeEncrypted method to select
+// the cipher used to encrypt the private key.
+func WithPKCS8Cipher(c PKCS8Cipher) Options {
+	return func(ctx *context) error {
+		switch c {
+		case AES256CBC, AES256GCM:
+			ctx.pkcs8Cipher = c
+			return nil
+		default:
+			return errors.Errorf("unsupported PKCS#8 cipher %d", c)
+		}
+	}
+}
+
+// WithKDFIterations is an option used in the SerializeEncrypted method to set
+// the number of PBKDF2 iterations used to derive the encryption key. By
+// default PBKDF2Iterations is used.
+func WithKDFIterations(n int) Options {
+	return func(ctx *context) error {
+		if n <= 0 {
+			return errors.Errorf("invalid number of KDF iterations %d", n)
+		}
+		ctx.kdfIterations = n
+		return nil
+	}
+}
+
 // WithFirstBlock will avoid failing if a PEM contains more than one block or
 // certificate and it will only look at the first.
 func WithFirstBlock() Options {
@@ -203,6 +252,16 @@ func WithFirstBlock() Options {
 	}
 }
 
+// WithBlockType will make Parse and Read use the first PEM block with the given
+// type, e.g. "CERTIFICATE" or "PRIVATE KEY", ignoring any other block. It will
+// fail if the PEM does not contain a block with the given type.
+func WithBlockType(typ string) Options {
+	return func(ctx *context) error {
+		ctx.blockType = typ
+		return nil
+	}
+}
+
 // ParseCertificate extracts the first certificate from the given pem.
 func ParseCertificate(pemData []byte) (*x509.Certificate, error) {
 	var block *pem.Block
@@ -282,7 +341,7 @@ func ReadCertificate(filename string, opts ...Options) (*x509.Certificate, error
 	}
 
 	// PEM format
-	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
+	if bytes.HasPrefix(normalize(b), []byte("-----BEGIN ")) {
 		var crt interface{}
 		crt, err = Read(filename, opts...)
 		if err != nil {
@@ -304,73 +363,110 @@ func ReadCertificate(filename string, opts ...Options) (*x509.Certificate, error
 // ReadCertificateBundle returns a list of *x509.Certificate from the given
 // filename. It supports certificates formats PEM and DER. If a DER-formatted
 // file is given only one certificate will be returned.
+//
+// PEM files can contain comments, blank lines, or any other text between the
+// PEM blocks, and blocks other than certificates, like private keys, are
+// ignored. An error is returned if the file does not contain any certificate.
 func ReadCertificateBundle(filename string) ([]*x509.Certificate, error) {
+	certs, _, err := readCertificateBundle(filename, false, nil)
+	return certs, err
+}
+
+// ReadCertificateBundleWithKeys is like ReadCertificateBundle, but it also
+// returns the private keys in the PEM blocks of the file. The options are used
+// to parse the private keys, e.g. to set the password of encrypted keys.
+func ReadCertificateBundleWithKeys(filename string, opts ...Options) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	return readCertificateBundle(filename, true, opts)
+}
+
+func readCertificateBundle(filename string, withKeys bool, opts []Options) ([]*x509.Certificate, []crypto.PrivateKey, error) {
 	b, err := utils.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// PEM format
-	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
-		var block *pem.Block
-		var bundle []*x509.Certificate
-		for len(b) > 0 {
-			block, b = pem.Decode(b)
-			if block == nil {
-				break
-			}
-			if block.Type != "CERTIFICATE" {
-				return nil, errors.Errorf("error decoding PEM: file '%s' is not a certificate bundle", filename)
-			}
-			var crt *x509.Certificate
-			crt, err = x509.ParseCertificate(block.Bytes)
+	// DER format (binary)
+	if !bytes.Contains(b, []byte("-----BEGIN ")) {
+		crt, err := x509.ParseCertificate(b)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error parsing %s", filename)
+		}
+		return []*x509.Certificate{crt}, nil, nil
+	}
+
+	// PEM format, pem.Decode skips any text before a block.
+	var block *pem.Block
+	var bundle []*x509.Certificate
+	var keys []crypto.PrivateKey
+	for len(b) > 0 {
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			crt, err := x509.ParseCertificate(block.Bytes)
 			if err != nil {
-				return nil, errors.Wrapf(err, "error parsing %s", filename)
+				return nil, nil, errors.Wrapf(err, "error parsing %s", filename)
 			}
 			bundle = append(bundle, crt)
+		case withKeys && strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			key, err := ParseKey(pem.EncodeToMemory(block), append([]Options{WithFilename(filename)}, opts...)...)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
 		}
-		if len(b) > 0 {
-			return nil, errors.Errorf("error decoding PEM: file '%s' contains unexpected data", filename)
-		}
-		return bundle, nil
 	}
-
-	// DER format (binary)
-	crt, err := x509.ParseCertificate(b)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	// Text after the last block is allowed, but not a block that cannot be
+	// decoded.
+	if bytes.Contains(b, []byte("-----BEGIN ")) {
+		return nil, nil, errors.Errorf("error decoding PEM: file '%s' contains unexpected data", filename)
 	}
-	return []*x509.Certificate{crt}, nil
+	if len(bundle) == 0 {
+		return nil, nil, errors.Errorf("error decoding PEM: file '%s' is not a certificate bundle", filename)
+	}
+	return bundle, keys, nil
 }
 
 // ReadCertificateRequest returns a *x509.CertificateRequest from the given
-// filename. It supports certificates formats PEM and DER.
+// filename. It supports certificates formats PEM and DER. The signature of the
+// certificate request is validated, and an error is returned if it is not
+// valid.
 func ReadCertificateRequest(filename string) (*x509.CertificateRequest, error) {
 	b, err := utils.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	// PEM format
-	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
-		csr, err := Parse(b, WithFilename(filename))
+	var csr *x509.CertificateRequest
+	if bytes.HasPrefix(normalize(b), []byte("-----BEGIN ")) {
+		// PEM format
+		v, err := Parse(b, WithFilename(filename))
 		if err != nil {
 			return nil, err
 		}
-		switch csr := csr.(type) {
-		case *x509.CertificateRequest:
-			return csr, nil
-		default:
+		var ok bool
+		if csr, ok = v.(*x509.CertificateRequest); !ok {
 			return nil, errors.Errorf("error decoding PEM: file '%s' does not contain a certificate request", filename)
 		}
+	} else {
+		// DER format (binary)
+		if csr, err = x509.ParseCertificateRequest(b); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", filename)
+		}
 	}
 
-	// DER format (binary)
-	csr, err := x509.ParseCertificateRequest(b)
-	return csr, errors.Wrapf(err, "error parsing %s", filename)
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errors.Wrapf(err, "error validating %s: invalid certificate request signature", filename)
+	}
+	return csr, nil
 }
 
-// Parse returns the key or certificate PEM-encoded in the given bytes.
+// Parse returns the key or certificate PEM-encoded in the given bytes. The
+// bytes can start with a UTF-8 byte order mark or whitespace, and use CRLF line
+// endings. By default, Parse fails if the bytes contain more than one block;
+// use WithFirstBlock or WithBlockType to select one of them.
 func Parse(b []byte, opts ...Options) (interface{}, error) {
 	// Populate options
 	ctx := newContext("PEM")
@@ -378,10 +474,17 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 		return nil, err
 	}
 
-	block, rest := pem.Decode(b)
+	block, rest := pem.Decode(normalize(b))
 	switch {
 	case block == nil:
 		return nil, errors.Errorf("error decoding %s: not a valid PEM encoded block", ctx.filename)
+	case ctx.blockType != "":
+		for block != nil && block.Type != ctx.blockType {
+			block, rest = pem.Decode(rest)
+		}
+		if block == nil {
+			return nil, errors.Errorf("error decoding %s: does not contain a PEM encoded block of type '%s'", ctx.filename, ctx.blockType)
+		}
 	case len(bytes.TrimSpace(rest)) > 0 && !ctx.firstBlock:
 		return nil, errors.Errorf("error decoding %s: contains more than one PEM encoded block", ctx.filename)
 	}
@@ -403,17 +506,28 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 	case "PUBLIC KEY":
 		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
 		return pub, errors.Wrapf(err, "error parsing %s", ctx.filename)
+	case "RSA PUBLIC KEY":
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		return pub, errors.Wrapf(err, "error parsing %s", ctx.filename)
 	case "RSA PRIVATE KEY":
 		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
 	case "EC PRIVATE KEY":
 		priv, err := x509.ParseECPrivateKey(block.Bytes)
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
-	case "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
-		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		priv, err := parsePKCS8PrivateKey(block.Bytes)
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
+	case "ENCRYPTED PRIVATE KEY":
+		// The padding check in DecryptPKCS8PrivateKey cannot always detect an
+		// incorrect password, an unparsable key is also a wrong password.
+		priv, err := parsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(x509.IncorrectPasswordError, "error parsing %s", ctx.filename)
+		}
+		return priv, nil
 	case "OPENSSH PRIVATE KEY":
-		priv, err := ParseOpenSSHPrivateKey(b, withContext(ctx))
+		priv, err := ParseOpenSSHPrivateKey(pem.EncodeToMemory(block), withContext(ctx))
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
 	case "CERTIFICATE":
 		crt, err := x509.ParseCertificate(block.Bytes)
@@ -443,6 +557,19 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 	}
 }
 
+// utf8BOM is the byte order mark that some editors add at the beginning of
+// UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// normalize removes the byte order mark and leading whitespace from the given
+// PEM-encoded bytes, and replaces CRLF line endings with LF, so pem.Decode can
+// find the first block.
+func normalize(b []byte) []byte {
+	b = bytes.TrimPrefix(b, utf8BOM)
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	return bytes.TrimLeft(b, " \t\r\n")
+}
+
 // ParseKey returns the key or the public key of a certificate or certificate
 // signing request in the given PEM-encoded bytes.
 func ParseKey(b []byte, opts ...Options) (interface{}, error) {
@@ -460,17 +587,114 @@ func ParseKey(b []byte, opts ...Options) (interface{}, error) {
 // Supported keys algorithms are RSA and EC. Supported standards for private
 // keys are PKCS#1, PKCS#8, RFC5915 for EC, and base64-encoded DER for
 // certificates and public keys.
+//
+// If the file is not PEM-encoded, Read will try to parse it as a DER-encoded
+// certificate, public key or private key, in that order.
 func Read(filename string, opts ...Options) (interface{}, error) {
 	b, err := utils.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
+	// DER format (binary)
+	if !bytes.Contains(b, []byte("-----BEGIN ")) {
+		return parseCertificateOrKeyDER(b, filename)
+	}
+
 	// force given filename
 	opts = append(opts, WithFilename(filename))
 	return Parse(b, opts...)
 }
 
+// PrivateKeyInfo is the private key returned by ReadPrivateKey along with its
+// properties.
+type PrivateKeyInfo struct {
+	// Key is the private key, an *rsa.PrivateKey, *ecdsa.PrivateKey,
+	// ed25519.PrivateKey or x25519.PrivateKey.
+	Key crypto.PrivateKey
+	// Algorithm is the key algorithm, "RSA", "EC", "Ed25519" or "X25519".
+	Algorithm string
+	// Bits is the size of the RSA modulus or the size of the curve in bits.
+	Bits int
+	// Curve is the name of the curve for EC keys, e.g. "P-256". It is empty
+	// for other algorithms.
+	Curve string
+	// Encrypted is true if the key was protected with a password.
+	Encrypted bool
+}
+
+// ReadPrivateKey returns the private key encoded in the given file and its
+// algorithm, size, and whether it was encrypted. It accepts the same formats
+// and options as Read, but it fails if the file does not contain a private
+// key.
+func ReadPrivateKey(filename string, opts ...Options) (*PrivateKeyInfo, error) {
+	var encrypted bool
+	opts = append(opts, func(ctx *context) error {
+		ctx.encrypted = &encrypted
+		return nil
+	})
+	v, err := Read(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := newPrivateKeyInfo(v, encrypted)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	return info, nil
+}
+
+// ParsePrivateKey returns the private key PEM-encoded in the given bytes and
+// its algorithm, size, and whether it was encrypted. It accepts the same
+// options as Parse, but it fails if the bytes do not contain a private key.
+//
+// The Algorithm of the returned PrivateKeyInfo can be used to know if a
+// Curve25519 key is an Ed25519 or an X25519 key. PKCS#8 keys using the OID of
+// the other algorithm are detected using the public key, if present.
+func ParsePrivateKey(b []byte, opts ...Options) (*PrivateKeyInfo, error) {
+	var encrypted bool
+	opts = append(opts, func(ctx *context) error {
+		ctx.encrypted = &encrypted
+		return nil
+	})
+	v, err := Parse(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	info, err := newPrivateKeyInfo(v, encrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing private key")
+	}
+	return info, nil
+}
+
+// newPrivateKeyInfo returns the PrivateKeyInfo of the given key.
+func newPrivateKeyInfo(v interface{}, encrypted bool) (*PrivateKeyInfo, error) {
+	info := &PrivateKeyInfo{
+		Key:       v,
+		Encrypted: encrypted,
+	}
+	switch k := v.(type) {
+	case *rsa.PrivateKey:
+		info.Algorithm = "RSA"
+		info.Bits = k.N.BitLen()
+	case *ecdsa.PrivateKey:
+		info.Algorithm = "EC"
+		info.Bits = k.Curve.Params().BitSize
+		info.Curve = k.Curve.Params().Name
+	case ed25519.PrivateKey:
+		info.Algorithm = "Ed25519"
+		info.Bits = 256
+	case x25519.PrivateKey:
+		info.Algorithm = "X25519"
+		info.Bits = 256
+	default:
+		return nil, errors.Errorf("%T is not a supported private key", v)
+	}
+	return info, nil
+}
+
 // Serialize will serialize the input to a PEM formatted block and apply
 // modifiers.
 func Serialize(in interface{}, opts ...Options) (*pem.Block, error) {
@@ -600,11 +824,65 @@ func Serialize(in interface{}, opts ...Options) (*pem.Block, error) {
 	return p, nil
 }
 
+// SerializeCertificateRequest serializes the given certificate request to a
+// PEM block of type "CERTIFICATE REQUEST". Use the ToFile option to write the
+// block to a file.
+func SerializeCertificateRequest(csr *x509.CertificateRequest, opts ...Options) (*pem.Block, error) {
+	if csr == nil || len(csr.Raw) == 0 {
+		return nil, errors.New("error serializing certificate request: certificate request is empty")
+	}
+	return Serialize(csr, opts...)
+}
+
+// SerializeEncrypted serializes the given private key to a PEM block with a
+// PKCS#8 EncryptedPrivateKeyInfo. The key is encrypted with AES-256-CBC, or
+// the cipher selected with WithPKCS8Cipher, and a key derived from the password
+// using PBKDF2. Supported keys are RSA, ECDSA and Ed25519.
+//
+// As in Serialize, the ToFile option can be used to store the PEM in disk.
+func SerializeEncrypted(key crypto.PrivateKey, password []byte, opts ...Options) (*pem.Block, error) {
+	ctx := new(context)
+	if err := ctx.apply(opts); err != nil {
+		return nil, err
+	}
+	if len(password) == 0 {
+		return nil, errors.New("failed to serialize to PEM: password cannot be empty")
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, errors.Errorf("cannot serialize type '%T', value '%v'", key, key)
+	}
+
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal private key")
+	}
+
+	iterations := ctx.kdfIterations
+	if iterations == 0 {
+		iterations = PBKDF2Iterations
+	}
+	p, err := encryptPKCS8PrivateKey(rand.Reader, b, password, cipherByKey(x509.PEMCipherAES256), ctx.pkcs8Cipher == AES256GCM, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.filename != "" {
+		if err := WriteFile(ctx.filename, pem.EncodeToMemory(p), ctx.perm); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
 // ParseDER parses the given DER-encoded bytes and results the public or private
 // key encoded.
 func ParseDER(b []byte) (interface{}, error) {
 	// Try private keys
-	key, err := x509.ParsePKCS8PrivateKey(b)
+	key, err := parsePKCS8PrivateKey(b)
 	if err != nil {
 		if key, err = x509.ParseECPrivateKey(b); err != nil {
 			key, err = x509.ParsePKCS1PrivateKey(b)
@@ -623,6 +901,63 @@ func ParseDER(b []byte) (interface{}, error) {
 	return key, nil
 }
 
+// parseCertificateOrKeyDER parses the given DER-encoded bytes as a
+// certificate, a public key or a private key, in that order.
+func parseCertificateOrKeyDER(b []byte, filename string) (interface{}, error) {
+	if crt, err := x509.ParseCertificate(b); err == nil {
+		return crt, nil
+	}
+	if pub, err := x509.ParsePKIXPublicKey(b); err == nil {
+		return pub, nil
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(b); err == nil {
+		return pub, nil
+	}
+	if priv, err := parsePKCS8PrivateKey(b); err == nil {
+		return priv, nil
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(b); err == nil {
+		return priv, nil
+	}
+	if priv, err := x509.ParseECPrivateKey(b); err == nil {
+		return priv, nil
+	}
+	return nil, errors.Errorf("error parsing %s: not a valid PEM encoded block, "+
+		"DER encoded certificate, PKIX or PKCS#1 public key, "+
+		"or PKCS#8, PKCS#1 or RFC5915 private key", filename)
+}
+
+// ParsePublicKey returns the public key in the given bytes. It accepts the PEM
+// formats "PUBLIC KEY", with a PKIX SubjectPublicKeyInfo, and "RSA PUBLIC KEY",
+// with a PKCS#1 RSA public key, as well as a single line in the OpenSSH
+// authorized_keys format, e.g. "ssh-ed25519 AAAA... comment".
+func ParsePublicKey(b []byte) (crypto.PublicKey, error) {
+	b = normalize(b)
+	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("error decoding public key: not a valid PEM encoded block")
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			return pub, errors.Wrap(err, "error parsing PKIX public key")
+		case "RSA PUBLIC KEY":
+			pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			return pub, errors.Wrap(err, "error parsing PKCS#1 public key")
+		default:
+			return nil, errors.Errorf("error decoding public key: unsupported PEM type '%s', "+
+				"expected 'PUBLIC KEY' or 'RSA PUBLIC KEY'", block.Type)
+		}
+	}
+
+	if _, _, _, _, err := ssh.ParseAuthorizedKey(b); err != nil {
+		return nil, errors.New("error decoding public key: unrecognized format, " +
+			"expected a PEM encoded PKIX or PKCS#1 public key, or an OpenSSH authorized_keys line")
+	}
+	return ParseSSH(b)
+}
+
 // ParseSSH parses parses a public key from an authorized_keys file used in
 // OpenSSH according to the sshd(8) manual page.
 func ParseSSH(b []byte) (interface{}, error) {
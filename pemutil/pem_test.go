@@ -15,6 +15,7 @@ import (
 	"encoding/pem"
 	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -76,60 +77,63 @@ type testdata struct {
 }
 
 var files = map[string]testdata{
-	"testdata/openssl.p256.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssl.p256.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssl.p256.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssl.p384.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssl.p384.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssl.p384.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssl.p521.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssl.p521.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssl.p521.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssl.rsa1024.pem":           {rsaPrivateKey, false},
-	"testdata/openssl.rsa1024.pub.pem":       {rsaPublicKey, false},
-	"testdata/openssl.rsa1024.enc.pem":       {rsaPrivateKey, true},
-	"testdata/openssl.rsa2048.pem":           {rsaPrivateKey, false},
-	"testdata/openssl.rsa2048.pub.pem":       {rsaPublicKey, false},
-	"testdata/openssl.rsa2048.enc.pem":       {rsaPrivateKey, true},
-	"testdata/openssh.ed25519.enc.pem":       {ed25519PrivateKey, true},
-	"testdata/openssh.ed25519.pem":           {ed25519PrivateKey, false},
-	"testdata/openssh.ed25519.pub.pem":       {ed25519PublicKey, false},
-	"testdata/openssh.p256.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssh.p256.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssh.p256.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssh.p384.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssh.p384.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssh.p384.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssh.p521.enc.pem":          {ecdsaPrivateKey, true},
-	"testdata/openssh.p521.pem":              {ecdsaPrivateKey, false},
-	"testdata/openssh.p521.pub.pem":          {ecdsaPublicKey, false},
-	"testdata/openssh.rsa1024.enc.pem":       {rsaPrivateKey, true},
-	"testdata/openssh.rsa1024.pem":           {rsaPrivateKey, false},
-	"testdata/openssh.rsa1024.pub.pem":       {rsaPublicKey, false},
-	"testdata/openssh.rsa2048.enc.pem":       {rsaPrivateKey, true},
-	"testdata/openssh.rsa2048.pem":           {rsaPrivateKey, false},
-	"testdata/openssh.rsa2048.pub.pem":       {rsaPublicKey, false},
-	"testdata/pkcs8/openssl.ed25519.pem":     {ed25519PrivateKey, false},
-	"testdata/pkcs8/openssl.ed25519.pub.pem": {ed25519PublicKey, false},
-	"testdata/pkcs8/openssl.ed25519.enc.pem": {ed25519PrivateKey, true},
-	"testdata/pkcs8/openssl.p256.pem":        {ecdsaPrivateKey, false},
-	"testdata/pkcs8/openssl.p256.pub.pem":    {ecdsaPublicKey, false},
-	"testdata/pkcs8/openssl.p256.enc.pem":    {ecdsaPrivateKey, true},
-	"testdata/pkcs8/openssl.p384.pem":        {ecdsaPrivateKey, false},
-	"testdata/pkcs8/openssl.p384.pub.pem":    {ecdsaPublicKey, false},
-	"testdata/pkcs8/openssl.p384.enc.pem":    {ecdsaPrivateKey, true},
-	"testdata/pkcs8/openssl.p521.pem":        {ecdsaPrivateKey, false},
-	"testdata/pkcs8/openssl.p521.pub.pem":    {ecdsaPublicKey, false},
-	"testdata/pkcs8/openssl.p521.enc.pem":    {ecdsaPrivateKey, true},
-	"testdata/pkcs8/openssl.rsa2048.pem":     {rsaPrivateKey, false},
-	"testdata/pkcs8/openssl.rsa2048.pub.pem": {rsaPublicKey, false},
-	"testdata/pkcs8/openssl.rsa2048.enc.pem": {rsaPrivateKey, true},
-	"testdata/pkcs8/openssl.rsa4096.pem":     {rsaPrivateKey, false},
-	"testdata/pkcs8/openssl.rsa4096.pub.pem": {rsaPublicKey, false},
-	"testdata/cosign.pub.pem":                {ecdsaPublicKey, false},
-	"testdata/cosign.enc.pem":                {ecdsaPrivateKey, true},
-	"testdata/nebula.pub":                    {x25519PublicKey, false},
-	"testdata/nebula.key":                    {x25519PrivateKey, false},
+	"testdata/openssl.p256.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssl.p256.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssl.p256.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssl.p384.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssl.p384.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssl.p384.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssl.p521.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssl.p521.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssl.p521.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssl.rsa1024.pem":                  {rsaPrivateKey, false},
+	"testdata/openssl.rsa1024.pub.pem":              {rsaPublicKey, false},
+	"testdata/openssl.rsa1024.enc.pem":              {rsaPrivateKey, true},
+	"testdata/openssl.rsa2048.pem":                  {rsaPrivateKey, false},
+	"testdata/openssl.rsa2048.pub.pem":              {rsaPublicKey, false},
+	"testdata/openssl.rsa2048.enc.pem":              {rsaPrivateKey, true},
+	"testdata/openssh.ed25519.enc.pem":              {ed25519PrivateKey, true},
+	"testdata/openssh.ed25519.pem":                  {ed25519PrivateKey, false},
+	"testdata/openssh.ed25519.pub.pem":              {ed25519PublicKey, false},
+	"testdata/openssh.p256.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssh.p256.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssh.p256.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssh.p384.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssh.p384.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssh.p384.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssh.p521.enc.pem":                 {ecdsaPrivateKey, true},
+	"testdata/openssh.p521.pem":                     {ecdsaPrivateKey, false},
+	"testdata/openssh.p521.pub.pem":                 {ecdsaPublicKey, false},
+	"testdata/openssh.rsa1024.enc.pem":              {rsaPrivateKey, true},
+	"testdata/openssh.rsa1024.pem":                  {rsaPrivateKey, false},
+	"testdata/openssh.rsa1024.pub.pem":              {rsaPublicKey, false},
+	"testdata/openssh.rsa2048.enc.pem":              {rsaPrivateKey, true},
+	"testdata/openssh.rsa2048.pem":                  {rsaPrivateKey, false},
+	"testdata/openssh.rsa2048.pub.pem":              {rsaPublicKey, false},
+	"testdata/pkcs8/openssl.ed25519.pem":            {ed25519PrivateKey, false},
+	"testdata/pkcs8/openssl.ed25519.pub.pem":        {ed25519PublicKey, false},
+	"testdata/pkcs8/openssl.ed25519.enc.pem":        {ed25519PrivateKey, true},
+	"testdata/pkcs8/openssl.p256.pem":               {ecdsaPrivateKey, false},
+	"testdata/pkcs8/openssl.p256.pub.pem":           {ecdsaPublicKey, false},
+	"testdata/pkcs8/openssl.p256.enc.pem":           {ecdsaPrivateKey, true},
+	"testdata/pkcs8/openssl.p384.pem":               {ecdsaPrivateKey, false},
+	"testdata/pkcs8/openssl.p384.pub.pem":           {ecdsaPublicKey, false},
+	"testdata/pkcs8/openssl.p384.enc.pem":           {ecdsaPrivateKey, true},
+	"testdata/pkcs8/openssl.p521.pem":               {ecdsaPrivateKey, false},
+	"testdata/pkcs8/openssl.p521.pub.pem":           {ecdsaPublicKey, false},
+	"testdata/pkcs8/openssl.p521.enc.pem":           {ecdsaPrivateKey, true},
+	"testdata/pkcs8/openssl.rsa2048.pem":            {rsaPrivateKey, false},
+	"testdata/pkcs8/openssl.rsa2048.pub.pem":        {rsaPublicKey, false},
+	"testdata/pkcs8/openssl.rsa2048.enc.pem":        {rsaPrivateKey, true},
+	"testdata/pkcs8/pbkdf2/openssl.rsa2048.enc.pem": {rsaPrivateKey, true},
+	"testdata/pkcs8/scrypt/openssl.p256.enc.pem":    {ecdsaPrivateKey, true},
+	"testdata/pkcs8/scrypt/openssl.ed25519.enc.pem": {ed25519PrivateKey, true},
+	"testdata/pkcs8/openssl.rsa4096.pem":            {rsaPrivateKey, false},
+	"testdata/pkcs8/openssl.rsa4096.pub.pem":        {rsaPublicKey, false},
+	"testdata/cosign.pub.pem":                       {ecdsaPublicKey, false},
+	"testdata/cosign.enc.pem":                       {ecdsaPrivateKey, true},
+	"testdata/nebula.pub":                           {x25519PublicKey, false},
+	"testdata/nebula.key":                           {x25519PrivateKey, false},
 }
 
 func readOrParseSSH(fn string) (interface{}, error) {
@@ -340,6 +344,10 @@ func TestReadCertificate(t *testing.T) {
 		{"testdata/ca.crt", nil, nil},
 		{"testdata/ca.der", nil, nil},
 		{"testdata/bundle.crt", []Options{WithFirstBlock()}, nil},
+		{"testdata/crlf.ca.crt", nil, nil},
+		{"testdata/multiple.pem", []Options{WithBlockType("CERTIFICATE")}, nil},
+		{"testdata/multiple.pem", nil, errors.New("error decoding testdata/multiple.pem: contains more than one PEM encoded block")},
+		{"testdata/multiple.pem", []Options{WithBlockType("CERTIFICATE REQUEST")}, errors.New("error decoding testdata/multiple.pem: does not contain a PEM encoded block of type 'CERTIFICATE REQUEST'")},
 		{"testdata/bundle.crt", nil, errors.New("error decoding testdata/bundle.crt: contains more than one PEM encoded block")},
 		{"testdata/notexists.crt", nil, errors.New("error reading testdata/notexists.crt: no such file or directory")},
 		{"testdata/badca.crt", nil, errors.New("error parsing testdata/badca.crt")},
@@ -372,6 +380,8 @@ func TestReadCertificateBundle(t *testing.T) {
 		{"testdata/ca.crt", 1, nil},
 		{"testdata/ca.der", 1, nil},
 		{"testdata/bundle.crt", 2, nil},
+		{"testdata/commented.bundle.pem", 2, nil},
+		{"testdata/multiple.pem", 1, nil},
 		{"testdata/notexists.crt", 0, errors.New("error reading testdata/notexists.crt: no such file or directory")},
 		{"testdata/badca.crt", 0, errors.New("error parsing testdata/badca.crt")},
 		{"testdata/badpem.crt", 0, errors.New("error decoding PEM: file 'testdata/badpem.crt' contains unexpected data")},
@@ -395,6 +405,67 @@ func TestReadCertificateBundle(t *testing.T) {
 	}
 }
 
+func TestReadCertificateBundle_comments(t *testing.T) {
+	want, err := ReadCertificateBundle("testdata/bundle.crt")
+	assert.FatalError(t, err)
+	certs, err := ReadCertificateBundle("testdata/commented.bundle.pem")
+	assert.FatalError(t, err)
+	assert.Equals(t, want, certs)
+
+	// Only comments and keys.
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "comments.pem")
+	assert.FatalError(t, os.WriteFile(fn, []byte("# comment\n\n-----BEGIN PUBLIC KEY-----\n-----END PUBLIC KEY-----\n# comment\n"), 0600))
+	_, err = ReadCertificateBundle(fn)
+	assert.Error(t, err)
+}
+
+func TestReadCertificateBundleWithKeys(t *testing.T) {
+	bundle, err := ReadCertificateBundle("testdata/bundle.crt")
+	assert.FatalError(t, err)
+	ca, err := ReadCertificate("testdata/ca.crt")
+	assert.FatalError(t, err)
+	key, err := Read("testdata/openssl.p256.pem")
+	assert.FatalError(t, err)
+
+	// Bundle with an encrypted key.
+	dir := t.TempDir()
+	encKey, err := os.ReadFile("testdata/openssl.p256.enc.pem")
+	assert.FatalError(t, err)
+	caPEM, err := os.ReadFile("testdata/ca.crt")
+	assert.FatalError(t, err)
+	encBundle := filepath.Join(dir, "enc.bundle.pem")
+	assert.FatalError(t, os.WriteFile(encBundle, append(append([]byte("# Encrypted key\n"), encKey...), caPEM...), 0600))
+
+	tests := []struct {
+		name      string
+		fn        string
+		opts      []Options
+		wantCerts []*x509.Certificate
+		wantKeys  []crypto.PrivateKey
+		wantErr   bool
+	}{
+		{"ok", "testdata/commented.bundle.pem", nil, bundle, []crypto.PrivateKey{key}, false},
+		{"ok no keys", "testdata/bundle.crt", nil, bundle, nil, false},
+		{"ok der", "testdata/ca.der", nil, []*x509.Certificate{ca}, nil, false},
+		{"ok encrypted", encBundle, []Options{WithPassword([]byte("mypassword"))}, []*x509.Certificate{ca}, []crypto.PrivateKey{key}, false},
+		{"fail encrypted", encBundle, []Options{WithPassword([]byte("badpassword"))}, nil, nil, true},
+		{"fail no certificates", "testdata/openssl.p256.pem", nil, nil, nil, true},
+		{"fail missing", "testdata/missing.pem", nil, nil, nil, true},
+		{"fail bad pem", "testdata/badpem.crt", nil, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, keys, err := ReadCertificateBundleWithKeys(tt.fn, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCertificateBundleWithKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equals(t, tt.wantCerts, certs)
+			assert.Equals(t, tt.wantKeys, keys)
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
 	type ParseTest struct {
 		in      []byte
@@ -962,6 +1033,58 @@ func TestParseSSH(t *testing.T) {
 	}
 }
 
+func TestParsePublicKey(t *testing.T) {
+	mustRead := func(filename string) interface{} {
+		v, err := Read(filename)
+		assert.FatalError(t, err)
+		return v
+	}
+	rsaKey := mustRead("testdata/openssl.rsa2048.pub.pem")
+	ed25519Key := mustRead("testdata/pkcs8/openssl.ed25519.pub.pem")
+
+	// Read also supports PKCS#1 public keys.
+	assert.Equals(t, rsaKey, mustRead("testdata/openssl.rsa2048.pkcs1.pub.pem"))
+
+	tests := []struct {
+		name     string
+		filename string
+		want     crypto.PublicKey
+		wantErr  bool
+	}{
+		{"spki rsa", "testdata/openssl.rsa2048.pub.pem", rsaKey, false},
+		{"spki ec", "testdata/openssl.p256.pub.pem", mustRead("testdata/openssl.p256.pub.pem"), false},
+		{"spki ed25519", "testdata/pkcs8/openssl.ed25519.pub.pem", ed25519Key, false},
+		{"pkcs1 rsa", "testdata/openssl.rsa2048.pkcs1.pub.pem", rsaKey, false},
+		{"ssh ed25519", "testdata/openssh.ed25519.pub.pem", mustRead("testdata/openssh.ed25519.pem").(ed25519.PrivateKey).Public(), false},
+		{"ssh p384", "testdata/openssh.p384.pub.pem", &mustRead("testdata/openssh.p384.pem").(*ecdsa.PrivateKey).PublicKey, false},
+		{"fail private key", "testdata/openssl.p256.pem", nil, true},
+		{"fail certificate", "testdata/ca.crt", nil, true},
+		{"fail der", "testdata/ca.der", nil, true},
+		{"fail bad pem", "testdata/badpem.crt", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(tt.filename)
+			assert.FatalError(t, err)
+			got, err := ParsePublicKey(b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey_errors(t *testing.T) {
+	_, err := ParsePublicKey([]byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"))
+	assert.True(t, err != nil && strings.Contains(err.Error(), "unsupported PEM type 'CERTIFICATE'"))
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	assert.True(t, err != nil && strings.Contains(err.Error(), "unrecognized format"))
+}
+
 func TestOpenSSH(t *testing.T) {
 	t.Parallel()
 	for fn, td := range files {
@@ -1010,6 +1133,93 @@ func TestOpenSSH(t *testing.T) {
 	}
 }
 
+func TestSerializeOpenSSHPrivateKey(t *testing.T) {
+	mustKey := func(kty, crv string, size int) crypto.PrivateKey {
+		t.Helper()
+		key, err := keyutil.GenerateKey(kty, crv, size)
+		assert.FatalError(t, err)
+		return key
+	}
+
+	ecdsaKey := mustKey("EC", "P-256", 0)
+	ecdsaP384Key := mustKey("EC", "P-384", 0)
+	ecdsaP521Key := mustKey("EC", "P-521", 0)
+	rsaKey := mustKey("RSA", "", 2048)
+	edKey := mustKey("OKP", "Ed25519", 0)
+	// x/crypto/ssh returns ed25519 keys as pointers.
+	edKeyPtr := edKey.(ed25519.PrivateKey)
+
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	assert.FatalError(t, err)
+
+	type args struct {
+		key  crypto.PrivateKey
+		opts []Options
+	}
+	tests := []struct {
+		name     string
+		args     args
+		password []byte
+		want     crypto.PrivateKey
+		wantErr  bool
+	}{
+		{"ok ecdsa", args{ecdsaKey, nil}, nil, ecdsaKey, false},
+		{"ok ecdsa P-384", args{ecdsaP384Key, nil}, nil, ecdsaP384Key, false},
+		{"ok ecdsa P-521", args{ecdsaP521Key, nil}, nil, ecdsaP521Key, false},
+		{"ok rsa", args{rsaKey, nil}, nil, rsaKey, false},
+		{"ok ed25519", args{edKey, nil}, nil, &edKeyPtr, false},
+		{"ok ecdsa with password", args{ecdsaKey, []Options{WithPassword([]byte("mypassword"))}}, []byte("mypassword"), ecdsaKey, false},
+		{"ok rsa with password", args{rsaKey, []Options{WithPassword([]byte("mypassword"))}}, []byte("mypassword"), rsaKey, false},
+		{"ok ed25519 with password", args{edKey, []Options{WithPassword([]byte("mypassword"))}}, []byte("mypassword"), &edKeyPtr, false},
+		{"ok with comment", args{edKey, []Options{WithComment("test@smallstep.com")}}, nil, &edKeyPtr, false},
+		{"fail unsupported curve", args{p224Key, nil}, nil, nil, true},
+		{"fail unsupported key", args{[]byte("a symmetric key"), nil}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := SerializeOpenSSHPrivateKey(tt.args.key, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeOpenSSHPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equals(t, "OPENSSH PRIVATE KEY", block.Type)
+
+			// Parse it using x/crypto/ssh.
+			var got interface{}
+			if tt.password == nil {
+				got, err = ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+			} else {
+				_, err = ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+				if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+					t.Errorf("ssh.ParseRawPrivateKey() error = %v, want *ssh.PassphraseMissingError", err)
+				}
+				_, err = ssh.ParseRawPrivateKeyWithPassphrase(pem.EncodeToMemory(block), []byte("foobar"))
+				if !errors.Is(err, x509.IncorrectPasswordError) {
+					t.Errorf("ssh.ParseRawPrivateKeyWithPassphrase() error = %v, want %v", err, x509.IncorrectPasswordError)
+				}
+				got, err = ssh.ParseRawPrivateKeyWithPassphrase(pem.EncodeToMemory(block), tt.password)
+			}
+			assert.FatalError(t, err)
+			if rk, ok := got.(*rsa.PrivateKey); ok {
+				rk.Precompute()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ssh.ParseRawPrivateKey() = %v, want %v", got, tt.want)
+			}
+
+			// The parsed key must match the original public key.
+			signer, err := ssh.NewSignerFromKey(got)
+			assert.FatalError(t, err)
+			pub, err := ssh.NewPublicKey(tt.args.key.(crypto.Signer).Public())
+			assert.FatalError(t, err)
+			assert.Equals(t, pub.Marshal(), signer.PublicKey().Marshal())
+		})
+	}
+}
+
 func TestRead_options(t *testing.T) {
 	mustKey := func(filename string) interface{} {
 		b, err := os.ReadFile(filename)
@@ -1057,6 +1267,122 @@ func TestRead_options(t *testing.T) {
 	}
 }
 
+func TestRead_der(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	crt, err := ReadCertificate("testdata/ca.crt")
+	assert.FatalError(t, err)
+
+	mustMarshal := func(b []byte, err error) []byte {
+		assert.FatalError(t, err)
+		return b
+	}
+	writeFile := func(name string, b []byte) string {
+		filename := t.TempDir() + "/" + name
+		assert.FatalError(t, os.WriteFile(filename, b, 0600))
+		return filename
+	}
+
+	tests := []struct {
+		name    string
+		der     []byte
+		want    interface{}
+		wantErr bool
+	}{
+		{"certificate", crt.Raw, crt, false},
+		{"ec public key", mustMarshal(x509.MarshalPKIXPublicKey(ecKey.Public())), ecKey.Public(), false},
+		{"ec private key", mustMarshal(x509.MarshalECPrivateKey(ecKey)), ecKey, false},
+		{"ec pkcs#8 private key", mustMarshal(x509.MarshalPKCS8PrivateKey(ecKey)), ecKey, false},
+		{"rsa public key", mustMarshal(x509.MarshalPKIXPublicKey(rsaKey.Public())), rsaKey.Public(), false},
+		{"rsa pkcs#1 public key", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), rsaKey.Public(), false},
+		{"rsa private key", x509.MarshalPKCS1PrivateKey(rsaKey), rsaKey, false},
+		{"rsa pkcs#8 private key", mustMarshal(x509.MarshalPKCS8PrivateKey(rsaKey)), rsaKey, false},
+		{"fail", []byte("not a der"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeFile("key.der", tt.der)
+			got, err := Read(filename)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.HasPrefix(t, err.Error(), "error parsing "+filename+": not a valid PEM encoded block, DER encoded certificate")
+				return
+			}
+			// Compare public keys to avoid precomputed values in rsa keys
+			if k, ok := got.(*rsa.PrivateKey); ok {
+				assert.Equals(t, rsaKey.D, k.D)
+				assert.Equals(t, rsaKey.Public(), k.Public())
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead_normalize(t *testing.T) {
+	key, err := Read("testdata/openssl.p256.pem")
+	assert.FatalError(t, err)
+	crt, err := ReadCertificate("testdata/ca.crt")
+	assert.FatalError(t, err)
+
+	type args struct {
+		filename string
+		opts     []Options
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    interface{}
+		wantErr error
+	}{
+		{"crlf key", args{"testdata/crlf.p256.pem", nil}, key, nil},
+		{"crlf certificate", args{"testdata/crlf.ca.crt", nil}, crt, nil},
+		{"first block", args{"testdata/multiple.pem", []Options{WithFirstBlock()}}, key, nil},
+		{"block type key", args{"testdata/multiple.pem", []Options{WithBlockType("EC PRIVATE KEY")}}, key, nil},
+		{"block type certificate", args{"testdata/multiple.pem", []Options{WithBlockType("CERTIFICATE")}}, crt, nil},
+		{"block type single block", args{"testdata/crlf.ca.crt", []Options{WithBlockType("CERTIFICATE")}}, crt, nil},
+		{"fail multiple blocks", args{"testdata/multiple.pem", nil}, nil,
+			errors.New("error decoding testdata/multiple.pem: contains more than one PEM encoded block")},
+		{"fail block type", args{"testdata/multiple.pem", []Options{WithBlockType("PUBLIC KEY")}}, nil,
+			errors.New("error decoding testdata/multiple.pem: does not contain a PEM encoded block of type 'PUBLIC KEY'")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(tt.args.filename, tt.args.opts...)
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_openSSHWithBlockType(t *testing.T) {
+	b, err := os.ReadFile("testdata/openssh.ed25519.pem")
+	assert.FatalError(t, err)
+	crt, err := os.ReadFile("testdata/ca.crt")
+	assert.FatalError(t, err)
+	want, err := Parse(b)
+	assert.FatalError(t, err)
+
+	got, err := Parse(append(crt, b...), WithBlockType("OPENSSH PRIVATE KEY"))
+	assert.FatalError(t, err)
+	assert.Equals(t, want, got)
+}
+
 func TestRead_promptPassword(t *testing.T) {
 	mustKey := func(filename string) interface{} {
 		b, err := os.ReadFile(filename)
@@ -1103,6 +1429,99 @@ func TestRead_promptPassword(t *testing.T) {
 	}
 }
 
+func TestReadPrivateKey(t *testing.T) {
+	type want struct {
+		algorithm string
+		bits      int
+		curve     string
+		encrypted bool
+	}
+	tests := []struct {
+		name     string
+		filename string
+		opts     []Options
+		want     want
+		wantErr  bool
+	}{
+		{"rsa1024", "testdata/openssl.rsa1024.pem", nil, want{"RSA", 1024, "", false}, false},
+		{"rsa2048", "testdata/openssl.rsa2048.pem", nil, want{"RSA", 2048, "", false}, false},
+		{"rsa2048 encrypted", "testdata/openssl.rsa2048.enc.pem", []Options{WithPassword([]byte("mypassword"))}, want{"RSA", 2048, "", true}, false},
+		{"rsa4096 pkcs8", "testdata/pkcs8/openssl.rsa4096.pem", nil, want{"RSA", 4096, "", false}, false},
+		{"p256", "testdata/openssl.p256.pem", nil, want{"EC", 256, "P-256", false}, false},
+		{"p256 encrypted", "testdata/openssl.p256.enc.pem", []Options{WithPasswordFile("testdata/password.txt")}, want{"EC", 256, "P-256", true}, false},
+		{"p384", "testdata/openssl.p384.pem", nil, want{"EC", 384, "P-384", false}, false},
+		{"p521 pkcs8 encrypted", "testdata/pkcs8/openssl.p521.enc.pem", []Options{WithPassword([]byte("mypassword"))}, want{"EC", 521, "P-521", true}, false},
+		{"ed25519", "testdata/pkcs8/openssl.ed25519.pem", nil, want{"Ed25519", 256, "", false}, false},
+		{"ed25519 der", "testdata/pkcs8/openssl.ed25519.der", nil, want{"Ed25519", 256, "", false}, false},
+		{"ed25519 pkcs8 encrypted", "testdata/pkcs8/openssl.ed25519.enc.pem", []Options{WithPassword([]byte("mypassword"))}, want{"Ed25519", 256, "", true}, false},
+		{"openssh p256", "testdata/openssh.p256.pem", nil, want{"EC", 256, "P-256", false}, false},
+		{"openssh ed25519 encrypted", "testdata/openssh.ed25519.enc.pem", []Options{WithPassword([]byte("mypassword"))}, want{"Ed25519", 256, "", true}, false},
+		{"nebula", "testdata/nebula.key", nil, want{"X25519", 256, "", false}, false},
+		{"x25519 pkcs8", "testdata/pkcs8/openssl.x25519.pem", nil, want{"X25519", 256, "", false}, false},
+		{"x25519 with ed25519 oid", "testdata/pkcs8/x25519.ed25519-oid.pem", nil, want{"X25519", 256, "", false}, false},
+		{"ed25519 with x25519 oid", "testdata/pkcs8/ed25519.x25519-oid.pem", nil, want{"Ed25519", 256, "", false}, false},
+		{"fail public key", "testdata/openssl.p256.pub.pem", nil, want{}, true},
+		{"fail certificate", "testdata/ca.crt", nil, want{}, true},
+		{"fail password", "testdata/openssl.p256.enc.pem", nil, want{}, true},
+		{"fail missing", "testdata/missing.pem", nil, want{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPrivateKey(tt.filename, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+			key, err := Read(tt.filename, tt.opts...)
+			assert.FatalError(t, err)
+			assert.Equals(t, key, got.Key)
+			assert.Equals(t, tt.want.algorithm, got.Algorithm)
+			assert.Equals(t, tt.want.bits, got.Bits)
+			assert.Equals(t, tt.want.curve, got.Curve)
+			assert.Equals(t, tt.want.encrypted, got.Encrypted)
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		filename      string
+		opts          []Options
+		wantAlgorithm string
+		wantEncrypted bool
+		wantErr       bool
+	}{
+		{"ok rsa", "testdata/openssl.rsa2048.pem", nil, "RSA", false, false},
+		{"ok p256 encrypted", "testdata/openssl.p256.enc.pem", []Options{WithPasswordFile("testdata/password.txt")}, "EC", true, false},
+		{"ok ed25519", "testdata/pkcs8/openssl.ed25519.pem", nil, "Ed25519", false, false},
+		{"ok x25519", "testdata/pkcs8/openssl.x25519.pem", nil, "X25519", false, false},
+		{"ok x25519 with ed25519 oid", "testdata/pkcs8/x25519.ed25519-oid.pem", nil, "X25519", false, false},
+		{"ok ed25519 with x25519 oid", "testdata/pkcs8/ed25519.x25519-oid.pem", nil, "Ed25519", false, false},
+		{"fail public key", "testdata/pkcs8/openssl.ed25519.pub.pem", nil, "", false, true},
+		{"fail der", "testdata/pkcs8/openssl.ed25519.der", nil, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(tt.filename)
+			assert.FatalError(t, err)
+			got, err := ParsePrivateKey(b, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equals(t, tt.wantAlgorithm, got.Algorithm)
+			assert.Equals(t, tt.wantEncrypted, got.Encrypted)
+		})
+	}
+}
+
 func TestReadCertificateRequest(t *testing.T) {
 	expected := &x509.CertificateRequest{
 		Subject: pkix.Name{
@@ -1154,6 +1573,7 @@ func TestReadCertificateRequest(t *testing.T) {
 		{"fail bad csr", args{"testdata/bad.csr"}, nil, true},
 		{"fail certificate", args{"testdata/ca.crt"}, nil, true},
 		{"fail certificate der", args{"testdata/ca.der"}, nil, true},
+		{"fail bad signature", args{"testdata/badsig.csr"}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1175,3 +1595,50 @@ func TestReadCertificateRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestReadCertificateRequest_keyTypes(t *testing.T) {
+	tests := []struct {
+		name          string
+		filename      string
+		wantCN        string
+		wantAlgorithm x509.PublicKeyAlgorithm
+	}{
+		{"rsa", "testdata/openssl.rsa2048.csr", "rsa.smallstep.com", x509.RSA},
+		{"ec", "testdata/openssl.p256.csr", "ec.smallstep.com", x509.ECDSA},
+		{"ec der", "testdata/openssl.p256.csr.der", "ec.smallstep.com", x509.ECDSA},
+		{"ec crlf", "testdata/crlf.p256.csr", "ec.smallstep.com", x509.ECDSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr, err := ReadCertificateRequest(tt.filename)
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.wantCN, csr.Subject.CommonName)
+			assert.Equals(t, []string{tt.wantCN}, csr.DNSNames)
+			assert.Equals(t, tt.wantAlgorithm, csr.PublicKeyAlgorithm)
+		})
+	}
+}
+
+func TestSerializeCertificateRequest(t *testing.T) {
+	csr, err := ReadCertificateRequest("testdata/openssl.rsa2048.csr")
+	assert.FatalError(t, err)
+	b, err := os.ReadFile("testdata/openssl.rsa2048.csr")
+	assert.FatalError(t, err)
+
+	block, err := SerializeCertificateRequest(csr)
+	assert.FatalError(t, err)
+	assert.Equals(t, "CERTIFICATE REQUEST", block.Type)
+	assert.Equals(t, b, pem.EncodeToMemory(block))
+
+	filename := filepath.Join(t.TempDir(), "test.csr")
+	_, err = SerializeCertificateRequest(csr, ToFile(filename, 0600))
+	assert.FatalError(t, err)
+	got, err := ReadCertificateRequest(filename)
+	assert.FatalError(t, err)
+	assert.Equals(t, csr.Raw, got.Raw)
+
+	_, err = SerializeCertificateRequest(nil)
+	assert.Error(t, err)
+	_, err = SerializeCertificateRequest(&x509.CertificateRequest{})
+	assert.Error(t, err)
+}
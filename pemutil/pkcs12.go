@@ -0,0 +1,63 @@
+package pemutil
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/internal/utils"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ParsePKCS12 parses the given PKCS #12 (.pfx or .p12) data and returns the
+// private key and the certificate chain, with the certificate of the private
+// key first and followed by any other certificate in the bundle.
+//
+// Both the legacy encryption algorithms, RC2 and 3DES, and the PBES2 schemes
+// with AES used by default in OpenSSL 3.x are supported.
+func ParsePKCS12(data, password []byte) (crypto.PrivateKey, []*x509.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, string(password))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error decoding PKCS#12 data")
+	}
+	return key, append([]*x509.Certificate{cert}, caCerts...), nil
+}
+
+// ReadPKCS12 reads the given PKCS #12 (.pfx or .p12) file and returns the
+// private key and the certificate chain. See ParsePKCS12 for more details.
+func ReadPKCS12(filename string, password []byte) (crypto.PrivateKey, []*x509.Certificate, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, chain, err := ParsePKCS12(b, password)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return key, chain, nil
+}
+
+// DecodeToPEM converts the given PKCS #12 data to PEM blocks. The first block
+// is the private key, serialized using Serialize with the given options, and it
+// is followed by the certificate chain. The private key is not encrypted
+// unless the WithPassword or WithPasswordPrompt options are used.
+func DecodeToPEM(data, password []byte, opts ...Options) ([]*pem.Block, error) {
+	key, chain, err := ParsePKCS12(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := Serialize(key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []*pem.Block{block}
+	for _, crt := range chain {
+		blocks = append(blocks, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: crt.Raw,
+		})
+	}
+	return blocks, nil
+}
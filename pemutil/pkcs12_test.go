@@ -0,0 +1,92 @@
+package pemutil
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+)
+
+func TestReadPKCS12(t *testing.T) {
+	key, err := Read("testdata/openssl.rsa2048.pem")
+	assert.FatalError(t, err)
+	leaf, err := ReadCertificate("testdata/pkcs12/leaf.crt")
+	assert.FatalError(t, err)
+	ca, err := ReadCertificate("testdata/pkcs12/ca.crt")
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name     string
+		filename string
+		password []byte
+		wantErr  bool
+	}{
+		{"ok legacy", "testdata/pkcs12/legacy.p12", []byte("mypassword"), false},
+		{"ok aes", "testdata/pkcs12/aes.p12", []byte("mypassword"), false},
+		{"fail password", "testdata/pkcs12/aes.p12", []byte("badpassword"), true},
+		{"fail legacy password", "testdata/pkcs12/legacy.p12", []byte("badpassword"), true},
+		{"fail pem", "testdata/pkcs12/leaf.crt", []byte("mypassword"), true},
+		{"fail missing", "testdata/pkcs12/missing.p12", []byte("mypassword"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotKey, gotChain, err := ReadPKCS12(tt.filename, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPKCS12() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				assert.Nil(t, gotKey)
+				assert.Nil(t, gotChain)
+				return
+			}
+			assert.Type(t, &rsa.PrivateKey{}, gotKey)
+			assert.True(t, gotKey.(*rsa.PrivateKey).Equal(key))
+			assert.Equals(t, []*x509.Certificate{leaf, ca}, gotChain)
+			assert.True(t, keyutil.Equal(gotChain[0].PublicKey, gotKey.(*rsa.PrivateKey).Public()))
+		})
+	}
+}
+
+func TestDecodeToPEM(t *testing.T) {
+	keyPEM, err := os.ReadFile("testdata/openssl.rsa2048.pem")
+	assert.FatalError(t, err)
+	leaf, err := ReadCertificate("testdata/pkcs12/leaf.crt")
+	assert.FatalError(t, err)
+	ca, err := ReadCertificate("testdata/pkcs12/ca.crt")
+	assert.FatalError(t, err)
+	keyBlock, _ := pem.Decode(keyPEM)
+
+	for _, fn := range []string{"testdata/pkcs12/legacy.p12", "testdata/pkcs12/aes.p12"} {
+		t.Run(fn, func(t *testing.T) {
+			b, err := os.ReadFile(fn)
+			assert.FatalError(t, err)
+
+			blocks, err := DecodeToPEM(b, []byte("mypassword"))
+			assert.FatalError(t, err)
+			assert.Len(t, 3, blocks)
+			assert.Equals(t, keyBlock.Type, blocks[0].Type)
+			assert.Equals(t, keyBlock.Bytes, blocks[0].Bytes)
+			assert.Equals(t, &pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}, blocks[1])
+			assert.Equals(t, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}, blocks[2])
+
+			// Key serialization options.
+			blocks, err = DecodeToPEM(b, []byte("mypassword"), WithPKCS8(true))
+			assert.FatalError(t, err)
+			assert.Equals(t, "PRIVATE KEY", blocks[0].Type)
+
+			blocks, err = DecodeToPEM(b, []byte("mypassword"), WithPassword([]byte("newpassword")))
+			assert.FatalError(t, err)
+			assert.Equals(t, "RSA PRIVATE KEY", blocks[0].Type)
+			key, err := Parse(pem.EncodeToMemory(blocks[0]), WithPassword([]byte("newpassword")))
+			assert.FatalError(t, err)
+			assert.Type(t, &rsa.PrivateKey{}, key)
+
+			_, err = DecodeToPEM(b, []byte("badpassword"))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -6,15 +6,16 @@ import (
 	"crypto/des"  //nolint:gosec // support for legacy keys
 	"crypto/sha1" //nolint:gosec // support for legacy keys
 	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/pem"
-	"hash"
 	"io"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 )
 
 // PBKDF2SaltSize is the default size of the salt for PBKDF2, 128-bit salt.
@@ -54,18 +55,42 @@ type pbkdf2Params struct {
 	PrfParam       prfParam `asn1:"optional"`
 }
 
-type pbkdf2Algorithms struct {
-	Algo         asn1.ObjectIdentifier
-	PBKDF2Params pbkdf2Params
+// Limits of the scrypt parameters accepted when decrypting a key. The
+// parameters come from the encrypted key, so they are bounded to prevent
+// excessive memory and CPU usage. The defaults used by OpenSSL and cosign are
+// N=2^14-2^15, r=8 and p=1.
+const (
+	scryptMaxCost            = 1 << 20
+	scryptMaxBlockSize       = 32
+	scryptMaxParallelization = 16
+	scryptMaxMemory          = 1 << 30 // 128 * N * r * p bytes
+)
+
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+type kdfAlgorithm struct {
+	Algo   asn1.ObjectIdentifier
+	Params asn1.RawValue
 }
 
 type pbkdf2Encs struct {
 	EncryAlgo asn1.ObjectIdentifier
-	IV        []byte
+	Params    asn1.RawValue
+}
+
+type gcmParams struct {
+	Nonce  []byte
+	ICVLen int `asn1:"default:12"`
 }
 
 type pbes2Params struct {
-	KeyDerivationFunc pbkdf2Algorithms
+	KeyDerivationFunc kdfAlgorithm
 	EncryptionScheme  pbkdf2Encs
 }
 
@@ -82,6 +107,7 @@ type encryptedPrivateKeyInfo struct {
 var (
 	// key derivation functions
 	oidPKCS5PBKDF2    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
 	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
 	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
 
@@ -89,6 +115,9 @@ var (
 	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
 	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
 	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidAES128GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}
+	oidAES192GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26}
+	oidAES256GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
 	oidDESCBC    = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}
 	oidD3DESCBC  = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
 )
@@ -101,6 +130,9 @@ type rfc1423Algo struct {
 	keySize    int
 	blockSize  int
 	identifier asn1.ObjectIdentifier
+	// gcmIdentifier is the identifier of the cipher in GCM mode, if
+	// supported.
+	gcmIdentifier asn1.ObjectIdentifier
 }
 
 // rfc1423Algos holds a slice of the possible ways to encrypt a PEM
@@ -120,26 +152,29 @@ var rfc1423Algos = []rfc1423Algo{{
 	blockSize:  des.BlockSize,
 	identifier: oidD3DESCBC,
 }, {
-	cipher:     x509.PEMCipherAES128,
-	name:       "AES-128-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    16,
-	blockSize:  aes.BlockSize,
-	identifier: oidAES128CBC,
+	cipher:        x509.PEMCipherAES128,
+	name:          "AES-128-CBC",
+	cipherFunc:    aes.NewCipher,
+	keySize:       16,
+	blockSize:     aes.BlockSize,
+	identifier:    oidAES128CBC,
+	gcmIdentifier: oidAES128GCM,
 }, {
-	cipher:     x509.PEMCipherAES192,
-	name:       "AES-192-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    24,
-	blockSize:  aes.BlockSize,
-	identifier: oidAES192CBC,
+	cipher:        x509.PEMCipherAES192,
+	name:          "AES-192-CBC",
+	cipherFunc:    aes.NewCipher,
+	keySize:       24,
+	blockSize:     aes.BlockSize,
+	identifier:    oidAES192CBC,
+	gcmIdentifier: oidAES192GCM,
 }, {
-	cipher:     x509.PEMCipherAES256,
-	name:       "AES-256-CBC",
-	cipherFunc: aes.NewCipher,
-	keySize:    32,
-	blockSize:  aes.BlockSize,
-	identifier: oidAES256CBC,
+	cipher:        x509.PEMCipherAES256,
+	name:          "AES-256-CBC",
+	cipherFunc:    aes.NewCipher,
+	keySize:       32,
+	blockSize:     aes.BlockSize,
+	identifier:    oidAES256CBC,
+	gcmIdentifier: oidAES256GCM,
 },
 }
 
@@ -153,13 +188,6 @@ func cipherByKey(key x509.PEMCipher) *rfc1423Algo {
 	return nil
 }
 
-// deriveKey uses a key derivation function to stretch the password into a key
-// with the number of bits our cipher requires. This algorithm was derived from
-// the OpenSSL source.
-func (c rfc1423Algo) deriveKey(password, salt []byte, h func() hash.Hash) []byte {
-	return pbkdf2.Key(password, salt, PBKDF2Iterations, c.keySize, h)
-}
-
 // DecryptPEMBlock takes a password encrypted PEM block and the password used
 // to encrypt it and returns a slice of decrypted DER encoded bytes.
 //
@@ -189,7 +217,7 @@ func DecryptPEMBlock(block *pem.Block, password []byte) ([]byte, error) {
 // decrypted DER bytes will be random noise.
 //
 // It supports AES-128-CBC, AES-192-CBC, AES-256-CBC, DES, or 3DES encrypted
-// data using the key derived with PBKDF2 over the given password.
+// data using the key derived with PBKDF2 or scrypt over the given password.
 func DecryptPKCS8PrivateKey(data, password []byte) ([]byte, error) {
 	var pki encryptedPrivateKeyInfo
 	if _, err := asn1.Unmarshal(data, &pki); err != nil {
@@ -200,51 +228,56 @@ func DecryptPKCS8PrivateKey(data, password []byte) ([]byte, error) {
 		return nil, errors.New("unsupported encrypted PEM: only PBES2 is supported")
 	}
 
-	if !pki.Algo.Parameters.KeyDerivationFunc.Algo.Equal(oidPKCS5PBKDF2) {
-		return nil, errors.New("unsupported encrypted PEM: only PBKDF2 is supported")
-	}
-
 	encParam := pki.Algo.Parameters.EncryptionScheme
-	kdfParam := pki.Algo.Parameters.KeyDerivationFunc.PBKDF2Params
-
-	iv := encParam.IV
-	salt := kdfParam.Salt
-	iter := kdfParam.IterationCount
 
-	// pbkdf2 hash function
-	keyHash := sha1.New
-	if kdfParam.PrfParam.Algo.Equal(oidHMACWithSHA256) {
-		keyHash = sha256.New
-	}
-
-	var symkey []byte
-	var block cipher.Block
-	var err error
+	var keySize int
+	var isGCM bool
+	var newCipher func(key []byte) (cipher.Block, error)
 	switch {
 	// AES-128-CBC, AES-192-CBC, AES-256-CBC
 	case encParam.EncryAlgo.Equal(oidAES128CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 16, keyHash)
-		block, err = aes.NewCipher(symkey)
+		keySize, newCipher = 16, aes.NewCipher
 	case encParam.EncryAlgo.Equal(oidAES192CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 24, keyHash)
-		block, err = aes.NewCipher(symkey)
+		keySize, newCipher = 24, aes.NewCipher
 	case encParam.EncryAlgo.Equal(oidAES256CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 32, keyHash)
-		block, err = aes.NewCipher(symkey)
+		keySize, newCipher = 32, aes.NewCipher
+	// AES-128-GCM, AES-192-GCM, AES-256-GCM
+	case encParam.EncryAlgo.Equal(oidAES128GCM):
+		keySize, newCipher, isGCM = 16, aes.NewCipher, true
+	case encParam.EncryAlgo.Equal(oidAES192GCM):
+		keySize, newCipher, isGCM = 24, aes.NewCipher, true
+	case encParam.EncryAlgo.Equal(oidAES256GCM):
+		keySize, newCipher, isGCM = 32, aes.NewCipher, true
 	// DES, TripleDES
 	case encParam.EncryAlgo.Equal(oidDESCBC):
-		symkey = pbkdf2.Key(password, salt, iter, 8, keyHash)
-		block, err = des.NewCipher(symkey) //nolint:gosec // support for legacy keys
+		keySize, newCipher = 8, des.NewCipher //nolint:gosec // support for legacy keys
 	case encParam.EncryAlgo.Equal(oidD3DESCBC):
-		symkey = pbkdf2.Key(password, salt, iter, 24, keyHash)
-		block, err = des.NewTripleDESCipher(symkey) //nolint:gosec // support for legacy keys
+		keySize, newCipher = 24, des.NewTripleDESCipher //nolint:gosec // support for legacy keys
 	default:
 		return nil, errors.Errorf("unsupported encrypted PEM: unknown algorithm %v", encParam.EncryAlgo)
 	}
+
+	symkey, err := deriveKDFKey(pki.Algo.Parameters.KeyDerivationFunc, password, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := newCipher(symkey)
 	if err != nil {
 		return nil, err
 	}
 
+	if isGCM {
+		return decryptGCM(block, encParam.Params, pki.PrivateKey)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(encParam.Params.FullBytes, &iv); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal encryption parameters")
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("error decrypting PEM: invalid IV size")
+	}
+
 	data = pki.PrivateKey
 	mode := cipher.NewCBCDecrypter(block, iv)
 	mode.CryptBlocks(data, data)
@@ -261,20 +294,153 @@ func DecryptPKCS8PrivateKey(data, password []byte) ([]byte, error) {
 		return nil, errors.New("error decrypting PEM: invalid padding")
 	}
 
-	last := int(data[dlen-1])
-	if dlen < last {
+	n, ok := unpad(data, blockSize)
+	if !ok {
 		return nil, x509.IncorrectPasswordError
 	}
-	if last == 0 || last > blockSize {
+	return data[:dlen-n], nil
+}
+
+// unpad returns the length of the RFC 1423 padding at the end of the given
+// data, and whether the padding is valid. The data must be a non-empty multiple
+// of the block size. To avoid leaking information about the decrypted data, the
+// check runs in constant time, it always inspects the last block completely.
+func unpad(data []byte, blockSize int) (int, bool) {
+	dlen := len(data)
+	last := int(data[dlen-1])
+	good := subtle.ConstantTimeLessOrEq(1, last) & subtle.ConstantTimeLessOrEq(last, blockSize)
+	for i := 1; i <= blockSize; i++ {
+		eq := subtle.ConstantTimeByteEq(data[dlen-i], byte(last))
+		good &= subtle.ConstantTimeSelect(subtle.ConstantTimeLessOrEq(i, last), eq, 1)
+	}
+	return last, good == 1
+}
+
+// VerifyPassphrase returns true if the given password decrypts the encrypted
+// private key PEM-encoded in the given bytes. An incorrect password returns
+// false with a nil error; an error is only returned if the PEM cannot be
+// decoded or is not a supported encrypted key.
+//
+// The decrypted key is parsed to detect incorrect passwords not caught by the
+// padding check, and it is discarded before returning.
+func VerifyPassphrase(pemData, password []byte) (bool, error) {
+	block, _ := pem.Decode(normalize(pemData))
+	if block == nil {
+		return false, errors.New("error decoding PEM: not a valid PEM encoded block")
+	}
+
+	var parse func([]byte) error
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		parse = func(der []byte) error {
+			_, err := parsePKCS8PrivateKey(der)
+			return err
+		}
+	case block.Headers["Proc-Type"] == "4,ENCRYPTED" && block.Type == "RSA PRIVATE KEY":
+		parse = func(der []byte) error {
+			_, err := x509.ParsePKCS1PrivateKey(der)
+			return err
+		}
+	case block.Headers["Proc-Type"] == "4,ENCRYPTED" && block.Type == "EC PRIVATE KEY":
+		parse = func(der []byte) error {
+			_, err := x509.ParseECPrivateKey(der)
+			return err
+		}
+	default:
+		return false, errors.Errorf("error verifying passphrase: unsupported PEM type '%s'", block.Type)
+	}
+
+	der, err := DecryptPEMBlock(block, password)
+	switch {
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return false, nil
+	case err != nil:
+		return false, errors.Wrap(err, "error verifying passphrase")
+	}
+	defer func() {
+		for i := range der {
+			der[i] = 0
+		}
+	}()
+
+	return parse(der) == nil, nil
+}
+
+// decryptGCM decrypts the given data using AES-GCM with the nonce and tag
+// length in the given parameters. A failure in the authentication of the data
+// is reported as an incorrect password.
+func decryptGCM(block cipher.Block, params asn1.RawValue, data []byte) ([]byte, error) {
+	var p gcmParams
+	if _, err := asn1.Unmarshal(params.FullBytes, &p); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal encryption parameters")
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, len(p.Nonce))
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting PEM")
+	}
+	if p.ICVLen != aead.Overhead() {
+		return nil, errors.Errorf("unsupported encrypted PEM: unsupported GCM tag size %d", p.ICVLen)
+	}
+	plaintext, err := aead.Open(nil, p.Nonce, data, nil)
+	if err != nil {
 		return nil, x509.IncorrectPasswordError
 	}
-	for _, val := range data[dlen-last:] {
-		if int(val) != last {
-			return nil, x509.IncorrectPasswordError
+	return plaintext, nil
+}
+
+// deriveKDFKey derives a key of the given size from the password using the key
+// derivation function described in the PBES2 parameters. PBKDF2 and scrypt are
+// supported.
+func deriveKDFKey(kdf kdfAlgorithm, password []byte, keySize int) ([]byte, error) {
+	switch {
+	case kdf.Algo.Equal(oidPKCS5PBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Params.FullBytes, &params); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal PBKDF2 parameters")
+		}
+		// pbkdf2 hash function
+		keyHash := sha1.New
+		if params.PrfParam.Algo.Equal(oidHMACWithSHA256) {
+			keyHash = sha256.New
+		}
+		return pbkdf2.Key(password, params.Salt, params.IterationCount, keySize, keyHash), nil
+	case kdf.Algo.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Params.FullBytes, &params); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal scrypt parameters")
+		}
+		if params.KeyLength != 0 && params.KeyLength != keySize {
+			return nil, errors.Errorf("unsupported encrypted PEM: scrypt key length %d does not match the cipher", params.KeyLength)
+		}
+		if err := validateScryptParams(params); err != nil {
+			return nil, err
+		}
+		key, err := scrypt.Key(password, params.Salt, params.CostParameter, params.BlockSize, params.ParallelizationParameter, keySize)
+		if err != nil {
+			return nil, errors.Wrap(err, "error deriving scrypt key")
 		}
+		return key, nil
+	default:
+		return nil, errors.New("unsupported encrypted PEM: only PBKDF2 and scrypt are supported")
 	}
+}
 
-	return data[:dlen-last], nil
+// validateScryptParams checks that the scrypt parameters are within the limits
+// accepted when decrypting a key.
+func validateScryptParams(params scryptParams) error {
+	n, r, p := params.CostParameter, params.BlockSize, params.ParallelizationParameter
+	switch {
+	case n <= 1 || n > scryptMaxCost:
+		return errors.Errorf("unsupported encrypted PEM: scrypt cost parameter %d is out of range", n)
+	case r <= 0 || r > scryptMaxBlockSize:
+		return errors.Errorf("unsupported encrypted PEM: scrypt block size %d is out of range", r)
+	case p <= 0 || p > scryptMaxParallelization:
+		return errors.Errorf("unsupported encrypted PEM: scrypt parallelization parameter %d is out of range", p)
+	case int64(128)*int64(n)*int64(r)*int64(p) > scryptMaxMemory:
+		return errors.Errorf("unsupported encrypted PEM: scrypt parameters N=%d, r=%d, p=%d exceed the memory limit", n, r, p)
+	default:
+		return nil
+	}
 }
 
 // EncryptPKCS8PrivateKey returns a PEM block holding the given PKCS#8 encroded
@@ -285,52 +451,91 @@ func EncryptPKCS8PrivateKey(rand io.Reader, data, password []byte, alg x509.PEMC
 	if ciph == nil {
 		return nil, errors.Errorf("failed to encrypt PEM: unknown algorithm %v", alg)
 	}
+	return encryptPKCS8PrivateKey(rand, data, password, ciph, false, PBKDF2Iterations)
+}
+
+// encryptPKCS8PrivateKey encrypts the PKCS#8 data with the given cipher, in
+// CBC or GCM mode, and a key derived from the password using PBKDF2 with the
+// given number of iterations.
+func encryptPKCS8PrivateKey(rand io.Reader, data, password []byte, ciph *rfc1423Algo, gcm bool, iterations int) (*pem.Block, error) {
+	if gcm && ciph.gcmIdentifier == nil {
+		return nil, errors.Errorf("failed to encrypt PEM: GCM is not supported with %s", ciph.name)
+	}
 
 	salt := make([]byte, PBKDF2SaltSize)
 	if _, err := io.ReadFull(rand, salt); err != nil {
 		return nil, errors.Wrap(err, "failed to generate salt")
 	}
-	iv := make([]byte, ciph.blockSize)
-	if _, err := io.ReadFull(rand, iv); err != nil {
-		return nil, errors.Wrap(err, "failed to generate IV")
-	}
 
-	key := ciph.deriveKey(password, salt, sha256.New)
+	key := pbkdf2.Key(password, salt, iterations, ciph.keySize, sha256.New)
 	block, err := ciph.cipherFunc(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create cipher")
 	}
-	enc := cipher.NewCBCEncrypter(block, iv)
-	pad := ciph.blockSize - len(data)%ciph.blockSize
-	encrypted := make([]byte, len(data), len(data)+pad)
-	// We could save this copy by encrypting all the whole blocks in
-	// the data separately, but it doesn't seem worth the additional
-	// code.
-	copy(encrypted, data)
-	// See RFC 1423, section 1.1
-	for i := 0; i < pad; i++ {
-		encrypted = append(encrypted, byte(pad))
+
+	var encrypted []byte
+	var encAlgo asn1.ObjectIdentifier
+	var encParams []byte
+	if gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create cipher")
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand, nonce); err != nil {
+			return nil, errors.Wrap(err, "failed to generate nonce")
+		}
+		encAlgo = ciph.gcmIdentifier
+		if encParams, err = asn1.Marshal(gcmParams{Nonce: nonce, ICVLen: aead.Overhead()}); err != nil {
+			return nil, errors.Wrap(err, "error marshaling encrypted key")
+		}
+		encrypted = aead.Seal(nil, nonce, data, nil)
+	} else {
+		iv := make([]byte, ciph.blockSize)
+		if _, err := io.ReadFull(rand, iv); err != nil {
+			return nil, errors.Wrap(err, "failed to generate IV")
+		}
+		enc := cipher.NewCBCEncrypter(block, iv)
+		pad := ciph.blockSize - len(data)%ciph.blockSize
+		encrypted = make([]byte, len(data), len(data)+pad)
+		// We could save this copy by encrypting all the whole blocks in
+		// the data separately, but it doesn't seem worth the additional
+		// code.
+		copy(encrypted, data)
+		// See RFC 1423, section 1.1
+		for i := 0; i < pad; i++ {
+			encrypted = append(encrypted, byte(pad))
+		}
+		enc.CryptBlocks(encrypted, encrypted)
+		encAlgo = ciph.identifier
+		if encParams, err = asn1.Marshal(iv); err != nil {
+			return nil, errors.Wrap(err, "error marshaling encrypted key")
+		}
 	}
-	enc.CryptBlocks(encrypted, encrypted)
 
 	// Build encrypted asn1 data
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: iterations,
+		PrfParam: prfParam{
+			Algo:      oidHMACWithSHA256,
+			NullParam: asn1.NullRawValue,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling encrypted key")
+	}
 	pki := encryptedPrivateKeyInfo{
 		Algo: encryptedlAlgorithmIdentifier{
 			Algorithm: oidPBES2,
 			Parameters: pbes2Params{
-				KeyDerivationFunc: pbkdf2Algorithms{
-					Algo: oidPKCS5PBKDF2,
-					PBKDF2Params: pbkdf2Params{
-						Salt:           salt,
-						IterationCount: PBKDF2Iterations,
-						PrfParam: prfParam{
-							Algo: oidHMACWithSHA256,
-						},
-					},
+				KeyDerivationFunc: kdfAlgorithm{
+					Algo:   oidPKCS5PBKDF2,
+					Params: asn1.RawValue{FullBytes: kdfParams},
 				},
 				EncryptionScheme: pbkdf2Encs{
-					EncryAlgo: ciph.identifier,
-					IV:        iv,
+					EncryAlgo: encAlgo,
+					Params:    asn1.RawValue{FullBytes: encParams},
 				},
 			},
 		},
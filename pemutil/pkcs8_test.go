@@ -1,11 +1,13 @@
 package pemutil
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"os"
@@ -14,6 +16,7 @@ import (
 	"testing"
 
 	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
 )
 
 func TestEncryptDecryptPKCS8(t *testing.T) {
@@ -230,3 +233,215 @@ func TestDecryptPKCS8PrivateKey_ciphers(t *testing.T) {
 
 	}
 }
+
+func Test_deriveKDFKey_scrypt(t *testing.T) {
+	mustParams := func(t *testing.T, n, r, p int) asn1.RawValue {
+		t.Helper()
+		b, err := asn1.Marshal(scryptParams{
+			Salt:                     []byte("0123456789abcdef"),
+			CostParameter:            n,
+			BlockSize:                r,
+			ParallelizationParameter: p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{FullBytes: b}
+	}
+
+	tests := []struct {
+		name    string
+		n, r, p int
+		wantErr bool
+	}{
+		{"ok", 1 << 10, 8, 1, false},
+		{"fail cost", 1 << 30, 8, 1, true},
+		{"fail cost not positive", 0, 8, 1, true},
+		{"fail block size", 1 << 10, 1 << 20, 1, true},
+		{"fail parallelization", 1 << 10, 8, 1 << 20, true},
+		{"fail memory", 1 << 20, 32, 16, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kdf := kdfAlgorithm{
+				Algo:   oidScrypt,
+				Params: mustParams(t, tt.n, tt.r, tt.p),
+			}
+			got, err := deriveKDFKey(kdf, []byte("mypassword"), 32)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deriveKDFKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && len(got) != 32 {
+				t.Errorf("deriveKDFKey() len = %d, want 32", len(got))
+			}
+		})
+	}
+}
+
+func TestRead_incorrectPassword(t *testing.T) {
+	for name, td := range files {
+		// skip non-encrypted and non pkcs8 keys
+		if !td.encrypted || !strings.HasPrefix(name, "testdata/pkcs8/") {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read(name, WithPassword([]byte("mypassword"))); err != nil {
+				t.Errorf("Read() error = %v", err)
+			}
+			_, err := Read(name, WithPassword([]byte("foobar")))
+			if !errors.Is(err, x509.IncorrectPasswordError) {
+				t.Errorf("Read() error=%v, wantErr=%v", err, x509.IncorrectPasswordError)
+			}
+		})
+	}
+}
+
+func TestSerializeEncrypted(t *testing.T) {
+	rsaKey, err := Read("testdata/openssl.rsa2048.pem")
+	assert.FatalError(t, err)
+	ecdsaKey, err := Read("testdata/openssl.p256.pem")
+	assert.FatalError(t, err)
+	edKey, err := Read("testdata/pkcs8/openssl.ed25519.pem")
+	assert.FatalError(t, err)
+
+	password := []byte("mypassword")
+	tmp := t.TempDir()
+
+	type args struct {
+		key      interface{}
+		password []byte
+		opts     []Options
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"rsa", args{rsaKey, password, nil}, false},
+		{"rsa gcm", args{rsaKey, password, []Options{WithPKCS8Cipher(AES256GCM)}}, false},
+		{"ecdsa", args{ecdsaKey, password, []Options{WithPKCS8Cipher(AES256CBC)}}, false},
+		{"ecdsa gcm", args{ecdsaKey, password, []Options{WithPKCS8Cipher(AES256GCM), WithKDFIterations(1000)}}, false},
+		{"ed25519", args{edKey, password, []Options{WithKDFIterations(1000)}}, false},
+		{"ed25519 gcm", args{edKey, password, []Options{WithPKCS8Cipher(AES256GCM)}}, false},
+		{"ed25519 to file", args{edKey, password, []Options{ToFile(tmp+"/ed25519.enc.pem", 0600)}}, false},
+		{"fail empty password", args{ecdsaKey, nil, nil}, true},
+		{"fail public key", args{ecdsaKey.(crypto.Signer).Public(), password, nil}, true},
+		{"fail type", args{[]byte("foobar"), password, nil}, true},
+		{"fail cipher", args{ecdsaKey, password, []Options{WithPKCS8Cipher(PKCS8Cipher(100))}}, true},
+		{"fail iterations", args{ecdsaKey, password, []Options{WithKDFIterations(0)}}, true},
+		{"fail write", args{ecdsaKey, password, []Options{ToFile(tmp+"/missing/key.pem", 0600)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SerializeEncrypted(tt.args.key, tt.args.password, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeEncrypted() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.Equals(t, "ENCRYPTED PRIVATE KEY", got.Type)
+			key, err := Parse(pem.EncodeToMemory(got), WithPassword(password))
+			if err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+			assert.Equals(t, tt.args.key, key)
+
+			if _, err := Parse(pem.EncodeToMemory(got), WithPassword([]byte("foobar"))); !errors.Is(err, x509.IncorrectPasswordError) {
+				t.Errorf("Parse() error=%v, wantErr=%v", err, x509.IncorrectPasswordError)
+			}
+		})
+	}
+
+	key, err := Read(tmp+"/ed25519.enc.pem", WithPassword(password))
+	assert.FatalError(t, err)
+	assert.Equals(t, edKey, key)
+}
+
+func Test_unpad(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		want   int
+		wantOK bool
+	}{
+		{"ok one", []byte{1, 2, 3, 4, 5, 6, 7, 1}, 1, true},
+		{"ok some", []byte{1, 2, 3, 4, 5, 3, 3, 3}, 3, true},
+		{"ok full block", []byte{8, 8, 8, 8, 8, 8, 8, 8}, 8, true},
+		{"ok two blocks", []byte{9, 9, 9, 9, 9, 9, 9, 9, 1, 2, 3, 4, 5, 6, 2, 2}, 2, true},
+		{"fail zero", []byte{1, 2, 3, 4, 5, 6, 7, 0}, 0, false},
+		{"fail too large", []byte{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}, 9, false},
+		{"fail mismatch", []byte{1, 2, 3, 4, 5, 3, 2, 3}, 3, false},
+		{"fail mismatch first", []byte{7, 8, 8, 8, 8, 8, 8, 8}, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := unpad(tt.data, 8)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("unpad() = (%d, %v), want (%d, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVerifyPassphrase(t *testing.T) {
+	password := []byte("mypassword")
+
+	// Both the correct and the incorrect passwords must go through the
+	// verification and return a nil error. OpenSSH and cosign keys are not
+	// supported.
+	for name, td := range files {
+		if !td.encrypted || strings.HasPrefix(name, "testdata/openssh.") || strings.HasPrefix(name, "testdata/cosign.") {
+			continue
+		}
+		name := name
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(name)
+			assert.FatalError(t, err)
+
+			ok, err := VerifyPassphrase(data, password)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = VerifyPassphrase(data, []byte("foobar"))
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			// The input must not be modified.
+			b, err := os.ReadFile(name)
+			assert.FatalError(t, err)
+			assert.Equals(t, b, data)
+		})
+	}
+
+	key, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	for _, c := range []PKCS8Cipher{AES256CBC, AES256GCM} {
+		block, err := SerializeEncrypted(key, password, WithPKCS8Cipher(c), WithKDFIterations(1000))
+		assert.FatalError(t, err)
+		data := pem.EncodeToMemory(block)
+
+		ok, err := VerifyPassphrase(data, password)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = VerifyPassphrase(data, []byte("foobar"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	// Errors
+	for _, fn := range []string{"testdata/openssl.p256.pem", "testdata/openssh.p256.enc.pem", "testdata/ca.crt", "testdata/password.txt"} {
+		data, err := os.ReadFile(fn)
+		assert.FatalError(t, err)
+		ok, err := VerifyPassphrase(data, password)
+		assert.Error(t, err)
+		assert.False(t, ok)
+	}
+}
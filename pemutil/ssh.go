@@ -105,6 +105,9 @@ func ParseOpenSSHPrivateKey(pemBytes []byte, opts ...Options) (crypto.PrivateKey
 }
 
 // SerializeOpenSSHPrivateKey serialize a private key in the OpenSSH PEM format.
+// Ed25519, ECDSA, and RSA keys are supported. If a password is given using
+// WithPassword or WithPasswordPrompt, the key will be encrypted using
+// aes256-ctr with a key derived using bcrypt.
 func SerializeOpenSSHPrivateKey(key crypto.PrivateKey, opts ...Options) (*pem.Block, error) {
 	ctx := new(context)
 	if err := ctx.apply(opts); err != nil {
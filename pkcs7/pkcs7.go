@@ -0,0 +1,274 @@
+// Package pkcs7 implements the creation and verification of detached
+// PKCS #7/CMS SignedData signatures, as defined in RFC 5652.
+//
+// The signatures are created using a crypto.Signer, so the private key can be
+// held by a KMS or an HSM. RSA PKCS #1 v1.5, ECDSA and Ed25519 keys are
+// supported.
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+)
+
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+var digestAlgorithms = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA256: oidDigestSHA256,
+	crypto.SHA384: oidDigestSHA384,
+	crypto.SHA512: oidDigestSHA512,
+}
+
+var ecdsaAlgorithms = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA256: oidECDSAWithSHA256,
+	crypto.SHA384: oidECDSAWithSHA384,
+	crypto.SHA512: oidECDSAWithSHA512,
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// SignOptions are the options used in SignDetached.
+type SignOptions struct {
+	// Hash is the digest algorithm, SHA-256 by default. SHA-256, SHA-384 and
+	// SHA-512 are supported. Ed25519 keys always use SHA-512.
+	Hash crypto.Hash
+	// Certificates are additional certificates to include in the SignedData,
+	// usually the intermediates of the signer certificate.
+	Certificates []*x509.Certificate
+	// SigningTime is the time set in the signing time attribute. It defaults
+	// to the current time.
+	SigningTime time.Time
+}
+
+// SignDetached signs the content with the given signer and returns a
+// DER-encoded ContentInfo with a SignedData that does not include the
+// content. The certificate must match the signer, and it is included in the
+// SignedData with the certificates in the options.
+func SignDetached(content []byte, signer crypto.Signer, cert *x509.Certificate, opts *SignOptions) ([]byte, error) {
+	switch {
+	case signer == nil:
+		return nil, errors.New("pkcs7: signer cannot be nil")
+	case cert == nil:
+		return nil, errors.New("pkcs7: certificate cannot be nil")
+	case !keyutil.Equal(cert.PublicKey, signer.Public()):
+		return nil, errors.New("pkcs7: signer public key does not match the certificate public key")
+	}
+	if opts == nil {
+		opts = &SignOptions{}
+	}
+
+	hash := opts.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+
+	var pure bool
+	var sigAlg pkix.AlgorithmIdentifier
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: ecdsaAlgorithms[hash]}
+	case ed25519.PublicKey:
+		hash, pure = crypto.SHA512, true
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidEd25519}
+	default:
+		return nil, fmt.Errorf("pkcs7: unsupported key type %T", signer.Public())
+	}
+	digestOID, ok := digestAlgorithms[hash]
+	if !ok {
+		return nil, fmt.Errorf("pkcs7: unsupported hash %s", hash)
+	}
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: digestOID, Parameters: asn1.NullRawValue}
+
+	signingTime := opts.SigningTime
+	if signingTime.IsZero() {
+		signingTime = time.Now()
+	}
+
+	h := hash.New()
+	h.Write(content)
+	attrs, err := marshalAttributes([]attributeValue{
+		{oidAttributeContentType, oidData},
+		{oidAttributeSigningTime, signingTime.UTC().Truncate(time.Second)},
+		{oidAttributeMessageDigest, h.Sum(nil)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is calculated over the DER encoding of the attributes
+	// with the SET OF tag, see RFC 5652 Section 5.4.
+	var signature []byte
+	if pure {
+		signature, err = signer.Sign(rand.Reader, attrs.FullBytes, crypto.Hash(0))
+	} else {
+		h := hash.New()
+		h.Write(attrs.FullBytes)
+		signature, err = signer.Sign(rand.Reader, h.Sum(nil), hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pkcs7: error signing content: %w", err)
+	}
+
+	var rawCerts []byte
+	for _, c := range append([]*x509.Certificate{cert}, opts.Certificates...) {
+		rawCerts = append(rawCerts, c.Raw...)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		ContentInfo: encapsulatedContentInfo{
+			EContentType: oidData,
+		},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      rawCerts,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			DigestAlgorithm: digestAlg,
+			SignedAttributes: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      attrs.Bytes,
+			},
+			SignatureAlgorithm: sigAlg,
+			Signature:          signature,
+		}},
+	}
+	b, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs7: error marshaling signed data: %w", err)
+	}
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      b,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs7: error marshaling content info: %w", err)
+	}
+	return der, nil
+}
+
+type attributeValue struct {
+	Type  asn1.ObjectIdentifier
+	Value interface{}
+}
+
+// marshalAttributes returns the DER encoding of the given attributes as a SET
+// OF, sorted as required by DER.
+func marshalAttributes(values []attributeValue) (asn1.RawValue, error) {
+	encoded := make([][]byte, len(values))
+	for i, v := range values {
+		b, err := asn1.Marshal(v.Value)
+		if err != nil {
+			return asn1.RawValue{}, fmt.Errorf("pkcs7: error marshaling attribute %s: %w", v.Type, err)
+		}
+		if encoded[i], err = asn1.Marshal(attribute{
+			Type:   v.Type,
+			Values: []asn1.RawValue{{FullBytes: b}},
+		}); err != nil {
+			return asn1.RawValue{}, fmt.Errorf("pkcs7: error marshaling attribute %s: %w", v.Type, err)
+		}
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	raw := asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(encoded, nil),
+	}
+	b, err := asn1.Marshal(raw)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("pkcs7: error marshaling attributes: %w", err)
+	}
+	raw.FullBytes = b
+	return raw, nil
+}
@@ -0,0 +1,226 @@
+package pkcs7
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/kmstest"
+	"go.step.sm/crypto/minica"
+)
+
+func mustSigner(t *testing.T, kty, crv string, size int) crypto.Signer {
+	t.Helper()
+	signer, err := keyutil.GenerateSigner(kty, crv, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func mustCertificate(t *testing.T, ca *minica.CA, signer crypto.Signer, extKeyUsage ...x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	if len(extKeyUsage) == 0 {
+		extKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	}
+	cert, err := ca.Sign(&x509.Certificate{
+		DNSNames:    []string{"signer.example.com"},
+		PublicKey:   signer.Public(),
+		ExtKeyUsage: extKeyUsage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSignDetached_Verify(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+
+	km, err := kmstest.New(context.Background(), apiv1.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kmsSigner, err := km.CreateSigner(&apiv1.CreateSignerRequest{SigningKey: "kmstest:name=pkcs7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("the content to sign")
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		hash   crypto.Hash
+	}{
+		{"ok EC P-256", mustSigner(t, "EC", "P-256", 0), 0},
+		{"ok EC P-384", mustSigner(t, "EC", "P-384", 0), crypto.SHA384},
+		{"ok RSA", mustSigner(t, "RSA", "", 2048), 0},
+		{"ok RSA SHA-512", mustSigner(t, "RSA", "", 2048), crypto.SHA512},
+		{"ok Ed25519", mustSigner(t, "OKP", "Ed25519", 0), 0},
+		{"ok KMS", kmsSigner, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := mustCertificate(t, ca, tt.signer)
+			der, err := SignDetached(content, tt.signer, cert, &SignOptions{
+				Hash:         tt.hash,
+				Certificates: []*x509.Certificate{ca.Intermediate},
+			})
+			if err != nil {
+				t.Fatalf("SignDetached() error = %v", err)
+			}
+			signers, err := Verify(der, content, roots, nil)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if len(signers) != 1 || !signers[0].Equal(cert) {
+				t.Errorf("Verify() = %v, want [%v]", signers, cert)
+			}
+		})
+	}
+}
+
+func TestSignDetached_fail(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := mustSigner(t, "EC", "P-256", 0)
+	cert := mustCertificate(t, ca, signer)
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		cert   *x509.Certificate
+		opts   *SignOptions
+	}{
+		{"fail nil signer", nil, cert, nil},
+		{"fail nil certificate", signer, nil, nil},
+		{"fail mismatch", mustSigner(t, "EC", "P-256", 0), cert, nil},
+		{"fail hash", signer, cert, &SignOptions{Hash: crypto.SHA1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SignDetached([]byte("content"), tt.signer, tt.cert, tt.opts); err == nil {
+				t.Error("SignDetached() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestVerify_fail(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+
+	otherCA, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherCA.Root)
+
+	signer := mustSigner(t, "EC", "P-256", 0)
+	cert := mustCertificate(t, ca, signer)
+	content := []byte("the content to sign")
+
+	der, err := SignDetached(content, signer, cert, &SignOptions{
+		Certificates: []*x509.Certificate{ca.Intermediate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noIntermediate, err := SignDetached(content, signer, cert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverAuth := mustCertificate(t, ca, signer, x509.ExtKeyUsageServerAuth)
+	serverAuthDER, err := SignDetached(content, signer, serverAuth, &SignOptions{
+		Certificates: []*x509.Certificate{ca.Intermediate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		der     []byte
+		content []byte
+		roots   *x509.CertPool
+		opts    *VerifyOptions
+	}{
+		{"fail tampered content", der, []byte("the content to sign!"), roots, nil},
+		{"fail nil content", der, nil, roots, nil},
+		{"fail other roots", der, content, otherRoots, nil},
+		{"fail missing intermediate", noIntermediate, content, roots, nil},
+		{"fail expired", der, content, roots, &VerifyOptions{CurrentTime: cert.NotAfter.Add(time.Hour)}},
+		{"fail key usage", serverAuthDER, content, roots, nil},
+		{"fail other key usage", der, content, roots, &VerifyOptions{KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}}},
+		{"fail bad der", der[:len(der)-1], content, roots, nil},
+		{"fail trailing data", append(append([]byte{}, der...), 0), content, roots, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.der, tt.content, tt.roots, tt.opts); err == nil {
+				t.Error("Verify() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestVerify_options(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+
+	signer := mustSigner(t, "EC", "P-256", 0)
+	cert := mustCertificate(t, ca, signer)
+	serverAuth := mustCertificate(t, ca, signer, x509.ExtKeyUsageServerAuth)
+	content := []byte("the content to sign")
+
+	sign := func(cert *x509.Certificate, signingTime time.Time) []byte {
+		t.Helper()
+		der, err := SignDetached(content, signer, cert, &SignOptions{
+			Certificates: []*x509.Certificate{ca.Intermediate},
+			SigningTime:  signingTime,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	tests := []struct {
+		name string
+		der  []byte
+		opts *VerifyOptions
+	}{
+		// The signing time is set by the signer and it is ignored.
+		{"ok signing time after expiration", sign(cert, cert.NotAfter.Add(time.Hour)), nil},
+		{"ok signing time before validity", sign(cert, cert.NotBefore.Add(-time.Hour)), nil},
+		{"ok current time", sign(cert, time.Time{}), &VerifyOptions{CurrentTime: cert.NotAfter.Add(-time.Minute)}},
+		{"ok key usages", sign(serverAuth, time.Time{}), &VerifyOptions{KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.der, content, roots, tt.opts); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
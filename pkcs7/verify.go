@@ -0,0 +1,231 @@
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VerifyOptions are the options used in Verify.
+type VerifyOptions struct {
+	// CurrentTime is the time used to validate the signer certificates. It
+	// defaults to the current time. The signing time attribute in the
+	// SignedData is set by the signer, so it is not trusted and it is never
+	// used to validate the certificates.
+	CurrentTime time.Time
+	// KeyUsages are the accepted extended key usages of the signer
+	// certificates. It defaults to code signing and email protection.
+	KeyUsages []x509.ExtKeyUsage
+}
+
+// Verify verifies the detached signatures in the given DER-encoded PKCS #7
+// SignedData over the content, and returns the certificates of the signers.
+// The signer certificates must chain to the given roots, using the other
+// certificates in the SignedData as intermediates, and they must be valid at
+// the current time, or the time in the options.
+//
+// If content is nil and the SignedData encapsulates the content, the
+// encapsulated content is verified instead.
+func Verify(der, content []byte, roots *x509.CertPool, opts *VerifyOptions) ([]*x509.Certificate, error) {
+	if opts == nil {
+		opts = new(VerifyOptions)
+	}
+	currentTime := opts.CurrentTime
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+	keyUsages := opts.KeyUsages
+	if len(keyUsages) == 0 {
+		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageEmailProtection}
+	}
+
+	sd, err := parseSignedData(der)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		if len(sd.ContentInfo.EContent.Bytes) == 0 {
+			return nil, errors.New("pkcs7: content cannot be nil for a detached signature")
+		}
+		var eContent []byte
+		if _, err := asn1.Unmarshal(sd.ContentInfo.EContent.Bytes, &eContent); err != nil {
+			return nil, fmt.Errorf("pkcs7: error parsing content: %w", err)
+		}
+		content = eContent
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("pkcs7: signed data does not contain any signer")
+	}
+
+	var certs []*x509.Certificate
+	if len(sd.Certificates.Bytes) > 0 {
+		if certs, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, fmt.Errorf("pkcs7: error parsing certificates: %w", err)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+
+	signers := make([]*x509.Certificate, 0, len(sd.SignerInfos))
+	for i := range sd.SignerInfos {
+		si := &sd.SignerInfos[i]
+		cert := findCertificate(certs, si.SID)
+		if cert == nil {
+			return nil, errors.New("pkcs7: signer certificate not found")
+		}
+		if err := verifySignerInfo(si, sd.ContentInfo.EContentType, content, cert); err != nil {
+			return nil, err
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   currentTime,
+			KeyUsages:     keyUsages,
+		}); err != nil {
+			return nil, fmt.Errorf("pkcs7: error verifying signer certificate: %w", err)
+		}
+		signers = append(signers, cert)
+	}
+	return signers, nil
+}
+
+func parseSignedData(der []byte) (*signedData, error) {
+	var ci contentInfo
+	rest, err := asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs7: error parsing content info: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("pkcs7: trailing data after content info")
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("pkcs7: unsupported content type %s", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("pkcs7: error parsing signed data: %w", err)
+	}
+	return &sd, nil
+}
+
+func findCertificate(certs []*x509.Certificate, sid issuerAndSerialNumber) *x509.Certificate {
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(sid.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, sid.Issuer.FullBytes) {
+			return c
+		}
+	}
+	return nil
+}
+
+// verifySignerInfo verifies the signature in the signer info. The signing time
+// attribute is ignored.
+func verifySignerInfo(si *signerInfo, contentType asn1.ObjectIdentifier, content []byte, cert *x509.Certificate) error {
+	hash, err := getHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	// Without signed attributes the signature is calculated over the content.
+	if len(si.SignedAttributes.Bytes) == 0 {
+		return checkSignature(cert, si, hash, content)
+	}
+
+	var hasContentType, hasDigest bool
+	for rest := si.SignedAttributes.Bytes; len(rest) > 0; {
+		var attr attribute
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return fmt.Errorf("pkcs7: error parsing signed attributes: %w", err)
+		}
+		if len(attr.Values) != 1 {
+			return fmt.Errorf("pkcs7: attribute %s must have one value", attr.Type)
+		}
+		value := attr.Values[0].FullBytes
+		switch {
+		case attr.Type.Equal(oidAttributeContentType):
+			var oid asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(value, &oid); err != nil || !oid.Equal(contentType) {
+				return errors.New("pkcs7: content type attribute does not match the content")
+			}
+			hasContentType = true
+		case attr.Type.Equal(oidAttributeMessageDigest):
+			var md []byte
+			if _, err := asn1.Unmarshal(value, &md); err != nil || !bytes.Equal(md, digest) {
+				return errors.New("pkcs7: message digest attribute does not match the content")
+			}
+			hasDigest = true
+		}
+	}
+	if !hasContentType || !hasDigest {
+		return errors.New("pkcs7: signed attributes must contain the content type and message digest")
+	}
+
+	// The signature is calculated over the attributes with the SET OF tag.
+	attrs, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      si.SignedAttributes.Bytes,
+	})
+	if err != nil {
+		return fmt.Errorf("pkcs7: error marshaling signed attributes: %w", err)
+	}
+	return checkSignature(cert, si, hash, attrs)
+}
+
+func checkSignature(cert *x509.Certificate, si *signerInfo, hash crypto.Hash, signed []byte) error {
+	alg, err := getSignatureAlgorithm(si.SignatureAlgorithm.Algorithm, hash)
+	if err != nil {
+		return err
+	}
+	if err := cert.CheckSignature(alg, signed, si.Signature); err != nil {
+		return fmt.Errorf("pkcs7: invalid signature: %w", err)
+	}
+	return nil
+}
+
+func getHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	for h, o := range digestAlgorithms {
+		if o.Equal(oid) {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("pkcs7: unsupported digest algorithm %s", oid)
+}
+
+func getSignatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch {
+	case oid.Equal(oidRSAEncryption):
+		switch hash {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case oid.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case oid.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case oid.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case oid.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case oid.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case oid.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case oid.Equal(oidEd25519):
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("pkcs7: unsupported signature algorithm %s", oid)
+}
@@ -44,9 +44,19 @@ func Bytes(size int) ([]byte, error) {
 // String returns a random string of a given length using the characters in
 // the given string. It splits the string on runes to support UTF-8
 // characters.
+//
+// Each character is selected using crypto/rand.Int, which uses rejection
+// sampling, so all the characters have the same probability.
 func String(length int, chars string) (string, error) {
-	result := make([]rune, length)
 	runes := []rune(chars)
+	switch {
+	case length < 0:
+		return "", errors.New("error creating random string: length cannot be negative")
+	case len(runes) == 0:
+		return "", errors.New("error creating random string: characters cannot be empty")
+	}
+
+	result := make([]rune, length)
 	x := int64(len(runes))
 	for i := range result {
 		num, err := rand.Int(rand.Reader, big.NewInt(x))
@@ -70,6 +80,13 @@ func Alphanumeric(length int) (string, error) {
 	return String(length, "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
 }
 
+// Base62 returns a random string of the given length using the 62 characters
+// of the base62 alphabet, in order: digits, upper case and lower case letters
+// (0-9+A-Z+a-z).
+func Base62(length int) (string, error) {
+	return String(length, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+}
+
 // ASCII returns a securely generated random ASCII string. It reads random
 // numbers from crypto/rand and searches for printable characters. It will
 // return an error if the system's secure random number generator fails to
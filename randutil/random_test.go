@@ -6,6 +6,7 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/smallstep/assert"
 )
@@ -44,6 +45,10 @@ func TestErrors(t *testing.T) {
 		str, err = Alphabet(size)
 		assert.Error(t, err)
 		assert.Len(t, 0, str)
+
+		str, err = Base62(size)
+		assert.Error(t, err)
+		assert.Len(t, 0, str)
 	}
 }
 
@@ -87,6 +92,44 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestString_fail(t *testing.T) {
+	str, err := String(-1, "0123456789")
+	assert.Error(t, err)
+	assert.Len(t, 0, str)
+
+	str, err = String(8, "")
+	assert.Error(t, err)
+	assert.Len(t, 0, str)
+}
+
+func TestString_length(t *testing.T) {
+	for _, l := range []int{0, 1, 7, 64, 1000} {
+		str, err := String(l, "0123456789世界ñçàèìòù")
+		assert.NoError(t, err)
+		assert.Equals(t, l, utf8.RuneCountInString(str))
+	}
+}
+
+func TestString_distribution(t *testing.T) {
+	// With 62 characters and 2000 samples per character, the standard
+	// deviation of each count is ~44, a 15% deviation is more than 6 sigmas.
+	const samples = 2000
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	str, err := Base62(len(chars) * samples)
+	assert.FatalError(t, err)
+
+	counts := make(map[rune]int)
+	for _, r := range str {
+		counts[r]++
+	}
+	assert.Len(t, len(chars), counts)
+	for _, r := range chars {
+		if n := counts[r]; n < samples*85/100 || n > samples*115/100 {
+			t.Errorf("character %q appears %d times, want about %d", r, n, samples)
+		}
+	}
+}
+
 func TestHex(t *testing.T) {
 	re := regexp.MustCompilePOSIX(`^[0-9a-f]+$`)
 	lengths := []int{4, 8, 16, 32}
@@ -117,6 +160,21 @@ func TestAlphanumeric(t *testing.T) {
 	}
 }
 
+func TestBase62(t *testing.T) {
+	re := regexp.MustCompilePOSIX(`^[0-9a-zA-Z]+$`)
+	lengths := []int{4, 8, 16, 32}
+	for _, l := range lengths {
+		a, err := Base62(l)
+		assert.True(t, re.MatchString(a))
+		assert.NoError(t, err)
+		b, err := Base62(l)
+		assert.True(t, re.MatchString(b))
+		assert.NoError(t, err)
+		// Most of the time
+		assert.NotEquals(t, a, b)
+	}
+}
+
 func TestASCII(t *testing.T) {
 	re := regexp.MustCompilePOSIX("^[\x21-\x7E]+$")
 	lengths := []int{4, 8, 16, 32}
@@ -3,9 +3,11 @@
 package sshutil
 
 import (
+	"crypto"
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
+	"io"
 
 	"github.com/pkg/errors"
 	"go.step.sm/crypto/randutil"
@@ -72,6 +74,52 @@ func (c *Certificate) GetCertificate() *ssh.Certificate {
 	}
 }
 
+// Sign signs the certificate with the given crypto.Signer, like the ones
+// returned by the CreateSigner method of a KMS, and returns the signed
+// ssh.Certificate. The private key of the signer is never accessed directly,
+// so it can be kept in an HSM or a TPM. RSA signers use rsa-sha2-256.
+func (c *Certificate) Sign(signer crypto.Signer) (*ssh.Certificate, error) {
+	s, err := NewSigner(signer)
+	if err != nil {
+		return nil, err
+	}
+	return CreateCertificate(c.GetCertificate(), s)
+}
+
+// NewSigner returns an ssh.AlgorithmSigner that signs using the given
+// crypto.Signer. Supported keys are RSA, ECDSA with the curves P-256, P-384 and
+// P-521, and Ed25519. RSA signers support the rsa-sha2-256 and rsa-sha2-512
+// algorithms, and the Sign method will use rsa-sha2-256 instead of the
+// deprecated ssh-rsa (SHA-1).
+func NewSigner(signer crypto.Signer) (ssh.AlgorithmSigner, error) {
+	if signer == nil {
+		return nil, errors.New("signer cannot be nil")
+	}
+	s, err := ssh.NewSignerFromSigner(signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating ssh signer")
+	}
+	as, ok := s.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, errors.Errorf("ssh signer %T does not support signature algorithms", s)
+	}
+	return &cryptoSigner{AlgorithmSigner: as}, nil
+}
+
+// cryptoSigner is an ssh.AlgorithmSigner backed by a crypto.Signer that uses
+// rsa-sha2-256 by default with RSA keys.
+type cryptoSigner struct {
+	ssh.AlgorithmSigner
+}
+
+// Sign signs the data using the default algorithm for the key type.
+func (s *cryptoSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	if s.PublicKey().Type() == ssh.KeyAlgoRSA {
+		return s.SignWithAlgorithm(rand, data, ssh.KeyAlgoRSASHA256)
+	}
+	return s.AlgorithmSigner.Sign(rand, data)
+}
+
 // CreateCertificate signs the given certificate with the given signer. If the
 // certificate does not have a nonce or a serial, it will create random ones.
 //
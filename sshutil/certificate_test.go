@@ -2,7 +2,10 @@ package sshutil
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
@@ -10,6 +13,7 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -454,3 +458,139 @@ func TestCreateCertificate(t *testing.T) {
 		})
 	}
 }
+
+func TestCertificate_Sign(t *testing.T) {
+	key := mustGeneratePublicKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		signer   crypto.Signer
+		wantAlgo string
+		wantErr  bool
+	}{
+		{"ok ecdsa", ecKey, ssh.KeyAlgoECDSA256, false},
+		{"ok rsa", rsaKey, ssh.KeyAlgoRSASHA256, false},
+		{"ok ed25519", edKey, ssh.KeyAlgoED25519, false},
+		{"fail nil", nil, "", true},
+		{"fail p224", p224Key, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			c := &Certificate{
+				Key:         key,
+				Type:        UserCert,
+				KeyID:       "jane@doe.com",
+				Principals:  []string{"jane"},
+				ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+				ValidBefore: uint64(now.Add(time.Hour).Unix()),
+				Extensions:  map[string]string{"permit-pty": ""},
+			}
+			got, err := c.Sign(tt.signer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Certificate.Sign() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("Certificate.Sign() = %v, want nil", got)
+				}
+				return
+			}
+			if got.Signature.Format != tt.wantAlgo {
+				t.Errorf("Certificate.Sign() signature format = %s, want %s", got.Signature.Format, tt.wantAlgo)
+			}
+
+			// Verify the certificate with x/crypto/ssh.
+			caKey, err := ssh.NewPublicKey(tt.signer.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checker := &ssh.CertChecker{
+				IsUserAuthority: func(auth ssh.PublicKey) bool {
+					return bytes.Equal(auth.Marshal(), caKey.Marshal())
+				},
+			}
+			if err := checker.CheckCert("jane", got); err != nil {
+				t.Errorf("CertChecker.CheckCert() error = %v", err)
+			}
+
+			// Parse the marshaled certificate.
+			pub, err := ssh.ParsePublicKey(got.Marshal())
+			if err != nil {
+				t.Fatalf("ssh.ParsePublicKey() error = %v", err)
+			}
+			if _, ok := pub.(*ssh.Certificate); !ok {
+				t.Errorf("ssh.ParsePublicKey() = %T, want *ssh.Certificate", pub)
+			}
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("the data")
+	tests := []struct {
+		name      string
+		signer    crypto.Signer
+		algorithm string
+		want      string
+		wantErr   bool
+	}{
+		{"ok rsa default", rsaKey, "", ssh.KeyAlgoRSASHA256, false},
+		{"ok rsa-sha2-256", rsaKey, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA256, false},
+		{"ok rsa-sha2-512", rsaKey, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA512, false},
+		{"ok ssh-rsa", rsaKey, ssh.KeyAlgoRSA, ssh.KeyAlgoRSA, false},
+		{"ok ed25519", edKey, "", ssh.KeyAlgoED25519, false},
+		{"fail ed25519 algorithm", edKey, ssh.KeyAlgoRSASHA256, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSigner(tt.signer)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			var sig *ssh.Signature
+			if tt.algorithm == "" {
+				sig, err = s.Sign(rand.Reader, data)
+			} else {
+				sig, err = s.SignWithAlgorithm(rand.Reader, data, tt.algorithm)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AlgorithmSigner.SignWithAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sig.Format != tt.want {
+				t.Errorf("AlgorithmSigner.SignWithAlgorithm() format = %s, want %s", sig.Format, tt.want)
+			}
+			if err := s.PublicKey().Verify(data, sig); err != nil {
+				t.Errorf("PublicKey.Verify() error = %v", err)
+			}
+		})
+	}
+}
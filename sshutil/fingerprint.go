@@ -31,6 +31,11 @@ const (
 	Base64RawURLFingerprint = fingerprint.Base64RawURLFingerprint
 	// EmojiFingerprint represents the emoji encoding of the fingerprint.
 	EmojiFingerprint = fingerprint.EmojiFingerprint
+	// LegacyMD5Fingerprint represents the legacy MD5 fingerprint of a key,
+	// encoded as colon separated hex bytes. This is the format used by
+	// OpenSSH with `ssh-keygen -E md5`. This encoding is only supported by the
+	// sshutil package.
+	LegacyMD5Fingerprint = FingerprintEncoding(-1)
 )
 
 // Fingerprint returns the SHA-256 fingerprint of an ssh public key or
@@ -40,13 +45,16 @@ func Fingerprint(pub ssh.PublicKey) string {
 }
 
 // EncodedFingerprint returns the SHA-256 hash of an ssh public key or
-// certificate using the specified encoding. If an invalid encoding is passed,
-// the return value will be an empty string.
+// certificate using the specified encoding. If LegacyMD5Fingerprint is used,
+// the MD5 hash will be returned in the "MD5:xx:xx:..." format. If an invalid
+// encoding is passed, the return value will be an empty string.
 func EncodedFingerprint(pub ssh.PublicKey, encoding FingerprintEncoding) string {
 	var fp string
 
 	sum := sha256.Sum256(pub.Marshal())
 	switch encoding {
+	case LegacyMD5Fingerprint:
+		return "MD5:" + ssh.FingerprintLegacyMD5(pub)
 	case DefaultFingerprint:
 		fp = fingerprint.Fingerprint(sum[:], Base64RawFingerprint)
 	default:
@@ -58,6 +66,28 @@ func EncodedFingerprint(pub ssh.PublicKey, encoding FingerprintEncoding) string
 	return "SHA256:" + fp
 }
 
+// AuthorizedKeyFingerprint parses a public key from an authorized_keys file
+// used in OpenSSH and returns its fingerprint using the specified encoding,
+// e.g. "SHA256:<base64-raw-fingerprint>" with the default encoding.
+//
+// If the input is an SSH certificate, its public key will be extracted and
+// taken as input for the fingerprint.
+func AuthorizedKeyFingerprint(in []byte, encoding FingerprintEncoding) (string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(in)
+	if err != nil {
+		return "", fmt.Errorf("error parsing public key: %w", err)
+	}
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	fp := EncodedFingerprint(key, encoding)
+	if fp == "" {
+		return "", fmt.Errorf("unsupported encoding format %v", encoding)
+	}
+	return fp, nil
+}
+
 // FormatFingerprint parses a public key from an authorized_keys file used in
 // OpenSSH and returns a public key fingerprint in the following format:
 //
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -96,6 +97,7 @@ func TestEncodedFingerprint(t *testing.T) {
 		{"Base64URLFingerprint", args{sshECKey, Base64URLFingerprint}, "SHA256:" + base64.URLEncoding.EncodeToString(b)},
 		{"HexFingerprint", args{sshECKey, HexFingerprint}, "SHA256:" + hex.EncodeToString(b)},
 		{"EmojiFingerprint", args{sshECKey, EmojiFingerprint}, "SHA256:" + emoji.Emoji(b)},
+		{"LegacyMD5Fingerprint", args{sshECKey, LegacyMD5Fingerprint}, "MD5:" + ssh.FingerprintLegacyMD5(sshECKey)},
 		{"fail", args{sshECKey, 100}, ""},
 	}
 	for _, tt := range tests {
@@ -204,6 +206,46 @@ func TestFormatFingerprint(t *testing.T) {
 	}
 }
 
+func TestAuthorizedKeyFingerprint(t *testing.T) {
+	mustReadFile := func(filename string) []byte {
+		t.Helper()
+		b, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		return b
+	}
+
+	ed25519Key := mustReadFile("testdata/ed25519.pub")
+	rsaKey := mustReadFile("testdata/rsa.pub")
+
+	type args struct {
+		in       []byte
+		encoding FingerprintEncoding
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      string
+		assertion assert.ErrorAssertionFunc
+	}{
+		// Outputs from `ssh-keygen -lf` and `ssh-keygen -E md5 -lf`
+		{"ok ed25519", args{ed25519Key, DefaultFingerprint}, "SHA256:3p6W5pxkmuXaEY/JrT+vCjohcD5EYpE59CeWsvHdesE", assert.NoError},
+		{"ok ed25519 md5", args{ed25519Key, LegacyMD5Fingerprint}, "MD5:cb:48:2c:2d:dd:51:dd:9e:c4:c9:9c:d4:ae:7e:6f:8d", assert.NoError},
+		{"ok rsa", args{rsaKey, DefaultFingerprint}, "SHA256:t18ARcZBO2eF4mge3AE932vvxa/zq8FaiwaRaS4sH/s", assert.NoError},
+		{"ok rsa md5", args{rsaKey, LegacyMD5Fingerprint}, "MD5:32:37:b6:4c:0b:ab:38:71:18:71:10:58:a8:14:2f:ee", assert.NoError},
+		{"ok rsa hex", args{rsaKey, HexFingerprint}, "SHA256:b75f0045c6413b6785e2681edc013ddf6befc5aff3abc15a8b0691692e2c1ffb", assert.NoError},
+		{"ok certificate", args{[]byte(fixtureECDSACertificate), DefaultFingerprint}, "SHA256:RvkDPGwl/G9d7LUFm1kmWhvOD9I/moPq4yxcb0STwr0", assert.NoError},
+		{"fail input", args{ed25519Key[:50], DefaultFingerprint}, "", assert.Error},
+		{"fail encoding", args{ed25519Key, 100}, "", assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AuthorizedKeyFingerprint(tt.args.in, tt.args.encoding)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestFormatCertificateFingerprint(t *testing.T) {
 	ecKey, sshECKey := generateKey(t, "EC", "P-256", 0)
 	_ = ecKey
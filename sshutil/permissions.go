@@ -0,0 +1,192 @@
+package sshutil
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Critical options defined by OpenSSH in PROTOCOL.certkeys. They are only
+// valid in user certificates.
+const (
+	ForceCommandOption   = "force-command"
+	SourceAddressOption  = "source-address"
+	VerifyRequiredOption = "verify-required"
+)
+
+// Extensions defined by OpenSSH in PROTOCOL.certkeys. They are only valid in
+// user certificates.
+const (
+	NoTouchRequiredExtension       = "no-touch-required"
+	PermitX11ForwardingExtension   = "permit-X11-forwarding"
+	PermitAgentForwardingExtension = "permit-agent-forwarding"
+	PermitPortForwardingExtension  = "permit-port-forwarding"
+	PermitPTYExtension             = "permit-pty"
+	PermitUserRCExtension          = "permit-user-rc"
+)
+
+var knownExtensions = map[string]bool{
+	NoTouchRequiredExtension:       true,
+	PermitX11ForwardingExtension:   true,
+	PermitAgentForwardingExtension: true,
+	PermitPortForwardingExtension:  true,
+	PermitPTYExtension:             true,
+	PermitUserRCExtension:          true,
+}
+
+// NewCriticalOptions returns the critical options of a user certificate with
+// the given force-command and source-address. The options are not set if the
+// command or the addresses are empty. The addresses are normalized using
+// SourceAddress.
+func NewCriticalOptions(forceCommand string, sourceAddresses ...string) (map[string]string, error) {
+	opts := make(map[string]string)
+	if forceCommand != "" {
+		opts[ForceCommandOption] = forceCommand
+	}
+	if len(sourceAddresses) > 0 {
+		s, err := SourceAddress(sourceAddresses...)
+		if err != nil {
+			return nil, err
+		}
+		opts[SourceAddressOption] = s
+	}
+	return opts, nil
+}
+
+// NewExtensions returns the extensions of a user certificate with the given
+// names. All the OpenSSH extensions have an empty value. Names must be one of
+// the extensions defined by OpenSSH, or a custom extension in the
+// name@domain format.
+func NewExtensions(names ...string) (map[string]string, error) {
+	ext := make(map[string]string, len(names))
+	for _, name := range names {
+		ext[name] = ""
+	}
+	return ValidateExtensions(UserCert, ext)
+}
+
+// SourceAddress returns the value of the source-address critical option for
+// the given addresses. Each address can be an IP address, a CIDR, or a comma
+// separated list of them. CIDRs are normalized to the network address, e.g.
+// "10.1.2.3/8" becomes "10.0.0.0/8", and duplicates are removed.
+func SourceAddress(addrs ...string) (string, error) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		for _, s := range strings.Split(addr, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				return "", errors.Errorf("invalid %s %q: address cannot be empty", SourceAddressOption, addr)
+			}
+			var normalized string
+			if strings.Contains(s, "/") {
+				_, ipNet, err := net.ParseCIDR(s)
+				if err != nil {
+					return "", errors.Errorf("invalid %s %q: %q is not a valid CIDR", SourceAddressOption, addr, s)
+				}
+				normalized = ipNet.String()
+			} else {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					return "", errors.Errorf("invalid %s %q: %q is not a valid IP address", SourceAddressOption, addr, s)
+				}
+				normalized = ip.String()
+			}
+			if !seen[normalized] {
+				seen[normalized] = true
+				list = append(list, normalized)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return "", errors.Errorf("invalid %s: addresses cannot be empty", SourceAddressOption)
+	}
+	return strings.Join(list, ","), nil
+}
+
+// ValidateCriticalOptions validates the critical options for the given
+// certificate type, and returns a copy of them with the source-address
+// normalized. OpenSSH refuses certificates with unknown critical options, so
+// only the ones defined by OpenSSH are allowed, and only in user certificates.
+func ValidateCriticalOptions(ct CertType, opts map[string]string) (map[string]string, error) {
+	if len(opts) == 0 {
+		return opts, nil
+	}
+	if ct != UserCert {
+		return nil, errors.Errorf("critical options are not supported in %s certificates", ct)
+	}
+
+	ret := make(map[string]string, len(opts))
+	for _, name := range sortedKeys(opts) {
+		value := opts[name]
+		switch name {
+		case ForceCommandOption:
+			if value == "" {
+				return nil, errors.Errorf("invalid %s: command cannot be empty", ForceCommandOption)
+			}
+		case SourceAddressOption:
+			s, err := SourceAddress(value)
+			if err != nil {
+				return nil, err
+			}
+			value = s
+		case VerifyRequiredOption:
+			if value != "" {
+				return nil, errors.Errorf("invalid %s: value must be empty", VerifyRequiredOption)
+			}
+		default:
+			return nil, errors.Errorf("unknown critical option %q", name)
+		}
+		ret[name] = value
+	}
+	return ret, nil
+}
+
+// ValidateExtensions validates the extensions for the given certificate type
+// and returns a copy of them. The extensions defined by OpenSSH must have an
+// empty value, and they are only valid in user certificates. Other extensions
+// must use the name@domain format.
+func ValidateExtensions(ct CertType, ext map[string]string) (map[string]string, error) {
+	if ext == nil {
+		return nil, nil
+	}
+
+	ret := make(map[string]string, len(ext))
+	for _, name := range sortedKeys(ext) {
+		value := ext[name]
+		switch {
+		case knownExtensions[name]:
+			if ct != UserCert {
+				return nil, errors.Errorf("extension %q is not supported in %s certificates", name, ct)
+			}
+			if value != "" {
+				return nil, errors.Errorf("invalid extension %q: value must be empty", name)
+			}
+		case isCustomExtension(name):
+		default:
+			return nil, errors.Errorf("unknown extension %q", name)
+		}
+		ret[name] = value
+	}
+	return ret, nil
+}
+
+// isCustomExtension returns true if the name uses the name@domain format used
+// for extensions not defined by OpenSSH.
+func isCustomExtension(name string) bool {
+	i := strings.Index(name, "@")
+	return i > 0 && i < len(name)-1 && strings.Count(name, "@") == 1
+}
+
+// sortedKeys returns the keys of the map in order, so errors are
+// deterministic.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package sshutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewCriticalOptions(t *testing.T) {
+	type args struct {
+		forceCommand    string
+		sourceAddresses []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok empty", args{"", nil}, map[string]string{}, false},
+		{"ok force-command", args{"/usr/bin/true", nil}, map[string]string{
+			ForceCommandOption: "/usr/bin/true",
+		}, false},
+		{"ok source-address", args{"", []string{"10.1.2.3/8", "192.168.1.1"}}, map[string]string{
+			SourceAddressOption: "10.0.0.0/8,192.168.1.1",
+		}, false},
+		{"ok both", args{"ls", []string{"2001:db8::1/32, 10.0.0.1"}}, map[string]string{
+			ForceCommandOption:  "ls",
+			SourceAddressOption: "2001:db8::/32,10.0.0.1",
+		}, false},
+		{"fail source-address", args{"ls", []string{"10.0.0.0/33"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCriticalOptions(tt.args.forceCommand, tt.args.sourceAddresses...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCriticalOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewCriticalOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok empty", nil, map[string]string{}, false},
+		{"ok known", []string{PermitPTYExtension, PermitUserRCExtension, NoTouchRequiredExtension}, map[string]string{
+			"permit-pty": "", "permit-user-rc": "", "no-touch-required": "",
+		}, false},
+		{"ok custom", []string{PermitPTYExtension, "login@github.com"}, map[string]string{
+			"permit-pty": "", "login@github.com": "",
+		}, false},
+		{"fail unknown", []string{PermitPTYExtension, "permit-everything"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewExtensions(tt.names...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewExtensions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewExtensions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		addrs   []string
+		want    string
+		wantErr bool
+	}{
+		{"ok ipv4", []string{"192.168.1.10"}, "192.168.1.10", false},
+		{"ok ipv6", []string{"2001:DB8::1"}, "2001:db8::1", false},
+		{"ok cidr", []string{"192.168.1.10/24"}, "192.168.1.0/24", false},
+		{"ok list", []string{"10.0.0.1/8, 172.16.0.0/12,192.168.0.1"}, "10.0.0.0/8,172.16.0.0/12,192.168.0.1", false},
+		{"ok multiple", []string{"10.0.0.1/8", "::1"}, "10.0.0.0/8,::1", false},
+		{"ok duplicates", []string{"10.0.0.1/8", "10.2.3.4/8,10.0.0.1"}, "10.0.0.0/8,10.0.0.1", false},
+		{"fail empty", nil, "", true},
+		{"fail empty address", []string{"10.0.0.1,"}, "", true},
+		{"fail hostname", []string{"example.com"}, "", true},
+		{"fail cidr mask", []string{"10.0.0.0/33"}, "", true},
+		{"fail cidr address", []string{"10.0.0.256/8"}, "", true},
+		{"fail wildcard", []string{"10.0.0.*"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SourceAddress(tt.addrs...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SourceAddress() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("SourceAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCriticalOptions(t *testing.T) {
+	type args struct {
+		ct   CertType
+		opts map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok nil", args{UserCert, nil}, nil, false},
+		{"ok nil host", args{HostCert, nil}, nil, false},
+		{"ok all", args{UserCert, map[string]string{
+			ForceCommandOption:   "/usr/bin/true",
+			SourceAddressOption:  "10.1.2.3/16,::1",
+			VerifyRequiredOption: "",
+		}}, map[string]string{
+			ForceCommandOption:   "/usr/bin/true",
+			SourceAddressOption:  "10.1.0.0/16,::1",
+			VerifyRequiredOption: "",
+		}, false},
+		{"fail host", args{HostCert, map[string]string{ForceCommandOption: "ls"}}, nil, true},
+		{"fail unknown", args{UserCert, map[string]string{"permit-root": ""}}, nil, true},
+		{"fail force-command", args{UserCert, map[string]string{ForceCommandOption: ""}}, nil, true},
+		{"fail source-address", args{UserCert, map[string]string{SourceAddressOption: "10.0.0.0/8;192.168.0.0/16"}}, nil, true},
+		{"fail source-address empty", args{UserCert, map[string]string{SourceAddressOption: ""}}, nil, true},
+		{"fail verify-required", args{UserCert, map[string]string{VerifyRequiredOption: "yes"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateCriticalOptions(tt.args.ct, tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCriticalOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateCriticalOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExtensions(t *testing.T) {
+	defaults := map[string]string{}
+	for k := range DefaultExtensions(UserCert) {
+		defaults[k] = ""
+	}
+
+	type args struct {
+		ct  CertType
+		ext map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok nil", args{UserCert, nil}, nil, false},
+		{"ok defaults", args{UserCert, defaults}, defaults, false},
+		{"ok custom", args{UserCert, map[string]string{"login@github.com": "alice"}}, map[string]string{"login@github.com": "alice"}, false},
+		{"ok custom host", args{HostCert, map[string]string{"foo@example.com": ""}}, map[string]string{"foo@example.com": ""}, false},
+		{"fail host", args{HostCert, map[string]string{PermitPTYExtension: ""}}, nil, true},
+		{"fail value", args{UserCert, map[string]string{PermitPTYExtension: "yes"}}, nil, true},
+		{"fail unknown", args{UserCert, map[string]string{"permit-everything": ""}}, nil, true},
+		{"fail custom format", args{UserCert, map[string]string{"foo@": ""}}, nil, true},
+		{"fail custom format at", args{UserCert, map[string]string{"foo@bar@example.com": ""}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateExtensions(tt.args.ct, tt.args.ext)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateExtensions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateExtensions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
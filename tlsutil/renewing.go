@@ -0,0 +1,75 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// IssueFunc defines the type of the functions used to issue a new leaf
+// certificate for the given public key. It returns the certificate and the
+// intermediates, without the root. It can be implemented using a minica.CA or
+// an x509util.CreateCertificate call with a KMS signer as the issuer.
+type IssueFunc func(pub crypto.PublicKey) (*x509.Certificate, []*x509.Certificate, error)
+
+// RenewingConfigOptions are the options used in RenewingConfig.
+type RenewingConfigOptions struct {
+	// Signer is the private key of the leaf certificates. It can be a KMS
+	// signer.
+	Signer crypto.Signer
+	// Issue is the function used to issue a new leaf certificate for the
+	// public key of the Signer.
+	Issue IssueFunc
+	// RenewBefore is the time before the certificate expiration when a new
+	// one will be issued. It defaults to 1/3 of the certificate validity.
+	RenewBefore time.Duration
+}
+
+// RenewingConfig returns a tls.Config for a server that issues a new leaf
+// certificate when the current one is about to expire. The certificate is
+// renewed in the background by a Renewer, so handshakes never wait for the
+// issuer, and if a renewal fails the current certificate keeps being used
+// while the Renewer retries.
+//
+// The first certificate is issued by RenewingConfig. The renewals stop when
+// the given context is done.
+func RenewingConfig(ctx context.Context, opts RenewingConfigOptions) (*tls.Config, error) {
+	switch {
+	case opts.Signer == nil:
+		return nil, errors.New("renewing config signer cannot be nil")
+	case opts.Issue == nil:
+		return nil, errors.New("renewing config issue function cannot be nil")
+	case opts.RenewBefore < 0:
+		return nil, errors.New("renewing config renew before cannot be negative")
+	}
+
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	renew := func() (*tls.Certificate, *tls.Config, error) {
+		leaf, chain, err := opts.Issue(opts.Signer.Public())
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error issuing certificate")
+		}
+		cert, err := NewCertificate(leaf, chain, opts.Signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cert, base.Clone(), nil
+	}
+
+	cert, config, err := renew()
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRenewer(cert, config, renew, WithRenewBefore(opts.RenewBefore))
+	if err != nil {
+		return nil, err
+	}
+	r.RunContext(ctx)
+	return r.GetConfig(), nil
+}
@@ -0,0 +1,147 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func testIssueFunc(ca *minica.CA, validity time.Duration, issued *int32) IssueFunc {
+	return func(pub crypto.PublicKey) (*x509.Certificate, []*x509.Certificate, error) {
+		atomic.AddInt32(issued, 1)
+		now := time.Now()
+		cert, err := ca.Sign(&x509.Certificate{
+			DNSNames:  []string{"localhost"},
+			PublicKey: pub,
+			NotBefore: now,
+			NotAfter:  now.Add(validity),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cert, []*x509.Certificate{ca.Intermediate}, nil
+	}
+}
+
+func TestRenewingConfig(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issued int32
+	config, err := RenewingConfig(context.Background(), RenewingConfigOptions{
+		Signer: &opaqueSigner{signer: signer},
+		Issue:  testIssueFunc(ca, time.Hour, &issued),
+	})
+	if err != nil {
+		t.Fatalf("RenewingConfig() error = %v", err)
+	}
+	testHandshake(t, ca, config)
+	if n := atomic.LoadInt32(&issued); n != 1 {
+		t.Errorf("RenewingConfig() issued %d certificates, want 1", n)
+	}
+
+	if _, err := RenewingConfig(context.Background(), RenewingConfigOptions{Issue: testIssueFunc(ca, time.Hour, &issued)}); err == nil {
+		t.Error("RenewingConfig() error = nil, want error")
+	}
+	if _, err := RenewingConfig(context.Background(), RenewingConfigOptions{Signer: signer}); err == nil {
+		t.Error("RenewingConfig() error = nil, want error")
+	}
+	if _, err := RenewingConfig(context.Background(), RenewingConfigOptions{
+		Signer: signer,
+		Issue: func(crypto.PublicKey) (*x509.Certificate, []*x509.Certificate, error) {
+			return nil, nil, errors.New("issue error")
+		},
+	}); err == nil {
+		t.Error("RenewingConfig() error = nil, want error")
+	}
+	if _, err := RenewingConfig(context.Background(), RenewingConfigOptions{
+		Signer:      signer,
+		Issue:       testIssueFunc(ca, time.Hour, &issued),
+		RenewBefore: -time.Minute,
+	}); err == nil {
+		t.Error("RenewingConfig() error = nil, want error")
+	}
+	if _, err := RenewingConfig(context.Background(), RenewingConfigOptions{
+		Signer: signer,
+		Issue:  testIssueFunc(ca, time.Second, &issued),
+	}); err == nil {
+		t.Error("RenewingConfig() error = nil, want error")
+	}
+}
+
+func TestRenewingConfig_renew(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issued int32
+	var failing int32
+	issue := testIssueFunc(ca, time.Minute, &issued)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// With a validity of 1m and renewing 58s before the expiration the
+	// certificate is renewed in the background in less than 2s.
+	config, err := RenewingConfig(ctx, RenewingConfigOptions{
+		Signer: signer,
+		Issue: func(pub crypto.PublicKey) (*x509.Certificate, []*x509.Certificate, error) {
+			if atomic.LoadInt32(&failing) == 1 {
+				return nil, nil, errors.New("issue error")
+			}
+			return issue(pub)
+		},
+		RenewBefore: 58 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("RenewingConfig() error = %v", err)
+	}
+
+	getCertificate := func() *tls.Certificate {
+		t.Helper()
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatalf("GetCertificate() error = %v", err)
+		}
+		return cert
+	}
+
+	first := getCertificate()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&issued) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("RenewingConfig() did not renew the certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Failed renewals keep the current certificate.
+	atomic.StoreInt32(&failing, 1)
+	second := getCertificate()
+	if second == first || second.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+		t.Errorf("GetCertificate() = %v, want the renewed certificate", second.Leaf.SerialNumber)
+	}
+	time.Sleep(2 * time.Second)
+	if got := getCertificate(); got.Leaf.NotAfter.Before(second.Leaf.NotAfter) {
+		t.Errorf("GetCertificate() returned an older certificate")
+	}
+	testHandshake(t, ca, config)
+}
@@ -0,0 +1,61 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+)
+
+// NewCertificate returns a tls.Certificate with the given leaf certificate and
+// chain that uses the given signer as the private key. The signer can be a
+// crypto.Signer returned by a KMS, so the private key never leaves it. The
+// chain must not include the root certificate.
+func NewCertificate(cert *x509.Certificate, chain []*x509.Certificate, signer crypto.Signer) (*tls.Certificate, error) {
+	switch {
+	case cert == nil:
+		return nil, errors.New("certificate cannot be nil")
+	case signer == nil:
+		return nil, errors.New("signer cannot be nil")
+	case !keyutil.Equal(cert.PublicKey, signer.Public()):
+		return nil, errors.New("signer public key does not match the certificate public key")
+	}
+
+	certificate := [][]byte{cert.Raw}
+	for _, c := range chain {
+		certificate = append(certificate, c.Raw)
+	}
+	return &tls.Certificate{
+		Certificate: certificate,
+		PrivateKey:  signer,
+		Leaf:        cert,
+	}, nil
+}
+
+// NewServerConfig returns a tls.Config for a server that uses the given
+// certificate, chain and signer. See NewCertificate.
+func NewServerConfig(cert *x509.Certificate, chain []*x509.Certificate, signer crypto.Signer) (*tls.Config, error) {
+	crt, err := NewCertificate(cert, chain, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*crt},
+	}, nil
+}
+
+// NewGetCertificateFunc returns a function that can be set in the
+// tls.Config GetCertificate property, and always returns the certificate with
+// the given chain and signer. See NewCertificate.
+func NewGetCertificateFunc(cert *x509.Certificate, chain []*x509.Certificate, signer crypto.Signer) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), error) {
+	crt, err := NewCertificate(cert, chain, signer)
+	if err != nil {
+		return nil, err
+	}
+	return func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return crt, nil
+	}, nil
+}
@@ -0,0 +1,136 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+// opaqueSigner hides the type of the private key, like a KMS signer.
+type opaqueSigner struct {
+	signer crypto.Signer
+	calls  int
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func mustSignerCertificate(t *testing.T) (*minica.CA, *x509.Certificate, *opaqueSigner) {
+	t.Helper()
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ca.Sign(&x509.Certificate{
+		DNSNames:  []string{"localhost"},
+		PublicKey: key.Public(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, cert, &opaqueSigner{signer: key}
+}
+
+func testHandshake(t *testing.T, ca *minica.CA, serverConfig *tls.Config) {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Root)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tls.Server(c1, serverConfig).Handshake()
+	}()
+
+	client := tls.Client(c2, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: "localhost",
+		RootCAs:    pool,
+	})
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client handshake error = %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("server handshake error = %v", err)
+	}
+	if n := len(client.ConnectionState().PeerCertificates); n != 2 {
+		t.Errorf("client got %d peer certificates, want 2", n)
+	}
+}
+
+func TestNewServerConfig(t *testing.T) {
+	ca, cert, signer := mustSignerCertificate(t)
+	config, err := NewServerConfig(cert, []*x509.Certificate{ca.Intermediate}, signer)
+	if err != nil {
+		t.Fatalf("NewServerConfig() error = %v", err)
+	}
+	testHandshake(t, ca, config)
+	if signer.calls == 0 {
+		t.Error("NewServerConfig() signer was not used")
+	}
+}
+
+func TestNewGetCertificateFunc(t *testing.T) {
+	ca, cert, signer := mustSignerCertificate(t)
+	fn, err := NewGetCertificateFunc(cert, []*x509.Certificate{ca.Intermediate}, signer)
+	if err != nil {
+		t.Fatalf("NewGetCertificateFunc() error = %v", err)
+	}
+	testHandshake(t, ca, &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: fn,
+	})
+	if signer.calls == 0 {
+		t.Error("NewGetCertificateFunc() signer was not used")
+	}
+}
+
+func TestNewCertificate_fail(t *testing.T) {
+	_, cert, signer := mustSignerCertificate(t)
+	otherKey, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		signer crypto.Signer
+	}{
+		{"fail nil certificate", nil, signer},
+		{"fail nil signer", cert, nil},
+		{"fail mismatch", cert, otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCertificate(tt.cert, nil, tt.signer); err == nil {
+				t.Error("NewCertificate() error = nil, want error")
+			}
+			if _, err := NewServerConfig(tt.cert, nil, tt.signer); err == nil {
+				t.Error("NewServerConfig() error = nil, want error")
+			}
+			if _, err := NewGetCertificateFunc(tt.cert, nil, tt.signer); err == nil {
+				t.Error("NewGetCertificateFunc() error = nil, want error")
+			}
+		})
+	}
+}
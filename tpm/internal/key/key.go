@@ -106,6 +106,10 @@ type CreateConfig struct {
 	// Size is used to specify the bit size of the key or elliptic curve. For
 	// example, '256' is used to specify curve P-256.
 	Size int
+	// PCRs are the indexes of the PCRs in the SHA-256 bank the key is bound
+	// to. If set, the key can only be used while the PCRs have the values they
+	// had when the key was created.
+	PCRs []int
 }
 
 var tpmEkTemplate *tpm2.Public
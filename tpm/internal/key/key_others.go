@@ -21,6 +21,17 @@ func create(rwc io.ReadWriteCloser, keyName string, config CreateConfig) ([]byte
 		return nil, fmt.Errorf("incorrect key options: %w", err)
 	}
 
+	// Keys bound to PCRs can only be used with a policy session that checks
+	// the current values of the PCRs.
+	if len(config.PCRs) > 0 {
+		digest, err := pcrPolicyDigest(rwc, config.PCRs)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating PCR policy: %w", err)
+		}
+		tmpl.AuthPolicy = digest
+		tmpl.Attributes &^= tpm2.FlagUserWithAuth
+	}
+
 	blob, pub, creationData, _, _, err := tpm2.CreateKey(rwc, srk, tpm2.PCRSelection{}, "", "", tmpl)
 	if err != nil {
 		return nil, fmt.Errorf("CreateKey() failed: %w", err)
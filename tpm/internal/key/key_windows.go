@@ -9,6 +9,10 @@ import (
 )
 
 func create(_ io.ReadWriteCloser, keyName string, config CreateConfig) ([]byte, error) {
+	if len(config.PCRs) > 0 {
+		return nil, fmt.Errorf("PCR policies are not supported on Windows")
+	}
+
 	pcp, err := openPCP()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open PCP: %w", err)
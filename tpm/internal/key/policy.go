@@ -0,0 +1,150 @@
+package key
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// pcrSelection returns the selection of the given PCRs in the SHA-256 bank.
+func pcrSelection(pcrs []int) tpm2.PCRSelection {
+	return tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}
+}
+
+// startPCRPolicySession starts a session of the given type and runs
+// TPM2_PolicyPCR with the current values of the given PCRs. The caller must
+// flush the returned session.
+func startPCRPolicySession(rw io.ReadWriter, pcrs []int, se tpm2.SessionType) (tpmutil.Handle, error) {
+	session, _, err := tpm2.StartAuthSession(rw, tpm2.HandleNull, tpm2.HandleNull,
+		make([]byte, 32), nil, se, tpm2.AlgNull, tpm2.AlgSHA256)
+	if err != nil {
+		return 0, fmt.Errorf("StartAuthSession() failed: %w", err)
+	}
+	if err := tpm2.PolicyPCR(rw, session, nil, pcrSelection(pcrs)); err != nil {
+		tpm2.FlushContext(rw, session) //nolint:errcheck // the PolicyPCR error is returned
+		return 0, fmt.Errorf("PolicyPCR() failed: %w", err)
+	}
+	return session, nil
+}
+
+// pcrPolicyDigest returns the digest of a policy that requires the given PCRs
+// to have their current values.
+func pcrPolicyDigest(rw io.ReadWriter, pcrs []int) ([]byte, error) {
+	session, err := startPCRPolicySession(rw, pcrs, tpm2.SessionTrial)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, session) //nolint:errcheck // the session is not used anymore
+
+	digest, err := tpm2.PolicyGetDigest(rw, session)
+	if err != nil {
+		return nil, fmt.Errorf("PolicyGetDigest() failed: %w", err)
+	}
+	return digest, nil
+}
+
+// SignWithPCRPolicy signs the digest with a key created with PCRs in its
+// CreateConfig. The key is loaded under the SRK from the serialized data, and
+// the signature is authorized with a policy session that checks the current
+// values of the PCRs, so it fails if they have changed since the key was
+// created. The public key is used to select the signature scheme.
+func SignWithPCRPolicy(rw io.ReadWriteCloser, data []byte, pcrs []int, pub crypto.PublicKey, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var sk serializedKey
+	if err := json.Unmarshal(data, &sk); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling key: %w", err)
+	}
+	if sk.Encoding != keyEncodingEncrypted {
+		return nil, fmt.Errorf("unsupported key encoding %s", sk.Encoding)
+	}
+
+	srk, _, err := getPrimaryKeyHandle(rw, commonSrkEquivalentHandle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SRK handle: %w", err)
+	}
+	key, _, err := tpm2.Load(rw, srk, "", sk.Public, sk.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("Load() failed: %w", err)
+	}
+	defer tpm2.FlushContext(rw, key) //nolint:errcheck // the key is not used anymore
+
+	session, err := startPCRPolicySession(rw, pcrs, tpm2.SessionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, session) //nolint:errcheck // the session is not used anymore
+
+	switch p := pub.(type) {
+	case *ecdsa.PublicKey:
+		return signECDSA(rw, session, key, digest, p)
+	case *rsa.PublicKey:
+		return signRSA(rw, session, key, digest, opts)
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+}
+
+// signECDSA is like the function with the same name in go-attestation, but it
+// authorizes the signature with the given policy session.
+func signECDSA(rw io.ReadWriter, session, key tpmutil.Handle, digest []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	orderBits := pub.Curve.Params().N.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(digest) > orderBytes {
+		digest = digest[:orderBytes]
+	}
+	ret := new(big.Int).SetBytes(digest)
+	excess := len(digest)*8 - orderBits
+	if excess > 0 {
+		ret.Rsh(ret, uint(excess))
+	}
+	digest = ret.Bytes()
+
+	sig, err := tpm2.SignWithSession(rw, session, key, "", digest, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign: %w", err)
+	}
+	if sig.ECC == nil {
+		return nil, fmt.Errorf("expected ECDSA signature, got: %v", sig.Alg)
+	}
+	return asn1.Marshal(struct {
+		R *big.Int
+		S *big.Int
+	}{sig.ECC.R, sig.ECC.S})
+}
+
+// signRSA is like the function with the same name in go-attestation, but it
+// authorizes the signature with the given policy session.
+func signRSA(rw io.ReadWriter, session, key tpmutil.Handle, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h, err := tpm2.HashToAlgorithm(opts.HashFunc())
+	if err != nil {
+		return nil, fmt.Errorf("incorrect hash algorithm: %w", err)
+	}
+
+	scheme := &tpm2.SigScheme{
+		Alg:  tpm2.AlgRSASSA,
+		Hash: h,
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		if pss.SaltLength != rsa.PSSSaltLengthAuto && pss.SaltLength != len(digest) {
+			return nil, fmt.Errorf("PSS salt length %d is incorrect, expected rsa.PSSSaltLengthAuto or %d", pss.SaltLength, len(digest))
+		}
+		scheme.Alg = tpm2.AlgRSAPSS
+	}
+
+	sig, err := tpm2.SignWithSession(rw, session, key, "", digest, nil, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign: %w", err)
+	}
+	if sig.RSA == nil {
+		return nil, fmt.Errorf("expected RSA signature, got: %v", sig.Alg)
+	}
+	return sig.RSA.Signature, nil
+}
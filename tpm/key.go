@@ -24,6 +24,7 @@ type Key struct {
 	attestedBy string
 	chain      []*x509.Certificate
 	createdAt  time.Time
+	pcrs       []int
 	blobs      *Blobs
 	tpm        *TPM
 }
@@ -77,6 +78,12 @@ func (k *Key) CertificateChain() []*x509.Certificate {
 	return k.chain
 }
 
+// PCRs returns the indexes of the PCRs in the SHA-256 bank the Key
+// is bound to. It returns nil if the Key is not bound to PCRs.
+func (k *Key) PCRs() []int {
+	return k.pcrs
+}
+
 // CreatedAt returns the the creation time of the Key.
 func (k *Key) CreatedAt() time.Time {
 	return k.createdAt.Truncate(time.Second)
@@ -94,12 +101,14 @@ func (k *Key) MarshalJSON() ([]byte, error) {
 		AttestedBy string    `json:"attestedBy,omitempty"`
 		Chain      [][]byte  `json:"chain,omitempty"`
 		CreatedAt  time.Time `json:"createdAt"`
+		PCRs       []int     `json:"pcrs,omitempty"`
 	}{
 		Name:       k.name,
 		Data:       k.data,
 		AttestedBy: k.attestedBy,
 		Chain:      chain,
 		CreatedAt:  k.createdAt,
+		PCRs:       k.pcrs,
 	}
 	return json.Marshal(o)
 }
@@ -118,6 +127,11 @@ type CreateKeyConfig struct {
 	// Size is used to specify the bit size of the key or elliptic curve. For
 	// example, '256' is used to specify curve P-256.
 	Size int
+	// PCRs are the indexes of the PCRs in the SHA-256 bank the Key is bound
+	// to. If set, the Key can only be used to sign while the PCRs have the
+	// values they had when the Key was created. PCRs are not supported on
+	// Windows.
+	PCRs []int
 
 	// TODO(hs): move key name to this struct?
 }
@@ -144,7 +158,7 @@ type AttestKeyConfig struct {
 // a random 10 character name is generated. If a Key with the same name exists,
 // `ErrExists` is returned. The Key won't be attested by an AK.
 func (t *TPM) CreateKey(ctx context.Context, name string, config CreateKeyConfig) (key *Key, err error) {
-	if err = t.open(ctx); err != nil {
+	if err = t.open(goTPMCall(ctx)); err != nil {
 		return nil, fmt.Errorf("failed opening TPM: %w", err)
 	}
 	defer closeTPM(ctx, t, &err)
@@ -161,6 +175,7 @@ func (t *TPM) CreateKey(ctx context.Context, name string, config CreateKeyConfig
 	createConfig := internalkey.CreateConfig{
 		Algorithm: config.Algorithm,
 		Size:      config.Size,
+		PCRs:      config.PCRs,
 	}
 	data, err := internalkey.Create(t.rwc, prefixKey(name), createConfig)
 	if err != nil {
@@ -171,6 +186,7 @@ func (t *TPM) CreateKey(ctx context.Context, name string, config CreateKeyConfig
 		name:      name,
 		data:      data,
 		createdAt: now,
+		pcrs:      config.PCRs,
 		tpm:       t,
 	}
 
@@ -446,6 +462,7 @@ func (k *Key) toStorage() *storage.Key {
 		AttestedBy: k.attestedBy,
 		Chain:      k.chain,
 		CreatedAt:  k.createdAt.UTC(),
+		PCRs:       k.pcrs,
 	}
 }
 
@@ -458,6 +475,7 @@ func keyFromStorage(sk *storage.Key, t *TPM) *Key {
 		attestedBy: sk.AttestedBy,
 		chain:      sk.Chain,
 		createdAt:  sk.CreatedAt.Local(),
+		pcrs:       sk.PCRs,
 		tpm:        t,
 	}
 }
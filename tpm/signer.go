@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 
+	internalkey "go.step.sm/crypto/tpm/internal/key"
 	"go.step.sm/crypto/tpm/storage"
 )
 
@@ -27,6 +28,9 @@ func (s *signer) Public() crypto.PublicKey {
 // will reload the TPM key to be used.
 func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (signature []byte, err error) {
 	ctx := context.Background()
+	if len(s.key.pcrs) > 0 {
+		return s.signWithPCRPolicy(ctx, digest, opts)
+	}
 	if err = s.tpm.open(ctx); err != nil {
 		return nil, fmt.Errorf("failed opening TPM: %w", err)
 	}
@@ -52,6 +56,23 @@ func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (si
 	return signer.Sign(rand, digest, opts)
 }
 
+// signWithPCRPolicy signs the digest with a Key bound to PCRs. The
+// signature is authorized with a policy session, so it fails if the
+// values of the PCRs have changed since the Key was created.
+func (s *signer) signWithPCRPolicy(ctx context.Context, digest []byte, opts crypto.SignerOpts) (signature []byte, err error) {
+	if err = s.tpm.open(goTPMCall(ctx)); err != nil {
+		return nil, fmt.Errorf("failed opening TPM: %w", err)
+	}
+	defer closeTPM(ctx, s.tpm, &err)
+
+	signature, err = internalkey.SignWithPCRPolicy(s.tpm.rwc, s.key.data, s.key.pcrs, s.public, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed signing with TPM key %q: %w", s.key.name, err)
+	}
+
+	return
+}
+
 // GetSigner returns a crypto.Signer for a TPM Key identified by `name`.
 func (t *TPM) GetSigner(ctx context.Context, name string) (csigner crypto.Signer, err error) {
 	if err = t.open(ctx); err != nil {
@@ -84,7 +105,7 @@ func (t *TPM) GetSigner(ctx context.Context, name string) (csigner crypto.Signer
 
 	csigner = &signer{
 		tpm:    t,
-		key:    Key{name: name, data: key.Data, attestedBy: key.AttestedBy, createdAt: key.CreatedAt, tpm: t},
+		key:    Key{name: name, data: key.Data, attestedBy: key.AttestedBy, createdAt: key.CreatedAt, pcrs: key.PCRs, tpm: t},
 		public: loadedKey.Public(),
 	}
 
@@ -73,6 +73,7 @@ type Key struct {
 	AttestedBy string
 	Chain      []*x509.Certificate
 	CreatedAt  time.Time
+	PCRs       []int
 }
 
 // MarshalJSON marshals the Key into JSON.
@@ -88,6 +89,7 @@ func (key *Key) MarshalJSON() ([]byte, error) {
 		Data:       key.Data,
 		AttestedBy: key.AttestedBy,
 		CreatedAt:  key.CreatedAt,
+		PCRs:       key.PCRs,
 	}
 
 	if len(chain) > 0 {
@@ -112,6 +114,7 @@ func (key *Key) UnmarshalJSON(data []byte) error {
 	key.Data = sk.Data
 	key.AttestedBy = sk.AttestedBy
 	key.CreatedAt = sk.CreatedAt
+	key.PCRs = sk.PCRs
 
 	if len(sk.Chain) > 0 {
 		chain := make([]*x509.Certificate, len(sk.Chain))
@@ -159,6 +162,7 @@ type serializedKey struct {
 	AttestedBy string        `json:"attestedBy"`
 	Chain      [][]byte      `json:"chain"`
 	CreatedAt  time.Time     `json:"createdAt"`
+	PCRs       []int         `json:"pcrs,omitempty"`
 }
 
 // keyForAK returns the key to use when storing an AK.
@@ -70,6 +70,7 @@ func TestKey_MarshalUnmarshal(t *testing.T) {
 		AttestedBy: "ak1",
 		Chain:      []*x509.Certificate{cert, ca.Intermediate},
 		CreatedAt:  time.Time{},
+		PCRs:       []int{0, 7},
 	}
 
 	data, err := json.Marshal(key)
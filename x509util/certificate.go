@@ -6,6 +6,7 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 
 	"github.com/pkg/errors"
@@ -55,7 +56,11 @@ func NewCertificate(cr *x509.CertificateRequest, opts ...Option) (*Certificate,
 	// If no template use only the certificate request with the default leaf key
 	// usages.
 	if o.CertBuffer == nil {
-		return NewCertificateRequestFromX509(cr).GetLeafCertificate(), nil
+		cert := NewCertificateRequestFromX509(cr).GetLeafCertificate()
+		if err := cert.addExtensions(o.Extensions); err != nil {
+			return nil, err
+		}
+		return cert, nil
 	}
 
 	// With templates
@@ -80,6 +85,10 @@ func NewCertificate(cr *x509.CertificateRequest, opts ...Option) (*Certificate,
 		cert.Extensions = append([]Extension{ext}, cert.Extensions...)
 	}
 
+	if err := cert.addExtensions(o.Extensions); err != nil {
+		return nil, err
+	}
+
 	return &cert, nil
 }
 
@@ -162,6 +171,19 @@ func (c *Certificate) hasExtension(oid ObjectIdentifier) bool {
 	return false
 }
 
+// addExtensions appends the given extensions to the certificate. It fails if
+// an extension is already in the certificate.
+func (c *Certificate) addExtensions(extensions []pkix.Extension) error {
+	for _, e := range extensions {
+		ext := newExtension(e)
+		if c.hasExtension(ext.ID) {
+			return errors.Errorf("error adding extension: extension %s is already in the certificate", e.Id)
+		}
+		c.Extensions = append(c.Extensions, ext)
+	}
+	return nil
+}
+
 // CreateCertificate signs the given template using the parent private key and
 // returns it.
 func CreateCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
@@ -2,6 +2,9 @@ package x509util
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -189,21 +192,68 @@ func (c *CertificateRequest) hasExtension(oid ObjectIdentifier) bool {
 // CreateCertificateRequest creates a simple X.509 certificate request with the
 // given common name and sans.
 func CreateCertificateRequest(commonName string, sans []string, signer crypto.Signer) (*x509.CertificateRequest, error) {
+	asn1Data, err := CreateCertificateRequestDER(pkix.Name{
+		CommonName: commonName,
+	}, sans, signer)
+	if err != nil {
+		return nil, err
+	}
+	// This should not fail
+	return x509.ParseCertificateRequest(asn1Data)
+}
+
+// CreateCertificateRequestDER creates an X.509 certificate request with the
+// given subject and sans, and returns it DER encoded. The sans are split into
+// DNS names, IP addresses, email addresses and URIs using SplitSANs.
+//
+// The request is signed using the given signer, and the signature algorithm is
+// chosen from the type of its public key, so only the signer Sign method is
+// used, this allows the use of signers backed by a KMS.
+func CreateCertificateRequestDER(subject pkix.Name, sans []string, signer crypto.Signer) ([]byte, error) {
+	if signer == nil {
+		return nil, errors.New("error creating certificate request: signer cannot be nil")
+	}
+	sigAlg, err := signatureAlgorithmForKey(signer.Public())
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating certificate request")
+	}
 	dnsNames, ips, emails, uris := SplitSANs(sans)
 	asn1Data, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
-		Subject: pkix.Name{
-			CommonName: commonName,
-		},
-		DNSNames:       dnsNames,
-		IPAddresses:    ips,
-		EmailAddresses: emails,
-		URIs:           uris,
+		Subject:            subject,
+		DNSNames:           dnsNames,
+		IPAddresses:        ips,
+		EmailAddresses:     emails,
+		URIs:               uris,
+		SignatureAlgorithm: sigAlg,
 	}, signer)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating certificate request")
 	}
-	// This should not fail
-	return x509.ParseCertificateRequest(asn1Data)
+	return asn1Data, nil
+}
+
+// signatureAlgorithmForKey returns the signature algorithm to use with the
+// given public key. ECDSA keys use a hash matching the size of the curve.
+func signatureAlgorithmForKey(pub crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return x509.ECDSAWithSHA256, nil
+		case elliptic.P384():
+			return x509.ECDSAWithSHA384, nil
+		case elliptic.P521():
+			return x509.ECDSAWithSHA512, nil
+		default:
+			return x509.UnknownSignatureAlgorithm, errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, errors.Errorf("unsupported public key type %T", pub)
+	}
 }
 
 // fixSubjectAltName makes sure to mark the SAN extension to critical if the
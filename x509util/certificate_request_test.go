@@ -2,7 +2,9 @@ package x509util
 
 import (
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -10,6 +12,7 @@ import (
 	"encoding/asn1"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"reflect"
@@ -530,3 +533,102 @@ func TestCreateCertificateRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateCertificateRequestDER(t *testing.T) {
+	mustSigner := func(fn func() (crypto.Signer, error)) crypto.Signer {
+		t.Helper()
+		signer, err := fn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signer
+	}
+	p256 := mustSigner(func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) })
+	p384 := mustSigner(func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) })
+	p521 := mustSigner(func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P521(), rand.Reader) })
+	p224 := mustSigner(func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P224(), rand.Reader) })
+	rsaKey := mustSigner(func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) })
+	edKey := mustSigner(func() (crypto.Signer, error) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	})
+
+	subject := pkix.Name{CommonName: "foo.bar", Organization: []string{"Smallstep"}}
+	sans := []string{"foo.bar", "john@doe.com", "uri:uuid:48da8308-b399-4748-861f-cb418362f820", "1.2.3.4"}
+
+	type args struct {
+		subject pkix.Name
+		sans    []string
+		signer  crypto.Signer
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantAlg x509.SignatureAlgorithm
+		wantErr bool
+	}{
+		{"ok P-256", args{subject, sans, p256}, x509.ECDSAWithSHA256, false},
+		{"ok P-384", args{subject, sans, p384}, x509.ECDSAWithSHA384, false},
+		{"ok P-521", args{subject, sans, p521}, x509.ECDSAWithSHA512, false},
+		{"ok RSA", args{subject, sans, rsaKey}, x509.SHA256WithRSA, false},
+		{"ok Ed25519", args{subject, sans, edKey}, x509.PureEd25519, false},
+		{"ok opaque signer", args{subject, sans, &opaqueSigner{p384}}, x509.ECDSAWithSHA384, false},
+		{"ok no sans", args{subject, nil, p256}, x509.ECDSAWithSHA256, false},
+		{"fail nil signer", args{subject, sans, nil}, 0, true},
+		{"fail sign", args{subject, sans, createBadSigner(t)}, 0, true},
+		{"fail curve", args{subject, sans, p224}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CreateCertificateRequestDER(tt.args.subject, tt.args.sans, tt.args.signer)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateCertificateRequestDER() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			cr, err := x509.ParseCertificateRequest(got)
+			if err != nil {
+				t.Fatalf("x509.ParseCertificateRequest() error = %v", err)
+			}
+			if err := cr.CheckSignature(); err != nil {
+				t.Errorf("CheckSignature() error = %v", err)
+			}
+			if cr.SignatureAlgorithm != tt.wantAlg {
+				t.Errorf("CreateCertificateRequestDER() signature algorithm = %v, want %v", cr.SignatureAlgorithm, tt.wantAlg)
+			}
+			if cr.Subject.CommonName != "foo.bar" || !reflect.DeepEqual(cr.Subject.Organization, []string{"Smallstep"}) {
+				t.Errorf("CreateCertificateRequestDER() subject = %v, want %v", cr.Subject, tt.args.subject)
+			}
+			// Compare the string representation, the parsed request does
+			// not distinguish nil and empty slices.
+			dnsNames, ips, emails, uris := SplitSANs(tt.args.sans)
+			if fmt.Sprint(cr.DNSNames) != fmt.Sprint(dnsNames) {
+				t.Errorf("CreateCertificateRequestDER() dnsNames = %v, want %v", cr.DNSNames, dnsNames)
+			}
+			if fmt.Sprint(cr.EmailAddresses) != fmt.Sprint(emails) {
+				t.Errorf("CreateCertificateRequestDER() emailAddresses = %v, want %v", cr.EmailAddresses, emails)
+			}
+			if fmt.Sprint(cr.IPAddresses) != fmt.Sprint(ips) {
+				t.Errorf("CreateCertificateRequestDER() ipAddresses = %v, want %v", cr.IPAddresses, ips)
+			}
+			if fmt.Sprint(cr.URIs) != fmt.Sprint(uris) {
+				t.Errorf("CreateCertificateRequestDER() uris = %v, want %v", cr.URIs, uris)
+			}
+		})
+	}
+}
+
+// opaqueSigner hides the type of the private key, like a KMS signer.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
@@ -419,6 +419,68 @@ func TestCertificate_GetCertificate(t *testing.T) {
 	}
 }
 
+func TestNewCertificate_withExtensions(t *testing.T) {
+	iss, issPriv := createIssuerCertificate(t, "issuer")
+	cr, _ := createCertificateRequest(t, "commonName", []string{"foo.com"})
+
+	custom := pkix.Extension{
+		Id:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1},
+		Critical: true,
+		Value:    []byte{0x0c, 0x05, 'h', 'e', 'l', 'l', 'o'},
+	}
+	// Embedded SCT list extension, RFC 6962 section 3.3, with an empty list.
+	sct := pkix.Extension{
+		Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+		Value: []byte{0x04, 0x02, 0x00, 0x00},
+	}
+	template := `{"subject": {{ toJson .Subject }}, "extensions": [{"id": "1.2.3.4", "value": "AQI="}]}`
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    []pkix.Extension
+		wantErr bool
+	}{
+		{"ok", []Option{WithExtensions(custom, sct)}, []pkix.Extension{custom, sct}, false},
+		{"ok multiple options", []Option{WithExtensions(custom), WithExtensions(sct)}, []pkix.Extension{custom, sct}, false},
+		{"ok template", []Option{WithTemplate(template, CreateTemplateData("commonName", nil)), WithExtensions(custom)}, []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{1, 2}}, custom,
+		}, false},
+		{"fail duplicated", []Option{WithExtensions(custom, custom)}, nil, true},
+		{"fail in template", []Option{WithTemplate(template, CreateTemplateData("commonName", nil)), WithExtensions(pkix.Extension{
+			Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{1, 2},
+		})}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := NewCertificate(cr, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			tpl := cert.GetCertificate()
+			tpl.SerialNumber = big.NewInt(1)
+			crt, err := CreateCertificate(tpl, iss, cr.PublicKey, issPriv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				got, ok := GetExtension(crt, want.Id)
+				if !ok {
+					t.Errorf("GetExtension() extension %s not found", want.Id)
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("GetExtension() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestCreateCertificate(t *testing.T) {
 	iss, issPriv := createIssuerCertificate(t, "issuer")
 
@@ -653,3 +715,102 @@ func TestCreateCertificate_debug(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCertificate_nameConstraints(t *testing.T) {
+	issuer, issuerSigner := createIssuerCertificate(t, "issuer")
+	cr, signer := createCertificateRequest(t, "Constrained Intermediate", nil)
+
+	mustIPNet := func(s string) *net.IPNet {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipNet
+	}
+
+	data := CreateTemplateData("Constrained Intermediate", nil)
+	data.SetNameConstraints(NameConstraints{
+		Critical:                true,
+		PermittedDNSDomains:     []string{"example.com"},
+		ExcludedDNSDomains:      []string{"internal.example.com"},
+		PermittedIPRanges:       []*net.IPNet{mustIPNet("10.0.0.0/8"), mustIPNet("2001:db8::/32")},
+		ExcludedIPRanges:        []*net.IPNet{mustIPNet("10.10.0.0/16")},
+		PermittedEmailAddresses: []string{"example.com"},
+		PermittedURIDomains:     []string{".example.com"},
+	})
+	c, err := NewCertificate(cr, WithTemplate(DefaultConstrainedIntermediateTemplate, data))
+	if err != nil {
+		t.Fatalf("NewCertificate() error = %v", err)
+	}
+	template := c.GetCertificate()
+	template.NotBefore = issuer.NotBefore
+	template.NotAfter = issuer.NotAfter
+	intermediate, err := CreateCertificate(template, issuer, cr.PublicKey, issuerSigner)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+
+	if !intermediate.IsCA || !intermediate.MaxPathLenZero {
+		t.Errorf("CreateCertificate() isCA = %v, maxPathLenZero = %v, want true, true", intermediate.IsCA, intermediate.MaxPathLenZero)
+	}
+	if intermediate.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+		t.Errorf("CreateCertificate() keyUsage = %v, want %v", intermediate.KeyUsage, x509.KeyUsageCertSign|x509.KeyUsageCRLSign)
+	}
+	if !intermediate.PermittedDNSDomainsCritical {
+		t.Error("CreateCertificate() name constraints are not critical")
+	}
+	if !reflect.DeepEqual(intermediate.PermittedDNSDomains, []string{"example.com"}) {
+		t.Errorf("CreateCertificate() permittedDNSDomains = %v, want [example.com]", intermediate.PermittedDNSDomains)
+	}
+	if !reflect.DeepEqual(intermediate.ExcludedDNSDomains, []string{"internal.example.com"}) {
+		t.Errorf("CreateCertificate() excludedDNSDomains = %v, want [internal.example.com]", intermediate.ExcludedDNSDomains)
+	}
+	if got := fmt.Sprint(intermediate.PermittedIPRanges); got != "[10.0.0.0/8 2001:db8::/32]" {
+		t.Errorf("CreateCertificate() permittedIPRanges = %v, want [10.0.0.0/8 2001:db8::/32]", got)
+	}
+	if got := fmt.Sprint(intermediate.ExcludedIPRanges); got != "[10.10.0.0/16]" {
+		t.Errorf("CreateCertificate() excludedIPRanges = %v, want [10.10.0.0/16]", got)
+	}
+	if !reflect.DeepEqual(intermediate.PermittedEmailAddresses, []string{"example.com"}) {
+		t.Errorf("CreateCertificate() permittedEmailAddresses = %v, want [example.com]", intermediate.PermittedEmailAddresses)
+	}
+	if !reflect.DeepEqual(intermediate.PermittedURIDomains, []string{".example.com"}) {
+		t.Errorf("CreateCertificate() permittedURIDomains = %v, want [.example.com]", intermediate.PermittedURIDomains)
+	}
+
+	// The constraints are enforced on the leaves issued by the intermediate.
+	roots := x509.NewCertPool()
+	roots.AddCert(intermediate)
+	tests := []struct {
+		name    string
+		sans    []string
+		wantErr bool
+	}{
+		{"ok dns", []string{"www.example.com"}, false},
+		{"ok ip", []string{"10.1.2.3", "2001:db8::1"}, false},
+		{"fail dns", []string{"www.example.org"}, true},
+		{"fail excluded dns", []string{"www.internal.example.com"}, true},
+		{"fail ip", []string{"192.168.1.1"}, true},
+		{"fail excluded ip", []string{"10.10.1.1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leafCR, _ := createCertificateRequest(t, "", tt.sans)
+			leafTemplate, err := NewCertificate(leafCR)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl := leafTemplate.GetCertificate()
+			tmpl.NotBefore = intermediate.NotBefore
+			tmpl.NotAfter = intermediate.NotAfter
+			leaf, err := CreateCertificate(tmpl, intermediate, leafCR.PublicKey, signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = leaf.Verify(x509.VerifyOptions{Roots: roots})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Certificate.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -198,6 +198,19 @@ func (e Extension) Set(c *x509.Certificate) {
 	})
 }
 
+// GetExtension returns the extension with the given OID in the certificate, it
+// returns false if the certificate does not have the extension. It can be used
+// to read the custom extensions added using WithExtensions or the extensions in
+// a template.
+func GetExtension(c *x509.Certificate, oid asn1.ObjectIdentifier) (pkix.Extension, bool) {
+	for _, e := range c.Extensions {
+		if e.Id.Equal(oid) {
+			return e, true
+		}
+	}
+	return pkix.Extension{}, false
+}
+
 // ObjectIdentifier represents a JSON strings that unmarshals into an ASN1
 // object identifier or OID.
 type ObjectIdentifier asn1.ObjectIdentifier
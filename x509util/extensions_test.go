@@ -130,6 +130,39 @@ func TestExtension_Set(t *testing.T) {
 	}
 }
 
+func TestGetExtension(t *testing.T) {
+	ext := pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Critical: true, Value: []byte("value")}
+	cert := &x509.Certificate{
+		Extensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 15}, Critical: true, Value: []byte{0x03, 0x02, 0x07, 0x80}},
+			ext,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		oid    asn1.ObjectIdentifier
+		want   pkix.Extension
+		wantOK bool
+	}{
+		{"ok", cert, asn1.ObjectIdentifier{1, 2, 3, 4}, ext, true},
+		{"missing", cert, asn1.ObjectIdentifier{1, 2, 3, 5}, pkix.Extension{}, false},
+		{"empty", &x509.Certificate{}, asn1.ObjectIdentifier{1, 2, 3, 4}, pkix.Extension{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOK := GetExtension(tt.cert, tt.oid)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetExtension() got = %v, want %v", got, tt.want)
+			}
+			if gotOK != tt.wantOK {
+				t.Errorf("GetExtension() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+		})
+	}
+}
+
 func TestObjectIdentifier_Equal(t *testing.T) {
 	type args struct {
 		v ObjectIdentifier
@@ -3,6 +3,7 @@ package x509util
 import (
 	"bytes"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	encoding_asn1 "encoding/asn1"
 	"encoding/base64"
 	"os"
@@ -20,6 +21,7 @@ import (
 // Options are the options that can be passed to NewCertificate.
 type Options struct {
 	CertBuffer *bytes.Buffer
+	Extensions []pkix.Extension
 }
 
 func (o *Options) apply(cr *x509.CertificateRequest, opts []Option) (*Options, error) {
@@ -92,6 +94,18 @@ func WithTemplateFile(path string, data TemplateData) Option {
 	}
 }
 
+// WithExtensions is an option that appends the given extensions to the
+// certificate created by NewCertificate, with or without a template. It can be
+// used to add signed certificate timestamps (SCTs), the CT poison extension of
+// precertificates, or proprietary extensions. NewCertificate fails if an
+// extension is already in the certificate.
+func WithExtensions(extensions ...pkix.Extension) Option {
+	return func(cr *x509.CertificateRequest, o *Options) error {
+		o.Extensions = append(o.Extensions, extensions...)
+		return nil
+	}
+}
+
 func asn1Encode(str string) (string, error) {
 	value, params := str, "printable"
 	if strings.Contains(value, sanTypeSeparator) {
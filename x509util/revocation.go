@@ -0,0 +1,302 @@
+package x509util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationStatus is the revocation status of a certificate.
+type RevocationStatus int
+
+const (
+	// RevocationStatusUnknown is the status of a certificate that cannot be
+	// checked, e.g. because it does not have OCSP servers or CRL distribution
+	// points.
+	RevocationStatusUnknown RevocationStatus = iota
+	// RevocationStatusGood is the status of a certificate that is not revoked.
+	RevocationStatusGood
+	// RevocationStatusRevoked is the status of a revoked certificate.
+	RevocationStatusRevoked
+)
+
+// String returns a string representation of s.
+func (s RevocationStatus) String() string {
+	switch s {
+	case RevocationStatusUnknown:
+		return "unknown"
+	case RevocationStatusGood:
+		return "good"
+	case RevocationStatusRevoked:
+		return "revoked"
+	default:
+		return fmt.Sprintf("unknown(%d)", s)
+	}
+}
+
+// maxRevocationResponseSize is the maximum size of the OCSP responses and CRLs
+// downloaded.
+const maxRevocationResponseSize = 10 << 20
+
+// DefaultRevocationChecker is the RevocationChecker used by CheckOCSP.
+var DefaultRevocationChecker = &RevocationChecker{
+	Client: &http.Client{Timeout: 15 * time.Second},
+}
+
+// RevocationChecker checks the revocation status of certificates using the
+// OCSP servers in the authority information access extension and the CRL
+// distribution points of the certificates.
+type RevocationChecker struct {
+	// Client is the HTTP client used to make the requests. If it's nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// HardFail makes VerifyChains reject the certificates with an unknown
+	// revocation status. By default, only revoked certificates are rejected.
+	HardFail bool
+}
+
+// CheckOCSP returns the revocation status of the given certificate using the
+// OCSP servers of the certificate and the DefaultRevocationChecker. The issuer
+// is the certificate that signed cert.
+func CheckOCSP(cert, issuer *x509.Certificate) (RevocationStatus, error) {
+	return DefaultRevocationChecker.CheckOCSP(cert, issuer)
+}
+
+// CheckCRL returns the revocation status of the given certificate in the given
+// CRL. It returns RevocationStatusRevoked if the serial number of the
+// certificate is in the CRL. It fails if the CRL has expired.
+//
+// CheckCRL does not verify the signature of the CRL, use
+// x509.Certificate.CheckCRLSignature with the issuer of the certificate, or
+// RevocationChecker.CheckCRL that downloads and verifies the CRL.
+func CheckCRL(cert *x509.Certificate, crl *pkix.CertificateList) (RevocationStatus, error) {
+	if cert == nil || crl == nil {
+		return RevocationStatusUnknown, errors.New("error checking CRL: certificate and CRL cannot be nil")
+	}
+	if crl.HasExpired(time.Now()) {
+		return RevocationStatusUnknown, errors.New("error checking CRL: CRL has expired")
+	}
+	for _, rc := range crl.TBSCertList.RevokedCertificates {
+		if rc.SerialNumber != nil && rc.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return RevocationStatusRevoked, nil
+		}
+	}
+	return RevocationStatusGood, nil
+}
+
+// CheckOCSP returns the revocation status of the given certificate using the
+// OCSP servers of the certificate. The servers are tried in order until one
+// returns a valid response. It returns RevocationStatusUnknown without an error
+// if the certificate does not have OCSP servers.
+func (c *RevocationChecker) CheckOCSP(cert, issuer *x509.Certificate) (RevocationStatus, error) {
+	if cert == nil || issuer == nil {
+		return RevocationStatusUnknown, errors.New("error checking OCSP: certificate and issuer cannot be nil")
+	}
+	if len(cert.OCSPServer) == 0 {
+		return RevocationStatusUnknown, nil
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return RevocationStatusUnknown, errors.Wrap(err, "error creating OCSP request")
+	}
+
+	var lastErr error
+	for _, server := range cert.OCSPServer {
+		var resp *ocsp.Response
+		if resp, lastErr = c.fetchOCSP(server, req, cert, issuer); lastErr == nil {
+			switch resp.Status {
+			case ocsp.Good:
+				return RevocationStatusGood, nil
+			case ocsp.Revoked:
+				return RevocationStatusRevoked, nil
+			default:
+				return RevocationStatusUnknown, nil
+			}
+		}
+	}
+	return RevocationStatusUnknown, lastErr
+}
+
+// CheckCRL returns the revocation status of the given certificate using the
+// CRL distribution points of the certificate. The CRLs are downloaded in order
+// until one is valid and signed by the issuer. It returns
+// RevocationStatusUnknown without an error if the certificate does not have
+// CRL distribution points.
+func (c *RevocationChecker) CheckCRL(cert, issuer *x509.Certificate) (RevocationStatus, error) {
+	if cert == nil || issuer == nil {
+		return RevocationStatusUnknown, errors.New("error checking CRL: certificate and issuer cannot be nil")
+	}
+	if len(cert.CRLDistributionPoints) == 0 {
+		return RevocationStatusUnknown, nil
+	}
+
+	var lastErr error
+	for _, dp := range cert.CRLDistributionPoints {
+		var crl *pkix.CertificateList
+		if crl, lastErr = c.FetchCRL(dp, issuer); lastErr == nil {
+			var status RevocationStatus
+			if status, lastErr = CheckCRL(cert, crl); lastErr == nil {
+				return status, nil
+			}
+		}
+	}
+	return RevocationStatusUnknown, lastErr
+}
+
+// Check returns the revocation status of the given certificate using OCSP, and
+// the CRL distribution points if the status cannot be checked using OCSP.
+func (c *RevocationChecker) Check(cert, issuer *x509.Certificate) (RevocationStatus, error) {
+	status, ocspErr := c.CheckOCSP(cert, issuer)
+	if status != RevocationStatusUnknown {
+		return status, nil
+	}
+	status, err := c.CheckCRL(cert, issuer)
+	if status != RevocationStatusUnknown {
+		return status, nil
+	}
+	if err == nil {
+		err = ocspErr
+	}
+	return RevocationStatusUnknown, err
+}
+
+// VerifyChains checks the revocation status of the certificates in the given
+// chains, usually the ones returned by x509.Certificate.Verify, and returns the
+// chains without revoked certificates. The root, the last certificate in a
+// chain, is not checked. It fails if all the chains have a revoked
+// certificate, or with HardFail, a certificate with an unknown status.
+func (c *RevocationChecker) VerifyChains(chains [][]*x509.Certificate) ([][]*x509.Certificate, error) {
+	var verified [][]*x509.Certificate
+	var lastErr error
+	for _, chain := range chains {
+		if lastErr = c.verifyChain(chain); lastErr == nil {
+			verified = append(verified, chain)
+		}
+	}
+	if len(verified) == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("error verifying revocation: there are no chains to verify")
+		}
+		return nil, lastErr
+	}
+	return verified, nil
+}
+
+// VerifyChainsWithRevocation verifies the given certificate using the given
+// options, like x509.Certificate.Verify, and checks the revocation status of
+// the certificates in the chains using the given RevocationChecker, see
+// RevocationChecker.VerifyChains. Only the chains without revoked certificates
+// are returned. If checker is nil, DefaultRevocationChecker is used.
+func VerifyChainsWithRevocation(cert *x509.Certificate, opts x509.VerifyOptions, checker *RevocationChecker) ([][]*x509.Certificate, error) {
+	if cert == nil {
+		return nil, errors.New("error verifying certificate: certificate cannot be nil")
+	}
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying certificate")
+	}
+	if checker == nil {
+		checker = DefaultRevocationChecker
+	}
+	return checker.VerifyChains(chains)
+}
+
+func (c *RevocationChecker) verifyChain(chain []*x509.Certificate) error {
+	for i := 0; i < len(chain)-1; i++ {
+		status, err := c.Check(chain[i], chain[i+1])
+		switch {
+		case status == RevocationStatusRevoked:
+			return errors.Errorf("error verifying revocation: certificate %s is revoked", chain[i].SerialNumber)
+		case status == RevocationStatusUnknown && c.HardFail:
+			if err != nil {
+				return errors.Wrapf(err, "error verifying revocation: certificate %s status is unknown", chain[i].SerialNumber)
+			}
+			return errors.Errorf("error verifying revocation: certificate %s status is unknown", chain[i].SerialNumber)
+		}
+	}
+	return nil
+}
+
+// FetchCRL downloads the CRL in the given URL and verifies its signature using
+// the given issuer. The CRL can be DER or PEM encoded.
+func (c *RevocationChecker) FetchCRL(url string, issuer *x509.Certificate) (*pkix.CertificateList, error) {
+	b, err := c.get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "error downloading CRL")
+	}
+	crl, err := x509.ParseCRL(b) //nolint:staticcheck // x509.ParseRevocationList requires Go 1.19
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing CRL from %s", url)
+	}
+	if err := issuer.CheckCRLSignature(crl); err != nil { //nolint:staticcheck // see above
+		return nil, errors.Wrapf(err, "error verifying CRL from %s", url)
+	}
+	return crl, nil
+}
+
+func (c *RevocationChecker) fetchOCSP(server string, req []byte, cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	if !isHTTPURL(server) {
+		return nil, errors.Errorf("error checking OCSP: unsupported OCSP server %s", server)
+	}
+	resp, err := c.client().Post(server, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking OCSP")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error checking OCSP: %s returned status code %d", server, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRevocationResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking OCSP")
+	}
+
+	// ParseResponseForCert verifies the signature of the response, and checks
+	// that it is the response for the given certificate.
+	ocspResp, err := ocsp.ParseResponseForCert(b, cert, issuer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing OCSP response from %s", server)
+	}
+	if !ocspResp.NextUpdate.IsZero() && time.Now().After(ocspResp.NextUpdate) {
+		return nil, errors.Errorf("error checking OCSP: response from %s has expired", server)
+	}
+	return ocspResp, nil
+}
+
+func (c *RevocationChecker) get(url string) ([]byte, error) {
+	if !isHTTPURL(url) {
+		return nil, errors.Errorf("unsupported URL %s", url)
+	}
+	resp, err := c.client().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s returned status code %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRevocationResponseSize))
+}
+
+func (c *RevocationChecker) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return http.DefaultClient
+}
+
+// isHTTPURL returns true if the URL uses the http or https schemes, the ones
+// supported to fetch OCSP responses and CRLs. LDAP URLs are not supported.
+func isHTTPURL(s string) bool {
+	s = strings.ToLower(s)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -0,0 +1,397 @@
+package x509util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type revocationTestCA struct {
+	Root    *x509.Certificate
+	Signer  crypto.Signer
+	Revoked *big.Int
+}
+
+func mustRevocationCA(t *testing.T, commonName string) *revocationTestCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root, err := CreateCertificate(template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &revocationTestCA{Root: root, Signer: key, Revoked: big.NewInt(666)}
+}
+
+func (ca *revocationTestCA) mustSign(t *testing.T, serial int64, ocspServer, crlDistributionPoints []string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	crt, err := CreateCertificate(&x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "leaf"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		OCSPServer:            ocspServer,
+		CRLDistributionPoints: crlDistributionPoints,
+	}, ca.Root, key.Public(), ca.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func (ca *revocationTestCA) mustCRL(t *testing.T, nextUpdate time.Time) []byte {
+	t.Helper()
+	now := time.Now()
+	b, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now.Add(-time.Minute),
+		NextUpdate: nextUpdate,
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: ca.Revoked, RevocationTime: now.Add(-time.Minute)},
+		},
+	}, ca.Root, ca.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// newRevocationServer returns a server with a stubbed OCSP responder in /ocsp
+// and the CRL of the CA in /crl.
+func newRevocationServer(t *testing.T, ca *revocationTestCA) *httptest.Server {
+	t.Helper()
+	crl := ca.mustCRL(t, time.Now().Add(time.Hour))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(time.Hour),
+		}
+		if req.SerialNumber.Cmp(ca.Revoked) == 0 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = now.Add(-time.Minute)
+		}
+		resp, err := ocsp.CreateResponse(ca.Root, ca.Root, template, ca.Signer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	})
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRevocationStatus_String(t *testing.T) {
+	tests := []struct {
+		name string
+		s    RevocationStatus
+		want string
+	}{
+		{"unknown", RevocationStatusUnknown, "unknown"},
+		{"good", RevocationStatusGood, "good"},
+		{"revoked", RevocationStatusRevoked, "revoked"},
+		{"other", RevocationStatus(100), "unknown(100)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.String(); got != tt.want {
+				t.Errorf("RevocationStatus.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCRL(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	good := ca.mustSign(t, 1, nil, nil)
+	revoked := ca.mustSign(t, ca.Revoked.Int64(), nil, nil)
+
+	parse := func(b []byte) *pkix.CertificateList {
+		t.Helper()
+		crl, err := x509.ParseCRL(b) //nolint:staticcheck // x509.ParseRevocationList requires Go 1.19
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+	crl := parse(ca.mustCRL(t, time.Now().Add(time.Hour)))
+	expired := parse(ca.mustCRL(t, time.Now().Add(-time.Second)))
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		crl     *pkix.CertificateList
+		want    RevocationStatus
+		wantErr bool
+	}{
+		{"ok good", good, crl, RevocationStatusGood, false},
+		{"ok revoked", revoked, crl, RevocationStatusRevoked, false},
+		{"fail expired", revoked, expired, RevocationStatusUnknown, true},
+		{"fail nil crl", good, nil, RevocationStatusUnknown, true},
+		{"fail nil cert", nil, crl, RevocationStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckCRL(tt.cert, tt.crl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCRL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CheckCRL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOCSP(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	otherCA := mustRevocationCA(t, "Other CA")
+	srv := newRevocationServer(t, ca)
+	ocspServer := []string{srv.URL + "/ocsp"}
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		issuer  *x509.Certificate
+		want    RevocationStatus
+		wantErr bool
+	}{
+		{"ok good", ca.mustSign(t, 1, ocspServer, nil), ca.Root, RevocationStatusGood, false},
+		{"ok revoked", ca.mustSign(t, ca.Revoked.Int64(), ocspServer, nil), ca.Root, RevocationStatusRevoked, false},
+		{"ok second server", ca.mustSign(t, ca.Revoked.Int64(), []string{srv.URL + "/error", srv.URL + "/ocsp"}, nil), ca.Root, RevocationStatusRevoked, false},
+		{"ok no servers", ca.mustSign(t, 1, nil, nil), ca.Root, RevocationStatusUnknown, false},
+		{"fail server error", ca.mustSign(t, 1, []string{srv.URL + "/error"}, nil), ca.Root, RevocationStatusUnknown, true},
+		{"fail unsupported server", ca.mustSign(t, 1, []string{"ldap://ocsp.example.com"}, nil), ca.Root, RevocationStatusUnknown, true},
+		{"fail other issuer", ca.mustSign(t, 1, ocspServer, nil), otherCA.Root, RevocationStatusUnknown, true},
+		{"fail nil issuer", ca.mustSign(t, 1, ocspServer, nil), nil, RevocationStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckOCSP(tt.cert, tt.issuer)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckOCSP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CheckOCSP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationChecker_CheckCRL(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	otherCA := mustRevocationCA(t, "Other CA")
+	srv := newRevocationServer(t, ca)
+	otherSrv := newRevocationServer(t, otherCA)
+	crlURL := []string{srv.URL + "/crl"}
+
+	c := &RevocationChecker{Client: srv.Client()}
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		issuer  *x509.Certificate
+		want    RevocationStatus
+		wantErr bool
+	}{
+		{"ok good", ca.mustSign(t, 1, nil, crlURL), ca.Root, RevocationStatusGood, false},
+		{"ok revoked", ca.mustSign(t, ca.Revoked.Int64(), nil, crlURL), ca.Root, RevocationStatusRevoked, false},
+		{"ok second distribution point", ca.mustSign(t, ca.Revoked.Int64(), nil, []string{srv.URL + "/error", srv.URL + "/crl"}), ca.Root, RevocationStatusRevoked, false},
+		{"ok no distribution points", ca.mustSign(t, 1, nil, nil), ca.Root, RevocationStatusUnknown, false},
+		{"fail server error", ca.mustSign(t, 1, nil, []string{srv.URL + "/error"}), ca.Root, RevocationStatusUnknown, true},
+		{"fail not a crl", ca.mustSign(t, 1, nil, []string{srv.URL + "/ocsp"}), ca.Root, RevocationStatusUnknown, true},
+		{"fail crl signature", ca.mustSign(t, 1, nil, []string{otherSrv.URL + "/crl"}), ca.Root, RevocationStatusUnknown, true},
+		{"fail nil issuer", ca.mustSign(t, 1, nil, crlURL), nil, RevocationStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.CheckCRL(tt.cert, tt.issuer)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RevocationChecker.CheckCRL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RevocationChecker.CheckCRL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationChecker_Check(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	srv := newRevocationServer(t, ca)
+	ocspServer := []string{srv.URL + "/ocsp"}
+	crlURL := []string{srv.URL + "/crl"}
+	errorURL := []string{srv.URL + "/error"}
+
+	c := new(RevocationChecker)
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    RevocationStatus
+		wantErr bool
+	}{
+		{"ok ocsp", ca.mustSign(t, ca.Revoked.Int64(), ocspServer, crlURL), RevocationStatusRevoked, false},
+		{"ok crl", ca.mustSign(t, ca.Revoked.Int64(), nil, crlURL), RevocationStatusRevoked, false},
+		{"ok crl after ocsp error", ca.mustSign(t, ca.Revoked.Int64(), errorURL, crlURL), RevocationStatusRevoked, false},
+		{"ok unknown", ca.mustSign(t, 1, nil, nil), RevocationStatusUnknown, false},
+		{"fail ocsp error", ca.mustSign(t, 1, errorURL, nil), RevocationStatusUnknown, true},
+		{"fail crl error", ca.mustSign(t, 1, nil, errorURL), RevocationStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Check(tt.cert, ca.Root)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RevocationChecker.Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RevocationChecker.Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationChecker_VerifyChains(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	srv := newRevocationServer(t, ca)
+	ocspServer := []string{srv.URL + "/ocsp"}
+
+	good := []*x509.Certificate{ca.mustSign(t, 1, ocspServer, nil), ca.Root}
+	revoked := []*x509.Certificate{ca.mustSign(t, ca.Revoked.Int64(), ocspServer, nil), ca.Root}
+	unknown := []*x509.Certificate{ca.mustSign(t, 1, nil, nil), ca.Root}
+
+	type fields struct {
+		HardFail bool
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		chains  [][]*x509.Certificate
+		want    [][]*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", fields{false}, [][]*x509.Certificate{good}, [][]*x509.Certificate{good}, false},
+		{"ok root only", fields{true}, [][]*x509.Certificate{{ca.Root}}, [][]*x509.Certificate{{ca.Root}}, false},
+		{"ok without revoked", fields{false}, [][]*x509.Certificate{revoked, good, unknown}, [][]*x509.Certificate{good, unknown}, false},
+		{"ok hard fail", fields{true}, [][]*x509.Certificate{revoked, good, unknown}, [][]*x509.Certificate{good}, false},
+		{"fail revoked", fields{false}, [][]*x509.Certificate{revoked}, nil, true},
+		{"fail hard fail unknown", fields{true}, [][]*x509.Certificate{unknown}, nil, true},
+		{"fail empty", fields{false}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &RevocationChecker{
+				HardFail: tt.fields.HardFail,
+			}
+			got, err := c.VerifyChains(tt.chains)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RevocationChecker.VerifyChains() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RevocationChecker.VerifyChains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChainsWithRevocation(t *testing.T) {
+	ca := mustRevocationCA(t, "Test CA")
+	srv := newRevocationServer(t, ca)
+	ocspServer := []string{srv.URL + "/ocsp"}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(mustRevocationCA(t, "Other CA").Root)
+
+	good := ca.mustSign(t, 1, ocspServer, nil)
+	revoked := ca.mustSign(t, ca.Revoked.Int64(), ocspServer, nil)
+	unknown := ca.mustSign(t, 2, nil, nil)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		roots   *x509.CertPool
+		checker *RevocationChecker
+		want    [][]*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", good, roots, nil, [][]*x509.Certificate{{good, ca.Root}}, false},
+		{"ok checker", good, roots, &RevocationChecker{Client: srv.Client()}, [][]*x509.Certificate{{good, ca.Root}}, false},
+		{"ok unknown", unknown, roots, nil, [][]*x509.Certificate{{unknown, ca.Root}}, false},
+		{"fail revoked", revoked, roots, nil, nil, true},
+		{"fail hard fail", unknown, roots, &RevocationChecker{HardFail: true}, nil, true},
+		{"fail verify", good, otherRoots, nil, nil, true},
+		{"fail nil", nil, roots, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyChainsWithRevocation(tt.cert, x509.VerifyOptions{
+				Roots:     tt.roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			}, tt.checker)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyChainsWithRevocation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VerifyChainsWithRevocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
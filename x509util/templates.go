@@ -17,6 +17,7 @@ const (
 	AuthorizationCrtKey   = "AuthorizationCrt"
 	AuthorizationChainKey = "AuthorizationChain"
 	WebhooksKey           = "Webhooks"
+	NameConstraintsKey    = "NameConstraints"
 )
 
 // TemplateError represents an error in a template produced by the fail
@@ -126,6 +127,12 @@ func (t TemplateData) SetCertificateRequest(cr *x509.CertificateRequest) {
 	t.SetInsecure(CertificateRequestKey, NewCertificateRequestFromX509(cr))
 }
 
+// SetNameConstraints sets the given name constraints in the template data. They
+// are used in the DefaultConstrainedIntermediateTemplate.
+func (t TemplateData) SetNameConstraints(v NameConstraints) {
+	t.Set(NameConstraintsKey, v)
+}
+
 // SetWebhook sets the given webhook response in the webhooks template data.
 func (t TemplateData) SetWebhook(webhookName string, data interface{}) {
 	if webhooksMap, ok := t[WebhooksKey].(map[string]interface{}); ok {
@@ -201,6 +208,19 @@ const DefaultIntermediateTemplate = `{
 	}
 }`
 
+// DefaultConstrainedIntermediateTemplate is a template that can be used to
+// generate an intermediate certificate with the name constraints set using
+// TemplateData.SetNameConstraints.
+const DefaultConstrainedIntermediateTemplate = `{
+	"subject": {{ toJson .Subject }},
+	"keyUsage": ["certSign", "crlSign"],
+	"basicConstraints": {
+		"isCA": true,
+		"maxPathLen": 0
+	},
+	"nameConstraints": {{ toJson .NameConstraints }}
+}`
+
 // DefaultRootTemplate is a template that can be used to generate a root
 // certificate.
 const DefaultRootTemplate = `{
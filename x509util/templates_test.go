@@ -183,6 +183,30 @@ func TestTemplateData_SetSANs(t *testing.T) {
 	}
 }
 
+func TestTemplateData_SetNameConstraints(t *testing.T) {
+	nc := NameConstraints{
+		Critical:            true,
+		PermittedDNSDomains: []string{"example.com"},
+	}
+	tests := []struct {
+		name string
+		td   TemplateData
+		nc   NameConstraints
+		want TemplateData
+	}{
+		{"ok", TemplateData{}, nc, TemplateData{NameConstraintsKey: nc}},
+		{"overwrite", TemplateData{NameConstraintsKey: NameConstraints{ExcludedDNSDomains: []string{"example.org"}}}, nc, TemplateData{NameConstraintsKey: nc}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.td.SetNameConstraints(tt.nc)
+			if !reflect.DeepEqual(tt.td, tt.want) {
+				t.Errorf("TemplateData.SetNameConstraints() = %v, want %v", tt.td, tt.want)
+			}
+		})
+	}
+}
+
 func TestTemplateData_SetSubjectAlternativeNames(t *testing.T) {
 	type args struct {
 		sans []SubjectAlternativeName
@@ -8,6 +8,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
 	"math/big"
 	"net"
 	"net/url"
@@ -88,6 +89,35 @@ func CreateSANs(sans []string) []SubjectAlternativeName {
 	return sanTypes
 }
 
+// ToX5C returns the given certificates as a list of standard base64 encoded
+// DER certificates, the format used in the x5c header of a JWS or JWK. The
+// certificate containing the key must be the first one.
+func ToX5C(certs []*x509.Certificate) []string {
+	x5c := make([]string, len(certs))
+	for i, cert := range certs {
+		x5c[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	return x5c
+}
+
+// FromX5C parses a list of standard base64 encoded DER certificates, like the
+// ones in the x5c header of a JWS or JWK, and returns the certificates.
+func FromX5C(x5c []string) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, len(x5c))
+	for i, s := range x5c {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding x5c certificate %d", i)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing x5c certificate %d", i)
+		}
+		certs[i] = cert
+	}
+	return certs, nil
+}
+
 // generateSerialNumber returns a random serial number.
 func generateSerialNumber() (*big.Int, error) {
 	limit := new(big.Int).Lsh(big.NewInt(1), 128)
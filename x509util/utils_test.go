@@ -3,6 +3,7 @@ package x509util
 import (
 	"crypto"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"net"
 	"net/url"
@@ -205,3 +206,69 @@ func TestSanitizeName(t *testing.T) {
 		})
 	}
 }
+
+func TestToX5C(t *testing.T) {
+	google := decodeCertificateFile(t, "testdata/google.crt")
+	smallstep := decodeCertificateFile(t, "testdata/smallstep.crt")
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		want  []string
+	}{
+		{"ok", []*x509.Certificate{google}, []string{base64.StdEncoding.EncodeToString(google.Raw)}},
+		{"ok chain", []*x509.Certificate{smallstep, google}, []string{
+			base64.StdEncoding.EncodeToString(smallstep.Raw),
+			base64.StdEncoding.EncodeToString(google.Raw),
+		}},
+		{"ok empty", []*x509.Certificate{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToX5C(tt.certs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToX5C() = %v, want %v", got, tt.want)
+			}
+			certs, err := FromX5C(got)
+			if err != nil {
+				t.Fatalf("FromX5C() error = %v", err)
+			}
+			if !reflect.DeepEqual(certs, tt.certs) {
+				t.Errorf("FromX5C() = %v, want %v", certs, tt.certs)
+			}
+		})
+	}
+}
+
+func TestFromX5C(t *testing.T) {
+	google := decodeCertificateFile(t, "testdata/google.crt")
+	smallstep := decodeCertificateFile(t, "testdata/smallstep.crt")
+
+	tests := []struct {
+		name    string
+		x5c     []string
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", []string{base64.StdEncoding.EncodeToString(google.Raw)}, []*x509.Certificate{google}, false},
+		{"ok chain", []string{
+			base64.StdEncoding.EncodeToString(smallstep.Raw),
+			base64.StdEncoding.EncodeToString(google.Raw),
+		}, []*x509.Certificate{smallstep, google}, false},
+		{"fail url encoding", []string{base64.RawURLEncoding.EncodeToString(google.Raw)}, nil, true},
+		{"fail base64", []string{"not base64!"}, nil, true},
+		{"fail certificate", []string{base64.StdEncoding.EncodeToString([]byte("not a certificate"))}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromX5C(tt.x5c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FromX5C() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromX5C() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}